@@ -0,0 +1,323 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pivotalservices/ignition/uaa"
+	"github.com/pivotalservices/ignition/user"
+	"github.com/pkg/errors"
+)
+
+// AdminOrg is an ignition org, and the account names of the users that manage
+// it
+type AdminOrg struct {
+	cloudfoundry.Organization
+	Owners []string `json:"owners"`
+}
+
+// ExperimenterConfig is the configuration that ignition uses to provision
+// orgs, as shown to admins
+type ExperimenterConfig struct {
+	OrgPrefix              string                   `json:"org_prefix"`
+	OrgCountUpdateInterval string                   `json:"org_count_update_interval"`
+	QuotaName              string                   `json:"quota_name"`
+	QuotaID                string                   `json:"quota_id"`
+	ISOSegmentName         string                   `json:"iso_segment_name"`
+	ISOSegmentID           string                   `json:"iso_segment_id"`
+	OrgTTL                 string                   `json:"org_ttl"`
+	OrgExpiryWarning       string                   `json:"org_expiry_warning"`
+	OrgReapInterval        string                   `json:"org_reap_interval"`
+	OrgReapDryRun          bool                     `json:"org_reap_dry_run"`
+	TemplateFile           string                   `json:"template_file,omitempty"`
+	Template               cloudfoundry.OrgTemplate `json:"template"`
+	RunningSecurityGroups  []string                 `json:"running_security_groups"`
+	StagingSecurityGroups  []string                 `json:"staging_security_groups"`
+}
+
+// AdminConfigHandler shows admins the configuration used to provision orgs
+func AdminConfigHandler(c ExperimenterConfig) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(c)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// AdminOrgsHandler lists every ignition org in each of the foundations, or in
+// the one named by the foundation query parameter, with its owners and when it
+// was created
+func AdminOrgsHandler(foundations []Foundation) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		logger := logging.FromContext(req.Context())
+		selected := foundations
+		if req.URL.Query().Get("foundation") != "" {
+			f, ok := adminFoundation(w, req, foundations)
+			if !ok {
+				return
+			}
+			selected = []Foundation{*f}
+		}
+		result := []AdminOrg{}
+		for _, f := range selected {
+			orgs, err := cloudfoundry.OrgsForQuotaID(f.QuotaID, f.AppsURL, f.CC)
+			if err != nil {
+				logger.Error("could not list ignition orgs", "foundation", f.Name, "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			for _, o := range orgs {
+				o.Foundation = f.Name
+				result = append(result, adminOrg(o, f.CC, logger))
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// AdminUserOrgHandler looks up the ignition org for the user with the account
+// name in the route's account variable, in the foundation named by the
+// foundation query parameter
+func AdminUserOrgHandler(orgPrefix string, foundations []Foundation) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		logger := logging.FromContext(req.Context())
+		accountName := mux.Vars(req)["account"]
+		if strings.TrimSpace(accountName) == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f, ok := adminFoundation(w, req, foundations)
+		if !ok {
+			return
+		}
+		userID, err := f.UAA.UserIDForAccountName(accountName)
+		if err != nil {
+			if _, ok := errors.Cause(err).(uaa.UserNotFoundError); !ok {
+				logger.Error("could not find user", "account_name", accountName, "foundation", f.Name, "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if strings.TrimSpace(userID) == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		org, err := FindOrgForUser(OrganizationName(orgPrefix, accountName), f.AppsURL, userID, f.QuotaID, f.CC)
+		if err != nil {
+			if _, ok := err.(OrgNotFoundError); !ok {
+				logger.Error("could not find org", "account_name", accountName, "foundation", f.Name, "error", err)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		org.Foundation = f.Name
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(adminOrg(*org, f.CC, logger))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// AdminDeleteOrgHandler deletes the ignition org with the GUID in the route's
+// guid variable, in the foundation named by the foundation query parameter,
+// and everything in it. The org is deleted in the background.
+func AdminDeleteOrgHandler(l Locker, foundations []Foundation) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		logger := logging.FromContext(req.Context())
+		f, ok := adminFoundation(w, req, foundations)
+		if !ok {
+			return
+		}
+		a := f.CC
+		org, ok := ignitionOrg(w, req, f.AppsURL, f.QuotaID, a)
+		if !ok {
+			return
+		}
+		unlock, err := l.Lock(org.Name)
+		if err != nil {
+			logger.Error("could not lock org", "org", org.Name, "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer unlock()
+
+		err = a.DeleteOrg(org.GUID, true, true)
+		if err != nil {
+			logger.Error("could not delete org", "org", org.Name, "foundation", f.Name, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		audit.Record(audit.Event{Type: audit.EventOrgDeleted, Actor: actor(req), Org: org.Name, OrgGUID: org.GUID, Reason: "deleted by admin"})
+		w.WriteHeader(http.StatusAccepted)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// AdminResetOrgHandler tears down the ignition org with the GUID in the route's
+// guid variable, in the foundation named by the foundation query parameter,
+// and provisions a fresh one for the user that manages it
+func AdminResetOrgHandler(t cloudfoundry.OrgTemplate, l Locker, foundations []Foundation) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		logger := logging.FromContext(req.Context())
+		f, ok := adminFoundation(w, req, foundations)
+		if !ok {
+			return
+		}
+		a := f.CC
+		org, ok := ignitionOrg(w, req, f.AppsURL, f.QuotaID, a)
+		if !ok {
+			return
+		}
+		managers, err := a.ListOrgManagers(org.GUID)
+		if err != nil {
+			logger.Error("could not list managers for org", "org", org.Name, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if len(managers) == 0 {
+			http.Error(w, "the org has no manager to reset it for", http.StatusConflict)
+			return
+		}
+		unlock, err := l.Lock(org.Name)
+		if err != nil {
+			logger.Error("could not lock org", "org", org.Name, "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer unlock()
+
+		logger.Info("resetting org for admin", "org", org.Name, "foundation", f.Name, "admin", actor(req))
+		reset, err := ResetOrgForUser(org.Name, f.AppsURL, managers[0].GUID, f.QuotaID, f.ISOSegmentID, t, a)
+		if err != nil {
+			logger.Error("could not reset org", "org", org.Name, "error", err)
+			writeProvisioningError(w, err)
+			return
+		}
+		reset.Foundation = f.Name
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(reset)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// SessionRevoker revokes every session belonging to a user
+type SessionRevoker interface {
+	RevokeOwner(owner string) (int, error)
+}
+
+// AdminRevokeSessionsHandler logs the user with the account name in the
+// route's account variable out of every one of their sessions. Sessions can
+// only be revoked when they are stored on the server, so r may be nil.
+func AdminRevokeSessionsHandler(r SessionRevoker) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		logger := logging.FromContext(req.Context())
+		if r == nil {
+			http.Error(w, "sessions are stored in cookies and cannot be revoked", http.StatusNotImplemented)
+			return
+		}
+		accountName := strings.TrimSpace(mux.Vars(req)["account"])
+		if accountName == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		n, err := r.RevokeOwner(accountName)
+		if err != nil {
+			logger.Error("could not revoke sessions", "account_name", accountName, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		audit.Record(audit.Event{Type: audit.EventSessionsRevoked, Actor: actor(req), AccountName: accountName, Reason: "revoked by admin"})
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Revoked int `json:"revoked"`
+		}{Revoked: n})
+	}
+	return http.HandlerFunc(fn)
+}
+
+// adminFoundation returns the foundation named by the request's foundation
+// query parameter, or the first foundation when it is not set, responding with
+// 404 if there is no such foundation
+func adminFoundation(w http.ResponseWriter, req *http.Request, foundations []Foundation) (*Foundation, bool) {
+	name := strings.TrimSpace(req.URL.Query().Get("foundation"))
+	if name == "" {
+		return &foundations[0], true
+	}
+	f := findFoundation(foundations, name)
+	if f == nil {
+		http.Error(w, fmt.Sprintf("there is no foundation named %s", name), http.StatusNotFound)
+		return nil, false
+	}
+	return f, true
+}
+
+// ignitionOrg finds the ignition org with the GUID in the route's guid
+// variable, responding with 404 if there is no such org
+func ignitionOrg(w http.ResponseWriter, req *http.Request, appsURL, quotaID string, a cloudfoundry.API) (*cloudfoundry.Organization, bool) {
+	guid := mux.Vars(req)["guid"]
+	orgs, err := cloudfoundry.OrgsForQuotaID(quotaID, appsURL, a)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("could not list ignition orgs", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	for i := range orgs {
+		if strings.EqualFold(orgs[i].GUID, guid) {
+			return &orgs[i], true
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	return nil, false
+}
+
+// adminOrg adds the org's owners to the org; an org whose owners cannot be
+// found is listed without them
+func adminOrg(o cloudfoundry.Organization, a cloudfoundry.API, logger *logging.Logger) AdminOrg {
+	owners, err := cloudfoundry.ManagerNamesForOrg(o.GUID, a)
+	if err != nil {
+		logger.Error("could not get managers for org", "org", o.Name, "error", err)
+	}
+	if owners == nil {
+		owners = []string{}
+	}
+	return AdminOrg{Organization: o, Owners: owners}
+}
+
+// actor is the email of the admin making the request
+func actor(req *http.Request) string {
+	profile, err := user.ProfileFromContext(req.Context())
+	if err != nil {
+		return ""
+	}
+	return profile.Email
+}
+
+// AdminReaperHandler shows admins the most recent report of the Reaper of
+// each foundation, naming the idle orgs that were, or would be, warned or
+// deleted. A foundation's report is null until its Reaper has run.
+func AdminReaperHandler(reapers map[string]*Reaper) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		result := map[string]*ReapReport{}
+		for name, r := range reapers {
+			result[name] = r.Report()
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,313 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/pivotalservices/ignition/uaa"
+	"github.com/pivotalservices/ignition/uaa/uaafakes"
+	"github.com/pivotalservices/ignition/user"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestAdminHandlers(t *testing.T) {
+	spec.Run(t, "AdminHandlers", testAdminHandlers, spec.Report(report.Terminal{}))
+}
+
+func testAdminHandlers(t *testing.T, when spec.G, it spec.S) {
+	var (
+		c, emea    *cloudfoundryfakes.FakeAPI
+		u, emeaUAA *uaafakes.FakeAPI
+		r          *mux.Router
+		w          *httptest.ResponseRecorder
+	)
+
+	serve := func(method, path string) {
+		req := httptest.NewRequest(method, path, nil)
+		req = req.WithContext(user.WithProfile(req.Context(), &user.Profile{Email: "admin@example.net"}))
+		r.ServeHTTP(w, req)
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		c = &cloudfoundryfakes.FakeAPI{}
+		u = &uaafakes.FakeAPI{}
+		emea = &cloudfoundryfakes.FakeAPI{}
+		emeaUAA = &uaafakes.FakeAPI{}
+		w = httptest.NewRecorder()
+		r = mux.NewRouter()
+		foundations := []api.Foundation{
+			{Name: "default", AppsURL: "https://apps.example.net", QuotaID: "test-quota-id", ISOSegmentID: "test-iso-segment-id", UAA: u, CC: c},
+			{Name: "emea", AppsURL: "https://apps.example.eu", QuotaID: "emea-quota-id", ISOSegmentID: "emea-iso-segment-id", UAA: emeaUAA, CC: emea},
+		}
+		r.Handle("/orgs", api.AdminOrgsHandler(foundations)).Methods(http.MethodGet)
+		r.Handle("/orgs/{guid}", api.AdminDeleteOrgHandler(&api.LocalLocker{}, foundations)).Methods(http.MethodDelete)
+		r.Handle("/orgs/{guid}/reset", api.AdminResetOrgHandler(playground, &api.LocalLocker{}, foundations)).Methods(http.MethodPost)
+		r.Handle("/users/{account}/org", api.AdminUserOrgHandler("ignition", foundations)).Methods(http.MethodGet)
+
+		c.ListOrgsReturns([]cloudfoundry.Organization{
+			cloudfoundry.Organization{
+				GUID:                "test-org-guid",
+				Name:                "ignition-testuser",
+				QuotaDefinitionGUID: "test-quota-id",
+				CreatedAt:           "2018-04-01T12:00:00Z",
+			},
+			cloudfoundry.Organization{
+				GUID:                "other-org-guid",
+				Name:                "someone-elses-org",
+				QuotaDefinitionGUID: "other-quota-id",
+			},
+		}, nil)
+		c.ListOrgManagersReturns([]cloudfoundry.User{
+			cloudfoundry.User{GUID: "test-user-id", Username: "testuser@example.net"},
+		}, nil)
+		c.CreateOrgReturns(cloudfoundry.Organization{
+			GUID:                "test-new-org-guid",
+			Name:                "ignition-testuser",
+			QuotaDefinitionGUID: "test-quota-id",
+		}, nil)
+		emea.ListOrgsReturns([]cloudfoundry.Organization{
+			cloudfoundry.Organization{
+				GUID:                "emea-org-guid",
+				Name:                "ignition-emeauser",
+				QuotaDefinitionGUID: "emea-quota-id",
+			},
+		}, nil)
+		emea.ListOrgManagersReturns([]cloudfoundry.User{
+			cloudfoundry.User{GUID: "emea-user-id", Username: "emeauser@example.net"},
+		}, nil)
+	})
+
+	when("listing orgs", func() {
+		it("lists the ignition orgs with their owners and creation times", func() {
+			serve(http.MethodGet, "/orgs")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var orgs []api.AdminOrg
+			Expect(json.NewDecoder(w.Body).Decode(&orgs)).To(Succeed())
+			Expect(orgs).To(HaveLen(2))
+			Expect(orgs[0].GUID).To(Equal("test-org-guid"))
+			Expect(orgs[0].Foundation).To(Equal("default"))
+			Expect(orgs[0].CreatedAt).To(Equal("2018-04-01T12:00:00Z"))
+			Expect(orgs[0].Owners).To(Equal([]string{"testuser@example.net"}))
+			Expect(orgs[1].GUID).To(Equal("emea-org-guid"))
+			Expect(orgs[1].Foundation).To(Equal("emea"))
+			Expect(orgs[1].Owners).To(Equal([]string{"emeauser@example.net"}))
+		})
+
+		it("lists the ignition orgs in the requested foundation", func() {
+			serve(http.MethodGet, "/orgs?foundation=emea")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var orgs []api.AdminOrg
+			Expect(json.NewDecoder(w.Body).Decode(&orgs)).To(Succeed())
+			Expect(orgs).To(HaveLen(1))
+			Expect(orgs[0].GUID).To(Equal("emea-org-guid"))
+			Expect(c.ListOrgsCallCount()).To(Equal(0))
+		})
+
+		it("is not found when the requested foundation does not exist", func() {
+			serve(http.MethodGet, "/orgs?foundation=apac")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		it("lists an org without owners when they cannot be found", func() {
+			c.ListOrgManagersReturns(nil, errors.New("test error"))
+			serve(http.MethodGet, "/orgs")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring(`"owners":[]`))
+		})
+
+		it("is an internal server error when the orgs cannot be listed", func() {
+			c.ListOrgsReturns(nil, errors.New("test error"))
+			serve(http.MethodGet, "/orgs")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	when("looking up a user's org", func() {
+		it("returns the user's org", func() {
+			u.UserIDForAccountNameReturns("test-user-id", nil)
+			serve(http.MethodGet, "/users/testuser@example.net/org")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(u.UserIDForAccountNameArgsForCall(0)).To(Equal("testuser@example.net"))
+			var org api.AdminOrg
+			Expect(json.NewDecoder(w.Body).Decode(&org)).To(Succeed())
+			Expect(org.Name).To(Equal("ignition-testuser"))
+			Expect(org.Owners).To(Equal([]string{"testuser@example.net"}))
+		})
+
+		it("returns the user's org in the requested foundation", func() {
+			emeaUAA.UserIDForAccountNameReturns("emea-user-id", nil)
+			serve(http.MethodGet, "/users/emeauser@example.net/org?foundation=emea")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(u.UserIDForAccountNameCallCount()).To(Equal(0))
+			Expect(emea.ListOrgsArgsForCall(0)).To(Equal(cloudfoundry.OrgQuery{UserGUID: "emea-user-id"}))
+			var org api.AdminOrg
+			Expect(json.NewDecoder(w.Body).Decode(&org)).To(Succeed())
+			Expect(org.GUID).To(Equal("emea-org-guid"))
+			Expect(org.Foundation).To(Equal("emea"))
+		})
+
+		it("is not found when the user does not exist", func() {
+			u.UserIDForAccountNameReturns("", uaa.UserNotFoundError("nobody"))
+			serve(http.MethodGet, "/users/nobody/org")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		it("is an internal server error when the user cannot be looked up", func() {
+			u.UserIDForAccountNameReturns("", errors.New("test error"))
+			serve(http.MethodGet, "/users/testuser/org")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+
+		it("is not found when the user has no org", func() {
+			u.UserIDForAccountNameReturns("test-user-id", nil)
+			c.ListOrgsReturns(nil, nil)
+			serve(http.MethodGet, "/users/testuser/org")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	when("deleting an org", func() {
+		it("deletes the org and records who deleted it", func() {
+			l := audit.NewLog(10)
+			audit.SetDefault(l)
+			defer audit.SetDefault(nil)
+			serve(http.MethodDelete, "/orgs/test-org-guid")
+			Expect(w.Code).To(Equal(http.StatusAccepted))
+			Expect(c.DeleteOrgCallCount()).To(Equal(1))
+			guid, recursive, _ := c.DeleteOrgArgsForCall(0)
+			Expect(guid).To(Equal("test-org-guid"))
+			Expect(recursive).To(BeTrue())
+			events := l.Recent(audit.Filter{Type: audit.EventOrgDeleted})
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Actor).To(Equal("admin@example.net"))
+		})
+
+		it("deletes the org in the requested foundation", func() {
+			serve(http.MethodDelete, "/orgs/emea-org-guid?foundation=emea")
+			Expect(w.Code).To(Equal(http.StatusAccepted))
+			Expect(emea.DeleteOrgCallCount()).To(Equal(1))
+			Expect(c.DeleteOrgCallCount()).To(Equal(0))
+
+			w = httptest.NewRecorder()
+			serve(http.MethodDelete, "/orgs/emea-org-guid")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		it("does not delete an org that was not created by ignition", func() {
+			serve(http.MethodDelete, "/orgs/other-org-guid")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+			Expect(c.DeleteOrgCallCount()).To(Equal(0))
+		})
+
+		it("is an internal server error when the org cannot be deleted", func() {
+			c.DeleteOrgReturns(errors.New("test error"))
+			serve(http.MethodDelete, "/orgs/test-org-guid")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	when("resetting an org", func() {
+		it("tears down the org and creates it again for its manager", func() {
+			serve(http.MethodPost, "/orgs/test-org-guid/reset")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring("test-new-org-guid"))
+			Expect(c.DeleteOrgCallCount()).To(Equal(1))
+			Expect(c.CreateOrgCallCount()).To(Equal(1))
+			org, user := c.AssociateOrgManagerArgsForCall(0)
+			Expect(org).To(Equal("test-new-org-guid"))
+			Expect(user).To(Equal("test-user-id"))
+		})
+
+		it("resets the org in the requested foundation", func() {
+			emea.CreateOrgReturns(cloudfoundry.Organization{GUID: "emea-new-org-guid", Name: "ignition-emeauser", QuotaDefinitionGUID: "emea-quota-id"}, nil)
+			serve(http.MethodPost, "/orgs/emea-org-guid/reset?foundation=emea")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring(`"foundation":"emea"`))
+			Expect(c.CreateOrgCallCount()).To(Equal(0))
+			_, quotaID := emea.CreateOrgArgsForCall(0)
+			Expect(quotaID).To(Equal("emea-quota-id"))
+			_, user := emea.AssociateOrgManagerArgsForCall(0)
+			Expect(user).To(Equal("emea-user-id"))
+		})
+
+		it("is a conflict when the org has no manager", func() {
+			c.ListOrgManagersReturns(nil, nil)
+			serve(http.MethodPost, "/orgs/test-org-guid/reset")
+			Expect(w.Code).To(Equal(http.StatusConflict))
+			Expect(c.DeleteOrgCallCount()).To(Equal(0))
+		})
+
+		it("does not reset an org that was not created by ignition", func() {
+			serve(http.MethodPost, "/orgs/other-org-guid/reset")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	when("revoking a user's sessions", func() {
+		it("revokes the sessions and reports how many were revoked", func() {
+			l := audit.NewLog(10)
+			audit.SetDefault(l)
+			defer audit.SetDefault(nil)
+			revoker := &fakeRevoker{revoked: 2}
+			r.Handle("/users/{account}/sessions", api.AdminRevokeSessionsHandler(revoker)).Methods(http.MethodDelete)
+			serve(http.MethodDelete, "/users/testuser/sessions")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring(`"revoked":2`))
+			Expect(revoker.owner).To(Equal("testuser"))
+			events := l.Recent(audit.Filter{Type: audit.EventSessionsRevoked})
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].AccountName).To(Equal("testuser"))
+			Expect(events[0].Actor).To(Equal("admin@example.net"))
+		})
+
+		it("is not implemented when sessions cannot be revoked", func() {
+			r.Handle("/users/{account}/sessions", api.AdminRevokeSessionsHandler(nil)).Methods(http.MethodDelete)
+			serve(http.MethodDelete, "/users/testuser/sessions")
+			Expect(w.Code).To(Equal(http.StatusNotImplemented))
+		})
+
+		it("is an internal server error when the sessions cannot be revoked", func() {
+			r.Handle("/users/{account}/sessions", api.AdminRevokeSessionsHandler(&fakeRevoker{err: errors.New("test error")})).Methods(http.MethodDelete)
+			serve(http.MethodDelete, "/users/testuser/sessions")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	it("shows the experimenter config", func() {
+		w := httptest.NewRecorder()
+		api.AdminConfigHandler(api.ExperimenterConfig{OrgPrefix: "ignition", OrgTTL: "720h0m0s", Template: playground}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(`"org_prefix":"ignition"`))
+		Expect(w.Body.String()).To(ContainSubstring(`"org_ttl":"720h0m0s"`))
+		Expect(w.Body.String()).To(ContainSubstring(`"playground"`))
+	})
+
+	it("shows the report of each foundation's reaper", func() {
+		w := httptest.NewRecorder()
+		api.AdminReaperHandler(map[string]*api.Reaper{"default": &api.Reaper{}}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{"default": null}`))
+	})
+}
+
+type fakeRevoker struct {
+	owner   string
+	revoked int
+	err     error
+}
+
+func (f *fakeRevoker) RevokeOwner(owner string) (int, error) {
+	f.owner = owner
+	return f.revoked, f.err
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pivotalservices/ignition/audit"
+)
+
+// defaultAuditLimit is the number of events returned when the request does not
+// set a limit
+const defaultAuditLimit = 100
+
+// AuditHandler returns the most recent audit events, newest first. The type,
+// account_name, user_id, org, since (RFC 3339), and limit query parameters
+// filter the events.
+func AuditHandler(l func() *audit.Log) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		q := req.URL.Query()
+		f := audit.Filter{
+			Type:        q.Get("type"),
+			AccountName: q.Get("account_name"),
+			UserID:      q.Get("user_id"),
+			Org:         q.Get("org"),
+			Limit:       defaultAuditLimit,
+		}
+		if since := q.Get("since"); since != "" {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			f.Since = t
+		}
+		if limit := q.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 1 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			f.Limit = n
+		}
+
+		events := l().Recent(f)
+		if events == nil {
+			events = []audit.Event{}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(events)
+	}
+	return http.HandlerFunc(fn)
+}
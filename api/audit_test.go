@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestAuditHandler(t *testing.T) {
+	spec.Run(t, "AuditHandler", testAuditHandler, spec.Report(report.Terminal{}))
+}
+
+func testAuditHandler(t *testing.T, when spec.G, it spec.S) {
+	var (
+		l       *audit.Log
+		handler http.Handler
+		w       *httptest.ResponseRecorder
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		l = audit.NewLog(10)
+		l.Record(audit.Event{Type: audit.EventLogin, AccountName: "one"})
+		l.Record(audit.Event{Type: audit.EventOrgCreated, Org: "ignition-one"})
+		l.Record(audit.Event{Type: audit.EventLogin, AccountName: "two"})
+		handler = api.AuditHandler(func() *audit.Log { return l })
+		w = httptest.NewRecorder()
+	})
+
+	it("returns the recent events, newest first", func() {
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		var events []audit.Event
+		Expect(json.NewDecoder(w.Body).Decode(&events)).To(Succeed())
+		Expect(events).To(HaveLen(3))
+		Expect(events[0].AccountName).To(Equal("two"))
+	})
+
+	it("filters the events", func() {
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?type=login&limit=1", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		var events []audit.Event
+		Expect(json.NewDecoder(w.Body).Decode(&events)).To(Succeed())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].AccountName).To(Equal("two"))
+	})
+
+	it("returns an empty list when there is no audit log", func() {
+		api.AuditHandler(func() *audit.Log { return nil }).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(Equal("[]\n"))
+	})
+
+	it("is a bad request when the query is invalid", func() {
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?limit=none", nil))
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?since=yesterday", nil))
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+}
@@ -3,11 +3,15 @@ package api
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"github.com/pivotalservices/ignition/http/session"
 	"github.com/pivotalservices/ignition/user"
 )
 
+// userInfoFromContext returns the user's ID in the first foundation's UAA,
+// which is empty when the user has not been created there, and their account
+// name
 func userInfoFromContext(ctx context.Context) (userID string, accountName string, err error) {
 	var profile *user.Profile
 	profile, err = user.ProfileFromContext(ctx)
@@ -17,9 +21,6 @@ func userInfoFromContext(ctx context.Context) (userID string, accountName string
 	if profile == nil {
 		return "", "", errors.New("no profile was found")
 	}
-	userID, err = session.UserIDFromContext(ctx)
-	if err != nil {
-		return "", "", err
-	}
-	return userID, profile.AccountName, nil
+	userID, _ = session.UserIDFromContext(ctx)
+	return strings.TrimSpace(userID), profile.AccountName, nil
 }
@@ -47,9 +47,11 @@ func testUserInfoFromContext(t *testing.T, when spec.G, it spec.S) {
 		})
 
 		when("there is no user id", func() {
-			it("errors", func() {
-				_, _, err := userInfoFromContext(ctx)
-				Expect(err).To(HaveOccurred())
+			it("returns an empty user id and the account name", func() {
+				userID, accountName, err := userInfoFromContext(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(userID).To(BeEmpty())
+				Expect(accountName).To(Equal("test-user"))
 			})
 		})
 
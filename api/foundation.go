@@ -0,0 +1,173 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/uaa"
+	"github.com/pivotalservices/ignition/user"
+	"github.com/pkg/errors"
+)
+
+// Foundation is a Cloud Foundry foundation that orgs can be provisioned in.
+// Users are issued sessions with their ID in the first foundation's UAA, when
+// they have one there.
+type Foundation struct {
+	Name         string
+	AppsURL      string
+	QuotaID      string
+	ISOSegmentID string
+	UAAOrigin    string
+	UAA          uaa.API
+	CC           cloudfoundry.API
+
+	// QuotaFallback is set when the foundation's orgs are given the default
+	// quota because ignition's quota could not be found; orgs other than
+	// ignition's can have the default quota too
+	QuotaFallback bool
+
+	// Groups, when set, restricts the foundation to members of the groups
+	Groups []string
+}
+
+// FoundationNotAllowedError indicates that the user cannot have an org
+// provisioned in the foundation
+type FoundationNotAllowedError string
+
+func (f FoundationNotAllowedError) Error() string {
+	if f == "" {
+		return "no foundation is available to you"
+	}
+	return "foundation " + string(f) + " is not available to you"
+}
+
+// allows returns true when the user can have an org provisioned in the
+// foundation
+func (f *Foundation) allows(profile *user.Profile) bool {
+	if len(f.Groups) == 0 {
+		return true
+	}
+	for _, g := range profile.Groups {
+		for _, name := range f.Groups {
+			if strings.EqualFold(g, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SelectFoundation returns the foundation that the user's org is provisioned
+// in. A user that the identity provider assigns to a foundation always uses
+// it. Other users can request any foundation that allows them; by default
+// they use the first foundation that is restricted to one of their groups, or
+// else the first foundation that is not restricted.
+func SelectFoundation(foundations []Foundation, profile *user.Profile, requested string) (*Foundation, error) {
+	requested = strings.TrimSpace(requested)
+	if profile.Foundation != "" {
+		if requested != "" && !strings.EqualFold(requested, profile.Foundation) {
+			return nil, FoundationNotAllowedError(requested)
+		}
+		f := findFoundation(foundations, profile.Foundation)
+		if f == nil {
+			return nil, FoundationNotAllowedError(profile.Foundation)
+		}
+		return f, nil
+	}
+	if requested != "" {
+		f := findFoundation(foundations, requested)
+		if f == nil || !f.allows(profile) {
+			return nil, FoundationNotAllowedError(requested)
+		}
+		return f, nil
+	}
+
+	var unrestricted *Foundation
+	for i := range foundations {
+		f := &foundations[i]
+		if len(f.Groups) == 0 {
+			if unrestricted == nil {
+				unrestricted = f
+			}
+			continue
+		}
+		if f.allows(profile) {
+			return f, nil
+		}
+	}
+	if unrestricted == nil {
+		return nil, FoundationNotAllowedError("")
+	}
+	return unrestricted, nil
+}
+
+// findFoundation returns the foundation with the name, or nil if there is no
+// such foundation
+func findFoundation(foundations []Foundation, name string) *Foundation {
+	for i := range foundations {
+		if strings.EqualFold(foundations[i].Name, name) {
+			return &foundations[i]
+		}
+	}
+	return nil
+}
+
+// foundationUserID returns the user's ID in the foundation's UAA. The ID in
+// the first foundation is the one the user's session was issued with, if it
+// has one. When create is set, a user that cannot be found in the foundation's
+// UAA is created.
+func foundationUserID(foundations []Foundation, f *Foundation, userID string, profile *user.Profile, create bool) (string, error) {
+	if f.Name == foundations[0].Name && userID != "" {
+		return userID, nil
+	}
+	id, err := f.UAA.UserIDForAccountName(profile.AccountName)
+	if err == nil && strings.TrimSpace(id) != "" {
+		return id, nil
+	}
+	if err == nil {
+		err = uaa.UserNotFoundError(profile.AccountName)
+	}
+	if _, ok := errors.Cause(err).(uaa.UserNotFoundError); !ok {
+		return "", errors.Wrapf(err, "could not find user [%s] in foundation [%s]", profile.AccountName, f.Name)
+	}
+	if !create {
+		return "", errors.Wrapf(err, "user [%s] does not exist in foundation [%s]", profile.AccountName, f.Name)
+	}
+	id, err = f.UAA.CreateUser(profile.AccountName, f.UAAOrigin, profile.AccountName, profile.Email)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not create user [%s] in foundation [%s]", profile.AccountName, f.Name)
+	}
+	if strings.TrimSpace(id) == "" {
+		return "", errors.Errorf("could not create user [%s] in foundation [%s]", profile.AccountName, f.Name)
+	}
+	audit.Record(audit.Event{Type: audit.EventUserCreated, AccountName: profile.AccountName, Email: profile.Email, UserID: id})
+	return id, nil
+}
+
+// FindOrgsForUser returns the user's org in each of the foundations that they
+// have one in
+func FindOrgsForUser(name, userID string, profile *user.Profile, foundations []Foundation) ([]cloudfoundry.Organization, error) {
+	var orgs []cloudfoundry.Organization
+	for i := range foundations {
+		f := &foundations[i]
+		id, err := foundationUserID(foundations, f, userID, profile, false)
+		if err != nil {
+			if _, ok := errors.Cause(err).(uaa.UserNotFoundError); ok {
+				// the user has not had an org in the foundation
+				continue
+			}
+			return nil, err
+		}
+		org, err := FindOrgForUser(name, f.AppsURL, id, f.QuotaID, f.CC)
+		if err != nil {
+			if _, ok := err.(OrgNotFoundError); ok {
+				continue
+			}
+			return nil, errors.Wrapf(err, "could not find org in foundation [%s]", f.Name)
+		}
+		org.Foundation = f.Name
+		orgs = append(orgs, *org)
+	}
+	return orgs, nil
+}
@@ -0,0 +1,235 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/uaa"
+	"github.com/pivotalservices/ignition/uaa/uaafakes"
+	"github.com/pivotalservices/ignition/user"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestSelectFoundation(t *testing.T) {
+	spec.Run(t, "SelectFoundation", testSelectFoundation, spec.Report(report.Terminal{}))
+}
+
+func testSelectFoundation(t *testing.T, when spec.G, it spec.S) {
+	var foundations []api.Foundation
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		foundations = []api.Foundation{
+			{Name: "us-east"},
+			{Name: "emea", Groups: []string{"emea"}},
+			{Name: "apac", Groups: []string{"apac", "asia"}},
+		}
+	})
+
+	it("selects the first unrestricted foundation by default", func() {
+		f, err := api.SelectFoundation(foundations, &user.Profile{}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Name).To(Equal("us-east"))
+	})
+
+	it("selects the first foundation restricted to one of the user's groups", func() {
+		f, err := api.SelectFoundation(foundations, &user.Profile{Groups: []string{"engineering", "Asia"}}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Name).To(Equal("apac"))
+	})
+
+	it("selects the requested foundation when it allows the user", func() {
+		f, err := api.SelectFoundation(foundations, &user.Profile{Groups: []string{"emea"}}, "US-East")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Name).To(Equal("us-east"))
+
+		_, err = api.SelectFoundation(foundations, &user.Profile{Groups: []string{"emea"}}, "apac")
+		Expect(err).To(Equal(api.FoundationNotAllowedError("apac")))
+		_, err = api.SelectFoundation(foundations, &user.Profile{}, "unknown")
+		Expect(err).To(Equal(api.FoundationNotAllowedError("unknown")))
+	})
+
+	it("selects the foundation the user is assigned to", func() {
+		profile := &user.Profile{Foundation: "emea"}
+		f, err := api.SelectFoundation(foundations, profile, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Name).To(Equal("emea"))
+
+		_, err = api.SelectFoundation(foundations, profile, "us-east")
+		Expect(err).To(HaveOccurred())
+		_, err = api.SelectFoundation(foundations, &user.Profile{Foundation: "unknown"}, "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("errors when no foundation allows the user", func() {
+		_, err := api.SelectFoundation(foundations[1:], &user.Profile{}, "")
+		Expect(err).To(Equal(api.FoundationNotAllowedError("")))
+		Expect(err.Error()).To(Equal("no foundation is available to you"))
+	})
+}
+
+func TestFoundationsHandler(t *testing.T) {
+	spec.Run(t, "OrganizationHandler with foundations", testFoundationsHandler, spec.Report(report.Terminal{}))
+}
+
+func testFoundationsHandler(t *testing.T, when spec.G, it spec.S) {
+	var (
+		r           *http.Request
+		w           *httptest.ResponseRecorder
+		primary     *cloudfoundryfakes.FakeAPI
+		emea        *cloudfoundryfakes.FakeAPI
+		emeaUAA     *uaafakes.FakeAPI
+		profile     *user.Profile
+		foundations []api.Foundation
+		handler     func() http.Handler
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		w = httptest.NewRecorder()
+		primary = &cloudfoundryfakes.FakeAPI{}
+		emea = &cloudfoundryfakes.FakeAPI{}
+		emeaUAA = &uaafakes.FakeAPI{}
+		emeaUAA.UserIDForAccountNameReturns("", uaa.UserNotFoundError("testuser@test.com"))
+		emeaUAA.CreateUserReturns("emea-user-id", nil)
+		foundations = []api.Foundation{
+			{Name: "default", AppsURL: "http://apps.example.net", QuotaID: "default-quota-id", ISOSegmentID: "default-iso-segment-id", CC: primary},
+			{Name: "emea", AppsURL: "http://apps.example.eu", QuotaID: "emea-quota-id", ISOSegmentID: "emea-iso-segment-id", UAAOrigin: "emea-sso", UAA: emeaUAA, CC: emea, Groups: []string{"emea"}},
+		}
+		profile = &user.Profile{AccountName: "testuser@test.com", Email: "testuser@test.com"}
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(user.WithProfile(session.ContextWithUserID(r.Context(), "test-user-id"), profile))
+		handler = func() http.Handler {
+			return api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, foundations)
+		}
+		emea.CreateOrgReturns(cloudfoundry.Organization{GUID: "emea-org-guid", Name: "ignition-testuser", QuotaDefinitionGUID: "emea-quota-id"}, nil)
+		primary.CreateOrgReturns(cloudfoundry.Organization{GUID: "default-org-guid", Name: "ignition-testuser", QuotaDefinitionGUID: "default-quota-id"}, nil)
+	})
+
+	it("provisions the org in the foundation for the user's groups", func() {
+		profile.Groups = []string{"emea"}
+		handler().ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		j, err := simplejson.NewFromReader(w.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(j.GetIndex(0).Get("foundation").MustString()).To(Equal("emea"))
+		Expect(j.GetIndex(0).Get("url").MustString()).To(Equal("http://apps.example.eu/organizations/emea-org-guid"))
+		Expect(primary.CreateOrgCallCount()).To(Equal(0))
+
+		// the user is created in the foundation's UAA and granted roles there
+		Expect(emeaUAA.CreateUserCallCount()).To(Equal(1))
+		username, origin, _, email := emeaUAA.CreateUserArgsForCall(0)
+		Expect(username).To(Equal("testuser@test.com"))
+		Expect(origin).To(Equal("emea-sso"))
+		Expect(email).To(Equal("testuser@test.com"))
+		_, quotaID := emea.CreateOrgArgsForCall(0)
+		Expect(quotaID).To(Equal("emea-quota-id"))
+		_, userID := emea.AssociateOrgManagerArgsForCall(0)
+		Expect(userID).To(Equal("emea-user-id"))
+	})
+
+	it("provisions the org in the requested foundation", func() {
+		profile.Groups = []string{"emea"}
+		r.URL.RawQuery = "foundation=default"
+		handler().ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(primary.CreateOrgCallCount()).To(Equal(1))
+		Expect(emea.CreateOrgCallCount()).To(Equal(0))
+		_, userID := primary.AssociateOrgManagerArgsForCall(0)
+		Expect(userID).To(Equal("test-user-id"))
+	})
+
+	it("creates the user in the first foundation's UAA when their session has no ID", func() {
+		primaryUAA := &uaafakes.FakeAPI{}
+		primaryUAA.UserIDForAccountNameReturns("", uaa.UserNotFoundError("testuser@test.com"))
+		primaryUAA.CreateUserReturns("default-user-id", nil)
+		foundations[0].UAA = primaryUAA
+		foundations[0].UAAOrigin = "default-sso"
+		r = r.WithContext(user.WithProfile(context.Background(), profile))
+		handler().ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(primaryUAA.CreateUserCallCount()).To(Equal(1))
+		_, origin, _, _ := primaryUAA.CreateUserArgsForCall(0)
+		Expect(origin).To(Equal("default-sso"))
+		Expect(emeaUAA.CreateUserCallCount()).To(Equal(0))
+		_, userID := primary.AssociateOrgManagerArgsForCall(0)
+		Expect(userID).To(Equal("default-user-id"))
+	})
+
+	it("is forbidden when the requested foundation does not allow the user", func() {
+		r.URL.RawQuery = "foundation=emea"
+		handler().ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+		Expect(emea.CreateOrgCallCount()).To(Equal(0))
+		Expect(primary.CreateOrgCallCount()).To(Equal(0))
+	})
+
+	it("is unavailable when the user cannot be created in the foundation", func() {
+		profile.Foundation = "emea"
+		emeaUAA.CreateUserReturns("", errors.New("test error"))
+		handler().ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(emea.CreateOrgCallCount()).To(Equal(0))
+	})
+
+	it("returns the user's orgs in every foundation", func() {
+		emeaUAA.UserIDForAccountNameReturns("emea-user-id", nil)
+		primary.ListOrgsReturns([]cloudfoundry.Organization{{GUID: "default-org-guid", Name: "ignition-testuser", QuotaDefinitionGUID: "default-quota-id"}}, nil)
+		emea.ListOrgsReturns([]cloudfoundry.Organization{{GUID: "emea-org-guid", Name: "ignition-testuser", QuotaDefinitionGUID: "emea-quota-id"}}, nil)
+		handler().ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		j, err := simplejson.NewFromReader(w.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(j.MustArray()).To(HaveLen(2))
+		Expect(j.GetIndex(0).Get("guid").MustString()).To(Equal("default-org-guid"))
+		Expect(j.GetIndex(0).Get("foundation").MustString()).To(Equal("default"))
+		Expect(j.GetIndex(1).Get("guid").MustString()).To(Equal("emea-org-guid"))
+		Expect(j.GetIndex(1).Get("foundation").MustString()).To(Equal("emea"))
+		Expect(emea.ListOrgsArgsForCall(0)).To(Equal(cloudfoundry.OrgQuery{UserGUID: "emea-user-id"}))
+		Expect(primary.CreateOrgCallCount()).To(Equal(0))
+		Expect(emea.CreateOrgCallCount()).To(Equal(0))
+	})
+
+	it("is not found when a foundation's orgs cannot be retrieved", func() {
+		emeaUAA.UserIDForAccountNameReturns("emea-user-id", nil)
+		emea.ListOrgsReturns(nil, errors.New("test error"))
+		handler().ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+		Expect(primary.CreateOrgCallCount()).To(Equal(0))
+	})
+
+	it("does not provision an org when a foundation's UAA cannot be searched for the user", func() {
+		emeaUAA.UserIDForAccountNameReturns("", errors.New("test error"))
+		handler().ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+		Expect(emeaUAA.CreateUserCallCount()).To(Equal(0))
+		Expect(primary.CreateOrgCallCount()).To(Equal(0))
+		Expect(emea.CreateOrgCallCount()).To(Equal(0))
+	})
+
+	it("resets the org in the foundation that it is in", func() {
+		emeaUAA.UserIDForAccountNameReturns("emea-user-id", nil)
+		emea.ListOrgsReturns([]cloudfoundry.Organization{{GUID: "emea-org-guid", Name: "ignition-testuser", QuotaDefinitionGUID: "emea-quota-id"}}, nil)
+		r.Method = http.MethodPost
+		api.ResetOrganizationHandler("ignition", playground, &api.LocalLocker{}, foundations).ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(emea.DeleteOrgCallCount()).To(Equal(1))
+		Expect(emea.CreateOrgCallCount()).To(Equal(1))
+		Expect(primary.DeleteOrgCallCount()).To(Equal(0))
+		Expect(primary.CreateOrgCallCount()).To(Equal(0))
+		j, err := simplejson.NewFromReader(w.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(j.Get("foundation").MustString()).To(Equal("emea"))
+	})
+}
@@ -2,13 +2,14 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
-	"net/url"
+	"sync"
 	"time"
 
 	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pivotalservices/ignition/metrics"
+	"github.com/pkg/errors"
 )
 
 // Info is metadata that ignition API clients can use to display their UX
@@ -19,25 +20,27 @@ type Info struct {
 	CollectAnalytics         bool
 }
 
-// InfoHandler writes the contents of the provided Info to the response
+// InfoHandler writes the contents of the provided Info to the response. The
+// ignition org count is the number of orgs with ignition's quota in every
+// foundation.
 func InfoHandler(
 	companyName string,
 	spaceName string,
-	orgQuotaID string,
 	collectAnalytics bool,
 	updateFreq time.Duration,
-	orgQuerier cloudfoundry.OrganizationQuerier,
+	foundations []Foundation,
 ) http.Handler {
 
-	orgCount := getIgnitionOrgCount(orgQuotaID, orgQuerier)
-	startBackgroundOrgCountUpdater(orgQuotaID, orgQuerier, &orgCount, updateFreq)
+	orgCount := &orgCounter{}
+	orgCount.update(foundations)
+	startBackgroundOrgCountUpdater(foundations, orgCount, updateFreq)
 
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		i := Info{
 			CompanyName:              companyName,
 			ExperimentationSpaceName: spaceName,
-			IgnitionOrgCount:         orgCountOrDefault(orgCount),
+			IgnitionOrgCount:         orgCount.get(),
 			CollectAnalytics:         collectAnalytics,
 		}
 		json.NewEncoder(w).Encode(i)
@@ -45,50 +48,60 @@ func InfoHandler(
 	return http.HandlerFunc(fn)
 }
 
-func getIgnitionOrgCount(orgQuotaID string, orgQuerier cloudfoundry.OrganizationQuerier) *int {
-	orgCount, err := queryIgnitionOrgCount(orgQuotaID, orgQuerier)
-	if err != nil {
-		// ignition org count is non-critical - so log it and continue
-		log.Println(fmt.Sprintf("[ERROR] Could not get updated org count: %s", err.Error()))
-	}
-	return orgCount
+// orgCounter holds the most recent ignition org count, which is read by
+// requests while the background updater replaces it
+type orgCounter struct {
+	mu    sync.RWMutex
+	count int
 }
 
-func queryIgnitionOrgCount(orgQuotaID string, orgQuerier cloudfoundry.OrganizationQuerier) (*int, error) {
-	orgs, err := orgQuerier.ListOrgsByQuery(url.Values{})
+func (c *orgCounter) get() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.count
+}
+
+// update queries the ignition org count, and keeps it when it is not zero;
+// an error is logged and leaves the count unchanged
+func (c *orgCounter) update(foundations []Foundation) {
+	count, err := queryIgnitionOrgCount(foundations)
 	if err != nil {
-		return nil, err
+		// ignition org count is non-critical - so log it and continue
+		logging.Default().Error("could not get updated org count", "error", err)
+		return
+	}
+	metrics.SetIgnitionOrgCount(count)
+	if count > 0 {
+		c.mu.Lock()
+		c.count = count
+		c.mu.Unlock()
 	}
+}
 
+// queryIgnitionOrgCount sums the orgs with ignition's quota in each
+// foundation; it errors if any foundation cannot be queried, rather than
+// reporting a partial count
+func queryIgnitionOrgCount(foundations []Foundation) (int, error) {
 	count := 0
-	for _, o := range orgs {
-		if o.QuotaDefinitionGuid == orgQuotaID {
-			count++
+	for _, f := range foundations {
+		orgs, err := f.CC.ListOrgs(cloudfoundry.OrgQuery{})
+		if err != nil {
+			return 0, errors.Wrapf(err, "could not list the orgs in foundation [%s]", f.Name)
+		}
+		for _, o := range orgs {
+			if o.QuotaDefinitionGUID == f.QuotaID {
+				count++
+			}
 		}
 	}
-	return &count, nil
+	return count, nil
 }
 
-func startBackgroundOrgCountUpdater(
-	orgQuotaID string,
-	orgQuerier cloudfoundry.OrganizationQuerier,
-	orgCount **int,
-	updateFreq time.Duration) {
+func startBackgroundOrgCountUpdater(foundations []Foundation, orgCount *orgCounter, updateFreq time.Duration) {
 	go func() {
 		for {
 			time.Sleep(updateFreq)
-			oc := getIgnitionOrgCount(orgQuotaID, orgQuerier)
-			if oc != nil && *oc > 0 {
-				*(orgCount) = oc
-			}
+			orgCount.update(foundations)
 		}
 	}()
 }
-
-func orgCountOrDefault(orgCount *int) int {
-	o := 0
-	if orgCount != nil {
-		o = *orgCount
-	}
-	return o
-}
@@ -8,9 +8,9 @@ import (
 	"time"
 
 	"github.com/bitly/go-simplejson"
-	cfclient "github.com/cloudfoundry-community/go-cfclient"
 	. "github.com/onsi/gomega"
 	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/cloudfoundry"
 	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -30,19 +30,19 @@ func testInfoHandler(t *testing.T, when spec.G, it spec.S) {
 		it.Before(func() {
 			a := &cloudfoundryfakes.FakeAPI{}
 			handler = api.InfoHandler(
-				"Test Company", "Test Space", "ignition-quota-definition-guid", false, 100*time.Millisecond, a)
+				"Test Company", "Test Space", false, 100*time.Millisecond, []api.Foundation{{Name: "default", QuotaID: "ignition-quota-definition-guid", CC: a}})
 
 			// stub this out after the handler has initialized, the goroutine will update
-			a.ListOrgsByQueryReturns([]cfclient.Org{
-				cfclient.Org{
-					Guid:                "4321",
+			a.ListOrgsReturns([]cloudfoundry.Organization{
+				cloudfoundry.Organization{
+					GUID:                "4321",
 					Name:                "orgprefix-joe",
-					QuotaDefinitionGuid: "ignition-quota-definition-guid",
+					QuotaDefinitionGUID: "ignition-quota-definition-guid",
 				},
-				cfclient.Org{
-					Guid:                "5432",
+				cloudfoundry.Organization{
+					GUID:                "5432",
 					Name:                "orgprefix-larry",
-					QuotaDefinitionGuid: "ignition-quota-definition-guid",
+					QuotaDefinitionGUID: "ignition-quota-definition-guid",
 				},
 			}, nil)
 		})
@@ -66,24 +66,24 @@ func testInfoHandler(t *testing.T, when spec.G, it spec.S) {
 
 		it.Before(func() {
 			a := &cloudfoundryfakes.FakeAPI{}
-			a.ListOrgsByQueryReturns([]cfclient.Org{
-				cfclient.Org{
-					Guid:                "1234",
+			a.ListOrgsReturns([]cloudfoundry.Organization{
+				cloudfoundry.Organization{
+					GUID:                "1234",
 					Name:                "some random org",
-					QuotaDefinitionGuid: "other-quota-definition-guid",
+					QuotaDefinitionGUID: "other-quota-definition-guid",
 				},
-				cfclient.Org{
-					Guid:                "4321",
+				cloudfoundry.Organization{
+					GUID:                "4321",
 					Name:                "orgprefix-joe",
-					QuotaDefinitionGuid: "ignition-quota-definition-guid",
+					QuotaDefinitionGUID: "ignition-quota-definition-guid",
 				},
-				cfclient.Org{
-					Guid:                "5432",
+				cloudfoundry.Organization{
+					GUID:                "5432",
 					Name:                "orgprefix-larry",
-					QuotaDefinitionGuid: "ignition-quota-definition-guid",
+					QuotaDefinitionGUID: "ignition-quota-definition-guid",
 				},
 			}, nil)
-			handler = api.InfoHandler("Test Company", "Test Space", "ignition-quota-definition-guid", false, time.Minute, a)
+			handler = api.InfoHandler("Test Company", "Test Space", false, time.Minute, []api.Foundation{{Name: "default", QuotaID: "ignition-quota-definition-guid", CC: a}})
 		})
 
 		it("returns the configured company name, space name, and ignition org count", func() {
@@ -102,13 +102,46 @@ func testInfoHandler(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("there are several foundations", func() {
+		var handler http.Handler
+
+		it.Before(func() {
+			east := &cloudfoundryfakes.FakeAPI{}
+			east.ListOrgsReturns([]cloudfoundry.Organization{
+				cloudfoundry.Organization{GUID: "1234", Name: "orgprefix-joe", QuotaDefinitionGUID: "east-quota-guid"},
+				cloudfoundry.Organization{GUID: "2345", Name: "some random org", QuotaDefinitionGUID: "west-quota-guid"},
+			}, nil)
+			west := &cloudfoundryfakes.FakeAPI{}
+			west.ListOrgsReturns([]cloudfoundry.Organization{
+				cloudfoundry.Organization{GUID: "4321", Name: "orgprefix-larry", QuotaDefinitionGUID: "west-quota-guid"},
+				cloudfoundry.Organization{GUID: "5432", Name: "orgprefix-moe", QuotaDefinitionGUID: "west-quota-guid"},
+			}, nil)
+			handler = api.InfoHandler("Test Company", "Test Space", false, time.Minute, []api.Foundation{
+				{Name: "east", QuotaID: "east-quota-guid", CC: east},
+				{Name: "west", QuotaID: "west-quota-guid", CC: west},
+			})
+		})
+
+		it("counts the ignition orgs in every foundation", func() {
+			r := httptest.NewRecorder()
+			handler.ServeHTTP(r, httptest.NewRequest(http.MethodGet, "/", nil))
+			Expect(r.Code).To(Equal(http.StatusOK))
+
+			j, err := simplejson.NewFromReader(r.Body)
+			if err != nil {
+				t.Errorf("Error while reading response JSON: %s", err)
+			}
+			Expect(j.GetPath("IgnitionOrgCount").MustInt()).To(Equal(3))
+		})
+	})
+
 	when("the cc api returns an error", func() {
 		var handler http.Handler
 
 		it.Before(func() {
 			a := &cloudfoundryfakes.FakeAPI{}
-			a.ListOrgsByQueryReturns([]cfclient.Org{}, errors.New("Some unknown CC API error"))
-			handler = api.InfoHandler("Test Company", "Test Space", "orgprefix", false, time.Minute, a)
+			a.ListOrgsReturns([]cloudfoundry.Organization{}, errors.New("Some unknown CC API error"))
+			handler = api.InfoHandler("Test Company", "Test Space", false, time.Minute, []api.Foundation{{Name: "default", QuotaID: "orgprefix", CC: a}})
 		})
 
 		it("returns the configured company name, space name, and defaults the org count to 0", func() {
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/metrics"
+	"github.com/pkg/errors"
+)
+
+// jobRetention is how long a finished job is kept so that its status can be
+// reported, and runningJobTTL is how long a shared store keeps a running job
+// that has not been updated, e.g. because the instance running it stopped
+const (
+	jobRetention  = time.Hour
+	runningJobTTL = 15 * time.Minute
+)
+
+// defaultJobRefresh is how often a running job is stored again, so that a
+// shared store keeps it however long its steps take
+const defaultJobRefresh = runningJobTTL / 3
+
+// StepStatus is the progress of a single step of a provisioning job
+type StepStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// Job is the status of an asynchronous request to provision an org
+type Job struct {
+	ID         string                     `json:"id"`
+	OrgName    string                     `json:"org_name"`
+	Foundation string                     `json:"foundation"`
+	State      string                     `json:"state"`
+	Steps      []StepStatus               `json:"steps"`
+	Org        *cloudfoundry.Organization `json:"org,omitempty"`
+	Error      *ProvisioningError         `json:"error,omitempty"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	UpdatedAt  time.Time                  `json:"updated_at"`
+}
+
+// JobStore keeps the status of the most recent job for each org in each
+// foundation. A store that is shared by the instances of ignition lets each of
+// them report the status of a job, and find that it is still running,
+// whichever instance runs it.
+type JobStore interface {
+	// Get returns the most recent job for the org in the foundation, if there
+	// is one
+	Get(foundation, orgName string) (Job, bool, error)
+
+	// Put stores the job as the most recent job for its org in its foundation
+	Put(job Job) error
+}
+
+// jobKey identifies the org in the foundation that a job provisions; orgs in
+// different foundations can have the same name
+func jobKey(foundation, orgName string) string {
+	return foundation + ":" + orgName
+}
+
+// MemoryJobStore is a JobStore kept in memory, which is neither shared with
+// other instances of ignition nor kept when ignition restarts. Finished jobs
+// are kept for an hour. The zero value is ready to use.
+type MemoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+// Get returns the most recent job for the org in the foundation, if there is
+// one
+func (m *MemoryJobStore) Get(foundation, orgName string) (Job, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[jobKey(foundation, orgName)]
+	return job, ok, nil
+}
+
+// Put stores the job as the most recent job for its org in its foundation,
+// and removes the jobs that finished more than an hour ago
+func (m *MemoryJobStore) Put(job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.jobs == nil {
+		m.jobs = make(map[string]Job)
+	}
+	now := time.Now()
+	for key, old := range m.jobs {
+		if old.State != StateRunning && now.Sub(old.UpdatedAt) > jobRetention {
+			delete(m.jobs, key)
+		}
+	}
+	m.jobs[jobKey(job.Foundation, job.OrgName)] = job
+	return nil
+}
+
+// Jobs runs org provisioning in the background and tracks the most recent job
+// for each org in each foundation. The zero value is ready to use.
+type Jobs struct {
+	// Wait is how long a request waits for a new job to finish before
+	// responding that provisioning is still in progress
+	Wait time.Duration
+
+	// Store keeps the status of the jobs; when it is nil, they are kept in
+	// memory, and only the instance of ignition that runs a job knows of it
+	Store JobStore
+
+	// Refresh is how often a running job is stored again, so that a store
+	// that expires running jobs does not expire one that is still running;
+	// it is 5 minutes when zero
+	Refresh time.Duration
+
+	once sync.Once
+	mu   sync.Mutex
+}
+
+type job struct {
+	status Job
+	done   chan struct{}
+
+	// storing is held while the job's status is stored, so that an older
+	// status never replaces a newer one
+	storing sync.Mutex
+}
+
+func (j *Jobs) store() JobStore {
+	j.once.Do(func() {
+		if j.Store == nil {
+			j.Store = &MemoryJobStore{}
+		}
+	})
+	return j.Store
+}
+
+// Get returns the most recent job for the org in the foundation
+func (j *Jobs) Get(foundation, orgName string) (Job, bool, error) {
+	job, ok, err := j.store().Get(foundation, orgName)
+	if err != nil {
+		return Job{}, false, errors.Wrapf(err, "could not get the job for org [%s] in foundation [%s]", orgName, foundation)
+	}
+	return job, ok, nil
+}
+
+// Latest returns the most recent job for the org in any of the foundations
+func (j *Jobs) Latest(foundations []Foundation, orgName string) (Job, bool, error) {
+	var latest Job
+	found := false
+	for _, f := range foundations {
+		job, ok, err := j.Get(f.Name, orgName)
+		if err != nil {
+			return Job{}, false, err
+		}
+		if ok && (!found || job.CreatedAt.After(latest.CreatedAt)) {
+			latest, found = job, true
+		}
+	}
+	return latest, found, nil
+}
+
+// Running returns the job for the org in any of the foundations if it has not
+// yet finished
+func (j *Jobs) Running(foundations []Foundation, orgName string) (Job, bool, error) {
+	for _, f := range foundations {
+		status, ok, err := j.Get(f.Name, orgName)
+		if err != nil {
+			return Job{}, false, err
+		}
+		if ok && status.State == StateRunning {
+			return status, true, nil
+		}
+	}
+	return Job{}, false, nil
+}
+
+// Start runs the pipeline that provisions the org in the foundation in the
+// background and returns its job, waiting up to Wait for it to finish. result
+// returns the org once the pipeline has run successfully, and release is
+// called when the job finishes.
+func (j *Jobs) Start(foundation, orgName string, p *Pipeline, result func() *cloudfoundry.Organization, release func()) Job {
+	now := time.Now()
+	jb := &job{
+		status: Job{
+			ID:         randomID(),
+			OrgName:    orgName,
+			Foundation: foundation,
+			State:      StateRunning,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		},
+		done: make(chan struct{}),
+	}
+	for _, step := range p.Steps {
+		jb.status.Steps = append(jb.status.Steps, StepStatus{Name: step.Name, State: StatePending})
+	}
+	j.put(p, jb)
+	go j.keepAlive(p, jb)
+
+	p.Observer = func(step string, state string, err error) {
+		j.mu.Lock()
+		for i := range jb.status.Steps {
+			if jb.status.Steps[i].Name != step {
+				continue
+			}
+			jb.status.Steps[i].State = state
+			if err != nil {
+				jb.status.Steps[i].Error = err.Error()
+			}
+		}
+		jb.status.UpdatedAt = time.Now()
+		j.mu.Unlock()
+		j.put(p, jb)
+	}
+
+	go func() {
+		defer close(jb.done)
+		defer release()
+		err := p.Run()
+		if err != nil {
+			p.logger().Error("could not provision org", "org", orgName, "foundation", foundation, "job_id", jb.status.ID, "error", err)
+		}
+
+		j.mu.Lock()
+		jb.status.UpdatedAt = time.Now()
+		if err != nil {
+			jb.status.State = StateFailed
+			pe, ok := err.(*ProvisioningError)
+			if !ok {
+				pe = &ProvisioningError{Message: err.Error(), cause: err}
+			}
+			jb.status.Error = pe
+		} else {
+			metrics.OrgCreated()
+			jb.status.State = StateSucceeded
+			jb.status.Org = result()
+		}
+		j.mu.Unlock()
+		j.put(p, jb)
+	}()
+
+	select {
+	case <-jb.done:
+	case <-time.After(j.Wait):
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return jb.snapshot()
+}
+
+// keepAlive stores the job's status again every Refresh until the job
+// finishes
+func (j *Jobs) keepAlive(p *Pipeline, jb *job) {
+	refresh := j.Refresh
+	if refresh <= 0 {
+		refresh = defaultJobRefresh
+	}
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-jb.done:
+			return
+		case <-ticker.C:
+			j.put(p, jb)
+		}
+	}
+}
+
+// put stores the job's current status; the job runs even when its status
+// cannot be stored, as the org's lock keeps another job from starting
+func (j *Jobs) put(p *Pipeline, jb *job) {
+	jb.storing.Lock()
+	defer jb.storing.Unlock()
+	j.mu.Lock()
+	status := jb.snapshot()
+	j.mu.Unlock()
+	err := j.store().Put(status)
+	if err != nil {
+		p.logger().Error("could not store the status of the job", "org", status.OrgName, "foundation", status.Foundation, "job_id", status.ID, "error", err)
+	}
+}
+
+func (jb *job) snapshot() Job {
+	status := jb.status
+	status.Steps = append([]StepStatus(nil), jb.status.Steps...)
+	return status
+}
+
+// randomID returns a random hex string, such as the ID of a job or the token
+// that a lock is held with
+func randomID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
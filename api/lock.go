@@ -0,0 +1,76 @@
+package api
+
+import (
+	"sync"
+)
+
+// Locker serializes the provisioning of orgs for a given key, so that
+// concurrent requests on behalf of the same account do not race to create the
+// same org. Implementations backed by a shared store allow multiple ignition
+// instances to cooperate.
+type Locker interface {
+	// Lock blocks until the lock for key is held, and returns a func that
+	// releases it
+	Lock(key string) (unlock func(), err error)
+}
+
+// LocalLocker is a Locker that only coordinates within a single process. The
+// zero value is ready to use.
+type LocalLocker struct {
+	mu    sync.Mutex
+	locks map[string]*localLock
+}
+
+type localLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// Lock blocks until no other caller in this process holds the lock for key
+func (l *LocalLocker) Lock(key string) (func(), error) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*localLock)
+	}
+	k, ok := l.locks[key]
+	if !ok {
+		k = &localLock{}
+		l.locks[key] = k
+	}
+	k.refs++
+	l.mu.Unlock()
+
+	k.mu.Lock()
+	return func() {
+		k.mu.Unlock()
+		l.mu.Lock()
+		k.refs--
+		if k.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}, nil
+}
+
+// Lockers is a Locker that acquires each of its lockers in order, e.g. a
+// LocalLocker followed by a distributed lock, and releases them in reverse
+type Lockers []Locker
+
+// Lock acquires every lock for key, or none of them if any cannot be acquired
+func (l Lockers) Lock(key string) (func(), error) {
+	var unlocks []func()
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, locker := range l {
+		unlock, err := locker.Lock(key)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return unlockAll, nil
+}
@@ -0,0 +1,121 @@
+package api_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestLocalLocker(t *testing.T) {
+	spec.Run(t, "LocalLocker", testLocalLocker, spec.Report(report.Terminal{}))
+}
+
+func testLocalLocker(t *testing.T, when spec.G, it spec.S) {
+	var l *api.LocalLocker
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		l = &api.LocalLocker{}
+	})
+
+	it("blocks a second caller for the same key until the first unlocks", func() {
+		unlock, err := l.Lock("ignition-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		acquired := make(chan struct{})
+		go func() {
+			unlock2, _ := l.Lock("ignition-test")
+			close(acquired)
+			unlock2()
+		}()
+
+		Consistently(acquired, 50*time.Millisecond).ShouldNot(BeClosed())
+		unlock()
+		Eventually(acquired).Should(BeClosed())
+	})
+
+	it("does not block callers for different keys", func() {
+		unlock, err := l.Lock("ignition-test")
+		Expect(err).NotTo(HaveOccurred())
+		defer unlock()
+
+		acquired := make(chan struct{})
+		go func() {
+			unlock2, _ := l.Lock("ignition-other")
+			close(acquired)
+			unlock2()
+		}()
+		Eventually(acquired).Should(BeClosed())
+	})
+
+	it("serializes many concurrent callers", func() {
+		var wg sync.WaitGroup
+		holders := 0
+		max := 0
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock, _ := l.Lock("ignition-test")
+				holders++
+				if holders > max {
+					max = holders
+				}
+				time.Sleep(time.Millisecond)
+				holders--
+				unlock()
+			}()
+		}
+		wg.Wait()
+		Expect(max).To(Equal(1))
+	})
+}
+
+func TestLockers(t *testing.T) {
+	spec.Run(t, "Lockers", testLockers, spec.Report(report.Terminal{}))
+}
+
+func testLockers(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("acquires and releases every lock", func() {
+		local := &api.LocalLocker{}
+		l := api.Lockers{local, &api.LocalLocker{}}
+		unlock, err := l.Lock("ignition-test")
+		Expect(err).NotTo(HaveOccurred())
+		unlock()
+
+		unlock, err = local.Lock("ignition-test")
+		Expect(err).NotTo(HaveOccurred())
+		unlock()
+	})
+
+	it("releases the locks it holds when a lock cannot be acquired", func() {
+		local := &api.LocalLocker{}
+		l := api.Lockers{local, failingLocker{}}
+		unlock, err := l.Lock("ignition-test")
+		Expect(err).To(HaveOccurred())
+		Expect(unlock).To(BeNil())
+
+		acquired := make(chan struct{})
+		go func() {
+			unlock2, _ := local.Lock("ignition-test")
+			close(acquired)
+			unlock2()
+		}()
+		Eventually(acquired).Should(BeClosed())
+	})
+
+	it("returns the error from the failing lock", func() {
+		_, err := api.Lockers{failingLocker{}}.Lock("ignition-test")
+		Expect(err).To(Equal(errors.New("test error")))
+	})
+}
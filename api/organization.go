@@ -3,100 +3,376 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/pivotalservices/ignition/audit"
 	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pivotalservices/ignition/metrics"
+	"github.com/pivotalservices/ignition/user"
 	"github.com/pkg/errors"
 )
 
-// OrganizationHandler retrieves or creates the user's development organization
-func OrganizationHandler(appsURL, orgPrefix, quotaID, isoSegmentID, spaceName string, a cloudfoundry.API) http.Handler {
+// OrganizationHandler retrieves the user's development organizations across
+// the foundations, or, when the user does not have one, creates one in the
+// foundation selected for them (see SelectFoundation), which the foundation
+// query parameter can request. The locker ensures that only one request
+// provisions the org at a time. Provisioning runs in the background; if it
+// does not finish within the jobs' Wait, the handler responds with 202 and the
+// job's status.
+func OrganizationHandler(orgPrefix string, t cloudfoundry.OrgTemplate, l Locker, j *Jobs, foundations []Foundation) http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		logger := logging.FromContext(req.Context())
 		userID, accountName, err := userInfoFromContext(req.Context())
 		if err != nil {
-			log.Println(err)
+			logger.Error("could not get user info", "error", err)
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
+		profile, _ := user.ProfileFromContext(req.Context())
 
-		if strings.TrimSpace(userID) == "" {
+		orgName := OrganizationName(orgPrefix, accountName)
+		job, running, err := j.Running(foundations, orgName)
+		if err != nil {
+			logger.Error("could not find a running job", "org", orgName, "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if running {
+			writeJobInProgress(w, job)
+			return
+		}
+
+		unlock, err := l.Lock(orgName)
+		if err != nil {
+			logger.Error("could not lock org", "org", orgName, "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		orgs, err := FindOrgsForUser(orgName, userID, profile, foundations)
+		if err != nil {
+			unlock()
+			logger.Error("could not find org", "org", orgName, "error", err)
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
+		if len(orgs) > 0 {
+			unlock()
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(orgs)
+			return
+		}
 
-		orgName := OrganizationName(orgPrefix, accountName)
-		org, err := FindOrgForUser(orgName, appsURL, userID, quotaID, a)
+		f, err := SelectFoundation(foundations, profile, req.URL.Query().Get("foundation"))
 		if err != nil {
-			switch err.(type) {
-			case OrgNotFoundError:
-				org, err = CreateOrgForUser(orgName, appsURL, userID, quotaID, isoSegmentID, spaceName, a)
-				if err != nil {
-					log.Println(err)
-					w.WriteHeader(http.StatusNotFound)
-					return
-				}
-			default:
-				log.Println(err)
-				w.WriteHeader(http.StatusNotFound)
-				return
-			}
+			unlock()
+			logger.Warn("could not select foundation", "org", orgName, "error", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		foundationUserID, err := foundationUserID(foundations, f, userID, profile, true)
+		if err != nil {
+			unlock()
+			logger.Error("could not find user in foundation", "foundation", f.Name, "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(org)
+		// the lock is held until the job finishes
+		p, result := newOrgPipeline(orgName, f.AppsURL, foundationUserID, f.QuotaID, f.ISOSegmentID, t, f.CC)
+		p.Logger = logger
+		job = j.Start(f.Name, orgName, p, func() *cloudfoundry.Organization {
+			org := result()
+			org.Foundation = f.Name
+			return org
+		}, unlock)
+		switch job.State {
+		case StateSucceeded:
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode([]*cloudfoundry.Organization{job.Org})
+		case StateFailed:
+			writeProvisioningError(w, job.Error)
+		default:
+			writeJobInProgress(w, job)
+		}
 	}
 	return http.HandlerFunc(fn)
 }
 
+// writeJobInProgress responds that the org is still being provisioned, and
+// where to check on its progress
+func writeJobInProgress(w http.ResponseWriter, job Job) {
+	w.Header().Set("Location", "/api/v1/organization/status")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
 // OrgNotFoundError indicates that an org cannot be found for the user
 type OrgNotFoundError string
 
 func (o OrgNotFoundError) Error() string {
-	return fmt.Sprintf("organization %s not found", o)
+	return fmt.Sprintf("organization %s not found", string(o))
 }
 
-// CreateOrgForUser creates an org, a default space, and creates or retrieves
-// the user and then assigns that user to org manager, org auditor, space manager,
-// space developer, and space auditor roles
-func CreateOrgForUser(name, appsURL, userID, quotaID, isoSegmentID, spaceName string, a cloudfoundry.API) (*cloudfoundry.Organization, error) {
+// provisioningRetries is the number of times a provisioning step is retried
+// when it fails with a transient error, and provisioningBackoff is the wait
+// before the first retry
+const (
+	provisioningRetries = 3
+	provisioningBackoff = 500 * time.Millisecond
+)
+
+// CreateOrgForUser creates an org and the spaces in the template, assigns the
+// user to org manager, org auditor, and the template's space roles, and binds
+// the template's security groups to its spaces. If the org was created
+// concurrently by another request for the same user, that org is adopted
+// instead. If any step fails, the org is deleted and a
+// *ProvisioningError is returned. The service instances in the template are
+// created last, and any that fail are listed in the org's
+// ServiceInstanceErrors.
+func CreateOrgForUser(name, appsURL, userID, quotaID, isoSegmentID string, t cloudfoundry.OrgTemplate, a cloudfoundry.API) (*cloudfoundry.Organization, error) {
 	// create the user if needed
 	if strings.TrimSpace(userID) == "" {
 		return nil, errors.New("cannot create an org without a valid userID")
 	}
 
-	// check for org existence by name
-
-	// create the org
-	org, err := cloudfoundry.CreateOrg(name, appsURL, quotaID, isoSegmentID, a)
+	p, result := newOrgPipeline(name, appsURL, userID, quotaID, isoSegmentID, t, a)
+	err := p.Run()
 	if err != nil {
 		return nil, err
 	}
+	metrics.OrgCreated()
 
-	// assign the user to org roles
-	_, err = a.AssociateOrgUser(org.GUID, userID)
-	if err != nil {
-		log.Println(err)
+	// return the org
+	return result(), nil
+}
+
+// newOrgPipeline returns the Pipeline that provisions an org for the user,
+// and a func that returns the org once the pipeline has run successfully
+func newOrgPipeline(name, appsURL, userID, quotaID, isoSegmentID string, t cloudfoundry.OrgTemplate, a cloudfoundry.API) (*Pipeline, func() *cloudfoundry.Organization) {
+	var org *cloudfoundry.Organization
+	adopted, attempted := false, false
+	p := &Pipeline{
+		Retries: provisioningRetries,
+		Backoff: provisioningBackoff,
+		Steps: []ProvisioningStep{
+			{
+				// create the org, or adopt it if the user already has it
+				Name: StepOrg,
+				Do: func() error {
+					retried := attempted
+					attempted = true
+					o, err := cloudfoundry.CreateOrgWithQuota(name, appsURL, quotaID, a)
+					if err != nil {
+						if !cloudfoundry.IsOrgNameTakenError(err) {
+							return err
+						}
+						o, adopted, err = adoptOrg(name, appsURL, userID, quotaID, retried, a)
+						if err != nil {
+							return err
+						}
+					}
+					org = o
+					if !adopted {
+						audit.Record(audit.Event{Type: audit.EventOrgCreated, UserID: userID, Org: org.Name, OrgGUID: org.GUID})
+					}
+					return nil
+				},
+				// deleting the org also removes everything created after it;
+				// an adopted org belongs to the request that created it
+				Undo: func() error {
+					if adopted {
+						return nil
+					}
+					err := a.DeleteOrg(org.GUID, true, false)
+					if err != nil {
+						return err
+					}
+					audit.Record(audit.Event{Type: audit.EventOrgDeleted, UserID: userID, Org: org.Name, OrgGUID: org.GUID, Reason: "provisioning failed"})
+					return nil
+				},
+			},
+			{
+				Name: StepISOSegment,
+				Do: func() error {
+					err := cloudfoundry.SetDefaultIsolationSegment(org, isoSegmentID, a)
+					if err != nil {
+						return err
+					}
+					org.DefaultIsolationSegmentGUID = isoSegmentID
+					return nil
+				},
+			},
+			{
+				// assign the user to org roles
+				Name: StepRoles,
+				Do: func() error {
+					err := a.AssociateOrgUser(org.GUID, userID)
+					if err != nil {
+						return errors.Wrapf(err, "could not make user [%s] a user of org [%s]", userID, org.Name)
+					}
+					err = a.AssociateOrgManager(org.GUID, userID)
+					if err != nil {
+						return errors.Wrapf(err, "could not make user [%s] a manager of org [%s]", userID, org.Name)
+					}
+					err = a.AssociateOrgAuditor(org.GUID, userID)
+					if err != nil {
+						return errors.Wrapf(err, "could not make user [%s] an auditor of org [%s]", userID, org.Name)
+					}
+					for _, role := range []string{"org_user", "org_manager", "org_auditor"} {
+						audit.Record(audit.Event{Type: audit.EventRoleGranted, UserID: userID, Org: org.Name, OrgGUID: org.GUID, Role: role})
+					}
+					return nil
+				},
+			},
+		},
 	}
-	_, err = a.AssociateOrgManager(org.GUID, userID)
-	if err != nil {
-		log.Println(err)
+
+	// create the space quotas that the spaces use
+	quotaIDs := make(map[string]string)
+	if len(t.SpaceQuotas) > 0 {
+		p.Steps = append(p.Steps, ProvisioningStep{
+			Name: StepSpaceQuotas,
+			Do: func() error {
+				for _, q := range t.SpaceQuotas {
+					id, err := cloudfoundry.CreateSpaceQuota(q, org.GUID, a)
+					if err != nil {
+						return err
+					}
+					quotaIDs[q.Name] = id
+				}
+				return nil
+			},
+		})
 	}
-	_, err = a.AssociateOrgAuditor(org.GUID, userID)
-	if err != nil {
-		log.Println(err)
+
+	// create each space, assign the user to its roles, and bind its security
+	// groups
+	for _, s := range t.Spaces {
+		space := s
+		p.Steps = append(p.Steps, ProvisioningStep{
+			Name: SpaceStepName(space.Name),
+			Do: func() error {
+				err := cloudfoundry.CreateSpaceFromTemplate(space, org.GUID, userID, quotaIDs[space.Quota], a)
+				if err != nil {
+					if cloudfoundry.IsSpaceNameTakenError(err) {
+						return nil
+					}
+					return err
+				}
+				audit.Record(audit.Event{Type: audit.EventSpaceCreated, UserID: userID, Org: org.Name, OrgGUID: org.GUID, Space: space.Name})
+				for _, role := range space.Roles {
+					audit.Record(audit.Event{Type: audit.EventRoleGranted, UserID: userID, Org: org.Name, OrgGUID: org.GUID, Space: space.Name, Role: "space_" + role})
+				}
+				return nil
+			},
+		})
+		if len(space.RunningSecurityGroups) == 0 && len(space.StagingSecurityGroups) == 0 {
+			continue
+		}
+		p.Steps = append(p.Steps, ProvisioningStep{
+			Name: SecurityGroupsStepName(space.Name),
+			Do: func() error {
+				spaceGUID, err := cloudfoundry.SpaceGUIDForName(space.Name, org.GUID, a)
+				if err != nil {
+					return err
+				}
+				return cloudfoundry.BindSecurityGroups(space, spaceGUID, a, a)
+			},
+		})
+	}
+
+	// create the service instances in each space; an instance that cannot be
+	// created is reported with the org rather than failing provisioning
+	for _, s := range t.Spaces {
+		space := s
+		if len(space.Services) == 0 {
+			continue
+		}
+		p.Steps = append(p.Steps, ProvisioningStep{
+			Name: ServicesStepName(space.Name),
+			Do: func() error {
+				org.ServiceInstanceErrors = append(org.ServiceInstanceErrors, createServiceInstances(space, org.GUID, a, p.logger())...)
+				return nil
+			},
+		})
+	}
+	return p, func() *cloudfoundry.Organization { return org }
+}
+
+// createServiceInstances creates each of the service instances in the space's
+// template, and returns those that could not be created
+func createServiceInstances(space cloudfoundry.SpaceTemplate, orgGUID string, a cloudfoundry.API, logger *logging.Logger) []cloudfoundry.ServiceInstanceError {
+	var failures []cloudfoundry.ServiceInstanceError
+	fail := func(instance cloudfoundry.ServiceInstanceTemplate, err error) {
+		logger.Warn("could not create service instance", "service_instance", instance.Name, "space", space.Name, "error", err)
+		failures = append(failures, cloudfoundry.ServiceInstanceError{
+			Space:   space.Name,
+			Name:    instance.Name,
+			Service: instance.Service,
+			Plan:    instance.Plan,
+			Message: err.Error(),
+		})
 	}
 
-	// create the space and assign the user to all space roles
-	err = cloudfoundry.CreateSpace(spaceName, org.GUID, userID, a)
+	spaceGUID, err := cloudfoundry.SpaceGUIDForName(space.Name, orgGUID, a)
 	if err != nil {
-		log.Println(err)
+		for _, instance := range space.Services {
+			fail(instance, err)
+		}
+		return failures
 	}
+	for _, instance := range space.Services {
+		planGUID, err := cloudfoundry.ServicePlanGUID(instance.Service, instance.Plan, a)
+		if err != nil {
+			fail(instance, err)
+			continue
+		}
+		err = cloudfoundry.CreateServiceInstance(instance, spaceGUID, planGUID, a)
+		if err != nil && !cloudfoundry.IsServiceInstanceNameTakenError(err) {
+			fail(instance, err)
+		}
+	}
+	return failures
+}
 
-	// return the org
-	return org, nil
+// adoptOrg returns the existing org with the given name when it is safe for
+// the user to take it over: it must be an ignition org, and it must either be
+// managed by the user already, in which case it is adopted, or, when retried
+// is set, have no managers yet, because an earlier attempt to create it
+// succeeded but its response was lost. Only an adopted org was provisioned
+// before this request, and is kept when provisioning fails.
+func adoptOrg(name, appsURL, userID, quotaID string, retried bool, a cloudfoundry.API) (*cloudfoundry.Organization, bool, error) {
+	org, err := cloudfoundry.OrgForName(name, appsURL, a)
+	if err != nil {
+		return nil, false, err
+	}
+	if org == nil {
+		return nil, false, errors.Errorf("org [%s] already exists but could not be found", name)
+	}
+	if !strings.EqualFold(org.QuotaDefinitionGUID, quotaID) {
+		return nil, false, errors.Errorf("org [%s] already exists and was not created by ignition", name)
+	}
+	managers, err := a.ListOrgManagers(org.GUID)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not list managers for org [%s]", name)
+	}
+	for _, m := range managers {
+		if m.GUID == userID {
+			return org, true, nil
+		}
+	}
+	if len(managers) > 0 {
+		return nil, false, errors.Errorf("org [%s] already exists and belongs to another user", name)
+	}
+	if !retried {
+		return nil, false, errors.Errorf("org [%s] already exists and is being provisioned by another request", name)
+	}
+	return org, false, nil
 }
 
 // FindOrgForUser returns an OrgNotFoundError if the org is not found, and a
@@ -4,19 +4,27 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bitly/go-simplejson"
 	cfclient "github.com/cloudfoundry-community/go-cfclient"
 	. "github.com/onsi/gomega"
 	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/pivotalservices/ignition/cloudfoundry"
 	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
 	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/uaa"
+	"github.com/pivotalservices/ignition/uaa/uaafakes"
 	"github.com/pivotalservices/ignition/user"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 )
 
+var playground = cloudfoundry.DefaultOrgTemplate("playground")
+
 func TestHandler(t *testing.T) {
 	spec.Run(t, "Handler", testHandler, spec.Report(report.Terminal{}))
 }
@@ -36,20 +44,27 @@ func testHandler(t *testing.T, when spec.G, it spec.S) {
 	when("there is no profile in the context", func() {
 		it("is not found", func() {
 			r = httptest.NewRequest(http.MethodGet, "/", nil)
-			api.OrganizationHandler("http://example.net", "ignition", "test-quota-id", "test-iso-segment-id", "playground", c).ServeHTTP(w, r)
+			api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
 			Expect(w.Code).To(Equal(http.StatusNotFound))
 		})
 	})
 
 	when("there is a profile in the context but no user id", func() {
-		it("is not found", func() {
+		it("is unavailable when the user cannot be created in the foundation", func() {
 			r = httptest.NewRequest(http.MethodGet, "/", nil)
 			profile := &user.Profile{
 				AccountName: "testuser@test.com",
 			}
 			r = r.WithContext(user.WithProfile(r.Context(), profile))
-			api.OrganizationHandler("http://example.net", "ignition", "test-quota-id", "test-iso-segment-id", "playground", c).ServeHTTP(w, r)
-			Expect(w.Code).To(Equal(http.StatusNotFound))
+			u := &uaafakes.FakeAPI{}
+			u.UserIDForAccountNameReturns("", uaa.UserNotFoundError("testuser@test.com"))
+			u.CreateUserReturns("", errors.New("test error"))
+			foundations := singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)
+			foundations[0].UAA = u
+			api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(u.CreateUserCallCount()).To(Equal(1))
+			Expect(c.CreateOrgCallCount()).To(Equal(0))
 		})
 	})
 
@@ -64,28 +79,28 @@ func testHandler(t *testing.T, when spec.G, it spec.S) {
 
 		when("orgs cannot be retrieved", func() {
 			it.Before(func() {
-				c.ListOrgsByQueryReturns(nil, errors.New("test error"))
+				c.ListOrgsReturns(nil, errors.New("test error"))
 			})
 
 			it("is not found", func() {
-				api.OrganizationHandler("http://example.net", "ignition", "test-quota-id", "test-iso-segment-id", "playground", c).ServeHTTP(w, r)
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
 				Expect(w.Code).To(Equal(http.StatusNotFound))
 			})
 		})
 
 		when("there are no orgs for the user", func() {
 			it.Before(func() {
-				c.ListOrgsByQueryReturns(nil, nil)
+				c.ListOrgsReturns(nil, nil)
 			})
 
 			it("creates the org", func() {
-				c.CreateOrgReturns(cfclient.Org{
-					Guid:                        "test-org-guid",
+				c.CreateOrgReturns(cloudfoundry.Organization{
+					GUID:                        "test-org-guid",
 					Name:                        "ignition-testuser",
-					QuotaDefinitionGuid:         "test-quota-id",
-					DefaultIsolationSegmentGuid: "test-iso-segment-id",
+					QuotaDefinitionGUID:         "test-quota-id",
+					DefaultIsolationSegmentGUID: "test-iso-segment-id",
 				}, nil)
-				api.OrganizationHandler("http://example.net", "ignition", "test-quota-id", "test-iso-segment-id", "playground", c).ServeHTTP(w, r)
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
 				Expect(w.Code).To(Equal(http.StatusOK))
 				Expect(w.Body.String()).To(ContainSubstring("ignition-testuser"))
 			})
@@ -93,20 +108,20 @@ func testHandler(t *testing.T, when spec.G, it spec.S) {
 
 		when("there are multiple orgs for the user", func() {
 			it.Before(func() {
-				c.ListOrgsByQueryReturns([]cfclient.Org{
-					cfclient.Org{
-						Guid:                        "test-org-2",
+				c.ListOrgsReturns([]cloudfoundry.Organization{
+					cloudfoundry.Organization{
+						GUID:                        "test-org-2",
 						Name:                        "ignition-testuser1",
-						QuotaDefinitionGuid:         "ignition-quota2-id",
-						DefaultIsolationSegmentGuid: "test-iso-segment-id",
+						QuotaDefinitionGUID:         "ignition-quota2-id",
+						DefaultIsolationSegmentGUID: "test-iso-segment-id",
 						CreatedAt:                   "created-at",
 						UpdatedAt:                   "updated-at",
 					},
-					cfclient.Org{
-						Guid:                        "test-org-1",
+					cloudfoundry.Organization{
+						GUID:                        "test-org-1",
 						Name:                        "ignition-testuser",
-						QuotaDefinitionGuid:         "ignition-quota-id",
-						DefaultIsolationSegmentGuid: "test-iso-segment-id",
+						QuotaDefinitionGUID:         "ignition-quota-id",
+						DefaultIsolationSegmentGUID: "test-iso-segment-id",
 						CreatedAt:                   "created-at",
 						UpdatedAt:                   "updated-at",
 					},
@@ -114,52 +129,503 @@ func testHandler(t *testing.T, when spec.G, it spec.S) {
 			})
 
 			it("selects the correct org when there is a name match", func() {
-				api.OrganizationHandler("http://example.net", "ignition", "test-quota2-id", "test-iso-segment-id", "playground", c).ServeHTTP(w, r)
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota2-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
 				Expect(w.Code).To(Equal(http.StatusOK))
 				Expect(w.Body.String()).To(ContainSubstring("test-org-1"))
 			})
 
 			when("creating an org succeeds", func() {
 				it.Before(func() {
-					c.CreateOrgReturns(cfclient.Org{
-						Guid:                        "test-org-guid",
+					c.CreateOrgReturns(cloudfoundry.Organization{
+						GUID:                        "test-org-guid",
 						Name:                        "ignition1-testuser",
-						QuotaDefinitionGuid:         "test-quota2-id",
-						DefaultIsolationSegmentGuid: "test-iso-segment-id",
+						QuotaDefinitionGUID:         "test-quota2-id",
+						DefaultIsolationSegmentGUID: "test-iso-segment-id",
 					}, nil)
 				})
 
 				it("creates the org when there is no name or quota match", func() {
-					api.OrganizationHandler("http://example.net", "ignition1", "test-quota2-id", "test-iso-segment-id", "playground", c).ServeHTTP(w, r)
+					api.OrganizationHandler("ignition1", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota2-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
 					Expect(w.Code).To(Equal(http.StatusOK))
 					j, err := simplejson.NewFromReader(w.Body)
 					if err != nil {
 						t.Errorf("Error while reading response JSON: %s", err)
 					}
-					Expect(j.GetPath("guid").MustString()).To(Equal("test-org-guid"))
-					Expect(j.GetPath("name").MustString()).To(Equal("ignition1-testuser"))
-					Expect(j.GetPath("quota_definition_guid").MustString()).To(Equal("test-quota2-id"))
-					Expect(j.GetPath("default_isolation_segment_guid").MustString()).To(Equal("test-iso-segment-id"))
+					Expect(j.MustArray()).To(HaveLen(1))
+					org := j.GetIndex(0)
+					Expect(org.GetPath("guid").MustString()).To(Equal("test-org-guid"))
+					Expect(org.GetPath("name").MustString()).To(Equal("ignition1-testuser"))
+					Expect(org.GetPath("quota_definition_guid").MustString()).To(Equal("test-quota2-id"))
+					Expect(org.GetPath("default_isolation_segment_guid").MustString()).To(Equal("test-iso-segment-id"))
+					Expect(org.GetPath("foundation").MustString()).To(Equal("default"))
 				})
 			})
 
 			when("creating an org fails", func() {
 				it.Before(func() {
-					c.CreateOrgReturns(cfclient.Org{}, errors.New("test error"))
+					c.CreateOrgReturns(cloudfoundry.Organization{}, errors.New("test error"))
+				})
+
+				it("is an internal server error describing the failed step", func() {
+					api.OrganizationHandler("ignition1", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota2-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusInternalServerError))
+					j, err := simplejson.NewFromReader(w.Body)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(j.GetPath("step").MustString()).To(Equal("org"))
+					Expect(j.GetPath("transient").MustBool()).To(BeFalse())
+					Expect(c.DeleteOrgCallCount()).To(Equal(0))
+				})
+			})
+
+			when("a step after creating the org fails", func() {
+				it.Before(func() {
+					c.CreateOrgReturns(cloudfoundry.Organization{
+						GUID:                "test-org-guid",
+						Name:                "ignition1-testuser",
+						QuotaDefinitionGUID: "test-quota2-id",
+					}, nil)
+					c.AssociateOrgManagerReturns(errors.New("test error"))
+				})
+
+				it("deletes the org and reports that it was rolled back", func() {
+					api.OrganizationHandler("ignition1", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota2-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusInternalServerError))
+					j, err := simplejson.NewFromReader(w.Body)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(j.GetPath("step").MustString()).To(Equal("roles"))
+					Expect(j.GetPath("rolled_back").MustBool()).To(BeTrue())
+					Expect(c.CreateSpaceCallCount()).To(Equal(0))
+					Expect(c.DeleteOrgCallCount()).To(Equal(1))
+					guid, recursive, _ := c.DeleteOrgArgsForCall(0)
+					Expect(guid).To(Equal("test-org-guid"))
+					Expect(recursive).To(BeTrue())
 				})
 
-				it("is not found", func() {
-					api.OrganizationHandler("http://example.net", "ignition1", "test-quota2-id", "test-iso-segment-id", "playground", c).ServeHTTP(w, r)
-					Expect(w.Code).To(Equal(http.StatusNotFound))
+				it("reports when the org could not be deleted", func() {
+					c.DeleteOrgReturns(errors.New("test error"))
+					api.OrganizationHandler("ignition1", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota2-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+					Expect(w.Code).To(Equal(http.StatusInternalServerError))
+					j, err := simplejson.NewFromReader(w.Body)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(j.GetPath("rolled_back").MustBool()).To(BeFalse())
+					Expect(j.GetPath("rollback_errors").MustStringArray()).To(HaveLen(1))
 				})
 			})
 
 			it("selects the correct org when there is a quota match (but not a name match)", func() {
-				api.OrganizationHandler("http://example.net", "ignition2", "ignition-quota-id", "test-iso-segment-id", "playground", c).ServeHTTP(w, r)
+				api.OrganizationHandler("ignition2", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "ignition-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
 				Expect(w.Code).To(Equal(http.StatusOK))
 				Expect(w.Body.String()).To(ContainSubstring("test-org-1"))
 			})
 		})
+
+		when("the org name has already been taken", func() {
+			var existing cloudfoundry.Organization
+
+			it.Before(func() {
+				existing = cloudfoundry.Organization{
+					GUID:                "test-existing-org-guid",
+					Name:                "ignition-testuser",
+					QuotaDefinitionGUID: "test-quota-id",
+				}
+				c.ListOrgsStub = func(query cloudfoundry.OrgQuery) ([]cloudfoundry.Organization, error) {
+					if query.Name == "ignition-testuser" {
+						return []cloudfoundry.Organization{existing}, nil
+					}
+					return nil, nil
+				}
+				c.CreateOrgReturns(cloudfoundry.Organization{}, cfclient.CloudFoundryError{
+					Code:      30002,
+					ErrorCode: "CF-OrganizationNameTaken",
+				})
+			})
+
+			it("does not adopt an org without managers that another request is provisioning", func() {
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusInternalServerError))
+				Expect(c.AssociateOrgManagerCallCount()).To(Equal(0))
+				Expect(c.DeleteOrgCallCount()).To(Equal(0))
+			})
+
+			it("takes over, and deletes when a later step fails, an org that an earlier attempt created", func() {
+				c.CreateOrgStub = func(name string, quotaID string) (cloudfoundry.Organization, error) {
+					if c.CreateOrgCallCount() == 1 {
+						// the org was created, but the response was lost
+						return cloudfoundry.Organization{}, cfclient.CloudFoundryHTTPError{StatusCode: http.StatusBadGateway}
+					}
+					return cloudfoundry.Organization{}, cfclient.CloudFoundryError{Code: 30002, ErrorCode: "CF-OrganizationNameTaken"}
+				}
+				c.AssociateOrgUserReturns(errors.New("test error"))
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: 5 * time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusInternalServerError))
+				Expect(c.CreateOrgCallCount()).To(Equal(2))
+				Expect(c.DeleteOrgCallCount()).To(Equal(1))
+				guid, _, _ := c.DeleteOrgArgsForCall(0)
+				Expect(guid).To(Equal("test-existing-org-guid"))
+			})
+
+			it("does not delete an adopted org when a later step fails", func() {
+				c.ListOrgManagersReturns([]cloudfoundry.User{cloudfoundry.User{GUID: "test-user-id"}}, nil)
+				c.AssociateOrgUserReturns(errors.New("test error"))
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusInternalServerError))
+				Expect(c.DeleteOrgCallCount()).To(Equal(0))
+			})
+
+			it("adopts the org when the user already manages it", func() {
+				c.ListOrgManagersReturns([]cloudfoundry.User{cloudfoundry.User{GUID: "test-user-id"}}, nil)
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Body.String()).To(ContainSubstring("test-existing-org-guid"))
+			})
+
+			it("does not adopt an org managed by another user", func() {
+				c.ListOrgManagersReturns([]cloudfoundry.User{cloudfoundry.User{GUID: "another-user-id"}}, nil)
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusInternalServerError))
+				Expect(c.AssociateOrgManagerCallCount()).To(Equal(0))
+				Expect(c.DeleteOrgCallCount()).To(Equal(0))
+			})
+
+			it("does not adopt an org that was not created by ignition", func() {
+				existing.QuotaDefinitionGUID = "another-quota-id"
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusInternalServerError))
+				Expect(c.AssociateOrgManagerCallCount()).To(Equal(0))
+				Expect(c.DeleteOrgCallCount()).To(Equal(0))
+			})
+		})
+
+		when("the lock cannot be acquired", func() {
+			it("is unavailable", func() {
+				l := api.Lockers{&api.LocalLocker{}, failingLocker{}}
+				api.OrganizationHandler("ignition", playground, l, &api.Jobs{}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(c.ListOrgsCallCount()).To(Equal(0))
+			})
+		})
+
+		when("the user makes concurrent requests", func() {
+			it.Before(func() {
+				var mu sync.Mutex
+				var created []cloudfoundry.Organization
+				c.ListOrgsStub = func(query cloudfoundry.OrgQuery) ([]cloudfoundry.Organization, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					return created, nil
+				}
+				c.CreateOrgStub = func(name, quotaGUID string) (cloudfoundry.Organization, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					org := cloudfoundry.Organization{
+						GUID:                "test-org-guid",
+						Name:                name,
+						QuotaDefinitionGUID: quotaGUID,
+					}
+					created = append(created, org)
+					return org, nil
+				}
+			})
+
+			it("only creates the org once", func() {
+				handler := api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Wait: time.Second}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c))
+				var wg sync.WaitGroup
+				codes := make([]int, 5)
+				for i := range codes {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						rec := httptest.NewRecorder()
+						handler.ServeHTTP(rec, r)
+						codes[i] = rec.Code
+					}(i)
+				}
+				wg.Wait()
+				Expect(c.CreateOrgCallCount()).To(Equal(1))
+				for _, code := range codes {
+					Expect(code).To(Or(Equal(http.StatusOK), Equal(http.StatusAccepted)))
+				}
+			})
+		})
+
+		when("provisioning takes longer than the jobs wait", func() {
+			var (
+				jobs    *api.Jobs
+				proceed chan struct{}
+			)
+
+			it.Before(func() {
+				jobs = &api.Jobs{Store: &api.MemoryJobStore{}}
+				proceed = make(chan struct{})
+				c.CreateOrgStub = func(name, quotaGUID string) (cloudfoundry.Organization, error) {
+					<-proceed
+					return cloudfoundry.Organization{
+						GUID:                "test-org-guid",
+						Name:                name,
+						QuotaDefinitionGUID: quotaGUID,
+					}, nil
+				}
+			})
+
+			it.After(func() {
+				select {
+				case <-proceed:
+				default:
+					close(proceed)
+				}
+			})
+
+			it("is accepted, and reports the job's progress", func() {
+				handler := api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, jobs, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c))
+				handler.ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusAccepted))
+				Expect(w.Header().Get("Location")).To(Equal("/api/v1/organization/status"))
+				j, err := simplejson.NewFromReader(w.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(j.GetPath("state").MustString()).To(Equal("running"))
+				Expect(j.GetPath("steps").MustArray()).To(HaveLen(4))
+				id := j.GetPath("id").MustString()
+				Expect(id).NotTo(BeEmpty())
+
+				w = httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusAccepted))
+				Expect(w.Body.String()).To(ContainSubstring(id))
+
+				// another instance that shares the job store finds the job
+				w = httptest.NewRecorder()
+				api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, &api.Jobs{Store: jobs.Store}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusAccepted))
+				Expect(w.Body.String()).To(ContainSubstring(id))
+
+				close(proceed)
+				Eventually(func() string {
+					job, _, _ := jobs.Get("default", "ignition-testuser")
+					return job.State
+				}).Should(Equal("succeeded"))
+				Expect(c.CreateOrgCallCount()).To(Equal(1))
+			})
+		})
+	})
+}
+
+// singleFoundation returns a foundation that uses the Cloud Controller API
+func singleFoundation(appsURL, quotaID, isoSegmentID string, a cloudfoundry.API) []api.Foundation {
+	return []api.Foundation{{
+		Name:         "default",
+		AppsURL:      appsURL,
+		QuotaID:      quotaID,
+		ISOSegmentID: isoSegmentID,
+		CC:           a,
+	}}
+}
+
+type failingLocker struct{}
+
+func (failingLocker) Lock(key string) (func(), error) {
+	return nil, errors.New("test error")
+}
+
+func TestCreateOrgForUser(t *testing.T) {
+	spec.Run(t, "CreateOrgForUser", testCreateOrgForUser, spec.Report(report.Terminal{}))
+}
+
+func testCreateOrgForUser(t *testing.T, when spec.G, it spec.S) {
+	var c *cloudfoundryfakes.FakeAPI
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		c = &cloudfoundryfakes.FakeAPI{}
+		c.CreateOrgReturns(cloudfoundry.Organization{
+			GUID:                "test-org-guid",
+			Name:                "ignition-testuser",
+			QuotaDefinitionGUID: "test-quota-id",
+		}, nil)
+		c.CreateSpaceReturns(cloudfoundry.Space{GUID: "test-space-guid"}, nil)
+		c.CreateSpaceQuotaReturns(cloudfoundry.SpaceQuota{GUID: "test-space-quota-guid"}, nil)
+	})
+
+	it("errors without a user id", func() {
+		org, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", " ", "test-quota-id", "test-iso-segment-id", playground, c)
+		Expect(err).To(HaveOccurred())
+		Expect(org).To(BeNil())
+		Expect(c.CreateOrgCallCount()).To(Equal(0))
+	})
+
+	it("creates each space in the template", func() {
+		template := cloudfoundry.OrgTemplate{
+			SpaceQuotas: []cloudfoundry.SpaceQuotaTemplate{
+				cloudfoundry.SpaceQuotaTemplate{Name: "small", MemoryLimit: 1024},
+			},
+			Spaces: []cloudfoundry.SpaceTemplate{
+				cloudfoundry.SpaceTemplate{
+					Name:     "dev",
+					Roles:    []string{cloudfoundry.SpaceRoleManager, cloudfoundry.SpaceRoleDeveloper},
+					AllowSSH: true,
+				},
+				cloudfoundry.SpaceTemplate{
+					Name:  "test",
+					Roles: []string{cloudfoundry.SpaceRoleAuditor},
+					Quota: "small",
+				},
+			},
+		}
+		org, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", "test-user-id", "test-quota-id", "test-iso-segment-id", template, c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(org.GUID).To(Equal("test-org-guid"))
+
+		Expect(c.CreateSpaceQuotaCallCount()).To(Equal(1))
+		Expect(c.CreateSpaceQuotaArgsForCall(0).OrgGUID).To(Equal("test-org-guid"))
+
+		Expect(c.CreateSpaceCallCount()).To(Equal(2))
+		dev := c.CreateSpaceArgsForCall(0)
+		Expect(dev.Name).To(Equal("dev"))
+		Expect(dev.ManagerGUIDs).To(Equal([]string{"test-user-id"}))
+		Expect(dev.DeveloperGUIDs).To(Equal([]string{"test-user-id"}))
+		Expect(dev.AuditorGUIDs).To(BeEmpty())
+		Expect(dev.AllowSSH).To(BeTrue())
+		Expect(dev.SpaceQuotaGUID).To(BeEmpty())
+		test := c.CreateSpaceArgsForCall(1)
+		Expect(test.Name).To(Equal("test"))
+		Expect(test.AuditorGUIDs).To(Equal([]string{"test-user-id"}))
+		Expect(test.AllowSSH).To(BeFalse())
+		Expect(test.SpaceQuotaGUID).To(Equal("test-space-quota-guid"))
+	})
+
+	it("records the org, spaces, and roles that it creates", func() {
+		l := audit.NewLog(20)
+		audit.SetDefault(l)
+		defer audit.SetDefault(nil)
+		_, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", "test-user-id", "test-quota-id", "test-iso-segment-id", playground, c)
+		Expect(err).NotTo(HaveOccurred())
+
+		orgs := l.Recent(audit.Filter{Type: audit.EventOrgCreated})
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].OrgGUID).To(Equal("test-org-guid"))
+		Expect(orgs[0].UserID).To(Equal("test-user-id"))
+		spaces := l.Recent(audit.Filter{Type: audit.EventSpaceCreated})
+		Expect(spaces).To(HaveLen(1))
+		Expect(spaces[0].Space).To(Equal("playground"))
+		var roles []string
+		for _, e := range l.Recent(audit.Filter{Type: audit.EventRoleGranted}) {
+			roles = append(roles, e.Role)
+		}
+		Expect(roles).To(ConsistOf("org_user", "org_manager", "org_auditor", "space_manager", "space_developer", "space_auditor"))
+	})
+
+	it("reports the space that could not be created", func() {
+		c.CreateSpaceReturnsOnCall(1, cloudfoundry.Space{}, errors.New("test error"))
+		template := cloudfoundry.OrgTemplate{
+			Spaces: []cloudfoundry.SpaceTemplate{
+				cloudfoundry.SpaceTemplate{Name: "dev"},
+				cloudfoundry.SpaceTemplate{Name: "test"},
+			},
+		}
+		_, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", "test-user-id", "test-quota-id", "test-iso-segment-id", template, c)
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*api.ProvisioningError).Step).To(Equal("space:test"))
+		Expect(c.DeleteOrgCallCount()).To(Equal(1))
+	})
+
+	when("the template has security groups", func() {
+		var template cloudfoundry.OrgTemplate
+
+		it.Before(func() {
+			template = cloudfoundry.OrgTemplate{
+				Spaces: []cloudfoundry.SpaceTemplate{
+					cloudfoundry.SpaceTemplate{
+						Name:                  "playground",
+						RunningSecurityGroups: []string{"artifacts"},
+						StagingSecurityGroups: []string{"artifacts"},
+					},
+				},
+			}
+			c.ListSpacesReturns([]cloudfoundry.Space{cloudfoundry.Space{GUID: "test-space-guid"}}, nil)
+			c.ListSecurityGroupsByNameReturns([]cloudfoundry.SecurityGroup{{GUID: "test-group-guid", Name: "artifacts"}}, nil)
+		})
+
+		it("binds the security groups to the space", func() {
+			_, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", "test-user-id", "test-quota-id", "test-iso-segment-id", template, c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.BindSecGroupCallCount()).To(Equal(1))
+			group, space := c.BindSecGroupArgsForCall(0)
+			Expect(group).To(Equal("test-group-guid"))
+			Expect(space).To(Equal("test-space-guid"))
+			Expect(c.BindStagingSecGroupToSpaceCallCount()).To(Equal(1))
+		})
+
+		it("deletes the org when a security group cannot be bound", func() {
+			c.BindSecGroupReturns(errors.New("test error"))
+			_, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", "test-user-id", "test-quota-id", "test-iso-segment-id", template, c)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*api.ProvisioningError).Step).To(Equal("security_groups:playground"))
+			Expect(c.DeleteOrgCallCount()).To(Equal(1))
+		})
+	})
+
+	when("the template has service instances", func() {
+		var template cloudfoundry.OrgTemplate
+
+		it.Before(func() {
+			template = cloudfoundry.OrgTemplate{
+				Spaces: []cloudfoundry.SpaceTemplate{
+					cloudfoundry.SpaceTemplate{
+						Name: "playground",
+						Services: []cloudfoundry.ServiceInstanceTemplate{
+							cloudfoundry.ServiceInstanceTemplate{Name: "db", Service: "p-mysql", Plan: "small"},
+							cloudfoundry.ServiceInstanceTemplate{Name: "config-server", Service: "p-config-server", Plan: "standard"},
+						},
+					},
+				},
+			}
+			c.ListSpacesReturns([]cloudfoundry.Space{cloudfoundry.Space{GUID: "test-space-guid"}}, nil)
+			c.ListServicesByLabelReturns([]cloudfoundry.Service{cloudfoundry.Service{GUID: "test-service-guid"}}, nil)
+			c.ListServicePlansForServiceReturns([]cloudfoundry.ServicePlan{
+				cloudfoundry.ServicePlan{GUID: "small-plan-guid", Name: "small"},
+				cloudfoundry.ServicePlan{GUID: "standard-plan-guid", Name: "standard"},
+			}, nil)
+			c.CreateServiceInstanceReturns(cloudfoundry.ServiceInstance{GUID: "test-instance-guid"}, nil)
+		})
+
+		it("creates the service instances in the space", func() {
+			org, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", "test-user-id", "test-quota-id", "test-iso-segment-id", template, c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org.ServiceInstanceErrors).To(BeEmpty())
+			Expect(c.CreateServiceInstanceCallCount()).To(Equal(2))
+			db := c.CreateServiceInstanceArgsForCall(0)
+			Expect(db.Name).To(Equal("db"))
+			Expect(db.SpaceGUID).To(Equal("test-space-guid"))
+			Expect(db.ServicePlanGUID).To(Equal("small-plan-guid"))
+			Expect(c.CreateServiceInstanceArgsForCall(1).ServicePlanGUID).To(Equal("standard-plan-guid"))
+		})
+
+		it("reports the service instances that could not be created with the org", func() {
+			c.CreateServiceInstanceReturnsOnCall(0, cloudfoundry.ServiceInstance{}, errors.New("test error"))
+			org, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", "test-user-id", "test-quota-id", "test-iso-segment-id", template, c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org.GUID).To(Equal("test-org-guid"))
+			Expect(org.ServiceInstanceErrors).To(HaveLen(1))
+			Expect(org.ServiceInstanceErrors[0].Space).To(Equal("playground"))
+			Expect(org.ServiceInstanceErrors[0].Name).To(Equal("db"))
+			Expect(org.ServiceInstanceErrors[0].Message).To(ContainSubstring("test error"))
+			Expect(c.CreateServiceInstanceCallCount()).To(Equal(2))
+			Expect(c.DeleteOrgCallCount()).To(Equal(0))
+		})
+
+		it("reports a service plan that is not in the marketplace", func() {
+			c.ListServicePlansForServiceReturns(nil, nil)
+			org, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", "test-user-id", "test-quota-id", "test-iso-segment-id", template, c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org.ServiceInstanceErrors).To(HaveLen(2))
+			Expect(c.CreateServiceInstanceCallCount()).To(Equal(0))
+		})
+
+		it("ignores service instances that already exist", func() {
+			c.CreateServiceInstanceReturnsOnCall(0, cloudfoundry.ServiceInstance{}, cfclient.CloudFoundryError{Code: 60002})
+			org, err := api.CreateOrgForUser("ignition-testuser", "http://example.net", "test-user-id", "test-quota-id", "test-iso-segment-id", template, c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org.ServiceInstanceErrors).To(BeEmpty())
+		})
 	})
 }
 
@@ -2,9 +2,9 @@ package api
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
+	"github.com/pivotalservices/ignition/logging"
 	"github.com/pivotalservices/ignition/user"
 )
 
@@ -13,7 +13,7 @@ func ProfileHandler() http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		p, err := user.ProfileFromContext(req.Context())
 		if err != nil {
-			log.Println(err)
+			logging.FromContext(req.Context()).Error("could not get profile", "error", err)
 			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
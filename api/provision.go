@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pivotalservices/ignition/metrics"
+	"github.com/pkg/errors"
+)
+
+// The steps taken to provision an org for a user. There is a StepSpace step
+// for each space in the org's template, named by SpaceStepName, a
+// StepSecurityGroups step for each space with security groups, named by
+// SecurityGroupsStepName, and a StepServices step for each space with service
+// instances, named by ServicesStepName.
+const (
+	StepOrg            = "org"
+	StepISOSegment     = "iso_segment"
+	StepRoles          = "roles"
+	StepSpaceQuotas    = "space_quotas"
+	StepSpace          = "space"
+	StepSecurityGroups = "security_groups"
+	StepServices       = "services"
+)
+
+// SpaceStepName is the name of the step that creates the named space
+func SpaceStepName(spaceName string) string {
+	return fmt.Sprintf("%s:%s", StepSpace, spaceName)
+}
+
+// SecurityGroupsStepName is the name of the step that binds security groups to
+// the named space
+func SecurityGroupsStepName(spaceName string) string {
+	return fmt.Sprintf("%s:%s", StepSecurityGroups, spaceName)
+}
+
+// ServicesStepName is the name of the step that creates the service instances
+// in the named space
+func ServicesStepName(spaceName string) string {
+	return fmt.Sprintf("%s:%s", StepServices, spaceName)
+}
+
+// The states of a provisioning job and of each of its steps. A step that
+// succeeded is StateRollbackFailed when undoing it failed, so whatever it
+// created is left behind.
+const (
+	StatePending        = "pending"
+	StateRunning        = "running"
+	StateSucceeded      = "succeeded"
+	StateFailed         = "failed"
+	StateRolledBack     = "rolled_back"
+	StateRollbackFailed = "rollback_failed"
+)
+
+// StepObserver is notified as each provisioning step changes state. err is
+// only set when the step has failed, or could not be rolled back.
+type StepObserver func(step string, state string, err error)
+
+// ProvisioningStep is a single step in provisioning an org. Undo reverses the
+// effects of Do, and may be nil when there is nothing to reverse (e.g. because
+// undoing an earlier step also undoes this one).
+type ProvisioningStep struct {
+	Name string
+	Do   func() error
+	Undo func() error
+}
+
+// ProvisioningError describes a provisioning attempt that failed, and whether
+// the steps that had already completed were rolled back
+type ProvisioningError struct {
+	Step           string   `json:"step"`
+	Message        string   `json:"message"`
+	Transient      bool     `json:"transient"`
+	RolledBack     bool     `json:"rolled_back"`
+	RollbackErrors []string `json:"rollback_errors,omitempty"`
+	cause          error
+}
+
+func (e *ProvisioningError) Error() string {
+	return fmt.Sprintf("provisioning failed at step [%s]: %s", e.Step, e.Message)
+}
+
+// Cause returns the error that caused the step to fail
+func (e *ProvisioningError) Cause() error {
+	return e.cause
+}
+
+// Pipeline runs provisioning steps in order. A step that fails with a
+// transient error is retried, and if a step still fails, the steps that
+// completed before it are undone in reverse order.
+type Pipeline struct {
+	Steps []ProvisioningStep
+
+	// Retries is the number of times a step is retried after a transient
+	// failure
+	Retries int
+
+	// Backoff is the time to wait before the first retry of a step; it is
+	// doubled for each subsequent retry
+	Backoff time.Duration
+
+	// Observer, if set, is notified of the progress of each step
+	Observer StepObserver
+
+	// Logger, if set, is used instead of the default logger so that
+	// provisioning can be correlated with the request that started it
+	Logger *logging.Logger
+}
+
+// Run runs each of the steps, returning a *ProvisioningError if any of them
+// fails
+func (p *Pipeline) Run() error {
+	for i, step := range p.Steps {
+		p.observe(step.Name, StateRunning, nil)
+		start := time.Now()
+		err := p.do(step)
+		if err == nil {
+			metrics.ObserveProvisioningStep(step.Name, StateSucceeded, time.Since(start))
+			p.observe(step.Name, StateSucceeded, nil)
+			continue
+		}
+		metrics.ObserveProvisioningStep(step.Name, StateFailed, time.Since(start))
+		p.observe(step.Name, StateFailed, err)
+
+		pe := &ProvisioningError{
+			Step:       step.Name,
+			Message:    err.Error(),
+			Transient:  cloudfoundry.IsTransientError(err),
+			RolledBack: true,
+			cause:      err,
+		}
+		for j := i - 1; j >= 0; j-- {
+			if p.Steps[j].Undo == nil {
+				continue
+			}
+			undoErr := p.Steps[j].Undo()
+			if undoErr != nil {
+				p.logger().Error("could not roll back provisioning step", "step", p.Steps[j].Name, "error", undoErr)
+				pe.RolledBack = false
+				pe.RollbackErrors = append(pe.RollbackErrors, errors.Wrapf(undoErr, "could not roll back step [%s]", p.Steps[j].Name).Error())
+				p.observe(p.Steps[j].Name, StateRollbackFailed, undoErr)
+				continue
+			}
+			p.observe(p.Steps[j].Name, StateRolledBack, nil)
+		}
+		return pe
+	}
+	return nil
+}
+
+func (p *Pipeline) logger() *logging.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return logging.Default()
+}
+
+func (p *Pipeline) observe(step string, state string, err error) {
+	if p.Observer != nil {
+		p.Observer(step, state, err)
+	}
+}
+
+func (p *Pipeline) do(step ProvisioningStep) error {
+	backoff := p.Backoff
+	err := step.Do()
+	for attempt := 0; err != nil && attempt < p.Retries && cloudfoundry.IsTransientError(err); attempt++ {
+		p.logger().Warn("retrying provisioning step after transient error", "step", step.Name, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+		err = step.Do()
+	}
+	return err
+}
+
+// writeProvisioningError writes the error to the response, as JSON when it is
+// a *ProvisioningError so that API clients can tell the user what went wrong
+func writeProvisioningError(w http.ResponseWriter, err error) {
+	pe, ok := err.(*ProvisioningError)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if pe.Transient {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(pe)
+}
@@ -0,0 +1,150 @@
+package api_test
+
+import (
+	"errors"
+	"testing"
+
+	cfclient "github.com/cloudfoundry-community/go-cfclient"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestPipeline(t *testing.T) {
+	spec.Run(t, "Pipeline", testPipeline, spec.Report(report.Terminal{}))
+}
+
+func testPipeline(t *testing.T, when spec.G, it spec.S) {
+	var (
+		calls []string
+		step  func(name string, err error) api.ProvisioningStep
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		calls = nil
+		step = func(name string, err error) api.ProvisioningStep {
+			return api.ProvisioningStep{
+				Name: name,
+				Do: func() error {
+					calls = append(calls, "do "+name)
+					return err
+				},
+				Undo: func() error {
+					calls = append(calls, "undo "+name)
+					return nil
+				},
+			}
+		}
+	})
+
+	it("runs every step in order", func() {
+		p := &api.Pipeline{Steps: []api.ProvisioningStep{step("a", nil), step("b", nil), step("c", nil)}}
+		Expect(p.Run()).To(Succeed())
+		Expect(calls).To(Equal([]string{"do a", "do b", "do c"}))
+	})
+
+	it("undoes the completed steps in reverse order when a step fails", func() {
+		p := &api.Pipeline{Steps: []api.ProvisioningStep{step("a", nil), step("b", nil), step("c", errors.New("test error")), step("d", nil)}}
+		err := p.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(calls).To(Equal([]string{"do a", "do b", "do c", "undo b", "undo a"}))
+
+		pe, ok := err.(*api.ProvisioningError)
+		Expect(ok).To(BeTrue())
+		Expect(pe.Step).To(Equal("c"))
+		Expect(pe.Message).To(Equal("test error"))
+		Expect(pe.Transient).To(BeFalse())
+		Expect(pe.RolledBack).To(BeTrue())
+		Expect(pe.Cause()).To(MatchError("test error"))
+	})
+
+	it("skips steps that cannot be undone", func() {
+		b := step("b", nil)
+		b.Undo = nil
+		p := &api.Pipeline{Steps: []api.ProvisioningStep{step("a", nil), b, step("c", errors.New("test error"))}}
+		Expect(p.Run()).NotTo(Succeed())
+		Expect(calls).To(Equal([]string{"do a", "do b", "do c", "undo a"}))
+	})
+
+	it("reports the steps that could not be undone", func() {
+		a := step("a", nil)
+		a.Undo = func() error { return errors.New("undo error") }
+		states := map[string]string{}
+		p := &api.Pipeline{
+			Steps: []api.ProvisioningStep{a, step("b", errors.New("test error"))},
+			Observer: func(step string, state string, err error) {
+				states[step] = state
+			},
+		}
+		err := p.Run()
+		Expect(states).To(Equal(map[string]string{"a": api.StateRollbackFailed, "b": api.StateFailed}))
+		pe, ok := err.(*api.ProvisioningError)
+		Expect(ok).To(BeTrue())
+		Expect(pe.RolledBack).To(BeFalse())
+		Expect(pe.RollbackErrors).To(HaveLen(1))
+		Expect(pe.RollbackErrors[0]).To(ContainSubstring("undo error"))
+	})
+
+	when("a step fails with a transient error", func() {
+		var attempts int
+		var transient error
+
+		it.Before(func() {
+			attempts = 0
+			transient = cfclient.CloudFoundryHTTPError{StatusCode: 503, Status: "503 Service Unavailable"}
+		})
+
+		it("retries the step", func() {
+			p := &api.Pipeline{
+				Retries: 2,
+				Steps: []api.ProvisioningStep{{
+					Name: "a",
+					Do: func() error {
+						attempts++
+						if attempts < 3 {
+							return transient
+						}
+						return nil
+					},
+				}},
+			}
+			Expect(p.Run()).To(Succeed())
+			Expect(attempts).To(Equal(3))
+		})
+
+		it("gives up after the configured number of retries", func() {
+			p := &api.Pipeline{
+				Retries: 2,
+				Steps: []api.ProvisioningStep{{
+					Name: "a",
+					Do: func() error {
+						attempts++
+						return transient
+					},
+				}},
+			}
+			err := p.Run()
+			Expect(err).To(HaveOccurred())
+			Expect(attempts).To(Equal(3))
+			Expect(err.(*api.ProvisioningError).Transient).To(BeTrue())
+		})
+	})
+
+	it("does not retry a step that fails with a permanent error", func() {
+		attempts := 0
+		p := &api.Pipeline{
+			Retries: 2,
+			Steps: []api.ProvisioningStep{{
+				Name: "a",
+				Do: func() error {
+					attempts++
+					return errors.New("test error")
+				},
+			}},
+		}
+		Expect(p.Run()).NotTo(Succeed())
+		Expect(attempts).To(Equal(1))
+	})
+}
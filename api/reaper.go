@@ -0,0 +1,336 @@
+package api
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pkg/errors"
+)
+
+// The actions that a Reaper can take for an idle org
+const (
+	ReapActionWarned      = "warned"
+	ReapActionDeleted     = "deleted"
+	ReapActionWouldWarn   = "would-warn"
+	ReapActionWouldDelete = "would-delete"
+)
+
+// Notifier warns the owners of an org that it is due to be deleted
+type Notifier interface {
+	NotifyExpiry(org cloudfoundry.Organization, owners []string, expiresAt time.Time) error
+}
+
+// LogNotifier is a Notifier that writes expiry warnings to the log
+type LogNotifier struct{}
+
+// NotifyExpiry logs that the org will be deleted at the given time
+func (n *LogNotifier) NotifyExpiry(org cloudfoundry.Organization, owners []string, expiresAt time.Time) error {
+	logging.Default().Warn("org is idle and will be deleted", "org", org.Name, "owners", owners, "expires_at", expiresAt.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// ReapedOrg describes the action taken (or that would be taken in dry-run
+// mode) for an idle org
+type ReapedOrg struct {
+	GUID         string
+	Name         string
+	Owners       []string
+	LastActivity time.Time
+	ExpiresAt    time.Time
+	Action       string
+	Error        string `json:",omitempty"`
+}
+
+// ReapReport is the outcome of a single pass of the Reaper
+type ReapReport struct {
+	GeneratedAt time.Time
+	DryRun      bool
+	Orgs        []ReapedOrg
+}
+
+// Warnings records when the owners of orgs were warned that the orgs are due
+// to be deleted. An implementation backed by a shared store lets instances of
+// ignition warn the owners of an org once between them, and keeps the record
+// when they restart.
+type Warnings interface {
+	// Warned returns when the owners of the org were warned, if they were
+	Warned(guid string) (time.Time, bool, error)
+
+	// Warn records that the owners of the org were warned at the time, unless
+	// a warning is already recorded, in which case it returns false
+	Warn(guid string, at time.Time) (bool, error)
+
+	// Forget removes the record of the org's warning, returning false when
+	// there was none, e.g. because another instance removed it first
+	Forget(guid string) (bool, error)
+}
+
+// MemoryWarnings are Warnings kept in memory, which are neither shared with
+// other instances of ignition nor kept when ignition restarts. The zero value
+// is ready to use.
+type MemoryWarnings struct {
+	mu     sync.Mutex
+	warned map[string]time.Time
+}
+
+// Warned returns when the owners of the org were warned, if they were
+func (m *MemoryWarnings) Warned(guid string) (time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	at, ok := m.warned[guid]
+	return at, ok, nil
+}
+
+// Warn records that the owners of the org were warned at the time, unless a
+// warning is already recorded
+func (m *MemoryWarnings) Warn(guid string, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.warned[guid]; ok {
+		return false, nil
+	}
+	if m.warned == nil {
+		m.warned = make(map[string]time.Time)
+	}
+	m.warned[guid] = at
+	return true, nil
+}
+
+// Forget removes the record of the org's warning
+func (m *MemoryWarnings) Forget(guid string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.warned[guid]
+	delete(m.warned, guid)
+	return ok, nil
+}
+
+// Reaper finds ignition orgs that have been idle for longer than the TTL,
+// warns their owners, and deletes them once they have been warned for at
+// least the Warning period. Ignition's orgs are those with the quota whose
+// names start with the OrgPrefix.
+type Reaper struct {
+	AppsURL   string
+	OrgPrefix string
+	QuotaID   string
+	TTL       time.Duration
+	Warning   time.Duration
+	DryRun    bool
+	Notifier  Notifier
+	API       cloudfoundry.API
+
+	// Warnings records the warnings that have been sent; they are kept in
+	// memory when it is nil
+	Warnings Warnings
+
+	mu     sync.Mutex
+	report *ReapReport
+}
+
+// Reap performs a single pass over the ignition orgs, warning the owners of
+// orgs that are about to expire and deleting expired orgs whose owners were
+// warned at least the Warning period ago. An org is never deleted on the pass
+// that its owners are first warned.
+func (r *Reaper) Reap() (*ReapReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Warnings == nil {
+		r.Warnings = &MemoryWarnings{}
+	}
+	now := time.Now()
+	orgs, err := r.orgs()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReapReport{
+		GeneratedAt: now,
+		DryRun:      r.DryRun,
+	}
+	for _, o := range orgs {
+		lastActivity, err := cloudfoundry.LastActivityForOrg(o, r.API)
+		if err != nil {
+			logging.Default().Error("could not get last activity for org", "org", o.Name, "error", err)
+			continue
+		}
+		expiresAt := lastActivity.Add(r.TTL)
+		if now.Before(expiresAt.Add(-r.Warning)) {
+			if !r.DryRun {
+				// the org is active again, so a later warning starts afresh
+				if _, err := r.Warnings.Forget(o.GUID); err != nil {
+					logging.Default().Error("could not forget the warning for org", "org", o.Name, "error", err)
+				}
+			}
+			continue
+		}
+
+		owners, err := cloudfoundry.ManagerNamesForOrg(o.GUID, r.API)
+		if err != nil {
+			logging.Default().Error("could not get managers for org", "org", o.Name, "error", err)
+		}
+		reaped := ReapedOrg{
+			GUID:         o.GUID,
+			Name:         o.Name,
+			Owners:       owners,
+			LastActivity: lastActivity,
+			ExpiresAt:    expiresAt,
+		}
+		warnedAt, warned, err := r.Warnings.Warned(o.GUID)
+		if err != nil {
+			reaped.Action = ReapActionWouldWarn
+			reaped.Error = err.Error()
+			report.Orgs = append(report.Orgs, reaped)
+			continue
+		}
+		if !warned {
+			// an org is deleted no sooner than the Warning after its owners
+			// are warned, however long it has been idle
+			reaped.ExpiresAt = latest(expiresAt, now.Add(r.Warning))
+			reaped.Action = ReapActionWouldWarn
+			if !r.DryRun {
+				reaped.Action = ReapActionWarned
+				reaped.Error = r.warn(o, owners, now, reaped.ExpiresAt)
+			}
+			report.Orgs = append(report.Orgs, reaped)
+			continue
+		}
+
+		reaped.ExpiresAt = latest(expiresAt, warnedAt.Add(r.Warning))
+		if now.Before(reaped.ExpiresAt) {
+			reaped.Action = ReapActionWouldWarn
+			if !r.DryRun {
+				reaped.Action = ReapActionWarned
+			}
+			report.Orgs = append(report.Orgs, reaped)
+			continue
+		}
+
+		reaped.Action = ReapActionWouldDelete
+		if !r.DryRun {
+			// forgetting the warning claims the org, so that only one
+			// instance deletes it
+			claimed, err := r.Warnings.Forget(o.GUID)
+			if err != nil || !claimed {
+				if err != nil {
+					logging.Default().Error("could not claim org for deletion", "org", o.Name, "error", err)
+				}
+				continue
+			}
+			reaped.Action = ReapActionDeleted
+			err = r.API.DeleteOrg(o.GUID, true, true)
+			if err != nil {
+				reaped.Error = err.Error()
+				// keep the warning, so that deletion is retried on the next
+				// pass rather than the owners being warned again
+				r.Warnings.Warn(o.GUID, warnedAt)
+			} else {
+				audit.Record(audit.Event{Type: audit.EventOrgDeleted, Org: o.Name, OrgGUID: o.GUID, Reason: "idle"})
+			}
+		}
+		report.Orgs = append(report.Orgs, reaped)
+	}
+	r.report = report
+	return report, nil
+}
+
+// warn records the warning for the org and notifies its owners, unless
+// another instance has just warned them, returning the error that prevented
+// them from being warned. A warning that cannot be sent is not recorded, so
+// that it is sent again on the next pass.
+func (r *Reaper) warn(o cloudfoundry.Organization, owners []string, now time.Time, expiresAt time.Time) string {
+	recorded, err := r.Warnings.Warn(o.GUID, now)
+	if err != nil {
+		return err.Error()
+	}
+	if !recorded {
+		return ""
+	}
+	err = r.notifier().NotifyExpiry(o, owners, expiresAt)
+	if err != nil {
+		r.Warnings.Forget(o.GUID)
+		return err.Error()
+	}
+	return ""
+}
+
+// latest returns the later of the times
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// orgs returns ignition's orgs: those with the quota whose names start with
+// the org prefix
+func (r *Reaper) orgs() ([]cloudfoundry.Organization, error) {
+	prefix := strings.TrimSpace(r.OrgPrefix)
+	if prefix == "" {
+		return nil, errors.New("an org prefix is required to find the orgs to reap")
+	}
+	orgs, err := cloudfoundry.OrgsForQuotaID(r.QuotaID, r.AppsURL, r.API)
+	if err != nil {
+		return nil, err
+	}
+	var result []cloudfoundry.Organization
+	for _, o := range orgs {
+		if strings.HasPrefix(o.Name, prefix+"-") {
+			result = append(result, o)
+		}
+	}
+	return result, nil
+}
+
+// Report returns the outcome of the most recent pass of the Reaper, or nil if
+// the Reaper has not yet run
+func (r *Reaper) Report() *ReapReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.report
+}
+
+func (r *Reaper) notifier() Notifier {
+	if r.Notifier == nil {
+		return &LogNotifier{}
+	}
+	return r.Notifier
+}
+
+// StartBackgroundReaper runs the Reaper every interval until the process
+// exits. It refuses an interval that is not positive, which would reap without
+// pause.
+func StartBackgroundReaper(r *Reaper, interval time.Duration) error {
+	if interval <= 0 {
+		return errors.Errorf("the reap interval [%s] must be greater than 0s", interval)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			reap(r)
+			<-ticker.C
+		}
+	}()
+	return nil
+}
+
+func reap(r *Reaper) {
+	report, err := r.Reap()
+	if err != nil {
+		// reaping is non-critical - so log it and continue
+		logging.Default().Error("could not reap idle orgs", "error", err)
+		return
+	}
+	for _, o := range report.Orgs {
+		keyvals := []interface{}{"action", o.Action, "org", o.Name, "last_activity", o.LastActivity.UTC().Format(time.RFC3339)}
+		if o.Error != "" {
+			logging.Default().Error("could not reap org", append(keyvals, "error", o.Error)...)
+			continue
+		}
+		logging.Default().Info("reaped org", keyvals...)
+	}
+}
@@ -0,0 +1,285 @@
+package api_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+type fakeNotifier struct {
+	notified []string
+	err      error
+}
+
+func (f *fakeNotifier) NotifyExpiry(org cloudfoundry.Organization, owners []string, expiresAt time.Time) error {
+	f.notified = append(f.notified, org.Name)
+	return f.err
+}
+
+func TestReaper(t *testing.T) {
+	spec.Run(t, "Reaper", testReaper, spec.Report(report.Terminal{}))
+}
+
+func testReaper(t *testing.T, when spec.G, it spec.S) {
+	var (
+		c        *cloudfoundryfakes.FakeAPI
+		notifier *fakeNotifier
+		warnings *api.MemoryWarnings
+		reaper   *api.Reaper
+	)
+
+	timestamp := func(ago time.Duration) string {
+		return time.Now().Add(-ago).UTC().Format(time.RFC3339)
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		c = &cloudfoundryfakes.FakeAPI{}
+		notifier = &fakeNotifier{}
+		warnings = &api.MemoryWarnings{}
+		reaper = &api.Reaper{
+			AppsURL:   "https://apps.example.net",
+			OrgPrefix: "ignition",
+			QuotaID:   "ignition-quota-id",
+			TTL:       30 * 24 * time.Hour,
+			Warning:   3 * 24 * time.Hour,
+			Notifier:  notifier,
+			API:       c,
+			Warnings:  warnings,
+		}
+		c.ListOrgsReturns([]cloudfoundry.Organization{
+			cloudfoundry.Organization{
+				GUID:                "active-guid",
+				Name:                "ignition-active",
+				QuotaDefinitionGUID: "ignition-quota-id",
+				CreatedAt:           timestamp(400 * 24 * time.Hour),
+				UpdatedAt:           timestamp(24 * time.Hour),
+			},
+			cloudfoundry.Organization{
+				GUID:                "expiring-guid",
+				Name:                "ignition-expiring",
+				QuotaDefinitionGUID: "ignition-quota-id",
+				CreatedAt:           timestamp(400 * 24 * time.Hour),
+				UpdatedAt:           timestamp(28 * 24 * time.Hour),
+			},
+			cloudfoundry.Organization{
+				GUID:                "expired-guid",
+				Name:                "ignition-expired",
+				QuotaDefinitionGUID: "ignition-quota-id",
+				CreatedAt:           timestamp(400 * 24 * time.Hour),
+				UpdatedAt:           timestamp(31 * 24 * time.Hour),
+			},
+			cloudfoundry.Organization{
+				GUID:                "other-guid",
+				Name:                "someone-elses-org",
+				QuotaDefinitionGUID: "other-quota-id",
+				CreatedAt:           timestamp(400 * 24 * time.Hour),
+				UpdatedAt:           timestamp(365 * 24 * time.Hour),
+			},
+		}, nil)
+		c.ListOrgManagersReturns([]cloudfoundry.User{
+			cloudfoundry.User{Username: "owner@example.net"},
+		}, nil)
+	})
+
+	it("has no report before it has run", func() {
+		Expect(reaper.Report()).To(BeNil())
+	})
+
+	it("only reaps the orgs with ignition's quota and org prefix", func() {
+		c.ListOrgsReturns([]cloudfoundry.Organization{
+			cloudfoundry.Organization{
+				GUID:                "platform-guid",
+				Name:                "platform-tools",
+				QuotaDefinitionGUID: "ignition-quota-id",
+				CreatedAt:           timestamp(400 * 24 * time.Hour),
+			},
+			cloudfoundry.Organization{
+				GUID:                "other-guid",
+				Name:                "ignition-elsewhere",
+				QuotaDefinitionGUID: "other-quota-id",
+				CreatedAt:           timestamp(400 * 24 * time.Hour),
+			},
+		}, nil)
+		r, err := reaper.Reap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Orgs).To(BeEmpty())
+		Expect(c.ListOrgsArgsForCall(0)).To(Equal(cloudfoundry.OrgQuery{QuotaGUID: "ignition-quota-id"}))
+		Expect(c.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	it("errors without listing the orgs when there is no org prefix", func() {
+		reaper.OrgPrefix = " "
+		_, err := reaper.Reap()
+		Expect(err).To(HaveOccurred())
+		Expect(c.ListOrgsCallCount()).To(Equal(0))
+	})
+
+	it("errors when the orgs cannot be listed", func() {
+		c.ListOrgsReturns(nil, errors.New("test error"))
+		r, err := reaper.Reap()
+		Expect(err).To(HaveOccurred())
+		Expect(r).To(BeNil())
+	})
+
+	it("warns the owners of expiring and expired orgs before deleting them", func() {
+		r, err := reaper.Reap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.DryRun).To(BeFalse())
+		Expect(r.Orgs).To(HaveLen(2))
+		Expect(r.Orgs[0].Name).To(Equal("ignition-expiring"))
+		Expect(r.Orgs[0].Action).To(Equal(api.ReapActionWarned))
+		Expect(r.Orgs[0].Owners).To(Equal([]string{"owner@example.net"}))
+		Expect(r.Orgs[1].Name).To(Equal("ignition-expired"))
+		Expect(r.Orgs[1].Action).To(Equal(api.ReapActionWarned))
+		Expect(r.Orgs[1].ExpiresAt).To(BeTemporally("~", time.Now().Add(3*24*time.Hour), time.Minute))
+		Expect(notifier.notified).To(Equal([]string{"ignition-expiring", "ignition-expired"}))
+		Expect(c.DeleteOrgCallCount()).To(Equal(0))
+		Expect(reaper.Report()).To(Equal(r))
+	})
+
+	it("deletes expired orgs whose owners were warned at least the warning period ago", func() {
+		warnings.Warn("expired-guid", time.Now().Add(-4*24*time.Hour))
+		warnings.Warn("expiring-guid", time.Now().Add(-4*24*time.Hour))
+		r, err := reaper.Reap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Orgs).To(HaveLen(2))
+		Expect(r.Orgs[0].Name).To(Equal("ignition-expiring"))
+		Expect(r.Orgs[0].Action).To(Equal(api.ReapActionWarned))
+		Expect(r.Orgs[1].Name).To(Equal("ignition-expired"))
+		Expect(r.Orgs[1].Action).To(Equal(api.ReapActionDeleted))
+		Expect(notifier.notified).To(BeEmpty())
+		Expect(c.DeleteOrgCallCount()).To(Equal(1))
+		guid, recursive, _ := c.DeleteOrgArgsForCall(0)
+		Expect(guid).To(Equal("expired-guid"))
+		Expect(recursive).To(BeTrue())
+		_, warned, _ := warnings.Warned("expired-guid")
+		Expect(warned).To(BeFalse())
+	})
+
+	it("does not delete an expired org until the warning period has passed", func() {
+		warnings.Warn("expired-guid", time.Now().Add(-time.Hour))
+		r, err := reaper.Reap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Orgs[1].Action).To(Equal(api.ReapActionWarned))
+		Expect(r.Orgs[1].ExpiresAt).To(BeTemporally("~", time.Now().Add(3*24*time.Hour-time.Hour), time.Minute))
+		Expect(c.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	it("only warns the owners of an org once", func() {
+		reaper.Reap()
+		reaper.Reap()
+		Expect(notifier.notified).To(Equal([]string{"ignition-expiring", "ignition-expired"}))
+	})
+
+	it("only warns once between reapers that share their warnings", func() {
+		other := &api.Reaper{
+			AppsURL:   reaper.AppsURL,
+			OrgPrefix: reaper.OrgPrefix,
+			QuotaID:   reaper.QuotaID,
+			TTL:       reaper.TTL,
+			Warning:   reaper.Warning,
+			Notifier:  notifier,
+			API:       c,
+			Warnings:  warnings,
+		}
+		reaper.Reap()
+		other.Reap()
+		Expect(notifier.notified).To(Equal([]string{"ignition-expiring", "ignition-expired"}))
+		Expect(c.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	it("forgets the warning when an org becomes active again", func() {
+		warnings.Warn("active-guid", time.Now().Add(-time.Hour))
+		reaper.Reap()
+		_, warned, _ := warnings.Warned("active-guid")
+		Expect(warned).To(BeFalse())
+	})
+
+	it("warns again on the next pass if the warning could not be sent", func() {
+		notifier.err = errors.New("test error")
+		r, _ := reaper.Reap()
+		Expect(r.Orgs[0].Error).To(Equal("test error"))
+		reaper.Reap()
+		Expect(notifier.notified).To(HaveLen(4))
+	})
+
+	it("records an error when an org cannot be deleted, and retries on the next pass", func() {
+		warnings.Warn("expired-guid", time.Now().Add(-4*24*time.Hour))
+		c.DeleteOrgReturns(errors.New("test error"))
+		r, err := reaper.Reap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Orgs[1].Action).To(Equal(api.ReapActionDeleted))
+		Expect(r.Orgs[1].Error).To(Equal("test error"))
+
+		c.DeleteOrgReturns(nil)
+		r, err = reaper.Reap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Orgs[1].Action).To(Equal(api.ReapActionDeleted))
+		Expect(r.Orgs[1].Error).To(BeEmpty())
+		Expect(c.DeleteOrgCallCount()).To(Equal(2))
+		Expect(notifier.notified).To(Equal([]string{"ignition-expiring"}))
+	})
+
+	it("refuses to reap without pause", func() {
+		Expect(api.StartBackgroundReaper(reaper, 0)).To(MatchError("the reap interval [0s] must be greater than 0s"))
+		Expect(c.ListOrgsCallCount()).To(Equal(0))
+	})
+
+	it("skips orgs whose activity cannot be determined", func() {
+		c.ListAppsReturns(nil, errors.New("test error"))
+		r, err := reaper.Reap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Orgs).To(BeEmpty())
+		Expect(c.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	it("treats recent app activity as activity in the org", func() {
+		c.ListAppsReturns([]cloudfoundry.App{
+			cloudfoundry.App{UpdatedAt: timestamp(time.Hour)},
+		}, nil)
+		r, err := reaper.Reap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Orgs).To(BeEmpty())
+	})
+
+	it("does not reap an org whose long-running app is started", func() {
+		c.ListAppsReturns([]cloudfoundry.App{
+			cloudfoundry.App{State: cloudfoundry.AppStateStarted, CreatedAt: timestamp(365 * 24 * time.Hour), UpdatedAt: timestamp(365 * 24 * time.Hour)},
+		}, nil)
+		r, err := reaper.Reap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Orgs).To(BeEmpty())
+		Expect(notifier.notified).To(BeEmpty())
+		Expect(c.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	when("in dry-run mode", func() {
+		it.Before(func() {
+			reaper.DryRun = true
+		})
+
+		it("reports what would be done without warning or deleting", func() {
+			warnings.Warn("expired-guid", time.Now().Add(-4*24*time.Hour))
+			r, err := reaper.Reap()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.DryRun).To(BeTrue())
+			Expect(r.Orgs).To(HaveLen(2))
+			Expect(r.Orgs[0].Action).To(Equal(api.ReapActionWouldWarn))
+			Expect(r.Orgs[1].Action).To(Equal(api.ReapActionWouldDelete))
+			Expect(notifier.notified).To(BeEmpty())
+			Expect(c.DeleteOrgCallCount()).To(Equal(0))
+			_, warned, _ := warnings.Warned("expiring-guid")
+			Expect(warned).To(BeFalse())
+			_, warned, _ = warnings.Warned("expired-guid")
+			Expect(warned).To(BeTrue())
+		})
+	})
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pkg/errors"
+)
+
+// The prefixes of the keys that RedisWarnings uses for the time that the
+// owners of each org were warned, that RedisJobStore uses for the most recent
+// job for each org in each foundation, and that RedisLocker uses for locks
+const (
+	redisWarningPrefix = "ignition:reaper:warned:"
+	redisJobPrefix     = "ignition:job:"
+	redisLockPrefix    = "ignition:lock:"
+)
+
+// The scripts that RedisLocker uses to release and to extend a lock, which do
+// nothing unless the lock is still held with the token given as ARGV[1]
+const (
+	redisUnlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+	redisExtendScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+)
+
+// The defaults for how long RedisLocker keeps a lock that is not extended,
+// and how often it tries to acquire a lock that is held
+const (
+	defaultLockTTL   = 30 * time.Second
+	defaultLockRetry = 100 * time.Millisecond
+)
+
+// RedisWarnings are Warnings kept in Redis, so that they are shared between
+// instances of ignition and kept when ignition restarts
+type RedisWarnings struct {
+	Pool *redis.Pool
+}
+
+// Warned returns when the owners of the org were warned, if they were
+func (r *RedisWarnings) Warned(guid string) (time.Time, bool, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+	seconds, err := redis.Int64(c.Do("GET", redisWarningPrefix+guid))
+	if err == redis.ErrNil {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "could not get the warning for org [%s] from redis", guid)
+	}
+	return time.Unix(seconds, 0), true, nil
+}
+
+// Warn records that the owners of the org were warned at the time, unless a
+// warning is already recorded
+func (r *RedisWarnings) Warn(guid string, at time.Time) (bool, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+	_, err := redis.String(c.Do("SET", redisWarningPrefix+guid, at.Unix(), "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "could not store the warning for org [%s] in redis", guid)
+	}
+	return true, nil
+}
+
+// Forget removes the record of the org's warning
+func (r *RedisWarnings) Forget(guid string) (bool, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+	deleted, err := redis.Int(c.Do("DEL", redisWarningPrefix+guid))
+	if err != nil {
+		return false, errors.Wrapf(err, "could not delete the warning for org [%s] from redis", guid)
+	}
+	return deleted > 0, nil
+}
+
+// RedisJobStore is a JobStore kept in Redis, so that every instance of
+// ignition can report the status of a job. Finished jobs are kept for an hour.
+type RedisJobStore struct {
+	Pool *redis.Pool
+}
+
+// Get returns the most recent job for the org in the foundation, if there is
+// one
+func (r *RedisJobStore) Get(foundation, orgName string) (Job, bool, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+	data, err := redis.Bytes(c.Do("GET", redisJobPrefix+jobKey(foundation, orgName)))
+	if err == redis.ErrNil {
+		return Job{}, false, nil
+	}
+	if err != nil {
+		return Job{}, false, errors.Wrapf(err, "could not get the job for org [%s] in foundation [%s] from redis", orgName, foundation)
+	}
+	var job Job
+	err = json.Unmarshal(data, &job)
+	if err != nil {
+		return Job{}, false, errors.Wrapf(err, "could not decode the job for org [%s]", orgName)
+	}
+	return job, true, nil
+}
+
+// Put stores the job as the most recent job for its org in its foundation. A
+// running job expires unless it is stored again, which Jobs does for as long
+// as it runs, so that a job whose instance stopped is forgotten.
+func (r *RedisJobStore) Put(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrapf(err, "could not encode the job for org [%s]", job.OrgName)
+	}
+	ttl := jobRetention
+	if job.State == StateRunning {
+		ttl = runningJobTTL
+	}
+	c := r.Pool.Get()
+	defer c.Close()
+	_, err = c.Do("SET", redisJobPrefix+jobKey(job.Foundation, job.OrgName), data, "EX", int(ttl.Seconds()))
+	if err != nil {
+		return errors.Wrapf(err, "could not store the job for org [%s] in redis", job.OrgName)
+	}
+	return nil
+}
+
+// RedisLocker is a Locker kept in Redis, so that instances of ignition do not
+// provision the same org at the same time. A lock is held with a random token,
+// and only released by its holder. It expires unless it is extended, which is
+// done for as long as it is held, so that the lock of an instance that stopped
+// is released.
+type RedisLocker struct {
+	Pool *redis.Pool
+
+	// TTL is how long a lock is kept when it is not extended; it is 30
+	// seconds when zero
+	TTL time.Duration
+
+	// Retry is how often Lock tries to acquire a lock that is held; it is
+	// 100ms when zero
+	Retry time.Duration
+}
+
+// Lock blocks until no other caller, in this or another instance of ignition,
+// holds the lock for key. It errors if Redis cannot be reached.
+func (r *RedisLocker) Lock(key string) (func(), error) {
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+	retry := r.Retry
+	if retry <= 0 {
+		retry = defaultLockRetry
+	}
+	token := randomID()
+	for {
+		acquired, err := r.acquire(key, token, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		time.Sleep(retry)
+	}
+
+	stop := make(chan struct{})
+	go r.extend(key, token, ttl, stop)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			err := r.release(key, token)
+			if err != nil {
+				logging.Default().Error("could not release the lock", "key", key, "error", err)
+			}
+		})
+	}, nil
+}
+
+// acquire sets the lock to the token, unless it is already held
+func (r *RedisLocker) acquire(key, token string, ttl time.Duration) (bool, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+	_, err := redis.String(c.Do("SET", redisLockPrefix+key, token, "NX", "PX", int(ttl/time.Millisecond)))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "could not acquire the lock for [%s] in redis", key)
+	}
+	return true, nil
+}
+
+// extend keeps the lock from expiring until stop is closed, or the lock is no
+// longer held with the token
+func (r *RedisLocker) extend(key, token string, ttl time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		held, err := r.run(redisExtendScript, key, token, int(ttl/time.Millisecond))
+		if err != nil {
+			logging.Default().Error("could not extend the lock", "key", key, "error", err)
+			continue
+		}
+		if !held {
+			logging.Default().Warn("the lock expired before it was released", "key", key)
+			return
+		}
+	}
+}
+
+// release deletes the lock, if it is still held with the token
+func (r *RedisLocker) release(key, token string) error {
+	_, err := r.run(redisUnlockScript, key, token)
+	return err
+}
+
+// run runs one of the lock's scripts, and returns whether the lock was held
+// with the token
+func (r *RedisLocker) run(script, key, token string, args ...interface{}) (bool, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+	n, err := redis.Int(c.Do("EVAL", append([]interface{}{script, 1, redisLockPrefix + key, token}, args...)...))
+	if err != nil {
+		return false, errors.Wrapf(err, "could not update the lock for [%s] in redis", key)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,280 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/internal/fakeredis"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestRedisWarnings(t *testing.T) {
+	spec.Run(t, "RedisWarnings", testRedisWarnings, spec.Report(report.Terminal{}))
+}
+
+func testRedisWarnings(t *testing.T, when spec.G, it spec.S) {
+	var (
+		server   *fakeredis.Server
+		pool     *redis.Pool
+		warnings *api.RedisWarnings
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		var err error
+		server, err = fakeredis.Run()
+		Expect(err).NotTo(HaveOccurred())
+		addr := server.Addr()
+		pool = &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", addr) }}
+		warnings = &api.RedisWarnings{Pool: pool}
+	})
+
+	it.After(func() {
+		pool.Close()
+		server.Close()
+	})
+
+	it("records a warning once until it is forgotten", func() {
+		_, warned, err := warnings.Warned("org-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warned).To(BeFalse())
+
+		at := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+		recorded, err := warnings.Warn("org-guid", at)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorded).To(BeTrue())
+		recorded, err = warnings.Warn("org-guid", at.Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorded).To(BeFalse())
+
+		warnedAt, warned, err := warnings.Warned("org-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warned).To(BeTrue())
+		Expect(warnedAt.Equal(at)).To(BeTrue())
+
+		forgotten, err := warnings.Forget("org-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forgotten).To(BeTrue())
+		forgotten, err = warnings.Forget("org-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forgotten).To(BeFalse())
+	})
+
+	it("errors when redis cannot be reached", func() {
+		server.Close()
+		_, _, err := warnings.Warned("org-guid")
+		Expect(err).To(HaveOccurred())
+		_, err = warnings.Warn("org-guid", time.Now())
+		Expect(err).To(HaveOccurred())
+		_, err = warnings.Forget("org-guid")
+		Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestRedisJobStore(t *testing.T) {
+	spec.Run(t, "RedisJobStore", testRedisJobStore, spec.Report(report.Terminal{}))
+}
+
+func testRedisJobStore(t *testing.T, when spec.G, it spec.S) {
+	var (
+		server *fakeredis.Server
+		pool   *redis.Pool
+		store  *api.RedisJobStore
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		var err error
+		server, err = fakeredis.Run()
+		Expect(err).NotTo(HaveOccurred())
+		addr := server.Addr()
+		pool = &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", addr) }}
+		store = &api.RedisJobStore{Pool: pool}
+	})
+
+	it.After(func() {
+		pool.Close()
+		server.Close()
+	})
+
+	it("stores the most recent job for each org in each foundation", func() {
+		_, ok, err := store.Get("default", "ignition-testuser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+
+		job := api.Job{
+			ID:         "test-job-id",
+			OrgName:    "ignition-testuser",
+			Foundation: "default",
+			State:      api.StateRunning,
+			Steps:      []api.StepStatus{{Name: api.StepOrg, State: api.StateSucceeded}},
+		}
+		Expect(store.Put(job)).To(Succeed())
+		_, ok, err = store.Get("emea", "ignition-testuser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+		stored, ok, err := store.Get("default", "ignition-testuser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(stored.ID).To(Equal("test-job-id"))
+		Expect(stored.Steps).To(Equal(job.Steps))
+		Expect(server.TTL("ignition:job:default:ignition-testuser")).To(BeNumerically("~", 15*time.Minute, time.Second))
+
+		job.State = api.StateFailed
+		job.Error = &api.ProvisioningError{Step: api.StepOrg, Message: "test error"}
+		Expect(store.Put(job)).To(Succeed())
+		stored, _, err = store.Get("default", "ignition-testuser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored.State).To(Equal(api.StateFailed))
+		Expect(stored.Error.Message).To(Equal("test error"))
+		Expect(server.TTL("ignition:job:default:ignition-testuser")).To(BeNumerically("~", time.Hour, time.Second))
+
+		server.FastForward(time.Hour)
+		_, ok, err = store.Get("default", "ignition-testuser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	it("keeps a running job for as long as it runs", func() {
+		proceed := make(chan struct{})
+		jobs := &api.Jobs{Store: store, Refresh: 20 * time.Millisecond}
+		p := &api.Pipeline{Steps: []api.ProvisioningStep{{
+			Name: api.StepOrg,
+			Do: func() error {
+				<-proceed
+				return nil
+			},
+		}}}
+		job := jobs.Start("default", "ignition-testuser", p, func() *cloudfoundry.Organization { return &cloudfoundry.Organization{} }, func() {})
+		Expect(job.State).To(Equal(api.StateRunning))
+
+		// the job outlives the time that redis keeps a job that is not stored
+		// again
+		for i := 0; i < 3; i++ {
+			server.FastForward(10 * time.Minute)
+			Eventually(func() time.Duration {
+				return server.TTL("ignition:job:default:ignition-testuser")
+			}).Should(BeNumerically(">", 14*time.Minute))
+		}
+		stored, ok, err := store.Get("default", "ignition-testuser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(stored.ID).To(Equal(job.ID))
+
+		close(proceed)
+		Eventually(func() string {
+			stored, _, _ := store.Get("default", "ignition-testuser")
+			return stored.State
+		}).Should(Equal(api.StateSucceeded))
+		Expect(server.TTL("ignition:job:default:ignition-testuser")).To(BeNumerically("~", time.Hour, time.Second))
+	})
+
+	it("errors when redis cannot be reached", func() {
+		server.Close()
+		_, _, err := store.Get("default", "ignition-testuser")
+		Expect(err).To(HaveOccurred())
+		Expect(store.Put(api.Job{OrgName: "ignition-testuser"})).NotTo(Succeed())
+	})
+}
+
+func TestRedisLocker(t *testing.T) {
+	spec.Run(t, "RedisLocker", testRedisLocker, spec.Report(report.Terminal{}))
+}
+
+func testRedisLocker(t *testing.T, when spec.G, it spec.S) {
+	var (
+		server *fakeredis.Server
+		pool   *redis.Pool
+		locker *api.RedisLocker
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		var err error
+		server, err = fakeredis.Run()
+		Expect(err).NotTo(HaveOccurred())
+		addr := server.Addr()
+		pool = &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", addr) }}
+		locker = &api.RedisLocker{Pool: pool, TTL: 300 * time.Millisecond, Retry: 10 * time.Millisecond}
+	})
+
+	it.After(func() {
+		pool.Close()
+		server.Close()
+	})
+
+	it("blocks another instance for the same key until the first unlocks", func() {
+		unlock, err := locker.Lock("ignition-test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(server.Exists("ignition:lock:ignition-test")).To(BeTrue())
+
+		other := &api.RedisLocker{Pool: pool, TTL: 300 * time.Millisecond, Retry: 10 * time.Millisecond}
+		acquired := make(chan struct{})
+		go func() {
+			unlock2, _ := other.Lock("ignition-test")
+			close(acquired)
+			unlock2()
+		}()
+		unlock3, err := other.Lock("ignition-other")
+		Expect(err).NotTo(HaveOccurred())
+		unlock3()
+
+		Consistently(acquired, 100*time.Millisecond).ShouldNot(BeClosed())
+		unlock()
+		Eventually(acquired).Should(BeClosed())
+		Eventually(func() bool { return server.Exists("ignition:lock:ignition-test") }).Should(BeFalse())
+	})
+
+	it("extends the lock while it is held", func() {
+		unlock, err := locker.Lock("ignition-test")
+		Expect(err).NotTo(HaveOccurred())
+		Consistently(func() bool { return server.Exists("ignition:lock:ignition-test") }, 600*time.Millisecond).Should(BeTrue())
+		unlock()
+		Expect(server.Exists("ignition:lock:ignition-test")).To(BeFalse())
+	})
+
+	it("acquires the lock of an instance that stopped once it expires", func() {
+		conn := pool.Get()
+		_, err := conn.Do("SET", "ignition:lock:ignition-test", "stopped-instance", "PX", 60000)
+		conn.Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		acquired := make(chan func())
+		go func() {
+			unlock, _ := locker.Lock("ignition-test")
+			acquired <- unlock
+		}()
+		Consistently(acquired, 50*time.Millisecond).ShouldNot(Receive())
+		server.FastForward(time.Minute)
+		var unlock func()
+		Eventually(acquired).Should(Receive(&unlock))
+		unlock()
+	})
+
+	it("does not release a lock that another instance holds", func() {
+		unlock, err := locker.Lock("ignition-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		// the lock expired, and another instance acquired it
+		conn := pool.Get()
+		_, err = conn.Do("SET", "ignition:lock:ignition-test", "other-instance")
+		conn.Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		unlock()
+		value, ok := server.Get("ignition:lock:ignition-test")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal("other-instance"))
+	})
+
+	it("errors when redis cannot be reached", func() {
+		server.Close()
+		_, err := locker.Lock("ignition-test")
+		Expect(err).To(HaveOccurred())
+	})
+}
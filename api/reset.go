@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pivotalservices/ignition/user"
+	"github.com/pkg/errors"
+)
+
+// ResetOrganizationHandler tears down the user's development organization and
+// everything in it, and then provisions a fresh one in the same foundation.
+// When the user does not have an org, one is provisioned in the foundation
+// selected for them.
+func ResetOrganizationHandler(orgPrefix string, t cloudfoundry.OrgTemplate, l Locker, foundations []Foundation) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		logger := logging.FromContext(req.Context())
+		userID, accountName, err := userInfoFromContext(req.Context())
+		if err != nil {
+			logger.Error("could not get user info", "error", err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		profile, _ := user.ProfileFromContext(req.Context())
+		orgName := OrganizationName(orgPrefix, accountName)
+		unlock, err := l.Lock(orgName)
+		if err != nil {
+			logger.Error("could not lock org", "org", orgName, "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer unlock()
+
+		orgs, err := FindOrgsForUser(orgName, userID, profile, foundations)
+		if err != nil {
+			logger.Error("could not find org", "org", orgName, "error", err)
+			writeProvisioningError(w, err)
+			return
+		}
+		var f *Foundation
+		if len(orgs) > 0 {
+			f = findFoundation(foundations, orgs[0].Foundation)
+		} else {
+			f, err = SelectFoundation(foundations, profile, req.URL.Query().Get("foundation"))
+			if err != nil {
+				logger.Warn("could not select foundation", "org", orgName, "error", err)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		foundationUserID, err := foundationUserID(foundations, f, userID, profile, true)
+		if err != nil {
+			logger.Error("could not find user in foundation", "foundation", f.Name, "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		org, err := ResetOrgForUser(orgName, f.AppsURL, foundationUserID, f.QuotaID, f.ISOSegmentID, t, f.CC)
+		if err != nil {
+			logger.Error("could not reset org", "org", orgName, "error", err)
+			switch err.(type) {
+			case OrgNotResettableError:
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				writeProvisioningError(w, err)
+			}
+			return
+		}
+		org.Foundation = f.Name
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(org)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// OrgNotResettableError indicates that the org found for the user was not
+// provisioned by ignition, and so cannot be reset
+type OrgNotResettableError string
+
+func (o OrgNotResettableError) Error() string {
+	return "organization " + string(o) + " was not created by ignition and cannot be reset"
+}
+
+// ResetOrgForUser deletes the user's ignition org, if it exists, along with
+// all of its apps, service instances, routes, and spaces, and then creates the
+// org again
+func ResetOrgForUser(name, appsURL, userID, quotaID, isoSegmentID string, t cloudfoundry.OrgTemplate, a cloudfoundry.API) (*cloudfoundry.Organization, error) {
+	org, err := FindOrgForUser(name, appsURL, userID, quotaID, a)
+	if err != nil {
+		if _, ok := err.(OrgNotFoundError); !ok {
+			return nil, err
+		}
+		org = nil
+	}
+	if org != nil {
+		if !strings.EqualFold(org.QuotaDefinitionGUID, quotaID) {
+			return nil, OrgNotResettableError(org.Name)
+		}
+		err = cloudfoundry.TearDownOrg(org.GUID, a)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not tear down org [%s]", org.Name)
+		}
+		audit.Record(audit.Event{Type: audit.EventOrgDeleted, UserID: userID, Org: org.Name, OrgGUID: org.GUID, Reason: "reset"})
+	}
+	return CreateOrgForUser(name, appsURL, userID, quotaID, isoSegmentID, t, a)
+}
@@ -0,0 +1,145 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/uaa"
+	"github.com/pivotalservices/ignition/uaa/uaafakes"
+	"github.com/pivotalservices/ignition/user"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestResetOrganizationHandler(t *testing.T) {
+	spec.Run(t, "ResetOrganizationHandler", testResetOrganizationHandler, spec.Report(report.Terminal{}))
+}
+
+func testResetOrganizationHandler(t *testing.T, when spec.G, it spec.S) {
+	var r *http.Request
+	var w *httptest.ResponseRecorder
+	var c *cloudfoundryfakes.FakeAPI
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodPost, "/", nil)
+		c = &cloudfoundryfakes.FakeAPI{}
+		c.CreateOrgReturns(cloudfoundry.Organization{
+			GUID:                "test-new-org-guid",
+			Name:                "ignition-testuser",
+			QuotaDefinitionGUID: "test-quota-id",
+		}, nil)
+	})
+
+	when("there is no user id in the context", func() {
+		it("is unavailable when the user cannot be created in the foundation", func() {
+			profile := &user.Profile{
+				AccountName: "testuser@test.com",
+			}
+			r = r.WithContext(user.WithProfile(r.Context(), profile))
+			u := &uaafakes.FakeAPI{}
+			u.UserIDForAccountNameReturns("", uaa.UserNotFoundError("testuser@test.com"))
+			u.CreateUserReturns("", errors.New("test error"))
+			foundations := singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)
+			foundations[0].UAA = u
+			api.ResetOrganizationHandler("ignition", playground, &api.LocalLocker{}, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(u.CreateUserCallCount()).To(Equal(1))
+			Expect(c.DeleteOrgCallCount()).To(Equal(0))
+			Expect(c.CreateOrgCallCount()).To(Equal(0))
+		})
+	})
+
+	when("there is a profile and a user id in the context", func() {
+		it.Before(func() {
+			profile := &user.Profile{
+				AccountName: "testuser@test.com",
+			}
+			r = r.WithContext(user.WithProfile(session.ContextWithUserID(r.Context(), "test-user-id"), profile))
+		})
+
+		when("orgs cannot be retrieved", func() {
+			it.Before(func() {
+				c.ListOrgsReturns(nil, errors.New("test error"))
+			})
+
+			it("is an internal server error", func() {
+				api.ResetOrganizationHandler("ignition", playground, &api.LocalLocker{}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusInternalServerError))
+				Expect(c.CreateOrgCallCount()).To(Equal(0))
+			})
+		})
+
+		when("the user has no org", func() {
+			it("creates the org", func() {
+				api.ResetOrganizationHandler("ignition", playground, &api.LocalLocker{}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Body.String()).To(ContainSubstring("test-new-org-guid"))
+				Expect(c.DeleteOrgCallCount()).To(Equal(0))
+				Expect(c.CreateOrgCallCount()).To(Equal(1))
+			})
+		})
+
+		when("the user has an ignition org", func() {
+			it.Before(func() {
+				c.ListOrgsReturns([]cloudfoundry.Organization{
+					cloudfoundry.Organization{
+						GUID:                "test-org-guid",
+						Name:                "ignition-testuser",
+						QuotaDefinitionGUID: "test-quota-id",
+					},
+				}, nil)
+			})
+
+			it("tears down the org and creates it again", func() {
+				api.ResetOrganizationHandler("ignition", playground, &api.LocalLocker{}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Body.String()).To(ContainSubstring("test-new-org-guid"))
+				Expect(c.DeleteOrgCallCount()).To(Equal(1))
+				guid, _, _ := c.DeleteOrgArgsForCall(0)
+				Expect(guid).To(Equal("test-org-guid"))
+				Expect(c.CreateOrgCallCount()).To(Equal(1))
+			})
+
+			it("is an internal server error when the org cannot be torn down", func() {
+				c.DeleteOrgReturns(errors.New("test error"))
+				api.ResetOrganizationHandler("ignition", playground, &api.LocalLocker{}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusInternalServerError))
+				Expect(c.CreateOrgCallCount()).To(Equal(0))
+			})
+
+			it("is an internal server error when the org cannot be created again", func() {
+				c.CreateOrgReturns(cloudfoundry.Organization{}, errors.New("test error"))
+				api.ResetOrganizationHandler("ignition", playground, &api.LocalLocker{}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+
+		when("the user's org was not created by ignition", func() {
+			it.Before(func() {
+				c.ListOrgsReturns([]cloudfoundry.Organization{
+					cloudfoundry.Organization{
+						GUID:                "test-org-guid",
+						Name:                "ignition-testuser",
+						QuotaDefinitionGUID: "some-other-quota-id",
+					},
+				}, nil)
+			})
+
+			it("is forbidden", func() {
+				api.ResetOrganizationHandler("ignition", playground, &api.LocalLocker{}, singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)).ServeHTTP(w, r)
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+				Expect(c.DeleteOrgCallCount()).To(Equal(0))
+				Expect(c.CreateOrgCallCount()).To(Equal(0))
+			})
+		})
+	})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pivotalservices/ignition/logging"
+)
+
+// OrganizationStatusHandler reports the status of the most recent job to
+// provision the user's development organization in any of the foundations.
+// The optional foundation query parameter restricts the response to the jobs
+// in a foundation, and the optional id query parameter to a specific job.
+func OrganizationStatusHandler(orgPrefix string, j *Jobs, foundations []Foundation) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, accountName, err := userInfoFromContext(req.Context())
+		if err != nil {
+			logging.FromContext(req.Context()).Error("could not get user info", "error", err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		searched := foundations
+		if name := req.URL.Query().Get("foundation"); name != "" {
+			f := findFoundation(foundations, name)
+			if f == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			searched = []Foundation{*f}
+		}
+		job, ok, err := j.Latest(searched, OrganizationName(orgPrefix, accountName))
+		if err != nil {
+			logging.FromContext(req.Context()).Error("could not get the job", "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if id := req.URL.Query().Get("id"); id != "" && id != job.ID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(job)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,188 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+	cfclient "github.com/cloudfoundry-community/go-cfclient"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/user"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestOrganizationStatusHandler(t *testing.T) {
+	spec.Run(t, "OrganizationStatusHandler", testOrganizationStatusHandler, spec.Report(report.Terminal{}))
+}
+
+func testOrganizationStatusHandler(t *testing.T, when spec.G, it spec.S) {
+	var (
+		r           *http.Request
+		w           *httptest.ResponseRecorder
+		c           *cloudfoundryfakes.FakeAPI
+		jobs        *api.Jobs
+		foundations []api.Foundation
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		w = httptest.NewRecorder()
+		c = &cloudfoundryfakes.FakeAPI{}
+		jobs = &api.Jobs{Wait: time.Second, Store: &api.MemoryJobStore{}}
+		foundations = singleFoundation("http://example.net", "test-quota-id", "test-iso-segment-id", c)
+		profile := &user.Profile{
+			AccountName: "testuser@test.com",
+		}
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(user.WithProfile(session.ContextWithUserID(r.Context(), "test-user-id"), profile))
+	})
+
+	it("is not found when there is no profile in the context", func() {
+		api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+	})
+
+	it("is not found when no org has been provisioned", func() {
+		api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+	})
+
+	it("is unavailable when the jobs cannot be read", func() {
+		jobs = &api.Jobs{Store: failingJobStore{}}
+		api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, r)
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	when("the org has been provisioned", func() {
+		var id string
+
+		it.Before(func() {
+			c.CreateOrgReturns(cloudfoundry.Organization{
+				GUID:                "test-org-guid",
+				Name:                "ignition-testuser",
+				QuotaDefinitionGUID: "test-quota-id",
+			}, nil)
+			rec := httptest.NewRecorder()
+			api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, jobs, foundations).ServeHTTP(rec, r)
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			job, ok, err := jobs.Get("default", "ignition-testuser")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			id = job.ID
+		})
+
+		it("reports each step of the job", func() {
+			api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			j, err := simplejson.NewFromReader(w.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(j.GetPath("id").MustString()).To(Equal(id))
+			Expect(j.GetPath("foundation").MustString()).To(Equal("default"))
+			Expect(j.GetPath("state").MustString()).To(Equal("succeeded"))
+			Expect(j.GetPath("org", "guid").MustString()).To(Equal("test-org-guid"))
+			steps := j.GetPath("steps")
+			Expect(steps.MustArray()).To(HaveLen(4))
+			for i, name := range []string{"org", "iso_segment", "roles", "space:playground"} {
+				Expect(steps.GetIndex(i).Get("name").MustString()).To(Equal(name))
+				Expect(steps.GetIndex(i).Get("state").MustString()).To(Equal("succeeded"))
+			}
+		})
+
+		it("reports the job to the other instances that share the job store", func() {
+			r.URL.RawQuery = "id=" + id
+			api.OrganizationStatusHandler("ignition", &api.Jobs{Store: jobs.Store}, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+
+		it("is not found when the id does not match the job", func() {
+			r.URL.RawQuery = "id=another-job"
+			api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		it("reports the job when the id matches", func() {
+			r.URL.RawQuery = "id=" + id
+			api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	when("there are jobs in several foundations", func() {
+		it.Before(func() {
+			foundations = []api.Foundation{{Name: "default", CC: c}, {Name: "emea", CC: c}}
+			now := time.Now()
+			Expect(jobs.Store.Put(api.Job{ID: "default-job", OrgName: "ignition-testuser", Foundation: "default", State: api.StateSucceeded, CreatedAt: now.Add(-time.Minute), UpdatedAt: now})).To(Succeed())
+			Expect(jobs.Store.Put(api.Job{ID: "emea-job", OrgName: "ignition-testuser", Foundation: "emea", State: api.StateRunning, CreatedAt: now, UpdatedAt: now})).To(Succeed())
+		})
+
+		it("reports the most recent job", func() {
+			api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			j, err := simplejson.NewFromReader(w.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(j.GetPath("id").MustString()).To(Equal("emea-job"))
+			Expect(j.GetPath("foundation").MustString()).To(Equal("emea"))
+		})
+
+		it("reports the job in the requested foundation", func() {
+			r.URL.RawQuery = "foundation=default"
+			api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			j, err := simplejson.NewFromReader(w.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(j.GetPath("id").MustString()).To(Equal("default-job"))
+		})
+
+		it("is not found when the requested foundation does not exist", func() {
+			r.URL.RawQuery = "foundation=unknown"
+			api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	when("provisioning the org failed", func() {
+		it.Before(func() {
+			c.CreateOrgReturns(cloudfoundry.Organization{
+				GUID:                "test-org-guid",
+				Name:                "ignition-testuser",
+				QuotaDefinitionGUID: "test-quota-id",
+			}, nil)
+			c.CreateSpaceReturns(cloudfoundry.Space{}, cfclient.CloudFoundryHTTPError{StatusCode: 400})
+			rec := httptest.NewRecorder()
+			api.OrganizationHandler("ignition", playground, &api.LocalLocker{}, jobs, foundations).ServeHTTP(rec, r)
+			Expect(rec.Code).To(Equal(http.StatusInternalServerError))
+		})
+
+		it("reports the failed step and the steps that were rolled back", func() {
+			api.OrganizationStatusHandler("ignition", jobs, foundations).ServeHTTP(w, r)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			j, err := simplejson.NewFromReader(w.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(j.GetPath("state").MustString()).To(Equal("failed"))
+			Expect(j.GetPath("error", "step").MustString()).To(Equal("space:playground"))
+			steps := j.GetPath("steps")
+			Expect(steps.GetIndex(0).Get("state").MustString()).To(Equal("rolled_back"))
+			Expect(steps.GetIndex(3).Get("state").MustString()).To(Equal("failed"))
+			Expect(steps.GetIndex(3).Get("error").MustString()).NotTo(BeEmpty())
+		})
+	})
+}
+
+// failingJobStore is a JobStore that cannot be reached
+type failingJobStore struct{}
+
+func (failingJobStore) Get(foundation, orgName string) (api.Job, bool, error) {
+	return api.Job{}, false, errors.New("test error")
+}
+
+func (failingJobStore) Put(job api.Job) error {
+	return errors.New("test error")
+}
@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotalservices/ignition/logging"
+)
+
+// The types of audit event
+const (
+	EventLogin           = "login"
+	EventLoginDenied     = "login_denied"
+	EventUserCreated     = "user_created"
+	EventOrgCreated      = "org_created"
+	EventRoleGranted     = "role_granted"
+	EventSpaceCreated    = "space_created"
+	EventOrgDeleted      = "org_deleted"
+	EventSessionsRevoked = "sessions_revoked"
+)
+
+// Event is something that ignition did, or refused to do, on behalf of a user
+type Event struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	AccountName string    `json:"account_name,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	Org         string    `json:"org,omitempty"`
+	OrgGUID     string    `json:"org_guid,omitempty"`
+	Space       string    `json:"space,omitempty"`
+	Role        string    `json:"role,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+
+	// Actor is the admin that acted on the user's behalf, if any
+	Actor string `json:"actor,omitempty"`
+}
+
+// Sink persists audit events
+type Sink interface {
+	Write(e Event) error
+}
+
+// Reader is a Sink that can read back the events written to it
+type Reader interface {
+	Read() ([]Event, error)
+}
+
+// Filter selects audit events. Fields that are not set match every event.
+type Filter struct {
+	Type        string
+	AccountName string
+	UserID      string
+	Org         string
+	Since       time.Time
+	Limit       int
+}
+
+func (f Filter) matches(e Event) bool {
+	if f.Type != "" && f.Type != e.Type {
+		return false
+	}
+	if f.AccountName != "" && f.AccountName != e.AccountName {
+		return false
+	}
+	if f.UserID != "" && f.UserID != e.UserID {
+		return false
+	}
+	if f.Org != "" && f.Org != e.Org {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// Log records audit events to its sinks, and keeps the most recent events so
+// that they can be queried
+type Log struct {
+	sinks []Sink
+	size  int
+
+	mu     sync.Mutex
+	recent []Event
+}
+
+// NewLog returns a Log that writes to the sinks and keeps the given number of
+// recent events. The recent events are loaded from the first sink that is a
+// Reader.
+func NewLog(size int, sinks ...Sink) *Log {
+	l := &Log{
+		sinks: sinks,
+		size:  size,
+	}
+	for _, s := range sinks {
+		r, ok := s.(Reader)
+		if !ok {
+			continue
+		}
+		events, err := r.Read()
+		if err != nil {
+			logging.Default().Error("could not read audit events", "error", err)
+			break
+		}
+		if len(events) > size {
+			events = events[len(events)-size:]
+		}
+		l.recent = events
+		break
+	}
+	return l
+}
+
+// Record writes the event to each of the sinks. The event's time is set if it
+// is zero.
+func (l *Log) Record(e Event) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	l.mu.Lock()
+	l.recent = append(l.recent, e)
+	if len(l.recent) > l.size {
+		l.recent = l.recent[len(l.recent)-l.size:]
+	}
+	l.mu.Unlock()
+
+	for _, s := range l.sinks {
+		err := s.Write(e)
+		if err != nil {
+			logging.Default().Error("could not write audit event", "type", e.Type, "error", err)
+		}
+	}
+}
+
+// Recent returns the recent events that match the filter, newest first
+func (l *Log) Recent(f Filter) []Event {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := []Event{}
+	for i := len(l.recent) - 1; i >= 0; i-- {
+		if !f.matches(l.recent[i]) {
+			continue
+		}
+		events = append(events, l.recent[i])
+		if f.Limit > 0 && len(events) == f.Limit {
+			break
+		}
+	}
+	return events
+}
+
+var (
+	stdMu sync.RWMutex
+	std   *Log
+)
+
+// Default returns the audit log that events are recorded to. It is nil, and
+// events are discarded, until SetDefault is called.
+func Default() *Log {
+	stdMu.RLock()
+	defer stdMu.RUnlock()
+	return std
+}
+
+// SetDefault replaces the audit log that events are recorded to
+func SetDefault(l *Log) {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	std = l
+}
+
+// Record records the event to the default audit log
+func Record(e Event) {
+	Default().Record(e)
+}
@@ -0,0 +1,136 @@
+package audit_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+type memorySink struct {
+	events []audit.Event
+	err    error
+}
+
+func (s *memorySink) Write(e audit.Event) error {
+	s.events = append(s.events, e)
+	return s.err
+}
+
+func TestLog(t *testing.T) {
+	spec.Run(t, "Log", testLog, spec.Report(report.Terminal{}))
+}
+
+func testLog(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("writes events to each sink and sets their time", func() {
+		a, b := &memorySink{}, &memorySink{err: errors.New("test error")}
+		l := audit.NewLog(10, a, b)
+		l.Record(audit.Event{Type: audit.EventLogin, AccountName: "test"})
+		Expect(a.events).To(HaveLen(1))
+		Expect(b.events).To(HaveLen(1))
+		Expect(a.events[0].Time).NotTo(BeZero())
+		Expect(l.Recent(audit.Filter{})).To(HaveLen(1))
+	})
+
+	it("returns the most recent events first, up to its size", func() {
+		l := audit.NewLog(2)
+		l.Record(audit.Event{Type: audit.EventLogin, AccountName: "one"})
+		l.Record(audit.Event{Type: audit.EventLogin, AccountName: "two"})
+		l.Record(audit.Event{Type: audit.EventLogin, AccountName: "three"})
+		events := l.Recent(audit.Filter{})
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].AccountName).To(Equal("three"))
+		Expect(events[1].AccountName).To(Equal("two"))
+	})
+
+	it("filters the recent events", func() {
+		now := time.Now()
+		l := audit.NewLog(10)
+		l.Record(audit.Event{Time: now.Add(-time.Hour), Type: audit.EventOrgCreated, Org: "ignition-a"})
+		l.Record(audit.Event{Time: now, Type: audit.EventOrgCreated, Org: "ignition-b", UserID: "b"})
+		l.Record(audit.Event{Time: now, Type: audit.EventSpaceCreated, Org: "ignition-b", UserID: "b"})
+		l.Record(audit.Event{Time: now, Type: audit.EventLogin, AccountName: "b"})
+
+		Expect(l.Recent(audit.Filter{Type: audit.EventOrgCreated})).To(HaveLen(2))
+		Expect(l.Recent(audit.Filter{Org: "ignition-b"})).To(HaveLen(2))
+		Expect(l.Recent(audit.Filter{UserID: "b", Type: audit.EventSpaceCreated})).To(HaveLen(1))
+		Expect(l.Recent(audit.Filter{AccountName: "b"})).To(HaveLen(1))
+		Expect(l.Recent(audit.Filter{Since: now.Add(-time.Minute)})).To(HaveLen(3))
+		Expect(l.Recent(audit.Filter{Limit: 1})).To(HaveLen(1))
+	})
+
+	it("discards events when there is no log", func() {
+		var l *audit.Log
+		l.Record(audit.Event{Type: audit.EventLogin})
+		Expect(l.Recent(audit.Filter{})).To(BeNil())
+		audit.Record(audit.Event{Type: audit.EventLogin})
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	spec.Run(t, "FileSink", testFileSink, spec.Report(report.Terminal{}))
+}
+
+func testFileSink(t *testing.T, when spec.G, it spec.S) {
+	var dir string
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		var err error
+		dir, err = ioutil.TempDir("", "ignition-audit")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		os.RemoveAll(dir)
+	})
+
+	it("appends events to the file as JSON lines", func() {
+		path := filepath.Join(dir, "audit.log")
+		s, err := audit.NewFileSink(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+		Expect(s.Write(audit.Event{Type: audit.EventLogin, AccountName: "test"})).To(Succeed())
+		Expect(s.Write(audit.Event{Type: audit.EventOrgDeleted, Org: "ignition-test"})).To(Succeed())
+
+		b, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(HavePrefix(`{"time":"0001-01-01T00:00:00Z","type":"login","account_name":"test"}` + "\n"))
+
+		events, err := s.Read()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(2))
+		Expect(events[1].Org).To(Equal("ignition-test"))
+	})
+
+	it("loads the recent events from the file", func() {
+		path := filepath.Join(dir, "audit.log")
+		Expect(ioutil.WriteFile(path, []byte("not json\n"+`{"type":"login","account_name":"earlier"}`+"\n"), 0600)).To(Succeed())
+		s, err := audit.NewFileSink(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		l := audit.NewLog(10, s)
+		l.Record(audit.Event{Type: audit.EventLogin, AccountName: "later"})
+		events := l.Recent(audit.Filter{})
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].AccountName).To(Equal("later"))
+		Expect(events[1].AccountName).To(Equal("earlier"))
+	})
+
+	it("fails when the file cannot be opened", func() {
+		_, err := audit.NewFileSink(filepath.Join(dir, "missing", "audit.log"))
+		Expect(err).To(HaveOccurred())
+	})
+}
@@ -0,0 +1,53 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package audit
+
+import (
+	"encoding/json"
+	"log/syslog"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// syslogTag identifies ignition's messages in the syslog
+const syslogTag = "ignition-audit"
+
+// SyslogSink writes audit events to a syslog server as JSON
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink connects to the syslog server at the address, which is either
+// "local" for the local syslog daemon, or a URL such as
+// udp://syslog.example.net:514
+func NewSyslogSink(address string) (*SyslogSink, error) {
+	network, addr := "", ""
+	if address != "local" {
+		u, err := url.Parse(address)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, errors.Errorf("invalid syslog address [%s]", address)
+		}
+		network, addr = u.Scheme, u.Host
+	}
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_AUTH, syslogTag)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not connect to syslog [%s]", address)
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+// Write sends the event to the syslog server
+func (s *SyslogSink) Write(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.w.Info(string(b))
+}
+
+// Close closes the connection to the syslog server
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,25 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package audit
+
+import "errors"
+
+// SyslogSink is not supported on this platform
+type SyslogSink struct{}
+
+// NewSyslogSink returns an error, because syslog is not supported on this
+// platform
+func NewSyslogSink(address string) (*SyslogSink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+// Write does nothing
+func (s *SyslogSink) Write(e Event) error {
+	return nil
+}
+
+// Close does nothing
+func (s *SyslogSink) Close() error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package cloudfoundry
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LastActivityForOrg returns the most recent time that the org, or any app in
+// the org, was updated. An org with a started app is in use, however long ago
+// the app was pushed, so its last activity is now. It errors when the org has
+// no creation time, or when any of the timestamps cannot be parsed, rather
+// than treating the org as idle since the zero time.
+func LastActivityForOrg(org Organization, q AppQuerier) (time.Time, error) {
+	if strings.TrimSpace(org.CreatedAt) == "" {
+		return time.Time{}, errors.Errorf("org [%s] has no created_at timestamp", org.Name)
+	}
+	var last time.Time
+	for _, ts := range []string{org.CreatedAt, org.UpdatedAt} {
+		t, err := parseTimestamp(ts)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "could not determine the activity of org [%s]", org.Name)
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	apps, err := q.ListApps(AppQuery{OrgGUID: org.GUID})
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "could not list apps for org [%s]", org.Name)
+	}
+	for _, a := range apps {
+		if strings.EqualFold(a.State, AppStateStarted) {
+			return time.Now(), nil
+		}
+	}
+	for _, a := range apps {
+		for _, ts := range []string{a.UpdatedAt, a.CreatedAt, a.PackageUpdatedAt} {
+			t, err := parseTimestamp(ts)
+			if err != nil {
+				return time.Time{}, errors.Wrapf(err, "could not determine the activity of app [%s] in org [%s]", a.Name, org.Name)
+			}
+			if t.After(last) {
+				last = t
+			}
+		}
+	}
+	return last, nil
+}
+
+// parseTimestamp parses an RFC 3339 timestamp; an empty timestamp, which the
+// Cloud Controller returns for resources that have not been updated, is the
+// zero time
+func parseTimestamp(s string) (time.Time, error) {
+	if strings.TrimSpace(s) == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("timestamp [%s] is not an RFC 3339 time", s)
+	}
+	return t, nil
+}
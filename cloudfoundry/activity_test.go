@@ -0,0 +1,110 @@
+package cloudfoundry_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestLastActivityForOrg(t *testing.T) {
+	spec.Run(t, "LastActivityForOrg", testLastActivityForOrg, spec.Report(report.Terminal{}))
+}
+
+func testLastActivityForOrg(t *testing.T, when spec.G, it spec.S) {
+	var (
+		f   *cloudfoundryfakes.FakeAPI
+		org cloudfoundry.Organization
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		f = &cloudfoundryfakes.FakeAPI{}
+		org = cloudfoundry.Organization{
+			GUID:      "test-org-guid",
+			Name:      "ignition-test",
+			CreatedAt: "2018-01-01T00:00:00Z",
+			UpdatedAt: "2018-02-01T00:00:00Z",
+		}
+	})
+
+	it("errors when the apps cannot be listed", func() {
+		f.ListAppsReturns(nil, errors.New("test error"))
+		last, err := cloudfoundry.LastActivityForOrg(org, f)
+		Expect(err).To(HaveOccurred())
+		Expect(last).To(BeZero())
+	})
+
+	it("queries the apps in the org", func() {
+		cloudfoundry.LastActivityForOrg(org, f)
+		Expect(f.ListAppsCallCount()).To(Equal(1))
+		Expect(f.ListAppsArgsForCall(0)).To(Equal(cloudfoundry.AppQuery{OrgGUID: "test-org-guid"}))
+	})
+
+	it("uses the org timestamps when there are no apps", func() {
+		last, err := cloudfoundry.LastActivityForOrg(org, f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last).To(Equal(time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	it("uses the most recent app timestamp", func() {
+		f.ListAppsReturns([]cloudfoundry.App{
+			cloudfoundry.App{
+				UpdatedAt: "2018-03-01T00:00:00Z",
+			},
+			cloudfoundry.App{
+				UpdatedAt:        "2018-03-02T00:00:00Z",
+				PackageUpdatedAt: "2018-04-01T00:00:00Z",
+			},
+			cloudfoundry.App{
+				CreatedAt: "2018-01-02T00:00:00Z",
+			},
+		}, nil)
+		last, err := cloudfoundry.LastActivityForOrg(org, f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last).To(Equal(time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	it("treats an org with a started app as active now", func() {
+		f.ListAppsReturns([]cloudfoundry.App{
+			cloudfoundry.App{State: "STOPPED", UpdatedAt: "2018-03-01T00:00:00Z"},
+			cloudfoundry.App{State: "STARTED", UpdatedAt: "2018-01-15T00:00:00Z"},
+		}, nil)
+		last, err := cloudfoundry.LastActivityForOrg(org, f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last).To(BeTemporally("~", time.Now(), time.Minute))
+	})
+
+	it("uses the creation time of an org that has not been updated", func() {
+		org.UpdatedAt = ""
+		last, err := cloudfoundry.LastActivityForOrg(org, f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last).To(Equal(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	it("errors when the org has no creation time", func() {
+		org.CreatedAt = ""
+		last, err := cloudfoundry.LastActivityForOrg(org, f)
+		Expect(err).To(MatchError("org [ignition-test] has no created_at timestamp"))
+		Expect(last).To(BeZero())
+		Expect(f.ListAppsCallCount()).To(Equal(0))
+	})
+
+	it("errors when a timestamp cannot be parsed", func() {
+		org.UpdatedAt = "yesterday"
+		_, err := cloudfoundry.LastActivityForOrg(org, f)
+		Expect(err).To(MatchError(ContainSubstring("timestamp [yesterday] is not an RFC 3339 time")))
+
+		org.UpdatedAt = ""
+		f.ListAppsReturns([]cloudfoundry.App{
+			cloudfoundry.App{Name: "garbage", UpdatedAt: "garbage"},
+		}, nil)
+		_, err = cloudfoundry.LastActivityForOrg(org, f)
+		Expect(err).To(MatchError(ContainSubstring("could not determine the activity of app [garbage] in org [ignition-test]")))
+	})
+}
@@ -5,8 +5,23 @@ package cloudfoundry
 type API interface {
 	OrganizationCreator
 	OrganizationQuerier
+	OrganizationDeleter
+	OrganizationManagerQuerier
 	SpaceCreator
+	SpaceQuerier
+	SpaceDeleter
+	SpaceQuotaCreator
 	RoleGrantor
 	QuotaQuerier
 	ISOSegmentQuerier
+	SecurityGroupQuerier
+	SecurityGroupBinder
+	AppQuerier
+	AppDeleter
+	ServicePlanQuerier
+	ServiceInstanceQuerier
+	ServiceInstanceCreator
+	ServiceInstanceDeleter
+	RouteQuerier
+	RouteDeleter
 }
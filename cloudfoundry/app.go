@@ -0,0 +1,31 @@
+package cloudfoundry
+
+// App is an app in a space
+type App struct {
+	GUID             string `json:"guid"`
+	Name             string `json:"name"`
+	SpaceGUID        string `json:"space_guid"`
+	State            string `json:"state"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+	PackageUpdatedAt string `json:"package_updated_at"`
+}
+
+// AppStateStarted is the state of an app that is meant to be running
+const AppStateStarted = "STARTED"
+
+// AppQuery filters the apps that are listed; the apps are not filtered by the
+// fields that are empty
+type AppQuery struct {
+	OrgGUID string
+}
+
+// AppQuerier is used to query a Cloud Controller API for apps
+type AppQuerier interface {
+	ListApps(query AppQuery) ([]App, error)
+}
+
+// AppDeleter deletes apps
+type AppDeleter interface {
+	DeleteApp(guid string) error
+}
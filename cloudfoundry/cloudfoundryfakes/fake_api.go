@@ -2,39 +2,24 @@
 package cloudfoundryfakes
 
 import (
-	"net/url"
 	"sync"
 
-	"github.com/cloudfoundry-community/go-cfclient"
 	"github.com/pivotalservices/ignition/cloudfoundry"
 )
 
 type FakeAPI struct {
-	CreateOrgStub        func(req cfclient.OrgRequest) (cfclient.Org, error)
+	CreateOrgStub        func(name, quotaGUID string) (cloudfoundry.Organization, error)
 	createOrgMutex       sync.RWMutex
 	createOrgArgsForCall []struct {
-		req cfclient.OrgRequest
+		name      string
+		quotaGUID string
 	}
 	createOrgReturns struct {
-		result1 cfclient.Org
+		result1 cloudfoundry.Organization
 		result2 error
 	}
 	createOrgReturnsOnCall map[int]struct {
-		result1 cfclient.Org
-		result2 error
-	}
-	UpdateOrgStub        func(orgGUID string, orgRequest cfclient.OrgRequest) (cfclient.Org, error)
-	updateOrgMutex       sync.RWMutex
-	updateOrgArgsForCall []struct {
-		orgGUID    string
-		orgRequest cfclient.OrgRequest
-	}
-	updateOrgReturns struct {
-		result1 cfclient.Org
-		result2 error
-	}
-	updateOrgReturnsOnCall map[int]struct {
-		result1 cfclient.Org
+		result1 cloudfoundry.Organization
 		result2 error
 	}
 	AddIsolationSegmentToOrgStub        func(isolationSegmentGUID, orgGUID string) error
@@ -49,114 +34,349 @@ type FakeAPI struct {
 	addIsolationSegmentToOrgReturnsOnCall map[int]struct {
 		result1 error
 	}
-	ListOrgsByQueryStub        func(query url.Values) ([]cfclient.Org, error)
-	listOrgsByQueryMutex       sync.RWMutex
-	listOrgsByQueryArgsForCall []struct {
-		query url.Values
+	SetOrgDefaultIsolationSegmentStub        func(orgGUID, isolationSegmentGUID string) error
+	setOrgDefaultIsolationSegmentMutex       sync.RWMutex
+	setOrgDefaultIsolationSegmentArgsForCall []struct {
+		orgGUID              string
+		isolationSegmentGUID string
+	}
+	setOrgDefaultIsolationSegmentReturns struct {
+		result1 error
+	}
+	setOrgDefaultIsolationSegmentReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ListOrgsStub        func(query cloudfoundry.OrgQuery) ([]cloudfoundry.Organization, error)
+	listOrgsMutex       sync.RWMutex
+	listOrgsArgsForCall []struct {
+		query cloudfoundry.OrgQuery
+	}
+	listOrgsReturns struct {
+		result1 []cloudfoundry.Organization
+		result2 error
+	}
+	listOrgsReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.Organization
+		result2 error
+	}
+	DeleteOrgStub        func(guid string, recursive, async bool) error
+	deleteOrgMutex       sync.RWMutex
+	deleteOrgArgsForCall []struct {
+		guid      string
+		recursive bool
+		async     bool
+	}
+	deleteOrgReturns struct {
+		result1 error
+	}
+	deleteOrgReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ListOrgManagersStub        func(orgGUID string) ([]cloudfoundry.User, error)
+	listOrgManagersMutex       sync.RWMutex
+	listOrgManagersArgsForCall []struct {
+		orgGUID string
 	}
-	listOrgsByQueryReturns struct {
-		result1 []cfclient.Org
+	listOrgManagersReturns struct {
+		result1 []cloudfoundry.User
 		result2 error
 	}
-	listOrgsByQueryReturnsOnCall map[int]struct {
-		result1 []cfclient.Org
+	listOrgManagersReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.User
 		result2 error
 	}
-	CreateSpaceStub        func(req cfclient.SpaceRequest) (cfclient.Space, error)
+	CreateSpaceStub        func(req cloudfoundry.SpaceRequest) (cloudfoundry.Space, error)
 	createSpaceMutex       sync.RWMutex
 	createSpaceArgsForCall []struct {
-		req cfclient.SpaceRequest
+		req cloudfoundry.SpaceRequest
 	}
 	createSpaceReturns struct {
-		result1 cfclient.Space
+		result1 cloudfoundry.Space
 		result2 error
 	}
 	createSpaceReturnsOnCall map[int]struct {
-		result1 cfclient.Space
+		result1 cloudfoundry.Space
+		result2 error
+	}
+	ListSpacesStub        func(query cloudfoundry.SpaceQuery) ([]cloudfoundry.Space, error)
+	listSpacesMutex       sync.RWMutex
+	listSpacesArgsForCall []struct {
+		query cloudfoundry.SpaceQuery
+	}
+	listSpacesReturns struct {
+		result1 []cloudfoundry.Space
+		result2 error
+	}
+	listSpacesReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.Space
+		result2 error
+	}
+	DeleteSpaceStub        func(guid string, recursive, async bool) error
+	deleteSpaceMutex       sync.RWMutex
+	deleteSpaceArgsForCall []struct {
+		guid      string
+		recursive bool
+		async     bool
+	}
+	deleteSpaceReturns struct {
+		result1 error
+	}
+	deleteSpaceReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CreateSpaceQuotaStub        func(req cloudfoundry.SpaceQuotaRequest) (cloudfoundry.SpaceQuota, error)
+	createSpaceQuotaMutex       sync.RWMutex
+	createSpaceQuotaArgsForCall []struct {
+		req cloudfoundry.SpaceQuotaRequest
+	}
+	createSpaceQuotaReturns struct {
+		result1 cloudfoundry.SpaceQuota
+		result2 error
+	}
+	createSpaceQuotaReturnsOnCall map[int]struct {
+		result1 cloudfoundry.SpaceQuota
+		result2 error
+	}
+	ListSpaceQuotasStub        func(query cloudfoundry.SpaceQuotaQuery) ([]cloudfoundry.SpaceQuota, error)
+	listSpaceQuotasMutex       sync.RWMutex
+	listSpaceQuotasArgsForCall []struct {
+		query cloudfoundry.SpaceQuotaQuery
+	}
+	listSpaceQuotasReturns struct {
+		result1 []cloudfoundry.SpaceQuota
 		result2 error
 	}
-	AssociateOrgUserStub        func(orgGUID, userGUID string) (cfclient.Org, error)
+	listSpaceQuotasReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.SpaceQuota
+		result2 error
+	}
+	AssociateOrgUserStub        func(orgGUID, userGUID string) error
 	associateOrgUserMutex       sync.RWMutex
 	associateOrgUserArgsForCall []struct {
 		orgGUID  string
 		userGUID string
 	}
 	associateOrgUserReturns struct {
-		result1 cfclient.Org
-		result2 error
+		result1 error
 	}
 	associateOrgUserReturnsOnCall map[int]struct {
-		result1 cfclient.Org
-		result2 error
+		result1 error
 	}
-	AssociateOrgAuditorStub        func(orgGUID, userGUID string) (cfclient.Org, error)
+	AssociateOrgAuditorStub        func(orgGUID, userGUID string) error
 	associateOrgAuditorMutex       sync.RWMutex
 	associateOrgAuditorArgsForCall []struct {
 		orgGUID  string
 		userGUID string
 	}
 	associateOrgAuditorReturns struct {
-		result1 cfclient.Org
-		result2 error
+		result1 error
 	}
 	associateOrgAuditorReturnsOnCall map[int]struct {
-		result1 cfclient.Org
-		result2 error
+		result1 error
 	}
-	AssociateOrgManagerStub        func(orgGUID, userGUID string) (cfclient.Org, error)
+	AssociateOrgManagerStub        func(orgGUID, userGUID string) error
 	associateOrgManagerMutex       sync.RWMutex
 	associateOrgManagerArgsForCall []struct {
 		orgGUID  string
 		userGUID string
 	}
 	associateOrgManagerReturns struct {
-		result1 cfclient.Org
-		result2 error
+		result1 error
 	}
 	associateOrgManagerReturnsOnCall map[int]struct {
-		result1 cfclient.Org
-		result2 error
+		result1 error
 	}
-	GetOrgQuotaByNameStub        func(name string) (cfclient.OrgQuota, error)
+	GetOrgQuotaByNameStub        func(name string) (cloudfoundry.Quota, error)
 	getOrgQuotaByNameMutex       sync.RWMutex
 	getOrgQuotaByNameArgsForCall []struct {
 		name string
 	}
 	getOrgQuotaByNameReturns struct {
-		result1 cfclient.OrgQuota
+		result1 cloudfoundry.Quota
 		result2 error
 	}
 	getOrgQuotaByNameReturnsOnCall map[int]struct {
-		result1 cfclient.OrgQuota
+		result1 cloudfoundry.Quota
+		result2 error
+	}
+	ListIsolationSegmentsByNameStub        func(name string) ([]cloudfoundry.IsolationSegment, error)
+	listIsolationSegmentsByNameMutex       sync.RWMutex
+	listIsolationSegmentsByNameArgsForCall []struct {
+		name string
+	}
+	listIsolationSegmentsByNameReturns struct {
+		result1 []cloudfoundry.IsolationSegment
+		result2 error
+	}
+	listIsolationSegmentsByNameReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.IsolationSegment
+		result2 error
+	}
+	ListSecurityGroupsByNameStub        func(name string) ([]cloudfoundry.SecurityGroup, error)
+	listSecurityGroupsByNameMutex       sync.RWMutex
+	listSecurityGroupsByNameArgsForCall []struct {
+		name string
+	}
+	listSecurityGroupsByNameReturns struct {
+		result1 []cloudfoundry.SecurityGroup
 		result2 error
 	}
-	ListIsolationSegmentsByQueryStub        func(query url.Values) ([]cfclient.IsolationSegment, error)
-	listIsolationSegmentsByQueryMutex       sync.RWMutex
-	listIsolationSegmentsByQueryArgsForCall []struct {
-		query url.Values
+	listSecurityGroupsByNameReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.SecurityGroup
+		result2 error
+	}
+	BindSecGroupStub        func(secGUID, spaceGUID string) error
+	bindSecGroupMutex       sync.RWMutex
+	bindSecGroupArgsForCall []struct {
+		secGUID   string
+		spaceGUID string
+	}
+	bindSecGroupReturns struct {
+		result1 error
+	}
+	bindSecGroupReturnsOnCall map[int]struct {
+		result1 error
+	}
+	BindStagingSecGroupToSpaceStub        func(secGUID, spaceGUID string) error
+	bindStagingSecGroupToSpaceMutex       sync.RWMutex
+	bindStagingSecGroupToSpaceArgsForCall []struct {
+		secGUID   string
+		spaceGUID string
+	}
+	bindStagingSecGroupToSpaceReturns struct {
+		result1 error
+	}
+	bindStagingSecGroupToSpaceReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ListAppsStub        func(query cloudfoundry.AppQuery) ([]cloudfoundry.App, error)
+	listAppsMutex       sync.RWMutex
+	listAppsArgsForCall []struct {
+		query cloudfoundry.AppQuery
+	}
+	listAppsReturns struct {
+		result1 []cloudfoundry.App
+		result2 error
+	}
+	listAppsReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.App
+		result2 error
+	}
+	DeleteAppStub        func(guid string) error
+	deleteAppMutex       sync.RWMutex
+	deleteAppArgsForCall []struct {
+		guid string
+	}
+	deleteAppReturns struct {
+		result1 error
+	}
+	deleteAppReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ListServicesByLabelStub        func(label string) ([]cloudfoundry.Service, error)
+	listServicesByLabelMutex       sync.RWMutex
+	listServicesByLabelArgsForCall []struct {
+		label string
+	}
+	listServicesByLabelReturns struct {
+		result1 []cloudfoundry.Service
+		result2 error
+	}
+	listServicesByLabelReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.Service
+		result2 error
+	}
+	ListServicePlansForServiceStub        func(serviceGUID string) ([]cloudfoundry.ServicePlan, error)
+	listServicePlansForServiceMutex       sync.RWMutex
+	listServicePlansForServiceArgsForCall []struct {
+		serviceGUID string
+	}
+	listServicePlansForServiceReturns struct {
+		result1 []cloudfoundry.ServicePlan
+		result2 error
+	}
+	listServicePlansForServiceReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.ServicePlan
+		result2 error
+	}
+	ListServiceInstancesStub        func(query cloudfoundry.ServiceInstanceQuery) ([]cloudfoundry.ServiceInstance, error)
+	listServiceInstancesMutex       sync.RWMutex
+	listServiceInstancesArgsForCall []struct {
+		query cloudfoundry.ServiceInstanceQuery
 	}
-	listIsolationSegmentsByQueryReturns struct {
-		result1 []cfclient.IsolationSegment
+	listServiceInstancesReturns struct {
+		result1 []cloudfoundry.ServiceInstance
 		result2 error
 	}
-	listIsolationSegmentsByQueryReturnsOnCall map[int]struct {
-		result1 []cfclient.IsolationSegment
+	listServiceInstancesReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.ServiceInstance
 		result2 error
 	}
+	CreateServiceInstanceStub        func(req cloudfoundry.ServiceInstanceRequest) (cloudfoundry.ServiceInstance, error)
+	createServiceInstanceMutex       sync.RWMutex
+	createServiceInstanceArgsForCall []struct {
+		req cloudfoundry.ServiceInstanceRequest
+	}
+	createServiceInstanceReturns struct {
+		result1 cloudfoundry.ServiceInstance
+		result2 error
+	}
+	createServiceInstanceReturnsOnCall map[int]struct {
+		result1 cloudfoundry.ServiceInstance
+		result2 error
+	}
+	DeleteServiceInstanceStub        func(guid string, recursive, async bool) error
+	deleteServiceInstanceMutex       sync.RWMutex
+	deleteServiceInstanceArgsForCall []struct {
+		guid      string
+		recursive bool
+		async     bool
+	}
+	deleteServiceInstanceReturns struct {
+		result1 error
+	}
+	deleteServiceInstanceReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ListRoutesStub        func(query cloudfoundry.RouteQuery) ([]cloudfoundry.Route, error)
+	listRoutesMutex       sync.RWMutex
+	listRoutesArgsForCall []struct {
+		query cloudfoundry.RouteQuery
+	}
+	listRoutesReturns struct {
+		result1 []cloudfoundry.Route
+		result2 error
+	}
+	listRoutesReturnsOnCall map[int]struct {
+		result1 []cloudfoundry.Route
+		result2 error
+	}
+	DeleteRouteStub        func(guid string) error
+	deleteRouteMutex       sync.RWMutex
+	deleteRouteArgsForCall []struct {
+		guid string
+	}
+	deleteRouteReturns struct {
+		result1 error
+	}
+	deleteRouteReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeAPI) CreateOrg(req cfclient.OrgRequest) (cfclient.Org, error) {
+func (fake *FakeAPI) CreateOrg(name string, quotaGUID string) (cloudfoundry.Organization, error) {
 	fake.createOrgMutex.Lock()
 	ret, specificReturn := fake.createOrgReturnsOnCall[len(fake.createOrgArgsForCall)]
 	fake.createOrgArgsForCall = append(fake.createOrgArgsForCall, struct {
-		req cfclient.OrgRequest
-	}{req})
-	fake.recordInvocation("CreateOrg", []interface{}{req})
+		name      string
+		quotaGUID string
+	}{name, quotaGUID})
+	fake.recordInvocation("CreateOrg", []interface{}{name, quotaGUID})
 	fake.createOrgMutex.Unlock()
 	if fake.CreateOrgStub != nil {
-		return fake.CreateOrgStub(req)
+		return fake.CreateOrgStub(name, quotaGUID)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -170,82 +390,30 @@ func (fake *FakeAPI) CreateOrgCallCount() int {
 	return len(fake.createOrgArgsForCall)
 }
 
-func (fake *FakeAPI) CreateOrgArgsForCall(i int) cfclient.OrgRequest {
+func (fake *FakeAPI) CreateOrgArgsForCall(i int) (string, string) {
 	fake.createOrgMutex.RLock()
 	defer fake.createOrgMutex.RUnlock()
-	return fake.createOrgArgsForCall[i].req
+	return fake.createOrgArgsForCall[i].name, fake.createOrgArgsForCall[i].quotaGUID
 }
 
-func (fake *FakeAPI) CreateOrgReturns(result1 cfclient.Org, result2 error) {
+func (fake *FakeAPI) CreateOrgReturns(result1 cloudfoundry.Organization, result2 error) {
 	fake.CreateOrgStub = nil
 	fake.createOrgReturns = struct {
-		result1 cfclient.Org
+		result1 cloudfoundry.Organization
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) CreateOrgReturnsOnCall(i int, result1 cfclient.Org, result2 error) {
+func (fake *FakeAPI) CreateOrgReturnsOnCall(i int, result1 cloudfoundry.Organization, result2 error) {
 	fake.CreateOrgStub = nil
 	if fake.createOrgReturnsOnCall == nil {
 		fake.createOrgReturnsOnCall = make(map[int]struct {
-			result1 cfclient.Org
+			result1 cloudfoundry.Organization
 			result2 error
 		})
 	}
 	fake.createOrgReturnsOnCall[i] = struct {
-		result1 cfclient.Org
-		result2 error
-	}{result1, result2}
-}
-
-func (fake *FakeAPI) UpdateOrg(orgGUID string, orgRequest cfclient.OrgRequest) (cfclient.Org, error) {
-	fake.updateOrgMutex.Lock()
-	ret, specificReturn := fake.updateOrgReturnsOnCall[len(fake.updateOrgArgsForCall)]
-	fake.updateOrgArgsForCall = append(fake.updateOrgArgsForCall, struct {
-		orgGUID    string
-		orgRequest cfclient.OrgRequest
-	}{orgGUID, orgRequest})
-	fake.recordInvocation("UpdateOrg", []interface{}{orgGUID, orgRequest})
-	fake.updateOrgMutex.Unlock()
-	if fake.UpdateOrgStub != nil {
-		return fake.UpdateOrgStub(orgGUID, orgRequest)
-	}
-	if specificReturn {
-		return ret.result1, ret.result2
-	}
-	return fake.updateOrgReturns.result1, fake.updateOrgReturns.result2
-}
-
-func (fake *FakeAPI) UpdateOrgCallCount() int {
-	fake.updateOrgMutex.RLock()
-	defer fake.updateOrgMutex.RUnlock()
-	return len(fake.updateOrgArgsForCall)
-}
-
-func (fake *FakeAPI) UpdateOrgArgsForCall(i int) (string, cfclient.OrgRequest) {
-	fake.updateOrgMutex.RLock()
-	defer fake.updateOrgMutex.RUnlock()
-	return fake.updateOrgArgsForCall[i].orgGUID, fake.updateOrgArgsForCall[i].orgRequest
-}
-
-func (fake *FakeAPI) UpdateOrgReturns(result1 cfclient.Org, result2 error) {
-	fake.UpdateOrgStub = nil
-	fake.updateOrgReturns = struct {
-		result1 cfclient.Org
-		result2 error
-	}{result1, result2}
-}
-
-func (fake *FakeAPI) UpdateOrgReturnsOnCall(i int, result1 cfclient.Org, result2 error) {
-	fake.UpdateOrgStub = nil
-	if fake.updateOrgReturnsOnCall == nil {
-		fake.updateOrgReturnsOnCall = make(map[int]struct {
-			result1 cfclient.Org
-			result2 error
-		})
-	}
-	fake.updateOrgReturnsOnCall[i] = struct {
-		result1 cfclient.Org
+		result1 cloudfoundry.Organization
 		result2 error
 	}{result1, result2}
 }
@@ -299,62 +467,212 @@ func (fake *FakeAPI) AddIsolationSegmentToOrgReturnsOnCall(i int, result1 error)
 	}{result1}
 }
 
-func (fake *FakeAPI) ListOrgsByQuery(query url.Values) ([]cfclient.Org, error) {
-	fake.listOrgsByQueryMutex.Lock()
-	ret, specificReturn := fake.listOrgsByQueryReturnsOnCall[len(fake.listOrgsByQueryArgsForCall)]
-	fake.listOrgsByQueryArgsForCall = append(fake.listOrgsByQueryArgsForCall, struct {
-		query url.Values
+func (fake *FakeAPI) SetOrgDefaultIsolationSegment(orgGUID string, isolationSegmentGUID string) error {
+	fake.setOrgDefaultIsolationSegmentMutex.Lock()
+	ret, specificReturn := fake.setOrgDefaultIsolationSegmentReturnsOnCall[len(fake.setOrgDefaultIsolationSegmentArgsForCall)]
+	fake.setOrgDefaultIsolationSegmentArgsForCall = append(fake.setOrgDefaultIsolationSegmentArgsForCall, struct {
+		orgGUID              string
+		isolationSegmentGUID string
+	}{orgGUID, isolationSegmentGUID})
+	fake.recordInvocation("SetOrgDefaultIsolationSegment", []interface{}{orgGUID, isolationSegmentGUID})
+	fake.setOrgDefaultIsolationSegmentMutex.Unlock()
+	if fake.SetOrgDefaultIsolationSegmentStub != nil {
+		return fake.SetOrgDefaultIsolationSegmentStub(orgGUID, isolationSegmentGUID)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.setOrgDefaultIsolationSegmentReturns.result1
+}
+
+func (fake *FakeAPI) SetOrgDefaultIsolationSegmentCallCount() int {
+	fake.setOrgDefaultIsolationSegmentMutex.RLock()
+	defer fake.setOrgDefaultIsolationSegmentMutex.RUnlock()
+	return len(fake.setOrgDefaultIsolationSegmentArgsForCall)
+}
+
+func (fake *FakeAPI) SetOrgDefaultIsolationSegmentArgsForCall(i int) (string, string) {
+	fake.setOrgDefaultIsolationSegmentMutex.RLock()
+	defer fake.setOrgDefaultIsolationSegmentMutex.RUnlock()
+	return fake.setOrgDefaultIsolationSegmentArgsForCall[i].orgGUID, fake.setOrgDefaultIsolationSegmentArgsForCall[i].isolationSegmentGUID
+}
+
+func (fake *FakeAPI) SetOrgDefaultIsolationSegmentReturns(result1 error) {
+	fake.SetOrgDefaultIsolationSegmentStub = nil
+	fake.setOrgDefaultIsolationSegmentReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) SetOrgDefaultIsolationSegmentReturnsOnCall(i int, result1 error) {
+	fake.SetOrgDefaultIsolationSegmentStub = nil
+	if fake.setOrgDefaultIsolationSegmentReturnsOnCall == nil {
+		fake.setOrgDefaultIsolationSegmentReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setOrgDefaultIsolationSegmentReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) ListOrgs(query cloudfoundry.OrgQuery) ([]cloudfoundry.Organization, error) {
+	fake.listOrgsMutex.Lock()
+	ret, specificReturn := fake.listOrgsReturnsOnCall[len(fake.listOrgsArgsForCall)]
+	fake.listOrgsArgsForCall = append(fake.listOrgsArgsForCall, struct {
+		query cloudfoundry.OrgQuery
 	}{query})
-	fake.recordInvocation("ListOrgsByQuery", []interface{}{query})
-	fake.listOrgsByQueryMutex.Unlock()
-	if fake.ListOrgsByQueryStub != nil {
-		return fake.ListOrgsByQueryStub(query)
+	fake.recordInvocation("ListOrgs", []interface{}{query})
+	fake.listOrgsMutex.Unlock()
+	if fake.ListOrgsStub != nil {
+		return fake.ListOrgsStub(query)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.listOrgsReturns.result1, fake.listOrgsReturns.result2
+}
+
+func (fake *FakeAPI) ListOrgsCallCount() int {
+	fake.listOrgsMutex.RLock()
+	defer fake.listOrgsMutex.RUnlock()
+	return len(fake.listOrgsArgsForCall)
+}
+
+func (fake *FakeAPI) ListOrgsArgsForCall(i int) cloudfoundry.OrgQuery {
+	fake.listOrgsMutex.RLock()
+	defer fake.listOrgsMutex.RUnlock()
+	return fake.listOrgsArgsForCall[i].query
+}
+
+func (fake *FakeAPI) ListOrgsReturns(result1 []cloudfoundry.Organization, result2 error) {
+	fake.ListOrgsStub = nil
+	fake.listOrgsReturns = struct {
+		result1 []cloudfoundry.Organization
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListOrgsReturnsOnCall(i int, result1 []cloudfoundry.Organization, result2 error) {
+	fake.ListOrgsStub = nil
+	if fake.listOrgsReturnsOnCall == nil {
+		fake.listOrgsReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.Organization
+			result2 error
+		})
+	}
+	fake.listOrgsReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.Organization
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) DeleteOrg(guid string, recursive bool, async bool) error {
+	fake.deleteOrgMutex.Lock()
+	ret, specificReturn := fake.deleteOrgReturnsOnCall[len(fake.deleteOrgArgsForCall)]
+	fake.deleteOrgArgsForCall = append(fake.deleteOrgArgsForCall, struct {
+		guid      string
+		recursive bool
+		async     bool
+	}{guid, recursive, async})
+	fake.recordInvocation("DeleteOrg", []interface{}{guid, recursive, async})
+	fake.deleteOrgMutex.Unlock()
+	if fake.DeleteOrgStub != nil {
+		return fake.DeleteOrgStub(guid, recursive, async)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.deleteOrgReturns.result1
+}
+
+func (fake *FakeAPI) DeleteOrgCallCount() int {
+	fake.deleteOrgMutex.RLock()
+	defer fake.deleteOrgMutex.RUnlock()
+	return len(fake.deleteOrgArgsForCall)
+}
+
+func (fake *FakeAPI) DeleteOrgArgsForCall(i int) (string, bool, bool) {
+	fake.deleteOrgMutex.RLock()
+	defer fake.deleteOrgMutex.RUnlock()
+	return fake.deleteOrgArgsForCall[i].guid, fake.deleteOrgArgsForCall[i].recursive, fake.deleteOrgArgsForCall[i].async
+}
+
+func (fake *FakeAPI) DeleteOrgReturns(result1 error) {
+	fake.DeleteOrgStub = nil
+	fake.deleteOrgReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) DeleteOrgReturnsOnCall(i int, result1 error) {
+	fake.DeleteOrgStub = nil
+	if fake.deleteOrgReturnsOnCall == nil {
+		fake.deleteOrgReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteOrgReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) ListOrgManagers(orgGUID string) ([]cloudfoundry.User, error) {
+	fake.listOrgManagersMutex.Lock()
+	ret, specificReturn := fake.listOrgManagersReturnsOnCall[len(fake.listOrgManagersArgsForCall)]
+	fake.listOrgManagersArgsForCall = append(fake.listOrgManagersArgsForCall, struct {
+		orgGUID string
+	}{orgGUID})
+	fake.recordInvocation("ListOrgManagers", []interface{}{orgGUID})
+	fake.listOrgManagersMutex.Unlock()
+	if fake.ListOrgManagersStub != nil {
+		return fake.ListOrgManagersStub(orgGUID)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.listOrgsByQueryReturns.result1, fake.listOrgsByQueryReturns.result2
+	return fake.listOrgManagersReturns.result1, fake.listOrgManagersReturns.result2
 }
 
-func (fake *FakeAPI) ListOrgsByQueryCallCount() int {
-	fake.listOrgsByQueryMutex.RLock()
-	defer fake.listOrgsByQueryMutex.RUnlock()
-	return len(fake.listOrgsByQueryArgsForCall)
+func (fake *FakeAPI) ListOrgManagersCallCount() int {
+	fake.listOrgManagersMutex.RLock()
+	defer fake.listOrgManagersMutex.RUnlock()
+	return len(fake.listOrgManagersArgsForCall)
 }
 
-func (fake *FakeAPI) ListOrgsByQueryArgsForCall(i int) url.Values {
-	fake.listOrgsByQueryMutex.RLock()
-	defer fake.listOrgsByQueryMutex.RUnlock()
-	return fake.listOrgsByQueryArgsForCall[i].query
+func (fake *FakeAPI) ListOrgManagersArgsForCall(i int) string {
+	fake.listOrgManagersMutex.RLock()
+	defer fake.listOrgManagersMutex.RUnlock()
+	return fake.listOrgManagersArgsForCall[i].orgGUID
 }
 
-func (fake *FakeAPI) ListOrgsByQueryReturns(result1 []cfclient.Org, result2 error) {
-	fake.ListOrgsByQueryStub = nil
-	fake.listOrgsByQueryReturns = struct {
-		result1 []cfclient.Org
+func (fake *FakeAPI) ListOrgManagersReturns(result1 []cloudfoundry.User, result2 error) {
+	fake.ListOrgManagersStub = nil
+	fake.listOrgManagersReturns = struct {
+		result1 []cloudfoundry.User
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) ListOrgsByQueryReturnsOnCall(i int, result1 []cfclient.Org, result2 error) {
-	fake.ListOrgsByQueryStub = nil
-	if fake.listOrgsByQueryReturnsOnCall == nil {
-		fake.listOrgsByQueryReturnsOnCall = make(map[int]struct {
-			result1 []cfclient.Org
+func (fake *FakeAPI) ListOrgManagersReturnsOnCall(i int, result1 []cloudfoundry.User, result2 error) {
+	fake.ListOrgManagersStub = nil
+	if fake.listOrgManagersReturnsOnCall == nil {
+		fake.listOrgManagersReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.User
 			result2 error
 		})
 	}
-	fake.listOrgsByQueryReturnsOnCall[i] = struct {
-		result1 []cfclient.Org
+	fake.listOrgManagersReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.User
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) CreateSpace(req cfclient.SpaceRequest) (cfclient.Space, error) {
+func (fake *FakeAPI) CreateSpace(req cloudfoundry.SpaceRequest) (cloudfoundry.Space, error) {
 	fake.createSpaceMutex.Lock()
 	ret, specificReturn := fake.createSpaceReturnsOnCall[len(fake.createSpaceArgsForCall)]
 	fake.createSpaceArgsForCall = append(fake.createSpaceArgsForCall, struct {
-		req cfclient.SpaceRequest
+		req cloudfoundry.SpaceRequest
 	}{req})
 	fake.recordInvocation("CreateSpace", []interface{}{req})
 	fake.createSpaceMutex.Unlock()
@@ -373,139 +691,336 @@ func (fake *FakeAPI) CreateSpaceCallCount() int {
 	return len(fake.createSpaceArgsForCall)
 }
 
-func (fake *FakeAPI) CreateSpaceArgsForCall(i int) cfclient.SpaceRequest {
+func (fake *FakeAPI) CreateSpaceArgsForCall(i int) cloudfoundry.SpaceRequest {
 	fake.createSpaceMutex.RLock()
 	defer fake.createSpaceMutex.RUnlock()
 	return fake.createSpaceArgsForCall[i].req
 }
 
-func (fake *FakeAPI) CreateSpaceReturns(result1 cfclient.Space, result2 error) {
+func (fake *FakeAPI) CreateSpaceReturns(result1 cloudfoundry.Space, result2 error) {
 	fake.CreateSpaceStub = nil
 	fake.createSpaceReturns = struct {
-		result1 cfclient.Space
+		result1 cloudfoundry.Space
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) CreateSpaceReturnsOnCall(i int, result1 cfclient.Space, result2 error) {
+func (fake *FakeAPI) CreateSpaceReturnsOnCall(i int, result1 cloudfoundry.Space, result2 error) {
 	fake.CreateSpaceStub = nil
 	if fake.createSpaceReturnsOnCall == nil {
 		fake.createSpaceReturnsOnCall = make(map[int]struct {
-			result1 cfclient.Space
+			result1 cloudfoundry.Space
 			result2 error
 		})
 	}
 	fake.createSpaceReturnsOnCall[i] = struct {
-		result1 cfclient.Space
+		result1 cloudfoundry.Space
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) AssociateOrgUser(orgGUID string, userGUID string) (cfclient.Org, error) {
-	fake.associateOrgUserMutex.Lock()
-	ret, specificReturn := fake.associateOrgUserReturnsOnCall[len(fake.associateOrgUserArgsForCall)]
-	fake.associateOrgUserArgsForCall = append(fake.associateOrgUserArgsForCall, struct {
-		orgGUID  string
-		userGUID string
-	}{orgGUID, userGUID})
-	fake.recordInvocation("AssociateOrgUser", []interface{}{orgGUID, userGUID})
-	fake.associateOrgUserMutex.Unlock()
-	if fake.AssociateOrgUserStub != nil {
-		return fake.AssociateOrgUserStub(orgGUID, userGUID)
+func (fake *FakeAPI) ListSpaces(query cloudfoundry.SpaceQuery) ([]cloudfoundry.Space, error) {
+	fake.listSpacesMutex.Lock()
+	ret, specificReturn := fake.listSpacesReturnsOnCall[len(fake.listSpacesArgsForCall)]
+	fake.listSpacesArgsForCall = append(fake.listSpacesArgsForCall, struct {
+		query cloudfoundry.SpaceQuery
+	}{query})
+	fake.recordInvocation("ListSpaces", []interface{}{query})
+	fake.listSpacesMutex.Unlock()
+	if fake.ListSpacesStub != nil {
+		return fake.ListSpacesStub(query)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.associateOrgUserReturns.result1, fake.associateOrgUserReturns.result2
+	return fake.listSpacesReturns.result1, fake.listSpacesReturns.result2
 }
 
-func (fake *FakeAPI) AssociateOrgUserCallCount() int {
-	fake.associateOrgUserMutex.RLock()
-	defer fake.associateOrgUserMutex.RUnlock()
-	return len(fake.associateOrgUserArgsForCall)
+func (fake *FakeAPI) ListSpacesCallCount() int {
+	fake.listSpacesMutex.RLock()
+	defer fake.listSpacesMutex.RUnlock()
+	return len(fake.listSpacesArgsForCall)
 }
 
-func (fake *FakeAPI) AssociateOrgUserArgsForCall(i int) (string, string) {
-	fake.associateOrgUserMutex.RLock()
-	defer fake.associateOrgUserMutex.RUnlock()
-	return fake.associateOrgUserArgsForCall[i].orgGUID, fake.associateOrgUserArgsForCall[i].userGUID
+func (fake *FakeAPI) ListSpacesArgsForCall(i int) cloudfoundry.SpaceQuery {
+	fake.listSpacesMutex.RLock()
+	defer fake.listSpacesMutex.RUnlock()
+	return fake.listSpacesArgsForCall[i].query
 }
 
-func (fake *FakeAPI) AssociateOrgUserReturns(result1 cfclient.Org, result2 error) {
-	fake.AssociateOrgUserStub = nil
-	fake.associateOrgUserReturns = struct {
-		result1 cfclient.Org
+func (fake *FakeAPI) ListSpacesReturns(result1 []cloudfoundry.Space, result2 error) {
+	fake.ListSpacesStub = nil
+	fake.listSpacesReturns = struct {
+		result1 []cloudfoundry.Space
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) AssociateOrgUserReturnsOnCall(i int, result1 cfclient.Org, result2 error) {
-	fake.AssociateOrgUserStub = nil
-	if fake.associateOrgUserReturnsOnCall == nil {
-		fake.associateOrgUserReturnsOnCall = make(map[int]struct {
-			result1 cfclient.Org
+func (fake *FakeAPI) ListSpacesReturnsOnCall(i int, result1 []cloudfoundry.Space, result2 error) {
+	fake.ListSpacesStub = nil
+	if fake.listSpacesReturnsOnCall == nil {
+		fake.listSpacesReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.Space
 			result2 error
 		})
 	}
-	fake.associateOrgUserReturnsOnCall[i] = struct {
-		result1 cfclient.Org
+	fake.listSpacesReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.Space
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) AssociateOrgAuditor(orgGUID string, userGUID string) (cfclient.Org, error) {
-	fake.associateOrgAuditorMutex.Lock()
-	ret, specificReturn := fake.associateOrgAuditorReturnsOnCall[len(fake.associateOrgAuditorArgsForCall)]
-	fake.associateOrgAuditorArgsForCall = append(fake.associateOrgAuditorArgsForCall, struct {
-		orgGUID  string
-		userGUID string
-	}{orgGUID, userGUID})
-	fake.recordInvocation("AssociateOrgAuditor", []interface{}{orgGUID, userGUID})
-	fake.associateOrgAuditorMutex.Unlock()
-	if fake.AssociateOrgAuditorStub != nil {
-		return fake.AssociateOrgAuditorStub(orgGUID, userGUID)
+func (fake *FakeAPI) DeleteSpace(guid string, recursive bool, async bool) error {
+	fake.deleteSpaceMutex.Lock()
+	ret, specificReturn := fake.deleteSpaceReturnsOnCall[len(fake.deleteSpaceArgsForCall)]
+	fake.deleteSpaceArgsForCall = append(fake.deleteSpaceArgsForCall, struct {
+		guid      string
+		recursive bool
+		async     bool
+	}{guid, recursive, async})
+	fake.recordInvocation("DeleteSpace", []interface{}{guid, recursive, async})
+	fake.deleteSpaceMutex.Unlock()
+	if fake.DeleteSpaceStub != nil {
+		return fake.DeleteSpaceStub(guid, recursive, async)
 	}
 	if specificReturn {
-		return ret.result1, ret.result2
+		return ret.result1
 	}
-	return fake.associateOrgAuditorReturns.result1, fake.associateOrgAuditorReturns.result2
+	return fake.deleteSpaceReturns.result1
 }
 
-func (fake *FakeAPI) AssociateOrgAuditorCallCount() int {
-	fake.associateOrgAuditorMutex.RLock()
-	defer fake.associateOrgAuditorMutex.RUnlock()
-	return len(fake.associateOrgAuditorArgsForCall)
+func (fake *FakeAPI) DeleteSpaceCallCount() int {
+	fake.deleteSpaceMutex.RLock()
+	defer fake.deleteSpaceMutex.RUnlock()
+	return len(fake.deleteSpaceArgsForCall)
 }
 
-func (fake *FakeAPI) AssociateOrgAuditorArgsForCall(i int) (string, string) {
-	fake.associateOrgAuditorMutex.RLock()
-	defer fake.associateOrgAuditorMutex.RUnlock()
-	return fake.associateOrgAuditorArgsForCall[i].orgGUID, fake.associateOrgAuditorArgsForCall[i].userGUID
+func (fake *FakeAPI) DeleteSpaceArgsForCall(i int) (string, bool, bool) {
+	fake.deleteSpaceMutex.RLock()
+	defer fake.deleteSpaceMutex.RUnlock()
+	return fake.deleteSpaceArgsForCall[i].guid, fake.deleteSpaceArgsForCall[i].recursive, fake.deleteSpaceArgsForCall[i].async
 }
 
-func (fake *FakeAPI) AssociateOrgAuditorReturns(result1 cfclient.Org, result2 error) {
-	fake.AssociateOrgAuditorStub = nil
-	fake.associateOrgAuditorReturns = struct {
-		result1 cfclient.Org
-		result2 error
-	}{result1, result2}
+func (fake *FakeAPI) DeleteSpaceReturns(result1 error) {
+	fake.DeleteSpaceStub = nil
+	fake.deleteSpaceReturns = struct {
+		result1 error
+	}{result1}
 }
 
-func (fake *FakeAPI) AssociateOrgAuditorReturnsOnCall(i int, result1 cfclient.Org, result2 error) {
-	fake.AssociateOrgAuditorStub = nil
-	if fake.associateOrgAuditorReturnsOnCall == nil {
-		fake.associateOrgAuditorReturnsOnCall = make(map[int]struct {
-			result1 cfclient.Org
+func (fake *FakeAPI) DeleteSpaceReturnsOnCall(i int, result1 error) {
+	fake.DeleteSpaceStub = nil
+	if fake.deleteSpaceReturnsOnCall == nil {
+		fake.deleteSpaceReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteSpaceReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) CreateSpaceQuota(req cloudfoundry.SpaceQuotaRequest) (cloudfoundry.SpaceQuota, error) {
+	fake.createSpaceQuotaMutex.Lock()
+	ret, specificReturn := fake.createSpaceQuotaReturnsOnCall[len(fake.createSpaceQuotaArgsForCall)]
+	fake.createSpaceQuotaArgsForCall = append(fake.createSpaceQuotaArgsForCall, struct {
+		req cloudfoundry.SpaceQuotaRequest
+	}{req})
+	fake.recordInvocation("CreateSpaceQuota", []interface{}{req})
+	fake.createSpaceQuotaMutex.Unlock()
+	if fake.CreateSpaceQuotaStub != nil {
+		return fake.CreateSpaceQuotaStub(req)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.createSpaceQuotaReturns.result1, fake.createSpaceQuotaReturns.result2
+}
+
+func (fake *FakeAPI) CreateSpaceQuotaCallCount() int {
+	fake.createSpaceQuotaMutex.RLock()
+	defer fake.createSpaceQuotaMutex.RUnlock()
+	return len(fake.createSpaceQuotaArgsForCall)
+}
+
+func (fake *FakeAPI) CreateSpaceQuotaArgsForCall(i int) cloudfoundry.SpaceQuotaRequest {
+	fake.createSpaceQuotaMutex.RLock()
+	defer fake.createSpaceQuotaMutex.RUnlock()
+	return fake.createSpaceQuotaArgsForCall[i].req
+}
+
+func (fake *FakeAPI) CreateSpaceQuotaReturns(result1 cloudfoundry.SpaceQuota, result2 error) {
+	fake.CreateSpaceQuotaStub = nil
+	fake.createSpaceQuotaReturns = struct {
+		result1 cloudfoundry.SpaceQuota
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) CreateSpaceQuotaReturnsOnCall(i int, result1 cloudfoundry.SpaceQuota, result2 error) {
+	fake.CreateSpaceQuotaStub = nil
+	if fake.createSpaceQuotaReturnsOnCall == nil {
+		fake.createSpaceQuotaReturnsOnCall = make(map[int]struct {
+			result1 cloudfoundry.SpaceQuota
 			result2 error
 		})
 	}
-	fake.associateOrgAuditorReturnsOnCall[i] = struct {
-		result1 cfclient.Org
+	fake.createSpaceQuotaReturnsOnCall[i] = struct {
+		result1 cloudfoundry.SpaceQuota
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) AssociateOrgManager(orgGUID string, userGUID string) (cfclient.Org, error) {
+func (fake *FakeAPI) ListSpaceQuotas(query cloudfoundry.SpaceQuotaQuery) ([]cloudfoundry.SpaceQuota, error) {
+	fake.listSpaceQuotasMutex.Lock()
+	ret, specificReturn := fake.listSpaceQuotasReturnsOnCall[len(fake.listSpaceQuotasArgsForCall)]
+	fake.listSpaceQuotasArgsForCall = append(fake.listSpaceQuotasArgsForCall, struct {
+		query cloudfoundry.SpaceQuotaQuery
+	}{query})
+	fake.recordInvocation("ListSpaceQuotas", []interface{}{query})
+	fake.listSpaceQuotasMutex.Unlock()
+	if fake.ListSpaceQuotasStub != nil {
+		return fake.ListSpaceQuotasStub(query)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.listSpaceQuotasReturns.result1, fake.listSpaceQuotasReturns.result2
+}
+
+func (fake *FakeAPI) ListSpaceQuotasCallCount() int {
+	fake.listSpaceQuotasMutex.RLock()
+	defer fake.listSpaceQuotasMutex.RUnlock()
+	return len(fake.listSpaceQuotasArgsForCall)
+}
+
+func (fake *FakeAPI) ListSpaceQuotasArgsForCall(i int) cloudfoundry.SpaceQuotaQuery {
+	fake.listSpaceQuotasMutex.RLock()
+	defer fake.listSpaceQuotasMutex.RUnlock()
+	return fake.listSpaceQuotasArgsForCall[i].query
+}
+
+func (fake *FakeAPI) ListSpaceQuotasReturns(result1 []cloudfoundry.SpaceQuota, result2 error) {
+	fake.ListSpaceQuotasStub = nil
+	fake.listSpaceQuotasReturns = struct {
+		result1 []cloudfoundry.SpaceQuota
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListSpaceQuotasReturnsOnCall(i int, result1 []cloudfoundry.SpaceQuota, result2 error) {
+	fake.ListSpaceQuotasStub = nil
+	if fake.listSpaceQuotasReturnsOnCall == nil {
+		fake.listSpaceQuotasReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.SpaceQuota
+			result2 error
+		})
+	}
+	fake.listSpaceQuotasReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.SpaceQuota
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) AssociateOrgUser(orgGUID string, userGUID string) error {
+	fake.associateOrgUserMutex.Lock()
+	ret, specificReturn := fake.associateOrgUserReturnsOnCall[len(fake.associateOrgUserArgsForCall)]
+	fake.associateOrgUserArgsForCall = append(fake.associateOrgUserArgsForCall, struct {
+		orgGUID  string
+		userGUID string
+	}{orgGUID, userGUID})
+	fake.recordInvocation("AssociateOrgUser", []interface{}{orgGUID, userGUID})
+	fake.associateOrgUserMutex.Unlock()
+	if fake.AssociateOrgUserStub != nil {
+		return fake.AssociateOrgUserStub(orgGUID, userGUID)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.associateOrgUserReturns.result1
+}
+
+func (fake *FakeAPI) AssociateOrgUserCallCount() int {
+	fake.associateOrgUserMutex.RLock()
+	defer fake.associateOrgUserMutex.RUnlock()
+	return len(fake.associateOrgUserArgsForCall)
+}
+
+func (fake *FakeAPI) AssociateOrgUserArgsForCall(i int) (string, string) {
+	fake.associateOrgUserMutex.RLock()
+	defer fake.associateOrgUserMutex.RUnlock()
+	return fake.associateOrgUserArgsForCall[i].orgGUID, fake.associateOrgUserArgsForCall[i].userGUID
+}
+
+func (fake *FakeAPI) AssociateOrgUserReturns(result1 error) {
+	fake.AssociateOrgUserStub = nil
+	fake.associateOrgUserReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) AssociateOrgUserReturnsOnCall(i int, result1 error) {
+	fake.AssociateOrgUserStub = nil
+	if fake.associateOrgUserReturnsOnCall == nil {
+		fake.associateOrgUserReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.associateOrgUserReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) AssociateOrgAuditor(orgGUID string, userGUID string) error {
+	fake.associateOrgAuditorMutex.Lock()
+	ret, specificReturn := fake.associateOrgAuditorReturnsOnCall[len(fake.associateOrgAuditorArgsForCall)]
+	fake.associateOrgAuditorArgsForCall = append(fake.associateOrgAuditorArgsForCall, struct {
+		orgGUID  string
+		userGUID string
+	}{orgGUID, userGUID})
+	fake.recordInvocation("AssociateOrgAuditor", []interface{}{orgGUID, userGUID})
+	fake.associateOrgAuditorMutex.Unlock()
+	if fake.AssociateOrgAuditorStub != nil {
+		return fake.AssociateOrgAuditorStub(orgGUID, userGUID)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.associateOrgAuditorReturns.result1
+}
+
+func (fake *FakeAPI) AssociateOrgAuditorCallCount() int {
+	fake.associateOrgAuditorMutex.RLock()
+	defer fake.associateOrgAuditorMutex.RUnlock()
+	return len(fake.associateOrgAuditorArgsForCall)
+}
+
+func (fake *FakeAPI) AssociateOrgAuditorArgsForCall(i int) (string, string) {
+	fake.associateOrgAuditorMutex.RLock()
+	defer fake.associateOrgAuditorMutex.RUnlock()
+	return fake.associateOrgAuditorArgsForCall[i].orgGUID, fake.associateOrgAuditorArgsForCall[i].userGUID
+}
+
+func (fake *FakeAPI) AssociateOrgAuditorReturns(result1 error) {
+	fake.AssociateOrgAuditorStub = nil
+	fake.associateOrgAuditorReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) AssociateOrgAuditorReturnsOnCall(i int, result1 error) {
+	fake.AssociateOrgAuditorStub = nil
+	if fake.associateOrgAuditorReturnsOnCall == nil {
+		fake.associateOrgAuditorReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.associateOrgAuditorReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) AssociateOrgManager(orgGUID string, userGUID string) error {
 	fake.associateOrgManagerMutex.Lock()
 	ret, specificReturn := fake.associateOrgManagerReturnsOnCall[len(fake.associateOrgManagerArgsForCall)]
 	fake.associateOrgManagerArgsForCall = append(fake.associateOrgManagerArgsForCall, struct {
@@ -518,9 +1033,9 @@ func (fake *FakeAPI) AssociateOrgManager(orgGUID string, userGUID string) (cfcli
 		return fake.AssociateOrgManagerStub(orgGUID, userGUID)
 	}
 	if specificReturn {
-		return ret.result1, ret.result2
+		return ret.result1
 	}
-	return fake.associateOrgManagerReturns.result1, fake.associateOrgManagerReturns.result2
+	return fake.associateOrgManagerReturns.result1
 }
 
 func (fake *FakeAPI) AssociateOrgManagerCallCount() int {
@@ -535,29 +1050,26 @@ func (fake *FakeAPI) AssociateOrgManagerArgsForCall(i int) (string, string) {
 	return fake.associateOrgManagerArgsForCall[i].orgGUID, fake.associateOrgManagerArgsForCall[i].userGUID
 }
 
-func (fake *FakeAPI) AssociateOrgManagerReturns(result1 cfclient.Org, result2 error) {
+func (fake *FakeAPI) AssociateOrgManagerReturns(result1 error) {
 	fake.AssociateOrgManagerStub = nil
 	fake.associateOrgManagerReturns = struct {
-		result1 cfclient.Org
-		result2 error
-	}{result1, result2}
+		result1 error
+	}{result1}
 }
 
-func (fake *FakeAPI) AssociateOrgManagerReturnsOnCall(i int, result1 cfclient.Org, result2 error) {
+func (fake *FakeAPI) AssociateOrgManagerReturnsOnCall(i int, result1 error) {
 	fake.AssociateOrgManagerStub = nil
 	if fake.associateOrgManagerReturnsOnCall == nil {
 		fake.associateOrgManagerReturnsOnCall = make(map[int]struct {
-			result1 cfclient.Org
-			result2 error
+			result1 error
 		})
 	}
 	fake.associateOrgManagerReturnsOnCall[i] = struct {
-		result1 cfclient.Org
-		result2 error
-	}{result1, result2}
+		result1 error
+	}{result1}
 }
 
-func (fake *FakeAPI) GetOrgQuotaByName(name string) (cfclient.OrgQuota, error) {
+func (fake *FakeAPI) GetOrgQuotaByName(name string) (cloudfoundry.Quota, error) {
 	fake.getOrgQuotaByNameMutex.Lock()
 	ret, specificReturn := fake.getOrgQuotaByNameReturnsOnCall[len(fake.getOrgQuotaByNameArgsForCall)]
 	fake.getOrgQuotaByNameArgsForCall = append(fake.getOrgQuotaByNameArgsForCall, struct {
@@ -586,92 +1098,705 @@ func (fake *FakeAPI) GetOrgQuotaByNameArgsForCall(i int) string {
 	return fake.getOrgQuotaByNameArgsForCall[i].name
 }
 
-func (fake *FakeAPI) GetOrgQuotaByNameReturns(result1 cfclient.OrgQuota, result2 error) {
+func (fake *FakeAPI) GetOrgQuotaByNameReturns(result1 cloudfoundry.Quota, result2 error) {
 	fake.GetOrgQuotaByNameStub = nil
 	fake.getOrgQuotaByNameReturns = struct {
-		result1 cfclient.OrgQuota
+		result1 cloudfoundry.Quota
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) GetOrgQuotaByNameReturnsOnCall(i int, result1 cfclient.OrgQuota, result2 error) {
+func (fake *FakeAPI) GetOrgQuotaByNameReturnsOnCall(i int, result1 cloudfoundry.Quota, result2 error) {
 	fake.GetOrgQuotaByNameStub = nil
 	if fake.getOrgQuotaByNameReturnsOnCall == nil {
 		fake.getOrgQuotaByNameReturnsOnCall = make(map[int]struct {
-			result1 cfclient.OrgQuota
+			result1 cloudfoundry.Quota
 			result2 error
 		})
 	}
 	fake.getOrgQuotaByNameReturnsOnCall[i] = struct {
-		result1 cfclient.OrgQuota
+		result1 cloudfoundry.Quota
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListIsolationSegmentsByName(name string) ([]cloudfoundry.IsolationSegment, error) {
+	fake.listIsolationSegmentsByNameMutex.Lock()
+	ret, specificReturn := fake.listIsolationSegmentsByNameReturnsOnCall[len(fake.listIsolationSegmentsByNameArgsForCall)]
+	fake.listIsolationSegmentsByNameArgsForCall = append(fake.listIsolationSegmentsByNameArgsForCall, struct {
+		name string
+	}{name})
+	fake.recordInvocation("ListIsolationSegmentsByName", []interface{}{name})
+	fake.listIsolationSegmentsByNameMutex.Unlock()
+	if fake.ListIsolationSegmentsByNameStub != nil {
+		return fake.ListIsolationSegmentsByNameStub(name)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.listIsolationSegmentsByNameReturns.result1, fake.listIsolationSegmentsByNameReturns.result2
+}
+
+func (fake *FakeAPI) ListIsolationSegmentsByNameCallCount() int {
+	fake.listIsolationSegmentsByNameMutex.RLock()
+	defer fake.listIsolationSegmentsByNameMutex.RUnlock()
+	return len(fake.listIsolationSegmentsByNameArgsForCall)
+}
+
+func (fake *FakeAPI) ListIsolationSegmentsByNameArgsForCall(i int) string {
+	fake.listIsolationSegmentsByNameMutex.RLock()
+	defer fake.listIsolationSegmentsByNameMutex.RUnlock()
+	return fake.listIsolationSegmentsByNameArgsForCall[i].name
+}
+
+func (fake *FakeAPI) ListIsolationSegmentsByNameReturns(result1 []cloudfoundry.IsolationSegment, result2 error) {
+	fake.ListIsolationSegmentsByNameStub = nil
+	fake.listIsolationSegmentsByNameReturns = struct {
+		result1 []cloudfoundry.IsolationSegment
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListIsolationSegmentsByNameReturnsOnCall(i int, result1 []cloudfoundry.IsolationSegment, result2 error) {
+	fake.ListIsolationSegmentsByNameStub = nil
+	if fake.listIsolationSegmentsByNameReturnsOnCall == nil {
+		fake.listIsolationSegmentsByNameReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.IsolationSegment
+			result2 error
+		})
+	}
+	fake.listIsolationSegmentsByNameReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.IsolationSegment
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) ListIsolationSegmentsByQuery(query url.Values) ([]cfclient.IsolationSegment, error) {
-	fake.listIsolationSegmentsByQueryMutex.Lock()
-	ret, specificReturn := fake.listIsolationSegmentsByQueryReturnsOnCall[len(fake.listIsolationSegmentsByQueryArgsForCall)]
-	fake.listIsolationSegmentsByQueryArgsForCall = append(fake.listIsolationSegmentsByQueryArgsForCall, struct {
-		query url.Values
+func (fake *FakeAPI) ListSecurityGroupsByName(name string) ([]cloudfoundry.SecurityGroup, error) {
+	fake.listSecurityGroupsByNameMutex.Lock()
+	ret, specificReturn := fake.listSecurityGroupsByNameReturnsOnCall[len(fake.listSecurityGroupsByNameArgsForCall)]
+	fake.listSecurityGroupsByNameArgsForCall = append(fake.listSecurityGroupsByNameArgsForCall, struct {
+		name string
+	}{name})
+	fake.recordInvocation("ListSecurityGroupsByName", []interface{}{name})
+	fake.listSecurityGroupsByNameMutex.Unlock()
+	if fake.ListSecurityGroupsByNameStub != nil {
+		return fake.ListSecurityGroupsByNameStub(name)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.listSecurityGroupsByNameReturns.result1, fake.listSecurityGroupsByNameReturns.result2
+}
+
+func (fake *FakeAPI) ListSecurityGroupsByNameCallCount() int {
+	fake.listSecurityGroupsByNameMutex.RLock()
+	defer fake.listSecurityGroupsByNameMutex.RUnlock()
+	return len(fake.listSecurityGroupsByNameArgsForCall)
+}
+
+func (fake *FakeAPI) ListSecurityGroupsByNameArgsForCall(i int) string {
+	fake.listSecurityGroupsByNameMutex.RLock()
+	defer fake.listSecurityGroupsByNameMutex.RUnlock()
+	return fake.listSecurityGroupsByNameArgsForCall[i].name
+}
+
+func (fake *FakeAPI) ListSecurityGroupsByNameReturns(result1 []cloudfoundry.SecurityGroup, result2 error) {
+	fake.ListSecurityGroupsByNameStub = nil
+	fake.listSecurityGroupsByNameReturns = struct {
+		result1 []cloudfoundry.SecurityGroup
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListSecurityGroupsByNameReturnsOnCall(i int, result1 []cloudfoundry.SecurityGroup, result2 error) {
+	fake.ListSecurityGroupsByNameStub = nil
+	if fake.listSecurityGroupsByNameReturnsOnCall == nil {
+		fake.listSecurityGroupsByNameReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.SecurityGroup
+			result2 error
+		})
+	}
+	fake.listSecurityGroupsByNameReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.SecurityGroup
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) BindSecGroup(secGUID string, spaceGUID string) error {
+	fake.bindSecGroupMutex.Lock()
+	ret, specificReturn := fake.bindSecGroupReturnsOnCall[len(fake.bindSecGroupArgsForCall)]
+	fake.bindSecGroupArgsForCall = append(fake.bindSecGroupArgsForCall, struct {
+		secGUID   string
+		spaceGUID string
+	}{secGUID, spaceGUID})
+	fake.recordInvocation("BindSecGroup", []interface{}{secGUID, spaceGUID})
+	fake.bindSecGroupMutex.Unlock()
+	if fake.BindSecGroupStub != nil {
+		return fake.BindSecGroupStub(secGUID, spaceGUID)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.bindSecGroupReturns.result1
+}
+
+func (fake *FakeAPI) BindSecGroupCallCount() int {
+	fake.bindSecGroupMutex.RLock()
+	defer fake.bindSecGroupMutex.RUnlock()
+	return len(fake.bindSecGroupArgsForCall)
+}
+
+func (fake *FakeAPI) BindSecGroupArgsForCall(i int) (string, string) {
+	fake.bindSecGroupMutex.RLock()
+	defer fake.bindSecGroupMutex.RUnlock()
+	return fake.bindSecGroupArgsForCall[i].secGUID, fake.bindSecGroupArgsForCall[i].spaceGUID
+}
+
+func (fake *FakeAPI) BindSecGroupReturns(result1 error) {
+	fake.BindSecGroupStub = nil
+	fake.bindSecGroupReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) BindSecGroupReturnsOnCall(i int, result1 error) {
+	fake.BindSecGroupStub = nil
+	if fake.bindSecGroupReturnsOnCall == nil {
+		fake.bindSecGroupReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.bindSecGroupReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) BindStagingSecGroupToSpace(secGUID string, spaceGUID string) error {
+	fake.bindStagingSecGroupToSpaceMutex.Lock()
+	ret, specificReturn := fake.bindStagingSecGroupToSpaceReturnsOnCall[len(fake.bindStagingSecGroupToSpaceArgsForCall)]
+	fake.bindStagingSecGroupToSpaceArgsForCall = append(fake.bindStagingSecGroupToSpaceArgsForCall, struct {
+		secGUID   string
+		spaceGUID string
+	}{secGUID, spaceGUID})
+	fake.recordInvocation("BindStagingSecGroupToSpace", []interface{}{secGUID, spaceGUID})
+	fake.bindStagingSecGroupToSpaceMutex.Unlock()
+	if fake.BindStagingSecGroupToSpaceStub != nil {
+		return fake.BindStagingSecGroupToSpaceStub(secGUID, spaceGUID)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.bindStagingSecGroupToSpaceReturns.result1
+}
+
+func (fake *FakeAPI) BindStagingSecGroupToSpaceCallCount() int {
+	fake.bindStagingSecGroupToSpaceMutex.RLock()
+	defer fake.bindStagingSecGroupToSpaceMutex.RUnlock()
+	return len(fake.bindStagingSecGroupToSpaceArgsForCall)
+}
+
+func (fake *FakeAPI) BindStagingSecGroupToSpaceArgsForCall(i int) (string, string) {
+	fake.bindStagingSecGroupToSpaceMutex.RLock()
+	defer fake.bindStagingSecGroupToSpaceMutex.RUnlock()
+	return fake.bindStagingSecGroupToSpaceArgsForCall[i].secGUID, fake.bindStagingSecGroupToSpaceArgsForCall[i].spaceGUID
+}
+
+func (fake *FakeAPI) BindStagingSecGroupToSpaceReturns(result1 error) {
+	fake.BindStagingSecGroupToSpaceStub = nil
+	fake.bindStagingSecGroupToSpaceReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) BindStagingSecGroupToSpaceReturnsOnCall(i int, result1 error) {
+	fake.BindStagingSecGroupToSpaceStub = nil
+	if fake.bindStagingSecGroupToSpaceReturnsOnCall == nil {
+		fake.bindStagingSecGroupToSpaceReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.bindStagingSecGroupToSpaceReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) ListApps(query cloudfoundry.AppQuery) ([]cloudfoundry.App, error) {
+	fake.listAppsMutex.Lock()
+	ret, specificReturn := fake.listAppsReturnsOnCall[len(fake.listAppsArgsForCall)]
+	fake.listAppsArgsForCall = append(fake.listAppsArgsForCall, struct {
+		query cloudfoundry.AppQuery
 	}{query})
-	fake.recordInvocation("ListIsolationSegmentsByQuery", []interface{}{query})
-	fake.listIsolationSegmentsByQueryMutex.Unlock()
-	if fake.ListIsolationSegmentsByQueryStub != nil {
-		return fake.ListIsolationSegmentsByQueryStub(query)
+	fake.recordInvocation("ListApps", []interface{}{query})
+	fake.listAppsMutex.Unlock()
+	if fake.ListAppsStub != nil {
+		return fake.ListAppsStub(query)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.listAppsReturns.result1, fake.listAppsReturns.result2
+}
+
+func (fake *FakeAPI) ListAppsCallCount() int {
+	fake.listAppsMutex.RLock()
+	defer fake.listAppsMutex.RUnlock()
+	return len(fake.listAppsArgsForCall)
+}
+
+func (fake *FakeAPI) ListAppsArgsForCall(i int) cloudfoundry.AppQuery {
+	fake.listAppsMutex.RLock()
+	defer fake.listAppsMutex.RUnlock()
+	return fake.listAppsArgsForCall[i].query
+}
+
+func (fake *FakeAPI) ListAppsReturns(result1 []cloudfoundry.App, result2 error) {
+	fake.ListAppsStub = nil
+	fake.listAppsReturns = struct {
+		result1 []cloudfoundry.App
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListAppsReturnsOnCall(i int, result1 []cloudfoundry.App, result2 error) {
+	fake.ListAppsStub = nil
+	if fake.listAppsReturnsOnCall == nil {
+		fake.listAppsReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.App
+			result2 error
+		})
+	}
+	fake.listAppsReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.App
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) DeleteApp(guid string) error {
+	fake.deleteAppMutex.Lock()
+	ret, specificReturn := fake.deleteAppReturnsOnCall[len(fake.deleteAppArgsForCall)]
+	fake.deleteAppArgsForCall = append(fake.deleteAppArgsForCall, struct {
+		guid string
+	}{guid})
+	fake.recordInvocation("DeleteApp", []interface{}{guid})
+	fake.deleteAppMutex.Unlock()
+	if fake.DeleteAppStub != nil {
+		return fake.DeleteAppStub(guid)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.deleteAppReturns.result1
+}
+
+func (fake *FakeAPI) DeleteAppCallCount() int {
+	fake.deleteAppMutex.RLock()
+	defer fake.deleteAppMutex.RUnlock()
+	return len(fake.deleteAppArgsForCall)
+}
+
+func (fake *FakeAPI) DeleteAppArgsForCall(i int) string {
+	fake.deleteAppMutex.RLock()
+	defer fake.deleteAppMutex.RUnlock()
+	return fake.deleteAppArgsForCall[i].guid
+}
+
+func (fake *FakeAPI) DeleteAppReturns(result1 error) {
+	fake.DeleteAppStub = nil
+	fake.deleteAppReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) DeleteAppReturnsOnCall(i int, result1 error) {
+	fake.DeleteAppStub = nil
+	if fake.deleteAppReturnsOnCall == nil {
+		fake.deleteAppReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteAppReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) ListServicesByLabel(label string) ([]cloudfoundry.Service, error) {
+	fake.listServicesByLabelMutex.Lock()
+	ret, specificReturn := fake.listServicesByLabelReturnsOnCall[len(fake.listServicesByLabelArgsForCall)]
+	fake.listServicesByLabelArgsForCall = append(fake.listServicesByLabelArgsForCall, struct {
+		label string
+	}{label})
+	fake.recordInvocation("ListServicesByLabel", []interface{}{label})
+	fake.listServicesByLabelMutex.Unlock()
+	if fake.ListServicesByLabelStub != nil {
+		return fake.ListServicesByLabelStub(label)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.listIsolationSegmentsByQueryReturns.result1, fake.listIsolationSegmentsByQueryReturns.result2
+	return fake.listServicesByLabelReturns.result1, fake.listServicesByLabelReturns.result2
 }
 
-func (fake *FakeAPI) ListIsolationSegmentsByQueryCallCount() int {
-	fake.listIsolationSegmentsByQueryMutex.RLock()
-	defer fake.listIsolationSegmentsByQueryMutex.RUnlock()
-	return len(fake.listIsolationSegmentsByQueryArgsForCall)
+func (fake *FakeAPI) ListServicesByLabelCallCount() int {
+	fake.listServicesByLabelMutex.RLock()
+	defer fake.listServicesByLabelMutex.RUnlock()
+	return len(fake.listServicesByLabelArgsForCall)
 }
 
-func (fake *FakeAPI) ListIsolationSegmentsByQueryArgsForCall(i int) url.Values {
-	fake.listIsolationSegmentsByQueryMutex.RLock()
-	defer fake.listIsolationSegmentsByQueryMutex.RUnlock()
-	return fake.listIsolationSegmentsByQueryArgsForCall[i].query
+func (fake *FakeAPI) ListServicesByLabelArgsForCall(i int) string {
+	fake.listServicesByLabelMutex.RLock()
+	defer fake.listServicesByLabelMutex.RUnlock()
+	return fake.listServicesByLabelArgsForCall[i].label
 }
 
-func (fake *FakeAPI) ListIsolationSegmentsByQueryReturns(result1 []cfclient.IsolationSegment, result2 error) {
-	fake.ListIsolationSegmentsByQueryStub = nil
-	fake.listIsolationSegmentsByQueryReturns = struct {
-		result1 []cfclient.IsolationSegment
+func (fake *FakeAPI) ListServicesByLabelReturns(result1 []cloudfoundry.Service, result2 error) {
+	fake.ListServicesByLabelStub = nil
+	fake.listServicesByLabelReturns = struct {
+		result1 []cloudfoundry.Service
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAPI) ListIsolationSegmentsByQueryReturnsOnCall(i int, result1 []cfclient.IsolationSegment, result2 error) {
-	fake.ListIsolationSegmentsByQueryStub = nil
-	if fake.listIsolationSegmentsByQueryReturnsOnCall == nil {
-		fake.listIsolationSegmentsByQueryReturnsOnCall = make(map[int]struct {
-			result1 []cfclient.IsolationSegment
+func (fake *FakeAPI) ListServicesByLabelReturnsOnCall(i int, result1 []cloudfoundry.Service, result2 error) {
+	fake.ListServicesByLabelStub = nil
+	if fake.listServicesByLabelReturnsOnCall == nil {
+		fake.listServicesByLabelReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.Service
 			result2 error
 		})
 	}
-	fake.listIsolationSegmentsByQueryReturnsOnCall[i] = struct {
-		result1 []cfclient.IsolationSegment
+	fake.listServicesByLabelReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.Service
 		result2 error
 	}{result1, result2}
 }
 
+func (fake *FakeAPI) ListServicePlansForService(serviceGUID string) ([]cloudfoundry.ServicePlan, error) {
+	fake.listServicePlansForServiceMutex.Lock()
+	ret, specificReturn := fake.listServicePlansForServiceReturnsOnCall[len(fake.listServicePlansForServiceArgsForCall)]
+	fake.listServicePlansForServiceArgsForCall = append(fake.listServicePlansForServiceArgsForCall, struct {
+		serviceGUID string
+	}{serviceGUID})
+	fake.recordInvocation("ListServicePlansForService", []interface{}{serviceGUID})
+	fake.listServicePlansForServiceMutex.Unlock()
+	if fake.ListServicePlansForServiceStub != nil {
+		return fake.ListServicePlansForServiceStub(serviceGUID)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.listServicePlansForServiceReturns.result1, fake.listServicePlansForServiceReturns.result2
+}
+
+func (fake *FakeAPI) ListServicePlansForServiceCallCount() int {
+	fake.listServicePlansForServiceMutex.RLock()
+	defer fake.listServicePlansForServiceMutex.RUnlock()
+	return len(fake.listServicePlansForServiceArgsForCall)
+}
+
+func (fake *FakeAPI) ListServicePlansForServiceArgsForCall(i int) string {
+	fake.listServicePlansForServiceMutex.RLock()
+	defer fake.listServicePlansForServiceMutex.RUnlock()
+	return fake.listServicePlansForServiceArgsForCall[i].serviceGUID
+}
+
+func (fake *FakeAPI) ListServicePlansForServiceReturns(result1 []cloudfoundry.ServicePlan, result2 error) {
+	fake.ListServicePlansForServiceStub = nil
+	fake.listServicePlansForServiceReturns = struct {
+		result1 []cloudfoundry.ServicePlan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListServicePlansForServiceReturnsOnCall(i int, result1 []cloudfoundry.ServicePlan, result2 error) {
+	fake.ListServicePlansForServiceStub = nil
+	if fake.listServicePlansForServiceReturnsOnCall == nil {
+		fake.listServicePlansForServiceReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.ServicePlan
+			result2 error
+		})
+	}
+	fake.listServicePlansForServiceReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.ServicePlan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListServiceInstances(query cloudfoundry.ServiceInstanceQuery) ([]cloudfoundry.ServiceInstance, error) {
+	fake.listServiceInstancesMutex.Lock()
+	ret, specificReturn := fake.listServiceInstancesReturnsOnCall[len(fake.listServiceInstancesArgsForCall)]
+	fake.listServiceInstancesArgsForCall = append(fake.listServiceInstancesArgsForCall, struct {
+		query cloudfoundry.ServiceInstanceQuery
+	}{query})
+	fake.recordInvocation("ListServiceInstances", []interface{}{query})
+	fake.listServiceInstancesMutex.Unlock()
+	if fake.ListServiceInstancesStub != nil {
+		return fake.ListServiceInstancesStub(query)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.listServiceInstancesReturns.result1, fake.listServiceInstancesReturns.result2
+}
+
+func (fake *FakeAPI) ListServiceInstancesCallCount() int {
+	fake.listServiceInstancesMutex.RLock()
+	defer fake.listServiceInstancesMutex.RUnlock()
+	return len(fake.listServiceInstancesArgsForCall)
+}
+
+func (fake *FakeAPI) ListServiceInstancesArgsForCall(i int) cloudfoundry.ServiceInstanceQuery {
+	fake.listServiceInstancesMutex.RLock()
+	defer fake.listServiceInstancesMutex.RUnlock()
+	return fake.listServiceInstancesArgsForCall[i].query
+}
+
+func (fake *FakeAPI) ListServiceInstancesReturns(result1 []cloudfoundry.ServiceInstance, result2 error) {
+	fake.ListServiceInstancesStub = nil
+	fake.listServiceInstancesReturns = struct {
+		result1 []cloudfoundry.ServiceInstance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListServiceInstancesReturnsOnCall(i int, result1 []cloudfoundry.ServiceInstance, result2 error) {
+	fake.ListServiceInstancesStub = nil
+	if fake.listServiceInstancesReturnsOnCall == nil {
+		fake.listServiceInstancesReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.ServiceInstance
+			result2 error
+		})
+	}
+	fake.listServiceInstancesReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.ServiceInstance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) CreateServiceInstance(req cloudfoundry.ServiceInstanceRequest) (cloudfoundry.ServiceInstance, error) {
+	fake.createServiceInstanceMutex.Lock()
+	ret, specificReturn := fake.createServiceInstanceReturnsOnCall[len(fake.createServiceInstanceArgsForCall)]
+	fake.createServiceInstanceArgsForCall = append(fake.createServiceInstanceArgsForCall, struct {
+		req cloudfoundry.ServiceInstanceRequest
+	}{req})
+	fake.recordInvocation("CreateServiceInstance", []interface{}{req})
+	fake.createServiceInstanceMutex.Unlock()
+	if fake.CreateServiceInstanceStub != nil {
+		return fake.CreateServiceInstanceStub(req)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.createServiceInstanceReturns.result1, fake.createServiceInstanceReturns.result2
+}
+
+func (fake *FakeAPI) CreateServiceInstanceCallCount() int {
+	fake.createServiceInstanceMutex.RLock()
+	defer fake.createServiceInstanceMutex.RUnlock()
+	return len(fake.createServiceInstanceArgsForCall)
+}
+
+func (fake *FakeAPI) CreateServiceInstanceArgsForCall(i int) cloudfoundry.ServiceInstanceRequest {
+	fake.createServiceInstanceMutex.RLock()
+	defer fake.createServiceInstanceMutex.RUnlock()
+	return fake.createServiceInstanceArgsForCall[i].req
+}
+
+func (fake *FakeAPI) CreateServiceInstanceReturns(result1 cloudfoundry.ServiceInstance, result2 error) {
+	fake.CreateServiceInstanceStub = nil
+	fake.createServiceInstanceReturns = struct {
+		result1 cloudfoundry.ServiceInstance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) CreateServiceInstanceReturnsOnCall(i int, result1 cloudfoundry.ServiceInstance, result2 error) {
+	fake.CreateServiceInstanceStub = nil
+	if fake.createServiceInstanceReturnsOnCall == nil {
+		fake.createServiceInstanceReturnsOnCall = make(map[int]struct {
+			result1 cloudfoundry.ServiceInstance
+			result2 error
+		})
+	}
+	fake.createServiceInstanceReturnsOnCall[i] = struct {
+		result1 cloudfoundry.ServiceInstance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) DeleteServiceInstance(guid string, recursive bool, async bool) error {
+	fake.deleteServiceInstanceMutex.Lock()
+	ret, specificReturn := fake.deleteServiceInstanceReturnsOnCall[len(fake.deleteServiceInstanceArgsForCall)]
+	fake.deleteServiceInstanceArgsForCall = append(fake.deleteServiceInstanceArgsForCall, struct {
+		guid      string
+		recursive bool
+		async     bool
+	}{guid, recursive, async})
+	fake.recordInvocation("DeleteServiceInstance", []interface{}{guid, recursive, async})
+	fake.deleteServiceInstanceMutex.Unlock()
+	if fake.DeleteServiceInstanceStub != nil {
+		return fake.DeleteServiceInstanceStub(guid, recursive, async)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.deleteServiceInstanceReturns.result1
+}
+
+func (fake *FakeAPI) DeleteServiceInstanceCallCount() int {
+	fake.deleteServiceInstanceMutex.RLock()
+	defer fake.deleteServiceInstanceMutex.RUnlock()
+	return len(fake.deleteServiceInstanceArgsForCall)
+}
+
+func (fake *FakeAPI) DeleteServiceInstanceArgsForCall(i int) (string, bool, bool) {
+	fake.deleteServiceInstanceMutex.RLock()
+	defer fake.deleteServiceInstanceMutex.RUnlock()
+	return fake.deleteServiceInstanceArgsForCall[i].guid, fake.deleteServiceInstanceArgsForCall[i].recursive, fake.deleteServiceInstanceArgsForCall[i].async
+}
+
+func (fake *FakeAPI) DeleteServiceInstanceReturns(result1 error) {
+	fake.DeleteServiceInstanceStub = nil
+	fake.deleteServiceInstanceReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) DeleteServiceInstanceReturnsOnCall(i int, result1 error) {
+	fake.DeleteServiceInstanceStub = nil
+	if fake.deleteServiceInstanceReturnsOnCall == nil {
+		fake.deleteServiceInstanceReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteServiceInstanceReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) ListRoutes(query cloudfoundry.RouteQuery) ([]cloudfoundry.Route, error) {
+	fake.listRoutesMutex.Lock()
+	ret, specificReturn := fake.listRoutesReturnsOnCall[len(fake.listRoutesArgsForCall)]
+	fake.listRoutesArgsForCall = append(fake.listRoutesArgsForCall, struct {
+		query cloudfoundry.RouteQuery
+	}{query})
+	fake.recordInvocation("ListRoutes", []interface{}{query})
+	fake.listRoutesMutex.Unlock()
+	if fake.ListRoutesStub != nil {
+		return fake.ListRoutesStub(query)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.listRoutesReturns.result1, fake.listRoutesReturns.result2
+}
+
+func (fake *FakeAPI) ListRoutesCallCount() int {
+	fake.listRoutesMutex.RLock()
+	defer fake.listRoutesMutex.RUnlock()
+	return len(fake.listRoutesArgsForCall)
+}
+
+func (fake *FakeAPI) ListRoutesArgsForCall(i int) cloudfoundry.RouteQuery {
+	fake.listRoutesMutex.RLock()
+	defer fake.listRoutesMutex.RUnlock()
+	return fake.listRoutesArgsForCall[i].query
+}
+
+func (fake *FakeAPI) ListRoutesReturns(result1 []cloudfoundry.Route, result2 error) {
+	fake.ListRoutesStub = nil
+	fake.listRoutesReturns = struct {
+		result1 []cloudfoundry.Route
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) ListRoutesReturnsOnCall(i int, result1 []cloudfoundry.Route, result2 error) {
+	fake.ListRoutesStub = nil
+	if fake.listRoutesReturnsOnCall == nil {
+		fake.listRoutesReturnsOnCall = make(map[int]struct {
+			result1 []cloudfoundry.Route
+			result2 error
+		})
+	}
+	fake.listRoutesReturnsOnCall[i] = struct {
+		result1 []cloudfoundry.Route
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPI) DeleteRoute(guid string) error {
+	fake.deleteRouteMutex.Lock()
+	ret, specificReturn := fake.deleteRouteReturnsOnCall[len(fake.deleteRouteArgsForCall)]
+	fake.deleteRouteArgsForCall = append(fake.deleteRouteArgsForCall, struct {
+		guid string
+	}{guid})
+	fake.recordInvocation("DeleteRoute", []interface{}{guid})
+	fake.deleteRouteMutex.Unlock()
+	if fake.DeleteRouteStub != nil {
+		return fake.DeleteRouteStub(guid)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.deleteRouteReturns.result1
+}
+
+func (fake *FakeAPI) DeleteRouteCallCount() int {
+	fake.deleteRouteMutex.RLock()
+	defer fake.deleteRouteMutex.RUnlock()
+	return len(fake.deleteRouteArgsForCall)
+}
+
+func (fake *FakeAPI) DeleteRouteArgsForCall(i int) string {
+	fake.deleteRouteMutex.RLock()
+	defer fake.deleteRouteMutex.RUnlock()
+	return fake.deleteRouteArgsForCall[i].guid
+}
+
+func (fake *FakeAPI) DeleteRouteReturns(result1 error) {
+	fake.DeleteRouteStub = nil
+	fake.deleteRouteReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAPI) DeleteRouteReturnsOnCall(i int, result1 error) {
+	fake.DeleteRouteStub = nil
+	if fake.deleteRouteReturnsOnCall == nil {
+		fake.deleteRouteReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteRouteReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeAPI) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.createOrgMutex.RLock()
 	defer fake.createOrgMutex.RUnlock()
-	fake.updateOrgMutex.RLock()
-	defer fake.updateOrgMutex.RUnlock()
 	fake.addIsolationSegmentToOrgMutex.RLock()
 	defer fake.addIsolationSegmentToOrgMutex.RUnlock()
-	fake.listOrgsByQueryMutex.RLock()
-	defer fake.listOrgsByQueryMutex.RUnlock()
+	fake.setOrgDefaultIsolationSegmentMutex.RLock()
+	defer fake.setOrgDefaultIsolationSegmentMutex.RUnlock()
+	fake.listOrgsMutex.RLock()
+	defer fake.listOrgsMutex.RUnlock()
+	fake.deleteOrgMutex.RLock()
+	defer fake.deleteOrgMutex.RUnlock()
+	fake.listOrgManagersMutex.RLock()
+	defer fake.listOrgManagersMutex.RUnlock()
 	fake.createSpaceMutex.RLock()
 	defer fake.createSpaceMutex.RUnlock()
+	fake.listSpacesMutex.RLock()
+	defer fake.listSpacesMutex.RUnlock()
+	fake.deleteSpaceMutex.RLock()
+	defer fake.deleteSpaceMutex.RUnlock()
+	fake.createSpaceQuotaMutex.RLock()
+	defer fake.createSpaceQuotaMutex.RUnlock()
+	fake.listSpaceQuotasMutex.RLock()
+	defer fake.listSpaceQuotasMutex.RUnlock()
 	fake.associateOrgUserMutex.RLock()
 	defer fake.associateOrgUserMutex.RUnlock()
 	fake.associateOrgAuditorMutex.RLock()
@@ -680,8 +1805,32 @@ func (fake *FakeAPI) Invocations() map[string][][]interface{} {
 	defer fake.associateOrgManagerMutex.RUnlock()
 	fake.getOrgQuotaByNameMutex.RLock()
 	defer fake.getOrgQuotaByNameMutex.RUnlock()
-	fake.listIsolationSegmentsByQueryMutex.RLock()
-	defer fake.listIsolationSegmentsByQueryMutex.RUnlock()
+	fake.listIsolationSegmentsByNameMutex.RLock()
+	defer fake.listIsolationSegmentsByNameMutex.RUnlock()
+	fake.listSecurityGroupsByNameMutex.RLock()
+	defer fake.listSecurityGroupsByNameMutex.RUnlock()
+	fake.bindSecGroupMutex.RLock()
+	defer fake.bindSecGroupMutex.RUnlock()
+	fake.bindStagingSecGroupToSpaceMutex.RLock()
+	defer fake.bindStagingSecGroupToSpaceMutex.RUnlock()
+	fake.listAppsMutex.RLock()
+	defer fake.listAppsMutex.RUnlock()
+	fake.deleteAppMutex.RLock()
+	defer fake.deleteAppMutex.RUnlock()
+	fake.listServicesByLabelMutex.RLock()
+	defer fake.listServicesByLabelMutex.RUnlock()
+	fake.listServicePlansForServiceMutex.RLock()
+	defer fake.listServicePlansForServiceMutex.RUnlock()
+	fake.listServiceInstancesMutex.RLock()
+	defer fake.listServiceInstancesMutex.RUnlock()
+	fake.createServiceInstanceMutex.RLock()
+	defer fake.createServiceInstanceMutex.RUnlock()
+	fake.deleteServiceInstanceMutex.RLock()
+	defer fake.deleteServiceInstanceMutex.RUnlock()
+	fake.listRoutesMutex.RLock()
+	defer fake.listRoutesMutex.RUnlock()
+	fake.deleteRouteMutex.RLock()
+	defer fake.deleteRouteMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -0,0 +1,30 @@
+package cloudfoundry
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+
+	cfclient "github.com/cloudfoundry-community/go-cfclient"
+	"github.com/pkg/errors"
+)
+
+// IsTransientError returns true when the error is likely to be resolved by
+// retrying the request, e.g. when the request to the Cloud Controller timed
+// out, or it responded with a server error. Other errors making the request,
+// such as an invalid URL or certificate, are not transient.
+func IsTransientError(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case nil:
+		return false
+	case cfclient.CloudFoundryHTTPError:
+		return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+	case V3Error:
+		return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+	case *url.Error:
+		return IsTransientError(e.Err)
+	case net.Error:
+		return e.Timeout() || e.Temporary()
+	}
+	return false
+}
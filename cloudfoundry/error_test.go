@@ -0,0 +1,67 @@
+package cloudfoundry_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	cfclient "github.com/cloudfoundry-community/go-cfclient"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestIsTransientError(t *testing.T) {
+	spec.Run(t, "IsTransientError", testIsTransientError, spec.Report(report.Terminal{}))
+}
+
+func testIsTransientError(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("is true for server errors", func() {
+		Expect(cloudfoundry.IsTransientError(cfclient.CloudFoundryHTTPError{StatusCode: 502})).To(BeTrue())
+		Expect(cloudfoundry.IsTransientError(cfclient.CloudFoundryHTTPError{StatusCode: 429})).To(BeTrue())
+		Expect(cloudfoundry.IsTransientError(cloudfoundry.V3Error{StatusCode: 503})).To(BeTrue())
+	})
+
+	it("is true for requests to the Cloud Controller that timed out or failed temporarily", func() {
+		err := &url.Error{Op: "Post", URL: "https://api.example.com", Err: netError{timeout: true}}
+		Expect(cloudfoundry.IsTransientError(pkgerrors.Wrap(err, "could not create org"))).To(BeTrue())
+		err = &url.Error{Op: "Post", URL: "https://api.example.com", Err: netError{temporary: true}}
+		Expect(cloudfoundry.IsTransientError(err)).To(BeTrue())
+		Expect(cloudfoundry.IsTransientError(netError{timeout: true})).To(BeTrue())
+	})
+
+	it("is false for other errors making requests to the Cloud Controller", func() {
+		err := &url.Error{Op: "Post", URL: "https://api.example.com", Err: errors.New("x509: certificate signed by unknown authority")}
+		Expect(cloudfoundry.IsTransientError(pkgerrors.Wrap(err, "could not create org"))).To(BeFalse())
+		err = &url.Error{Op: "Post", URL: "https://api.example.com", Err: netError{}}
+		Expect(cloudfoundry.IsTransientError(err)).To(BeFalse())
+		Expect(cloudfoundry.IsTransientError(&url.Error{Op: "parse", URL: "://api", Err: errors.New("missing protocol scheme")})).To(BeFalse())
+	})
+
+	it("is false for client errors", func() {
+		Expect(cloudfoundry.IsTransientError(cfclient.CloudFoundryHTTPError{StatusCode: 400})).To(BeFalse())
+		Expect(cloudfoundry.IsTransientError(cfclient.CloudFoundryError{Code: 30002})).To(BeFalse())
+		Expect(cloudfoundry.IsTransientError(cloudfoundry.V3Error{StatusCode: 422})).To(BeFalse())
+	})
+
+	it("is false for other errors", func() {
+		Expect(cloudfoundry.IsTransientError(nil)).To(BeFalse())
+		Expect(cloudfoundry.IsTransientError(errors.New("test error"))).To(BeFalse())
+	})
+}
+
+// netError is a net.Error that can time out, or be temporary
+type netError struct {
+	timeout   bool
+	temporary bool
+}
+
+func (e netError) Error() string   { return "test network error" }
+func (e netError) Timeout() bool   { return e.timeout }
+func (e netError) Temporary() bool { return e.temporary }
@@ -2,22 +2,23 @@ package cloudfoundry
 
 import (
 	"fmt"
-	"net/url"
 	"strings"
-
-	cfclient "github.com/cloudfoundry-community/go-cfclient"
 )
 
+// IsolationSegment is an isolation segment
+type IsolationSegment struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
 // ISOSegmentQuerier is used to query a Cloud Controller API for isolation segments
 type ISOSegmentQuerier interface {
-	ListIsolationSegmentsByQuery(query url.Values) ([]cfclient.IsolationSegment, error)
+	ListIsolationSegmentsByName(name string) ([]IsolationSegment, error)
 }
 
 // ISOSegmentIDForName gets the isolation segment ID for the given iso segment name
 func ISOSegmentIDForName(name string, iq ISOSegmentQuerier) (string, error) {
-	q := url.Values{}
-	q.Set("names", name)
-	isoSegments, err := iq.ListIsolationSegmentsByQuery(q)
+	isoSegments, err := iq.ListIsolationSegmentsByName(name)
 	if err != nil {
 		return "", err
 	}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"testing"
 
-	cfclient "github.com/cloudfoundry-community/go-cfclient"
 	. "github.com/onsi/gomega"
 	"github.com/pivotalservices/ignition/cloudfoundry"
 	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
@@ -26,7 +25,7 @@ func testISOSegmentForName(t *testing.T, when spec.G, it spec.S) {
 	when("list iso segments returns an error", func() {
 		it.Before(func() {
 			f = &cloudfoundryfakes.FakeAPI{}
-			f.ListIsolationSegmentsByQueryReturns(nil, errors.New("test error"))
+			f.ListIsolationSegmentsByNameReturns(nil, errors.New("test error"))
 		})
 
 		it("errors when the call to cloud foundry fails", func() {
@@ -39,8 +38,8 @@ func testISOSegmentForName(t *testing.T, when spec.G, it spec.S) {
 	when("list iso segment only returns the shared default iso segment", func() {
 		it.Before(func() {
 			f = &cloudfoundryfakes.FakeAPI{}
-			f.ListIsolationSegmentsByQueryReturns([]cfclient.IsolationSegment{
-				cfclient.IsolationSegment{
+			f.ListIsolationSegmentsByNameReturns([]cloudfoundry.IsolationSegment{
+				cloudfoundry.IsolationSegment{
 					GUID: "shared-iso-guid",
 					Name: "shared",
 				},
@@ -63,12 +62,12 @@ func testISOSegmentForName(t *testing.T, when spec.G, it spec.S) {
 	when("list iso segment returns the shared default iso segment and one other", func() {
 		it.Before(func() {
 			f = &cloudfoundryfakes.FakeAPI{}
-			f.ListIsolationSegmentsByQueryReturns([]cfclient.IsolationSegment{
-				cfclient.IsolationSegment{
+			f.ListIsolationSegmentsByNameReturns([]cloudfoundry.IsolationSegment{
+				cloudfoundry.IsolationSegment{
 					GUID: "shared-iso-guid",
 					Name: "shared",
 				},
-				cfclient.IsolationSegment{
+				cloudfoundry.IsolationSegment{
 					GUID: "my-iso-guid",
 					Name: "myiso",
 				},
@@ -2,7 +2,8 @@ package cloudfoundry
 
 import (
 	"fmt"
-	"net/url"
+	"net/http"
+	"regexp"
 	"strings"
 
 	cfclient "github.com/cloudfoundry-community/go-cfclient"
@@ -18,80 +19,188 @@ type Organization struct {
 	QuotaDefinitionGUID         string `json:"quota_definition_guid"`
 	DefaultIsolationSegmentGUID string `json:"default_isolation_segment_guid"`
 	URL                         string `json:"url"`
+
+	// Foundation is the name of the ignition foundation that the org is in
+	Foundation string `json:"foundation,omitempty"`
+
+	// ServiceInstanceErrors lists the service instances in the org's
+	// template that could not be created when the org was provisioned
+	ServiceInstanceErrors []ServiceInstanceError `json:"service_instance_errors,omitempty"`
+}
+
+// OrgQuery filters the orgs that are listed; the orgs are not filtered by the
+// fields that are empty
+type OrgQuery struct {
+	Name      string
+	UserGUID  string
+	QuotaGUID string
+}
+
+// User is a Cloud Controller user
+type User struct {
+	GUID     string `json:"guid"`
+	Username string `json:"username"`
 }
 
 // OrganizationQuerier is used to query a Cloud Controller API for organizations
 type OrganizationQuerier interface {
-	ListOrgsByQuery(query url.Values) ([]cfclient.Org, error)
+	ListOrgs(query OrgQuery) ([]Organization, error)
 }
 
 // OrganizationCreator creates orgs
 type OrganizationCreator interface {
-	CreateOrg(req cfclient.OrgRequest) (cfclient.Org, error)
-	UpdateOrg(orgGUID string, orgRequest cfclient.OrgRequest) (cfclient.Org, error)
+	CreateOrg(name, quotaGUID string) (Organization, error)
 	AddIsolationSegmentToOrg(isolationSegmentGUID, orgGUID string) error
+	SetOrgDefaultIsolationSegment(orgGUID, isolationSegmentGUID string) error
+}
+
+// OrganizationDeleter deletes orgs
+type OrganizationDeleter interface {
+	DeleteOrg(guid string, recursive, async bool) error
+}
+
+// OrganizationManagerQuerier is used to query a Cloud Controller API for the
+// managers of an organization
+type OrganizationManagerQuerier interface {
+	ListOrgManagers(orgGUID string) ([]User, error)
 }
 
 // RoleGrantor allows for users to be granted org and space roles
 type RoleGrantor interface {
-	AssociateOrgUser(orgGUID, userGUID string) (cfclient.Org, error)
-	AssociateOrgAuditor(orgGUID, userGUID string) (cfclient.Org, error)
-	AssociateOrgManager(orgGUID, userGUID string) (cfclient.Org, error)
+	AssociateOrgUser(orgGUID, userGUID string) error
+	AssociateOrgAuditor(orgGUID, userGUID string) error
+	AssociateOrgManager(orgGUID, userGUID string) error
 }
 
 // OrgsForUserID returns the orgs that the user is a member of
 func OrgsForUserID(id string, appsURL string, q OrganizationQuerier) ([]Organization, error) {
-	query := url.Values{}
-	query.Add("q", fmt.Sprintf("user_guid:%s", id))
-	o, err := q.ListOrgsByQuery(query)
+	o, err := q.ListOrgs(OrgQuery{UserGUID: id})
 	if err != nil {
 		return nil, err
 	}
 
 	result := make([]Organization, len(o))
 	for i := range o {
-		result[i] = convertOrg(o[i], appsURL)
+		result[i] = withURL(o[i], appsURL)
+	}
+	return result, nil
+}
+
+// OrgsForQuotaID returns the orgs that have been assigned the given quota. The
+// Cloud Controller filters the orgs by quota, and they are checked again here
+// in case it ignores the filter.
+func OrgsForQuotaID(quotaID string, appsURL string, q OrganizationQuerier) ([]Organization, error) {
+	if strings.TrimSpace(quotaID) == "" {
+		return nil, errors.New("a quota is required to find orgs by quota")
+	}
+	o, err := q.ListOrgs(OrgQuery{QuotaGUID: quotaID})
+	if err != nil {
+		return nil, err
+	}
+	var result []Organization
+	for i := range o {
+		if o[i].QuotaDefinitionGUID == quotaID {
+			result = append(result, withURL(o[i], appsURL))
+		}
+	}
+	return result, nil
+}
+
+// ManagerNamesForOrg returns the usernames of the managers of the given org
+func ManagerNamesForOrg(orgGUID string, q OrganizationManagerQuerier) ([]string, error) {
+	managers, err := q.ListOrgManagers(orgGUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list managers for org [%s]", orgGUID)
+	}
+	var result []string
+	for _, m := range managers {
+		if strings.TrimSpace(m.Username) != "" {
+			result = append(result, m.Username)
+		}
 	}
 	return result, nil
 }
 
+// OrgForName returns the org with the given name, or nil if no such org exists
+func OrgForName(name string, appsURL string, q OrganizationQuerier) (*Organization, error) {
+	o, err := q.ListOrgs(OrgQuery{Name: strings.ToLower(name)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find org with name [%s]", name)
+	}
+	if len(o) == 0 {
+		return nil, nil
+	}
+	org := withURL(o[0], appsURL)
+	return &org, nil
+}
+
+// orgNameTakenCode is the Cloud Controller error code for
+// CF-OrganizationNameTaken
+const orgNameTakenCode = 30002
+
+// orgNameTakenDetail matches the detail of the v3 error that reports that an
+// org with the requested name already exists
+var orgNameTakenDetail = regexp.MustCompile(`^Organization '.*' already exists\.$`)
+
+// IsOrgNameTakenError returns true when the error reports that an org with the
+// requested name already exists
+func IsOrgNameTakenError(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case cfclient.CloudFoundryError:
+		return e.Code == orgNameTakenCode
+	case V3Error:
+		return e.StatusCode == http.StatusUnprocessableEntity && orgNameTakenDetail.MatchString(e.Detail)
+	}
+	return false
+}
+
 // CreateOrg creates an organization with the given name and quota for
 // the given user
 func CreateOrg(name, appsURL, quotaID, isoSegmentID string, a OrganizationCreator) (*Organization, error) {
-	req := cfclient.OrgRequest{
-		Name:                strings.ToLower(name),
-		QuotaDefinitionGuid: quotaID,
+	o, err := CreateOrgWithQuota(name, appsURL, quotaID, a)
+	if err != nil {
+		return nil, err
 	}
-	org, err := a.CreateOrg(req)
+
+	err = SetDefaultIsolationSegment(o, isoSegmentID, a)
+	if err != nil {
+		return nil, err
+	}
+	o.DefaultIsolationSegmentGUID = isoSegmentID
+	return o, nil
+}
+
+// CreateOrgWithQuota creates an organization with the given name and quota,
+// without assigning it an isolation segment
+func CreateOrgWithQuota(name, appsURL, quotaID string, a OrganizationCreator) (*Organization, error) {
+	org, err := a.CreateOrg(strings.ToLower(name), quotaID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not create org with name [%s] and quota [%s]", name, quotaID)
 	}
 
+	o := withURL(org, appsURL)
+	return &o, nil
+}
+
+// SetDefaultIsolationSegment enables the org to use the isolation segment and
+// makes it the default for the org
+func SetDefaultIsolationSegment(org *Organization, isoSegmentID string, a OrganizationCreator) error {
 	// enable the org to use the iso segment
-	err = a.AddIsolationSegmentToOrg(isoSegmentID, org.Guid)
+	err := a.AddIsolationSegmentToOrg(isoSegmentID, org.GUID)
 	if err != nil {
-		return nil, errors.Wrapf(err, "could not assign isolation segment [%s] to org [%s]", isoSegmentID, name)
+		return errors.Wrapf(err, "could not assign isolation segment [%s] to org [%s]", isoSegmentID, org.Name)
 	}
 
 	// make the iso segment the default for the org
-	req.DefaultIsolationSegmentGuid = isoSegmentID
-	_, err = a.UpdateOrg(org.Guid, req)
+	err = a.SetOrgDefaultIsolationSegment(org.GUID, isoSegmentID)
 	if err != nil {
-		return nil, errors.Wrapf(err, "could not make isolation segment [%s] the default for org [%s]", isoSegmentID, name)
+		return errors.Wrapf(err, "could not make isolation segment [%s] the default for org [%s]", isoSegmentID, org.Name)
 	}
-
-	o := convertOrg(org, appsURL)
-	return &o, nil
+	return nil
 }
 
-func convertOrg(o cfclient.Org, appsURL string) Organization {
-	return Organization{
-		GUID:                        o.Guid,
-		CreatedAt:                   o.CreatedAt,
-		UpdatedAt:                   o.UpdatedAt,
-		Name:                        o.Name,
-		QuotaDefinitionGUID:         o.QuotaDefinitionGuid,
-		DefaultIsolationSegmentGUID: o.DefaultIsolationSegmentGuid,
-		URL: fmt.Sprintf("%s/organizations/%s", appsURL, o.Guid),
-	}
+// withURL sets the URL of the org in Apps Manager
+func withURL(o Organization, appsURL string) Organization {
+	o.URL = fmt.Sprintf("%s/organizations/%s", appsURL, o.GUID)
+	return o
 }
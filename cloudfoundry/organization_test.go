@@ -23,22 +23,22 @@ func testOrgsForUserID(t *testing.T, when spec.G, it spec.S) {
 
 	it("returns an error if the querier returns an error", func() {
 		a := &cloudfoundryfakes.FakeAPI{}
-		a.ListOrgsByQueryReturns(nil, errors.New("test error"))
+		a.ListOrgsReturns(nil, errors.New("test error"))
 		orgs, err := cloudfoundry.OrgsForUserID("123", "", a)
 		Expect(err).To(HaveOccurred())
 		Expect(orgs).To(BeNil())
 	})
 
-	it("maps the cfclient.Org to the Organization correctly", func() {
+	it("returns the user's orgs with their URLs", func() {
 		a := &cloudfoundryfakes.FakeAPI{}
-		a.ListOrgsByQueryReturns([]cfclient.Org{
-			cfclient.Org{
-				Guid:                        "1234",
+		a.ListOrgsReturns([]cloudfoundry.Organization{
+			cloudfoundry.Organization{
+				GUID:                        "1234",
 				Name:                        "test-org",
 				CreatedAt:                   "now",
 				UpdatedAt:                   "later",
-				QuotaDefinitionGuid:         "321",
-				DefaultIsolationSegmentGuid: "987",
+				QuotaDefinitionGUID:         "321",
+				DefaultIsolationSegmentGUID: "987",
 			},
 		}, nil)
 		orgs, err := cloudfoundry.OrgsForUserID("123", "https://example.com", a)
@@ -52,6 +52,7 @@ func testOrgsForUserID(t *testing.T, when spec.G, it spec.S) {
 		Expect(orgs[0].QuotaDefinitionGUID).To(Equal("321"))
 		Expect(orgs[0].DefaultIsolationSegmentGUID).To(Equal("987"))
 		Expect(orgs[0].URL).To(Equal("https://example.com/organizations/1234"))
+		Expect(a.ListOrgsArgsForCall(0)).To(Equal(cloudfoundry.OrgQuery{UserGUID: "123"}))
 	})
 }
 
@@ -66,7 +67,7 @@ func testCreateOrg(t *testing.T, when spec.G, it spec.S) {
 
 	it("returns an error if the creator returns an error", func() {
 		a := &cloudfoundryfakes.FakeAPI{}
-		a.CreateOrgReturns(cfclient.Org{}, errors.New("test error"))
+		a.CreateOrgReturns(cloudfoundry.Organization{}, errors.New("test error"))
 		org, err := cloudfoundry.CreateOrg("test-org", "appsurl", "quotaID", "isoSegmentID", a)
 		Expect(err).To(HaveOccurred())
 		Expect(org).To(BeNil())
@@ -74,13 +75,12 @@ func testCreateOrg(t *testing.T, when spec.G, it spec.S) {
 
 	it("returns the org if it is created successfully", func() {
 		a := &cloudfoundryfakes.FakeAPI{}
-		a.CreateOrgReturns(cfclient.Org{
-			Guid:                        "test-org-guid",
-			Name:                        "test-org",
-			QuotaDefinitionGuid:         "quotaID",
-			CreatedAt:                   "created-at",
-			UpdatedAt:                   "updated-at",
-			DefaultIsolationSegmentGuid: "isoSegmentID",
+		a.CreateOrgReturns(cloudfoundry.Organization{
+			GUID:                "test-org-guid",
+			Name:                "test-org",
+			QuotaDefinitionGUID: "quotaID",
+			CreatedAt:           "created-at",
+			UpdatedAt:           "updated-at",
 		}, nil)
 		org, err := cloudfoundry.CreateOrg("test-org", "appsurl", "quotaID", "isoSegmentID", a)
 		Expect(err).NotTo(HaveOccurred())
@@ -95,5 +95,203 @@ func testCreateOrg(t *testing.T, when spec.G, it spec.S) {
 			URL: "appsurl/organizations/test-org-guid",
 		}
 		Expect(*org).To(BeEquivalentTo(expected))
+		name, quotaID := a.CreateOrgArgsForCall(0)
+		Expect(name).To(Equal("test-org"))
+		Expect(quotaID).To(Equal("quotaID"))
+	})
+}
+
+func TestSetDefaultIsolationSegment(t *testing.T) {
+	spec.Run(t, "SetDefaultIsolationSegment", testSetDefaultIsolationSegment, spec.Report(report.Terminal{}))
+}
+
+func testSetDefaultIsolationSegment(t *testing.T, when spec.G, it spec.S) {
+	var org *cloudfoundry.Organization
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		org = &cloudfoundry.Organization{
+			GUID:                "test-org-guid",
+			Name:                "test-org",
+			QuotaDefinitionGUID: "quotaID",
+		}
+	})
+
+	it("returns an error if the isolation segment cannot be assigned", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.AddIsolationSegmentToOrgReturns(errors.New("test error"))
+		err := cloudfoundry.SetDefaultIsolationSegment(org, "isoSegmentID", a)
+		Expect(err).To(HaveOccurred())
+		Expect(a.SetOrgDefaultIsolationSegmentCallCount()).To(Equal(0))
+	})
+
+	it("returns an error if the default cannot be updated", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.SetOrgDefaultIsolationSegmentReturns(errors.New("test error"))
+		err := cloudfoundry.SetDefaultIsolationSegment(org, "isoSegmentID", a)
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("assigns the isolation segment and makes it the default", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		err := cloudfoundry.SetDefaultIsolationSegment(org, "isoSegmentID", a)
+		Expect(err).NotTo(HaveOccurred())
+		isoSegmentID, orgGUID := a.AddIsolationSegmentToOrgArgsForCall(0)
+		Expect(isoSegmentID).To(Equal("isoSegmentID"))
+		Expect(orgGUID).To(Equal("test-org-guid"))
+		orgGUID, isoSegmentID = a.SetOrgDefaultIsolationSegmentArgsForCall(0)
+		Expect(orgGUID).To(Equal("test-org-guid"))
+		Expect(isoSegmentID).To(Equal("isoSegmentID"))
+	})
+}
+
+func TestOrgsForQuotaID(t *testing.T) {
+	spec.Run(t, "OrgsForQuotaID", testOrgsForQuotaID, spec.Report(report.Terminal{}))
+}
+
+func testOrgsForQuotaID(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("returns an error if the querier returns an error", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.ListOrgsReturns(nil, errors.New("test error"))
+		orgs, err := cloudfoundry.OrgsForQuotaID("quota-id", "", a)
+		Expect(err).To(HaveOccurred())
+		Expect(orgs).To(BeNil())
+	})
+
+	it("only returns the orgs with the given quota", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.ListOrgsReturns([]cloudfoundry.Organization{
+			cloudfoundry.Organization{
+				GUID:                "1234",
+				Name:                "some-other-org",
+				QuotaDefinitionGUID: "other-quota-id",
+			},
+			cloudfoundry.Organization{
+				GUID:                "4321",
+				Name:                "ignition-test",
+				QuotaDefinitionGUID: "quota-id",
+			},
+		}, nil)
+		orgs, err := cloudfoundry.OrgsForQuotaID("quota-id", "https://example.com", a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(orgs)).To(Equal(1))
+		Expect(orgs[0].GUID).To(Equal("4321"))
+		Expect(orgs[0].URL).To(Equal("https://example.com/organizations/4321"))
+		Expect(a.ListOrgsArgsForCall(0)).To(Equal(cloudfoundry.OrgQuery{QuotaGUID: "quota-id"}))
+	})
+
+	it("errors without listing the orgs when the quota is empty", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		_, err := cloudfoundry.OrgsForQuotaID("", "", a)
+		Expect(err).To(HaveOccurred())
+		Expect(a.ListOrgsCallCount()).To(Equal(0))
+	})
+}
+
+func TestManagerNamesForOrg(t *testing.T) {
+	spec.Run(t, "ManagerNamesForOrg", testManagerNamesForOrg, spec.Report(report.Terminal{}))
+}
+
+func testManagerNamesForOrg(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("returns an error if the querier returns an error", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.ListOrgManagersReturns(nil, errors.New("test error"))
+		names, err := cloudfoundry.ManagerNamesForOrg("1234", a)
+		Expect(err).To(HaveOccurred())
+		Expect(names).To(BeNil())
+	})
+
+	it("returns the usernames of the managers", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.ListOrgManagersReturns([]cloudfoundry.User{
+			cloudfoundry.User{GUID: "1", Username: "test@example.net"},
+			cloudfoundry.User{GUID: "2"},
+		}, nil)
+		names, err := cloudfoundry.ManagerNamesForOrg("1234", a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"test@example.net"}))
+		Expect(a.ListOrgManagersArgsForCall(0)).To(Equal("1234"))
+	})
+}
+
+func TestOrgForName(t *testing.T) {
+	spec.Run(t, "OrgForName", testOrgForName, spec.Report(report.Terminal{}))
+}
+
+func testOrgForName(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("returns an error if the querier returns an error", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.ListOrgsReturns(nil, errors.New("test error"))
+		org, err := cloudfoundry.OrgForName("ignition-test", "", a)
+		Expect(err).To(HaveOccurred())
+		Expect(org).To(BeNil())
+	})
+
+	it("returns nil if there is no org with the name", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		org, err := cloudfoundry.OrgForName("ignition-test", "", a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(org).To(BeNil())
+	})
+
+	it("queries for the org by its lowercase name", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.ListOrgsReturns([]cloudfoundry.Organization{
+			cloudfoundry.Organization{
+				GUID: "1234",
+				Name: "ignition-test",
+			},
+		}, nil)
+		org, err := cloudfoundry.OrgForName("Ignition-Test", "https://example.com", a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(org.GUID).To(Equal("1234"))
+		Expect(org.URL).To(Equal("https://example.com/organizations/1234"))
+		Expect(a.ListOrgsArgsForCall(0)).To(Equal(cloudfoundry.OrgQuery{Name: "ignition-test"}))
+	})
+}
+
+func TestIsOrgNameTakenError(t *testing.T) {
+	spec.Run(t, "IsOrgNameTakenError", testIsOrgNameTakenError, spec.Report(report.Terminal{}))
+}
+
+func testIsOrgNameTakenError(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("is true for a name taken error from the Cloud Controller", func() {
+		err := cfclient.CloudFoundryError{Code: 30002, ErrorCode: "CF-OrganizationNameTaken"}
+		Expect(cloudfoundry.IsOrgNameTakenError(err)).To(BeTrue())
+	})
+
+	it("is true for a name taken error that has been wrapped", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.CreateOrgReturns(cloudfoundry.Organization{}, cfclient.CloudFoundryError{Code: 30002})
+		_, err := cloudfoundry.CreateOrg("ignition-test", "", "quota-id", "iso-segment-id", a)
+		Expect(cloudfoundry.IsOrgNameTakenError(err)).To(BeTrue())
+	})
+
+	it("is true for a v3 name taken error", func() {
+		err := cloudfoundry.V3Error{StatusCode: 422, Code: 10008, Title: "CF-UnprocessableEntity", Detail: "Organization 'ignition-test' already exists."}
+		Expect(cloudfoundry.IsOrgNameTakenError(err)).To(BeTrue())
+	})
+
+	it("is false for other errors", func() {
+		Expect(cloudfoundry.IsOrgNameTakenError(nil)).To(BeFalse())
+		Expect(cloudfoundry.IsOrgNameTakenError(errors.New("test error"))).To(BeFalse())
+		Expect(cloudfoundry.IsOrgNameTakenError(cfclient.CloudFoundryError{Code: 30003})).To(BeFalse())
+		Expect(cloudfoundry.IsOrgNameTakenError(cloudfoundry.V3Error{StatusCode: 422, Detail: "Name can't be blank"})).To(BeFalse())
 	})
 }
@@ -3,13 +3,17 @@ package cloudfoundry
 import (
 	"fmt"
 	"strings"
-
-	cfclient "github.com/cloudfoundry-community/go-cfclient"
 )
 
+// Quota is an org quota
+type Quota struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
 // QuotaQuerier is used to query a Cloud Controller API for quotas
 type QuotaQuerier interface {
-	GetOrgQuotaByName(name string) (cfclient.OrgQuota, error)
+	GetOrgQuotaByName(name string) (Quota, error)
 }
 
 // QuotaIDForName gets the quota ID for the given quota name
@@ -18,8 +22,8 @@ func QuotaIDForName(name string, q QuotaQuerier) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if strings.TrimSpace(quota.Guid) == "" {
+	if strings.TrimSpace(quota.GUID) == "" {
 		return "", fmt.Errorf("cannot find quota with name [%s]", name)
 	}
-	return quota.Guid, nil
+	return quota.GUID, nil
 }
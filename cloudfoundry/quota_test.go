@@ -4,7 +4,6 @@ import (
 	"errors"
 	"testing"
 
-	cfclient "github.com/cloudfoundry-community/go-cfclient"
 	. "github.com/onsi/gomega"
 	"github.com/pivotalservices/ignition/cloudfoundry"
 	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
@@ -23,7 +22,7 @@ func testQuotaIDForName(t *testing.T, when spec.G, it spec.S) {
 
 	it("errors when the call to cloud foundry fails", func() {
 		f := &cloudfoundryfakes.FakeAPI{}
-		f.GetOrgQuotaByNameReturns(cfclient.OrgQuota{}, errors.New("test error"))
+		f.GetOrgQuotaByNameReturns(cloudfoundry.Quota{}, errors.New("test error"))
 		id, err := cloudfoundry.QuotaIDForName("test", f)
 		Expect(id).To(BeZero())
 		Expect(err).To(HaveOccurred())
@@ -31,8 +30,8 @@ func testQuotaIDForName(t *testing.T, when spec.G, it spec.S) {
 
 	it("returns the quota id when the call to cloud foundry succeeds", func() {
 		f := &cloudfoundryfakes.FakeAPI{}
-		f.GetOrgQuotaByNameReturns(cfclient.OrgQuota{
-			Guid: "test-org-quota-id",
+		f.GetOrgQuotaByNameReturns(cloudfoundry.Quota{
+			GUID: "test-org-quota-id",
 		}, nil)
 		id, err := cloudfoundry.QuotaIDForName("test", f)
 		Expect(id).To(Equal("test-org-quota-id"))
@@ -41,7 +40,7 @@ func testQuotaIDForName(t *testing.T, when spec.G, it spec.S) {
 
 	it("errors when the quota id is empty", func() {
 		f := &cloudfoundryfakes.FakeAPI{}
-		f.GetOrgQuotaByNameReturns(cfclient.OrgQuota{}, nil)
+		f.GetOrgQuotaByNameReturns(cloudfoundry.Quota{}, nil)
 		id, err := cloudfoundry.QuotaIDForName("test", f)
 		Expect(id).To(BeZero())
 		Expect(err).To(HaveOccurred())
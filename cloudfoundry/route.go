@@ -0,0 +1,24 @@
+package cloudfoundry
+
+// Route is a route in a space
+type Route struct {
+	GUID      string `json:"guid"`
+	Host      string `json:"host"`
+	SpaceGUID string `json:"space_guid"`
+}
+
+// RouteQuery filters the routes that are listed; the routes are not filtered
+// by the fields that are empty
+type RouteQuery struct {
+	OrgGUID string
+}
+
+// RouteQuerier is used to query a Cloud Controller API for routes
+type RouteQuerier interface {
+	ListRoutes(query RouteQuery) ([]Route, error)
+}
+
+// RouteDeleter deletes routes
+type RouteDeleter interface {
+	DeleteRoute(guid string) error
+}
@@ -0,0 +1,62 @@
+package cloudfoundry
+
+import (
+	"github.com/pkg/errors"
+)
+
+// SecurityGroup is an application security group
+type SecurityGroup struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
+// SecurityGroupQuerier is used to query a Cloud Controller API for
+// application security groups
+type SecurityGroupQuerier interface {
+	ListSecurityGroupsByName(name string) ([]SecurityGroup, error)
+}
+
+// SecurityGroupBinder binds application security groups to spaces
+type SecurityGroupBinder interface {
+	BindSecGroup(secGUID, spaceGUID string) error
+	BindStagingSecGroupToSpace(secGUID, spaceGUID string) error
+}
+
+// SecurityGroupIDForName gets the security group ID for the given security
+// group name
+func SecurityGroupIDForName(name string, q SecurityGroupQuerier) (string, error) {
+	groups, err := q.ListSecurityGroupsByName(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not find security group with name [%s]", name)
+	}
+	if len(groups) == 0 || groups[0].GUID == "" {
+		return "", errors.Errorf("could not find security group with name [%s]", name)
+	}
+	return groups[0].GUID, nil
+}
+
+// BindSecurityGroups binds the running and staging security groups in the
+// template to the space
+func BindSecurityGroups(t SpaceTemplate, spaceGUID string, q SecurityGroupQuerier, b SecurityGroupBinder) error {
+	for _, name := range t.RunningSecurityGroups {
+		id, err := SecurityGroupIDForName(name, q)
+		if err != nil {
+			return err
+		}
+		err = b.BindSecGroup(id, spaceGUID)
+		if err != nil {
+			return errors.Wrapf(err, "could not bind running security group [%s] to space [%s]", name, t.Name)
+		}
+	}
+	for _, name := range t.StagingSecurityGroups {
+		id, err := SecurityGroupIDForName(name, q)
+		if err != nil {
+			return err
+		}
+		err = b.BindStagingSecGroupToSpace(id, spaceGUID)
+		if err != nil {
+			return errors.Wrapf(err, "could not bind staging security group [%s] to space [%s]", name, t.Name)
+		}
+	}
+	return nil
+}
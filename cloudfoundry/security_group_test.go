@@ -0,0 +1,106 @@
+package cloudfoundry_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestSecurityGroupIDForName(t *testing.T) {
+	spec.Run(t, "SecurityGroupIDForName", testSecurityGroupIDForName, spec.Report(report.Terminal{}))
+}
+
+func testSecurityGroupIDForName(t *testing.T, when spec.G, it spec.S) {
+	var a *cloudfoundryfakes.FakeAPI
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		a = &cloudfoundryfakes.FakeAPI{}
+	})
+
+	it("returns the guid of the security group", func() {
+		a.ListSecurityGroupsByNameReturns([]cloudfoundry.SecurityGroup{{GUID: "test-group-guid", Name: "artifacts"}}, nil)
+		id, err := cloudfoundry.SecurityGroupIDForName("artifacts", a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal("test-group-guid"))
+		Expect(a.ListSecurityGroupsByNameArgsForCall(0)).To(Equal("artifacts"))
+	})
+
+	it("returns an error when the security group cannot be found", func() {
+		id, err := cloudfoundry.SecurityGroupIDForName("artifacts", a)
+		Expect(err).To(MatchError("could not find security group with name [artifacts]"))
+		Expect(id).To(BeEmpty())
+	})
+
+	it("returns an error when the security groups cannot be listed", func() {
+		a.ListSecurityGroupsByNameReturns(nil, errors.New("No security group with name artifacts found"))
+		id, err := cloudfoundry.SecurityGroupIDForName("artifacts", a)
+		Expect(err).To(HaveOccurred())
+		Expect(id).To(BeEmpty())
+	})
+}
+
+func TestBindSecurityGroups(t *testing.T) {
+	spec.Run(t, "BindSecurityGroups", testBindSecurityGroups, spec.Report(report.Terminal{}))
+}
+
+func testBindSecurityGroups(t *testing.T, when spec.G, it spec.S) {
+	var (
+		a        *cloudfoundryfakes.FakeAPI
+		template cloudfoundry.SpaceTemplate
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		a = &cloudfoundryfakes.FakeAPI{}
+		a.ListSecurityGroupsByNameStub = func(name string) ([]cloudfoundry.SecurityGroup, error) {
+			return []cloudfoundry.SecurityGroup{{GUID: name + "-guid", Name: name}}, nil
+		}
+		template = cloudfoundry.SpaceTemplate{
+			Name:                  "playground",
+			RunningSecurityGroups: []string{"artifacts", "internal-dns"},
+			StagingSecurityGroups: []string{"artifacts"},
+		}
+	})
+
+	it("binds the running and staging security groups to the space", func() {
+		err := cloudfoundry.BindSecurityGroups(template, "test-space-guid", a, a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(a.BindSecGroupCallCount()).To(Equal(2))
+		group, space := a.BindSecGroupArgsForCall(0)
+		Expect(group).To(Equal("artifacts-guid"))
+		Expect(space).To(Equal("test-space-guid"))
+		group, _ = a.BindSecGroupArgsForCall(1)
+		Expect(group).To(Equal("internal-dns-guid"))
+		Expect(a.BindStagingSecGroupToSpaceCallCount()).To(Equal(1))
+		group, space = a.BindStagingSecGroupToSpaceArgsForCall(0)
+		Expect(group).To(Equal("artifacts-guid"))
+		Expect(space).To(Equal("test-space-guid"))
+	})
+
+	it("returns an error when a security group cannot be found", func() {
+		a.ListSecurityGroupsByNameReturns(nil, errors.New("test error"))
+		a.ListSecurityGroupsByNameStub = nil
+		err := cloudfoundry.BindSecurityGroups(template, "test-space-guid", a, a)
+		Expect(err).To(HaveOccurred())
+		Expect(a.BindSecGroupCallCount()).To(Equal(0))
+	})
+
+	it("returns an error when a running security group cannot be bound", func() {
+		a.BindSecGroupReturns(errors.New("test error"))
+		err := cloudfoundry.BindSecurityGroups(template, "test-space-guid", a, a)
+		Expect(err).To(HaveOccurred())
+		Expect(a.BindStagingSecGroupToSpaceCallCount()).To(Equal(0))
+	})
+
+	it("returns an error when a staging security group cannot be bound", func() {
+		a.BindStagingSecGroupToSpaceReturns(errors.New("test error"))
+		err := cloudfoundry.BindSecurityGroups(template, "test-space-guid", a, a)
+		Expect(err).To(HaveOccurred())
+	})
+}
@@ -0,0 +1,51 @@
+package cloudfoundry
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Service is a service in the marketplace; the v3 API calls it a service
+// offering
+type Service struct {
+	GUID  string `json:"guid"`
+	Label string `json:"label"`
+}
+
+// ServicePlan is a plan of a marketplace service
+type ServicePlan struct {
+	GUID        string `json:"guid"`
+	Name        string `json:"name"`
+	ServiceGUID string `json:"service_guid"`
+}
+
+// ServicePlanQuerier is used to query a Cloud Controller API for the services
+// and service plans in the marketplace
+type ServicePlanQuerier interface {
+	ListServicesByLabel(label string) ([]Service, error)
+	ListServicePlansForService(serviceGUID string) ([]ServicePlan, error)
+}
+
+// ServicePlanGUID returns the GUID of the named plan of the named marketplace
+// service
+func ServicePlanGUID(service string, plan string, q ServicePlanQuerier) (string, error) {
+	services, err := q.ListServicesByLabel(service)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not find service [%s]", service)
+	}
+	if len(services) == 0 {
+		return "", errors.Errorf("service [%s] is not in the marketplace", service)
+	}
+
+	for _, s := range services {
+		plans, err := q.ListServicePlansForService(s.GUID)
+		if err != nil {
+			return "", errors.Wrapf(err, "could not find plans for service [%s]", service)
+		}
+		for _, p := range plans {
+			if p.Name == plan {
+				return p.GUID, nil
+			}
+		}
+	}
+	return "", errors.Errorf("service [%s] has no plan [%s]", service, plan)
+}
@@ -0,0 +1,99 @@
+package cloudfoundry
+
+import (
+	"net/http"
+	"strings"
+
+	cfclient "github.com/cloudfoundry-community/go-cfclient"
+	"github.com/pkg/errors"
+)
+
+// ServiceInstance is a managed service instance in a space
+type ServiceInstance struct {
+	GUID      string `json:"guid"`
+	Name      string `json:"name"`
+	SpaceGUID string `json:"space_guid"`
+}
+
+// ServiceInstanceRequest describes a managed service instance to create in a
+// space, and the parameters to pass to its broker
+type ServiceInstanceRequest struct {
+	Name            string
+	SpaceGUID       string
+	ServicePlanGUID string
+	Params          map[string]interface{}
+}
+
+// ServiceInstanceQuery filters the service instances that are listed; the
+// service instances are not filtered by the fields that are empty
+type ServiceInstanceQuery struct {
+	Name      string
+	SpaceGUID string
+	OrgGUID   string
+}
+
+// ServiceInstanceQuerier is used to query a Cloud Controller API for service
+// instances
+type ServiceInstanceQuerier interface {
+	ListServiceInstances(query ServiceInstanceQuery) ([]ServiceInstance, error)
+}
+
+// ServiceInstanceCreator creates service instances
+type ServiceInstanceCreator interface {
+	CreateServiceInstance(req ServiceInstanceRequest) (ServiceInstance, error)
+}
+
+// ServiceInstanceDeleter deletes service instances
+type ServiceInstanceDeleter interface {
+	DeleteServiceInstance(guid string, recursive, async bool) error
+}
+
+// ServiceInstanceError describes a service instance in an org's template that
+// could not be created
+type ServiceInstanceError struct {
+	Space   string `json:"space"`
+	Name    string `json:"name"`
+	Service string `json:"service"`
+	Plan    string `json:"plan"`
+	Message string `json:"message"`
+}
+
+// serviceInstanceNameTakenCode is the Cloud Controller error code for
+// CF-ServiceInstanceNameTaken
+const serviceInstanceNameTakenCode = 60002
+
+// serviceInstanceNameTakenDetail begins the detail of the v3 error that
+// reports that a service instance with the requested name already exists in
+// the space
+const serviceInstanceNameTakenDetail = "The service instance name is taken"
+
+// IsServiceInstanceNameTakenError returns true when the error reports that a
+// service instance with the requested name already exists in the space
+func IsServiceInstanceNameTakenError(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case cfclient.CloudFoundryError:
+		return e.Code == serviceInstanceNameTakenCode
+	case V3Error:
+		return e.Code == serviceInstanceNameTakenCode ||
+			e.StatusCode == http.StatusUnprocessableEntity && strings.HasPrefix(e.Detail, serviceInstanceNameTakenDetail)
+	}
+	return false
+}
+
+// CreateServiceInstance creates the service instance described by the template
+// in the space, using the given service plan
+func CreateServiceInstance(t ServiceInstanceTemplate, spaceGUID string, servicePlanGUID string, a ServiceInstanceCreator) error {
+	instance, err := a.CreateServiceInstance(ServiceInstanceRequest{
+		Name:            t.Name,
+		SpaceGUID:       spaceGUID,
+		ServicePlanGUID: servicePlanGUID,
+		Params:          t.Params,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not create service instance with name [%s] and spaceGUID [%s]", t.Name, spaceGUID)
+	}
+	if instance.GUID == "" {
+		return errors.Errorf("could not create service instance with name [%s] and spaceGUID [%s]", t.Name, spaceGUID)
+	}
+	return nil
+}
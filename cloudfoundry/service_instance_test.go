@@ -0,0 +1,87 @@
+package cloudfoundry_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	cfclient "github.com/cloudfoundry-community/go-cfclient"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestCreateServiceInstance(t *testing.T) {
+	spec.Run(t, "CreateServiceInstance", testCreateServiceInstance, spec.Report(report.Terminal{}))
+}
+
+func testCreateServiceInstance(t *testing.T, when spec.G, it spec.S) {
+	var (
+		a        *cloudfoundryfakes.FakeAPI
+		template cloudfoundry.ServiceInstanceTemplate
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		a = &cloudfoundryfakes.FakeAPI{}
+		template = cloudfoundry.ServiceInstanceTemplate{
+			Name:    "config-server",
+			Service: "p-config-server",
+			Plan:    "standard",
+			Params:  map[string]interface{}{"count": 1},
+		}
+	})
+
+	it("creates the service instance in the space", func() {
+		a.CreateServiceInstanceReturns(cloudfoundry.ServiceInstance{GUID: "test-instance-guid"}, nil)
+		err := cloudfoundry.CreateServiceInstance(template, "test-space-guid", "test-plan-guid", a)
+		Expect(err).NotTo(HaveOccurred())
+		req := a.CreateServiceInstanceArgsForCall(0)
+		Expect(req.Name).To(Equal("config-server"))
+		Expect(req.SpaceGUID).To(Equal("test-space-guid"))
+		Expect(req.ServicePlanGUID).To(Equal("test-plan-guid"))
+		Expect(req.Params).To(HaveKeyWithValue("count", 1))
+	})
+
+	it("returns an error when the service instance cannot be created", func() {
+		a.CreateServiceInstanceReturns(cloudfoundry.ServiceInstance{}, errors.New("test error"))
+		err := cloudfoundry.CreateServiceInstance(template, "test-space-guid", "test-plan-guid", a)
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("returns an error when no service instance is returned", func() {
+		a.CreateServiceInstanceReturns(cloudfoundry.ServiceInstance{}, nil)
+		err := cloudfoundry.CreateServiceInstance(template, "test-space-guid", "test-plan-guid", a)
+		Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestIsServiceInstanceNameTakenError(t *testing.T) {
+	spec.Run(t, "IsServiceInstanceNameTakenError", testIsServiceInstanceNameTakenError, spec.Report(report.Terminal{}))
+}
+
+func testIsServiceInstanceNameTakenError(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("is true for a name taken error that has been wrapped", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.CreateServiceInstanceReturns(cloudfoundry.ServiceInstance{}, cfclient.CloudFoundryError{Code: 60002, ErrorCode: "CF-ServiceInstanceNameTaken"})
+		err := cloudfoundry.CreateServiceInstance(cloudfoundry.ServiceInstanceTemplate{Name: "db"}, "test-space-guid", "test-plan-guid", a)
+		Expect(cloudfoundry.IsServiceInstanceNameTakenError(err)).To(BeTrue())
+	})
+
+	it("is true for a v3 name taken error", func() {
+		err := cloudfoundry.V3Error{StatusCode: http.StatusUnprocessableEntity, Code: 10008, Title: "CF-UnprocessableEntity", Detail: "The service instance name is taken: db."}
+		Expect(cloudfoundry.IsServiceInstanceNameTakenError(err)).To(BeTrue())
+	})
+
+	it("is false for other errors", func() {
+		Expect(cloudfoundry.IsServiceInstanceNameTakenError(errors.New("test error"))).To(BeFalse())
+		Expect(cloudfoundry.IsServiceInstanceNameTakenError(cfclient.CloudFoundryError{Code: 40002})).To(BeFalse())
+		Expect(cloudfoundry.IsServiceInstanceNameTakenError(cloudfoundry.V3Error{StatusCode: http.StatusUnprocessableEntity, Detail: "Name must be unique per organization"})).To(BeFalse())
+	})
+}
@@ -0,0 +1,67 @@
+package cloudfoundry_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestServicePlanGUID(t *testing.T) {
+	spec.Run(t, "ServicePlanGUID", testServicePlanGUID, spec.Report(report.Terminal{}))
+}
+
+func testServicePlanGUID(t *testing.T, when spec.G, it spec.S) {
+	var a *cloudfoundryfakes.FakeAPI
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		a = &cloudfoundryfakes.FakeAPI{}
+		a.ListServicesByLabelReturns([]cloudfoundry.Service{
+			cloudfoundry.Service{GUID: "test-service-guid", Label: "p-mysql"},
+		}, nil)
+		a.ListServicePlansForServiceReturns([]cloudfoundry.ServicePlan{
+			cloudfoundry.ServicePlan{GUID: "large-plan-guid", Name: "large"},
+			cloudfoundry.ServicePlan{GUID: "small-plan-guid", Name: "small"},
+		}, nil)
+	})
+
+	it("returns the guid of the plan", func() {
+		guid, err := cloudfoundry.ServicePlanGUID("p-mysql", "small", a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(guid).To(Equal("small-plan-guid"))
+		Expect(a.ListServicesByLabelArgsForCall(0)).To(Equal("p-mysql"))
+		Expect(a.ListServicePlansForServiceArgsForCall(0)).To(Equal("test-service-guid"))
+	})
+
+	it("returns an error when the service is not in the marketplace", func() {
+		a.ListServicesByLabelReturns(nil, nil)
+		guid, err := cloudfoundry.ServicePlanGUID("p-mysql", "small", a)
+		Expect(err).To(HaveOccurred())
+		Expect(guid).To(BeEmpty())
+		Expect(a.ListServicePlansForServiceCallCount()).To(Equal(0))
+	})
+
+	it("returns an error when the service does not have the plan", func() {
+		guid, err := cloudfoundry.ServicePlanGUID("p-mysql", "medium", a)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("has no plan [medium]"))
+		Expect(guid).To(BeEmpty())
+	})
+
+	it("returns an error when the services cannot be listed", func() {
+		a.ListServicesByLabelReturns(nil, errors.New("test error"))
+		_, err := cloudfoundry.ServicePlanGUID("p-mysql", "small", a)
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("returns an error when the plans cannot be listed", func() {
+		a.ListServicePlansForServiceReturns(nil, errors.New("test error"))
+		_, err := cloudfoundry.ServicePlanGUID("p-mysql", "small", a)
+		Expect(err).To(HaveOccurred())
+	})
+}
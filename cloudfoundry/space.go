@@ -1,29 +1,114 @@
 package cloudfoundry
 
 import (
+	"net/http"
+
 	cfclient "github.com/cloudfoundry-community/go-cfclient"
 	"github.com/pkg/errors"
 )
 
+// Space is a space in an org
+type Space struct {
+	GUID    string `json:"guid"`
+	Name    string `json:"name"`
+	OrgGUID string `json:"organization_guid"`
+}
+
+// SpaceRequest describes a space to create in an org, and the users that are
+// granted roles in it
+type SpaceRequest struct {
+	Name           string
+	OrgGUID        string
+	SpaceQuotaGUID string
+	AllowSSH       bool
+	ManagerGUIDs   []string
+	DeveloperGUIDs []string
+	AuditorGUIDs   []string
+}
+
+// SpaceQuery filters the spaces that are listed; the spaces are not filtered
+// by the fields that are empty
+type SpaceQuery struct {
+	Name    string
+	OrgGUID string
+}
+
 // SpaceCreator creates spaces
 type SpaceCreator interface {
-	CreateSpace(req cfclient.SpaceRequest) (cfclient.Space, error)
+	CreateSpace(req SpaceRequest) (Space, error)
+}
+
+// SpaceQuerier is used to query a Cloud Controller API for spaces
+type SpaceQuerier interface {
+	ListSpaces(query SpaceQuery) ([]Space, error)
+}
+
+// SpaceDeleter deletes spaces
+type SpaceDeleter interface {
+	DeleteSpace(guid string, recursive, async bool) error
+}
+
+// spaceNameTakenCode is the Cloud Controller error code for
+// CF-SpaceNameTaken
+const spaceNameTakenCode = 40002
+
+// spaceNameTakenDetail is the detail of the v3 error that reports that a space
+// with the requested name already exists in the org
+const spaceNameTakenDetail = "Name must be unique per organization"
+
+// IsSpaceNameTakenError returns true when the error reports that a space with
+// the requested name already exists in the org
+func IsSpaceNameTakenError(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case cfclient.CloudFoundryError:
+		return e.Code == spaceNameTakenCode
+	case V3Error:
+		return e.StatusCode == http.StatusUnprocessableEntity && e.Detail == spaceNameTakenDetail
+	}
+	return false
+}
+
+// SpaceGUIDForName returns the GUID of the named space in the org
+func SpaceGUIDForName(name string, organizationID string, q SpaceQuerier) (string, error) {
+	spaces, err := q.ListSpaces(SpaceQuery{Name: name, OrgGUID: organizationID})
+	if err != nil {
+		return "", errors.Wrapf(err, "could not find space with name [%s] and organizationID [%s]", name, organizationID)
+	}
+	if len(spaces) == 0 {
+		return "", errors.Errorf("could not find space with name [%s] and organizationID [%s]", name, organizationID)
+	}
+	return spaces[0].GUID, nil
 }
 
 // CreateSpace creates a space with the given name
 // for the given user
 func CreateSpace(name string, organizationID string, userID string, a SpaceCreator) error {
-	req := cfclient.SpaceRequest{
-		Name:             name,
-		AuditorGuid:      []string{userID},
-		DeveloperGuid:    []string{userID},
-		ManagerGuid:      []string{userID},
-		OrganizationGuid: organizationID,
-		AllowSSH:         true,
+	return CreateSpaceFromTemplate(DefaultOrgTemplate(name).Spaces[0], organizationID, userID, "", a)
+}
+
+// CreateSpaceFromTemplate creates the space described by the template, grants
+// the user the template's roles in it, and assigns it the given space quota
+// (if any)
+func CreateSpaceFromTemplate(t SpaceTemplate, organizationID string, userID string, spaceQuotaID string, a SpaceCreator) error {
+	req := SpaceRequest{
+		Name:           t.Name,
+		OrgGUID:        organizationID,
+		SpaceQuotaGUID: spaceQuotaID,
+		AllowSSH:       t.AllowSSH,
+	}
+	for _, role := range t.Roles {
+		switch role {
+		case SpaceRoleManager:
+			req.ManagerGUIDs = []string{userID}
+		case SpaceRoleDeveloper:
+			req.DeveloperGUIDs = []string{userID}
+		case SpaceRoleAuditor:
+			req.AuditorGUIDs = []string{userID}
+		}
 	}
 	space, err := a.CreateSpace(req)
-	if err != nil || space.Guid == "" {
-		return errors.Wrapf(err, "could not create space with name [%s] and organizationID [%s]", name, organizationID)
+	if err != nil || space.GUID == "" {
+		return errors.Wrapf(err, "could not create space with name [%s] and organizationID [%s]", t.Name, organizationID)
 	}
 
 	return nil
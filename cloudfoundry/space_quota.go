@@ -0,0 +1,74 @@
+package cloudfoundry
+
+import (
+	"github.com/pkg/errors"
+)
+
+// SpaceQuota is a space quota in an org
+type SpaceQuota struct {
+	GUID    string `json:"guid"`
+	Name    string `json:"name"`
+	OrgGUID string `json:"organization_guid"`
+}
+
+// SpaceQuotaRequest describes a space quota to create in an org. A limit of
+// -1 is unlimited; the limits that ignition does not set are unlimited.
+type SpaceQuotaRequest struct {
+	Name                    string
+	OrgGUID                 string
+	NonBasicServicesAllowed bool
+	TotalServices           int
+	TotalRoutes             int
+	MemoryLimit             int
+	InstanceMemoryLimit     int
+	AppInstanceLimit        int
+}
+
+// SpaceQuotaQuery filters the space quotas that are listed; the space quotas
+// are not filtered by the fields that are empty
+type SpaceQuotaQuery struct {
+	Name    string
+	OrgGUID string
+}
+
+// SpaceQuotaCreator creates space quotas, and finds those that already exist
+type SpaceQuotaCreator interface {
+	CreateSpaceQuota(req SpaceQuotaRequest) (SpaceQuota, error)
+	ListSpaceQuotas(query SpaceQuotaQuery) ([]SpaceQuota, error)
+}
+
+// CreateSpaceQuota creates the space quota described by the template in the
+// org, and returns its ID. If the org already has a space quota with the same
+// name, its ID is returned instead.
+func CreateSpaceQuota(t SpaceQuotaTemplate, orgGUID string, a SpaceQuotaCreator) (string, error) {
+	quota, err := a.CreateSpaceQuota(SpaceQuotaRequest{
+		Name:                    t.Name,
+		OrgGUID:                 orgGUID,
+		NonBasicServicesAllowed: t.NonBasicServicesAllowed,
+		TotalServices:           t.TotalServices,
+		TotalRoutes:             t.TotalRoutes,
+		MemoryLimit:             t.MemoryLimit,
+		InstanceMemoryLimit:     t.InstanceMemoryLimit,
+		AppInstanceLimit:        t.AppInstanceLimit,
+	})
+	if err == nil && quota.GUID != "" {
+		return quota.GUID, nil
+	}
+	if IsTransientError(err) {
+		return "", errors.Wrapf(err, "could not create space quota [%s] in org [%s]", t.Name, orgGUID)
+	}
+
+	// the quota may already exist, e.g. when provisioning is retried
+	quotas, listErr := a.ListSpaceQuotas(SpaceQuotaQuery{Name: t.Name, OrgGUID: orgGUID})
+	if listErr == nil {
+		for _, q := range quotas {
+			if q.OrgGUID == orgGUID && q.Name == t.Name {
+				return q.GUID, nil
+			}
+		}
+	}
+	if err == nil {
+		err = errors.New("no space quota was returned")
+	}
+	return "", errors.Wrapf(err, "could not create space quota [%s] in org [%s]", t.Name, orgGUID)
+}
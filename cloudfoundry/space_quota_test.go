@@ -0,0 +1,78 @@
+package cloudfoundry_test
+
+import (
+	"errors"
+	"testing"
+
+	cfclient "github.com/cloudfoundry-community/go-cfclient"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestCreateSpaceQuota(t *testing.T) {
+	spec.Run(t, "CreateSpaceQuota", testCreateSpaceQuota, spec.Report(report.Terminal{}))
+}
+
+func testCreateSpaceQuota(t *testing.T, when spec.G, it spec.S) {
+	var (
+		a        *cloudfoundryfakes.FakeAPI
+		template cloudfoundry.SpaceQuotaTemplate
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		a = &cloudfoundryfakes.FakeAPI{}
+		template = cloudfoundry.SpaceQuotaTemplate{
+			Name:                "small",
+			MemoryLimit:         1024,
+			InstanceMemoryLimit: -1,
+			TotalRoutes:         10,
+			TotalServices:       5,
+			AppInstanceLimit:    -1,
+		}
+	})
+
+	it("creates the space quota in the org", func() {
+		a.CreateSpaceQuotaReturns(cloudfoundry.SpaceQuota{GUID: "test-quota-guid"}, nil)
+		id, err := cloudfoundry.CreateSpaceQuota(template, "test-org-guid", a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal("test-quota-guid"))
+		req := a.CreateSpaceQuotaArgsForCall(0)
+		Expect(req.Name).To(Equal("small"))
+		Expect(req.OrgGUID).To(Equal("test-org-guid"))
+		Expect(req.MemoryLimit).To(Equal(1024))
+		Expect(req.TotalRoutes).To(Equal(10))
+		Expect(req.TotalServices).To(Equal(5))
+		Expect(req.AppInstanceLimit).To(Equal(-1))
+	})
+
+	it("uses the existing space quota when it cannot be created", func() {
+		a.CreateSpaceQuotaReturns(cloudfoundry.SpaceQuota{}, cfclient.CloudFoundryError{Code: 310004})
+		a.ListSpaceQuotasReturns([]cloudfoundry.SpaceQuota{
+			cloudfoundry.SpaceQuota{GUID: "other-org-quota-guid", Name: "small", OrgGUID: "other-org-guid"},
+			cloudfoundry.SpaceQuota{GUID: "existing-quota-guid", Name: "small", OrgGUID: "test-org-guid"},
+		}, nil)
+		id, err := cloudfoundry.CreateSpaceQuota(template, "test-org-guid", a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal("existing-quota-guid"))
+		Expect(a.ListSpaceQuotasArgsForCall(0)).To(Equal(cloudfoundry.SpaceQuotaQuery{Name: "small", OrgGUID: "test-org-guid"}))
+	})
+
+	it("returns an error when the space quota cannot be created and does not exist", func() {
+		a.CreateSpaceQuotaReturns(cloudfoundry.SpaceQuota{}, errors.New("test error"))
+		id, err := cloudfoundry.CreateSpaceQuota(template, "test-org-guid", a)
+		Expect(err).To(HaveOccurred())
+		Expect(id).To(BeEmpty())
+	})
+
+	it("does not look for an existing space quota after a transient error", func() {
+		a.CreateSpaceQuotaReturns(cloudfoundry.SpaceQuota{}, cfclient.CloudFoundryHTTPError{StatusCode: 502})
+		_, err := cloudfoundry.CreateSpaceQuota(template, "test-org-guid", a)
+		Expect(err).To(HaveOccurred())
+		Expect(cloudfoundry.IsTransientError(err)).To(BeTrue())
+		Expect(a.ListSpaceQuotasCallCount()).To(Equal(0))
+	})
+}
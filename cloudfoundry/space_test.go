@@ -23,20 +23,122 @@ func testCreateSpace(t *testing.T, when spec.G, it spec.S) {
 
 	it("returns an error if the creator returns an error", func() {
 		a := &cloudfoundryfakes.FakeAPI{}
-		a.CreateSpaceReturns(cfclient.Space{}, errors.New("test error"))
+		a.CreateSpaceReturns(cloudfoundry.Space{}, errors.New("test error"))
 		err := cloudfoundry.CreateSpace("test-space", "test-organization-id", "test-user-id", a)
 		Expect(err).To(HaveOccurred())
 	})
 
 	it("returns the space if it is created successfully", func() {
 		a := &cloudfoundryfakes.FakeAPI{}
-		a.CreateSpaceReturns(cfclient.Space{
-			Guid:      "test-space-guid",
-			Name:      "test-space",
-			CreatedAt: "created-at",
-			UpdatedAt: "updated-at",
+		a.CreateSpaceReturns(cloudfoundry.Space{
+			GUID:    "test-space-guid",
+			Name:    "test-space",
+			OrgGUID: "test-organization-id",
 		}, nil)
 		err := cloudfoundry.CreateSpace("test-space", "test-organization-id", "test-user-id", a)
 		Expect(err).NotTo(HaveOccurred())
 	})
 }
+
+func TestCreateSpaceFromTemplate(t *testing.T) {
+	spec.Run(t, "CreateSpaceFromTemplate", testCreateSpaceFromTemplate, spec.Report(report.Terminal{}))
+}
+
+func testCreateSpaceFromTemplate(t *testing.T, when spec.G, it spec.S) {
+	var a *cloudfoundryfakes.FakeAPI
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		a = &cloudfoundryfakes.FakeAPI{}
+		a.CreateSpaceReturns(cloudfoundry.Space{GUID: "test-space-guid"}, nil)
+	})
+
+	it("grants every role and allows ssh for the default template", func() {
+		err := cloudfoundry.CreateSpace("test-space", "test-organization-id", "test-user-id", a)
+		Expect(err).NotTo(HaveOccurred())
+		req := a.CreateSpaceArgsForCall(0)
+		Expect(req.ManagerGUIDs).To(Equal([]string{"test-user-id"}))
+		Expect(req.DeveloperGUIDs).To(Equal([]string{"test-user-id"}))
+		Expect(req.AuditorGUIDs).To(Equal([]string{"test-user-id"}))
+		Expect(req.AllowSSH).To(BeTrue())
+		Expect(req.SpaceQuotaGUID).To(BeEmpty())
+	})
+
+	it("only grants the roles in the template", func() {
+		template := cloudfoundry.SpaceTemplate{
+			Name:  "test",
+			Roles: []string{cloudfoundry.SpaceRoleAuditor},
+		}
+		err := cloudfoundry.CreateSpaceFromTemplate(template, "test-organization-id", "test-user-id", "test-quota-id", a)
+		Expect(err).NotTo(HaveOccurred())
+		req := a.CreateSpaceArgsForCall(0)
+		Expect(req.Name).To(Equal("test"))
+		Expect(req.OrgGUID).To(Equal("test-organization-id"))
+		Expect(req.ManagerGUIDs).To(BeEmpty())
+		Expect(req.DeveloperGUIDs).To(BeEmpty())
+		Expect(req.AuditorGUIDs).To(Equal([]string{"test-user-id"}))
+		Expect(req.AllowSSH).To(BeFalse())
+		Expect(req.SpaceQuotaGUID).To(Equal("test-quota-id"))
+	})
+}
+
+func TestIsSpaceNameTakenError(t *testing.T) {
+	spec.Run(t, "IsSpaceNameTakenError", testIsSpaceNameTakenError, spec.Report(report.Terminal{}))
+}
+
+func testIsSpaceNameTakenError(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("is true for a name taken error that has been wrapped", func() {
+		a := &cloudfoundryfakes.FakeAPI{}
+		a.CreateSpaceReturns(cloudfoundry.Space{}, cfclient.CloudFoundryError{Code: 40002, ErrorCode: "CF-SpaceNameTaken"})
+		err := cloudfoundry.CreateSpace("test-space", "test-organization-id", "test-user-id", a)
+		Expect(cloudfoundry.IsSpaceNameTakenError(err)).To(BeTrue())
+	})
+
+	it("is true for a v3 name taken error", func() {
+		err := cloudfoundry.V3Error{StatusCode: 422, Code: 10008, Title: "CF-UnprocessableEntity", Detail: "Name must be unique per organization"}
+		Expect(cloudfoundry.IsSpaceNameTakenError(err)).To(BeTrue())
+	})
+
+	it("is false for other errors", func() {
+		Expect(cloudfoundry.IsSpaceNameTakenError(errors.New("test error"))).To(BeFalse())
+		Expect(cloudfoundry.IsSpaceNameTakenError(cfclient.CloudFoundryError{Code: 30002})).To(BeFalse())
+		Expect(cloudfoundry.IsSpaceNameTakenError(cloudfoundry.V3Error{StatusCode: 422, Detail: "Name can't be blank"})).To(BeFalse())
+	})
+}
+
+func TestSpaceGUIDForName(t *testing.T) {
+	spec.Run(t, "SpaceGUIDForName", testSpaceGUIDForName, spec.Report(report.Terminal{}))
+}
+
+func testSpaceGUIDForName(t *testing.T, when spec.G, it spec.S) {
+	var a *cloudfoundryfakes.FakeAPI
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		a = &cloudfoundryfakes.FakeAPI{}
+	})
+
+	it("returns the guid of the space in the org", func() {
+		a.ListSpacesReturns([]cloudfoundry.Space{cloudfoundry.Space{GUID: "test-space-guid"}}, nil)
+		guid, err := cloudfoundry.SpaceGUIDForName("playground", "test-org-guid", a)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(guid).To(Equal("test-space-guid"))
+		Expect(a.ListSpacesArgsForCall(0)).To(Equal(cloudfoundry.SpaceQuery{Name: "playground", OrgGUID: "test-org-guid"}))
+	})
+
+	it("returns an error when the space does not exist", func() {
+		guid, err := cloudfoundry.SpaceGUIDForName("playground", "test-org-guid", a)
+		Expect(err).To(HaveOccurred())
+		Expect(guid).To(BeEmpty())
+	})
+
+	it("returns an error when the spaces cannot be listed", func() {
+		a.ListSpacesReturns(nil, errors.New("test error"))
+		_, err := cloudfoundry.SpaceGUIDForName("playground", "test-org-guid", a)
+		Expect(err).To(HaveOccurred())
+	})
+}
@@ -0,0 +1,73 @@
+package cloudfoundry
+
+import (
+	"github.com/pkg/errors"
+)
+
+// OrganizationTearDowner can delete an org and everything within it
+type OrganizationTearDowner interface {
+	OrganizationDeleter
+	SpaceQuerier
+	SpaceDeleter
+	AppQuerier
+	AppDeleter
+	ServiceInstanceQuerier
+	ServiceInstanceDeleter
+	RouteQuerier
+	RouteDeleter
+}
+
+// TearDownOrg deletes the apps, service instances, routes, and spaces in the
+// org with the given ID, and then deletes the org itself. Each deletion is
+// synchronous so that the org name is free to be reused once it returns.
+func TearDownOrg(orgGUID string, a OrganizationTearDowner) error {
+	apps, err := a.ListApps(AppQuery{OrgGUID: orgGUID})
+	if err != nil {
+		return errors.Wrapf(err, "could not list apps for org [%s]", orgGUID)
+	}
+	for _, app := range apps {
+		err = a.DeleteApp(app.GUID)
+		if err != nil {
+			return errors.Wrapf(err, "could not delete app [%s]", app.Name)
+		}
+	}
+
+	instances, err := a.ListServiceInstances(ServiceInstanceQuery{OrgGUID: orgGUID})
+	if err != nil {
+		return errors.Wrapf(err, "could not list service instances for org [%s]", orgGUID)
+	}
+	for _, instance := range instances {
+		err = a.DeleteServiceInstance(instance.GUID, true, false)
+		if err != nil {
+			return errors.Wrapf(err, "could not delete service instance [%s]", instance.Name)
+		}
+	}
+
+	routes, err := a.ListRoutes(RouteQuery{OrgGUID: orgGUID})
+	if err != nil {
+		return errors.Wrapf(err, "could not list routes for org [%s]", orgGUID)
+	}
+	for _, route := range routes {
+		err = a.DeleteRoute(route.GUID)
+		if err != nil {
+			return errors.Wrapf(err, "could not delete route [%s]", route.Host)
+		}
+	}
+
+	spaces, err := a.ListSpaces(SpaceQuery{OrgGUID: orgGUID})
+	if err != nil {
+		return errors.Wrapf(err, "could not list spaces for org [%s]", orgGUID)
+	}
+	for _, space := range spaces {
+		err = a.DeleteSpace(space.GUID, true, false)
+		if err != nil {
+			return errors.Wrapf(err, "could not delete space [%s]", space.Name)
+		}
+	}
+
+	err = a.DeleteOrg(orgGUID, true, false)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete org [%s]", orgGUID)
+	}
+	return nil
+}
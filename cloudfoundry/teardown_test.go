@@ -0,0 +1,117 @@
+package cloudfoundry_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestTearDownOrg(t *testing.T) {
+	spec.Run(t, "TearDownOrg", testTearDownOrg, spec.Report(report.Terminal{}))
+}
+
+func testTearDownOrg(t *testing.T, when spec.G, it spec.S) {
+	var f *cloudfoundryfakes.FakeAPI
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		f = &cloudfoundryfakes.FakeAPI{}
+		f.ListAppsReturns([]cloudfoundry.App{
+			cloudfoundry.App{GUID: "test-app-1"},
+			cloudfoundry.App{GUID: "test-app-2"},
+		}, nil)
+		f.ListServiceInstancesReturns([]cloudfoundry.ServiceInstance{
+			cloudfoundry.ServiceInstance{GUID: "test-instance"},
+		}, nil)
+		f.ListRoutesReturns([]cloudfoundry.Route{
+			cloudfoundry.Route{GUID: "test-route"},
+		}, nil)
+		f.ListSpacesReturns([]cloudfoundry.Space{
+			cloudfoundry.Space{GUID: "test-space"},
+		}, nil)
+	})
+
+	it("deletes everything in the org and then the org", func() {
+		err := cloudfoundry.TearDownOrg("test-org-guid", f)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(f.ListAppsCallCount()).To(Equal(1))
+		Expect(f.ListAppsArgsForCall(0)).To(Equal(cloudfoundry.AppQuery{OrgGUID: "test-org-guid"}))
+		Expect(f.DeleteAppCallCount()).To(Equal(2))
+		Expect(f.DeleteAppArgsForCall(0)).To(Equal("test-app-1"))
+		Expect(f.DeleteAppArgsForCall(1)).To(Equal("test-app-2"))
+
+		Expect(f.ListServiceInstancesArgsForCall(0)).To(Equal(cloudfoundry.ServiceInstanceQuery{OrgGUID: "test-org-guid"}))
+		Expect(f.DeleteServiceInstanceCallCount()).To(Equal(1))
+		guid, recursive, async := f.DeleteServiceInstanceArgsForCall(0)
+		Expect(guid).To(Equal("test-instance"))
+		Expect(recursive).To(BeTrue())
+		Expect(async).To(BeFalse())
+
+		Expect(f.ListRoutesArgsForCall(0)).To(Equal(cloudfoundry.RouteQuery{OrgGUID: "test-org-guid"}))
+		Expect(f.DeleteRouteCallCount()).To(Equal(1))
+		Expect(f.DeleteRouteArgsForCall(0)).To(Equal("test-route"))
+
+		Expect(f.ListSpacesArgsForCall(0)).To(Equal(cloudfoundry.SpaceQuery{OrgGUID: "test-org-guid"}))
+		Expect(f.DeleteSpaceCallCount()).To(Equal(1))
+		guid, recursive, async = f.DeleteSpaceArgsForCall(0)
+		Expect(guid).To(Equal("test-space"))
+		Expect(recursive).To(BeTrue())
+		Expect(async).To(BeFalse())
+
+		Expect(f.DeleteOrgCallCount()).To(Equal(1))
+		guid, recursive, async = f.DeleteOrgArgsForCall(0)
+		Expect(guid).To(Equal("test-org-guid"))
+		Expect(recursive).To(BeTrue())
+		Expect(async).To(BeFalse())
+	})
+
+	it("errors when the apps cannot be listed", func() {
+		f.ListAppsReturns(nil, errors.New("test error"))
+		err := cloudfoundry.TearDownOrg("test-org-guid", f)
+		Expect(err).To(HaveOccurred())
+		Expect(f.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	it("errors when an app cannot be deleted", func() {
+		f.DeleteAppReturns(errors.New("test error"))
+		err := cloudfoundry.TearDownOrg("test-org-guid", f)
+		Expect(err).To(HaveOccurred())
+		Expect(f.DeleteAppCallCount()).To(Equal(1))
+		Expect(f.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	it("errors when a service instance cannot be deleted", func() {
+		f.DeleteServiceInstanceReturns(errors.New("test error"))
+		err := cloudfoundry.TearDownOrg("test-org-guid", f)
+		Expect(err).To(HaveOccurred())
+		Expect(f.DeleteRouteCallCount()).To(Equal(0))
+		Expect(f.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	it("errors when a route cannot be deleted", func() {
+		f.DeleteRouteReturns(errors.New("test error"))
+		err := cloudfoundry.TearDownOrg("test-org-guid", f)
+		Expect(err).To(HaveOccurred())
+		Expect(f.DeleteSpaceCallCount()).To(Equal(0))
+		Expect(f.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	it("errors when a space cannot be deleted", func() {
+		f.DeleteSpaceReturns(errors.New("test error"))
+		err := cloudfoundry.TearDownOrg("test-org-guid", f)
+		Expect(err).To(HaveOccurred())
+		Expect(f.DeleteOrgCallCount()).To(Equal(0))
+	})
+
+	it("errors when the org cannot be deleted", func() {
+		f.DeleteOrgReturns(errors.New("test error"))
+		err := cloudfoundry.TearDownOrg("test-org-guid", f)
+		Expect(err).To(HaveOccurred())
+	})
+}
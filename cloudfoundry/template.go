@@ -0,0 +1,196 @@
+package cloudfoundry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The space roles that can be granted to the user
+const (
+	SpaceRoleManager   = "manager"
+	SpaceRoleDeveloper = "developer"
+	SpaceRoleAuditor   = "auditor"
+)
+
+// unlimited is the value the Cloud Controller uses for a limit that is not
+// enforced
+const unlimited = -1
+
+// OrgTemplate describes what is created in a new org
+type OrgTemplate struct {
+	SpaceQuotas []SpaceQuotaTemplate `json:"space_quotas,omitempty"`
+	Spaces      []SpaceTemplate      `json:"spaces"`
+}
+
+// SpaceTemplate describes a space to create in a new org, the roles in that
+// space that are granted to the user, the application security groups bound
+// to it, and the marketplace service instances to create in it. When
+// unmarshalled from JSON, Roles defaults to all roles and AllowSSH defaults to
+// true.
+type SpaceTemplate struct {
+	Name                  string                    `json:"name"`
+	Roles                 []string                  `json:"roles"`
+	AllowSSH              bool                      `json:"allow_ssh"`
+	Quota                 string                    `json:"quota,omitempty"`
+	RunningSecurityGroups []string                  `json:"running_security_groups,omitempty"`
+	StagingSecurityGroups []string                  `json:"staging_security_groups,omitempty"`
+	Services              []ServiceInstanceTemplate `json:"services,omitempty"`
+}
+
+// SecurityGroups returns the name of every security group bound to the spaces
+// in the template
+func (t OrgTemplate) SecurityGroups() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, s := range t.Spaces {
+		for _, name := range append(append([]string(nil), s.RunningSecurityGroups...), s.StagingSecurityGroups...) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ServiceInstanceTemplate describes a service instance to create from a plan
+// of a marketplace service, and the parameters to pass to its broker
+type ServiceInstanceTemplate struct {
+	Name    string                 `json:"name"`
+	Service string                 `json:"service"`
+	Plan    string                 `json:"plan"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// SpaceQuotaTemplate describes a space quota to create in a new org. When
+// unmarshalled from JSON, limits that are not specified are unlimited.
+type SpaceQuotaTemplate struct {
+	Name                    string `json:"name"`
+	NonBasicServicesAllowed bool   `json:"non_basic_services_allowed"`
+	TotalServices           int    `json:"total_services"`
+	TotalRoutes             int    `json:"total_routes"`
+	MemoryLimit             int    `json:"memory_limit"`
+	InstanceMemoryLimit     int    `json:"instance_memory_limit"`
+	AppInstanceLimit        int    `json:"app_instance_limit"`
+}
+
+// DefaultOrgTemplate is a single space with the given name, in which the user
+// has every role and SSH is allowed
+func DefaultOrgTemplate(spaceName string) OrgTemplate {
+	return OrgTemplate{
+		Spaces: []SpaceTemplate{
+			SpaceTemplate{
+				Name:     spaceName,
+				Roles:    allSpaceRoles(),
+				AllowSSH: true,
+			},
+		},
+	}
+}
+
+func allSpaceRoles() []string {
+	return []string{SpaceRoleManager, SpaceRoleDeveloper, SpaceRoleAuditor}
+}
+
+// UnmarshalJSON applies the defaults for a SpaceTemplate
+func (s *SpaceTemplate) UnmarshalJSON(b []byte) error {
+	type plain SpaceTemplate
+	p := plain{
+		Roles:    allSpaceRoles(),
+		AllowSSH: true,
+	}
+	err := json.Unmarshal(b, &p)
+	if err != nil {
+		return err
+	}
+	*s = SpaceTemplate(p)
+	return nil
+}
+
+// UnmarshalJSON applies the defaults for a SpaceQuotaTemplate
+func (q *SpaceQuotaTemplate) UnmarshalJSON(b []byte) error {
+	type plain SpaceQuotaTemplate
+	p := plain{
+		TotalServices:       unlimited,
+		TotalRoutes:         unlimited,
+		MemoryLimit:         unlimited,
+		InstanceMemoryLimit: unlimited,
+		AppInstanceLimit:    unlimited,
+	}
+	err := json.Unmarshal(b, &p)
+	if err != nil {
+		return err
+	}
+	*q = SpaceQuotaTemplate(p)
+	return nil
+}
+
+// Validate reports every problem with the template at once
+func (t OrgTemplate) Validate() error {
+	var problems []string
+	quotas := make(map[string]bool)
+	for i, q := range t.SpaceQuotas {
+		name := strings.TrimSpace(q.Name)
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("space quota %d has no name", i))
+			continue
+		}
+		if quotas[name] {
+			problems = append(problems, fmt.Sprintf("space quota [%s] is defined more than once", name))
+		}
+		quotas[name] = true
+	}
+
+	if len(t.Spaces) == 0 {
+		problems = append(problems, "at least one space is required")
+	}
+	spaces := make(map[string]bool)
+	for i, s := range t.Spaces {
+		name := strings.TrimSpace(s.Name)
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("space %d has no name", i))
+			continue
+		}
+		if spaces[name] {
+			problems = append(problems, fmt.Sprintf("space [%s] is defined more than once", name))
+		}
+		spaces[name] = true
+		for _, role := range s.Roles {
+			switch role {
+			case SpaceRoleManager, SpaceRoleDeveloper, SpaceRoleAuditor:
+			default:
+				problems = append(problems, fmt.Sprintf("space [%s] has unknown role [%s]", name, role))
+			}
+		}
+		if s.Quota != "" && !quotas[s.Quota] {
+			problems = append(problems, fmt.Sprintf("space [%s] uses undefined space quota [%s]", name, s.Quota))
+		}
+		for _, group := range append(append([]string(nil), s.RunningSecurityGroups...), s.StagingSecurityGroups...) {
+			if strings.TrimSpace(group) == "" {
+				problems = append(problems, fmt.Sprintf("space [%s] has a security group with no name", name))
+			}
+		}
+		instances := make(map[string]bool)
+		for j, si := range s.Services {
+			instance := strings.TrimSpace(si.Name)
+			if instance == "" {
+				problems = append(problems, fmt.Sprintf("service instance %d in space [%s] has no name", j, name))
+				continue
+			}
+			if instances[instance] {
+				problems = append(problems, fmt.Sprintf("service instance [%s] in space [%s] is defined more than once", instance, name))
+			}
+			instances[instance] = true
+			if strings.TrimSpace(si.Service) == "" || strings.TrimSpace(si.Plan) == "" {
+				problems = append(problems, fmt.Sprintf("service instance [%s] in space [%s] needs a service and a plan", instance, name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid org template: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
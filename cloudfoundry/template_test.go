@@ -0,0 +1,149 @@
+package cloudfoundry_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestOrgTemplate(t *testing.T) {
+	spec.Run(t, "OrgTemplate", testOrgTemplate, spec.Report(report.Terminal{}))
+}
+
+func testOrgTemplate(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("defaults to a single space with every role and ssh allowed", func() {
+		template := cloudfoundry.DefaultOrgTemplate("playground")
+		Expect(template.Spaces).To(HaveLen(1))
+		Expect(template.Spaces[0].Name).To(Equal("playground"))
+		Expect(template.Spaces[0].Roles).To(ConsistOf("manager", "developer", "auditor"))
+		Expect(template.Spaces[0].AllowSSH).To(BeTrue())
+		Expect(template.Validate()).To(Succeed())
+	})
+
+	it("applies defaults when unmarshalling", func() {
+		var template cloudfoundry.OrgTemplate
+		err := json.Unmarshal([]byte(`{
+			"space_quotas": [{"name": "small", "memory_limit": 1024}],
+			"spaces": [
+				{"name": "dev"},
+				{"name": "test", "roles": ["auditor"], "allow_ssh": false, "quota": "small"}
+			]
+		}`), &template)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(template.Validate()).To(Succeed())
+
+		Expect(template.SpaceQuotas).To(HaveLen(1))
+		Expect(template.SpaceQuotas[0].MemoryLimit).To(Equal(1024))
+		Expect(template.SpaceQuotas[0].TotalRoutes).To(Equal(-1))
+		Expect(template.SpaceQuotas[0].NonBasicServicesAllowed).To(BeFalse())
+
+		Expect(template.Spaces).To(HaveLen(2))
+		Expect(template.Spaces[0].Roles).To(ConsistOf("manager", "developer", "auditor"))
+		Expect(template.Spaces[0].AllowSSH).To(BeTrue())
+		Expect(template.Spaces[1].Roles).To(Equal([]string{"auditor"}))
+		Expect(template.Spaces[1].AllowSSH).To(BeFalse())
+		Expect(template.Spaces[1].Quota).To(Equal("small"))
+	})
+
+	it("reports every problem with an invalid template", func() {
+		template := cloudfoundry.OrgTemplate{
+			SpaceQuotas: []cloudfoundry.SpaceQuotaTemplate{
+				cloudfoundry.SpaceQuotaTemplate{Name: "small"},
+				cloudfoundry.SpaceQuotaTemplate{Name: "small"},
+			},
+			Spaces: []cloudfoundry.SpaceTemplate{
+				cloudfoundry.SpaceTemplate{Name: "dev", Roles: []string{"owner"}},
+				cloudfoundry.SpaceTemplate{Name: "dev"},
+				cloudfoundry.SpaceTemplate{Name: ""},
+				cloudfoundry.SpaceTemplate{Name: "test", Quota: "large"},
+			},
+		}
+		err := template.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("space quota [small] is defined more than once"))
+		Expect(err.Error()).To(ContainSubstring("space [dev] has unknown role [owner]"))
+		Expect(err.Error()).To(ContainSubstring("space [dev] is defined more than once"))
+		Expect(err.Error()).To(ContainSubstring("space 2 has no name"))
+		Expect(err.Error()).To(ContainSubstring("space [test] uses undefined space quota [large]"))
+	})
+
+	it("unmarshals the service instances in a space", func() {
+		var template cloudfoundry.OrgTemplate
+		err := json.Unmarshal([]byte(`{
+			"spaces": [{
+				"name": "playground",
+				"services": [
+					{"name": "db", "service": "p-mysql", "plan": "small"},
+					{"name": "config-server", "service": "p-config-server", "plan": "standard", "params": {"count": 1}}
+				]
+			}]
+		}`), &template)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(template.Validate()).To(Succeed())
+		Expect(template.Spaces[0].Services).To(HaveLen(2))
+		Expect(template.Spaces[0].Services[0]).To(Equal(cloudfoundry.ServiceInstanceTemplate{Name: "db", Service: "p-mysql", Plan: "small"}))
+		Expect(template.Spaces[0].Services[1].Params).To(HaveKeyWithValue("count", BeNumerically("==", 1)))
+	})
+
+	it("reports every problem with the service instances in a space", func() {
+		template := cloudfoundry.OrgTemplate{
+			Spaces: []cloudfoundry.SpaceTemplate{
+				cloudfoundry.SpaceTemplate{
+					Name: "playground",
+					Services: []cloudfoundry.ServiceInstanceTemplate{
+						cloudfoundry.ServiceInstanceTemplate{Name: "db", Service: "p-mysql", Plan: "small"},
+						cloudfoundry.ServiceInstanceTemplate{Name: "db", Service: "p-mysql", Plan: "small"},
+						cloudfoundry.ServiceInstanceTemplate{Service: "p-mysql", Plan: "small"},
+						cloudfoundry.ServiceInstanceTemplate{Name: "cache", Service: "p-redis"},
+					},
+				},
+			},
+		}
+		err := template.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("service instance [db] in space [playground] is defined more than once"))
+		Expect(err.Error()).To(ContainSubstring("service instance 2 in space [playground] has no name"))
+		Expect(err.Error()).To(ContainSubstring("service instance [cache] in space [playground] needs a service and a plan"))
+	})
+
+	it("lists each security group bound to its spaces once", func() {
+		template := cloudfoundry.OrgTemplate{
+			Spaces: []cloudfoundry.SpaceTemplate{
+				cloudfoundry.SpaceTemplate{
+					Name:                  "dev",
+					RunningSecurityGroups: []string{"artifacts", "internal-dns"},
+					StagingSecurityGroups: []string{"artifacts"},
+				},
+				cloudfoundry.SpaceTemplate{
+					Name:                  "test",
+					StagingSecurityGroups: []string{"proxy"},
+				},
+			},
+		}
+		Expect(template.SecurityGroups()).To(Equal([]string{"artifacts", "internal-dns", "proxy"}))
+		Expect(cloudfoundry.DefaultOrgTemplate("playground").SecurityGroups()).To(BeEmpty())
+	})
+
+	it("requires security groups to be named", func() {
+		template := cloudfoundry.OrgTemplate{
+			Spaces: []cloudfoundry.SpaceTemplate{
+				cloudfoundry.SpaceTemplate{Name: "dev", RunningSecurityGroups: []string{" "}},
+			},
+		}
+		err := template.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("space [dev] has a security group with no name"))
+	})
+
+	it("requires at least one space", func() {
+		Expect(cloudfoundry.OrgTemplate{}.Validate()).NotTo(Succeed())
+	})
+}
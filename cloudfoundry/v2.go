@@ -0,0 +1,335 @@
+package cloudfoundry
+
+import (
+	"fmt"
+	"net/url"
+
+	cfclient "github.com/cloudfoundry-community/go-cfclient"
+	"github.com/pkg/errors"
+)
+
+// V2Client is an API that uses the v2 Cloud Controller API. It adapts the
+// client's v2 types to those of the API; the client's methods are only used
+// directly for the deletions and bindings whose arguments are already the
+// same.
+type V2Client struct {
+	*cfclient.Client
+}
+
+// ListOrgs lists the orgs that match the query
+func (c *V2Client) ListOrgs(query OrgQuery) ([]Organization, error) {
+	q := url.Values{}
+	if query.Name != "" {
+		q.Add("q", fmt.Sprintf("name:%s", query.Name))
+	}
+	if query.UserGUID != "" {
+		q.Add("q", fmt.Sprintf("user_guid:%s", query.UserGUID))
+	}
+	if query.QuotaGUID != "" {
+		q.Add("q", fmt.Sprintf("quota_definition_guid:%s", query.QuotaGUID))
+	}
+	orgs, err := c.Client.ListOrgsByQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Organization, len(orgs))
+	for i := range orgs {
+		result[i] = convertOrg(orgs[i])
+	}
+	return result, nil
+}
+
+// CreateOrg creates an org with the quota
+func (c *V2Client) CreateOrg(name, quotaGUID string) (Organization, error) {
+	org, err := c.Client.CreateOrg(cfclient.OrgRequest{
+		Name:                name,
+		QuotaDefinitionGuid: quotaGUID,
+	})
+	if err != nil {
+		return Organization{}, err
+	}
+	return convertOrg(org), nil
+}
+
+// SetOrgDefaultIsolationSegment makes the isolation segment the default for
+// the org; the org must already be able to use it
+func (c *V2Client) SetOrgDefaultIsolationSegment(orgGUID, isolationSegmentGUID string) error {
+	// the v2 API replaces the org's name, so it is sent unchanged
+	org, err := c.Client.GetOrgByGuid(orgGUID)
+	if err != nil {
+		return errors.Wrapf(err, "could not get org [%s]", orgGUID)
+	}
+	_, err = c.Client.UpdateOrg(orgGUID, cfclient.OrgRequest{
+		Name:                        org.Name,
+		QuotaDefinitionGuid:         org.QuotaDefinitionGuid,
+		DefaultIsolationSegmentGuid: isolationSegmentGUID,
+	})
+	return err
+}
+
+// ListOrgManagers lists the users that manage the org
+func (c *V2Client) ListOrgManagers(orgGUID string) ([]User, error) {
+	managers, err := c.Client.ListOrgManagers(orgGUID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]User, len(managers))
+	for i := range managers {
+		result[i] = User{GUID: managers[i].Guid, Username: managers[i].Username}
+	}
+	return result, nil
+}
+
+// AssociateOrgUser makes the user a member of the org
+func (c *V2Client) AssociateOrgUser(orgGUID, userGUID string) error {
+	_, err := c.Client.AssociateOrgUser(orgGUID, userGUID)
+	return err
+}
+
+// AssociateOrgAuditor makes the user an auditor of the org
+func (c *V2Client) AssociateOrgAuditor(orgGUID, userGUID string) error {
+	_, err := c.Client.AssociateOrgAuditor(orgGUID, userGUID)
+	return err
+}
+
+// AssociateOrgManager makes the user a manager of the org
+func (c *V2Client) AssociateOrgManager(orgGUID, userGUID string) error {
+	_, err := c.Client.AssociateOrgManager(orgGUID, userGUID)
+	return err
+}
+
+// CreateSpace creates the space, granting the users their roles in it
+func (c *V2Client) CreateSpace(req SpaceRequest) (Space, error) {
+	space, err := c.Client.CreateSpace(cfclient.SpaceRequest{
+		Name:              req.Name,
+		OrganizationGuid:  req.OrgGUID,
+		SpaceQuotaDefGuid: req.SpaceQuotaGUID,
+		AllowSSH:          req.AllowSSH,
+		ManagerGuid:       req.ManagerGUIDs,
+		DeveloperGuid:     req.DeveloperGUIDs,
+		AuditorGuid:       req.AuditorGUIDs,
+	})
+	if err != nil {
+		return Space{}, err
+	}
+	return convertSpace(space), nil
+}
+
+// ListSpaces lists the spaces that match the query
+func (c *V2Client) ListSpaces(query SpaceQuery) ([]Space, error) {
+	q := url.Values{}
+	if query.Name != "" {
+		q.Add("q", fmt.Sprintf("name:%s", query.Name))
+	}
+	if query.OrgGUID != "" {
+		q.Add("q", fmt.Sprintf("organization_guid:%s", query.OrgGUID))
+	}
+	spaces, err := c.Client.ListSpacesByQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Space, len(spaces))
+	for i := range spaces {
+		result[i] = convertSpace(spaces[i])
+	}
+	return result, nil
+}
+
+// GetOrgQuotaByName gets the org quota with the name
+func (c *V2Client) GetOrgQuotaByName(name string) (Quota, error) {
+	quota, err := c.Client.GetOrgQuotaByName(name)
+	if err != nil {
+		return Quota{}, err
+	}
+	return Quota{GUID: quota.Guid, Name: quota.Name}, nil
+}
+
+// ListIsolationSegmentsByName lists the isolation segments with the name
+func (c *V2Client) ListIsolationSegmentsByName(name string) ([]IsolationSegment, error) {
+	q := url.Values{}
+	q.Set("names", name)
+	segments, err := c.Client.ListIsolationSegmentsByQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]IsolationSegment, len(segments))
+	for i := range segments {
+		result[i] = IsolationSegment{GUID: segments[i].GUID, Name: segments[i].Name}
+	}
+	return result, nil
+}
+
+// ListApps lists the apps that match the query
+func (c *V2Client) ListApps(query AppQuery) ([]App, error) {
+	q := url.Values{}
+	if query.OrgGUID != "" {
+		q.Add("q", fmt.Sprintf("organization_guid:%s", query.OrgGUID))
+	}
+	apps, err := c.Client.ListAppsByQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]App, len(apps))
+	for i, a := range apps {
+		result[i] = App{
+			GUID:             a.Guid,
+			Name:             a.Name,
+			SpaceGUID:        a.SpaceGuid,
+			State:            a.State,
+			CreatedAt:        a.CreatedAt,
+			UpdatedAt:        a.UpdatedAt,
+			PackageUpdatedAt: a.PackageUpdatedAt,
+		}
+	}
+	return result, nil
+}
+
+// ListRoutes lists the routes that match the query
+func (c *V2Client) ListRoutes(query RouteQuery) ([]Route, error) {
+	q := url.Values{}
+	if query.OrgGUID != "" {
+		q.Add("q", fmt.Sprintf("organization_guid:%s", query.OrgGUID))
+	}
+	routes, err := c.Client.ListRoutesByQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Route, len(routes))
+	for i, r := range routes {
+		result[i] = Route{GUID: r.Guid, Host: r.Host, SpaceGUID: r.SpaceGuid}
+	}
+	return result, nil
+}
+
+// ListServicesByLabel lists the marketplace services with the label
+func (c *V2Client) ListServicesByLabel(label string) ([]Service, error) {
+	services, err := c.Client.ListServicesByQuery(url.Values{"q": {fmt.Sprintf("label:%s", label)}})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Service, len(services))
+	for i, s := range services {
+		result[i] = Service{GUID: s.Guid, Label: s.Label}
+	}
+	return result, nil
+}
+
+// ListServicePlansForService lists the plans of the marketplace service
+func (c *V2Client) ListServicePlansForService(serviceGUID string) ([]ServicePlan, error) {
+	plans, err := c.Client.ListServicePlansByQuery(url.Values{"q": {fmt.Sprintf("service_guid:%s", serviceGUID)}})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ServicePlan, len(plans))
+	for i, p := range plans {
+		result[i] = ServicePlan{GUID: p.Guid, Name: p.Name, ServiceGUID: p.ServiceGuid}
+	}
+	return result, nil
+}
+
+// ListServiceInstances lists the service instances that match the query
+func (c *V2Client) ListServiceInstances(query ServiceInstanceQuery) ([]ServiceInstance, error) {
+	q := url.Values{}
+	if query.Name != "" {
+		q.Add("q", fmt.Sprintf("name:%s", query.Name))
+	}
+	if query.SpaceGUID != "" {
+		q.Add("q", fmt.Sprintf("space_guid:%s", query.SpaceGUID))
+	}
+	if query.OrgGUID != "" {
+		q.Add("q", fmt.Sprintf("organization_guid:%s", query.OrgGUID))
+	}
+	instances, err := c.Client.ListServiceInstancesByQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ServiceInstance, len(instances))
+	for i, s := range instances {
+		result[i] = ServiceInstance{GUID: s.Guid, Name: s.Name, SpaceGUID: s.SpaceGuid}
+	}
+	return result, nil
+}
+
+// CreateServiceInstance creates the managed service instance
+func (c *V2Client) CreateServiceInstance(req ServiceInstanceRequest) (ServiceInstance, error) {
+	instance, err := c.Client.CreateServiceInstance(cfclient.ServiceInstanceRequest{
+		Name:            req.Name,
+		SpaceGuid:       req.SpaceGUID,
+		ServicePlanGuid: req.ServicePlanGUID,
+		Parameters:      req.Params,
+	})
+	if err != nil {
+		return ServiceInstance{}, err
+	}
+	return ServiceInstance{GUID: instance.Guid, Name: instance.Name, SpaceGUID: instance.SpaceGuid}, nil
+}
+
+// CreateSpaceQuota creates the space quota in its org
+func (c *V2Client) CreateSpaceQuota(req SpaceQuotaRequest) (SpaceQuota, error) {
+	quota, err := c.Client.CreateSpaceQuota(cfclient.SpaceQuotaRequest{
+		Name:                    req.Name,
+		OrganizationGuid:        req.OrgGUID,
+		NonBasicServicesAllowed: req.NonBasicServicesAllowed,
+		TotalServices:           req.TotalServices,
+		TotalRoutes:             req.TotalRoutes,
+		MemoryLimit:             req.MemoryLimit,
+		InstanceMemoryLimit:     req.InstanceMemoryLimit,
+		AppInstanceLimit:        req.AppInstanceLimit,
+		AppTaskLimit:            unlimited,
+		TotalServiceKeys:        unlimited,
+		TotalReservedRoutePorts: unlimited,
+	})
+	if err != nil {
+		return SpaceQuota{}, err
+	}
+	if quota == nil {
+		return SpaceQuota{}, nil
+	}
+	return SpaceQuota{GUID: quota.Guid, Name: quota.Name, OrgGUID: quota.OrganizationGuid}, nil
+}
+
+// ListSpaceQuotas lists the space quotas that match the query
+func (c *V2Client) ListSpaceQuotas(query SpaceQuotaQuery) ([]SpaceQuota, error) {
+	// the v2 API does not filter space quotas, so they are filtered here
+	quotas, err := c.Client.ListSpaceQuotasByQuery(url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	var result []SpaceQuota
+	for _, q := range quotas {
+		if query.Name != "" && q.Name != query.Name || query.OrgGUID != "" && q.OrganizationGuid != query.OrgGUID {
+			continue
+		}
+		result = append(result, SpaceQuota{GUID: q.Guid, Name: q.Name, OrgGUID: q.OrganizationGuid})
+	}
+	return result, nil
+}
+
+// ListSecurityGroupsByName lists the security groups with the name; the v2
+// client reports a group that does not exist as an error
+func (c *V2Client) ListSecurityGroupsByName(name string) ([]SecurityGroup, error) {
+	group, err := c.Client.GetSecGroupByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return []SecurityGroup{{GUID: group.Guid, Name: group.Name}}, nil
+}
+
+func convertOrg(o cfclient.Org) Organization {
+	return Organization{
+		GUID:                        o.Guid,
+		CreatedAt:                   o.CreatedAt,
+		UpdatedAt:                   o.UpdatedAt,
+		Name:                        o.Name,
+		QuotaDefinitionGUID:         o.QuotaDefinitionGuid,
+		DefaultIsolationSegmentGUID: o.DefaultIsolationSegmentGuid,
+	}
+}
+
+func convertSpace(s cfclient.Space) Space {
+	return Space{
+		GUID:    s.Guid,
+		Name:    s.Name,
+		OrgGUID: s.OrganizationGuid,
+	}
+}
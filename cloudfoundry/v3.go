@@ -0,0 +1,966 @@
+package cloudfoundry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The v3 role types that ignition grants
+const (
+	roleOrganizationUser    = "organization_user"
+	roleOrganizationManager = "organization_manager"
+	roleOrganizationAuditor = "organization_auditor"
+	roleSpaceManager        = "space_manager"
+	roleSpaceDeveloper      = "space_developer"
+	roleSpaceAuditor        = "space_auditor"
+)
+
+// jobPollInterval is how often an asynchronous job is checked, and
+// jobTimeout is how long it can take before waiting for it fails
+const (
+	jobPollInterval = 500 * time.Millisecond
+	jobTimeout      = 2 * time.Minute
+)
+
+// V3Error is an error returned by the v3 Cloud Controller API
+type V3Error struct {
+	StatusCode int
+	Code       int
+	Title      string
+	Detail     string
+}
+
+func (e V3Error) Error() string {
+	return fmt.Sprintf("cfclient: v3 error (%d): %s (%d): %s", e.StatusCode, e.Title, e.Code, e.Detail)
+}
+
+// v3ErrorDetail is an error in the errors of a v3 response or job
+type v3ErrorDetail struct {
+	Code   int    `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+// v3ToOne is a to-one relationship of a v3 resource
+type v3ToOne struct {
+	Data *struct {
+		GUID string `json:"guid"`
+	} `json:"data"`
+}
+
+func (r v3ToOne) guid() string {
+	if r.Data == nil {
+		return ""
+	}
+	return r.Data.GUID
+}
+
+func toOne(guid string) map[string]interface{} {
+	if guid == "" {
+		return map[string]interface{}{"data": nil}
+	}
+	return map[string]interface{}{"data": map[string]string{"guid": guid}}
+}
+
+type v3Org struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+	Relationships struct {
+		Quota v3ToOne `json:"quota"`
+	} `json:"relationships"`
+}
+
+func (o v3Org) convert() Organization {
+	return Organization{
+		GUID:                o.GUID,
+		CreatedAt:           o.CreatedAt,
+		UpdatedAt:           o.UpdatedAt,
+		Name:                o.Name,
+		QuotaDefinitionGUID: o.Relationships.Quota.guid(),
+	}
+}
+
+type v3Space struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Relationships struct {
+		Organization v3ToOne `json:"organization"`
+	} `json:"relationships"`
+}
+
+func (s v3Space) convert() Space {
+	return Space{GUID: s.GUID, Name: s.Name, OrgGUID: s.Relationships.Organization.guid()}
+}
+
+type v3Role struct {
+	GUID          string `json:"guid"`
+	Type          string `json:"type"`
+	Relationships struct {
+		User         v3ToOne `json:"user"`
+		Organization v3ToOne `json:"organization"`
+		Space        v3ToOne `json:"space"`
+	} `json:"relationships"`
+}
+
+type v3App struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	State         string `json:"state"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+	Relationships struct {
+		Space v3ToOne `json:"space"`
+	} `json:"relationships"`
+}
+
+func (a v3App) convert() App {
+	return App{
+		GUID:      a.GUID,
+		Name:      a.Name,
+		SpaceGUID: a.Relationships.Space.guid(),
+		State:     a.State,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+}
+
+type v3Package struct {
+	UpdatedAt     string `json:"updated_at"`
+	Relationships struct {
+		App v3ToOne `json:"app"`
+	} `json:"relationships"`
+}
+
+type v3Route struct {
+	GUID          string `json:"guid"`
+	Host          string `json:"host"`
+	Relationships struct {
+		Space v3ToOne `json:"space"`
+	} `json:"relationships"`
+}
+
+type v3ServiceInstance struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Relationships struct {
+		Space v3ToOne `json:"space"`
+	} `json:"relationships"`
+}
+
+func (i v3ServiceInstance) convert() ServiceInstance {
+	return ServiceInstance{GUID: i.GUID, Name: i.Name, SpaceGUID: i.Relationships.Space.guid()}
+}
+
+type v3SpaceQuota struct {
+	GUID          string `json:"guid"`
+	Name          string `json:"name"`
+	Relationships struct {
+		Organization v3ToOne `json:"organization"`
+	} `json:"relationships"`
+}
+
+func (q v3SpaceQuota) convert() SpaceQuota {
+	return SpaceQuota{GUID: q.GUID, Name: q.Name, OrgGUID: q.Relationships.Organization.guid()}
+}
+
+// V3Client is an API that uses the v3 Cloud Controller API. Orgs listed with
+// the v3 API do not include their default isolation segment.
+type V3Client struct {
+	URL    string       // the URL of the Cloud Controller API
+	Client *http.Client // authenticates requests to the Cloud Controller
+}
+
+// ListOrgs lists the orgs that match the query
+func (c *V3Client) ListOrgs(query OrgQuery) ([]Organization, error) {
+	q := url.Values{}
+	if query.Name != "" {
+		q.Set("names", query.Name)
+	}
+	if query.UserGUID != "" {
+		// the orgs that the user is a member of are those in which they hold
+		// the organization_user role
+		roles, err := c.listRoles(url.Values{"types": {roleOrganizationUser}, "user_guids": {query.UserGUID}})
+		if err != nil {
+			return nil, err
+		}
+		var guids []string
+		for _, r := range roles {
+			guids = append(guids, r.Relationships.Organization.guid())
+		}
+		if len(guids) == 0 {
+			return nil, nil
+		}
+		q.Set("guids", strings.Join(guids, ","))
+	}
+	if query.QuotaGUID != "" {
+		// the v3 API does not filter orgs by quota, but the quota lists the
+		// orgs that it is applied to
+		guids, err := c.quotaOrgGUIDs(query.QuotaGUID)
+		if err != nil {
+			return nil, err
+		}
+		if q.Get("guids") != "" {
+			guids = intersect(guids, strings.Split(q.Get("guids"), ","))
+		}
+		if len(guids) == 0 {
+			return nil, nil
+		}
+		q.Set("guids", strings.Join(guids, ","))
+	}
+
+	var result []Organization
+	err := c.list("/v3/organizations", q, func(resources json.RawMessage) error {
+		var orgs []v3Org
+		if err := json.Unmarshal(resources, &orgs); err != nil {
+			return err
+		}
+		for _, o := range orgs {
+			result = append(result, o.convert())
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateOrg creates an org and applies the quota to it
+func (c *V3Client) CreateOrg(name, quotaGUID string) (Organization, error) {
+	var org v3Org
+	_, err := c.do(http.MethodPost, "/v3/organizations", map[string]interface{}{"name": name}, &org)
+	if err != nil {
+		return Organization{}, err
+	}
+	if quotaGUID == "" {
+		return org.convert(), nil
+	}
+
+	_, err = c.do(http.MethodPost, fmt.Sprintf("/v3/organization_quotas/%s/relationships/organizations", quotaGUID), map[string]interface{}{
+		"data": []map[string]string{{"guid": org.GUID}},
+	}, nil)
+	if err != nil {
+		// an org without the quota would not be adopted when provisioning is
+		// retried, so it is removed
+		c.DeleteOrg(org.GUID, true, true)
+		return Organization{}, errors.Wrapf(err, "could not apply quota [%s] to org [%s]", quotaGUID, name)
+	}
+	result := org.convert()
+	result.QuotaDefinitionGUID = quotaGUID
+	return result, nil
+}
+
+// AddIsolationSegmentToOrg entitles the org to use the isolation segment
+func (c *V3Client) AddIsolationSegmentToOrg(isolationSegmentGUID, orgGUID string) error {
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/v3/isolation_segments/%s/relationships/organizations", isolationSegmentGUID), map[string]interface{}{
+		"data": []map[string]string{{"guid": orgGUID}},
+	}, nil)
+	return err
+}
+
+// SetOrgDefaultIsolationSegment makes the isolation segment the default for
+// the org; the org must already be able to use it
+func (c *V3Client) SetOrgDefaultIsolationSegment(orgGUID, isolationSegmentGUID string) error {
+	_, err := c.do(http.MethodPatch, fmt.Sprintf("/v3/organizations/%s/relationships/default_isolation_segment", orgGUID), toOne(isolationSegmentGUID), nil)
+	return err
+}
+
+// DeleteOrg deletes the org and everything in it; the v3 API always deletes
+// recursively. Unless async is true, it waits for the deletion to complete.
+func (c *V3Client) DeleteOrg(guid string, recursive, async bool) error {
+	return c.delete(fmt.Sprintf("/v3/organizations/%s", guid), async)
+}
+
+// ListOrgManagers lists the users that manage the org
+func (c *V3Client) ListOrgManagers(orgGUID string) ([]User, error) {
+	q := url.Values{
+		"types":              {roleOrganizationManager},
+		"organization_guids": {orgGUID},
+		"include":            {"user"},
+	}
+	var result []User
+	err := c.list("/v3/roles", q, nil, func(included json.RawMessage) error {
+		var users struct {
+			Users []struct {
+				GUID     string `json:"guid"`
+				Username string `json:"username"`
+			} `json:"users"`
+		}
+		if err := json.Unmarshal(included, &users); err != nil {
+			return err
+		}
+		for _, u := range users.Users {
+			result = append(result, User{GUID: u.GUID, Username: u.Username})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AssociateOrgUser makes the user a member of the org
+func (c *V3Client) AssociateOrgUser(orgGUID, userGUID string) error {
+	return c.createRole(roleOrganizationUser, userGUID, "organization", orgGUID)
+}
+
+// AssociateOrgAuditor makes the user an auditor of the org
+func (c *V3Client) AssociateOrgAuditor(orgGUID, userGUID string) error {
+	return c.createRole(roleOrganizationAuditor, userGUID, "organization", orgGUID)
+}
+
+// AssociateOrgManager makes the user a manager of the org
+func (c *V3Client) AssociateOrgManager(orgGUID, userGUID string) error {
+	return c.createRole(roleOrganizationManager, userGUID, "organization", orgGUID)
+}
+
+// CreateSpace creates the space, applies its space quota and SSH setting,
+// and grants the users their roles in it. If any of those fail, the space is
+// deleted so that creating it can be retried.
+func (c *V3Client) CreateSpace(req SpaceRequest) (Space, error) {
+	var space v3Space
+	_, err := c.do(http.MethodPost, "/v3/spaces", map[string]interface{}{
+		"name": req.Name,
+		"relationships": map[string]interface{}{
+			"organization": toOne(req.OrgGUID),
+		},
+	}, &space)
+	if err != nil {
+		return Space{}, err
+	}
+
+	err = c.configureSpace(space.GUID, req)
+	if err != nil {
+		c.DeleteSpace(space.GUID, true, true)
+		return Space{}, errors.Wrapf(err, "could not configure space [%s]", req.Name)
+	}
+	return space.convert(), nil
+}
+
+func (c *V3Client) configureSpace(spaceGUID string, req SpaceRequest) error {
+	if req.SpaceQuotaGUID != "" {
+		_, err := c.do(http.MethodPost, fmt.Sprintf("/v3/space_quotas/%s/relationships/spaces", req.SpaceQuotaGUID), map[string]interface{}{
+			"data": []map[string]string{{"guid": spaceGUID}},
+		}, nil)
+		if err != nil {
+			return errors.Wrapf(err, "could not apply space quota [%s]", req.SpaceQuotaGUID)
+		}
+	}
+	// SSH is enabled in new spaces
+	if !req.AllowSSH {
+		_, err := c.do(http.MethodPatch, fmt.Sprintf("/v3/spaces/%s/features/ssh", spaceGUID), map[string]interface{}{"enabled": false}, nil)
+		if err != nil {
+			return errors.Wrap(err, "could not disable SSH")
+		}
+	}
+	roles := []struct {
+		roleType string
+		users    []string
+	}{
+		{roleSpaceManager, req.ManagerGUIDs},
+		{roleSpaceDeveloper, req.DeveloperGUIDs},
+		{roleSpaceAuditor, req.AuditorGUIDs},
+	}
+	for _, r := range roles {
+		for _, userGUID := range r.users {
+			err := c.createRole(r.roleType, userGUID, "space", spaceGUID)
+			if err != nil {
+				return errors.Wrapf(err, "could not grant user [%s] the %s role", userGUID, r.roleType)
+			}
+		}
+	}
+	return nil
+}
+
+// ListSpaces lists the spaces that match the query
+func (c *V3Client) ListSpaces(query SpaceQuery) ([]Space, error) {
+	q := url.Values{}
+	if query.Name != "" {
+		q.Set("names", query.Name)
+	}
+	if query.OrgGUID != "" {
+		q.Set("organization_guids", query.OrgGUID)
+	}
+	var result []Space
+	err := c.list("/v3/spaces", q, func(resources json.RawMessage) error {
+		var spaces []v3Space
+		if err := json.Unmarshal(resources, &spaces); err != nil {
+			return err
+		}
+		for _, s := range spaces {
+			result = append(result, s.convert())
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteSpace deletes the space and everything in it; the v3 API always
+// deletes recursively. Unless async is true, it waits for the deletion to
+// complete.
+func (c *V3Client) DeleteSpace(guid string, recursive, async bool) error {
+	return c.delete(fmt.Sprintf("/v3/spaces/%s", guid), async)
+}
+
+// quotaOrgGUIDs returns the GUIDs of the orgs that the org quota is applied to
+func (c *V3Client) quotaOrgGUIDs(quotaGUID string) ([]string, error) {
+	var guids []string
+	found := false
+	err := c.list("/v3/organization_quotas", url.Values{"guids": {quotaGUID}}, func(resources json.RawMessage) error {
+		var page []struct {
+			Relationships struct {
+				Organizations struct {
+					Data []struct {
+						GUID string `json:"guid"`
+					} `json:"data"`
+				} `json:"organizations"`
+			} `json:"relationships"`
+		}
+		if err := json.Unmarshal(resources, &page); err != nil {
+			return err
+		}
+		for _, quota := range page {
+			found = true
+			for _, org := range quota.Relationships.Organizations.Data {
+				guids = append(guids, org.GUID)
+			}
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("could not find org quota [%s]", quotaGUID)
+	}
+	return guids, nil
+}
+
+// intersect returns the items of a that are also in b
+func intersect(a, b []string) []string {
+	var result []string
+	for _, item := range a {
+		for _, other := range b {
+			if item == other {
+				result = append(result, item)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// GetOrgQuotaByName gets the org quota with the name
+func (c *V3Client) GetOrgQuotaByName(name string) (Quota, error) {
+	var quotas []Quota
+	err := c.list("/v3/organization_quotas", url.Values{"names": {name}}, func(resources json.RawMessage) error {
+		var page []Quota
+		if err := json.Unmarshal(resources, &page); err != nil {
+			return err
+		}
+		quotas = append(quotas, page...)
+		return nil
+	}, nil)
+	if err != nil {
+		return Quota{}, err
+	}
+	if len(quotas) != 1 {
+		return Quota{}, errors.Errorf("expected exactly 1 org quota with name [%s], found %d", name, len(quotas))
+	}
+	return quotas[0], nil
+}
+
+// ListIsolationSegmentsByName lists the isolation segments with the name
+func (c *V3Client) ListIsolationSegmentsByName(name string) ([]IsolationSegment, error) {
+	var result []IsolationSegment
+	err := c.list("/v3/isolation_segments", url.Values{"names": {name}}, func(resources json.RawMessage) error {
+		var page []IsolationSegment
+		if err := json.Unmarshal(resources, &page); err != nil {
+			return err
+		}
+		result = append(result, page...)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListApps lists the apps that match the query. The v3 API does not change an
+// app when a package is uploaded for it, so each app's PackageUpdatedAt is
+// the time that its newest package was updated.
+func (c *V3Client) ListApps(query AppQuery) ([]App, error) {
+	q := url.Values{}
+	if query.OrgGUID != "" {
+		q.Set("organization_guids", query.OrgGUID)
+	}
+	var result []App
+	err := c.list("/v3/apps", q, func(resources json.RawMessage) error {
+		var apps []v3App
+		if err := json.Unmarshal(resources, &apps); err != nil {
+			return err
+		}
+		for _, a := range apps {
+			result = append(result, a.convert())
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	packageUpdatedAt := make(map[string]string)
+	err = c.list("/v3/packages", q, func(resources json.RawMessage) error {
+		var packages []v3Package
+		if err := json.Unmarshal(resources, &packages); err != nil {
+			return err
+		}
+		for _, p := range packages {
+			app := p.Relationships.App.guid()
+			if latestTimestamp(p.UpdatedAt, packageUpdatedAt[app]) {
+				packageUpdatedAt[app] = p.UpdatedAt
+			}
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list packages")
+	}
+	for i := range result {
+		result[i].PackageUpdatedAt = packageUpdatedAt[result[i].GUID]
+	}
+	return result, nil
+}
+
+// latestTimestamp returns whether the RFC 3339 timestamp t is later than
+// other; a timestamp that cannot be parsed is kept, so that it is reported by
+// whatever parses it next
+func latestTimestamp(t, other string) bool {
+	if other == "" {
+		return true
+	}
+	a, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		return true
+	}
+	b, err := time.Parse(time.RFC3339, other)
+	return err != nil || a.After(b)
+}
+
+// DeleteApp deletes the app, and waits for the deletion to complete
+func (c *V3Client) DeleteApp(guid string) error {
+	return c.delete(fmt.Sprintf("/v3/apps/%s", guid), false)
+}
+
+// ListRoutes lists the routes that match the query
+func (c *V3Client) ListRoutes(query RouteQuery) ([]Route, error) {
+	q := url.Values{}
+	if query.OrgGUID != "" {
+		q.Set("organization_guids", query.OrgGUID)
+	}
+	var result []Route
+	err := c.list("/v3/routes", q, func(resources json.RawMessage) error {
+		var routes []v3Route
+		if err := json.Unmarshal(resources, &routes); err != nil {
+			return err
+		}
+		for _, r := range routes {
+			result = append(result, Route{GUID: r.GUID, Host: r.Host, SpaceGUID: r.Relationships.Space.guid()})
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteRoute deletes the route, and waits for the deletion to complete
+func (c *V3Client) DeleteRoute(guid string) error {
+	return c.delete(fmt.Sprintf("/v3/routes/%s", guid), false)
+}
+
+// ListServicesByLabel lists the marketplace services, which the v3 API calls
+// service offerings, with the label
+func (c *V3Client) ListServicesByLabel(label string) ([]Service, error) {
+	var result []Service
+	err := c.list("/v3/service_offerings", url.Values{"names": {label}}, func(resources json.RawMessage) error {
+		var offerings []struct {
+			GUID string `json:"guid"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(resources, &offerings); err != nil {
+			return err
+		}
+		for _, o := range offerings {
+			result = append(result, Service{GUID: o.GUID, Label: o.Name})
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListServicePlansForService lists the plans of the marketplace service
+func (c *V3Client) ListServicePlansForService(serviceGUID string) ([]ServicePlan, error) {
+	var result []ServicePlan
+	err := c.list("/v3/service_plans", url.Values{"service_offering_guids": {serviceGUID}}, func(resources json.RawMessage) error {
+		var plans []struct {
+			GUID          string `json:"guid"`
+			Name          string `json:"name"`
+			Relationships struct {
+				ServiceOffering v3ToOne `json:"service_offering"`
+			} `json:"relationships"`
+		}
+		if err := json.Unmarshal(resources, &plans); err != nil {
+			return err
+		}
+		for _, p := range plans {
+			result = append(result, ServicePlan{GUID: p.GUID, Name: p.Name, ServiceGUID: p.Relationships.ServiceOffering.guid()})
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListServiceInstances lists the service instances that match the query
+func (c *V3Client) ListServiceInstances(query ServiceInstanceQuery) ([]ServiceInstance, error) {
+	q := url.Values{}
+	if query.Name != "" {
+		q.Set("names", query.Name)
+	}
+	if query.SpaceGUID != "" {
+		q.Set("space_guids", query.SpaceGUID)
+	}
+	if query.OrgGUID != "" {
+		q.Set("organization_guids", query.OrgGUID)
+	}
+	var result []ServiceInstance
+	err := c.list("/v3/service_instances", q, func(resources json.RawMessage) error {
+		var instances []v3ServiceInstance
+		if err := json.Unmarshal(resources, &instances); err != nil {
+			return err
+		}
+		for _, i := range instances {
+			result = append(result, i.convert())
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateServiceInstance creates the managed service instance. Like the v2
+// API with accepts_incomplete, it does not wait for the broker to provision
+// the instance, which the Cloud Controller records before it asks the broker.
+func (c *V3Client) CreateServiceInstance(req ServiceInstanceRequest) (ServiceInstance, error) {
+	body := map[string]interface{}{
+		"type": "managed",
+		"name": req.Name,
+		"relationships": map[string]interface{}{
+			"space":        toOne(req.SpaceGUID),
+			"service_plan": toOne(req.ServicePlanGUID),
+		},
+	}
+	if len(req.Params) > 0 {
+		body["parameters"] = req.Params
+	}
+	_, err := c.do(http.MethodPost, "/v3/service_instances", body, nil)
+	if err != nil {
+		return ServiceInstance{}, err
+	}
+	instances, err := c.ListServiceInstances(ServiceInstanceQuery{Name: req.Name, SpaceGUID: req.SpaceGUID})
+	if err != nil {
+		return ServiceInstance{}, errors.Wrapf(err, "could not find service instance [%s]", req.Name)
+	}
+	if len(instances) == 0 {
+		return ServiceInstance{}, errors.Errorf("service instance [%s] was not created", req.Name)
+	}
+	return instances[0], nil
+}
+
+// DeleteServiceInstance deletes the service instance, and its bindings and
+// keys; the v3 API always deletes recursively. Unless async is true, it
+// waits for the deletion to complete.
+func (c *V3Client) DeleteServiceInstance(guid string, recursive, async bool) error {
+	return c.delete(fmt.Sprintf("/v3/service_instances/%s", guid), async)
+}
+
+// CreateSpaceQuota creates the space quota in its org
+func (c *V3Client) CreateSpaceQuota(req SpaceQuotaRequest) (SpaceQuota, error) {
+	var quota v3SpaceQuota
+	_, err := c.do(http.MethodPost, "/v3/space_quotas", map[string]interface{}{
+		"name": req.Name,
+		"apps": map[string]interface{}{
+			"total_memory_in_mb":       limit(req.MemoryLimit),
+			"per_process_memory_in_mb": limit(req.InstanceMemoryLimit),
+			"total_instances":          limit(req.AppInstanceLimit),
+		},
+		"services": map[string]interface{}{
+			"paid_services_allowed":   req.NonBasicServicesAllowed,
+			"total_service_instances": limit(req.TotalServices),
+		},
+		"routes": map[string]interface{}{
+			"total_routes": limit(req.TotalRoutes),
+		},
+		"relationships": map[string]interface{}{
+			"organization": toOne(req.OrgGUID),
+		},
+	}, &quota)
+	if err != nil {
+		return SpaceQuota{}, err
+	}
+	return quota.convert(), nil
+}
+
+// limit is the v3 value of the limit, in which null is unlimited
+func limit(l int) interface{} {
+	if l == unlimited {
+		return nil
+	}
+	return l
+}
+
+// ListSpaceQuotas lists the space quotas that match the query
+func (c *V3Client) ListSpaceQuotas(query SpaceQuotaQuery) ([]SpaceQuota, error) {
+	q := url.Values{}
+	if query.Name != "" {
+		q.Set("names", query.Name)
+	}
+	if query.OrgGUID != "" {
+		q.Set("organization_guids", query.OrgGUID)
+	}
+	var result []SpaceQuota
+	err := c.list("/v3/space_quotas", q, func(resources json.RawMessage) error {
+		var quotas []v3SpaceQuota
+		if err := json.Unmarshal(resources, &quotas); err != nil {
+			return err
+		}
+		for _, quota := range quotas {
+			result = append(result, quota.convert())
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListSecurityGroupsByName lists the security groups with the name
+func (c *V3Client) ListSecurityGroupsByName(name string) ([]SecurityGroup, error) {
+	var result []SecurityGroup
+	err := c.list("/v3/security_groups", url.Values{"names": {name}}, func(resources json.RawMessage) error {
+		var page []SecurityGroup
+		if err := json.Unmarshal(resources, &page); err != nil {
+			return err
+		}
+		result = append(result, page...)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// BindSecGroup binds the security group to the space for its running apps
+func (c *V3Client) BindSecGroup(secGUID, spaceGUID string) error {
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/v3/security_groups/%s/relationships/running_spaces", secGUID), map[string]interface{}{
+		"data": []map[string]string{{"guid": spaceGUID}},
+	}, nil)
+	return err
+}
+
+// BindStagingSecGroupToSpace binds the security group to the space for
+// staging its apps
+func (c *V3Client) BindStagingSecGroupToSpace(secGUID, spaceGUID string) error {
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/v3/security_groups/%s/relationships/staging_spaces", secGUID), map[string]interface{}{
+		"data": []map[string]string{{"guid": spaceGUID}},
+	}, nil)
+	return err
+}
+
+func (c *V3Client) listRoles(q url.Values) ([]v3Role, error) {
+	var result []v3Role
+	err := c.list("/v3/roles", q, func(resources json.RawMessage) error {
+		var page []v3Role
+		if err := json.Unmarshal(resources, &page); err != nil {
+			return err
+		}
+		result = append(result, page...)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// createRole grants the user the role in the org or space; a role that the
+// user already holds is not an error, so that granting it can be retried
+func (c *V3Client) createRole(roleType, userGUID, relationship, guid string) error {
+	_, err := c.do(http.MethodPost, "/v3/roles", map[string]interface{}{
+		"type": roleType,
+		"relationships": map[string]interface{}{
+			"user":       toOne(userGUID),
+			relationship: toOne(guid),
+		},
+	}, nil)
+	if e, ok := err.(V3Error); ok && e.StatusCode == http.StatusUnprocessableEntity && strings.Contains(e.Detail, "already has") {
+		return nil
+	}
+	return err
+}
+
+// delete deletes the resource, and unless async is true, waits for the job
+// that deletes it to complete
+func (c *V3Client) delete(path string, async bool) error {
+	header, err := c.do(http.MethodDelete, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	if async || header.Get("Location") == "" {
+		return nil
+	}
+	return c.wait(header.Get("Location"))
+}
+
+// wait polls the job until it completes or fails
+func (c *V3Client) wait(location string) error {
+	deadline := time.Now().Add(jobTimeout)
+	for {
+		var job struct {
+			State  string          `json:"state"`
+			Errors []v3ErrorDetail `json:"errors"`
+		}
+		_, err := c.do(http.MethodGet, location, nil, &job)
+		if err != nil {
+			return errors.Wrapf(err, "could not get job [%s]", location)
+		}
+		switch job.State {
+		case "COMPLETE":
+			return nil
+		case "FAILED":
+			if len(job.Errors) > 0 {
+				e := job.Errors[0]
+				return V3Error{StatusCode: http.StatusOK, Code: e.Code, Title: e.Title, Detail: e.Detail}
+			}
+			return errors.Errorf("job [%s] failed", location)
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("job [%s] did not complete within %s", location, jobTimeout)
+		}
+		time.Sleep(jobPollInterval)
+	}
+}
+
+// list gets every page of the resources at the path, passing the resources
+// and included resources of each page to the funcs that are not nil
+func (c *V3Client) list(path string, q url.Values, resources func(json.RawMessage) error, included func(json.RawMessage) error) error {
+	q.Set("per_page", "5000")
+	next := path + "?" + q.Encode()
+	for next != "" {
+		var page struct {
+			Pagination struct {
+				Next *struct {
+					Href string `json:"href"`
+				} `json:"next"`
+			} `json:"pagination"`
+			Resources json.RawMessage `json:"resources"`
+			Included  json.RawMessage `json:"included"`
+		}
+		_, err := c.do(http.MethodGet, next, nil, &page)
+		if err != nil {
+			return err
+		}
+		if resources != nil {
+			if err := resources(page.Resources); err != nil {
+				return errors.Wrapf(err, "could not parse the resources of [%s]", next)
+			}
+		}
+		if included != nil && len(page.Included) > 0 {
+			if err := included(page.Included); err != nil {
+				return errors.Wrapf(err, "could not parse the included resources of [%s]", next)
+			}
+		}
+		next = ""
+		if page.Pagination.Next != nil {
+			next = page.Pagination.Next.Href
+		}
+	}
+	return nil
+}
+
+// do sends the request, with the body encoded as JSON, and decodes the
+// response into result. Responses with an error status are returned as a
+// V3Error.
+func (c *V3Client) do(method, path string, body interface{}, result interface{}) (http.Header, error) {
+	u := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		u = strings.TrimSuffix(c.URL, "/") + path
+	}
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		e := V3Error{StatusCode: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+		var errs struct {
+			Errors []v3ErrorDetail `json:"errors"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errs) == nil && len(errs.Errors) > 0 {
+			e.Code, e.Title, e.Detail = errs.Errors[0].Code, errs.Errors[0].Title, errs.Errors[0].Detail
+		}
+		return nil, e
+	}
+	if result != nil && resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(result)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse the response to %s [%s]", method, path)
+		}
+	}
+	return resp.Header, nil
+}
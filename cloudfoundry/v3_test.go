@@ -0,0 +1,346 @@
+package cloudfoundry_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/internal/fakecf"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+func TestV3Client(t *testing.T) {
+	spec.Run(t, "V3Client", testV3Client, spec.Report(report.Terminal{}))
+}
+
+func testV3Client(t *testing.T, when spec.G, it spec.S) {
+	var (
+		f       *fakecf.Server
+		s       *httptest.Server
+		c       *cloudfoundry.V3Client
+		userID  string
+		quotaID string
+		v2      []string
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		f = fakecf.New("ignition", "ignition-secret")
+		v2 = nil
+		s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.HasPrefix(req.URL.Path, "/v2/") {
+				v2 = append(v2, req.Method+" "+req.URL.Path)
+			}
+			f.ServeHTTP(w, req)
+		}))
+		config := &clientcredentials.Config{
+			ClientID:     "ignition",
+			ClientSecret: "ignition-secret",
+			TokenURL:     s.URL + "/oauth/token",
+		}
+		client := config.Client(context.Background())
+		c = &cloudfoundry.V3Client{
+			URL:    s.URL,
+			Client: client,
+		}
+		userID = f.AddUser("developer@example.net", "ignition-sso", "developer@example.net")
+		quotaID = f.AddOrgQuota("ignition")
+	})
+
+	it.After(func() {
+		s.Close()
+		Expect(v2).To(BeEmpty(), "the v3 client should not use the v2 API")
+	})
+
+	it("creates orgs with the quota and lists them for their members", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(org.Name).To(Equal("ignition-developer"))
+		Expect(org.QuotaDefinitionGUID).To(Equal(quotaID))
+
+		orgs, err := c.ListOrgs(cloudfoundry.OrgQuery{UserGUID: userID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(BeEmpty())
+
+		Expect(c.AssociateOrgUser(org.GUID, userID)).To(Succeed())
+		orgs, err = c.ListOrgs(cloudfoundry.OrgQuery{UserGUID: userID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].GUID).To(Equal(org.GUID))
+		Expect(orgs[0].QuotaDefinitionGUID).To(Equal(quotaID))
+
+		orgs, err = c.ListOrgs(cloudfoundry.OrgQuery{Name: "ignition-developer"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+		orgs, err = c.ListOrgs(cloudfoundry.OrgQuery{Name: "another-org"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(BeEmpty())
+	})
+
+	it("lists the orgs with a quota", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.CreateOrg("platform-tools", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		orgs, err := c.ListOrgs(cloudfoundry.OrgQuery{QuotaGUID: quotaID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].GUID).To(Equal(org.GUID))
+
+		orgs, err = c.ListOrgs(cloudfoundry.OrgQuery{QuotaGUID: quotaID, UserGUID: userID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(BeEmpty())
+		Expect(c.AssociateOrgUser(org.GUID, userID)).To(Succeed())
+		orgs, err = c.ListOrgs(cloudfoundry.OrgQuery{QuotaGUID: quotaID, UserGUID: userID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+
+		_, err = c.ListOrgs(cloudfoundry.OrgQuery{QuotaGUID: "missing-quota-id"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("removes the org when the quota cannot be applied", func() {
+		_, err := c.CreateOrg("ignition-developer", "unknown-quota-id")
+		Expect(err).To(HaveOccurred())
+		Expect(f.Orgs()).To(BeEmpty())
+	})
+
+	it("makes an isolation segment the org's default", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		segments, err := c.ListIsolationSegmentsByName(fakecf.SharedIsolationSegmentName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(segments).To(HaveLen(1))
+
+		Expect(c.AddIsolationSegmentToOrg(segments[0].GUID, org.GUID)).To(Succeed())
+		Expect(c.SetOrgDefaultIsolationSegment(org.GUID, segments[0].GUID)).To(Succeed())
+		Expect(f.Orgs()[0].DefaultIsolationSegmentGUID).To(Equal(segments[0].GUID))
+	})
+
+	it("grants org roles that the user already has without an error", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.AssociateOrgUser(org.GUID, userID)).To(Succeed())
+		Expect(c.AssociateOrgManager(org.GUID, userID)).To(Succeed())
+		Expect(c.AssociateOrgManager(org.GUID, userID)).To(Succeed())
+		Expect(c.AssociateOrgAuditor(org.GUID, userID)).To(Succeed())
+		Expect(f.Roles()).To(HaveLen(3))
+
+		managers, err := c.ListOrgManagers(org.GUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(managers).To(ConsistOf(cloudfoundry.User{GUID: userID, Username: "developer@example.net"}))
+	})
+
+	it("creates spaces with their quota, ssh setting, and roles", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.AssociateOrgUser(org.GUID, userID)).To(Succeed())
+		spaceQuota, err := c.CreateSpaceQuota(cloudfoundry.SpaceQuotaRequest{Name: "small", OrgGUID: org.GUID, MemoryLimit: 1024})
+		Expect(err).NotTo(HaveOccurred())
+
+		space, err := c.CreateSpace(cloudfoundry.SpaceRequest{
+			Name:           "playground",
+			OrgGUID:        org.GUID,
+			SpaceQuotaGUID: spaceQuota.GUID,
+			DeveloperGUIDs: []string{userID},
+			AuditorGUIDs:   []string{userID},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(space.Name).To(Equal("playground"))
+		Expect(space.OrgGUID).To(Equal(org.GUID))
+
+		spaces := f.Spaces()
+		Expect(spaces).To(HaveLen(1))
+		Expect(spaces[0].SpaceQuotaGUID).To(Equal(spaceQuota.GUID))
+		Expect(spaces[0].AllowSSH).To(BeFalse())
+		var roles []string
+		for _, r := range f.Roles() {
+			if r.SpaceGUID == space.GUID {
+				roles = append(roles, r.Type)
+			}
+		}
+		Expect(roles).To(ConsistOf(fakecf.RoleSpaceDeveloper, fakecf.RoleSpaceAuditor))
+
+		found, err := c.ListSpaces(cloudfoundry.SpaceQuery{Name: "playground", OrgGUID: org.GUID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(ConsistOf(space))
+	})
+
+	it("removes the space when its roles cannot be granted", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+
+		// the user is not a member of the org, so cannot have a space role
+		_, err = c.CreateSpace(cloudfoundry.SpaceRequest{
+			Name:           "playground",
+			OrgGUID:        org.GUID,
+			DeveloperGUIDs: []string{userID},
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(f.Spaces()).To(BeEmpty())
+	})
+
+	it("reports names that are taken", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.CreateOrg("ignition-developer", quotaID)
+		Expect(cloudfoundry.IsOrgNameTakenError(err)).To(BeTrue())
+
+		_, err = c.CreateSpace(cloudfoundry.SpaceRequest{Name: "playground", OrgGUID: org.GUID, AllowSSH: true})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.CreateSpace(cloudfoundry.SpaceRequest{Name: "playground", OrgGUID: org.GUID, AllowSSH: true})
+		Expect(cloudfoundry.IsSpaceNameTakenError(err)).To(BeTrue())
+	})
+
+	it("deletes orgs and spaces", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		space, err := c.CreateSpace(cloudfoundry.SpaceRequest{Name: "playground", OrgGUID: org.GUID, AllowSSH: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(c.DeleteSpace(space.GUID, true, false)).To(Succeed())
+		Expect(f.Spaces()).To(BeEmpty())
+		Expect(c.DeleteOrg(org.GUID, true, false)).To(Succeed())
+		Expect(f.Orgs()).To(BeEmpty())
+
+		err = c.DeleteOrg(org.GUID, true, false)
+		Expect(err).To(HaveOccurred())
+		Expect(err.(cloudfoundry.V3Error).StatusCode).To(Equal(404))
+	})
+
+	it("looks up org quotas by name", func() {
+		quota, err := c.GetOrgQuotaByName("ignition")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(quota).To(Equal(cloudfoundry.Quota{GUID: quotaID, Name: "ignition"}))
+
+		_, err = c.GetOrgQuotaByName("unknown")
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("creates space quotas, and finds those that already exist", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		template := cloudfoundry.SpaceQuotaTemplate{
+			Name:                "small",
+			MemoryLimit:         1024,
+			InstanceMemoryLimit: -1,
+			TotalRoutes:         10,
+			TotalServices:       -1,
+			AppInstanceLimit:    -1,
+		}
+		id, err := cloudfoundry.CreateSpaceQuota(template, org.GUID, c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.SpaceQuotas()).To(ConsistOf(fakecf.SpaceQuota{
+			GUID:                id,
+			Name:                "small",
+			OrgGUID:             org.GUID,
+			MemoryLimit:         1024,
+			InstanceMemoryLimit: -1,
+			TotalRoutes:         10,
+			TotalServices:       -1,
+			AppInstanceLimit:    -1,
+		}))
+
+		existing, err := cloudfoundry.CreateSpaceQuota(template, org.GUID, c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(existing).To(Equal(id))
+		Expect(f.SpaceQuotas()).To(HaveLen(1))
+	})
+
+	it("binds security groups to spaces", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		space, err := c.CreateSpace(cloudfoundry.SpaceRequest{Name: "playground", OrgGUID: org.GUID, AllowSSH: true})
+		Expect(err).NotTo(HaveOccurred())
+		f.AddSecurityGroup("artifacts")
+		f.AddSecurityGroup("internal-dns")
+
+		err = cloudfoundry.BindSecurityGroups(cloudfoundry.SpaceTemplate{
+			Name:                  "playground",
+			RunningSecurityGroups: []string{"artifacts", "internal-dns"},
+			StagingSecurityGroups: []string{"artifacts"},
+		}, space.GUID, c, c)
+		Expect(err).NotTo(HaveOccurred())
+		groups := f.SecurityGroups()
+		Expect(groups[0].RunningSpaceGUIDs).To(ConsistOf(space.GUID))
+		Expect(groups[0].StagingSpaceGUIDs).To(ConsistOf(space.GUID))
+		Expect(groups[1].RunningSpaceGUIDs).To(ConsistOf(space.GUID))
+		Expect(groups[1].StagingSpaceGUIDs).To(BeEmpty())
+
+		_, err = cloudfoundry.SecurityGroupIDForName("missing", c)
+		Expect(err).To(MatchError("could not find security group with name [missing]"))
+	})
+
+	it("creates service instances from the marketplace", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		space, err := c.CreateSpace(cloudfoundry.SpaceRequest{Name: "playground", OrgGUID: org.GUID, AllowSSH: true})
+		Expect(err).NotTo(HaveOccurred())
+		f.AddService("p-mysql", "large", "small")
+
+		planGUID, err := cloudfoundry.ServicePlanGUID("p-mysql", "small", c)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = cloudfoundry.ServicePlanGUID("p-mysql", "medium", c)
+		Expect(err).To(MatchError("service [p-mysql] has no plan [medium]"))
+		_, err = cloudfoundry.ServicePlanGUID("p-redis", "small", c)
+		Expect(err).To(MatchError("service [p-redis] is not in the marketplace"))
+
+		template := cloudfoundry.ServiceInstanceTemplate{Name: "db", Service: "p-mysql", Plan: "small", Params: map[string]interface{}{"count": 1}}
+		Expect(cloudfoundry.CreateServiceInstance(template, space.GUID, planGUID, c)).To(Succeed())
+		instances := f.ServiceInstances()
+		Expect(instances).To(HaveLen(1))
+		Expect(instances[0].Name).To(Equal("db"))
+		Expect(instances[0].SpaceGUID).To(Equal(space.GUID))
+		Expect(instances[0].PlanGUID).To(Equal(planGUID))
+		Expect(instances[0].Parameters).To(HaveKeyWithValue("count", BeNumerically("==", 1)))
+
+		err = cloudfoundry.CreateServiceInstance(template, space.GUID, planGUID, c)
+		Expect(cloudfoundry.IsServiceInstanceNameTakenError(err)).To(BeTrue())
+	})
+
+	it("lists the apps in an org, with when their packages were updated", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		space, err := c.CreateSpace(cloudfoundry.SpaceRequest{Name: "playground", OrgGUID: org.GUID, AllowSSH: true})
+		Expect(err).NotTo(HaveOccurred())
+		appGUID := f.AddApp("web", space.GUID)
+
+		apps, err := c.ListApps(cloudfoundry.AppQuery{OrgGUID: org.GUID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(apps).To(HaveLen(1))
+		Expect(apps[0].GUID).To(Equal(appGUID))
+		Expect(apps[0].SpaceGUID).To(Equal(space.GUID))
+		Expect(apps[0].State).To(Equal("STARTED"))
+		Expect(apps[0].PackageUpdatedAt).NotTo(BeEmpty())
+		Expect(apps[0].PackageUpdatedAt).To(Equal(apps[0].UpdatedAt))
+
+		apps, err = c.ListApps(cloudfoundry.AppQuery{OrgGUID: "other-org-guid"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(apps).To(BeEmpty())
+	})
+
+	it("tears down an org and everything in it", func() {
+		org, err := c.CreateOrg("ignition-developer", quotaID)
+		Expect(err).NotTo(HaveOccurred())
+		space, err := c.CreateSpace(cloudfoundry.SpaceRequest{Name: "playground", OrgGUID: org.GUID, AllowSSH: true})
+		Expect(err).NotTo(HaveOccurred())
+		f.AddApp("web", space.GUID)
+		f.AddRoute("web", space.GUID)
+		f.AddService("p-mysql", "small")
+		planGUID, err := cloudfoundry.ServicePlanGUID("p-mysql", "small", c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cloudfoundry.CreateServiceInstance(cloudfoundry.ServiceInstanceTemplate{Name: "db"}, space.GUID, planGUID, c)).To(Succeed())
+
+		Expect(cloudfoundry.TearDownOrg(org.GUID, c)).To(Succeed())
+		Expect(f.Orgs()).To(BeEmpty())
+		Expect(f.Spaces()).To(BeEmpty())
+		Expect(f.ServiceInstances()).To(BeEmpty())
+	})
+}
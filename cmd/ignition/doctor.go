@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pivotalservices/ignition/config"
+)
+
+// runDoctor checks that ignition can use each of the services it is
+// configured with, and prints a report of the checks
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	file := configFileFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	useConfigFile(*file)
+
+	checks := config.Diagnose()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, c := range checks {
+		result, detail := "PASS", c.Detail
+		switch {
+		case c.Skipped:
+			result = "SKIP"
+		case c.Err != nil:
+			result = "FAIL"
+			detail = c.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result, c.Name, detail)
+	}
+	w.Flush()
+	if config.Failed(checks) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+
+	"github.com/pivotalservices/ignition/internal/fakecf"
+	"github.com/pivotalservices/ignition/logging"
+)
+
+// runFakeCF runs a fake Cloud Controller and UAA so that ignition can be run
+// locally without a Cloud Foundry foundation
+func runFakeCF(args []string) int {
+	flags := flag.NewFlagSet("fake-cf", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:3002", "the address to listen on")
+	clientID := flags.String("client-id", "ignition-api", "the client ID ignition uses the Cloud Controller and UAA with")
+	clientSecret := flags.String("client-secret", "ignition-api-secret", "the client secret ignition uses the Cloud Controller and UAA with")
+	quota := flags.String("quota", "ignition", "the org quota to create for ignition orgs")
+	securityGroups := flags.String("security-groups", "", "a comma separated list of application security groups to create")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	f := fakecf.New(*clientID, *clientSecret)
+	if strings.TrimSpace(*quota) != "" && *quota != fakecf.DefaultQuotaName {
+		f.AddOrgQuota(*quota)
+	}
+	for _, name := range strings.Split(*securityGroups, ",") {
+		if strings.TrimSpace(name) != "" {
+			f.AddSecurityGroup(strings.TrimSpace(name))
+		}
+	}
+	logging.Default().Info("starting fake Cloud Controller and UAA", "url", "http://"+*addr, "client_id", *clientID)
+	err := http.ListenAndServe(*addr, f)
+	logging.Default().Error("fake Cloud Controller and UAA stopped", "error", err)
+	return 1
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pivotalservices/ignition/user/openid/fakeprovider"
+)
+
+// runFakeOIDC runs a fake OpenID Connect provider so that ignition can be
+// run locally without a UAA or other identity provider
+func runFakeOIDC(args []string) int {
+	flags := flag.NewFlagSet("fake-oidc", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:3001", "the address to listen on")
+	issuer := flags.String("issuer", "", "the issuer URL (defaults to http://<addr>)")
+	clientID := flags.String("client-id", "ignition", "the client ID ignition logs in with")
+	clientSecret := flags.String("client-secret", "ignition-secret", "the client secret ignition logs in with")
+	usersFile := flags.String("users", "", "a JSON file containing the users that can log in")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var users []fakeprovider.User
+	if *usersFile != "" {
+		b, err := ioutil.ReadFile(*usersFile)
+		if err != nil {
+			logging.Default().Error("could not read users", "file", *usersFile, "error", err)
+			return 1
+		}
+		if err := json.Unmarshal(b, &users); err != nil {
+			logging.Default().Error("could not parse users", "file", *usersFile, "error", err)
+			return 1
+		}
+	}
+
+	p, err := fakeprovider.New(*clientID, *clientSecret, users...)
+	if err != nil {
+		logging.Default().Error("could not create the fake provider", "error", err)
+		return 1
+	}
+	p.Issuer = *issuer
+	if p.Issuer == "" {
+		p.Issuer = "http://" + *addr
+	}
+	logging.Default().Info("starting fake OpenID Connect provider", "issuer", p.Issuer, "client_id", *clientID)
+	err = http.ListenAndServe(*addr, p)
+	logging.Default().Error("fake OpenID Connect provider stopped", "error", err)
+	return 1
+}
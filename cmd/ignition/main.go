@@ -2,20 +2,46 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/pivotalservices/ignition/config"
 	"github.com/pivotalservices/ignition/http"
+	"github.com/pivotalservices/ignition/logging"
 )
 
 func main() {
-	log.SetFlags(log.Lshortfile | log.Ldate | log.Ltime | log.LUTC)
+	// the standard logger writes through the structured logger
+	log.SetFlags(0)
+	log.SetOutput(logging.Default().Writer())
+	if len(os.Args) > 1 && os.Args[1] == "fake-oidc" {
+		os.Exit(runFakeOIDC(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fake-cf" {
+		os.Exit(runFakeCF(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:]))
+	}
+	// validate is an alias of config validate
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctor(os.Args[2:]))
+	}
 	ignition, err := config.New()
 	if err != nil {
-		log.Fatal(err)
+		logging.Default().Error("could not load configuration", "error", err)
+		os.Exit(1)
 	}
+	level, _ := logging.ParseLevel(ignition.Server.LogLevel)
+	logging.SetDefault(logging.New(os.Stdout, level))
+	log.SetOutput(logging.Default().Writer())
 	api := http.API{
 		Ignition: ignition,
 	}
-	log.Printf("Starting Server listening on %s\n", api.URI())
-	log.Fatal(api.Run())
+	logging.Default().Info("starting server", "uri", api.URI())
+	err = api.Run()
+	logging.Default().Error("server stopped", "error", err)
+	os.Exit(1)
 }
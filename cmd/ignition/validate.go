@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pivotalservices/ignition/config"
+)
+
+// runConfig runs the config subcommand, which has the validate subcommand
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: ignition config validate [-config file]")
+		return 2
+	}
+	return runValidate(args[1:])
+}
+
+// runValidate loads ignition's configuration without starting the server or
+// connecting to any of the services it refers to, and lists every problem
+// with it
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	file := configFileFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	useConfigFile(*file)
+
+	err := config.Validate()
+	if err != nil {
+		printProblems(os.Stdout, err)
+		return 1
+	}
+	fmt.Fprintln(os.Stdout, "the configuration is valid")
+	return 0
+}
+
+// configFileFlag adds the -config flag, which names the config file
+func configFileFlag(flags *flag.FlagSet) *string {
+	return flags.String("config", "", "the config file to use (defaults to $"+config.ConfigFileEnv+")")
+}
+
+// useConfigFile makes the file, when it is set, the config file that ignition
+// reads its configuration from
+func useConfigFile(file string) {
+	if file != "" {
+		os.Setenv(config.ConfigFileEnv, file)
+	}
+}
+
+// printProblems lists each problem with the configuration on its own line
+func printProblems(w io.Writer, err error) {
+	v, ok := err.(*config.ValidationError)
+	if !ok {
+		fmt.Fprintf(w, "invalid configuration: %v\n", err)
+		return
+	}
+	fmt.Fprintln(w, "invalid configuration:")
+	for _, problem := range v.Problems {
+		fmt.Fprintf(w, "  - %s\n", problem)
+	}
+}
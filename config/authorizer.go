@@ -6,11 +6,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 	"strings"
 
-	cfenv "github.com/cloudfoundry-community/go-cfenv"
-	"github.com/kelseyhightower/envconfig"
 	"github.com/pivotalservices/ignition/user"
 	"github.com/pivotalservices/ignition/user/openid"
 	"github.com/pkg/errors"
@@ -19,18 +16,27 @@ import (
 
 // Authorizer is used to authenticate and authorize the user
 type Authorizer struct {
-	Variant           string          `envconfig:"auth_variant" default:"p-identity"`                    // IGNITION_AUTH_VARIANT
-	ServiceName       string          `envconfig:"auth_servicename" default:"ignition-identity"`         // IGNITION_AUTH_SERVICENAME
-	ClientID          string          `envconfig:"client_id"`                                            // IGNITION_CLIENT_ID << REQUIRED
-	ClientSecret      string          `envconfig:"client_secret"`                                        // IGNITION_CLIENT_SECRET << REQUIRED
-	URL               string          `envconfig:"auth_url"`                                             // IGNITION_AUTH_URL << REQUIRED
-	Domain            string          `envconfig:"authorized_domain"`                                    // IGNITION_AUTHORIZED_DOMAIN << REQUIRED
-	Scopes            []string        `envconfig:"auth_scopes" default:"openid,profile,user_attributes"` // IGNITION_AUTH_SCOPES
-	SkipTLSValidation bool            `envconfig:"skip_tls_validation" default:"false"`                  // IGNITION_SKIP_TLS_VALIDATION
-	Provider          *Provider       `ignored:"true"`
-	Verifier          openid.Verifier `ignored:"true"`
-	Fetcher           user.Fetcher    `ignored:"true"`
-	Config            *oauth2.Config  `ignored:"true"`
+	Variant             string          `envconfig:"auth_variant" default:"p-identity"`                    // IGNITION_AUTH_VARIANT
+	ServiceName         string          `envconfig:"auth_servicename" default:"ignition-identity"`         // IGNITION_AUTH_SERVICENAME
+	ClientID            string          `envconfig:"client_id"`                                            // IGNITION_CLIENT_ID << REQUIRED
+	ClientSecret        string          `envconfig:"client_secret"`                                        // IGNITION_CLIENT_SECRET << REQUIRED
+	URL                 string          `envconfig:"auth_url"`                                             // IGNITION_AUTH_URL << REQUIRED
+	Domains             []string        `envconfig:"authorized_domain" required:"true"`                    // IGNITION_AUTHORIZED_DOMAIN << REQUIRED
+	Scopes              []string        `envconfig:"auth_scopes" default:"openid,profile,user_attributes"` // IGNITION_AUTH_SCOPES
+	SkipTLSValidation   bool            `envconfig:"skip_tls_validation" default:"false"`                  // IGNITION_SKIP_TLS_VALIDATION
+	AdminEmails         []string        `envconfig:"admin_emails"`                                         // IGNITION_ADMIN_EMAILS
+	AllowedGroups       []string        `envconfig:"allowed_groups"`                                       // IGNITION_ALLOWED_GROUPS
+	DeniedGroups        []string        `envconfig:"denied_groups"`                                        // IGNITION_DENIED_GROUPS
+	GroupAttributes     []string        `envconfig:"auth_group_attributes" default:"groups"`               // IGNITION_AUTH_GROUP_ATTRIBUTES
+	FoundationAttribute string          `envconfig:"auth_foundation_attribute"`                            // IGNITION_AUTH_FOUNDATION_ATTRIBUTE
+	MatchSubdomains     bool            `envconfig:"authorized_subdomains" default:"false"`                // IGNITION_AUTHORIZED_SUBDOMAINS
+	AllowedUsers        []string        `envconfig:"allowed_users"`                                        // IGNITION_ALLOWED_USERS
+	DeniedUsers         []string        `envconfig:"denied_users"`                                         // IGNITION_DENIED_USERS
+	AdminGroups         []string        `envconfig:"admin_groups"`                                         // IGNITION_ADMIN_GROUPS
+	Provider            *Provider       `ignored:"true"`
+	Verifier            openid.Verifier `ignored:"true"`
+	Fetcher             user.Fetcher    `ignored:"true"`
+	Config              *oauth2.Config  `ignored:"true"`
 }
 
 // Provider is an OpenID Connect provider
@@ -41,62 +47,52 @@ type Provider struct {
 	JWKSURL         string   `json:"jwks_uri"`
 	UserInfoURL     string   `json:"userinfo_endpoint"`
 	ScopesSupported []string `json:"scopes_supported"`
+	EndSessionURL   string   `json:"end_session_endpoint"`
 }
 
-// NewAuthorizer uses environment variables to populate a new Authorizer
-func NewAuthorizer(name string) (*Authorizer, error) {
-	var a Authorizer
-	envconfig.Process(ignition, &a)
-	if cfenv.IsRunningOnCF() {
-		c, err := cfenv.Current()
-		if err != nil {
-			return nil, err
-		}
-		s, err := c.Services.WithName(name)
-		if err != nil {
-			return nil, err
-		}
-
-		variant, ok := s.CredentialString("auth_variant")
-		if ok && strings.TrimSpace(variant) != "" {
-			a.Variant = variant
-		}
-
-		scopes, ok := s.CredentialString("auth_scopes")
-		if ok && strings.TrimSpace(scopes) != "" {
-			a.Scopes = strings.Split(scopes, ",")
-		}
-
-		domain, ok := s.CredentialString("authorized_domain")
-		if ok && strings.TrimSpace(domain) != "" {
-			a.Domain = domain
-		}
-
-		authURL, ok := s.CredentialString("auth_url")
-		if ok && strings.TrimSpace(authURL) != "" {
-			a.URL = authURL
-		}
-
-		skipTLSValidation, ok := s.CredentialString("skip_tls_validation")
-		if ok {
-			if b, err := strconv.ParseBool(skipTLSValidation); err == nil {
-				a.SkipTLSValidation = b
-			}
-		}
+// Policy returns the policy that decides which users are authorized to use
+// ignition
+func (a *Authorizer) Policy() *user.Policy {
+	return &user.Policy{
+		Domains:         a.Domains,
+		MatchSubdomains: a.MatchSubdomains,
+		AllowedUsers:    a.AllowedUsers,
+		DeniedUsers:     a.DeniedUsers,
+		AllowedGroups:   a.AllowedGroups,
+		DeniedGroups:    a.DeniedGroups,
+		AdminEmails:     a.AdminEmails,
+		AdminGroups:     a.AdminGroups,
+	}
+}
 
-		clientID, ok := s.CredentialString("client_id")
-		if ok && strings.TrimSpace(clientID) != "" {
-			a.ClientID = clientID
-		}
-		clientSecret, ok := s.CredentialString("client_secret")
-		if ok && strings.TrimSpace(clientSecret) != "" {
-			a.ClientSecret = clientSecret
-		}
-		serviceName, ok := s.CredentialString("auth_servicename")
-		if ok && strings.TrimSpace(serviceName) != "" {
-			a.ServiceName = serviceName
-		}
+// NewAuthorizer uses the config file, environment variables, and the named
+// service to populate a new Authorizer, and discovers its OpenID Connect
+// provider
+func NewAuthorizer(name string) (*Authorizer, error) {
+	a, err := loadAuthorizer(name)
+	if err != nil {
+		return nil, err
+	}
+	err = a.discover()
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
 
+// loadAuthorizer populates an Authorizer from its settings, without
+// contacting its OpenID Connect provider
+func loadAuthorizer(name string) (*Authorizer, error) {
+	settings, err := loadSettings(name, true)
+	if err != nil {
+		return nil, err
+	}
+	var a Authorizer
+	err = settings.decode(&a)
+	if err != nil {
+		return nil, err
+	}
+	if c := settings.app; c != nil {
 		a.Variant = strings.TrimSpace(a.Variant)
 		a.ServiceName = strings.TrimSpace(a.ServiceName)
 		if strings.EqualFold(strings.TrimSpace(a.Variant), "p-identity") {
@@ -133,36 +129,72 @@ func NewAuthorizer(name string) (*Authorizer, error) {
 	if a.URL == "" {
 		return nil, errors.New("auth_url is required")
 	}
-	a.Domain = strings.TrimSpace(a.Domain)
-	if a.Domain == "" {
+	var domains []string
+	for _, domain := range a.Domains {
+		if domain = user.NormalizeDomain(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	a.Domains = domains
+	if len(a.Domains) == 0 {
 		return nil, errors.New("authorized_domain is required")
 	}
+	var admins []string
+	for _, email := range a.AdminEmails {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			admins = append(admins, email)
+		}
+	}
+	a.AdminEmails = admins
+	a.AllowedUsers = trimNames(a.AllowedUsers)
+	a.DeniedUsers = trimNames(a.DeniedUsers)
+	a.AdminGroups = trimNames(a.AdminGroups)
+	a.AllowedGroups = trimNames(a.AllowedGroups)
+	a.DeniedGroups = trimNames(a.DeniedGroups)
+	a.GroupAttributes = trimNames(a.GroupAttributes)
+	a.FoundationAttribute = strings.TrimSpace(a.FoundationAttribute)
+	return &a, nil
+}
+
+// wellKnownURL is the URL of the OpenID Connect provider's discovery document
+func (a *Authorizer) wellKnownURL() string {
+	return strings.TrimSuffix(a.URL, "/") + "/.well-known/openid-configuration"
+}
 
-	wellKnown := strings.TrimSuffix(a.URL, "/") + "/.well-known/openid-configuration"
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: a.SkipTLSValidation},
+// client returns an HTTP client for requests to the OpenID Connect provider
+func (a *Authorizer) client() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: a.SkipTLSValidation},
+		},
 	}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Get(wellKnown)
+}
+
+// discover fetches the OpenID Connect provider's discovery document, and
+// creates the verifier, fetcher, and OAuth2 config that use the provider
+func (a *Authorizer) discover() error {
+	resp, err := a.client().Get(a.wellKnownURL())
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 	body, _ := ioutil.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: %s", resp.Status, body)
+		return fmt.Errorf("%s: %s", resp.Status, body)
 	}
 
 	var p Provider
 	err = json.Unmarshal(body, &p)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	a.Provider = &p
 	// TODO: Warn when a.Scopes includes items that are not in p.ScopesSupported
 	a.Verifier = openid.NewVerifier(p.Issuer, a.ClientID, p.JWKSURL, a.SkipTLSValidation)
 	a.Fetcher = &openid.Fetcher{
-		Verifier: a.Verifier,
+		Verifier:            a.Verifier,
+		GroupAttributes:     a.GroupAttributes,
+		FoundationAttribute: a.FoundationAttribute,
 	}
 	a.Config = &oauth2.Config{
 		ClientID:     a.ClientID,
@@ -173,5 +205,5 @@ func NewAuthorizer(name string) (*Authorizer, error) {
 		},
 		Scopes: a.Scopes,
 	}
-	return &a, nil
+	return nil
 }
@@ -13,6 +13,7 @@ import (
 
 	. "github.com/onsi/gomega"
 	"github.com/pivotalservices/ignition/internal"
+	"github.com/pivotalservices/ignition/user/openid"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 )
@@ -41,6 +42,15 @@ func testNewAuthorizer(t *testing.T, when spec.G, it spec.S) {
 		os.Unsetenv("IGNITION_AUTH_URL")
 		os.Unsetenv("IGNITION_AUTHORIZED_DOMAIN")
 		os.Unsetenv("IGNITION_SKIP_TLS_VALIDATION")
+		os.Unsetenv("IGNITION_ADMIN_EMAILS")
+		os.Unsetenv("IGNITION_ALLOWED_GROUPS")
+		os.Unsetenv("IGNITION_DENIED_GROUPS")
+		os.Unsetenv("IGNITION_AUTH_GROUP_ATTRIBUTES")
+		os.Unsetenv("IGNITION_AUTH_FOUNDATION_ATTRIBUTE")
+		os.Unsetenv("IGNITION_AUTHORIZED_SUBDOMAINS")
+		os.Unsetenv("IGNITION_ALLOWED_USERS")
+		os.Unsetenv("IGNITION_DENIED_USERS")
+		os.Unsetenv("IGNITION_ADMIN_GROUPS")
 	}
 
 	it.Before(func() {
@@ -90,7 +100,61 @@ func testNewAuthorizer(t *testing.T, when spec.G, it spec.S) {
 			Expect(a.ClientID).To(Equal("test-ignition-client-id"))
 			Expect(a.ClientSecret).To(Equal("test-ignition-client-secret"))
 			Expect(a.URL).To(Equal(s.URL))
-			Expect(a.Domain).To(Equal("test-ignition-authorized-domain"))
+			Expect(a.Domains).To(Equal([]string{"test-ignition-authorized-domain"}))
+			Expect(a.Provider.EndSessionURL).To(Equal(s.URL + "/logout.do"))
+		})
+
+		it("reads groups from the groups user attribute by default", func() {
+			a, err := NewAuthorizer("ignition-config")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.GroupAttributes).To(Equal([]string{"groups"}))
+			Expect(a.Policy().AllowedGroups).To(BeEmpty())
+			Expect(a.Policy().DeniedGroups).To(BeEmpty())
+		})
+
+		it("configures the authorized domains and users", func() {
+			os.Setenv("IGNITION_AUTHORIZED_DOMAIN", "@Example.net, example.org")
+			os.Setenv("IGNITION_AUTHORIZED_SUBDOMAINS", "true")
+			os.Setenv("IGNITION_ALLOWED_USERS", "partner@example.com")
+			os.Setenv("IGNITION_DENIED_USERS", "corp\\intern, former@example.net")
+			a, err := NewAuthorizer("ignition-config")
+			Expect(err).NotTo(HaveOccurred())
+			p := a.Policy()
+			Expect(p.Domains).To(Equal([]string{"example.net", "example.org"}))
+			Expect(p.MatchSubdomains).To(BeTrue())
+			Expect(p.AllowedUsers).To(Equal([]string{"partner@example.com"}))
+			Expect(p.DeniedUsers).To(Equal([]string{"corp\\intern", "former@example.net"}))
+		})
+
+		it("configures the admins", func() {
+			os.Setenv("IGNITION_ADMIN_EMAILS", "Admin@example.net, ")
+			os.Setenv("IGNITION_ADMIN_GROUPS", "platform-operators")
+			a, err := NewAuthorizer("ignition-config")
+			Expect(err).NotTo(HaveOccurred())
+			p := a.Policy()
+			Expect(p.AdminEmails).To(Equal([]string{"admin@example.net"}))
+			Expect(p.AdminGroups).To(Equal([]string{"platform-operators"}))
+		})
+
+		it("configures the group policy", func() {
+			os.Setenv("IGNITION_ALLOWED_GROUPS", "engineering, platform")
+			os.Setenv("IGNITION_DENIED_GROUPS", "contractors")
+			os.Setenv("IGNITION_AUTH_GROUP_ATTRIBUTES", "memberOf")
+			a, err := NewAuthorizer("ignition-config")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.GroupAttributes).To(Equal([]string{"memberOf"}))
+			p := a.Policy()
+			Expect(p.Domains).To(Equal([]string{"test-ignition-authorized-domain"}))
+			Expect(p.AllowedGroups).To(Equal([]string{"engineering", "platform"}))
+			Expect(p.DeniedGroups).To(Equal([]string{"contractors"}))
+		})
+
+		it("configures the attribute that assigns users to a foundation", func() {
+			os.Setenv("IGNITION_AUTH_FOUNDATION_ATTRIBUTE", " region ")
+			a, err := NewAuthorizer("ignition-config")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.FoundationAttribute).To(Equal("region"))
+			Expect(a.Fetcher.(*openid.Fetcher).FoundationAttribute).To(Equal("region"))
 		})
 
 		when("there is an error fetching the well known metadata", func() {
@@ -200,7 +264,11 @@ func testNewAuthorizer(t *testing.T, when spec.G, it spec.S) {
 								"skip_tls_validation": "true",
 								"client_id": "test-service-client-id",
 								"client_secret": "test-service-client-secret",
-								"auth_servicename": "test-service-client-servicename"
+								"auth_servicename": "test-service-client-servicename",
+								"allowed_groups": "engineering,platform",
+								"denied_groups": "contractors",
+								"authorized_subdomains": "true",
+								"denied_users": "former@example.net"
 							}
 						}
 					]
@@ -217,8 +285,12 @@ func testNewAuthorizer(t *testing.T, when spec.G, it spec.S) {
 				Expect(a.ClientID).To(Equal("test-service-client-id"))
 				Expect(a.ClientSecret).To(Equal("test-service-client-secret"))
 				Expect(a.URL).To(Equal(s.URL))
-				Expect(a.Domain).To(Equal("test-service-authorized-domain"))
+				Expect(a.Domains).To(Equal([]string{"test-service-authorized-domain"}))
 				Expect(a.ServiceName).To(Equal("test-service-client-servicename"))
+				Expect(a.AllowedGroups).To(Equal([]string{"engineering", "platform"}))
+				Expect(a.DeniedGroups).To(Equal([]string{"contractors"}))
+				Expect(a.MatchSubdomains).To(BeTrue())
+				Expect(a.DeniedUsers).To(Equal([]string{"former@example.net"}))
 			})
 
 			when("the variant is p-identity and there is no identity service", func() {
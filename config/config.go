@@ -8,12 +8,22 @@ type Ignition struct {
 	Deployment   *Deployment
 	Experimenter *Experimenter
 	Authorizer   *Authorizer
+
+	// Foundations are the foundations that orgs are provisioned in; the
+	// first is the Deployment
+	Foundations []*Foundation
 }
 
-// New builds configuration for Ignition using the environment and an associated
-// Cloud Foundry user provided service named `ignition-config`, optionally
-// making use of a bound p-identity service instance named `ignition`
+// New builds configuration for Ignition using the config file, the environment
+// and an associated Cloud Foundry user provided service named
+// `ignition-config`, optionally making use of a bound p-identity service
+// instance named `ignition`. Every invalid setting is reported before any
+// service is contacted.
 func New() (*Ignition, error) {
+	err := Validate()
+	if err != nil {
+		return nil, err
+	}
 	i := &Ignition{}
 	s, err := NewServer()
 	if err != nil {
@@ -30,10 +40,15 @@ func New() (*Ignition, error) {
 		return nil, err
 	}
 	i.Deployment = d
-	e, err := NewExperimenter(s.ServiceName, i.Deployment.CC, i.Deployment.CC)
+	e, err := NewExperimenter(s.ServiceName, i.Deployment.CC, i.Deployment.CC, i.Deployment.CC)
 	if err != nil {
 		return nil, err
 	}
 	i.Experimenter = e
+	f, err := NewFoundations(s.ServiceName, i.Deployment, i.Experimenter)
+	if err != nil {
+		return nil, err
+	}
+	i.Foundations = f
 	return i, nil
 }
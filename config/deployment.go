@@ -4,90 +4,96 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 
 	cfclient "github.com/cloudfoundry-community/go-cfclient"
-	cfenv "github.com/cloudfoundry-community/go-cfenv"
-	"github.com/kelseyhightower/envconfig"
 	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/metrics"
 	"github.com/pivotalservices/ignition/uaa"
 	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 )
 
+// The versions of the Cloud Controller API that ignition can use to manage
+// orgs, spaces, roles, quotas, and isolation segments
+const (
+	CCAPIVersion2 = "v2"
+	CCAPIVersion3 = "v3"
+)
+
 // Deployment is a Cloud Foundry Deployment
 type Deployment struct {
-	SystemDomain      string           `envconfig:"system_domain"`                       // IGNITION_SYSTEM_DOMAIN << REQUIRED
+	SystemDomain      string           `envconfig:"system_domain" required:"true"`       // IGNITION_SYSTEM_DOMAIN << REQUIRED
 	AppsURL           string           `ignored:"true"`                                  // Ignored
 	APIURL            string           `ignored:"true"`                                  // Ignored
 	UAAURL            string           `ignored:"true"`                                  // Ignored
-	UAAOrigin         string           `envconfig:"uaa_origin"`                          // IGNITION_UAA_ORIGIN << REQUIRED
-	ClientID          string           `envconfig:"api_client_id"`                       // IGNITION_API_CLIENT_ID << REQUIRED
-	ClientSecret      string           `envconfig:"api_client_secret"`                   // IGNITION_API_CLIENT_SECRET << REQUIRED
+	UAAOrigin         string           `envconfig:"uaa_origin" required:"true"`          // IGNITION_UAA_ORIGIN << REQUIRED
+	ClientID          string           `envconfig:"api_client_id" required:"true"`       // IGNITION_API_CLIENT_ID << REQUIRED
+	ClientSecret      string           `envconfig:"api_client_secret" required:"true"`   // IGNITION_API_CLIENT_SECRET << REQUIRED
 	SkipTLSValidation bool             `envconfig:"skip_tls_validation" default:"false"` // IGNITION_SKIP_TLS_VALIDATION
+	CCAPIVersion      string           `envconfig:"cc_api_version" default:"v2"`         // IGNITION_CC_API_VERSION (v2 or v3)
 	CC                cloudfoundry.API `ignored:"true"`                                  // Ignored
 	UAA               uaa.API          `ignored:"true"`                                  // Ignored
 }
 
-// NewDeployment uses environment variables to populate a Deployment
+// NewDeployment uses the config file, environment variables, and the named
+// service to populate a Deployment
 func NewDeployment(name string) (*Deployment, error) {
+	settings, err := loadSettings(name, true)
+	if err != nil {
+		return nil, err
+	}
 	var d Deployment
-	envconfig.Process(ignition, &d)
-	if cfenv.IsRunningOnCF() {
-		c, err := cfenv.Current()
-		if err != nil {
-			return nil, err
-		}
-		s, err := c.Services.WithName(name)
-		if err != nil {
-			return nil, err
-		}
-
-		systemDomain, ok := s.CredentialString("system_domain")
-		if ok && strings.TrimSpace(systemDomain) != "" {
-			d.SystemDomain = systemDomain
-		}
-
-		skipTLSValidation, ok := s.CredentialString("skip_tls_validation")
-		if ok {
-			if b, err := strconv.ParseBool(skipTLSValidation); err == nil {
-				d.SkipTLSValidation = b
-			}
-		}
-
-		uaaOrigin, ok := s.CredentialString("uaa_origin")
-		if ok && strings.TrimSpace(uaaOrigin) != "" {
-			d.UAAOrigin = uaaOrigin
-		}
-
-		clientID, ok := s.CredentialString("api_client_id")
-		if ok && strings.TrimSpace(clientID) != "" {
-			d.ClientID = clientID
-		}
-		clientSecret, ok := s.CredentialString("api_client_secret")
-		if ok && strings.TrimSpace(clientSecret) != "" {
-			d.ClientSecret = clientSecret
-		}
+	err = settings.decode(&d)
+	if err != nil {
+		return nil, err
+	}
+	err = d.connect()
+	if err != nil {
+		return nil, err
 	}
+	return &d, nil
+}
+
+// connect validates the Deployment, and creates the clients for its Cloud
+// Controller and UAA
+func (d *Deployment) connect() error {
 	if strings.TrimSpace(d.SystemDomain) == "" {
-		return nil, errors.New("system_domain is required")
+		return errors.New("system_domain is required")
 	}
 	d.ParseSystemDomain()
 	d.UAAOrigin = strings.TrimSpace(d.UAAOrigin)
 	if d.UAAOrigin == "" {
-		return nil, errors.New("uaa_origin is required")
+		return errors.New("uaa_origin is required")
 	}
 	if strings.TrimSpace(d.ClientID) == "" {
-		return nil, errors.New("api_client_id is required")
+		return errors.New("api_client_id is required")
 	}
 	if strings.TrimSpace(d.ClientSecret) == "" {
-		return nil, errors.New("api_client_secret is required")
+		return errors.New("api_client_secret is required")
+	}
+	d.CCAPIVersion = strings.ToLower(strings.TrimSpace(d.CCAPIVersion))
+	if d.CCAPIVersion != CCAPIVersion2 && d.CCAPIVersion != CCAPIVersion3 {
+		return errors.Errorf("cc_api_version must be %s or %s, not [%s]", CCAPIVersion2, CCAPIVersion3, d.CCAPIVersion)
 	}
 
+	// requests to the UAA (including for tokens) and to the Cloud Controller
+	// are instrumented separately
 	uaaConfig := d.Config()
-	uaaClient := uaaConfig.Client(context.Background())
+	uaaContext := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport: &metrics.Transport{Upstream: metrics.UpstreamUAA},
+	})
+	uaaClient := uaaConfig.Client(uaaContext)
+	tokenSource := uaaConfig.TokenSource(uaaContext)
+	ccClient := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: tokenSource,
+			Base:   &metrics.Transport{Upstream: metrics.UpstreamCloudController},
+		},
+	}
 
 	uaaAPI := &uaa.Client{
 		URL:          d.UAAURL,
@@ -103,19 +109,27 @@ func NewDeployment(name string) (*Deployment, error) {
 		ClientSecret:      d.ClientSecret,
 		UserAgent:         "ignition-api",
 		SkipSslValidation: d.SkipTLSValidation,
-		HttpClient:        uaaClient,
-		TokenSource:       uaaConfig.TokenSource(context.Background()),
+		HttpClient:        ccClient,
+		TokenSource:       tokenSource,
 	}
 
-	d.CC = &cfclient.Client{
-		Config: *config,
-		Endpoint: cfclient.Endpoint{
-			TokenEndpoint: uaaConfig.TokenURL,
+	d.CC = &cloudfoundry.V2Client{
+		Client: &cfclient.Client{
+			Config: *config,
+			Endpoint: cfclient.Endpoint{
+				TokenEndpoint: uaaConfig.TokenURL,
+			},
 		},
 	}
+	if d.CCAPIVersion == CCAPIVersion3 {
+		d.CC = &cloudfoundry.V3Client{
+			URL:    d.APIURL,
+			Client: ccClient,
+		}
+	}
 
 	d.UAA = uaaAPI
-	return &d, nil
+	return nil
 }
 
 // Config builds an oauth2.Config for the Deployment
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 )
@@ -24,6 +25,7 @@ func testDeployment(t *testing.T, when spec.G, it spec.S) {
 		os.Unsetenv("IGNITION_API_CLIENT_ID")
 		os.Unsetenv("IGNITION_API_CLIENT_SECRET")
 		os.Unsetenv("IGNITION_SKIP_TLS_VALIDATION")
+		os.Unsetenv("IGNITION_CC_API_VERSION")
 	}
 
 	it.Before(func() {
@@ -92,6 +94,8 @@ func testDeployment(t *testing.T, when spec.G, it spec.S) {
 				Expect(d.UAAOrigin).To(Equal("okta"))
 				Expect(d.ClientID).To(Equal("test-client-id"))
 				Expect(d.ClientSecret).To(Equal("test-client-secret"))
+				Expect(d.CCAPIVersion).To(Equal(CCAPIVersion2))
+				Expect(d.CC).To(BeAssignableToTypeOf(&cloudfoundry.V2Client{}))
 			})
 
 			it("can generate an oauth2.Config", func() {
@@ -119,6 +123,32 @@ func testDeployment(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 
+			when("the cc api version is v3", func() {
+				it.Before(func() {
+					os.Setenv("IGNITION_CC_API_VERSION", "V3")
+				})
+
+				it("uses the v3 api", func() {
+					d, err := NewDeployment("ignition-config")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(d.CCAPIVersion).To(Equal(CCAPIVersion3))
+					Expect(d.CC).To(BeAssignableToTypeOf(&cloudfoundry.V3Client{}))
+					Expect(d.CC.(*cloudfoundry.V3Client).URL).To(Equal("https://api.run.example.com"))
+				})
+			})
+
+			when("the cc api version is not supported", func() {
+				it.Before(func() {
+					os.Setenv("IGNITION_CC_API_VERSION", "v4")
+				})
+
+				it("errors", func() {
+					d, err := NewDeployment("ignition-config")
+					Expect(err).To(HaveOccurred())
+					Expect(d).To(BeNil())
+				})
+			})
+
 			when("the system domain is empty", func() {
 				it.Before(func() {
 					os.Setenv("IGNITION_SYSTEM_DOMAIN", "")
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	cfenv "github.com/cloudfoundry-community/go-cfenv"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// Check is the result of checking that ignition can use one of the services
+// it is configured with. A check is skipped when a check that it depends on
+// fails.
+type Check struct {
+	Name    string
+	Detail  string
+	Err     error
+	Skipped bool
+}
+
+// Failed returns true when any of the checks failed
+func Failed(checks []Check) bool {
+	for _, c := range checks {
+		if c.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// doctor records the checks of ignition's configuration
+type doctor struct {
+	checks []Check
+}
+
+// check records the result of the check, returning true when it passed
+func (d *doctor) check(name string, detail string, err error) bool {
+	d.checks = append(d.checks, Check{Name: name, Detail: detail, Err: err})
+	return err == nil
+}
+
+// skip records that the check was skipped, and why
+func (d *doctor) skip(name string, reason string) {
+	d.checks = append(d.checks, Check{Name: name, Detail: reason, Skipped: true})
+}
+
+// Diagnose loads ignition's configuration and checks that ignition can use the
+// services it is configured with: it discovers the OpenID Connect provider and
+// fetches its keys, gets a token from each foundation's UAA with the scopes
+// that ignition requires, finds each foundation's quota, isolation segment,
+// and security groups, and, on Cloud Foundry, checks that ignition's domain is
+// mapped to the app.
+func Diagnose() []Check {
+	d := &doctor{}
+	settings, err := loadSettings("", false)
+	if err == nil {
+		err = Validate()
+	}
+	if !d.check("configuration", "", err) {
+		return d.checks
+	}
+	name := settings.serviceName()
+
+	s, err := NewServer()
+	if d.check("server", "", err) {
+		d.checkRoute(s)
+	} else {
+		d.skip("route mapping", "the server could not be configured")
+	}
+
+	a, err := loadAuthorizer(name)
+	if err == nil {
+		err = a.discover()
+	}
+	if d.check("OIDC discovery", authDetail(a), err) {
+		d.checkKeys(a)
+	} else {
+		d.skip("JWKS", "the OpenID Connect provider could not be discovered")
+	}
+
+	deployment, err := NewDeployment(name)
+	if !d.check("deployment", "", err) {
+		return d.checks
+	}
+	e, err := loadExperimenter(name)
+	if !d.check("org template", "", err) {
+		return d.checks
+	}
+	foundations, err := loadFoundations(name, deployment, e)
+	if !d.check("foundations", strings.Join(foundationNames(foundations), ", "), err) {
+		return d.checks
+	}
+	for _, f := range foundations {
+		d.checkFoundation(f, e, len(foundations) > 1)
+	}
+	return d.checks
+}
+
+// checkRoute checks that the server's domain is one of the app's routes, when
+// it is running on Cloud Foundry
+func (d *doctor) checkRoute(s *Server) {
+	if !cfenv.IsRunningOnCF() {
+		d.skip("route mapping", "not running on Cloud Foundry")
+		return
+	}
+	app, err := cfenv.Current()
+	if err != nil {
+		d.check("route mapping", "", err)
+		return
+	}
+	for _, uri := range app.ApplicationURIs {
+		if strings.EqualFold(uri, s.Domain) {
+			d.check("route mapping", fmt.Sprintf("%s is mapped to %s", s.Domain, app.Name), nil)
+			return
+		}
+	}
+	d.check("route mapping", "", errors.Errorf("%s is not mapped to %s; its routes are [%s]", s.Domain, app.Name, strings.Join(app.ApplicationURIs, ", ")))
+}
+
+// checkKeys checks that the OpenID Connect provider's key set can be fetched,
+// and has keys to verify ID tokens with
+func (d *doctor) checkKeys(a *Authorizer) {
+	url := a.Provider.JWKSURL
+	resp, err := a.client().Get(url)
+	if err != nil {
+		d.check("JWKS", url, err)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		d.check("JWKS", url, fmt.Errorf("%s: %s", resp.Status, body))
+		return
+	}
+	var keys jose.JSONWebKeySet
+	err = json.Unmarshal(body, &keys)
+	if err != nil {
+		d.check("JWKS", url, errors.Wrap(err, "could not parse the key set"))
+		return
+	}
+	if len(keys.Keys) == 0 {
+		d.check("JWKS", url, errors.New("the key set has no keys"))
+		return
+	}
+	d.check("JWKS", fmt.Sprintf("%d keys from %s", len(keys.Keys), url), nil)
+}
+
+// checkFoundation checks that a token can be issued to the foundation's API
+// client with the scopes that ignition requires, and finds the foundation's
+// quota, isolation segment, and security groups. The checks are named for the
+// foundation when there is more than one.
+func (d *doctor) checkFoundation(f *Foundation, e *Experimenter, named bool) {
+	name := func(check string) string {
+		if named {
+			return fmt.Sprintf("%s [%s]", check, f.Name)
+		}
+		return check
+	}
+	detail, err := checkToken(f.Deployment)
+	if !d.check(name("UAA token"), detail, err) {
+		reason := "a UAA token could not be issued"
+		d.skip(name("quota"), reason)
+		d.skip(name("isolation segment"), reason)
+		d.skip(name("security groups"), reason)
+		return
+	}
+
+	cc := f.Deployment.CC
+	quotaName, quotaID, err := findQuota(f.QuotaName, cc)
+	detail = fmt.Sprintf("%s (%s)", quotaName, quotaID)
+	if err == nil && quotaFellBack(f.QuotaName, quotaName) {
+		// ignition still starts, but the orgs it creates get the default
+		// quota, and idle orgs are not reaped
+		detail = fmt.Sprintf("orgs would use %s (%s)", quotaName, quotaID)
+		err = errors.Errorf("quota [%s] was not found, so ignition falls back to the %s quota", f.QuotaName, quotaName)
+	}
+	d.check(name("quota"), detail, err)
+
+	isoSegmentName, isoSegmentID, err := findISOSegment(f.ISOSegmentName, cc)
+	d.check(name("isolation segment"), fmt.Sprintf("%s (%s)", isoSegmentName, isoSegmentID), err)
+
+	groups := e.Template.SecurityGroups()
+	if len(groups) == 0 {
+		d.skip(name("security groups"), "the org template does not use any")
+		return
+	}
+	d.check(name("security groups"), strings.Join(groups, ", "), findSecurityGroups(e.Template, cc))
+}
+
+// checkToken gets a token for the Deployment's API client, and checks that it
+// has the scopes that ignition requires. It fails when the token's scopes
+// cannot be determined, rather than passing a client that may lack them.
+func checkToken(d *Deployment) (string, error) {
+	config := d.Config()
+	token, err := config.Token(context.Background())
+	if err != nil {
+		return config.TokenURL, errors.Wrapf(err, "could not get a token for client [%s]", d.ClientID)
+	}
+	granted, ok := tokenScopes(token)
+	if !ok {
+		return config.TokenURL, errors.Errorf("could not determine the scopes of the token for client [%s], which must have the scopes [%s]", d.ClientID, strings.Join(config.Scopes, ", "))
+	}
+	var missing []string
+	for _, scope := range config.Scopes {
+		if !hasScope(granted, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return config.TokenURL, errors.Errorf("the token for client [%s] does not have the scopes [%s]", d.ClientID, strings.Join(missing, ", "))
+	}
+	return fmt.Sprintf("client %s has %s", d.ClientID, strings.Join(config.Scopes, ", ")), nil
+}
+
+// tokenScopes returns the space separated scopes of the token: those in the
+// token response, or else those in the scope claim of the access token, which
+// UAA issues as a JWT. The claim is read without verifying the token, which
+// was just issued to ignition by the UAA. It returns false when neither has
+// the scopes.
+func tokenScopes(token *oauth2.Token) (string, bool) {
+	if granted, ok := token.Extra("scope").(string); ok {
+		return granted, true
+	}
+	parts := strings.Split(token.AccessToken, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", false
+	}
+	var claims struct {
+		Scope interface{} `json:"scope"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return "", false
+	}
+	switch scope := claims.Scope.(type) {
+	case string:
+		return scope, true
+	case []interface{}:
+		var scopes []string
+		for _, s := range scope {
+			if name, ok := s.(string); ok {
+				scopes = append(scopes, name)
+			}
+		}
+		return strings.Join(scopes, " "), true
+	}
+	return "", false
+}
+
+// hasScope returns true when the space separated scopes include the scope
+func hasScope(scopes string, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// authDetail describes the OpenID Connect provider that the Authorizer uses
+func authDetail(a *Authorizer) string {
+	if a == nil || a.URL == "" {
+		return ""
+	}
+	return a.wellKnownURL()
+}
+
+// foundationNames returns the names of the foundations
+func foundationNames(foundations []*Foundation) []string {
+	var names []string
+	for _, f := range foundations {
+		names = append(names, f.Name)
+	}
+	return names
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/internal/fakecf"
+	"github.com/pivotalservices/ignition/user/openid/fakeprovider"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestDiagnose(t *testing.T) {
+	spec.Run(t, "Diagnose", testDiagnose, spec.Report(report.Terminal{}))
+}
+
+func testDiagnose(t *testing.T, when spec.G, it spec.S) {
+	var (
+		foundation *fakecf.Server
+		cf, idp    *httptest.Server
+		dir        string
+		find       func(checks []Check, name string) Check
+	)
+
+	reset := func() {
+		os.Unsetenv("VCAP_APPLICATION")
+		os.Unsetenv("VCAP_SERVICES")
+		os.Unsetenv("PORT")
+
+		os.Unsetenv(ConfigFileEnv)
+		os.Unsetenv("IGNITION_SESSION_SECRET")
+		os.Unsetenv("IGNITION_DOMAIN")
+		os.Unsetenv("IGNITION_AUTH_VARIANT")
+		os.Unsetenv("IGNITION_CLIENT_ID")
+		os.Unsetenv("IGNITION_CLIENT_SECRET")
+		os.Unsetenv("IGNITION_AUTH_URL")
+		os.Unsetenv("IGNITION_AUTHORIZED_DOMAIN")
+		os.Unsetenv("IGNITION_SYSTEM_DOMAIN")
+		os.Unsetenv("IGNITION_UAA_ORIGIN")
+		os.Unsetenv("IGNITION_API_CLIENT_ID")
+		os.Unsetenv("IGNITION_API_CLIENT_SECRET")
+		os.Unsetenv("IGNITION_QUOTA_NAME")
+		os.Unsetenv("IGNITION_ISO_SEGMENT_NAME")
+		os.Unsetenv("IGNITION_RUNNING_SECURITY_GROUPS")
+		os.Unsetenv("IGNITION_FOUNDATIONS_FILE")
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		reset()
+		provider, err := fakeprovider.New("ignition", "ignition-secret")
+		Expect(err).NotTo(HaveOccurred())
+		idp = httptest.NewServer(provider)
+		provider.Issuer = idp.URL
+
+		foundation = fakecf.New("ignition-api", "ignition-api-secret")
+		foundation.AddOrgQuota("ignition")
+		cf = httptest.NewServer(foundation)
+
+		os.Setenv("IGNITION_SESSION_SECRET", "test-session-secret")
+		os.Setenv("IGNITION_AUTH_VARIANT", "openid")
+		os.Setenv("IGNITION_CLIENT_ID", "ignition")
+		os.Setenv("IGNITION_CLIENT_SECRET", "ignition-secret")
+		os.Setenv("IGNITION_AUTH_URL", idp.URL)
+		os.Setenv("IGNITION_AUTHORIZED_DOMAIN", "example.net")
+		os.Setenv("IGNITION_SYSTEM_DOMAIN", cf.URL)
+		os.Setenv("IGNITION_UAA_ORIGIN", "ignition-sso")
+		os.Setenv("IGNITION_API_CLIENT_ID", "ignition-api")
+		os.Setenv("IGNITION_API_CLIENT_SECRET", "ignition-api-secret")
+
+		dir, err = ioutil.TempDir("", "doctor")
+		Expect(err).NotTo(HaveOccurred())
+		find = func(checks []Check, name string) Check {
+			for _, c := range checks {
+				if c.Name == name {
+					return c
+				}
+			}
+			t.Fatalf("there is no %s check", name)
+			return Check{}
+		}
+	})
+
+	it.After(func() {
+		reset()
+		idp.Close()
+		cf.Close()
+		os.RemoveAll(dir)
+	})
+
+	it("passes when ignition can use every service it is configured with", func() {
+		foundation.AddSecurityGroup("internal-dns")
+		os.Setenv("IGNITION_RUNNING_SECURITY_GROUPS", "internal-dns")
+		checks := Diagnose()
+		Expect(Failed(checks)).To(BeFalse())
+		var names []string
+		for _, c := range checks {
+			names = append(names, c.Name)
+		}
+		Expect(names).To(Equal([]string{
+			"configuration", "server", "route mapping", "OIDC discovery", "JWKS",
+			"deployment", "org template", "foundations",
+			"UAA token", "quota", "isolation segment", "security groups",
+		}))
+		Expect(find(checks, "route mapping").Skipped).To(BeTrue())
+		Expect(find(checks, "OIDC discovery").Detail).To(Equal(idp.URL + "/.well-known/openid-configuration"))
+		Expect(find(checks, "JWKS").Detail).To(Equal("1 keys from " + idp.URL + fakeprovider.KeysPath))
+		Expect(find(checks, "UAA token").Detail).To(Equal("client ignition-api has cloud_controller.admin, scim.write, scim.read"))
+		Expect(find(checks, "quota").Detail).To(HavePrefix("ignition ("))
+		Expect(find(checks, "isolation segment").Detail).To(HavePrefix("shared ("))
+		Expect(find(checks, "security groups").Detail).To(Equal("internal-dns"))
+	})
+
+	it("only checks the configuration when it is invalid", func() {
+		os.Unsetenv("IGNITION_SESSION_SECRET")
+		checks := Diagnose()
+		Expect(Failed(checks)).To(BeTrue())
+		Expect(checks).To(HaveLen(1))
+		Expect(checks[0].Name).To(Equal("configuration"))
+		Expect(checks[0].Err).To(MatchError("invalid configuration: session_secret is required"))
+	})
+
+	it("skips the JWKS check when the OpenID Connect provider cannot be discovered", func() {
+		idp.Close()
+		checks := Diagnose()
+		Expect(Failed(checks)).To(BeTrue())
+		Expect(find(checks, "OIDC discovery").Err).To(HaveOccurred())
+		Expect(find(checks, "JWKS").Skipped).To(BeTrue())
+		Expect(find(checks, "UAA token").Err).NotTo(HaveOccurred())
+	})
+
+	it("skips the foundation's lookups when a UAA token cannot be issued", func() {
+		os.Setenv("IGNITION_API_CLIENT_SECRET", "wrong-secret")
+		checks := Diagnose()
+		Expect(Failed(checks)).To(BeTrue())
+		Expect(find(checks, "UAA token").Err).To(MatchError(ContainSubstring("could not get a token for client [ignition-api]")))
+		Expect(find(checks, "quota").Skipped).To(BeTrue())
+		Expect(find(checks, "isolation segment").Skipped).To(BeTrue())
+		Expect(find(checks, "security groups").Skipped).To(BeTrue())
+	})
+
+	it("fails when the API client does not have the scopes that ignition requires", func() {
+		foundation.AddClient("limited-api", "limited-api-secret", "cloud_controller.admin", "scim.read")
+		os.Setenv("IGNITION_API_CLIENT_ID", "limited-api")
+		os.Setenv("IGNITION_API_CLIENT_SECRET", "limited-api-secret")
+		checks := Diagnose()
+		Expect(Failed(checks)).To(BeTrue())
+		Expect(find(checks, "UAA token").Err).To(HaveOccurred())
+		Expect(find(checks, "quota").Skipped).To(BeTrue())
+	})
+
+	it("fails when the quota falls back to the default, or the isolation segment does not exist", func() {
+		os.Setenv("IGNITION_ISO_SEGMENT_NAME", "missing")
+		os.Setenv("IGNITION_QUOTA_NAME", "missing")
+		checks := Diagnose()
+		Expect(Failed(checks)).To(BeTrue())
+		quota := find(checks, "quota")
+		Expect(quota.Err).To(MatchError("quota [missing] was not found, so ignition falls back to the default quota"))
+		Expect(quota.Detail).To(HavePrefix("orgs would use default ("))
+		Expect(find(checks, "isolation segment").Err).To(HaveOccurred())
+	})
+
+	it("names the checks of each foundation when there is more than one", func() {
+		emea := fakecf.New("ignition-api", "ignition-api-secret")
+		emeaCF := httptest.NewServer(emea)
+		defer emeaCF.Close()
+		file := filepath.Join(dir, "foundations.json")
+		Expect(ioutil.WriteFile(file, []byte(fmt.Sprintf(`[{"name": "emea", "system_domain": "%s", "quota_name": "emea"}]`, emeaCF.URL)), 0600)).To(Succeed())
+		os.Setenv("IGNITION_FOUNDATIONS_FILE", file)
+		checks := Diagnose()
+		Expect(find(checks, "foundations").Detail).To(Equal("default, emea"))
+		Expect(find(checks, "UAA token [emea]").Err).NotTo(HaveOccurred())
+		Expect(find(checks, "quota [default]").Detail).To(HavePrefix("ignition ("))
+		Expect(find(checks, "quota [emea]").Err).To(MatchError("quota [emea] was not found, so ignition falls back to the default quota"))
+	})
+
+	it("checks that the domain is mapped to the app on Cloud Foundry", func() {
+		os.Setenv("VCAP_APPLICATION", `{"application_name": "ignition", "application_uris": ["ignition.example.net"], "name": "ignition"}`)
+		os.Setenv("PORT", "54321")
+		os.Setenv("VCAP_SERVICES", `{"user-provided": [{"name": "ignition-config", "credentials": {}}]}`)
+		os.Setenv("IGNITION_DOMAIN", "ignition.example.net")
+		check := find(Diagnose(), "route mapping")
+		Expect(check.Err).NotTo(HaveOccurred())
+		Expect(check.Detail).To(Equal("ignition.example.net is mapped to ignition"))
+
+		os.Setenv("IGNITION_DOMAIN", "ignition.example.com")
+		check = find(Diagnose(), "route mapping")
+		Expect(check.Err).To(MatchError("ignition.example.com is not mapped to ignition; its routes are [ignition.example.net]"))
+	})
+}
+
+func TestCheckToken(t *testing.T) {
+	spec.Run(t, "checkToken", testCheckToken, spec.Report(report.Terminal{}))
+}
+
+func testCheckToken(t *testing.T, when spec.G, it spec.S) {
+	var (
+		uaa         *httptest.Server
+		accessToken string
+		d           *Deployment
+	)
+
+	// jwt returns an unsigned JWT with the claims
+	jwt := func(claims string) string {
+		encode := base64.RawURLEncoding.EncodeToString
+		return encode([]byte(`{"alg":"RS256"}`)) + "." + encode([]byte(claims)) + ".signature"
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		uaa = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			// the token response does not list the token's scopes
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token": %q, "token_type": "bearer", "expires_in": 43199}`, accessToken)
+		}))
+		d = &Deployment{ClientID: "ignition-api", ClientSecret: "ignition-api-secret", UAAURL: uaa.URL}
+	})
+
+	it.After(func() {
+		uaa.Close()
+	})
+
+	it("reads the scopes from the access token's claims", func() {
+		accessToken = jwt(`{"scope": ["cloud_controller.admin", "scim.read", "scim.write"]}`)
+		detail, err := checkToken(d)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(detail).To(Equal("client ignition-api has cloud_controller.admin, scim.write, scim.read"))
+	})
+
+	it("fails when the access token's claims do not have the scopes that ignition requires", func() {
+		accessToken = jwt(`{"scope": "cloud_controller.admin scim.read"}`)
+		_, err := checkToken(d)
+		Expect(err).To(MatchError("the token for client [ignition-api] does not have the scopes [scim.write]"))
+	})
+
+	it("fails when the scopes of the token cannot be determined", func() {
+		accessToken = "opaque-token"
+		_, err := checkToken(d)
+		Expect(err).To(MatchError(ContainSubstring("could not determine the scopes of the token for client [ignition-api]")))
+	})
+}
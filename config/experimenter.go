@@ -6,8 +6,6 @@ import (
 	"strings"
 	"time"
 
-	cfenv "github.com/cloudfoundry-community/go-cfenv"
-	"github.com/kelseyhightower/envconfig"
 	"github.com/pivotalservices/ignition/cloudfoundry"
 	"github.com/pkg/errors"
 )
@@ -25,72 +23,169 @@ type Experimenter struct {
 	QuotaID                string        `ignored:"true"`
 	ISOSegmentName         string        `envconfig:"iso_segment_name" default:"shared"` // IGNITION_ISO_SEGMENT_NAME
 	ISOSegmentID           string        `ignored:"true"`
+	OrgTTL                 time.Duration `envconfig:"org_ttl" default:"0s"`             // IGNITION_ORG_TTL (0 disables reaping of idle orgs)
+	OrgExpiryWarning       time.Duration `envconfig:"org_expiry_warning" default:"72h"` // IGNITION_ORG_EXPIRY_WARNING
+	OrgReapInterval        time.Duration `envconfig:"org_reap_interval" default:"1h"`   // IGNITION_ORG_REAP_INTERVAL
+	OrgReapDryRun          bool          `envconfig:"org_reap_dry_run" default:"false"` // IGNITION_ORG_REAP_DRY_RUN
+	TemplateFile           string        `envconfig:"template_file"`                    // IGNITION_TEMPLATE_FILE
+
+	// QuotaFallback is set when there is no quota with the QuotaName, so that
+	// orgs are given the default quota, which orgs other than ignition's can
+	// have too
+	QuotaFallback bool `ignored:"true"`
+
+	// Template describes the spaces created in each new org; it is read from
+	// the template in ignition-config or the config file, or from the template
+	// file
+	Template cloudfoundry.OrgTemplate `ignored:"true"`
+
+	// RunningSecurityGroups and StagingSecurityGroups are the application
+	// security groups bound to every space in the template
+	RunningSecurityGroups []string `envconfig:"running_security_groups"` // IGNITION_RUNNING_SECURITY_GROUPS
+	StagingSecurityGroups []string `envconfig:"staging_security_groups"` // IGNITION_STAGING_SECURITY_GROUPS
+}
+
+// NewExperimenter uses the config file, environment variables, and the named
+// service to populate an Experimenter, and finds its quota, isolation segment,
+// and security groups
+func NewExperimenter(name string, qq cloudfoundry.QuotaQuerier, iq cloudfoundry.ISOSegmentQuerier, sq cloudfoundry.SecurityGroupQuerier) (*Experimenter, error) {
+	e, err := loadExperimenter(name)
+	if err != nil {
+		return nil, err
+	}
+	quotaName := e.QuotaName
+	e.QuotaName, e.QuotaID, err = findQuota(quotaName, qq)
+	if err != nil {
+		return nil, err
+	}
+	e.QuotaFallback = quotaFellBack(quotaName, e.QuotaName)
+	e.ISOSegmentName, e.ISOSegmentID, err = findISOSegment(e.ISOSegmentName, iq)
+	if err != nil {
+		return nil, err
+	}
+	err = findSecurityGroups(e.Template, sq)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
 }
 
-// NewExperimenter uses environment variables to populate an Experimenter
-func NewExperimenter(name string, qq cloudfoundry.QuotaQuerier, iq cloudfoundry.ISOSegmentQuerier) (*Experimenter, error) {
+// loadExperimenter populates an Experimenter from its settings and org
+// template, without contacting the Cloud Controller
+func loadExperimenter(name string) (*Experimenter, error) {
+	settings, err := loadSettings(name, false)
+	if err != nil {
+		return nil, err
+	}
 	var e Experimenter
-	envconfig.Process(ignition, &e)
-	if cfenv.IsRunningOnCF() {
-		env, err := cfenv.Current()
-		if err != nil {
-			return nil, err
-		}
-		service, err := env.Services.WithName(name)
-		if err == nil && service != nil {
-			orgPrefix, ok := service.CredentialString("org_prefix")
-			if ok && strings.TrimSpace(orgPrefix) != "" {
-				e.OrgPrefix = orgPrefix
-			}
-			updateInterval, ok := service.CredentialString("org_count_update_interval")
-			if ok && strings.TrimSpace(updateInterval) != "" {
-				d, err := time.ParseDuration(updateInterval)
-				if err != nil {
-					log.Println(fmt.Sprintf("[WARN] [%s] is an invalid time.Duration, defaulting org update interval to 1m", updateInterval))
-				} else {
-					e.OrgCountUpdateInterval = d
-				}
-			}
-			quotaName, ok := service.CredentialString("quota_name")
-			if ok && strings.TrimSpace(quotaName) != "" {
-				e.QuotaName = quotaName
-			}
-			spaceName, ok := service.CredentialString("space_name")
-			if ok && strings.TrimSpace(spaceName) != "" {
-				e.SpaceName = spaceName
-			}
-			isoSegmentName, ok := service.CredentialString("iso_segment_name")
-			if ok && strings.TrimSpace(isoSegmentName) != "" {
-				e.ISOSegmentName = isoSegmentName
-			}
-		}
+	err = settings.decode(&e)
+	if err != nil {
+		return nil, err
+	}
+	if e.OrgTTL > 0 && e.OrgReapInterval <= 0 {
+		return nil, newValidationError([]string{fmt.Sprintf("org_reap_interval [%s] must be greater than 0s when org_ttl is set", e.OrgReapInterval)})
 	}
 	e.OrgPrefix = strings.TrimSpace(e.OrgPrefix)
 	e.QuotaName = strings.TrimSpace(e.QuotaName)
 	e.SpaceName = strings.TrimSpace(e.SpaceName)
 	e.ISOSegmentName = strings.TrimSpace(e.ISOSegmentName)
+	e.TemplateFile = strings.TrimSpace(e.TemplateFile)
+	e.RunningSecurityGroups = trimNames(e.RunningSecurityGroups)
+	e.StagingSecurityGroups = trimNames(e.StagingSecurityGroups)
+
+	template, err := orgTemplate(e.SpaceName, e.TemplateFile, settings.document("template"))
+	if err != nil {
+		return nil, err
+	}
+	for i := range template.Spaces {
+		space := &template.Spaces[i]
+		space.RunningSecurityGroups = appendNames(space.RunningSecurityGroups, e.RunningSecurityGroups)
+		space.StagingSecurityGroups = appendNames(space.StagingSecurityGroups, e.StagingSecurityGroups)
+	}
+	e.Template = template
+	e.SpaceName = template.Spaces[0].Name
+	return &e, nil
+}
 
-	if e.QuotaName == "" {
-		e.QuotaName = defaultQuota
+// findQuota returns the name and ID of the quota with the name, or of the
+// default quota when there is no such quota
+func findQuota(name string, qq cloudfoundry.QuotaQuerier) (string, string, error) {
+	if name == "" {
+		name = defaultQuota
 	}
-	quotaID, err := cloudfoundry.QuotaIDForName(e.QuotaName, qq)
+	quotaID, err := cloudfoundry.QuotaIDForName(name, qq)
 	if err != nil {
 		var defaultErr error
 		quotaID, defaultErr = cloudfoundry.QuotaIDForName(defaultQuota, qq)
 		if defaultErr != nil {
-			return nil, errors.Wrapf(err, "could not find quota id for quota with name [%s], nor for the default quota", e.QuotaName)
+			return "", "", errors.Wrapf(err, "could not find quota id for quota with name [%s], nor for the default quota", name)
 		}
-		e.QuotaName = defaultQuota
+		name = defaultQuota
 	}
-	e.QuotaID = quotaID
+	return name, quotaID, nil
+}
+
+// quotaFellBack returns true when the quota that was found is the default
+// quota rather than the one with the name
+func quotaFellBack(name string, found string) bool {
+	return found != withDefault(name, defaultQuota)
+}
 
-	if e.ISOSegmentName == "" {
-		e.ISOSegmentName = defaultIsolationSegment
+// findISOSegment returns the name and ID of the isolation segment with the
+// name, or of the shared isolation segment when the name is empty
+func findISOSegment(name string, iq cloudfoundry.ISOSegmentQuerier) (string, string, error) {
+	if name == "" {
+		name = defaultIsolationSegment
 	}
-	isoSegmentID, err := cloudfoundry.ISOSegmentIDForName(e.ISOSegmentName, iq)
+	isoSegmentID, err := cloudfoundry.ISOSegmentIDForName(name, iq)
 	if err != nil {
-		return nil, err
+		return "", "", err
 	}
-	e.ISOSegmentID = isoSegmentID
-	return &e, nil
+	return name, isoSegmentID, nil
+}
+
+// findSecurityGroups returns an error listing the template's security groups
+// that do not exist
+func findSecurityGroups(t cloudfoundry.OrgTemplate, sq cloudfoundry.SecurityGroupQuerier) error {
+	var missing []string
+	for _, group := range t.SecurityGroups() {
+		_, err := cloudfoundry.SecurityGroupIDForName(group, sq)
+		if err != nil {
+			log.Println(err)
+			missing = append(missing, group)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("could not find security groups [%s]", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// trimNames trims each of the names, and removes those that are empty
+func trimNames(names []string) []string {
+	var trimmed []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			trimmed = append(trimmed, name)
+		}
+	}
+	return trimmed
+}
+
+// appendNames appends the names that are not already in the list
+func appendNames(list []string, names []string) []string {
+	for _, name := range names {
+		found := false
+		for _, existing := range list {
+			if existing == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, name)
+		}
+	}
+	return list
 }
@@ -4,11 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
-	cfclient "github.com/cloudfoundry-community/go-cfclient"
 	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
 	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -30,21 +31,31 @@ func testNewExperimenter(t *testing.T, when spec.G, it spec.S) {
 		os.Unsetenv("IGNITION_QUOTA_NAME")
 		os.Unsetenv("IGNITION_SPACE_NAME")
 		os.Unsetenv("IGNITION_ISO_SEGMENT_NAME")
+		os.Unsetenv("IGNITION_ORG_TTL")
+		os.Unsetenv("IGNITION_ORG_EXPIRY_WARNING")
+		os.Unsetenv("IGNITION_ORG_REAP_INTERVAL")
+		os.Unsetenv("IGNITION_ORG_REAP_DRY_RUN")
+		os.Unsetenv("IGNITION_TEMPLATE_FILE")
+		os.Unsetenv("IGNITION_RUNNING_SECURITY_GROUPS")
+		os.Unsetenv("IGNITION_STAGING_SECURITY_GROUPS")
 	}
 
 	it.Before(func() {
 		RegisterTestingT(t)
 		reset()
 		f = &cloudfoundryfakes.FakeAPI{}
-		f.GetOrgQuotaByNameReturns(cfclient.OrgQuota{
-			Guid: "test-quota-id",
+		f.GetOrgQuotaByNameReturns(cloudfoundry.Quota{
+			GUID: "test-quota-id",
 		}, nil)
-		f.ListIsolationSegmentsByQueryReturns([]cfclient.IsolationSegment{
-			cfclient.IsolationSegment{
+		f.ListIsolationSegmentsByNameReturns([]cloudfoundry.IsolationSegment{
+			cloudfoundry.IsolationSegment{
 				Name: "shared",
 				GUID: "shared-iso-segment-id",
 			},
 		}, nil)
+		f.ListSecurityGroupsByNameStub = func(name string) ([]cloudfoundry.SecurityGroup, error) {
+			return []cloudfoundry.SecurityGroup{{GUID: name + "-guid", Name: name}}, nil
+		}
 	})
 
 	it.After(func() {
@@ -61,11 +72,69 @@ func testNewExperimenter(t *testing.T, when spec.G, it spec.S) {
 			Expect(e.SpaceName).To(Equal("playground"))
 			Expect(e.ISOSegmentName).To(Equal("shared"))
 			Expect(e.ISOSegmentID).NotTo(BeZero())
+			Expect(e.OrgTTL).To(BeZero())
+			Expect(e.OrgExpiryWarning).To(Equal(72 * time.Hour))
+			Expect(e.OrgReapInterval).To(Equal(time.Hour))
+			Expect(e.OrgReapDryRun).To(BeFalse())
+		})
+
+		it("uses a single space in the org template", func() {
+			e := createExperimenter(f)
+			Expect(e.Template).To(Equal(cloudfoundry.DefaultOrgTemplate("playground")))
+		})
+
+		it("uses the org template in the template file", func() {
+			os.Setenv("IGNITION_TEMPLATE_FILE", filepath.Join("testdata", "template.json"))
+			e := createExperimenter(f)
+			Expect(e.Template.Spaces).To(HaveLen(2))
+			Expect(e.Template.Spaces[0].Name).To(Equal("dev"))
+			Expect(e.Template.Spaces[0].Services).To(Equal([]cloudfoundry.ServiceInstanceTemplate{
+				cloudfoundry.ServiceInstanceTemplate{Name: "db", Service: "p-mysql", Plan: "small"},
+			}))
+			Expect(e.Template.Spaces[1].Quota).To(Equal("small"))
+			Expect(e.Template.SpaceQuotas).To(HaveLen(1))
+			Expect(e.SpaceName).To(Equal("dev"))
+		})
+
+		it("binds the security groups to every space in the template", func() {
+			os.Setenv("IGNITION_TEMPLATE_FILE", filepath.Join("testdata", "template.json"))
+			os.Setenv("IGNITION_RUNNING_SECURITY_GROUPS", "artifacts, internal-dns")
+			os.Setenv("IGNITION_STAGING_SECURITY_GROUPS", "artifacts")
+			e := createExperimenter(f)
+			Expect(e.RunningSecurityGroups).To(Equal([]string{"artifacts", "internal-dns"}))
+			Expect(e.StagingSecurityGroups).To(Equal([]string{"artifacts"}))
+			for _, space := range e.Template.Spaces {
+				Expect(space.RunningSecurityGroups).To(Equal([]string{"artifacts", "internal-dns"}))
+				Expect(space.StagingSecurityGroups).To(Equal([]string{"artifacts"}))
+			}
+			Expect(f.ListSecurityGroupsByNameCallCount()).To(Equal(2))
+		})
+
+		it("errors when a security group does not exist", func() {
+			os.Setenv("IGNITION_RUNNING_SECURITY_GROUPS", "artifacts,missing")
+			f.ListSecurityGroupsByNameStub = func(name string) ([]cloudfoundry.SecurityGroup, error) {
+				if name == "missing" {
+					return nil, nil
+				}
+				return []cloudfoundry.SecurityGroup{{GUID: name + "-guid", Name: name}}, nil
+			}
+			e, err := NewExperimenter("ignition-config", f, f, f)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("[missing]"))
+			Expect(e).To(BeNil())
+		})
+
+		it("errors when the template file does not exist", func() {
+			os.Setenv("IGNITION_TEMPLATE_FILE", filepath.Join("testdata", "missing.json"))
+			e, err := NewExperimenter("ignition-config", f, f, f)
+			Expect(err).To(HaveOccurred())
+			Expect(e).To(BeNil())
 		})
 
 		it("looks up the Quota ID if it is missing", func() {
 			e := createExperimenter(f)
 			Expect(e.QuotaID).To(Equal("test-quota-id"))
+			Expect(e.QuotaFallback).To(BeFalse())
 		})
 
 		it("looks up the ISO Segment ID if it is missing", func() {
@@ -74,21 +143,22 @@ func testNewExperimenter(t *testing.T, when spec.G, it spec.S) {
 		})
 
 		it("falls back to the default quota if the quota cannot be found", func() {
-			f.GetOrgQuotaByNameReturnsOnCall(0, cfclient.OrgQuota{}, errors.New("not found"))
-			f.GetOrgQuotaByNameReturnsOnCall(1, cfclient.OrgQuota{
-				Guid: "default-quota-id",
+			f.GetOrgQuotaByNameReturnsOnCall(0, cloudfoundry.Quota{}, errors.New("not found"))
+			f.GetOrgQuotaByNameReturnsOnCall(1, cloudfoundry.Quota{
+				GUID: "default-quota-id",
 			}, nil)
 			e := createExperimenter(f)
 			Expect(e.OrgPrefix).To(Equal("ignition"))
 			Expect(e.OrgCountUpdateInterval).To(Equal(time.Minute))
 			Expect(e.QuotaName).To(Equal("default"))
 			Expect(e.QuotaID).To(Equal("default-quota-id"))
+			Expect(e.QuotaFallback).To(BeTrue())
 			Expect(e.SpaceName).To(Equal("playground"))
 		})
 
 		it("errors if the named and the default quota cannot be found", func() {
-			f.GetOrgQuotaByNameReturns(cfclient.OrgQuota{}, errors.New("not found"))
-			e, err := NewExperimenter("ignition-config", f, f)
+			f.GetOrgQuotaByNameReturns(cloudfoundry.Quota{}, errors.New("not found"))
+			e, err := NewExperimenter("ignition-config", f, f, f)
 			Expect(err).To(HaveOccurred())
 			Expect(e).To(BeNil())
 		})
@@ -100,8 +170,12 @@ func testNewExperimenter(t *testing.T, when spec.G, it spec.S) {
 				os.Setenv("IGNITION_SPACE_NAME", "env-space")
 				os.Setenv("IGNITION_QUOTA_NAME", "env-quota-name")
 				os.Setenv("IGNITION_ISO_SEGMENT_NAME", "env-iso-segment-name")
-				f.ListIsolationSegmentsByQueryReturns([]cfclient.IsolationSegment{
-					cfclient.IsolationSegment{
+				os.Setenv("IGNITION_ORG_TTL", "720h")
+				os.Setenv("IGNITION_ORG_EXPIRY_WARNING", "24h")
+				os.Setenv("IGNITION_ORG_REAP_INTERVAL", "10m")
+				os.Setenv("IGNITION_ORG_REAP_DRY_RUN", "true")
+				f.ListIsolationSegmentsByNameReturns([]cloudfoundry.IsolationSegment{
+					cloudfoundry.IsolationSegment{
 						Name: "env-iso-segment-name",
 						GUID: "env-iso-segment-id",
 					},
@@ -117,6 +191,23 @@ func testNewExperimenter(t *testing.T, when spec.G, it spec.S) {
 				Expect(e.SpaceName).To(Equal("env-space"))
 				Expect(e.ISOSegmentName).To(Equal("env-iso-segment-name"))
 				Expect(e.ISOSegmentID).To(Equal("env-iso-segment-id"))
+				Expect(e.OrgTTL).To(Equal(720 * time.Hour))
+				Expect(e.OrgExpiryWarning).To(Equal(24 * time.Hour))
+				Expect(e.OrgReapInterval).To(Equal(10 * time.Minute))
+				Expect(e.OrgReapDryRun).To(BeTrue())
+			})
+		})
+
+		when("idle orgs are reaped without pause", func() {
+			it.Before(func() {
+				os.Setenv("IGNITION_ORG_TTL", "720h")
+				os.Setenv("IGNITION_ORG_REAP_INTERVAL", "0s")
+			})
+
+			it("errors", func() {
+				e, err := NewExperimenter("", f, f, f)
+				Expect(err).To(MatchError("invalid configuration: org_reap_interval [0s] must be greater than 0s when org_ttl is set"))
+				Expect(e).To(BeNil())
 			})
 		})
 
@@ -144,7 +235,7 @@ func testNewExperimenter(t *testing.T, when spec.G, it spec.S) {
 
 		it("errors when VCAP_APPLICATION contents are invalid", func() {
 			os.Setenv("VCAP_APPLICATION", "%&^%@")
-			e, err := NewExperimenter("ignition-config", f, f)
+			e, err := NewExperimenter("ignition-config", f, f, f)
 			Expect(err).To(HaveOccurred())
 			Expect(e).To(BeNil())
 		})
@@ -183,16 +274,88 @@ func testNewExperimenter(t *testing.T, when spec.G, it spec.S) {
 			Expect(e.OrgCountUpdateInterval).To(Equal(time.Minute * 3))
 		})
 
-		it("defaults the org count update interval to 1m when given an invalid duration", func() {
+		it("errors when given an invalid org count update interval", func() {
 			stubCupsService("org_count_update_interval", "garbage")
+			e, err := NewExperimenter("ignition-config", f, f, f)
+			Expect(err).To(MatchError(ContainSubstring("org_count_update_interval [garbage] from ignition-config must be a duration")))
+			Expect(e).To(BeNil())
+		})
+
+		it("uses the org ttl specified in ignition-config", func() {
+			stubCupsService("org_ttl", "720h")
 			e := createExperimenter(f)
-			Expect(e.OrgCountUpdateInterval).To(Equal(time.Minute))
+			Expect(e.OrgTTL).To(Equal(720 * time.Hour))
+		})
+
+		it("errors when given an invalid org ttl", func() {
+			stubCupsService("org_ttl", "garbage")
+			_, err := NewExperimenter("ignition-config", f, f, f)
+			Expect(err).To(MatchError(ContainSubstring("org_ttl [garbage]")))
+		})
+
+		it("uses the org expiry warning specified in ignition-config", func() {
+			stubCupsService("org_expiry_warning", "48h")
+			e := createExperimenter(f)
+			Expect(e.OrgExpiryWarning).To(Equal(48 * time.Hour))
+		})
+
+		it("uses the org reap interval specified in ignition-config", func() {
+			stubCupsService("org_reap_interval", "30m")
+			e := createExperimenter(f)
+			Expect(e.OrgReapInterval).To(Equal(30 * time.Minute))
+		})
+
+		it("uses the org reap dry run setting specified in ignition-config", func() {
+			stubCupsService("org_reap_dry_run", "true")
+			e := createExperimenter(f)
+			Expect(e.OrgReapDryRun).To(BeTrue())
+		})
+
+		it("uses the org template specified in ignition-config", func() {
+			os.Setenv("VCAP_SERVICES", `{"user-provided": [{
+				"name": "ignition-config",
+				"instance_name": "ignition-config",
+				"credentials": {
+					"template": {"spaces": [{"name": "dev"}, {"name": "test", "roles": ["auditor"], "allow_ssh": false}]}
+				}}]}`)
+			e := createExperimenter(f)
+			Expect(e.Template.Spaces).To(HaveLen(2))
+			Expect(e.Template.Spaces[0].Roles).To(ConsistOf("manager", "developer", "auditor"))
+			Expect(e.Template.Spaces[0].AllowSSH).To(BeTrue())
+			Expect(e.Template.Spaces[1].Roles).To(Equal([]string{"auditor"}))
+			Expect(e.Template.Spaces[1].AllowSSH).To(BeFalse())
+		})
+
+		it("errors when the org template in ignition-config is invalid", func() {
+			os.Setenv("VCAP_SERVICES", `{"user-provided": [{
+				"name": "ignition-config",
+				"instance_name": "ignition-config",
+				"credentials": {
+					"template": {"spaces": [{"name": "dev", "roles": ["owner"]}]}
+				}}]}`)
+			e, err := NewExperimenter("ignition-config", f, f, f)
+			Expect(err).To(HaveOccurred())
+			Expect(e).To(BeNil())
+		})
+
+		it("uses the security groups specified in ignition-config", func() {
+			stubCupsService("running_security_groups", "artifacts,internal-dns")
+			e := createExperimenter(f)
+			Expect(e.RunningSecurityGroups).To(Equal([]string{"artifacts", "internal-dns"}))
+			Expect(e.StagingSecurityGroups).To(BeEmpty())
+			Expect(e.Template.Spaces[0].RunningSecurityGroups).To(Equal([]string{"artifacts", "internal-dns"}))
+		})
+
+		it("uses the template file specified in ignition-config", func() {
+			stubCupsService("template_file", filepath.Join("testdata", "template.json"))
+			e := createExperimenter(f)
+			Expect(e.Template.Spaces).To(HaveLen(2))
 		})
 
 		it("uses the isolation segment name specified in ignition-config", func() {
 			stubCupsService("iso_segment_name", "test-ignition-iso-segment-name")
-			f.ListIsolationSegmentsByQueryReturns([]cfclient.IsolationSegment{
-				cfclient.IsolationSegment{
+			f.ListIsolationSegmentsByNameReturns([]cloudfoundry.IsolationSegment{
+				cloudfoundry.IsolationSegment{
 					Name: "test-ignition-iso-segment-name",
 					GUID: "test-iso-segment-id",
 				},
@@ -212,7 +375,7 @@ func testNewExperimenter(t *testing.T, when spec.G, it spec.S) {
 }
 
 func createExperimenter(f *cloudfoundryfakes.FakeAPI) *Experimenter {
-	e, err := NewExperimenter("ignition-config", f, f)
+	e, err := NewExperimenter("ignition-config", f, f, f)
 	Expect(err).NotTo(HaveOccurred())
 	Expect(e).NotTo(BeNil())
 	return e
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Foundation is a Cloud Foundry foundation that ignition provisions orgs in.
+// The primary foundation is the Deployment; any other foundations are read
+// from the foundations setting or the foundations file, and use the
+// primary foundation's UAA origin, API client, Cloud Controller API version,
+// quota name, and isolation segment name unless they set their own.
+type Foundation struct {
+	Name           string      `json:"name"`
+	SystemDomain   string      `json:"system_domain"`
+	UAAOrigin      string      `json:"uaa_origin"`
+	ClientID       string      `json:"api_client_id"`
+	ClientSecret   string      `json:"api_client_secret"`
+	CCAPIVersion   string      `json:"cc_api_version"`
+	QuotaName      string      `json:"quota_name"`
+	QuotaID        string      `json:"-"`
+	ISOSegmentName string      `json:"iso_segment_name"`
+	ISOSegmentID   string      `json:"-"`
+	Deployment     *Deployment `json:"-"`
+
+	// QuotaFallback is set when there is no quota with the QuotaName in the
+	// foundation, so that its orgs are given the default quota
+	QuotaFallback bool `json:"-"`
+
+	// Groups, when set, restricts the foundation to members of the groups,
+	// and makes it the foundation that they are assigned to
+	Groups []string `json:"groups"`
+}
+
+// foundationSettings are the settings for the foundations
+type foundationSettings struct {
+	Name string `envconfig:"foundation_name" default:"default"` // IGNITION_FOUNDATION_NAME
+	File string `envconfig:"foundations_file"`                  // IGNITION_FOUNDATIONS_FILE
+}
+
+// NewFoundations returns the primary foundation, which is the Deployment,
+// followed by the foundations in ignition-config, the config file, or the
+// foundations file. The foundations setting takes precedence over a
+// foundations file.
+func NewFoundations(name string, d *Deployment, e *Experimenter) ([]*Foundation, error) {
+	foundations, err := loadFoundations(name, d, e)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range foundations[1:] {
+		err = f.lookup(e)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not configure foundation [%s]", f.Name)
+		}
+	}
+	return foundations, nil
+}
+
+// loadFoundations returns the foundations, with the Deployments of those other
+// than the primary foundation, without contacting them
+func loadFoundations(name string, d *Deployment, e *Experimenter) ([]*Foundation, error) {
+	settings, err := loadSettings(name, false)
+	if err != nil {
+		return nil, err
+	}
+	var s foundationSettings
+	err = settings.decode(&s)
+	if err != nil {
+		return nil, err
+	}
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return nil, errors.New("foundation_name cannot be empty")
+	}
+
+	primary := &Foundation{
+		Name:           s.Name,
+		SystemDomain:   d.SystemDomain,
+		UAAOrigin:      d.UAAOrigin,
+		ClientID:       d.ClientID,
+		ClientSecret:   d.ClientSecret,
+		CCAPIVersion:   d.CCAPIVersion,
+		QuotaName:      e.QuotaName,
+		QuotaID:        e.QuotaID,
+		ISOSegmentName: e.ISOSegmentName,
+		ISOSegmentID:   e.ISOSegmentID,
+		QuotaFallback:  e.QuotaFallback,
+		Deployment:     d,
+	}
+	others, err := readFoundations(strings.TrimSpace(s.File), settings.document("foundations"))
+	if err != nil {
+		return nil, err
+	}
+
+	foundations := []*Foundation{primary}
+	names := map[string]bool{strings.ToLower(primary.Name): true}
+	for i := range others {
+		f := &others[i]
+		f.Name = strings.TrimSpace(f.Name)
+		if f.Name == "" {
+			return nil, errors.Errorf("foundation %d does not have a name", i+1)
+		}
+		if names[strings.ToLower(f.Name)] {
+			return nil, errors.Errorf("foundation [%s] is configured more than once", f.Name)
+		}
+		names[strings.ToLower(f.Name)] = true
+		err = f.deploy(primary)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not configure foundation [%s]", f.Name)
+		}
+		foundations = append(foundations, f)
+	}
+	return foundations, nil
+}
+
+// deploy creates the foundation's Deployment, using the primary foundation's
+// settings for those the foundation does not set
+func (f *Foundation) deploy(primary *Foundation) error {
+	d := &Deployment{
+		SystemDomain:      f.SystemDomain,
+		UAAOrigin:         withDefault(f.UAAOrigin, primary.UAAOrigin),
+		ClientID:          withDefault(f.ClientID, primary.ClientID),
+		ClientSecret:      withDefault(f.ClientSecret, primary.ClientSecret),
+		CCAPIVersion:      withDefault(f.CCAPIVersion, primary.CCAPIVersion),
+		SkipTLSValidation: primary.Deployment.SkipTLSValidation,
+	}
+	err := d.connect()
+	if err != nil {
+		return err
+	}
+	f.Deployment = d
+	f.SystemDomain = d.SystemDomain
+	f.UAAOrigin = d.UAAOrigin
+	f.ClientID = d.ClientID
+	f.ClientSecret = d.ClientSecret
+	f.CCAPIVersion = d.CCAPIVersion
+	f.Groups = trimNames(f.Groups)
+	f.QuotaName = withDefault(f.QuotaName, primary.QuotaName)
+	f.ISOSegmentName = withDefault(f.ISOSegmentName, primary.ISOSegmentName)
+	return nil
+}
+
+// lookup finds the foundation's quota, isolation segment, and the template's
+// security groups
+func (f *Foundation) lookup(e *Experimenter) error {
+	name := f.QuotaName
+	var err error
+	f.QuotaName, f.QuotaID, err = findQuota(name, f.Deployment.CC)
+	if err != nil {
+		return err
+	}
+	f.QuotaFallback = quotaFellBack(name, f.QuotaName)
+	f.ISOSegmentName, f.ISOSegmentID, err = findISOSegment(f.ISOSegmentName, f.Deployment.CC)
+	if err != nil {
+		return err
+	}
+	return findSecurityGroups(e.Template, f.Deployment.CC)
+}
+
+// readFoundations reads the foundations from the setting, or, when it is not
+// set, from the file
+func readFoundations(file string, setting interface{}) ([]Foundation, error) {
+	var foundations []Foundation
+	var b []byte
+	var err error
+	switch c := setting.(type) {
+	case nil:
+		if file == "" {
+			return nil, nil
+		}
+		b, err = ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read foundations file [%s]", file)
+		}
+	case string:
+		b = []byte(c)
+	default:
+		b, err = json.Marshal(c)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read foundations")
+		}
+	}
+
+	err = json.Unmarshal(b, &foundations)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse foundations")
+	}
+	return foundations, nil
+}
+
+// withDefault returns the value, or the default when the value is empty
+func withDefault(value string, def string) string {
+	if value = strings.TrimSpace(value); value != "" {
+		return value
+	}
+	return def
+}
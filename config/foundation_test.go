@@ -0,0 +1,185 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/internal/fakecf"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestNewFoundations(t *testing.T) {
+	spec.Run(t, "NewFoundations", testNewFoundations, spec.Report(report.Terminal{}))
+}
+
+func testNewFoundations(t *testing.T, when spec.G, it spec.S) {
+	var (
+		primary, emea   *fakecf.Server
+		p, s            *httptest.Server
+		d               *Deployment
+		e               *Experimenter
+		dir             string
+		emeaQuotaID     string
+		writeFoundation func(string) string
+	)
+
+	reset := func() {
+		os.Unsetenv("VCAP_APPLICATION")
+		os.Unsetenv("VCAP_SERVICES")
+		os.Unsetenv("PORT")
+
+		os.Unsetenv("IGNITION_SYSTEM_DOMAIN")
+		os.Unsetenv("IGNITION_UAA_ORIGIN")
+		os.Unsetenv("IGNITION_API_CLIENT_ID")
+		os.Unsetenv("IGNITION_API_CLIENT_SECRET")
+		os.Unsetenv("IGNITION_FOUNDATION_NAME")
+		os.Unsetenv("IGNITION_FOUNDATIONS_FILE")
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		reset()
+		primary = fakecf.New("ignition-api", "ignition-api-secret")
+		primary.AddOrgQuota("ignition")
+		p = httptest.NewServer(primary)
+		emea = fakecf.New("ignition-api", "ignition-api-secret")
+		emea.AddOrgQuota("ignition")
+		emeaQuotaID = emea.AddOrgQuota("emea")
+		s = httptest.NewServer(emea)
+
+		os.Setenv("IGNITION_SYSTEM_DOMAIN", p.URL)
+		os.Setenv("IGNITION_UAA_ORIGIN", "ignition-sso")
+		os.Setenv("IGNITION_API_CLIENT_ID", "ignition-api")
+		os.Setenv("IGNITION_API_CLIENT_SECRET", "ignition-api-secret")
+		var err error
+		d, err = NewDeployment("ignition-config")
+		Expect(err).NotTo(HaveOccurred())
+		e, err = NewExperimenter("ignition-config", d.CC, d.CC, d.CC)
+		Expect(err).NotTo(HaveOccurred())
+
+		dir, err = ioutil.TempDir("", "foundations")
+		Expect(err).NotTo(HaveOccurred())
+		writeFoundation = func(contents string) string {
+			file := filepath.Join(dir, "foundations.json")
+			Expect(ioutil.WriteFile(file, []byte(contents), 0600)).To(Succeed())
+			return file
+		}
+	})
+
+	it.After(func() {
+		reset()
+		p.Close()
+		s.Close()
+		os.RemoveAll(dir)
+	})
+
+	it("has only the primary foundation by default", func() {
+		foundations, err := NewFoundations("ignition-config", d, e)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(foundations).To(HaveLen(1))
+		Expect(foundations[0].Name).To(Equal("default"))
+		Expect(foundations[0].Deployment).To(Equal(d))
+		Expect(foundations[0].QuotaID).To(Equal(e.QuotaID))
+		Expect(foundations[0].ISOSegmentID).To(Equal(e.ISOSegmentID))
+		Expect(foundations[0].Groups).To(BeEmpty())
+	})
+
+	it("reads the other foundations from the foundations file", func() {
+		os.Setenv("IGNITION_FOUNDATION_NAME", "us-east")
+		os.Setenv("IGNITION_FOUNDATIONS_FILE", writeFoundation(fmt.Sprintf(`[{
+			"name": " emea ",
+			"system_domain": "%s",
+			"quota_name": "emea",
+			"groups": ["emea", " "]
+		}]`, s.URL)))
+		foundations, err := NewFoundations("ignition-config", d, e)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(foundations).To(HaveLen(2))
+		Expect(foundations[0].Name).To(Equal("us-east"))
+
+		f := foundations[1]
+		Expect(f.Name).To(Equal("emea"))
+		Expect(f.Deployment.APIURL).To(Equal(s.URL))
+		Expect(f.UAAOrigin).To(Equal("ignition-sso"))
+		Expect(f.ClientID).To(Equal("ignition-api"))
+		Expect(f.CCAPIVersion).To(Equal(CCAPIVersion2))
+		Expect(f.QuotaName).To(Equal("emea"))
+		Expect(f.QuotaID).To(Equal(emeaQuotaID))
+		Expect(f.QuotaFallback).To(BeFalse())
+		Expect(f.ISOSegmentName).To(Equal("shared"))
+		Expect(f.ISOSegmentID).NotTo(BeEmpty())
+		Expect(f.Groups).To(Equal([]string{"emea"}))
+	})
+
+	it("errors when a foundation cannot be reached with its API client", func() {
+		os.Setenv("IGNITION_FOUNDATIONS_FILE", writeFoundation(fmt.Sprintf(`[{
+			"name": "emea",
+			"system_domain": "%s",
+			"api_client_secret": "wrong-secret"
+		}]`, s.URL)))
+		foundations, err := NewFoundations("ignition-config", d, e)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("could not configure foundation [emea]"))
+		Expect(foundations).To(BeNil())
+	})
+
+	it("errors when a foundation does not have a system domain", func() {
+		os.Setenv("IGNITION_FOUNDATIONS_FILE", writeFoundation(`[{"name": "emea"}]`))
+		_, err := NewFoundations("ignition-config", d, e)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("system_domain is required"))
+	})
+
+	it("errors when foundations have the same name", func() {
+		os.Setenv("IGNITION_FOUNDATIONS_FILE", writeFoundation(fmt.Sprintf(`[{
+			"name": "Default",
+			"system_domain": "%s"
+		}]`, s.URL)))
+		_, err := NewFoundations("ignition-config", d, e)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("configured more than once"))
+	})
+
+	it("errors when a foundation does not have a name", func() {
+		os.Setenv("IGNITION_FOUNDATIONS_FILE", writeFoundation(fmt.Sprintf(`[{"system_domain": "%s"}]`, s.URL)))
+		_, err := NewFoundations("ignition-config", d, e)
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("errors when the foundations file cannot be read", func() {
+		os.Setenv("IGNITION_FOUNDATIONS_FILE", filepath.Join(dir, "missing.json"))
+		_, err := NewFoundations("ignition-config", d, e)
+		Expect(err).To(HaveOccurred())
+	})
+
+	when("running on Cloud Foundry", func() {
+		it.Before(func() {
+			os.Setenv("VCAP_APPLICATION", `{"cf_api": "https://api.run.pcfbeta.io","limits": {"fds": 16384},"application_name": "ignition","application_uris": ["ignition.pcfbeta.io"],"name": "ignition","space_name": "development","space_id": "test-space-id","uris": ["ignition.pcfbeta.io"],"users": null,"application_id": "test-app-id"}`)
+			os.Setenv("PORT", "54321")
+		})
+
+		it("reads the foundations from ignition-config in preference to the file", func() {
+			os.Setenv("IGNITION_FOUNDATIONS_FILE", filepath.Join(dir, "missing.json"))
+			os.Setenv("VCAP_SERVICES", fmt.Sprintf(`{"user-provided": [{
+				"name": "ignition-config",
+				"instance_name": "ignition-config",
+				"credentials": {
+					"foundation_name": "us-east",
+					"foundations": [{"name": "emea", "system_domain": "%s", "quota_name": "emea"}]
+				}
+			}]}`, s.URL))
+			foundations, err := NewFoundations("ignition-config", d, e)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(foundations).To(HaveLen(2))
+			Expect(foundations[0].Name).To(Equal("us-east"))
+			Expect(foundations[1].Name).To(Equal("emea"))
+			Expect(foundations[1].QuotaID).To(Equal(emeaQuotaID))
+		})
+	})
+}
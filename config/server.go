@@ -2,13 +2,17 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	cfenv "github.com/cloudfoundry-community/go-cfenv"
 	"github.com/dghubble/sessions"
-	"github.com/kelseyhightower/envconfig"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/logging"
 )
 
 // Server is an HTTP/S web server
@@ -20,19 +24,38 @@ type Server struct {
 	Port             int            `envconfig:"port" default:"3000"`                          // IGNITION_PORT
 	ServePort        int            `envconfig:"serve_port" default:"3000"`                    // IGNITION_SERVE_PORT
 	WebRoot          string         `ignored:"true"`                                           // Not configurable
-	SessionSecret    string         `envconfig:"session_secret"`                               // IGNITION_SESSION_SECRET << REQUIRED
+	SessionSecret    string         `envconfig:"session_secret" required:"true"`               // IGNITION_SESSION_SECRET << REQUIRED
 	CompanyName      string         `envconfig:"company_name" default:"Your Company"`          // IGNITION_COMPANY_NAME
+	LogLevel         string         `envconfig:"log_level" default:"info"`                     // IGNITION_LOG_LEVEL (debug, info, warn, or error)
+	AuditFile        string         `envconfig:"audit_file"`                                   // IGNITION_AUDIT_FILE (JSON lines)
+	AuditSyslog      string         `envconfig:"audit_syslog"`                                 // IGNITION_AUDIT_SYSLOG ("local", or a URL such as udp://syslog.example.net:514)
 	SessionStore     sessions.Store `ignored:"true"`                                           // Not configurable
+
+	// Redis is the pool of connections to the Redis server when sessions are
+	// stored in Redis; the state that instances of ignition share, such as the
+	// warnings sent for idle orgs, is kept there too
+	Redis *redis.Pool `ignored:"true"`
+
+	SessionBackend  string `envconfig:"session_backend" default:"cookie"` // IGNITION_SESSION_BACKEND (cookie, memory, or redis)
+	SessionRedisURL string `envconfig:"session_redis_url"`                // IGNITION_SESSION_REDIS_URL (e.g. redis://:password@redis.example.net:6379/0)
+
+	SessionPreviousSecrets []string      `envconfig:"session_previous_secrets"`       // IGNITION_SESSION_PREVIOUS_SECRETS (comma separated, accepted until existing sessions expire)
+	SessionLifetime        time.Duration `envconfig:"session_lifetime" default:"24h"` // IGNITION_SESSION_LIFETIME (how long a user stays logged in while their token is refreshed; 0 for no limit)
 }
 
-// NewServer uses environment variables to populate a Server
+// NewServer uses the config file, environment variables, and the
+// ignition-config service to populate a Server
 func NewServer() (*Server, error) {
+	settings, err := loadSettings("", false)
+	if err != nil {
+		return nil, err
+	}
 	var s Server
-	err := envconfig.Process(ignition, &s)
+	err = settings.decode(&s)
 	if err != nil {
 		return nil, err
 	}
-	err = s.ConfigureServer(s.ServiceName)
+	err = s.ConfigureServer(settings.app)
 	if err != nil {
 		return nil, err
 	}
@@ -41,14 +64,49 @@ func NewServer() (*Server, error) {
 	if strings.TrimSpace(s.SessionSecret) == "" {
 		return nil, errors.New("session_secret is required")
 	}
-	s.SessionStore = sessions.NewCookieStore([]byte(s.SessionSecret), nil)
+	s.SessionPreviousSecrets = trimNames(s.SessionPreviousSecrets)
+	s.SessionBackend = strings.ToLower(strings.TrimSpace(s.SessionBackend))
+	s.SessionRedisURL = strings.TrimSpace(s.SessionRedisURL)
+	s.SessionStore, err = s.newSessionStore()
+	if err != nil {
+		return nil, err
+	}
 	s.CompanyName = strings.TrimSpace(s.CompanyName)
 	s.Scheme = strings.TrimSpace(s.Scheme)
 	s.Domain = strings.TrimSpace(s.Domain)
 	s.ServiceName = strings.TrimSpace(s.ServiceName)
+	s.LogLevel = strings.TrimSpace(s.LogLevel)
+	s.AuditFile = strings.TrimSpace(s.AuditFile)
+	s.AuditSyslog = strings.TrimSpace(s.AuditSyslog)
+	if _, err := logging.ParseLevel(s.LogLevel); err != nil {
+		return nil, err
+	}
 	return &s, nil
 }
 
+// newSessionStore creates the store for the configured session backend
+func (s *Server) newSessionStore() (sessions.Store, error) {
+	keyPairs := session.KeyPairs(append([]string{s.SessionSecret}, s.SessionPreviousSecrets...)...)
+	switch s.SessionBackend {
+	case "", "cookie":
+		return session.NewCookieStore(keyPairs...), nil
+	case "memory":
+		return session.NewServerStore(session.NewMemoryBackend(), keyPairs...), nil
+	case "redis":
+		if s.SessionRedisURL == "" {
+			return nil, errors.New("session_redis_url is required when session_backend is redis")
+		}
+		b, err := session.NewRedisBackend(s.SessionRedisURL)
+		if err != nil {
+			return nil, err
+		}
+		s.Redis = b.Pool
+		return session.NewServerStore(b, keyPairs...), nil
+	default:
+		return nil, fmt.Errorf("session_backend [%s] must be cookie, memory, or redis", s.SessionBackend)
+	}
+}
+
 // ConfigureWebRoot ensures the webroot is set to appropriate values for local
 // development and for use on Cloud Foundry
 func (s *Server) ConfigureWebRoot(root string) {
@@ -60,47 +118,21 @@ func (s *Server) ConfigureWebRoot(root string) {
 }
 
 // ConfigureServer ensures that the server will function correctly on Cloud
-// Foundry
-func (s *Server) ConfigureServer(name string) error {
-	if !cfenv.IsRunningOnCF() {
+// Foundry, where the app is nil when it is not running on Cloud Foundry
+func (s *Server) ConfigureServer(app *cfenv.App) error {
+	if app == nil {
 		return nil
 	}
-	env, err := cfenv.Current()
-	if err != nil {
-		return err
-	}
 	s.Scheme = "https"
 	s.Port = 443
-	s.ServePort = env.Port
-	service, err := env.Services.WithName(name)
-	if err == nil && service != nil {
-		domain, ok := service.CredentialString("domain")
-		if ok && strings.TrimSpace(domain) != "" {
-			s.Domain = domain
-		}
-
-		sessionSecret, ok := service.CredentialString("session_secret")
-		if ok && strings.TrimSpace(sessionSecret) != "" {
-			s.SessionSecret = sessionSecret
-		}
-
-		companyName, ok := service.CredentialString("company_name")
-		if ok && strings.TrimSpace(companyName) != "" {
-			s.CompanyName = companyName
-		}
-
-		collectAnalytics, ok := service.CredentialString("collect_analytics")
-		if ok && strings.TrimSpace(collectAnalytics) != "" && strings.ToLower(strings.TrimSpace(collectAnalytics)) != "false" {
-			s.CollectAnalytics = true
-		}
-	}
+	s.ServePort = app.Port
 	d := strings.TrimSpace(strings.ToLower(s.Domain))
 	if d != "localhost" && d != "" {
 		return nil
 	}
-	if len(env.ApplicationURIs) == 0 {
+	if len(app.ApplicationURIs) == 0 {
 		return errors.New("ignition requires a route to function; please map a route")
 	}
-	s.Domain = env.ApplicationURIs[0]
+	s.Domain = app.ApplicationURIs[0]
 	return nil
 }
@@ -3,8 +3,10 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/http/session"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 )
@@ -27,6 +29,11 @@ func testServer(t *testing.T, when spec.G, it spec.S) {
 		os.Unsetenv("IGNITION_SESSION_SECRET")
 		os.Unsetenv("IGNITION_COMPANY_NAME")
 		os.Unsetenv("IGNITION_COLLECT_ANALYTICS")
+		os.Unsetenv("IGNITION_LOG_LEVEL")
+		os.Unsetenv("IGNITION_SESSION_BACKEND")
+		os.Unsetenv("IGNITION_SESSION_REDIS_URL")
+		os.Unsetenv("IGNITION_SESSION_PREVIOUS_SECRETS")
+		os.Unsetenv("IGNITION_SESSION_LIFETIME")
 	}
 	it.Before(func() {
 		RegisterTestingT(t)
@@ -65,6 +72,57 @@ func testServer(t *testing.T, when spec.G, it spec.S) {
 				Expect(s.Port).To(Equal(3000))
 				Expect(s.ServePort).To(Equal(3000))
 				Expect(s.WebRoot).To(ContainSubstring("dist"))
+				Expect(s.LogLevel).To(Equal("info"))
+				Expect(s.SessionLifetime).To(Equal(24 * time.Hour))
+			})
+
+			it("errors if the log level is unknown", func() {
+				os.Setenv("IGNITION_LOG_LEVEL", "verbose")
+				s, err := NewServer()
+				Expect(err).To(HaveOccurred())
+				Expect(s).To(BeNil())
+			})
+
+			it("stores sessions in cookies by default", func() {
+				s, err := NewServer()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(s.SessionBackend).To(Equal("cookie"))
+				Expect(s.SessionStore).To(BeAssignableToTypeOf(&session.CookieStore{}))
+			})
+
+			it("accepts sessions issued with a previous secret", func() {
+				os.Setenv("IGNITION_SESSION_PREVIOUS_SECRETS", "old-secret, older-secret,")
+				s, err := NewServer()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(s.SessionPreviousSecrets).To(Equal([]string{"old-secret", "older-secret"}))
+				Expect(s.SessionStore.(*session.CookieStore).Codecs).To(HaveLen(6))
+			})
+
+			it("stores sessions in memory", func() {
+				os.Setenv("IGNITION_SESSION_BACKEND", "Memory")
+				s, err := NewServer()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(s.SessionStore).To(BeAssignableToTypeOf(&session.ServerStore{}))
+			})
+
+			it("stores sessions in redis", func() {
+				os.Setenv("IGNITION_SESSION_BACKEND", "redis")
+				os.Setenv("IGNITION_SESSION_REDIS_URL", "redis://:password@redis.example.net:6379/0")
+				s, err := NewServer()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(s.SessionStore).To(BeAssignableToTypeOf(&session.ServerStore{}))
+			})
+
+			it("errors if the redis session backend has no url", func() {
+				os.Setenv("IGNITION_SESSION_BACKEND", "redis")
+				_, err := NewServer()
+				Expect(err).To(MatchError(ContainSubstring("session_redis_url is required")))
+			})
+
+			it("errors if the session backend is unknown", func() {
+				os.Setenv("IGNITION_SESSION_BACKEND", "database")
+				_, err := NewServer()
+				Expect(err).To(HaveOccurred())
 			})
 		})
 
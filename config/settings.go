@@ -0,0 +1,402 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cfenv "github.com/cloudfoundry-community/go-cfenv"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigFileEnv is the environment variable that names ignition's config file
+const ConfigFileEnv = "IGNITION_CONFIG_FILE"
+
+const defaultServiceName = "ignition-config"
+
+// documents are the settings that hold a JSON document rather than a value;
+// they are read from the config file or the service credentials
+var documents = []string{"template", "foundations"}
+
+// ValidationError lists every problem with ignition's configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(e.Problems, "; "))
+}
+
+// newValidationError returns a ValidationError for the problems, or nil when
+// there are none
+func newValidationError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+// settings are the values of ignition's configuration, read from the config
+// file, the environment, and the credentials of the ignition-config service,
+// in increasing order of precedence. The settings that each of Server,
+// Authorizer, Deployment, Experimenter, and the foundations use, and their
+// types and defaults, are declared by the envconfig, default, and required tags
+// of their fields.
+type settings struct {
+	file        map[string]interface{}
+	fileName    string
+	credentials map[string]interface{}
+	service     string
+	app         *cfenv.App
+
+	// problems are those with the config file, such as settings that ignition
+	// does not have
+	problems []string
+}
+
+// loadSettings reads the config file and, when running on Cloud Foundry, the
+// credentials of the named service. When the name is empty, the service is the
+// one named by config_servicename. The service is optional unless required is
+// set.
+func loadSettings(name string, required bool) (*settings, error) {
+	s := &settings{fileName: strings.TrimSpace(os.Getenv(ConfigFileEnv))}
+	err := s.readFile()
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(name) == "" {
+		name = s.serviceName()
+	}
+	if !cfenv.IsRunningOnCF() {
+		return s, nil
+	}
+	s.app, err = cfenv.Current()
+	if err != nil {
+		return nil, err
+	}
+	service, err := s.app.Services.WithName(name)
+	if err != nil {
+		if required {
+			return nil, err
+		}
+		return s, nil
+	}
+	s.service = name
+	s.credentials = service.Credentials
+	return s, nil
+}
+
+// readFile reads the config file, which is YAML or JSON, and records the
+// settings in it that ignition does not have
+func (s *settings) readFile() error {
+	if s.fileName == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(s.fileName)
+	if err != nil {
+		return errors.Wrapf(err, "could not read config file [%s]", s.fileName)
+	}
+	var file map[string]interface{}
+	err = yaml.UnmarshalStrict(b, &file)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse config file [%s]", s.fileName)
+	}
+	known := schema()
+	for key, value := range file {
+		if _, ok := known[key]; !ok {
+			s.problems = append(s.problems, fmt.Sprintf("%s in %s is not an ignition setting", key, s.fileName))
+			continue
+		}
+		file[key] = jsonValue(value)
+	}
+	sort.Strings(s.problems)
+	s.file = file
+	return nil
+}
+
+// serviceName returns the name of the service that holds ignition's
+// configuration, which is set in the config file or the environment
+func (s *settings) serviceName() string {
+	name := defaultServiceName
+	if v, ok := s.file["config_servicename"].(string); ok && strings.TrimSpace(v) != "" {
+		name = v
+	}
+	if v := os.Getenv(env("config_servicename")); strings.TrimSpace(v) != "" {
+		name = v
+	}
+	return strings.TrimSpace(name)
+}
+
+// lookup returns the value of the setting, and where it was set. Empty values
+// in the config file and the service are ignored, but an environment variable
+// that is set and empty clears the setting.
+func (s *settings) lookup(key string) (interface{}, string, bool) {
+	if v, ok := s.credentials[key]; ok && !empty(v) {
+		return v, s.service, true
+	}
+	if v, ok := os.LookupEnv(env(key)); ok {
+		return v, env(key), true
+	}
+	if v, ok := s.file[key]; ok && !empty(v) {
+		return v, s.fileName, true
+	}
+	return nil, "", false
+}
+
+// document returns the JSON document held by the setting, or nil when it is
+// not set
+func (s *settings) document(key string) interface{} {
+	if v, ok := s.credentials[key]; ok && !empty(v) {
+		return v
+	}
+	if v, ok := s.file[key]; ok && !empty(v) {
+		return v
+	}
+	return nil
+}
+
+// decode sets the fields of the spec, which is a pointer to a struct, from
+// the settings, and returns a ValidationError listing every setting that is
+// invalid or missing, along with any problems with the config file
+func (s *settings) decode(spec interface{}) error {
+	problems := append([]string{}, s.problems...)
+	problems = append(problems, s.assign(spec)...)
+	return newValidationError(problems)
+}
+
+// assign sets the fields of the spec from the settings, returning the problems
+// with the settings
+func (s *settings) assign(spec interface{}) []string {
+	var problems []string
+	v := reflect.ValueOf(spec).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("envconfig")
+		if key == "" || field.Tag.Get("ignored") == "true" {
+			continue
+		}
+		value, source, ok := s.lookup(key)
+		if field.Tag.Get("required") == "true" && empty(value) {
+			problems = append(problems, fmt.Sprintf("%s is required", key))
+			continue
+		}
+		if !ok {
+			def, ok := field.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			value, source = def, "its default"
+		}
+		err := setField(v.Field(i), value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s [%v] from %s %s", key, value, source, err))
+		}
+	}
+	return problems
+}
+
+// setField sets the field to the value, which is a string, boolean, number,
+// or list
+func setField(f reflect.Value, value interface{}) error {
+	if f.Kind() == reflect.Slice {
+		list, err := listValue(value)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(list))
+		return nil
+	}
+	raw, ok := scalarValue(value)
+	if !ok {
+		return errors.New("must be a single value")
+	}
+	s := strings.TrimSpace(raw)
+	switch {
+	case f.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.New("must be a duration, such as 90s, 15m, or 24h")
+		}
+		f.SetInt(int64(d))
+	case f.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		f.SetBool(b)
+	case f.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		f.SetInt(int64(n))
+	case f.Kind() == reflect.String:
+		f.SetString(raw)
+	default:
+		return errors.Errorf("cannot be set to a %s", f.Type())
+	}
+	return nil
+}
+
+// listValue returns the list, or the comma separated list in the string
+func listValue(value interface{}) ([]string, error) {
+	if s, ok := value.(string); ok {
+		return strings.Split(s, ","), nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("must be a list, or a comma separated string")
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := scalarValue(item)
+		if !ok {
+			return nil, errors.New("must be a list of single values")
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// scalarValue returns the value as a string, if it is a string, boolean, or
+// number
+func scalarValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int, int64, uint64:
+		return fmt.Sprint(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// jsonValue converts the maps in a value read from YAML to the maps that
+// encoding/json reads and writes
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// empty returns true when the value is not set, or is a blank string
+func empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	s, ok := value.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// env returns the environment variable for the setting
+func env(key string) string {
+	return strings.ToUpper(ignition + "_" + key)
+}
+
+// specs are the structs whose fields declare ignition's settings
+func specs() []interface{} {
+	return []interface{}{&Server{}, &Authorizer{}, &Deployment{}, &Experimenter{}, &foundationSettings{}}
+}
+
+// schema returns the type of each of ignition's settings; documents are nil
+func schema() map[string]reflect.Type {
+	types := map[string]reflect.Type{}
+	for _, spec := range specs() {
+		t := reflect.TypeOf(spec).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if key := field.Tag.Get("envconfig"); key != "" && field.Tag.Get("ignored") != "true" {
+				types[key] = field.Type
+			}
+		}
+	}
+	for _, key := range documents {
+		types[key] = nil
+	}
+	return types
+}
+
+// Validate reads ignition's configuration from the config file, the
+// environment, and the ignition-config service, and returns a
+// ValidationError listing every setting that is unknown, missing, has a value
+// of the wrong type, or is otherwise rejected by New, such as an unsupported
+// cc_api_version or session_backend, or a template or foundations document
+// that cannot be parsed. It does not connect to any of the services that the
+// settings refer to.
+func Validate() error {
+	s, err := loadSettings("", false)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	seen := map[string]bool{}
+	add := func(found ...string) {
+		for _, problem := range found {
+			if !seen[problem] {
+				seen[problem] = true
+				problems = append(problems, problem)
+			}
+		}
+	}
+	add(s.problems...)
+	for _, spec := range specs() {
+		add(s.assign(spec)...)
+	}
+	for _, err := range load(s.serviceName()) {
+		if v, ok := err.(*ValidationError); ok {
+			add(v.Problems...)
+		} else {
+			add(err.Error())
+		}
+	}
+	return newValidationError(problems)
+}
+
+// load builds each part of ignition's configuration as New does, up to the
+// point where a service must be contacted, and returns the errors
+func load(name string) []error {
+	var errs []error
+	if _, err := NewServer(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := loadAuthorizer(name); err != nil {
+		errs = append(errs, err)
+	}
+	d, err := NewDeployment(name)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	e, err := loadExperimenter(name)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if d != nil && e != nil {
+		if _, err := loadFoundations(name, d, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/cloudfoundry/cloudfoundryfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestSettings(t *testing.T) {
+	spec.Run(t, "Settings", testSettings, spec.Report(report.Terminal{}))
+}
+
+func testSettings(t *testing.T, when spec.G, it spec.S) {
+	var (
+		dir       string
+		writeFile func(name, contents string) string
+	)
+
+	reset := func() {
+		os.Unsetenv("VCAP_APPLICATION")
+		os.Unsetenv("VCAP_SERVICES")
+		os.Unsetenv("PORT")
+
+		os.Unsetenv(ConfigFileEnv)
+		os.Unsetenv("IGNITION_SESSION_SECRET")
+		os.Unsetenv("IGNITION_COMPANY_NAME")
+		os.Unsetenv("IGNITION_COLLECT_ANALYTICS")
+		os.Unsetenv("IGNITION_PORT")
+		os.Unsetenv("IGNITION_SESSION_LIFETIME")
+		os.Unsetenv("IGNITION_SYSTEM_DOMAIN")
+		os.Unsetenv("IGNITION_UAA_ORIGIN")
+		os.Unsetenv("IGNITION_API_CLIENT_ID")
+		os.Unsetenv("IGNITION_API_CLIENT_SECRET")
+		os.Unsetenv("IGNITION_AUTHORIZED_DOMAIN")
+		os.Unsetenv("IGNITION_RUNNING_SECURITY_GROUPS")
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		reset()
+		var err error
+		dir, err = ioutil.TempDir("", "settings")
+		Expect(err).NotTo(HaveOccurred())
+		writeFile = func(name, contents string) string {
+			file := filepath.Join(dir, name)
+			Expect(ioutil.WriteFile(file, []byte(contents), 0600)).To(Succeed())
+			return file
+		}
+	})
+
+	it.After(func() {
+		reset()
+		os.RemoveAll(dir)
+	})
+
+	it("reads settings from a YAML config file", func() {
+		os.Setenv(ConfigFileEnv, writeFile("ignition.yml", `
+session_secret: file-secret
+company_name: File Company
+collect_analytics: true
+port: 8080
+session_lifetime: 12h
+session_previous_secrets: [old-secret, older-secret]
+`))
+		s, err := NewServer()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.SessionSecret).To(Equal("file-secret"))
+		Expect(s.CompanyName).To(Equal("File Company"))
+		Expect(s.CollectAnalytics).To(BeTrue())
+		Expect(s.Port).To(Equal(8080))
+		Expect(s.ServePort).To(Equal(3000))
+		Expect(s.SessionLifetime).To(Equal(12 * time.Hour))
+		Expect(s.SessionPreviousSecrets).To(Equal([]string{"old-secret", "older-secret"}))
+	})
+
+	it("reads the org template from a JSON config file", func() {
+		os.Setenv(ConfigFileEnv, writeFile("ignition.json", `{
+			"running_security_groups": "artifacts, internal-dns",
+			"template": {"spaces": [{"name": "dev"}, {"name": "test", "roles": ["auditor"]}]}
+		}`))
+		f := &cloudfoundryfakes.FakeAPI{}
+		f.GetOrgQuotaByNameReturns(cloudfoundry.Quota{GUID: "test-quota-id"}, nil)
+		f.ListIsolationSegmentsByNameReturns([]cloudfoundry.IsolationSegment{{Name: "shared", GUID: "shared-iso-segment-id"}}, nil)
+		f.ListSecurityGroupsByNameStub = func(name string) ([]cloudfoundry.SecurityGroup, error) {
+			return []cloudfoundry.SecurityGroup{{GUID: name + "-guid", Name: name}}, nil
+		}
+		e, err := NewExperimenter("ignition-config", f, f, f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(e.Template.Spaces).To(HaveLen(2))
+		Expect(e.Template.Spaces[1].Roles).To(Equal([]string{"auditor"}))
+		Expect(e.Template.Spaces[1].RunningSecurityGroups).To(Equal([]string{"artifacts", "internal-dns"}))
+		Expect(e.SpaceName).To(Equal("dev"))
+	})
+
+	it("prefers the environment to the config file, and ignition-config to the environment", func() {
+		os.Setenv(ConfigFileEnv, writeFile("ignition.yml", `
+session_secret: file-secret
+company_name: File Company
+port: 8080
+`))
+		os.Setenv("IGNITION_COMPANY_NAME", "Environment Company")
+		os.Setenv("IGNITION_SESSION_SECRET", "environment-secret")
+		s, err := NewServer()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.SessionSecret).To(Equal("environment-secret"))
+		Expect(s.CompanyName).To(Equal("Environment Company"))
+		Expect(s.Port).To(Equal(8080))
+
+		os.Setenv("VCAP_APPLICATION", `{"application_name": "ignition", "application_uris": ["ignition.example.net"], "name": "ignition"}`)
+		os.Setenv("PORT", "54321")
+		os.Setenv("VCAP_SERVICES", `{"user-provided": [{
+			"name": "ignition-config",
+			"credentials": {"session_secret": "service-secret", "collect_analytics": true}
+		}]}`)
+		s, err = NewServer()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.SessionSecret).To(Equal("service-secret"))
+		Expect(s.CompanyName).To(Equal("Environment Company"))
+		Expect(s.CollectAnalytics).To(BeTrue())
+	})
+
+	it("only accepts true or false for a boolean", func() {
+		os.Setenv("IGNITION_SESSION_SECRET", "environment-secret")
+		os.Setenv("IGNITION_COLLECT_ANALYTICS", "yes")
+		_, err := NewServer()
+		Expect(err).To(MatchError("invalid configuration: collect_analytics [yes] from IGNITION_COLLECT_ANALYTICS must be true or false"))
+	})
+
+	it("reports every invalid setting at once", func() {
+		file := writeFile("ignition.yml", `
+session_lifetime: a day
+company_name: [Acme, Widgets]
+system_domain: run.example.net
+uaa_origin: okta
+api_client_id: ignition
+api_client_secret: ignition-secret
+skip_tls_validaton: true
+`)
+		os.Setenv(ConfigFileEnv, file)
+		os.Setenv("IGNITION_PORT", "https")
+		os.Setenv("IGNITION_AUTHORIZED_DOMAIN", "example.net")
+		err := Validate()
+		Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		Expect(err.(*ValidationError).Problems).To(ConsistOf(
+			"skip_tls_validaton in "+file+" is not an ignition setting",
+			"port [https] from IGNITION_PORT must be a whole number",
+			"session_secret is required",
+			"company_name [[Acme Widgets]] from "+file+" must be a single value",
+			"session_lifetime [a day] from "+file+" must be a duration, such as 90s, 15m, or 24h",
+		))
+	})
+
+	it("is valid when every required setting is set", func() {
+		os.Setenv(ConfigFileEnv, writeFile("ignition.yml", `
+session_secret: file-secret
+system_domain: run.example.net
+uaa_origin: okta
+api_client_id: ignition
+api_client_secret: ignition-secret
+client_id: ignition-sso
+client_secret: ignition-sso-secret
+auth_url: https://login.example.net
+authorized_domain: [example.net, example.com]
+`))
+		Expect(Validate()).To(Succeed())
+	})
+
+	it("reports the settings that New rejects without contacting any service", func() {
+		file := writeFile("ignition.json", `{
+			"session_secret": "file-secret",
+			"session_backend": "redis",
+			"system_domain": "run.example.net",
+			"uaa_origin": "okta",
+			"api_client_id": "ignition",
+			"api_client_secret": "ignition-secret",
+			"cc_api_version": "v4",
+			"client_id": "ignition-sso",
+			"client_secret": "ignition-sso-secret",
+			"auth_url": "https://login.example.net",
+			"authorized_domain": "example.net",
+			"template": {"spaces": "dev"},
+			"foundations": [{"name": "emea"}]
+		}`)
+		os.Setenv(ConfigFileEnv, file)
+		err := Validate()
+		Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		problems := err.(*ValidationError).Problems
+		Expect(problems).To(HaveLen(3))
+		Expect(problems[0]).To(Equal("session_redis_url is required when session_backend is redis"))
+		Expect(problems[1]).To(Equal("cc_api_version must be v2 or v3, not [v4]"))
+		Expect(problems[2]).To(HavePrefix("could not parse org template"))
+
+		os.Setenv(ConfigFileEnv, writeFile("ignition.json", `{
+			"session_secret": "file-secret",
+			"system_domain": "run.example.net",
+			"uaa_origin": "okta",
+			"api_client_id": "ignition",
+			"api_client_secret": "ignition-secret",
+			"client_id": "ignition-sso",
+			"client_secret": "ignition-sso-secret",
+			"auth_url": "https://login.example.net",
+			"authorized_domain": "example.net",
+			"foundations": [{"name": "emea"}]
+		}`))
+		Expect(Validate()).To(MatchError("invalid configuration: could not configure foundation [emea]: system_domain is required"))
+	})
+
+	it("errors when the config file cannot be read or parsed", func() {
+		os.Setenv(ConfigFileEnv, filepath.Join(dir, "missing.yml"))
+		Expect(Validate()).To(MatchError(ContainSubstring("could not read config file")))
+
+		os.Setenv(ConfigFileEnv, writeFile("ignition.yml", "port: 8080\nport: 8081\n"))
+		Expect(Validate()).To(MatchError(ContainSubstring("could not parse config file")))
+	})
+
+	it("describes every setting in the config file schema", func() {
+		b, err := ioutil.ReadFile(filepath.Join("..", "docs", "config.schema.json"))
+		Expect(err).NotTo(HaveOccurred())
+		var doc struct {
+			Properties map[string]struct {
+				Type interface{} `json:"type"`
+			} `json:"properties"`
+		}
+		Expect(json.Unmarshal(b, &doc)).To(Succeed())
+
+		types := map[string]interface{}{}
+		for key, p := range doc.Properties {
+			types[key] = p.Type
+		}
+		expected := map[string]interface{}{}
+		for key, typ := range schema() {
+			switch {
+			case key == "template":
+				expected[key] = "object"
+			case key == "foundations":
+				expected[key] = "array"
+			case typ == reflect.TypeOf(time.Duration(0)):
+				expected[key] = "string"
+			case typ.Kind() == reflect.Bool:
+				expected[key] = "boolean"
+			case typ.Kind() == reflect.Int:
+				expected[key] = "integer"
+			case typ.Kind() == reflect.Slice:
+				expected[key] = []interface{}{"array", "string"}
+			default:
+				expected[key] = "string"
+			}
+		}
+		Expect(types).To(Equal(expected))
+	})
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pkg/errors"
+)
+
+// orgTemplate returns the template for new orgs. The template setting takes
+// precedence over a template file; when neither is provided the template is a
+// single space with the given name.
+func orgTemplate(spaceName string, templateFile string, setting interface{}) (cloudfoundry.OrgTemplate, error) {
+	var t cloudfoundry.OrgTemplate
+	var b []byte
+	var err error
+	switch c := setting.(type) {
+	case nil:
+		if templateFile == "" {
+			return cloudfoundry.DefaultOrgTemplate(spaceName), nil
+		}
+		b, err = ioutil.ReadFile(templateFile)
+		if err != nil {
+			return t, errors.Wrapf(err, "could not read org template file [%s]", templateFile)
+		}
+	case string:
+		b = []byte(c)
+	default:
+		b, err = json.Marshal(c)
+		if err != nil {
+			return t, errors.Wrap(err, "could not read org template")
+		}
+	}
+
+	err = json.Unmarshal(b, &t)
+	if err != nil {
+		return t, errors.Wrap(err, "could not parse org template")
+	}
+	err = t.Validate()
+	if err != nil {
+		return t, err
+	}
+	return t, nil
+}
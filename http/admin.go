@@ -0,0 +1,51 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/pivotalservices/ignition/http/session"
+)
+
+func (a *API) handleAdmin(r *mux.Router, locker api.Locker) {
+	policy := a.Ignition.Authorizer.Policy()
+	admin := func(h http.Handler) http.Handler {
+		h = Secure(RequireAdmin(h, policy), policy, a.Ignition.Server.SessionStore, a.refresher())
+		return ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, h)
+	}
+	e := a.Ignition.Experimenter
+	foundations := a.foundations()
+
+	r.Handle("/api/v1/admin/audit", admin(api.AuditHandler(audit.Default))).Methods(http.MethodGet).Name("audit")
+	r.Handle("/api/v1/admin/config", admin(api.AdminConfigHandler(a.experimenterConfig()))).Methods(http.MethodGet).Name("admin-config")
+	r.Handle("/api/v1/admin/orgs", admin(api.AdminOrgsHandler(foundations))).Methods(http.MethodGet).Name("admin-orgs")
+	r.Handle("/api/v1/admin/orgs/{guid}", admin(api.AdminDeleteOrgHandler(locker, foundations))).Methods(http.MethodDelete).Name("admin-delete-org")
+	r.Handle("/api/v1/admin/orgs/{guid}/reset", admin(api.AdminResetOrgHandler(e.Template, locker, foundations))).Methods(http.MethodPost).Name("admin-reset-org")
+	r.Handle("/api/v1/admin/users/{account}/org", admin(api.AdminUserOrgHandler(e.OrgPrefix, foundations))).Methods(http.MethodGet).Name("admin-user-org")
+	r.Handle("/api/v1/admin/reaper", admin(api.AdminReaperHandler(a.reapers))).Methods(http.MethodGet).Name("admin-reaper")
+	revoker, _ := a.Ignition.Server.SessionStore.(session.Revoker)
+	r.Handle("/api/v1/admin/users/{account}/sessions", admin(api.AdminRevokeSessionsHandler(revoker))).Methods(http.MethodDelete).Name("admin-revoke-sessions")
+}
+
+// experimenterConfig is the Experimenter configuration shown to admins
+func (a *API) experimenterConfig() api.ExperimenterConfig {
+	e := a.Ignition.Experimenter
+	return api.ExperimenterConfig{
+		OrgPrefix:              e.OrgPrefix,
+		OrgCountUpdateInterval: e.OrgCountUpdateInterval.String(),
+		QuotaName:              e.QuotaName,
+		QuotaID:                e.QuotaID,
+		ISOSegmentName:         e.ISOSegmentName,
+		ISOSegmentID:           e.ISOSegmentID,
+		OrgTTL:                 e.OrgTTL.String(),
+		OrgExpiryWarning:       e.OrgExpiryWarning.String(),
+		OrgReapInterval:        e.OrgReapInterval.String(),
+		OrgReapDryRun:          e.OrgReapDryRun,
+		TemplateFile:           e.TemplateFile,
+		Template:               e.Template,
+		RunningSecurityGroups:  e.RunningSecurityGroups,
+		StagingSecurityGroups:  e.StagingSecurityGroups,
+	}
+}
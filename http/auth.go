@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"net/http"
 	"strings"
 	"time"
@@ -11,8 +12,10 @@ import (
 	dgoauth2 "github.com/dghubble/gologin/oauth2"
 	"github.com/dghubble/sessions"
 	"github.com/gorilla/mux"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/audit"
 	"github.com/pivotalservices/ignition/http/session"
-	"github.com/pivotalservices/ignition/uaa"
+	"github.com/pivotalservices/ignition/metrics"
 	"github.com/pivotalservices/ignition/user"
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2"
@@ -25,23 +28,49 @@ func (a *API) handleAuth(r *mux.Router) {
 	}
 
 	oauth2SuccessHandler := session.IssueSession(a.Ignition.Server.SessionStore, a.Ignition.Deployment.UAA)
+	oauth2SuccessHandler = recordDeniedLogin(oauth2SuccessHandler, a.Ignition.Authorizer.Policy())
 	oauth2FailureHandler := session.LogoutHandler(a.Ignition.Server.SessionStore)
-	oauth2Handler := CallbackHandler(a.Ignition.Authorizer.Config, a.Ignition.Authorizer.Fetcher, oauth2SuccessHandler, oauth2FailureHandler)
+	oauth2Handler := CallbackHandler(a.Ignition.Authorizer.Config, a.Ignition.Authorizer.Fetcher, stateConfig, oauth2SuccessHandler, oauth2FailureHandler)
 	oauth2Handler = dgoauth2.StateHandler(stateConfig, oauth2Handler)
 	oauth2Handler = ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, oauth2Handler)
 	oauth2Handler = ensureHTTPS(oauth2Handler)
 
-	loginHandler := dgoauth2.LoginHandler(a.Ignition.Authorizer.Config, nil)
+	loginHandler := LoginHandler(a.Ignition.Authorizer.Config, stateConfig, nil)
 	loginHandler = dgoauth2.StateHandler(stateConfig, loginHandler)
 	loginHandler = ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, loginHandler)
 	loginHandler = ensureHTTPS(loginHandler)
 
 	r.Handle("/login", loginHandler).Name("login")
 	r.Handle("/oauth2", oauth2Handler).Name("oauth2")
-	r.Handle("/logout", ensureHTTPS(session.LogoutHandler(a.Ignition.Server.SessionStore))).Name("logout")
+	logoutHandler := session.LogoutHandler(a.Ignition.Server.SessionStore)
+	if p := a.Ignition.Authorizer.Provider; p != nil && p.EndSessionURL != "" {
+		logoutHandler = session.EndSessionHandler(a.Ignition.Server.SessionStore, p.EndSessionURL, a.Ignition.Authorizer.ClientID, a.URI()+"/")
+	}
+
+	r.Handle("/logout", ensureHTTPS(logoutHandler)).Name("logout")
+	// signing out everywhere revokes every one of the user's sessions, so it
+	// is only done for a form posted by ignition's own pages
+	logoutEverywhereHandler := ensureSameOrigin(session.LogoutEverywhereHandler(a.Ignition.Server.SessionStore), a.URI())
+	r.Handle("/logout/everywhere", ensureHTTPS(logoutEverywhereHandler)).Methods(http.MethodPost).Name("logout-everywhere")
+	r.Handle("/logout/backchannel", ensureHTTPS(session.BackChannelLogoutHandler(a.Ignition.Server.SessionStore, a.Ignition.Authorizer.Verifier))).Methods(http.MethodPost).Name("backchannel-logout")
+}
+
+// refresher refreshes the tokens in users' sessions, and ends sessions that
+// have outlived the session lifetime
+func (a *API) refresher() *session.Refresher {
+	return &session.Refresher{
+		Config:  a.Ignition.Authorizer.Config,
+		Fetcher: a.Ignition.Authorizer.Fetcher,
+		MaxAge:  a.Ignition.Server.SessionLifetime,
+	}
 }
 
-func ensureUser(next http.Handler, uaa uaa.API, origin string, s sessions.Store) http.Handler {
+// ensureUser creates the user in the first foundation's UAA, and saves their
+// ID in the session, when that is the foundation selected for them (see
+// api.SelectFoundation) and their session does not have an ID yet. A user
+// selected for another foundation is not created in the first foundation's
+// UAA; the handler creates them in the foundation that it provisions in.
+func ensureUser(next http.Handler, foundations []api.Foundation, s sessions.Store) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		userID, err := session.UserIDFromContext(r.Context())
 		if strings.TrimSpace(userID) != "" {
@@ -54,12 +83,18 @@ func ensureUser(next http.Handler, uaa uaa.API, origin string, s sessions.Store)
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
+			f, err := api.SelectFoundation(foundations, profile, r.URL.Query().Get("foundation"))
+			if err != nil || f.Name != foundations[0].Name {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-			userID, err = uaa.CreateUser(profile.AccountName, origin, profile.AccountName, profile.Email)
+			userID, err = f.UAA.CreateUser(profile.AccountName, f.UAAOrigin, profile.AccountName, profile.Email)
 			if err != nil || strings.TrimSpace(userID) == "" {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
+			audit.Record(audit.Event{Type: audit.EventUserCreated, AccountName: profile.AccountName, Email: profile.Email, UserID: userID})
 			r = r.WithContext(session.ContextWithUserID(r.Context(), userID))
 			session.UpdateSessionWithUserID(w, r, s, userID)
 		}
@@ -90,16 +125,69 @@ func Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
-// Authorize guards access to protected resources by inspecting the user's token
-func Authorize(next http.Handler, domain string) http.Handler {
+// Authorize guards access to protected resources by checking the user's
+// profile against the policy
+func Authorize(next http.Handler, policy *user.Policy) http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		profile, err := user.ProfileFromContext(req.Context())
 		if err != nil {
 			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
-		if strings.TrimSpace(domain) != "" && !strings.HasSuffix(strings.ToLower(profile.Email), domain) {
-			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		err = policy.Authorize(profile)
+		if err != nil {
+			writeForbidden(w, err.Error())
+			return
+		}
+		next.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// recordDeniedLogin records a login by a user that the policy does not
+// authorize, once when they sign in rather than on each request that Authorize
+// forbids. The session is still issued, so that the SPA can tell the user why
+// they are forbidden.
+func recordDeniedLogin(next http.Handler, policy *user.Policy) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		profile, err := user.ProfileFromContext(req.Context())
+		if err == nil {
+			if err = policy.Authorize(profile); err != nil {
+				audit.Record(audit.Event{Type: audit.EventLoginDenied, AccountName: profile.AccountName, Email: profile.Email, Reason: err.Error()})
+			}
+		}
+		next.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// forbiddenResponse tells the SPA why the user is not authorized
+type forbiddenResponse struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+func writeForbidden(w http.ResponseWriter, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(forbiddenResponse{
+		Error:  http.StatusText(http.StatusForbidden),
+		Reason: reason,
+	})
+}
+
+// RequireAdmin only allows users that are administrators according to the
+// policy to access the protected resource
+func RequireAdmin(next http.Handler, policy *user.Policy) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		profile, err := user.ProfileFromContext(req.Context())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if !policy.IsAdmin(profile) {
+			writeForbidden(w, "you are not an administrator")
 			return
 		}
 		next.ServeHTTP(w, req)
@@ -108,22 +196,25 @@ func Authorize(next http.Handler, domain string) http.Handler {
 }
 
 // Secure ensures the current request is TLS secured, authenticated, and authorized
-func Secure(next http.Handler, domain string, store sessions.Store) http.Handler {
-	return ensureHTTPS(session.PopulateContext(Authenticate(Authorize(next, domain)), store))
+func Secure(next http.Handler, policy *user.Policy, store sessions.Store, r *session.Refresher) http.Handler {
+	return ensureHTTPS(session.PopulateContext(Authenticate(Authorize(next, policy)), store, r))
 }
 
-// CallbackHandler handles Google redirection URI requests and adds the Google
-// access token and Userinfoplus to the ctx. If authentication succeeds,
-// handling delegates to the success handler, otherwise to the failure handler.
-func CallbackHandler(config *oauth2.Config, fetcher user.Fetcher, success, failure http.Handler) http.Handler {
+// CallbackHandler handles redirection URI requests from the identity provider
+// and adds the access token and the user's profile to the ctx. The code is
+// exchanged using the PKCE code verifier from LoginHandler, and the ID token
+// must have the nonce from LoginHandler. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+func CallbackHandler(config *oauth2.Config, fetcher user.Fetcher, cookieConfig gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
 	wrappedSuccessHandler := func(config *oauth2.Config, f user.Fetcher, success, failure http.Handler) http.Handler {
-		if failure == nil {
-			failure = gologin.DefaultFailureHandler
-		}
 		fn := func(w http.ResponseWriter, req *http.Request) {
 			ctx := req.Context()
 			token, err := dgoauth2.TokenFromContext(ctx)
 			if err != nil {
+				metrics.LoginFailed(metrics.LoginFailureToken)
 				ctx = gologin.WithError(ctx, err)
 				failure.ServeHTTP(w, req.WithContext(ctx))
 				return
@@ -132,6 +223,7 @@ func CallbackHandler(config *oauth2.Config, fetcher user.Fetcher, success, failu
 			profile, err := f.Profile(ctx, config, token)
 			err = validateResponse(profile, err)
 			if err != nil {
+				metrics.LoginFailed(metrics.LoginFailureProfile)
 				ctx = gologin.WithError(ctx, err)
 				failure.ServeHTTP(w, req.WithContext(ctx))
 				return
@@ -141,7 +233,17 @@ func CallbackHandler(config *oauth2.Config, fetcher user.Fetcher, success, failu
 		}
 		return http.HandlerFunc(fn)
 	}(config, fetcher, success, failure)
-	return dgoauth2.CallbackHandler(config, wrappedSuccessHandler, failure)
+	return exchangeHandler(config, cookieConfig, wrappedSuccessHandler, countLoginFailure(metrics.LoginFailureOAuth2, failure))
+}
+
+// countLoginFailure counts a failed login for the given reason before
+// handling the failure
+func countLoginFailure(reason string, failure http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		metrics.LoginFailed(reason)
+		failure.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
 }
 
 // validateResponse returns an error if the given profile, raw
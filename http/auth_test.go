@@ -2,15 +2,21 @@ package http
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/dghubble/gologin"
 	dgoauth2 "github.com/dghubble/gologin/oauth2"
 	"github.com/dghubble/sessions"
 	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/audit"
 	"github.com/pivotalservices/ignition/http/session"
 	"github.com/pivotalservices/ignition/http/session/sessionfakes"
 	"github.com/pivotalservices/ignition/uaa/uaafakes"
@@ -184,7 +190,7 @@ func testAuthorize(t *testing.T, when spec.G, it spec.S) {
 		})
 
 		it("is unauthorized if there is no profile in the context", func() {
-			Authorize(nil, "").ServeHTTP(w, req)
+			Authorize(nil, &user.Policy{}).ServeHTTP(w, req)
 			Expect(w.Code).To(Equal(http.StatusUnauthorized))
 		})
 
@@ -198,18 +204,46 @@ func testAuthorize(t *testing.T, when spec.G, it spec.S) {
 				req = req.WithContext(user.WithProfile(req.Context(), profile))
 			})
 
-			it("is forbidden if the user's email does not end with the domain", func() {
-				Authorize(nil, "example.com").ServeHTTP(w, req)
+			it("is forbidden if the user's email is not in one of the domains", func() {
+				Authorize(nil, &user.Policy{Domains: []string{"example.com", "example.org"}}).ServeHTTP(w, req)
 				Expect(w.Code).To(Equal(http.StatusForbidden))
 			})
 
-			it("calls the next handler if the user's email does end with the domain", func() {
+			it("tells the SPA why the user is forbidden", func() {
+				Authorize(nil, &user.Policy{Domains: []string{"example.com"}}).ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+				Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+				Expect(w.Body.String()).To(MatchJSON(`{"error": "Forbidden", "reason": "email domain example.net is not authorized"}`))
+			})
+
+			it("is forbidden if the user is denied", func() {
+				Authorize(nil, &user.Policy{Domains: []string{"example.net"}, DeniedUsers: []string{"corp\tester"}}).ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+			})
+
+			it("does not record a denied login on each request", func() {
+				l := audit.NewLog(10)
+				audit.SetDefault(l)
+				defer audit.SetDefault(nil)
+				Authorize(nil, &user.Policy{Domains: []string{"example.com"}}).ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+				Expect(l.Recent(audit.Filter{Type: audit.EventLoginDenied})).To(BeEmpty())
+			})
+
+			it("is forbidden if the user is in a denied group", func() {
+				profile, _ := user.ProfileFromContext(req.Context())
+				profile.Groups = []string{"contractors"}
+				Authorize(nil, &user.Policy{Domains: []string{"example.net"}, DeniedGroups: []string{"contractors"}}).ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+			})
+
+			it("calls the next handler if the user's email is in the domain", func() {
 				called := false
 				next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 					called = true
 					w.WriteHeader(http.StatusOK)
 				})
-				Authorize(next, "example.net").ServeHTTP(w, req)
+				Authorize(next, &user.Policy{Domains: []string{"example.net"}}).ServeHTTP(w, req)
 				Expect(called).To(BeTrue())
 				Expect(w.Code).To(Equal(http.StatusOK))
 			})
@@ -217,19 +251,156 @@ func testAuthorize(t *testing.T, when spec.G, it spec.S) {
 	})
 }
 
+func TestRecordDeniedLogin(t *testing.T) {
+	spec.Run(t, "recordDeniedLogin", testRecordDeniedLogin, spec.Report(report.Terminal{}))
+}
+
+func testRecordDeniedLogin(t *testing.T, when spec.G, it spec.S) {
+	var (
+		l      *audit.Log
+		called bool
+		next   http.Handler
+		req    *http.Request
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		l = audit.NewLog(10)
+		audit.SetDefault(l)
+		called = false
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+		profile := &user.Profile{Email: "test@example.net", AccountName: "tester"}
+		req = httptest.NewRequest(http.MethodGet, "/oauth2", nil)
+		req = req.WithContext(user.WithProfile(req.Context(), profile))
+	})
+
+	it.After(func() {
+		audit.SetDefault(nil)
+	})
+
+	it("records that the user was denied, and issues the session", func() {
+		recordDeniedLogin(next, &user.Policy{Domains: []string{"example.com"}}).ServeHTTP(httptest.NewRecorder(), req)
+		Expect(called).To(BeTrue())
+		events := l.Recent(audit.Filter{Type: audit.EventLoginDenied})
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Email).To(Equal("test@example.net"))
+		Expect(events[0].Reason).To(Equal("email domain example.net is not authorized"))
+	})
+
+	it("records nothing when the user is authorized", func() {
+		recordDeniedLogin(next, &user.Policy{Domains: []string{"example.net"}}).ServeHTTP(httptest.NewRecorder(), req)
+		Expect(called).To(BeTrue())
+		Expect(l.Recent(audit.Filter{Type: audit.EventLoginDenied})).To(BeEmpty())
+	})
+}
+
+func TestRequireAdmin(t *testing.T) {
+	spec.Run(t, "RequireAdmin", testRequireAdmin, spec.Report(report.Terminal{}))
+}
+
+func testRequireAdmin(t *testing.T, when spec.G, it spec.S) {
+	var (
+		called bool
+		next   http.Handler
+		w      *httptest.ResponseRecorder
+		req    *http.Request
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		called = false
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+	})
+
+	it("is unauthorized if there is no profile in the context", func() {
+		RequireAdmin(next, &user.Policy{AdminEmails: []string{"admin@example.net"}}).ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		Expect(called).To(BeFalse())
+	})
+
+	when("there is a profile in the context", func() {
+		it.Before(func() {
+			req = req.WithContext(user.WithProfile(req.Context(), &user.Profile{Email: "Admin@example.net", Groups: []string{"platform-operators"}}))
+		})
+
+		it("is forbidden if the user is not an admin", func() {
+			RequireAdmin(next, &user.Policy{AdminEmails: []string{"someone@example.net"}}).ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusForbidden))
+			Expect(called).To(BeFalse())
+		})
+
+		it("is forbidden if there are no admins", func() {
+			RequireAdmin(next, &user.Policy{}).ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusForbidden))
+		})
+
+		it("calls the next handler if the user is an admin", func() {
+			RequireAdmin(next, &user.Policy{AdminEmails: []string{"admin@example.net"}}).ServeHTTP(w, req)
+			Expect(called).To(BeTrue())
+		})
+
+		it("calls the next handler if the user is in an admin group", func() {
+			RequireAdmin(next, &user.Policy{AdminGroups: []string{"platform-operators"}}).ServeHTTP(w, req)
+			Expect(called).To(BeTrue())
+		})
+	})
+}
+
 func TestCallbackHandler(t *testing.T) {
 	spec.Run(t, "CallbackHandler", testCallbackHandler, spec.Report(report.Terminal{}))
 }
 
 func testCallbackHandler(t *testing.T, when spec.G, it spec.S) {
 	var (
-		s            *httptest.Server
-		fakeVerifier *openidfakes.FakeVerifier
+		s             *httptest.Server
+		fakeVerifier  *openidfakes.FakeVerifier
+		config        *oauth2.Config
+		codeChallenge string
+		handler       http.Handler
+		succeeded     bool
+		failed        bool
+		ctxActual     context.Context
 	)
 
+	// login starts a login, returning the nonce sent to the identity provider
+	// and a callback request that carries the login cookie
+	login := func() (string, *http.Request) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/login", nil)
+		LoginHandler(config, gologin.DebugOnlyCookieConfig, nil).ServeHTTP(w, r.WithContext(dgoauth2.WithState(r.Context(), "teststate")))
+		Expect(w.Code).To(Equal(http.StatusFound))
+		location, err := url.Parse(w.Header().Get("Location"))
+		Expect(err).NotTo(HaveOccurred())
+		codeChallenge = location.Query().Get("code_challenge")
+
+		callback := httptest.NewRequest(http.MethodGet, "/oauth2?state=teststate&code=testcode", nil)
+		for _, c := range w.Result().Cookies() {
+			callback.AddCookie(c)
+		}
+		return location.Query().Get("nonce"), callback.WithContext(dgoauth2.WithState(callback.Context(), "teststate"))
+	}
+
 	it.Before(func() {
 		RegisterTestingT(t)
+		succeeded = false
+		failed = false
+		// s is a local stand in for the identity provider's token endpoint,
+		// which checks the PKCE code verifier against the code challenge
 		s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if r.PostForm.Get("code") != "testcode" || base64.RawURLEncoding.EncodeToString(sum[:]) != codeChallenge {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "invalid_grant"}`))
+				return
+			}
 			w.Header().Set("Content-Type", "application/json")
 			w.Write([]byte(
 				`{
@@ -242,24 +413,16 @@ func testCallbackHandler(t *testing.T, when spec.G, it spec.S) {
 					"jti": "1234567890"
 				}`))
 		}))
+		config = &oauth2.Config{
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  s.URL + "/oauth/authorize?prompt=select_account",
+				TokenURL: s.URL + "/oauth/token",
+			},
+		}
 		fakeVerifier = &openidfakes.FakeVerifier{}
-		fakeVerifier.VerifyReturns(&openid.Claims{
-			UserName: "testuser",
-			Email:    "test@pivotal.io",
-		}, nil)
-	})
-
-	it.After(func() {
-		s.Close()
-	})
-
-	it("", func() {
 		fetcher := &openid.Fetcher{
 			Verifier: fakeVerifier,
 		}
-		succeeded := false
-		failed := false
-		var ctxActual context.Context
 		success := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			succeeded = true
 			ctxActual = r.Context()
@@ -268,18 +431,40 @@ func testCallbackHandler(t *testing.T, when spec.G, it spec.S) {
 			failed = true
 			ctxActual = r.Context()
 		})
-		handler := CallbackHandler(&oauth2.Config{
-			Endpoint: oauth2.Endpoint{
-				AuthURL:  s.URL,
-				TokenURL: s.URL,
-			},
-		}, fetcher, success, failure)
+		handler = CallbackHandler(config, fetcher, gologin.DebugOnlyCookieConfig, success, failure)
+	})
 
+	it.After(func() {
+		s.Close()
+	})
+
+	it("sends a PKCE code challenge and a nonce to the identity provider", func() {
 		w := httptest.NewRecorder()
-		r := httptest.NewRequest(http.MethodGet, "/oauth2?state=teststate&code=testcode", nil)
-		ctx := context.Background()
-		ctx = dgoauth2.WithState(ctx, "teststate")
-		handler.ServeHTTP(w, r.WithContext(ctx))
+		r := httptest.NewRequest(http.MethodGet, "/login", nil)
+		LoginHandler(config, gologin.DebugOnlyCookieConfig, nil).ServeHTTP(w, r.WithContext(dgoauth2.WithState(r.Context(), "teststate")))
+		location, err := url.Parse(w.Header().Get("Location"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(location.Query().Get("prompt")).To(Equal("select_account"))
+		Expect(location.Query().Get("state")).To(Equal("teststate"))
+		Expect(location.Query().Get("code_challenge_method")).To(Equal("S256"))
+		Expect(location.Query().Get("code_challenge")).To(HaveLen(43))
+		Expect(location.Query().Get("nonce")).NotTo(BeEmpty())
+		cookies := w.Result().Cookies()
+		Expect(cookies).To(HaveLen(1))
+		Expect(cookies[0].Name).To(Equal("ignition-login"))
+		Expect(cookies[0].HttpOnly).To(BeTrue())
+		Expect(cookies[0].Value).NotTo(ContainSubstring(location.Query().Get("code_challenge")))
+	})
+
+	it("adds the profile to the context when the code verifier and nonce match", func() {
+		nonce, r := login()
+		fakeVerifier.VerifyReturns(&openid.Claims{
+			UserName: "testuser",
+			Email:    "test@pivotal.io",
+			Nonce:    nonce,
+		}, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
 
 		Expect(failed).To(BeFalse())
 		Expect(succeeded).To(BeTrue())
@@ -287,6 +472,49 @@ func testCallbackHandler(t *testing.T, when spec.G, it spec.S) {
 		p, err := user.ProfileFromContext(ctxActual)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(p.Email).To(Equal("test@pivotal.io"))
+		Expect(w.Result().Cookies()).To(ContainElement(WithTransform(func(c *http.Cookie) bool {
+			return c.Name == "ignition-login" && c.MaxAge < 0
+		}, BeTrue())))
+	})
+
+	it("fails when the ID token has a different nonce", func() {
+		_, r := login()
+		fakeVerifier.VerifyReturns(&openid.Claims{
+			UserName: "testuser",
+			Email:    "test@pivotal.io",
+			Nonce:    "replayed-nonce",
+		}, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		Expect(succeeded).To(BeFalse())
+		Expect(failed).To(BeTrue())
+		Expect(gologin.ErrorFromContext(ctxActual).Error()).To(ContainSubstring("nonce"))
+	})
+
+	it("fails when the code verifier does not match the code challenge", func() {
+		nonce, r := login()
+		fakeVerifier.VerifyReturns(&openid.Claims{UserName: "testuser", Nonce: nonce}, nil)
+		r.Header.Del("Cookie")
+		r.AddCookie(&http.Cookie{Name: "ignition-login", Value: "another-verifier." + nonce})
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		Expect(succeeded).To(BeFalse())
+		Expect(failed).To(BeTrue())
+	})
+
+	it("fails without the login cookie", func() {
+		nonce, r := login()
+		fakeVerifier.VerifyReturns(&openid.Claims{UserName: "testuser", Nonce: nonce}, nil)
+		r.Header.Del("Cookie")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		Expect(succeeded).To(BeFalse())
+		Expect(failed).To(BeTrue())
+	})
+
+	it("fails when the state does not match", func() {
+		nonce, r := login()
+		fakeVerifier.VerifyReturns(&openid.Claims{UserName: "testuser", Nonce: nonce}, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r.WithContext(dgoauth2.WithState(r.Context(), "otherstate")))
+		Expect(succeeded).To(BeFalse())
+		Expect(failed).To(BeTrue())
 	})
 }
 
@@ -302,6 +530,8 @@ func testEnsureUser(t *testing.T, when spec.G, it spec.S) {
 		w                *httptest.ResponseRecorder
 		r                *http.Request
 		uaa              *uaafakes.FakeAPI
+		emeaUAA          *uaafakes.FakeAPI
+		foundations      []api.Foundation
 		fakeSessionStore *sessionfakes.FakeStore
 	)
 
@@ -310,6 +540,7 @@ func testEnsureUser(t *testing.T, when spec.G, it spec.S) {
 
 		called = false
 		uaa = &uaafakes.FakeAPI{}
+		emeaUAA = &uaafakes.FakeAPI{}
 		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			called = true
 		})
@@ -317,7 +548,11 @@ func testEnsureUser(t *testing.T, when spec.G, it spec.S) {
 		s := sessions.NewSession(fakeSessionStore, "ignition-test")
 		fakeSessionStore.SaveReturns(nil)
 		fakeSessionStore.GetReturns(s, nil)
-		handler = ensureUser(next, uaa, "origin", fakeSessionStore)
+		foundations = []api.Foundation{
+			{Name: "default", UAAOrigin: "origin", UAA: uaa},
+			{Name: "emea", UAAOrigin: "emea-origin", UAA: emeaUAA, Groups: []string{"emea"}},
+		}
+		handler = ensureUser(next, foundations, fakeSessionStore)
 		w = httptest.NewRecorder()
 		r = httptest.NewRequest(http.MethodGet, "/", nil)
 	})
@@ -354,6 +589,35 @@ func testEnsureUser(t *testing.T, when spec.G, it spec.S) {
 				handler.ServeHTTP(w, r.WithContext(ctx))
 				Expect(called).To(BeTrue())
 				Expect(uaa.CreateUserCallCount()).To(Equal(1))
+				_, origin, _, _ := uaa.CreateUserArgsForCall(0)
+				Expect(origin).To(Equal("origin"))
+			})
+
+			it("does not create the user in the first foundation when another foundation is selected for them", func() {
+				profile, _ := user.ProfileFromContext(ctx)
+				profile.Groups = []string{"emea"}
+				handler.ServeHTTP(w, r.WithContext(ctx))
+				Expect(called).To(BeTrue())
+				Expect(uaa.CreateUserCallCount()).To(Equal(0))
+				Expect(emeaUAA.CreateUserCallCount()).To(Equal(0))
+
+				called = false
+				profile.Groups = nil
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?foundation=emea", nil).WithContext(ctx))
+				Expect(called).To(BeTrue())
+				Expect(uaa.CreateUserCallCount()).To(Equal(0))
+			})
+
+			it("records that the user was created", func() {
+				l := audit.NewLog(10)
+				audit.SetDefault(l)
+				defer audit.SetDefault(nil)
+				uaa.CreateUserReturns("test-user-id", nil)
+				handler.ServeHTTP(w, r.WithContext(ctx))
+				events := l.Recent(audit.Filter{Type: audit.EventUserCreated})
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].UserID).To(Equal("test-user-id"))
+				Expect(events[0].AccountName).To(Equal("testaccount"))
 			})
 
 			it("is unauthorized if the user cannot be created", func() {
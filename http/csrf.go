@@ -0,0 +1,84 @@
+package http
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pivotalservices/ignition/logging"
+)
+
+// requestedWithHeader is set by the SPA, and by any script, on the requests
+// that change state. Browsers only let a cross-site page set it once ignition
+// has allowed it with CORS, which ignition does not do.
+const requestedWithHeader = "X-Requested-With"
+
+// ensureSameOrigin only allows requests that were made by ignition's own pages:
+// their Origin, or their Referer when the browser did not send an Origin, must
+// be the given uri. The session cookie alone does not show that the user meant
+// to make a request, because a browser sends it with the requests that other
+// sites make, such as the submission of a cross-site form.
+func ensureSameOrigin(next http.Handler, uri string) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			origin = req.Referer()
+		}
+		if !sameOrigin(origin, uri) {
+			logging.FromContext(req.Context()).Warn("refusing a cross-origin request", "origin", origin)
+			writeForbidden(w, "the request was not made by ignition")
+			return
+		}
+		next.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// ensureNotSimple only allows requests that a cross-site page cannot make
+// without CORS, which are those with an X-Requested-With header or a JSON
+// body. Forms and other simple requests are refused.
+func ensureNotSimple(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(requestedWithHeader) == "" {
+			mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			if mediaType != "application/json" {
+				writeForbidden(w, "the request must have an X-Requested-With header or a JSON body")
+				return
+			}
+		}
+		next.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// sameOrigin returns whether the origin, or the origin of the URL, is that of
+// the uri
+func sameOrigin(origin, uri string) bool {
+	o, err := url.Parse(origin)
+	if err != nil || o.Host == "" {
+		return false
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(o.Scheme, u.Scheme) &&
+		strings.EqualFold(o.Hostname(), u.Hostname()) &&
+		originPort(o) == originPort(u)
+}
+
+// originPort is the port of the URL, which is the scheme's default port when
+// the URL does not have one
+func originPort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return "443"
+	case "http":
+		return "80"
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestEnsureSameOrigin(t *testing.T) {
+	spec.Run(t, "ensureSameOrigin", testEnsureSameOrigin, spec.Report(report.Terminal{}))
+}
+
+func testEnsureSameOrigin(t *testing.T, when spec.G, it spec.S) {
+	var (
+		called  bool
+		handler http.Handler
+		w       *httptest.ResponseRecorder
+		req     *http.Request
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		called = false
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+		handler = ensureSameOrigin(next, "https://ignition.example.net:443")
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodPost, "https://ignition.example.net/api/v1/organization/reset", nil)
+	})
+
+	it("calls the next handler when the request comes from ignition", func() {
+		req.Header.Set("Origin", "https://ignition.example.net")
+		handler.ServeHTTP(w, req)
+		Expect(called).To(BeTrue())
+	})
+
+	it("refuses a request from another site", func() {
+		req.Header.Set("Origin", "https://evil.example.com")
+		handler.ServeHTTP(w, req)
+		Expect(called).To(BeFalse())
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+	})
+
+	it("refuses a request from another scheme or port of ignition's domain", func() {
+		for _, origin := range []string{"http://ignition.example.net", "https://ignition.example.net:8443", "null"} {
+			w = httptest.NewRecorder()
+			req.Header.Set("Origin", origin)
+			handler.ServeHTTP(w, req)
+			Expect(called).To(BeFalse(), origin)
+			Expect(w.Code).To(Equal(http.StatusForbidden), origin)
+		}
+	})
+
+	it("checks the referer when the browser does not send an origin", func() {
+		req.Header.Set("Referer", "https://ignition.example.net/")
+		handler.ServeHTTP(w, req)
+		Expect(called).To(BeTrue())
+
+		called = false
+		w = httptest.NewRecorder()
+		req.Header.Set("Referer", "https://evil.example.com/ignition.example.net")
+		handler.ServeHTTP(w, req)
+		Expect(called).To(BeFalse())
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+	})
+
+	it("refuses a request that has neither an origin nor a referer", func() {
+		handler.ServeHTTP(w, req)
+		Expect(called).To(BeFalse())
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+	})
+}
+
+func TestEnsureNotSimple(t *testing.T) {
+	spec.Run(t, "ensureNotSimple", testEnsureNotSimple, spec.Report(report.Terminal{}))
+}
+
+func testEnsureNotSimple(t *testing.T, when spec.G, it spec.S) {
+	var (
+		called  bool
+		handler http.Handler
+		w       *httptest.ResponseRecorder
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		called = false
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+		handler = ensureNotSimple(next)
+		w = httptest.NewRecorder()
+	})
+
+	it("refuses a form", func() {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a=b"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler.ServeHTTP(w, req)
+		Expect(called).To(BeFalse())
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+	})
+
+	it("calls the next handler for a request with an X-Requested-With header", func() {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Requested-With", "XMLHttpRequest")
+		handler.ServeHTTP(w, req)
+		Expect(called).To(BeTrue())
+	})
+
+	it("calls the next handler for a request with a JSON body", func() {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		handler.ServeHTTP(w, req)
+		Expect(called).To(BeTrue())
+	})
+}
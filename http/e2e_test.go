@@ -0,0 +1,354 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/config"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/internal/fakecf"
+	"github.com/pivotalservices/ignition/user/openid/fakeprovider"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestEndToEnd(t *testing.T) {
+	spec.Run(t, "EndToEnd", testEndToEnd, spec.Report(report.Terminal{}))
+}
+
+func testEndToEnd(t *testing.T, when spec.G, it spec.S) {
+	var (
+		provider   *fakeprovider.Provider
+		idp        *httptest.Server
+		foundation *fakecf.Server
+		cf         *httptest.Server
+		emea       *fakecf.Server
+		emeaCF     *httptest.Server
+		dir        string
+		s          *httptest.Server
+		client     *http.Client
+		api        *API
+	)
+
+	env := []string{
+		"IGNITION_CLIENT_ID",
+		"IGNITION_CLIENT_SECRET",
+		"IGNITION_AUTH_URL",
+		"IGNITION_AUTHORIZED_DOMAIN",
+		"IGNITION_SYSTEM_DOMAIN",
+		"IGNITION_UAA_ORIGIN",
+		"IGNITION_API_CLIENT_ID",
+		"IGNITION_API_CLIENT_SECRET",
+		"IGNITION_ORG_COUNT_UPDATE_INTERVAL",
+		"IGNITION_AUTH_FOUNDATION_ATTRIBUTE",
+		"IGNITION_FOUNDATIONS_FILE",
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		var err error
+		provider, err = fakeprovider.New("ignition", "ignition-secret")
+		Expect(err).NotTo(HaveOccurred())
+		idp = httptest.NewServer(provider)
+		provider.Issuer = idp.URL
+
+		os.Setenv("IGNITION_CLIENT_ID", "ignition")
+		os.Setenv("IGNITION_CLIENT_SECRET", "ignition-secret")
+		os.Setenv("IGNITION_AUTH_URL", idp.URL)
+		os.Setenv("IGNITION_AUTHORIZED_DOMAIN", "example.net")
+		os.Setenv("IGNITION_AUTH_FOUNDATION_ATTRIBUTE", "region")
+		authorizer, err := config.NewAuthorizer("ignition-config")
+		Expect(err).NotTo(HaveOccurred())
+
+		foundation = fakecf.New("ignition-api", "ignition-api-secret")
+		foundation.AddOrgQuota("ignition")
+		cf = httptest.NewServer(foundation)
+		os.Setenv("IGNITION_SYSTEM_DOMAIN", cf.URL)
+		os.Setenv("IGNITION_UAA_ORIGIN", "ignition-sso")
+		os.Setenv("IGNITION_API_CLIENT_ID", "ignition-api")
+		os.Setenv("IGNITION_API_CLIENT_SECRET", "ignition-api-secret")
+		os.Setenv("IGNITION_ORG_COUNT_UPDATE_INTERVAL", "1h")
+		deployment, err := config.NewDeployment("ignition-config")
+		Expect(err).NotTo(HaveOccurred())
+		experimenter, err := config.NewExperimenter("ignition-config", deployment.CC, deployment.CC, deployment.CC)
+		Expect(err).NotTo(HaveOccurred())
+
+		// a second foundation for the members of the emea group
+		emea = fakecf.New("ignition-api", "ignition-api-secret")
+		emea.AddOrgQuota("ignition")
+		emeaCF = httptest.NewServer(emea)
+		dir, err = ioutil.TempDir("", "foundations")
+		Expect(err).NotTo(HaveOccurred())
+		file := filepath.Join(dir, "foundations.json")
+		Expect(ioutil.WriteFile(file, []byte(fmt.Sprintf(`[{"name": "emea", "system_domain": "%s", "groups": ["emea"]}]`, emeaCF.URL)), 0600)).To(Succeed())
+		os.Setenv("IGNITION_FOUNDATIONS_FILE", file)
+		foundations, err := config.NewFoundations("ignition-config", deployment, experimenter)
+		Expect(err).NotTo(HaveOccurred())
+
+		api = &API{
+			Ignition: &config.Ignition{
+				Authorizer:   authorizer,
+				Deployment:   deployment,
+				Experimenter: experimenter,
+				Foundations:  foundations,
+				Server: &config.Server{
+					SessionStore: session.NewServerStore(session.NewMemoryBackend(), []byte("test-session-secret")),
+				},
+			},
+		}
+		// the router is created once the server's address is known, so that
+		// the identity provider can redirect back to it
+		s = httptest.NewUnstartedServer(nil)
+		addr := s.Listener.Addr().(*net.TCPAddr)
+		api.Ignition.Server.Scheme = "https"
+		api.Ignition.Server.Domain = addr.IP.String()
+		api.Ignition.Server.Port = addr.Port
+		authorizer.Config.RedirectURL = api.URI() + "/oauth2"
+		s.Config.Handler = api.createRouter()
+		s.StartTLS()
+
+		jar, err := cookiejar.New(nil)
+		Expect(err).NotTo(HaveOccurred())
+		client = s.Client()
+		client.Jar = jar
+		// the SPA is served from /, which is where a successful login ends
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if req.URL.Path == "/" {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		}
+	})
+
+	it.After(func() {
+		s.Close()
+		cf.Close()
+		emeaCF.Close()
+		idp.Close()
+		os.RemoveAll(dir)
+		for _, name := range env {
+			os.Unsetenv(name)
+		}
+	})
+
+	login := func() {
+		resp, err := client.Get(s.URL + "/login")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusFound))
+		Expect(resp.Header.Get("Location")).To(Equal("/"))
+	}
+
+	it("requires the user to log in", func() {
+		resp, err := client.Get(s.URL + "/api/v1/organization")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	getOrgs := func(query string) []cloudfoundry.Organization {
+		resp, err := client.Get(s.URL + "/api/v1/organization" + query)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var orgs []cloudfoundry.Organization
+		Expect(json.NewDecoder(resp.Body).Decode(&orgs)).To(Succeed())
+		return orgs
+	}
+
+	getOrg := func() cloudfoundry.Organization {
+		orgs := getOrgs("")
+		Expect(orgs).To(HaveLen(1))
+		return orgs[0]
+	}
+
+	it("logs the user in and provisions their org", func() {
+		userID := foundation.AddUser("developer", "ignition-sso", "developer@example.net")
+		login()
+
+		org := getOrg()
+		Expect(org.Name).To(Equal("ignition-developer"))
+		Expect(org.Foundation).To(Equal("default"))
+		Expect(emea.Orgs()).To(BeEmpty())
+		orgs := foundation.Orgs()
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].GUID).To(Equal(org.GUID))
+		Expect(foundation.Spaces()).To(HaveLen(1))
+		for _, r := range foundation.Roles() {
+			Expect(r.UserGUID).To(Equal(userID))
+		}
+
+		// the org is found for the user, rather than provisioned again
+		Expect(getOrg().GUID).To(Equal(org.GUID))
+		Expect(foundation.Orgs()).To(HaveLen(1))
+	})
+
+	it("creates the user in the UAA when they do not exist", func() {
+		login()
+		org := getOrg()
+		Expect(org.Name).To(Equal("ignition-developer"))
+
+		users := foundation.Users()
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].UserName).To(Equal("developer"))
+		Expect(users[0].Origin).To(Equal("ignition-sso"))
+	})
+
+	it("provisions the org in the foundation for the user's groups", func() {
+		provider.Users[0].Groups = []string{"emea"}
+		login()
+		org := getOrg()
+		Expect(org.Name).To(Equal("ignition-developer"))
+		Expect(org.Foundation).To(Equal("emea"))
+		Expect(foundation.Orgs()).To(BeEmpty())
+		Expect(emea.Orgs()).To(HaveLen(1))
+
+		// the user is created in the foundation's UAA
+		users := emea.Users()
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].UserName).To(Equal("developer"))
+		for _, r := range emea.Roles() {
+			Expect(r.UserGUID).To(Equal(users[0].ID))
+		}
+	})
+
+	it("lets the user pick a foundation that allows them", func() {
+		login()
+		resp, err := client.Get(s.URL + "/api/v1/organization?foundation=emea")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(emea.Orgs()).To(BeEmpty())
+
+		provider.Users[0].Groups = []string{"emea"}
+		login()
+		orgs := getOrgs("?foundation=default")
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].Foundation).To(Equal("default"))
+		Expect(emea.Orgs()).To(BeEmpty())
+	})
+
+	it("provisions the org in the foundation the user is assigned to", func() {
+		provider.Users[0].Claims = map[string]interface{}{
+			"user_attributes": map[string][]string{"region": {"emea"}},
+		}
+		login()
+		resp, err := client.Get(s.URL + "/api/v1/organization?foundation=default")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+		org := getOrg()
+		Expect(org.Foundation).To(Equal("emea"))
+		Expect(emea.Orgs()).To(HaveLen(1))
+	})
+
+	it("returns the user's orgs in every foundation", func() {
+		userID := emea.AddUser("developer", "ignition-sso", "developer@example.net")
+		f := api.Ignition.Foundations[1]
+		existing, err := f.Deployment.CC.CreateOrg("ignition-developer", f.QuotaID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Deployment.CC.AssociateOrgUser(existing.GUID, userID)).To(Succeed())
+		login()
+
+		// the user has an org, so one is not provisioned
+		org := getOrg()
+		Expect(org.GUID).To(Equal(existing.GUID))
+		Expect(org.Foundation).To(Equal("emea"))
+		Expect(foundation.Orgs()).To(BeEmpty())
+
+		// the user was created in the primary foundation's UAA when they
+		// first asked for their org
+		primary := api.Ignition.Foundations[0]
+		created, err := primary.Deployment.CC.CreateOrg("ignition-developer", primary.QuotaID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(primary.Deployment.CC.AssociateOrgUser(created.GUID, foundation.Users()[0].ID)).To(Succeed())
+		orgs := getOrgs("")
+		Expect(orgs).To(HaveLen(2))
+		Expect(orgs[0].Foundation).To(Equal("default"))
+		Expect(orgs[0].GUID).To(Equal(created.GUID))
+		Expect(orgs[1].Foundation).To(Equal("emea"))
+	})
+
+	reset := func(origin string, header http.Header) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, s.URL+"/api/v1/organization/reset", strings.NewReader("confirm=true"))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", origin)
+		for k := range header {
+			req.Header.Set(k, header.Get(k))
+		}
+		resp, err := client.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		return resp
+	}
+
+	it("refuses to reset the user's org for a cross-origin request", func() {
+		foundation.AddUser("developer", "ignition-sso", "developer@example.net")
+		login()
+		org := getOrg()
+
+		resp := reset("https://evil.example.com", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		resp = reset("https://evil.example.com", http.Header{"X-Requested-With": {"XMLHttpRequest"}})
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		// a form posted from ignition's own domain is refused too
+		resp = reset(s.URL, nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(foundation.Orgs()).To(HaveLen(1))
+		Expect(foundation.Orgs()[0].GUID).To(Equal(org.GUID))
+
+		resp = reset(s.URL, http.Header{"X-Requested-With": {"XMLHttpRequest"}})
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(foundation.Orgs()).To(HaveLen(1))
+		Expect(foundation.Orgs()[0].GUID).NotTo(Equal(org.GUID))
+	})
+
+	it("only signs the user out everywhere for a request from ignition", func() {
+		login()
+		logoutEverywhere := func(origin string) *http.Response {
+			req, err := http.NewRequest(http.MethodPost, s.URL+"/logout/everywhere", nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Origin", origin)
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			return resp
+		}
+
+		resp := logoutEverywhere("https://evil.example.com")
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		getOrg()
+
+		resp = logoutEverywhere(s.URL)
+		Expect(resp.StatusCode).To(Equal(http.StatusFound))
+		resp, err := client.Get(s.URL + "/api/v1/organization")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	it("ends the session when the user logs out", func() {
+		login()
+		resp, err := client.Get(s.URL + "/logout")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		resp, err = client.Get(s.URL + "/api/v1/organization")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pivotalservices/ignition/logging"
+)
+
+// requestIDHeader is the header used to report the request's ID in the
+// response
+const requestIDHeader = "X-Request-Id"
+
+// requestIDHeaders are checked in order for an ID that has already been
+// assigned to the request by the Cloud Foundry router or the client
+var requestIDHeaders = []string{"X-Vcap-Request-Id", requestIDHeader}
+
+// LogRequests adds a logger for the request to its context, that includes
+// the request's ID in every entry, and logs each request when it completes
+func LogRequests(next http.Handler, l *logging.Logger) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		id := requestID(req)
+		w.Header().Set(requestIDHeader, id)
+		rl := l.With("request_id", id)
+		rw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rw, req.WithContext(logging.NewContext(req.Context(), rl)))
+		rl.Info("request",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", rw.status,
+			"duration_ms", time.Since(start).Seconds()*1000,
+			"remote_addr", req.RemoteAddr,
+			"user_agent", req.UserAgent())
+	}
+	return http.HandlerFunc(fn)
+}
+
+func requestID(req *http.Request) string {
+	for _, h := range requestIDHeaders {
+		if id := strings.TrimSpace(req.Header.Get(h)); id != "" {
+			return id
+		}
+	}
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// statusResponseWriter records the status code written to the response
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
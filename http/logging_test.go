@@ -0,0 +1,87 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestLogRequests(t *testing.T) {
+	spec.Run(t, "LogRequests", testLogRequests, spec.Report(report.Terminal{}))
+}
+
+func testLogRequests(t *testing.T, when spec.G, it spec.S) {
+	var (
+		buf     *bytes.Buffer
+		handler http.Handler
+		w       *httptest.ResponseRecorder
+		r       *http.Request
+	)
+
+	entries := func() []map[string]interface{} {
+		var result []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			entry := make(map[string]interface{})
+			Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed())
+			result = append(result, entry)
+		}
+		return result
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		buf = &bytes.Buffer{}
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/api/v1/organization", nil)
+		next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			logging.AddField(req.Context(), "account_name", "testuser")
+			logging.FromContext(req.Context()).Info("test message")
+			w.WriteHeader(http.StatusAccepted)
+		})
+		handler = LogRequests(next, logging.New(buf, logging.InfoLevel))
+	})
+
+	it("uses the Cloud Foundry router's request id", func() {
+		r.Header.Set("X-Vcap-Request-Id", "test-vcap-request-id")
+		r.Header.Set("X-Request-Id", "test-request-id")
+		handler.ServeHTTP(w, r)
+		Expect(w.Header().Get("X-Request-Id")).To(Equal("test-vcap-request-id"))
+		for _, e := range entries() {
+			Expect(e).To(HaveKeyWithValue("request_id", "test-vcap-request-id"))
+		}
+	})
+
+	it("uses the client's request id", func() {
+		r.Header.Set("X-Request-Id", "test-request-id")
+		handler.ServeHTTP(w, r)
+		Expect(w.Header().Get("X-Request-Id")).To(Equal("test-request-id"))
+		Expect(entries()[0]).To(HaveKeyWithValue("request_id", "test-request-id"))
+	})
+
+	it("generates a request id", func() {
+		handler.ServeHTTP(w, r)
+		id := w.Header().Get("X-Request-Id")
+		Expect(id).NotTo(BeEmpty())
+		Expect(entries()[0]).To(HaveKeyWithValue("request_id", id))
+	})
+
+	it("logs the request with the fields added while handling it", func() {
+		handler.ServeHTTP(w, r)
+		e := entries()
+		Expect(e).To(HaveLen(2))
+		Expect(e[0]).To(HaveKeyWithValue("msg", "test message"))
+		Expect(e[1]).To(HaveKeyWithValue("msg", "request"))
+		Expect(e[1]).To(HaveKeyWithValue("method", "GET"))
+		Expect(e[1]).To(HaveKeyWithValue("path", "/api/v1/organization"))
+		Expect(e[1]).To(HaveKeyWithValue("status", BeNumerically("==", http.StatusAccepted)))
+		Expect(e[1]).To(HaveKeyWithValue("account_name", "testuser"))
+	})
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+	dgoauth2 "github.com/dghubble/gologin/oauth2"
+	"github.com/pivotalservices/ignition/user/openid"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// loginCookieName is the cookie that binds the PKCE code verifier and the
+// OpenID Connect nonce for a login to the browser that started it
+const loginCookieName = "ignition-login"
+
+// LoginHandler sends the user to the identity provider to log in. The
+// authorization request uses PKCE (S256) and a nonce, which are kept in a
+// short-lived cookie until the provider sends the user back to the
+// CallbackHandler.
+func LoginHandler(config *oauth2.Config, cookieConfig gologin.CookieConfig, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		state, err := dgoauth2.StateFromContext(ctx)
+		if err != nil {
+			failure.ServeHTTP(w, req.WithContext(gologin.WithError(ctx, err)))
+			return
+		}
+		verifier, err := randomToken()
+		if err != nil {
+			failure.ServeHTTP(w, req.WithContext(gologin.WithError(ctx, err)))
+			return
+		}
+		nonce, err := randomToken()
+		if err != nil {
+			failure.ServeHTTP(w, req.WithContext(gologin.WithError(ctx, err)))
+			return
+		}
+		http.SetCookie(w, loginCookie(cookieConfig, verifier+"."+nonce, cookieConfig.MaxAge))
+		authURL := config.AuthCodeURL(state,
+			oauth2.SetAuthURLParam("code_challenge", codeChallenge(verifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+			oauth2.SetAuthURLParam("nonce", nonce))
+		http.Redirect(w, req, authURL, http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// exchangeHandler checks the state returned by the identity provider, and
+// exchanges the authorization code for a token using the PKCE code verifier
+// for the login. The token and the login's nonce are added to the ctx.
+func exchangeHandler(config *oauth2.Config, cookieConfig gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		fail := func(err error) {
+			failure.ServeHTTP(w, req.WithContext(gologin.WithError(ctx, err)))
+		}
+		req.ParseForm()
+		code, state := req.Form.Get("code"), req.Form.Get("state")
+		if code == "" || state == "" {
+			fail(errors.New("oauth2: request missing code or state"))
+			return
+		}
+		ownerState, err := dgoauth2.StateFromContext(ctx)
+		if err != nil || state != ownerState {
+			fail(errors.New("oauth2: invalid OAuth2 state parameter"))
+			return
+		}
+		cookie, err := req.Cookie(loginCookieName)
+		if err != nil {
+			fail(errors.New("oauth2: request missing login cookie"))
+			return
+		}
+		http.SetCookie(w, loginCookie(cookieConfig, "", -1))
+		parts := strings.Split(cookie.Value, ".")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			fail(errors.New("oauth2: invalid login cookie"))
+			return
+		}
+		token, err := config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", parts[0]))
+		if err != nil {
+			fail(err)
+			return
+		}
+		ctx = dgoauth2.WithToken(ctx, token)
+		ctx = openid.WithNonce(ctx, parts[1])
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// codeChallenge is the S256 PKCE code challenge for the code verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomToken returns a random, URL safe string with 256 bits of entropy,
+// suitable for use as a PKCE code verifier or a nonce
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "could not generate random token")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// loginCookie is the login cookie with the value; a negative maxAge deletes
+// the cookie
+func loginCookie(config gologin.CookieConfig, value string, maxAge int) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     loginCookieName,
+		Value:    value,
+		Domain:   config.Domain,
+		Path:     config.Path,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   config.Secure,
+	}
+	if maxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(maxAge) * time.Second)
+	} else if maxAge < 0 {
+		cookie.Expires = time.Unix(1, 0)
+	}
+	return cookie
+}
@@ -4,22 +4,40 @@ import (
 	_ "expvar" // metrics
 	"fmt"
 	"html/template"
-	"log"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/audit"
 	"github.com/pivotalservices/ignition/config"
 	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pivotalservices/ignition/metrics"
 )
 
+// orgProvisioningWait is how long a request for the user's org waits for the
+// org to be provisioned before responding that provisioning is in progress
+const orgProvisioningWait = 3 * time.Second
+
+// recentAuditEvents is the number of audit events that admins can query
+const recentAuditEvents = 1000
+
 // API is the Ignition web app
 type API struct {
 	Ignition *config.Ignition
+
+	// Locker, when set, coordinates org provisioning with other ignition
+	// instances in addition to the lock held within this process; when it is
+	// not set and Redis is configured, the lock is held in Redis
+	Locker api.Locker
+
+	// reapers are the Reapers of idle orgs, by the name of their foundation
+	reapers map[string]*api.Reaper
 }
 
 // URI is the combination of the scheme, domain, and port
@@ -34,36 +52,168 @@ func (a *API) URI() string {
 // Run starts a server listening on the given serveURI
 func (a *API) Run() error {
 	a.Ignition.Authorizer.Config.RedirectURL = fmt.Sprintf("%s%s", a.URI(), "/oauth2")
+	err := a.startAudit()
+	if err != nil {
+		return err
+	}
+	a.startReaper()
 	r := a.createRouter()
-	return http.ListenAndServe(fmt.Sprintf(":%v", a.Ignition.Server.ServePort), handlers.LoggingHandler(os.Stdout, handlers.CORS()(r)))
+	return http.ListenAndServe(fmt.Sprintf(":%v", a.Ignition.Server.ServePort), LogRequests(handlers.CORS()(r), logging.Default()))
+}
+
+// startAudit records audit events to the configured file and syslog server,
+// keeping the most recent events in memory so that admins can query them
+func (a *API) startAudit() error {
+	s := a.Ignition.Server
+	var sinks []audit.Sink
+	if s.AuditFile != "" {
+		f, err := audit.NewFileSink(s.AuditFile)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, f)
+	}
+	if s.AuditSyslog != "" {
+		l, err := audit.NewSyslogSink(s.AuditSyslog)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, l)
+	}
+	audit.SetDefault(audit.NewLog(recentAuditEvents, sinks...))
+	return nil
+}
+
+// startReaper starts the background reaping of idle orgs in each foundation
+// when an org TTL has been configured
+func (a *API) startReaper() {
+	e := a.Ignition.Experimenter
+	a.reapers = map[string]*api.Reaper{}
+	if e.OrgTTL <= 0 {
+		return
+	}
+	var warnings api.Warnings = &api.MemoryWarnings{}
+	if a.Ignition.Server.Redis != nil {
+		warnings = &api.RedisWarnings{Pool: a.Ignition.Server.Redis}
+	} else {
+		logging.Default().Warn("the warnings sent for idle orgs are kept in memory, so each instance of ignition sends its own, and they are sent again when it restarts; set session_backend to redis to share them")
+	}
+	for _, f := range a.foundations() {
+		if f.QuotaFallback {
+			// the default quota is not ignition's alone, so its idle orgs are
+			// not all ignition's to delete
+			logging.Default().Error("not reaping idle orgs, because ignition's quota was not found and orgs are given the default quota", "foundation", f.Name)
+			continue
+		}
+		reaper := &api.Reaper{
+			AppsURL:   f.AppsURL,
+			OrgPrefix: e.OrgPrefix,
+			QuotaID:   f.QuotaID,
+			TTL:       e.OrgTTL,
+			Warning:   e.OrgExpiryWarning,
+			DryRun:    e.OrgReapDryRun,
+			API:       f.CC,
+			Warnings:  warnings,
+		}
+		err := api.StartBackgroundReaper(reaper, e.OrgReapInterval)
+		if err != nil {
+			logging.Default().Error("not reaping idle orgs", "foundation", f.Name, "error", err)
+			continue
+		}
+		logging.Default().Info("reaping idle orgs", "foundation", f.Name, "ttl", e.OrgTTL, "interval", e.OrgReapInterval, "dry_run", e.OrgReapDryRun)
+		a.reapers[f.Name] = reaper
+	}
+}
+
+// foundations returns the foundations that orgs are provisioned in; when no
+// foundations have been configured, that is only the Deployment
+func (a *API) foundations() []api.Foundation {
+	if len(a.Ignition.Foundations) == 0 {
+		d := a.Ignition.Deployment
+		e := a.Ignition.Experimenter
+		return []api.Foundation{{
+			Name:          "default",
+			AppsURL:       d.AppsURL,
+			QuotaID:       e.QuotaID,
+			ISOSegmentID:  e.ISOSegmentID,
+			UAAOrigin:     d.UAAOrigin,
+			UAA:           d.UAA,
+			CC:            d.CC,
+			QuotaFallback: e.QuotaFallback,
+		}}
+	}
+	foundations := make([]api.Foundation, len(a.Ignition.Foundations))
+	for i, f := range a.Ignition.Foundations {
+		foundations[i] = api.Foundation{
+			Name:          f.Name,
+			AppsURL:       f.Deployment.AppsURL,
+			QuotaID:       f.QuotaID,
+			ISOSegmentID:  f.ISOSegmentID,
+			UAAOrigin:     f.Deployment.UAAOrigin,
+			UAA:           f.Deployment.UAA,
+			CC:            f.Deployment.CC,
+			QuotaFallback: f.QuotaFallback,
+			Groups:        f.Groups,
+		}
+	}
+	return foundations
 }
 
 func (a *API) createRouter() *mux.Router {
 	r := mux.NewRouter()
 	r.PathPrefix("/assets/").Handler(http.StripPrefix("/assets/", http.FileServer(http.Dir(path.Join(a.Ignition.Server.WebRoot, "assets")+string(os.PathSeparator))))).Name("assets")
-	r.Handle("/api/v1/profile", ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, ensureHTTPS(session.PopulateContext(Authenticate(api.ProfileHandler()), a.Ignition.Server.SessionStore))))
+	r.Handle("/api/v1/profile", ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, ensureHTTPS(session.PopulateContext(Authenticate(api.ProfileHandler()), a.Ignition.Server.SessionStore, a.refresher()))))
+	foundations := a.foundations()
 	infoHandler := api.InfoHandler(
 		a.Ignition.Server.CompanyName,
 		a.Ignition.Experimenter.SpaceName,
-		a.Ignition.Experimenter.QuotaID,
 		a.Ignition.Server.CollectAnalytics,
 		a.Ignition.Experimenter.OrgCountUpdateInterval,
-		a.Ignition.Deployment.CC)
-	r.Handle("/api/v1/info", ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, Secure(infoHandler, a.Ignition.Authorizer.Domain, a.Ignition.Server.SessionStore)))
+		foundations)
+	r.Handle("/api/v1/info", ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, Secure(infoHandler, a.Ignition.Authorizer.Policy(), a.Ignition.Server.SessionStore, a.refresher())))
+
+	locker := api.Lockers{&api.LocalLocker{}}
+	switch {
+	case a.Locker != nil:
+		locker = append(locker, a.Locker)
+	case a.Ignition.Server.Redis != nil:
+		locker = append(locker, &api.RedisLocker{Pool: a.Ignition.Server.Redis})
+	}
+	jobs := &api.Jobs{Wait: orgProvisioningWait}
+	if a.Ignition.Server.Redis != nil {
+		jobs.Store = &api.RedisJobStore{Pool: a.Ignition.Server.Redis}
+	}
 
 	orgHandler := api.OrganizationHandler(
-		a.Ignition.Deployment.AppsURL,
 		a.Ignition.Experimenter.OrgPrefix,
-		a.Ignition.Experimenter.QuotaID,
-		a.Ignition.Experimenter.ISOSegmentID,
-		a.Ignition.Experimenter.SpaceName,
-		a.Ignition.Deployment.CC)
-	orgHandler = ensureUser(orgHandler, a.Ignition.Deployment.UAA, a.Ignition.Deployment.UAAOrigin, a.Ignition.Server.SessionStore)
-	orgHandler = Secure(orgHandler, a.Ignition.Authorizer.Domain, a.Ignition.Server.SessionStore)
+		a.Ignition.Experimenter.Template,
+		locker,
+		jobs,
+		foundations)
+	orgHandler = ensureUser(orgHandler, foundations, a.Ignition.Server.SessionStore)
+	orgHandler = Secure(orgHandler, a.Ignition.Authorizer.Policy(), a.Ignition.Server.SessionStore, a.refresher())
 	r.Handle("/api/v1/organization", ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, orgHandler))
 
+	statusHandler := api.OrganizationStatusHandler(a.Ignition.Experimenter.OrgPrefix, jobs, foundations)
+	statusHandler = Secure(statusHandler, a.Ignition.Authorizer.Policy(), a.Ignition.Server.SessionStore, a.refresher())
+	r.Handle("/api/v1/organization/status", ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, statusHandler)).Methods(http.MethodGet)
+
+	resetHandler := api.ResetOrganizationHandler(
+		a.Ignition.Experimenter.OrgPrefix,
+		a.Ignition.Experimenter.Template,
+		locker,
+		foundations)
+	resetHandler = ensureUser(resetHandler, foundations, a.Ignition.Server.SessionStore)
+	resetHandler = Secure(resetHandler, a.Ignition.Authorizer.Policy(), a.Ignition.Server.SessionStore, a.refresher())
+	// resetting deletes everything in the user's org, so it is only done for
+	// ignition's own pages, and never for a page on another site
+	resetHandler = ensureSameOrigin(ensureNotSimple(resetHandler), a.URI())
+	r.Handle("/api/v1/organization/reset", ensureHTTPClient(a.Ignition.Authorizer.SkipTLSValidation, resetHandler)).Methods(http.MethodPost)
+
+	a.handleAdmin(r, locker)
 	a.handleAuth(r)
 	r.Handle("/debug/vars", http.DefaultServeMux)
+	r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet).Name("metrics")
 	var t *template.Template
 
 	// If the API can't handle the route, let the SPA handle it
@@ -73,7 +223,7 @@ func (a *API) createRouter() *mux.Router {
 			t, err = template.ParseFiles(filepath.Join(a.Ignition.Server.WebRoot, "index.html"))
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
-				log.Println(err)
+				logging.FromContext(req.Context()).Error("could not parse index.html", "error", err)
 				return
 			}
 		}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"net/http"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -43,7 +44,18 @@ func testAPI(t *testing.T, when spec.G, it spec.S) {
 		Expect(r).NotTo(BeNil())
 		assets := r.GetRoute("assets")
 		Expect(assets).NotTo(BeNil())
+		Expect(r.GetRoute("metrics")).NotTo(BeNil())
+		Expect(r.GetRoute("audit")).NotTo(BeNil())
+		for _, name := range []string{"admin-config", "admin-orgs", "admin-delete-org", "admin-reset-org", "admin-user-org", "admin-reaper", "admin-revoke-sessions", "logout", "logout-everywhere", "backchannel-logout"} {
+			Expect(r.GetRoute(name)).NotTo(BeNil(), name)
+		}
 		nonexistent := r.GetRoute("nonexistent")
 		Expect(nonexistent).To(BeNil())
 	})
+
+	it("only signs users out everywhere when they post the request", func() {
+		methods, err := api.createRouter().GetRoute("logout-everywhere").GetMethods()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(methods).To(Equal([]string{http.MethodPost}))
+	})
 }
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"net/http"
+
+	"github.com/dghubble/sessions"
+	"github.com/gorilla/securecookie"
+)
+
+// CookieStore is a sessions.Store that keeps the values of each session in
+// the signed session cookie. It encodes sessions as sessions.CookieStore does,
+// so that it reads the cookies that sessions.CookieStore issued, but the
+// cookies it sets are SameSite, like those of a ServerStore.
+type CookieStore struct {
+	Codecs []securecookie.Codec
+	Config *sessions.Config
+}
+
+// NewCookieStore returns a CookieStore that signs sessions with the key pairs,
+// as described by securecookie.CodecsFromPairs
+func NewCookieStore(keyPairs ...[]byte) *CookieStore {
+	return &CookieStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Config: &sessions.Config{
+			Path:     "/",
+			MaxAge:   defaultMaxAge,
+			HTTPOnly: true,
+		},
+	}
+}
+
+// New returns a new, unsaved session with the given name
+func (s *CookieStore) New(name string) *sessions.Session {
+	session := sessions.NewSession(s, name)
+	config := *s.Config
+	session.Config = &config
+	return session
+}
+
+// Get returns the named session for the request, decoded from its cookie
+func (s *CookieStore) Get(req *http.Request, name string) (*sessions.Session, error) {
+	cookie, err := req.Cookie(name)
+	if err != nil {
+		return nil, err
+	}
+	session := s.New(name)
+	err = securecookie.DecodeMulti(name, cookie.Value, &session.Values, s.Codecs...)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Save encodes the session's values in the session cookie
+func (s *CookieStore) Save(w http.ResponseWriter, session *sessions.Session) error {
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, newCookie(session.Name(), encoded, session.Config))
+	return nil
+}
+
+// Destroy deletes the session cookie
+func (s *CookieStore) Destroy(w http.ResponseWriter, name string) {
+	http.SetCookie(w, newCookie(name, "", &sessions.Config{Path: s.Config.Path, Domain: s.Config.Domain, MaxAge: -1}))
+}
@@ -0,0 +1,75 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/sessions"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestCookieStore(t *testing.T) {
+	spec.Run(t, "CookieStore", testCookieStore, spec.Report(report.Terminal{}))
+}
+
+func testCookieStore(t *testing.T, when spec.G, it spec.S) {
+	var store *session.CookieStore
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		store = session.NewCookieStore([]byte("test-secret"))
+	})
+
+	it("keeps the session's values in a SameSite cookie", func() {
+		s := store.New("ignition")
+		s.Values["profile"] = "test-profile"
+		w := httptest.NewRecorder()
+		Expect(s.Save(w)).To(Succeed())
+		cookies := w.Result().Cookies()
+		Expect(cookies).To(HaveLen(1))
+		Expect(cookies[0].HttpOnly).To(BeTrue())
+		Expect(cookies[0].SameSite).To(Equal(http.SameSiteLaxMode))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+		loaded, err := store.Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Values).To(HaveKeyWithValue("profile", "test-profile"))
+	})
+
+	it("reads the sessions that sessions.CookieStore issued", func() {
+		s := sessions.NewCookieStore([]byte("test-secret")).New("ignition")
+		s.Values["profile"] = "test-profile"
+		w := httptest.NewRecorder()
+		Expect(s.Save(w)).To(Succeed())
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(w.Result().Cookies()[0])
+		loaded, err := store.Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Values).To(HaveKeyWithValue("profile", "test-profile"))
+	})
+
+	it("errors when the cookie was not signed with its keys", func() {
+		s := session.NewCookieStore([]byte("other-secret")).New("ignition")
+		w := httptest.NewRecorder()
+		Expect(s.Save(w)).To(Succeed())
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(w.Result().Cookies()[0])
+		_, err := store.Get(req, "ignition")
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("deletes the session cookie when the session is destroyed", func() {
+		w := httptest.NewRecorder()
+		store.Destroy(w, "ignition")
+		cookies := w.Result().Cookies()
+		Expect(cookies).To(HaveLen(1))
+		Expect(cookies[0].MaxAge).To(Equal(-1))
+	})
+}
@@ -0,0 +1,36 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"strings"
+)
+
+// KeyPairs derives the key pairs used to sign and encrypt session cookies from
+// the session secrets, for use with sessions.NewCookieStore or NewServerStore.
+// Cookies are always signed and encrypted with the first secret; the others
+// are previous secrets that are only used to read existing cookies, so that
+// the secret can be rotated without logging everyone out.
+//
+// Cookies that were signed, but not encrypted, with any of the secrets can
+// still be read, so that sessions issued before cookies were encrypted remain
+// valid until they expire.
+func KeyPairs(secrets ...string) [][]byte {
+	var encrypted, signed [][]byte
+	for _, secret := range secrets {
+		if strings.TrimSpace(secret) == "" {
+			continue
+		}
+		encrypted = append(encrypted, deriveKey(secret, "hash"), deriveKey(secret, "block"))
+		signed = append(signed, []byte(secret), nil)
+	}
+	return append(encrypted, signed...)
+}
+
+// deriveKey derives a 32 byte key for the purpose from the secret, so that
+// the keys used to sign and encrypt cookies are independent of each other
+func deriveKey(secret string, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("ignition-session-" + purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,80 @@
+package session_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/sessions"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestKeyPairs(t *testing.T) {
+	spec.Run(t, "KeyPairs", testKeyPairs, spec.Report(report.Terminal{}))
+}
+
+func testKeyPairs(t *testing.T, when spec.G, it spec.S) {
+	// issue saves a session with a profile in the store, returning a request
+	// that carries its cookie
+	issue := func(store sessions.Store) *http.Request {
+		s := store.New("ignition")
+		s.Values["profile"] = "test-profile"
+		w := httptest.NewRecorder()
+		Expect(s.Save(w)).To(Succeed())
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range w.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		return req
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("derives an encrypted key pair and a signed key pair for each secret", func() {
+		pairs := session.KeyPairs("current-secret", " ", "previous-secret")
+		Expect(pairs).To(HaveLen(8))
+		Expect(pairs[0]).To(HaveLen(32))
+		Expect(pairs[1]).To(HaveLen(32))
+		Expect(pairs[0]).NotTo(Equal(pairs[1]))
+		Expect(pairs[0]).NotTo(Equal(pairs[2]))
+		Expect(pairs[4]).To(Equal([]byte("current-secret")))
+		Expect(pairs[5]).To(BeNil())
+	})
+
+	it("encrypts the session cookie", func() {
+		req := issue(sessions.NewCookieStore(session.KeyPairs("test-secret")...))
+		cookie, err := req.Cookie("ignition")
+		Expect(err).NotTo(HaveOccurred())
+		decoded, err := base64.URLEncoding.DecodeString(cookie.Value)
+		Expect(err).NotTo(HaveOccurred())
+		for _, part := range strings.Split(string(decoded), "|") {
+			raw, _ := base64.URLEncoding.DecodeString(part)
+			Expect(string(raw)).NotTo(ContainSubstring("test-profile"))
+		}
+	})
+
+	it("reads cookies issued with a previous secret", func() {
+		req := issue(sessions.NewCookieStore(session.KeyPairs("previous-secret")...))
+		store := sessions.NewCookieStore(session.KeyPairs("current-secret", "previous-secret")...)
+		s, err := store.Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Values).To(HaveKeyWithValue("profile", "test-profile"))
+
+		_, err = sessions.NewCookieStore(session.KeyPairs("current-secret")...).Get(req, "ignition")
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("reads cookies that were signed but not encrypted", func() {
+		req := issue(sessions.NewCookieStore([]byte("test-secret"), nil))
+		s, err := sessions.NewCookieStore(session.KeyPairs("test-secret")...).Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Values).To(HaveKeyWithValue("profile", "test-profile"))
+	})
+}
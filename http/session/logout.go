@@ -0,0 +1,83 @@
+package session
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/sessions"
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pivotalservices/ignition/user/openid"
+)
+
+// EndSessionHandler logs a user out and deletes their session, like
+// LogoutHandler, and then sends them to the identity provider's end session
+// endpoint, so that they are logged out of the identity provider too and are
+// not signed straight back in. The provider sends them back to the
+// postLogoutRedirectURI, which must be registered with the provider.
+func EndSessionHandler(s sessions.Store, endSessionURL, clientID, postLogoutRedirectURI string) http.Handler {
+	u, err := url.Parse(endSessionURL)
+	if err != nil || endSessionURL == "" {
+		if err != nil {
+			logging.Default().Error("could not parse end session endpoint, users will only be logged out of ignition", "url", endSessionURL, "error", err)
+		}
+		return LogoutHandler(s)
+	}
+	q := u.Query()
+	q.Set("client_id", clientID)
+	if postLogoutRedirectURI != "" {
+		q.Set("post_logout_redirect_uri", postLogoutRedirectURI)
+	}
+	u.RawQuery = q.Encode()
+	redirect := u.String()
+
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		logout(w, req, s)
+		http.Redirect(w, req, redirect, http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// BackChannelLogoutHandler handles OpenID Connect back-channel logout
+// requests from the identity provider, revoking every session for the subject
+// of the verified logout token. Sessions stored in cookies cannot be revoked.
+func BackChannelLogoutHandler(s sessions.Store, v openid.Verifier) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		logger := logging.FromContext(req.Context())
+		r, ok := s.(Revoker)
+		if !ok || v == nil {
+			http.Error(w, "sessions are stored in cookies and cannot be revoked", http.StatusNotImplemented)
+			return
+		}
+		logoutToken := req.PostFormValue("logout_token")
+		if logoutToken == "" {
+			http.Error(w, "logout_token is required", http.StatusBadRequest)
+			return
+		}
+		claims, err := v.Verify(req.Context(), logoutToken)
+		if err != nil {
+			logger.Warn("could not verify logout token", "error", err)
+			http.Error(w, "logout_token could not be verified", http.StatusBadRequest)
+			return
+		}
+		if !claims.IsLogoutToken() {
+			http.Error(w, "logout_token is not a logout token", http.StatusBadRequest)
+			return
+		}
+		if claims.Sub == "" {
+			http.Error(w, "logout_token must have a sub claim", http.StatusBadRequest)
+			return
+		}
+		n, err := r.RevokeSubject(claims.Sub)
+		if err != nil {
+			logger.Error("could not revoke sessions", "sub", claims.Sub, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		audit.Record(audit.Event{Type: audit.EventSessionsRevoked, Reason: fmt.Sprintf("back-channel logout of subject [%s] ended %d sessions", claims.Sub, n)})
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.HandlerFunc(fn)
+}
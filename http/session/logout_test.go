@@ -0,0 +1,130 @@
+package session_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/sessions"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/http/session/sessionfakes"
+	"github.com/pivotalservices/ignition/user/openid"
+	"github.com/pivotalservices/ignition/user/openid/openidfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestEndSessionHandler(t *testing.T) {
+	spec.Run(t, "EndSessionHandler", testEndSessionHandler, spec.Report(report.Terminal{}))
+}
+
+func testEndSessionHandler(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("destroys the session and sends the user to the end session endpoint", func() {
+		s := &sessionfakes.FakeStore{}
+		handler := session.EndSessionHandler(s, "https://login.example.net/logout?tenant=test", "test-client", "https://ignition.example.net/")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logout", nil))
+		Expect(s.DestroyCallCount()).To(Equal(1))
+		Expect(w.Code).To(Equal(http.StatusFound))
+		location, err := url.Parse(w.Header().Get("Location"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(location.Host).To(Equal("login.example.net"))
+		Expect(location.Path).To(Equal("/logout"))
+		Expect(location.Query().Get("tenant")).To(Equal("test"))
+		Expect(location.Query().Get("client_id")).To(Equal("test-client"))
+		Expect(location.Query().Get("post_logout_redirect_uri")).To(Equal("https://ignition.example.net/"))
+	})
+
+	it("only logs the user out of ignition without an end session endpoint", func() {
+		s := &sessionfakes.FakeStore{}
+		w := httptest.NewRecorder()
+		session.EndSessionHandler(s, "", "test-client", "https://ignition.example.net/").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logout", nil))
+		Expect(s.DestroyCallCount()).To(Equal(1))
+		Expect(w.Header().Get("Location")).To(Equal("/"))
+	})
+}
+
+func TestBackChannelLogoutHandler(t *testing.T) {
+	spec.Run(t, "BackChannelLogoutHandler", testBackChannelLogoutHandler, spec.Report(report.Terminal{}))
+}
+
+func testBackChannelLogoutHandler(t *testing.T, when spec.G, it spec.S) {
+	var (
+		store    *session.ServerStore
+		verifier *openidfakes.FakeVerifier
+		req      *http.Request
+		w        *httptest.ResponseRecorder
+	)
+
+	logoutRequest := func(token string) *http.Request {
+		form := url.Values{"logout_token": []string{token}}
+		r := httptest.NewRequest(http.MethodPost, "/logout/backchannel", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		store = session.NewServerStore(session.NewMemoryBackend(), []byte("test-secret"))
+		s := store.New("ignition")
+		s.Values["sub"] = "test-subject"
+		w = httptest.NewRecorder()
+		Expect(s.Save(w)).To(Succeed())
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(w.Result().Cookies()[0])
+
+		verifier = &openidfakes.FakeVerifier{}
+		verifier.VerifyReturns(&openid.Claims{
+			Sub:    "test-subject",
+			Events: map[string]json.RawMessage{openid.BackChannelLogoutEvent: json.RawMessage("{}")},
+		}, nil)
+		w = httptest.NewRecorder()
+	})
+
+	it("revokes the sessions for the subject of the logout token", func() {
+		session.BackChannelLogoutHandler(store, verifier).ServeHTTP(w, logoutRequest("test-logout-token"))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Cache-Control")).To(Equal("no-store"))
+		_, token := verifier.VerifyArgsForCall(0)
+		Expect(token).To(Equal("test-logout-token"))
+		_, err := store.Get(req, "ignition")
+		Expect(err).To(Equal(session.ErrSessionNotFound))
+	})
+
+	it("is a bad request without a logout token", func() {
+		session.BackChannelLogoutHandler(store, verifier).ServeHTTP(w, logoutRequest(""))
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(verifier.VerifyCallCount()).To(Equal(0))
+	})
+
+	it("is a bad request when the logout token cannot be verified", func() {
+		verifier.VerifyReturns(nil, errors.New("test error"))
+		session.BackChannelLogoutHandler(store, verifier).ServeHTTP(w, logoutRequest("test-logout-token"))
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		_, err := store.Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it("is a bad request when the token is not a logout token", func() {
+		verifier.VerifyReturns(&openid.Claims{Sub: "test-subject", Nonce: "test-nonce"}, nil)
+		session.BackChannelLogoutHandler(store, verifier).ServeHTTP(w, logoutRequest("test-id-token"))
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		_, err := store.Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it("is not implemented when sessions are stored in cookies", func() {
+		cookies := sessions.NewCookieStore([]byte("test-secret"), nil)
+		session.BackChannelLogoutHandler(cookies, verifier).ServeHTTP(w, logoutRequest("test-logout-token"))
+		Expect(w.Code).To(Equal(http.StatusNotImplemented))
+	})
+}
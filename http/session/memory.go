@@ -0,0 +1,110 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+type memorySession struct {
+	owners  []string
+	data    []byte
+	expires time.Time
+}
+
+// MemoryBackend is a Backend that keeps sessions in memory. Sessions are lost
+// when ignition restarts, and are not shared between instances of ignition.
+type MemoryBackend struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	owners   map[string]map[string]bool
+}
+
+// NewMemoryBackend returns an empty MemoryBackend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		sessions: make(map[string]memorySession),
+		owners:   make(map[string]map[string]bool),
+	}
+}
+
+// Get returns the session, or ErrSessionNotFound if it does not exist or has
+// expired
+func (m *MemoryBackend) Get(id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if !s.expires.IsZero() && time.Now().After(s.expires) {
+		m.delete(id)
+		return nil, ErrSessionNotFound
+	}
+	return s.data, nil
+}
+
+// Set stores the session until the ttl passes; a session with a ttl of zero
+// does not expire
+func (m *MemoryBackend) Set(id string, owners []string, data []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge()
+	m.delete(id)
+	s := memorySession{owners: owners, data: data}
+	if ttl > 0 {
+		s.expires = time.Now().Add(ttl)
+	}
+	m.sessions[id] = s
+	for _, owner := range owners {
+		if m.owners[owner] == nil {
+			m.owners[owner] = make(map[string]bool)
+		}
+		m.owners[owner][id] = true
+	}
+	return nil
+}
+
+// Delete deletes the session
+func (m *MemoryBackend) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delete(id)
+	return nil
+}
+
+// DeleteOwner deletes each of the owner's sessions
+func (m *MemoryBackend) DeleteOwner(owner string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge()
+	n := 0
+	for id := range m.owners[owner] {
+		m.delete(id)
+		n++
+	}
+	return n, nil
+}
+
+func (m *MemoryBackend) delete(id string) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return
+	}
+	delete(m.sessions, id)
+	for _, owner := range s.owners {
+		delete(m.owners[owner], id)
+		if len(m.owners[owner]) == 0 {
+			delete(m.owners, owner)
+		}
+	}
+}
+
+// purge deletes the sessions that have expired
+func (m *MemoryBackend) purge() {
+	now := time.Now()
+	for id, s := range m.sessions {
+		if !s.expires.IsZero() && now.After(s.expires) {
+			m.delete(id)
+		}
+	}
+}
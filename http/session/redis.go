@@ -0,0 +1,145 @@
+package session
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// The prefixes of the keys that a RedisBackend uses for sessions, and for the
+// set of session IDs that belong to each owner
+const (
+	redisSessionPrefix = "ignition:session:"
+	redisOwnerPrefix   = "ignition:owner:"
+)
+
+// RedisBackend is a Backend that keeps sessions in Redis, or any server that
+// speaks the Redis protocol, so that they are shared between instances of
+// ignition
+type RedisBackend struct {
+	Pool *redis.Pool
+}
+
+// NewRedisBackend returns a RedisBackend that connects to the server at the
+// URL (e.g. redis://:password@redis.example.net:6379/0)
+func NewRedisBackend(rawURL string) (*RedisBackend, error) {
+	// check the URL before it is used to dial connections for the pool
+	_, err := redisURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisBackend{
+		Pool: &redis.Pool{
+			MaxIdle:     3,
+			IdleTimeout: 4 * time.Minute,
+			Dial: func() (redis.Conn, error) {
+				return redis.DialURL(rawURL,
+					redis.DialConnectTimeout(5*time.Second),
+					redis.DialReadTimeout(5*time.Second),
+					redis.DialWriteTimeout(5*time.Second))
+			},
+			TestOnBorrow: func(c redis.Conn, t time.Time) error {
+				if time.Since(t) < time.Minute {
+					return nil
+				}
+				_, err := c.Do("PING")
+				return err
+			},
+		},
+	}, nil
+}
+
+// Get returns the session, or ErrSessionNotFound if it does not exist or has
+// expired
+func (r *RedisBackend) Get(id string) ([]byte, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+	data, err := redis.Bytes(c.Do("GET", redisSessionPrefix+id))
+	if err == redis.ErrNil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get session from redis")
+	}
+	return data, nil
+}
+
+// Set stores the session until the ttl passes; a session with a ttl of zero
+// does not expire
+func (r *RedisBackend) Set(id string, owners []string, data []byte, ttl time.Duration) error {
+	c := r.Pool.Get()
+	defer c.Close()
+	seconds := int64(ttl / time.Second)
+	c.Send("MULTI")
+	if seconds > 0 {
+		c.Send("SET", redisSessionPrefix+id, data, "EX", seconds)
+	} else {
+		c.Send("SET", redisSessionPrefix+id, data)
+	}
+	for _, owner := range owners {
+		c.Send("SADD", redisOwnerPrefix+owner, id)
+		if seconds > 0 {
+			c.Send("EXPIRE", redisOwnerPrefix+owner, seconds)
+		}
+	}
+	_, err := c.Do("EXEC")
+	if err != nil {
+		return errors.Wrap(err, "could not store session in redis")
+	}
+	return nil
+}
+
+// Delete deletes the session
+func (r *RedisBackend) Delete(id string) error {
+	c := r.Pool.Get()
+	defer c.Close()
+	_, err := c.Do("DEL", redisSessionPrefix+id)
+	if err != nil {
+		return errors.Wrap(err, "could not delete session from redis")
+	}
+	return nil
+}
+
+// DeleteOwner deletes each of the owner's sessions
+func (r *RedisBackend) DeleteOwner(owner string) (int, error) {
+	c := r.Pool.Get()
+	defer c.Close()
+	ids, err := redis.Strings(c.Do("SMEMBERS", redisOwnerPrefix+owner))
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not list sessions for [%s] in redis", owner)
+	}
+	n := 0
+	for _, id := range ids {
+		deleted, err := redis.Int(c.Do("DEL", redisSessionPrefix+id))
+		if err != nil {
+			return n, errors.Wrap(err, "could not delete session from redis")
+		}
+		n += deleted
+	}
+	_, err = c.Do("DEL", redisOwnerPrefix+owner)
+	if err != nil {
+		return n, errors.Wrapf(err, "could not delete sessions for [%s] from redis", owner)
+	}
+	return n, nil
+}
+
+// Ping checks that the Redis server can be reached
+func (r *RedisBackend) Ping() error {
+	c := r.Pool.Get()
+	defer c.Close()
+	_, err := c.Do("PING")
+	return err
+}
+
+func redisURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse redis url [%s]", rawURL)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, errors.Errorf("redis url [%s] must use the redis or rediss scheme", u.Host)
+	}
+	return u, nil
+}
@@ -0,0 +1,78 @@
+package session_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/internal/fakeredis"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestRedisBackend(t *testing.T) {
+	spec.Run(t, "RedisBackend", testRedisBackend, spec.Report(report.Terminal{}))
+}
+
+func testRedisBackend(t *testing.T, when spec.G, it spec.S) {
+	var (
+		server  *fakeredis.Server
+		backend *session.RedisBackend
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		var err error
+		server, err = fakeredis.Run()
+		Expect(err).NotTo(HaveOccurred())
+		backend, err = session.NewRedisBackend("redis://" + server.Addr())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		backend.Pool.Close()
+		server.Close()
+	})
+
+	it("stores sessions until they expire", func() {
+		Expect(backend.Ping()).To(Succeed())
+		Expect(backend.Set("test-id", []string{"testuser"}, []byte(`{"profile":"test"}`), time.Hour)).To(Succeed())
+		data, err := backend.Get("test-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal(`{"profile":"test"}`))
+		Expect(server.TTL("ignition:session:test-id")).To(BeNumerically("~", time.Hour, time.Second))
+
+		server.FastForward(2 * time.Hour)
+		_, err = backend.Get("test-id")
+		Expect(err).To(Equal(session.ErrSessionNotFound))
+	})
+
+	it("deletes a session", func() {
+		Expect(backend.Set("test-id", []string{"testuser"}, []byte("{}"), time.Hour)).To(Succeed())
+		Expect(backend.Delete("test-id")).To(Succeed())
+		_, err := backend.Get("test-id")
+		Expect(err).To(Equal(session.ErrSessionNotFound))
+	})
+
+	it("deletes every session belonging to an owner", func() {
+		Expect(backend.Set("first-id", []string{"testuser"}, []byte("{}"), time.Hour)).To(Succeed())
+		Expect(backend.Set("second-id", []string{"testuser"}, []byte("{}"), time.Hour)).To(Succeed())
+		Expect(backend.Set("other-id", []string{"otheruser"}, []byte("{}"), time.Hour)).To(Succeed())
+		Expect(backend.Delete("second-id")).To(Succeed())
+
+		n, err := backend.DeleteOwner("testuser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(1))
+		_, err = backend.Get("first-id")
+		Expect(err).To(Equal(session.ErrSessionNotFound))
+		_, err = backend.Get("other-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(server.Exists("ignition:owner:testuser")).To(BeFalse())
+	})
+
+	it("requires a redis url", func() {
+		_, err := session.NewRedisBackend("http://redis.example.net")
+		Expect(err).To(HaveOccurred())
+	})
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dghubble/sessions"
+	"github.com/pivotalservices/ignition/user"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// Refresher refreshes the access token in a session when it expires, so that
+// users stay logged in, and ends sessions that are older than MaxAge
+type Refresher struct {
+	// Config is used to refresh tokens with their refresh token
+	Config *oauth2.Config
+
+	// Fetcher verifies the ID token that is issued with a refreshed token, and
+	// gets the user's profile from it
+	Fetcher user.Fetcher
+
+	// MaxAge is how long a session lasts after the user logs in, however many
+	// times its token is refreshed. Sessions last as long as their token can be
+	// refreshed when MaxAge is zero.
+	MaxAge time.Duration
+}
+
+// Expired reports whether the session is older than MaxAge
+func (r *Refresher) Expired(session *sessions.Session, now time.Time) bool {
+	if r == nil || r.MaxAge <= 0 {
+		return false
+	}
+	issued, ok := session.Values[sessionIssuedKey].(string)
+	if !ok {
+		// sessions issued before their age was recorded end when their token
+		// expires
+		return false
+	}
+	unix, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil {
+		return true
+	}
+	return now.Sub(time.Unix(unix, 0)) > r.MaxAge
+}
+
+// Refresh uses the token's refresh token to get a new token. If a new ID token
+// is issued with it, the ID token is verified and the user's profile is
+// returned as well.
+func (r *Refresher) Refresh(ctx context.Context, token *oauth2.Token) (*oauth2.Token, *user.Profile, error) {
+	if r == nil || r.Config == nil {
+		return nil, nil, errors.New("tokens cannot be refreshed")
+	}
+	refreshed, err := r.Config.TokenSource(ctx, token).Token()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not refresh token")
+	}
+	if refreshed.Extra("id_token") == nil || r.Fetcher == nil {
+		return refreshed, nil, nil
+	}
+	profile, err := r.Fetcher.Profile(ctx, r.Config, refreshed)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not verify refreshed ID token")
+	}
+	return refreshed, profile, nil
+}
+
+// canRefresh reports whether the token has expired and can be refreshed
+func (r *Refresher) canRefresh(token *oauth2.Token) bool {
+	return r != nil && r.Config != nil && !token.Valid() && token.RefreshToken != ""
+}
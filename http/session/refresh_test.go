@@ -0,0 +1,144 @@
+package session_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dghubble/sessions"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/pivotalservices/ignition/http/session/sessionfakes"
+	"github.com/pivotalservices/ignition/user"
+	"github.com/pivotalservices/ignition/user/openid"
+	"github.com/pivotalservices/ignition/user/openid/openidfakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+	"golang.org/x/oauth2"
+)
+
+func TestRefresher(t *testing.T) {
+	spec.Run(t, "Refresher", testRefresher, spec.Report(report.Terminal{}))
+}
+
+func testRefresher(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tokenServer  *httptest.Server
+		tokenHandler http.HandlerFunc
+		verifier     *openidfakes.FakeVerifier
+		refresher    *session.Refresher
+		store        *sessionfakes.FakeStore
+		s            *sessions.Session
+		nextContext  context.Context
+		handler      http.Handler
+	)
+
+	// setToken stores the token in the session
+	setToken := func(token *oauth2.Token) {
+		j, err := json.Marshal(token)
+		Expect(err).NotTo(HaveOccurred())
+		b := bytes.NewBuffer(nil)
+		session.GzipWrite(b, j)
+		s.Values["token"] = b.String()
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		tokenHandler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"refreshed-token","token_type":"bearer","expires_in":3600,"refresh_token":"new-refresh-token","id_token":"test-id-token"}`))
+		}
+		tokenServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { tokenHandler(w, r) }))
+		verifier = &openidfakes.FakeVerifier{}
+		verifier.VerifyReturns(&openid.Claims{Email: "test@pivotal.io", UserName: "testuser", Groups: []string{"developers"}}, nil)
+		refresher = &session.Refresher{
+			Config: &oauth2.Config{
+				ClientID: "test-client",
+				Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL + "/oauth/token"},
+			},
+			Fetcher: &openid.Fetcher{Verifier: verifier},
+			MaxAge:  24 * time.Hour,
+		}
+
+		store = &sessionfakes.FakeStore{}
+		s = sessions.NewSession(store, "ignition")
+		store.GetReturns(s, nil)
+		s.Values["profile"] = `{"email": "test@pivotal.io", "AccountName": "testuser"}`
+		s.Values["issued"] = strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+		setToken(&oauth2.Token{AccessToken: "expired-token", RefreshToken: "test-refresh-token", Expiry: time.Now().Add(-time.Minute)})
+
+		nextContext = nil
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { nextContext = r.Context() })
+		handler = session.PopulateContext(next, store, refresher)
+	})
+
+	it.After(func() {
+		tokenServer.Close()
+	})
+
+	it("refreshes an expired token and saves it in the session", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		token, err := session.TokenFromContext(nextContext)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.AccessToken).To(Equal("refreshed-token"))
+		Expect(token.Valid()).To(BeTrue())
+		Expect(store.SaveCallCount()).To(Equal(1))
+
+		Expect(verifier.VerifyCallCount()).To(Equal(1))
+		_, rawIDToken := verifier.VerifyArgsForCall(0)
+		Expect(rawIDToken).To(Equal("test-id-token"))
+		profile, err := user.ProfileFromContext(nextContext)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Groups).To(ConsistOf("developers"))
+	})
+
+	it("does not refresh a token that has not expired", func() {
+		setToken(&oauth2.Token{AccessToken: "test-token", RefreshToken: "test-refresh-token", Expiry: time.Now().Add(time.Hour)})
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		token, err := session.TokenFromContext(nextContext)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.AccessToken).To(Equal("test-token"))
+		Expect(store.SaveCallCount()).To(Equal(0))
+	})
+
+	it("keeps the expired token when it cannot be refreshed", func() {
+		tokenHandler = func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		token, err := session.TokenFromContext(nextContext)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.AccessToken).To(Equal("expired-token"))
+		Expect(store.SaveCallCount()).To(Equal(0))
+	})
+
+	it("keeps the expired token when the refreshed ID token cannot be verified", func() {
+		verifier.VerifyReturns(nil, errors.New("test error"))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		token, err := session.TokenFromContext(nextContext)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.AccessToken).To(Equal("expired-token"))
+		Expect(store.SaveCallCount()).To(Equal(0))
+	})
+
+	it("ignores a session that has outlived the session lifetime", func() {
+		s.Values["issued"] = strconv.FormatInt(time.Now().Add(-25*time.Hour).Unix(), 10)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		_, err := session.TokenFromContext(nextContext)
+		Expect(err).To(HaveOccurred())
+		_, err = user.ProfileFromContext(nextContext)
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("does not limit the lifetime of sessions when there is no max age", func() {
+		refresher.MaxAge = 0
+		s.Values["issued"] = strconv.FormatInt(time.Now().Add(-25*time.Hour).Unix(), 10)
+		Expect(refresher.Expired(s, time.Now())).To(BeFalse())
+	})
+}
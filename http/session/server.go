@@ -0,0 +1,235 @@
+package session
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dghubble/sessions"
+	"github.com/gorilla/securecookie"
+	"github.com/pkg/errors"
+)
+
+// defaultMaxAge is how long a session lasts, in seconds
+const defaultMaxAge = 3600 * 24 * 7
+
+// sessionIDKey holds the ID of a session loaded by a ServerStore in the
+// session's values; it is not saved with the other values
+const sessionIDKey = "_id"
+
+// ErrSessionNotFound is returned by a Backend when there is no session with
+// the given ID, or the session has expired
+var ErrSessionNotFound = errors.New("session not found")
+
+// Backend stores sessions on the server, keyed by session ID. Each session has
+// owners, so that all of an owner's sessions can be revoked at once.
+type Backend interface {
+	Get(id string) ([]byte, error)
+	Set(id string, owners []string, data []byte, ttl time.Duration) error
+	Delete(id string) error
+	DeleteOwner(owner string) (int, error)
+}
+
+// Revoker is a sessions.Store whose sessions can be revoked before they expire
+type Revoker interface {
+	// Revoke revokes the named session for the request
+	Revoke(req *http.Request, name string) error
+
+	// RevokeOwner revokes every session belonging to the owner, returning the
+	// number of sessions that were revoked
+	RevokeOwner(owner string) (int, error)
+
+	// RevokeSubject revokes every session for the identity provider's subject
+	// (the sub claim), returning the number of sessions that were revoked
+	RevokeSubject(sub string) (int, error)
+}
+
+// ServerStore is a sessions.Store that keeps the values of each session on the
+// server, in a Backend. The session cookie only holds a signed, opaque session
+// ID.
+type ServerStore struct {
+	Backend Backend
+	Codecs  []securecookie.Codec
+	Config  *sessions.Config
+}
+
+// NewServerStore returns a ServerStore that signs session IDs with the key
+// pairs, as described by securecookie.CodecsFromPairs
+func NewServerStore(b Backend, keyPairs ...[]byte) *ServerStore {
+	return &ServerStore{
+		Backend: b,
+		Codecs:  securecookie.CodecsFromPairs(keyPairs...),
+		Config: &sessions.Config{
+			Path:     "/",
+			MaxAge:   defaultMaxAge,
+			HTTPOnly: true,
+		},
+	}
+}
+
+// New returns a new, unsaved session with the given name
+func (s *ServerStore) New(name string) *sessions.Session {
+	session := sessions.NewSession(s, name)
+	config := *s.Config
+	session.Config = &config
+	return session
+}
+
+// Get returns the named session for the request, loading its values from the
+// backend
+func (s *ServerStore) Get(req *http.Request, name string) (*sessions.Session, error) {
+	id, err := s.sessionID(req, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.Backend.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	session := s.New(name)
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&session.Values)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal session")
+	}
+	session.Values[sessionIDKey] = id
+	return session, nil
+}
+
+// Save stores the session's values in the backend, and sets the session cookie
+func (s *ServerStore) Save(w http.ResponseWriter, session *sessions.Session) error {
+	id, _ := session.Values[sessionIDKey].(string)
+	if id == "" {
+		var err error
+		id, err = newSessionID()
+		if err != nil {
+			return err
+		}
+	}
+	values := make(map[string]interface{}, len(session.Values))
+	for k, v := range session.Values {
+		if k != sessionIDKey {
+			values[k] = v
+		}
+	}
+	// the values are gob encoded, like the values in cookie sessions, because
+	// they hold binary data such as the compressed token
+	var data bytes.Buffer
+	err := gob.NewEncoder(&data).Encode(values)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal session")
+	}
+	var owners []string
+	if owner, _ := session.Values[sessionOwnerKey].(string); normalizeOwner(owner) != "" {
+		owners = append(owners, accountOwner(owner))
+	}
+	if sub, _ := session.Values[sessionSubjectKey].(string); sub != "" {
+		owners = append(owners, subjectOwner(sub))
+	}
+	err = s.Backend.Set(id, owners, data.Bytes(), time.Duration(session.Config.MaxAge)*time.Second)
+	if err != nil {
+		return errors.Wrap(err, "could not store session")
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), id, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	session.Values[sessionIDKey] = id
+	http.SetCookie(w, newCookie(session.Name(), encoded, session.Config))
+	return nil
+}
+
+// Destroy deletes the session cookie. The session itself is only deleted from
+// the backend by Revoke, because Destroy is not given the request.
+func (s *ServerStore) Destroy(w http.ResponseWriter, name string) {
+	http.SetCookie(w, newCookie(name, "", &sessions.Config{Path: s.Config.Path, Domain: s.Config.Domain, MaxAge: -1}))
+}
+
+// Revoke deletes the named session for the request from the backend
+func (s *ServerStore) Revoke(req *http.Request, name string) error {
+	id, err := s.sessionID(req, name)
+	if err != nil {
+		return err
+	}
+	return s.Backend.Delete(id)
+}
+
+// RevokeOwner deletes every session belonging to the owner from the backend
+func (s *ServerStore) RevokeOwner(owner string) (int, error) {
+	if normalizeOwner(owner) == "" {
+		return 0, nil
+	}
+	return s.Backend.DeleteOwner(accountOwner(owner))
+}
+
+// RevokeSubject deletes every session for the subject from the backend
+func (s *ServerStore) RevokeSubject(sub string) (int, error) {
+	if sub == "" {
+		return 0, nil
+	}
+	return s.Backend.DeleteOwner(subjectOwner(sub))
+}
+
+func (s *ServerStore) sessionID(req *http.Request, name string) (string, error) {
+	cookie, err := req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	var id string
+	err = securecookie.DecodeMulti(name, cookie.Value, &id, s.Codecs...)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// normalizeOwner returns the account name that owns a session in the form
+// it is stored in the session
+func normalizeOwner(owner string) string {
+	return strings.ToLower(strings.TrimSpace(owner))
+}
+
+// accountOwner is the backend owner for the sessions of an account
+func accountOwner(owner string) string {
+	return "account:" + normalizeOwner(owner)
+}
+
+// subjectOwner is the backend owner for the sessions of an identity provider
+// subject; subjects are case sensitive
+func subjectOwner(sub string) string {
+	return "sub:" + sub
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "could not generate session id")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newCookie returns the session cookie. It is SameSite, so that browsers do
+// not send it with the requests that other sites make to ignition, such as the
+// submission of a cross-site form.
+func newCookie(name, value string, config *sessions.Config) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   config.Domain,
+		Path:     config.Path,
+		MaxAge:   config.MaxAge,
+		HttpOnly: config.HTTPOnly,
+		Secure:   config.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if config.MaxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(config.MaxAge) * time.Second)
+	} else if config.MaxAge < 0 {
+		cookie.Expires = time.Unix(1, 0)
+	}
+	return cookie
+}
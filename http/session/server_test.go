@@ -0,0 +1,182 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/http/session"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestServerStore(t *testing.T) {
+	spec.Run(t, "ServerStore", testServerStore, spec.Report(report.Terminal{}))
+}
+
+func testServerStore(t *testing.T, when spec.G, it spec.S) {
+	var (
+		backend *session.MemoryBackend
+		store   *session.ServerStore
+	)
+
+	// save saves a session with the values, returning a request that carries
+	// its cookie
+	save := func(values map[string]interface{}) *http.Request {
+		s := store.New("ignition")
+		for k, v := range values {
+			s.Values[k] = v
+		}
+		w := httptest.NewRecorder()
+		Expect(s.Save(w)).To(Succeed())
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range w.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		return req
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		backend = session.NewMemoryBackend()
+		store = session.NewServerStore(backend, []byte("test-secret"))
+	})
+
+	it("keeps the session's values on the server", func() {
+		s := store.New("ignition")
+		s.Values["profile"] = "test-profile"
+		w := httptest.NewRecorder()
+		Expect(s.Save(w)).To(Succeed())
+		cookies := w.Result().Cookies()
+		Expect(cookies).To(HaveLen(1))
+		Expect(cookies[0].Name).To(Equal("ignition"))
+		Expect(cookies[0].HttpOnly).To(BeTrue())
+		Expect(cookies[0].SameSite).To(Equal(http.SameSiteLaxMode))
+		Expect(cookies[0].Value).NotTo(ContainSubstring("test-profile"))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+		loaded, err := store.Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Values).To(HaveKeyWithValue("profile", "test-profile"))
+	})
+
+	it("keeps binary values intact", func() {
+		req := save(map[string]interface{}{"token": "\x1f\x8b\x08\x00\xff"})
+		loaded, err := store.Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Values).To(HaveKeyWithValue("token", "\x1f\x8b\x08\x00\xff"))
+	})
+
+	it("updates a session in place when it is saved again", func() {
+		req := save(map[string]interface{}{"profile": "test-profile"})
+		s, err := store.Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+		s.Values["uaaid"] = "test-user-id"
+		Expect(s.Save(httptest.NewRecorder())).To(Succeed())
+
+		loaded, err := store.Get(req, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Values).To(HaveKeyWithValue("uaaid", "test-user-id"))
+	})
+
+	it("does not load a session whose cookie was signed with another secret", func() {
+		req := save(map[string]interface{}{"profile": "test-profile"})
+		other := session.NewServerStore(backend, []byte("other-secret"))
+		_, err := other.Get(req, "ignition")
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("does not load a session without a cookie", func() {
+		_, err := store.Get(httptest.NewRequest(http.MethodGet, "/", nil), "ignition")
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("revokes the session for a request", func() {
+		req := save(map[string]interface{}{"profile": "test-profile"})
+		Expect(store.Revoke(req, "ignition")).To(Succeed())
+		_, err := store.Get(req, "ignition")
+		Expect(err).To(Equal(session.ErrSessionNotFound))
+	})
+
+	it("revokes every session belonging to an owner", func() {
+		first := save(map[string]interface{}{"owner": "testuser"})
+		second := save(map[string]interface{}{"owner": "testuser"})
+		other := save(map[string]interface{}{"owner": "otheruser"})
+
+		n, err := store.RevokeOwner("TestUser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(2))
+		_, err = store.Get(first, "ignition")
+		Expect(err).To(Equal(session.ErrSessionNotFound))
+		_, err = store.Get(second, "ignition")
+		Expect(err).To(Equal(session.ErrSessionNotFound))
+		_, err = store.Get(other, "ignition")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it("deletes the cookie when the session is destroyed", func() {
+		w := httptest.NewRecorder()
+		store.Destroy(w, "ignition")
+		cookies := w.Result().Cookies()
+		Expect(cookies).To(HaveLen(1))
+		Expect(cookies[0].MaxAge).To(BeNumerically("<", 0))
+	})
+
+	it("expires sessions", func() {
+		Expect(backend.Set("test-id", []string{"testuser"}, []byte("{}"), time.Millisecond)).To(Succeed())
+		time.Sleep(5 * time.Millisecond)
+		_, err := backend.Get("test-id")
+		Expect(err).To(Equal(session.ErrSessionNotFound))
+		n, err := backend.DeleteOwner("testuser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(0))
+	})
+}
+
+func TestLogoutEverywhereHandler(t *testing.T) {
+	spec.Run(t, "LogoutEverywhereHandler", testLogoutEverywhereHandler, spec.Report(report.Terminal{}))
+}
+
+func testLogoutEverywhereHandler(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("revokes every one of the user's sessions", func() {
+		store := session.NewServerStore(session.NewMemoryBackend(), []byte("test-secret"))
+		var reqs []*http.Request
+		for i := 0; i < 2; i++ {
+			s := store.New("ignition")
+			s.Values["owner"] = "testuser"
+			w := httptest.NewRecorder()
+			Expect(s.Save(w)).To(Succeed())
+			req := httptest.NewRequest(http.MethodPost, "/logout/everywhere", nil)
+			req.AddCookie(w.Result().Cookies()[0])
+			reqs = append(reqs, req)
+		}
+
+		w := httptest.NewRecorder()
+		session.LogoutEverywhereHandler(store).ServeHTTP(w, reqs[0])
+		Expect(w.Code).To(Equal(http.StatusFound))
+		for _, req := range reqs {
+			_, err := store.Get(req, "ignition")
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	it("revokes the session when logging out of a server store", func() {
+		store := session.NewServerStore(session.NewMemoryBackend(), []byte("test-secret"))
+		s := store.New("ignition")
+		w := httptest.NewRecorder()
+		Expect(s.Save(w)).To(Succeed())
+		req := httptest.NewRequest(http.MethodGet, "/logout", nil)
+		req.AddCookie(w.Result().Cookies()[0])
+
+		session.LogoutHandler(store).ServeHTTP(httptest.NewRecorder(), req)
+		_, err := store.Get(req, "ignition")
+		Expect(err).To(Equal(session.ErrSessionNotFound))
+	})
+}
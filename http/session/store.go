@@ -3,12 +3,17 @@ package session
 import (
 	"bytes"
 	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	dgoauth2 "github.com/dghubble/gologin/oauth2"
 	"github.com/dghubble/sessions"
+	"github.com/pivotalservices/ignition/audit"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/pivotalservices/ignition/metrics"
 	"github.com/pivotalservices/ignition/uaa"
 	"github.com/pivotalservices/ignition/user"
 	"golang.org/x/oauth2"
@@ -19,6 +24,9 @@ const (
 	sessionProfileKey = "profile"
 	sessionEmailKey   = "email"
 	sessionUAAIDKey   = "uaaid"
+	sessionOwnerKey   = "owner"
+	sessionIssuedKey  = "issued"
+	sessionSubjectKey = "sub"
 	sessionName       = "ignition"
 )
 
@@ -29,7 +37,7 @@ func UpdateSessionWithUserID(w http.ResponseWriter, req *http.Request, s session
 	}
 	session, err := s.Get(req, sessionName)
 	if err != nil {
-		log.Println(err)
+		logging.FromContext(req.Context()).Error("could not get session", "error", err)
 		return
 	}
 	session.Values[sessionUAAIDKey] = userID
@@ -42,61 +50,76 @@ func IssueSession(s sessions.Store, u uaa.API) http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		profile, err := user.ProfileFromContext(req.Context())
 		if err != nil {
+			metrics.LoginFailed(metrics.LoginFailureSession)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		token, err := dgoauth2.TokenFromContext(req.Context())
 		if err != nil {
+			metrics.LoginFailed(metrics.LoginFailureSession)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		session := s.New(sessionName)
 		if session == nil {
+			metrics.LoginFailed(metrics.LoginFailureSession)
 			http.Error(w, "session cannot be created", http.StatusInternalServerError)
 			return
 		}
 		j, err := json.Marshal(profile)
 		if err != nil {
+			metrics.LoginFailed(metrics.LoginFailureSession)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		session.Values[sessionProfileKey] = string(j)
-		j, err = json.Marshal(token)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		session.Values[sessionOwnerKey] = normalizeOwner(profile.AccountName)
+		if profile.Subject != "" {
+			session.Values[sessionSubjectKey] = profile.Subject
 		}
-		var buf bytes.Buffer
-		err = GzipWrite(&buf, []byte(j))
+		session.Values[sessionIssuedKey] = strconv.FormatInt(time.Now().Unix(), 10)
+		rawToken, err := encodeToken(token)
 		if err != nil {
+			metrics.LoginFailed(metrics.LoginFailureSession)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		session.Values[sessionTokenKey] = string(buf.String())
+		session.Values[sessionTokenKey] = rawToken
 		userID, err := u.UserIDForAccountName(profile.AccountName)
 		if err == nil {
 			session.Values[sessionUAAIDKey] = userID
 		}
 		session.Save(w)
+		metrics.LoginSucceeded()
+		audit.Record(audit.Event{Type: audit.EventLogin, AccountName: profile.AccountName, Email: profile.Email, UserID: userID})
+		logging.AddField(req.Context(), "account_name", profile.AccountName)
+		logging.FromContext(req.Context()).Info("logged in")
 		http.Redirect(w, req, "/", http.StatusFound)
 	}
 	return http.HandlerFunc(fn)
 }
 
-// PopulateContext populates the context with session information
-func PopulateContext(next http.Handler, s sessions.Store) http.Handler {
+// PopulateContext populates the context with session information. When r is
+// set, sessions older than its MaxAge are ignored, and expired tokens are
+// refreshed and saved in the session.
+func PopulateContext(next http.Handler, s sessions.Store, r *Refresher) http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		session, err := s.Get(req, sessionName)
 		if err != nil {
 			next.ServeHTTP(w, req)
 			return
 		}
+		if r.Expired(session, time.Now()) {
+			logging.FromContext(req.Context()).Info("session has outlived the session lifetime")
+			next.ServeHTTP(w, req)
+			return
+		}
 		rawToken, ok := session.Values[sessionTokenKey].(string)
 		var buf bytes.Buffer
 		err = GunzipWrite(&buf, []byte(rawToken))
 		if err != nil {
-			log.Println(err)
+			logging.FromContext(req.Context()).Error("could not decompress token", "error", err)
 			next.ServeHTTP(w, req)
 			return
 		}
@@ -105,7 +128,10 @@ func PopulateContext(next http.Handler, s sessions.Store) http.Handler {
 			token := oauth2.Token{}
 			err = json.Unmarshal(buf.Bytes(), &token)
 			if err != nil {
-				log.Println(err)
+				logging.FromContext(ctx).Error("could not unmarshal token", "error", err)
+			}
+			if r.canRefresh(&token) {
+				refreshToken(w, req, session, r, &token)
 			}
 			ctx = ContextWithToken(ctx, &token)
 		}
@@ -115,9 +141,12 @@ func PopulateContext(next http.Handler, s sessions.Store) http.Handler {
 			profile := user.Profile{}
 			err = json.Unmarshal([]byte(rawProfile), &profile)
 			if err != nil {
-				log.Println(err)
+				logging.FromContext(ctx).Error("could not unmarshal profile", "error", err)
 			}
 			ctx = user.WithProfile(ctx, &profile)
+			if profile.AccountName != "" {
+				logging.AddField(ctx, "account_name", profile.AccountName)
+			}
 		}
 		userID, ok := session.Values[sessionUAAIDKey].(string)
 		if ok {
@@ -129,9 +158,98 @@ func PopulateContext(next http.Handler, s sessions.Store) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+// refreshToken refreshes the expired token, saving the new token, and the
+// user's profile if a new ID token was issued, in the session. The token is
+// left as it is if it cannot be refreshed.
+func refreshToken(w http.ResponseWriter, req *http.Request, session *sessions.Session, r *Refresher, token *oauth2.Token) {
+	logger := logging.FromContext(req.Context())
+	refreshed, profile, err := r.Refresh(req.Context(), token)
+	if err != nil {
+		logger.Warn("could not refresh token", "error", err)
+		return
+	}
+	rawToken, err := encodeToken(refreshed)
+	if err != nil {
+		logger.Error("could not encode refreshed token", "error", err)
+		return
+	}
+	session.Values[sessionTokenKey] = rawToken
+	if profile != nil {
+		j, err := json.Marshal(profile)
+		if err != nil {
+			logger.Error("could not marshal refreshed profile", "error", err)
+			return
+		}
+		session.Values[sessionProfileKey] = string(j)
+	}
+	err = session.Save(w)
+	if err != nil {
+		logger.Error("could not save refreshed session", "error", err)
+		return
+	}
+	*token = *refreshed
+	logger.Debug("refreshed token")
+}
+
+// encodeToken marshals and compresses the token for storage in a session
+func encodeToken(token *oauth2.Token) (string, error) {
+	j, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = GzipWrite(&buf, j)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 // LogoutHandler logs a user out and deletes their session
 func LogoutHandler(s sessions.Store) http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
+		logout(w, req, s)
+		http.Redirect(w, req, "/", http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// logout revokes the user's session, if the store can revoke sessions, and
+// deletes the session cookie
+func logout(w http.ResponseWriter, req *http.Request, s sessions.Store) {
+	if r, ok := s.(Revoker); ok {
+		err := r.Revoke(req, sessionName)
+		if err != nil {
+			logging.FromContext(req.Context()).Debug("could not revoke session", "error", err)
+		}
+	}
+	s.Destroy(w, sessionName)
+}
+
+// LogoutEverywhereHandler logs a user out of every one of their sessions, when
+// the store can revoke them, and deletes the current session
+func LogoutEverywhereHandler(s sessions.Store) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		r, ok := s.(Revoker)
+		if !ok {
+			LogoutHandler(s).ServeHTTP(w, req)
+			return
+		}
+		logger := logging.FromContext(req.Context())
+		session, err := s.Get(req, sessionName)
+		if err == nil {
+			owner, _ := session.Values[sessionOwnerKey].(string)
+			n, err := r.RevokeOwner(owner)
+			if err != nil {
+				logger.Error("could not revoke sessions", "owner", owner, "error", err)
+			} else if owner != "" {
+				audit.Record(audit.Event{Type: audit.EventSessionsRevoked, AccountName: owner, Reason: fmt.Sprintf("logged out of %d sessions", n)})
+			}
+		}
+		err = r.Revoke(req, sessionName)
+		if err != nil {
+			logger.Debug("could not revoke session", "error", err)
+		}
 		s.Destroy(w, sessionName)
 		http.Redirect(w, req, "/", http.StatusFound)
 	}
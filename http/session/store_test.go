@@ -14,6 +14,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/pivotalservices/ignition/http/session"
 	"github.com/pivotalservices/ignition/http/session/sessionfakes"
+	"github.com/pivotalservices/ignition/logging"
 	"github.com/pivotalservices/ignition/uaa/uaafakes"
 	"github.com/pivotalservices/ignition/user"
 	"github.com/sclevine/spec"
@@ -102,6 +103,8 @@ func testIssueSession(t *testing.T, when spec.G, it spec.S) {
 			w := httptest.NewRecorder()
 			handler.ServeHTTP(w, req)
 			Expect(w.Code).Should(Equal(http.StatusFound))
+			Expect(s.Values).To(HaveKey("issued"))
+			Expect(s.Values).To(HaveKeyWithValue("owner", "test@pivotal.io"))
 		})
 
 		when("there is no user ID for the account name", func() {
@@ -159,7 +162,7 @@ func testPopulateContext(t *testing.T, when spec.G, it spec.S) {
 	it("invokes the next handler", func() {
 		called := false
 		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
-		handler := session.PopulateContext(next, fakeSessionStore)
+		handler := session.PopulateContext(next, fakeSessionStore, nil)
 
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -181,7 +184,7 @@ func testPopulateContext(t *testing.T, when spec.G, it spec.S) {
 			s.Values["uaaid"] = "testuser"
 
 			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { nextContext = r.Context() })
-			handler = session.PopulateContext(next, fakeSessionStore)
+			handler = session.PopulateContext(next, fakeSessionStore, nil)
 		})
 
 		when("the session cannot be retrieved", func() {
@@ -227,6 +230,17 @@ func testPopulateContext(t *testing.T, when spec.G, it spec.S) {
 				Expect(userID).To(Equal("testuser"))
 			})
 		})
+
+		it("adds the account name to the request's log entries", func() {
+			s.Values["profile"] = `{"email": "test@pivotal.io", "AccountName": "testuser"}`
+			buf := bytes.NewBuffer(nil)
+			l := logging.New(buf, logging.InfoLevel)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			handler.ServeHTTP(w, r.WithContext(logging.NewContext(r.Context(), l)))
+			l.Info("test message")
+			Expect(buf.String()).To(ContainSubstring(`"account_name":"testuser"`))
+		})
 	})
 }
 
@@ -0,0 +1,644 @@
+// Package fakecf is a stateful fake of the Cloud Controller (v2 and v3) and
+// UAA APIs that ignition uses. It is served over HTTP so that the real
+// Cloud Controller and UAA clients, and the queries that ignition builds for
+// them, are exercised by tests and local development runs.
+package fakecf
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// The names of the org quota and isolation segment that every foundation has
+const (
+	DefaultQuotaName           = "default"
+	SharedIsolationSegmentName = "shared"
+)
+
+// The role types, as they are named by the v3 API
+const (
+	RoleOrganizationUser    = "organization_user"
+	RoleOrganizationManager = "organization_manager"
+	RoleOrganizationAuditor = "organization_auditor"
+	RoleSpaceDeveloper      = "space_developer"
+	RoleSpaceManager        = "space_manager"
+	RoleSpaceAuditor        = "space_auditor"
+)
+
+// Org is an organization
+type Org struct {
+	GUID                        string
+	Name                        string
+	Status                      string
+	QuotaGUID                   string
+	DefaultIsolationSegmentGUID string
+	IsolationSegmentGUIDs       []string
+	CreatedAt                   time.Time
+	UpdatedAt                   time.Time
+}
+
+// Space is a space in an org
+type Space struct {
+	GUID           string
+	Name           string
+	OrgGUID        string
+	SpaceQuotaGUID string
+	AllowSSH       bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Role is an org or space role held by a user
+type Role struct {
+	GUID      string
+	Type      string
+	UserGUID  string
+	OrgGUID   string
+	SpaceGUID string
+}
+
+// Quota is an org quota
+type Quota struct {
+	GUID string
+	Name string
+}
+
+// SpaceQuota is a space quota that belongs to an org
+type SpaceQuota struct {
+	GUID                string
+	Name                string
+	OrgGUID             string
+	MemoryLimit         int
+	InstanceMemoryLimit int
+	TotalRoutes         int
+	TotalServices       int
+	AppInstanceLimit    int
+	NonBasicServices    bool
+}
+
+// IsolationSegment is an isolation segment
+type IsolationSegment struct {
+	GUID string
+	Name string
+}
+
+// SecurityGroup is an application security group, and the spaces that it is
+// bound to
+type SecurityGroup struct {
+	GUID              string
+	Name              string
+	RunningSpaceGUIDs []string
+	StagingSpaceGUIDs []string
+}
+
+// Service is a marketplace service
+type Service struct {
+	GUID  string
+	Label string
+}
+
+// ServicePlan is a plan of a marketplace service
+type ServicePlan struct {
+	GUID        string
+	Name        string
+	ServiceGUID string
+}
+
+// ServiceInstance is an instance of a service plan in a space
+type ServiceInstance struct {
+	GUID       string
+	Name       string
+	SpaceGUID  string
+	PlanGUID   string
+	Parameters map[string]interface{}
+	Tags       []string
+}
+
+// App is an app in a space
+type App struct {
+	GUID      string
+	Name      string
+	SpaceGUID string
+	State     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Route is a route in a space
+type Route struct {
+	GUID      string
+	Host      string
+	SpaceGUID string
+}
+
+// User is a UAA user
+type User struct {
+	ID         string
+	UserName   string
+	Origin     string
+	ExternalID string
+	Email      string
+}
+
+// Client is a UAA client that can get tokens with the client credentials
+// grant
+type Client struct {
+	ID          string
+	Secret      string
+	Authorities []string
+}
+
+// Server is a fake Cloud Controller and UAA. The Cloud Controller and UAA are
+// served from the same address, which works for system domains that are
+// localhost or 127.0.0.1 because ignition does not prefix them with api. or
+// login.
+type Server struct {
+	router *mux.Router
+	mu     sync.Mutex
+	now    func() time.Time
+
+	clients           []*Client
+	tokens            map[string]*Client
+	orgs              []*Org
+	spaces            []*Space
+	roles             []*Role
+	quotas            []*Quota
+	spaceQuotas       []*SpaceQuota
+	isolationSegments []*IsolationSegment
+	securityGroups    []*SecurityGroup
+	services          []*Service
+	plans             []*ServicePlan
+	serviceInstances  []*ServiceInstance
+	apps              []*App
+	routes            []*Route
+	users             []*User
+}
+
+// New returns a fake foundation with the default org quota and the shared
+// isolation segment, and a UAA client with the authorities that ignition
+// requires
+func New(clientID, clientSecret string) *Server {
+	s := &Server{
+		now:    func() time.Time { return time.Now().UTC() },
+		tokens: make(map[string]*Client),
+	}
+	s.AddClient(clientID, clientSecret, "cloud_controller.admin", "scim.read", "scim.write")
+	s.AddOrgQuota(DefaultQuotaName)
+	s.AddIsolationSegment(SharedIsolationSegmentName)
+
+	r := mux.NewRouter()
+	s.handleUAA(r)
+	s.handleV2(r)
+	s.handleV3(r)
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, "/v3/") {
+			writeV3Error(w, http.StatusNotFound, 10010, "CF-ResourceNotFound", "Unknown request")
+			return
+		}
+		writeV2Error(w, http.StatusNotFound, 10000, "CF-NotFound", "Unknown request")
+	})
+	s.router = r
+	return s
+}
+
+// ServeHTTP serves the Cloud Controller and UAA APIs
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.router.ServeHTTP(w, req)
+}
+
+// AddClient adds a UAA client with the given authorities
+func (s *Server) AddClient(id, secret string, authorities ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients = append(s.clients, &Client{ID: id, Secret: secret, Authorities: authorities})
+}
+
+// AddOrgQuota adds an org quota, returning its GUID
+func (s *Server) AddOrgQuota(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := &Quota{GUID: newGUID(), Name: name}
+	s.quotas = append(s.quotas, q)
+	return q.GUID
+}
+
+// AddIsolationSegment adds an isolation segment, returning its GUID
+func (s *Server) AddIsolationSegment(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := &IsolationSegment{GUID: newGUID(), Name: name}
+	s.isolationSegments = append(s.isolationSegments, i)
+	return i.GUID
+}
+
+// AddSecurityGroup adds an application security group, returning its GUID
+func (s *Server) AddSecurityGroup(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := &SecurityGroup{GUID: newGUID(), Name: name}
+	s.securityGroups = append(s.securityGroups, g)
+	return g.GUID
+}
+
+// AddService adds a marketplace service with the given plans, returning the
+// service's GUID
+func (s *Server) AddService(label string, plans ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc := &Service{GUID: newGUID(), Label: label}
+	s.services = append(s.services, svc)
+	for _, name := range plans {
+		s.plans = append(s.plans, &ServicePlan{GUID: newGUID(), Name: name, ServiceGUID: svc.GUID})
+	}
+	return svc.GUID
+}
+
+// AddUser adds a UAA user, returning its ID
+func (s *Server) AddUser(userName, origin, email string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := &User{ID: newGUID(), UserName: userName, Origin: origin, Email: email}
+	s.users = append(s.users, u)
+	return u.ID
+}
+
+// AddApp adds a started app to the space, returning its GUID
+func (s *Server) AddApp(name, spaceGUID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	a := &App{GUID: newGUID(), Name: name, SpaceGUID: spaceGUID, State: "STARTED", CreatedAt: now, UpdatedAt: now}
+	s.apps = append(s.apps, a)
+	return a.GUID
+}
+
+// AddRoute adds a route to the space, returning its GUID
+func (s *Server) AddRoute(host, spaceGUID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &Route{GUID: newGUID(), Host: host, SpaceGUID: spaceGUID}
+	s.routes = append(s.routes, r)
+	return r.GUID
+}
+
+// Orgs returns the foundation's orgs
+func (s *Server) Orgs() []Org {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]Org, len(s.orgs))
+	for i := range s.orgs {
+		result[i] = *s.orgs[i]
+	}
+	return result
+}
+
+// Spaces returns the foundation's spaces
+func (s *Server) Spaces() []Space {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]Space, len(s.spaces))
+	for i := range s.spaces {
+		result[i] = *s.spaces[i]
+	}
+	return result
+}
+
+// Roles returns the roles that users hold in the foundation's orgs and
+// spaces
+func (s *Server) Roles() []Role {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]Role, len(s.roles))
+	for i := range s.roles {
+		result[i] = *s.roles[i]
+	}
+	return result
+}
+
+// SpaceQuotas returns the foundation's space quotas
+func (s *Server) SpaceQuotas() []SpaceQuota {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]SpaceQuota, len(s.spaceQuotas))
+	for i := range s.spaceQuotas {
+		result[i] = *s.spaceQuotas[i]
+	}
+	return result
+}
+
+// SecurityGroups returns the foundation's security groups
+func (s *Server) SecurityGroups() []SecurityGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]SecurityGroup, len(s.securityGroups))
+	for i := range s.securityGroups {
+		result[i] = *s.securityGroups[i]
+	}
+	return result
+}
+
+// ServiceInstances returns the foundation's service instances
+func (s *Server) ServiceInstances() []ServiceInstance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]ServiceInstance, len(s.serviceInstances))
+	for i := range s.serviceInstances {
+		result[i] = *s.serviceInstances[i]
+	}
+	return result
+}
+
+// Users returns the UAA's users
+func (s *Server) Users() []User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]User, len(s.users))
+	for i := range s.users {
+		result[i] = *s.users[i]
+	}
+	return result
+}
+
+// authenticate returns the client that the request's bearer token was issued
+// to, or nil if the token is missing or unknown; s.mu must be held
+func (s *Server) authenticate(req *http.Request) *Client {
+	auth := req.Header.Get("Authorization")
+	if len(auth) < len("bearer ") || !strings.EqualFold(auth[:len("bearer ")], "bearer ") {
+		return nil
+	}
+	return s.tokens[strings.TrimSpace(auth[len("bearer "):])]
+}
+
+func hasAuthority(c *Client, authority string) bool {
+	for _, a := range c.Authorities {
+		if a == authority {
+			return true
+		}
+	}
+	return false
+}
+
+// The lookups below must be called with s.mu held
+
+func (s *Server) org(guid string) *Org {
+	for _, o := range s.orgs {
+		if o.GUID == guid {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) space(guid string) *Space {
+	for _, sp := range s.spaces {
+		if sp.GUID == guid {
+			return sp
+		}
+	}
+	return nil
+}
+
+func (s *Server) quota(guid string) *Quota {
+	for _, q := range s.quotas {
+		if q.GUID == guid {
+			return q
+		}
+	}
+	return nil
+}
+
+func (s *Server) isolationSegment(guid string) *IsolationSegment {
+	for _, i := range s.isolationSegments {
+		if i.GUID == guid {
+			return i
+		}
+	}
+	return nil
+}
+
+func (s *Server) securityGroup(guid string) *SecurityGroup {
+	for _, g := range s.securityGroups {
+		if g.GUID == guid {
+			return g
+		}
+	}
+	return nil
+}
+
+func (s *Server) plan(guid string) *ServicePlan {
+	for _, p := range s.plans {
+		if p.GUID == guid {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *Server) user(id string) *User {
+	for _, u := range s.users {
+		if u.ID == id {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Server) orgNamed(name string) *Org {
+	for _, o := range s.orgs {
+		if o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+// spaceOrg returns the GUID of the org that the space is in
+func (s *Server) spaceOrg(spaceGUID string) string {
+	if sp := s.space(spaceGUID); sp != nil {
+		return sp.OrgGUID
+	}
+	return ""
+}
+
+func (s *Server) hasRole(roleType, userGUID, orgGUID, spaceGUID string) bool {
+	for _, r := range s.roles {
+		if r.Type == roleType && r.UserGUID == userGUID && r.OrgGUID == orgGUID && r.SpaceGUID == spaceGUID {
+			return true
+		}
+	}
+	return false
+}
+
+// inOrg returns true when the user holds any role in the org
+func (s *Server) inOrg(userGUID, orgGUID string) bool {
+	for _, r := range s.roles {
+		if r.UserGUID == userGUID && r.OrgGUID == orgGUID && r.SpaceGUID == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// grant gives the user the role, if they do not already hold it
+func (s *Server) grant(roleType, userGUID, orgGUID, spaceGUID string) *Role {
+	for _, r := range s.roles {
+		if r.Type == roleType && r.UserGUID == userGUID && r.OrgGUID == orgGUID && r.SpaceGUID == spaceGUID {
+			return r
+		}
+	}
+	r := &Role{GUID: newGUID(), Type: roleType, UserGUID: userGUID, OrgGUID: orgGUID, SpaceGUID: spaceGUID}
+	s.roles = append(s.roles, r)
+	return r
+}
+
+// deleteSpace deletes the space and everything in it
+func (s *Server) deleteSpace(guid string) {
+	var apps []*App
+	for _, a := range s.apps {
+		if a.SpaceGUID != guid {
+			apps = append(apps, a)
+		}
+	}
+	s.apps = apps
+	var routes []*Route
+	for _, r := range s.routes {
+		if r.SpaceGUID != guid {
+			routes = append(routes, r)
+		}
+	}
+	s.routes = routes
+	var instances []*ServiceInstance
+	for _, i := range s.serviceInstances {
+		if i.SpaceGUID != guid {
+			instances = append(instances, i)
+		}
+	}
+	s.serviceInstances = instances
+	var roles []*Role
+	for _, r := range s.roles {
+		if r.SpaceGUID != guid {
+			roles = append(roles, r)
+		}
+	}
+	s.roles = roles
+	for _, g := range s.securityGroups {
+		g.RunningSpaceGUIDs = without(g.RunningSpaceGUIDs, guid)
+		g.StagingSpaceGUIDs = without(g.StagingSpaceGUIDs, guid)
+	}
+	var spaces []*Space
+	for _, sp := range s.spaces {
+		if sp.GUID != guid {
+			spaces = append(spaces, sp)
+		}
+	}
+	s.spaces = spaces
+}
+
+// spaceIsEmpty returns true when the space has no apps, routes, or service
+// instances
+func (s *Server) spaceIsEmpty(guid string) bool {
+	for _, a := range s.apps {
+		if a.SpaceGUID == guid {
+			return false
+		}
+	}
+	for _, r := range s.routes {
+		if r.SpaceGUID == guid {
+			return false
+		}
+	}
+	for _, i := range s.serviceInstances {
+		if i.SpaceGUID == guid {
+			return false
+		}
+	}
+	return true
+}
+
+// orgIsEmpty returns true when the org has no spaces
+func (s *Server) orgIsEmpty(guid string) bool {
+	for _, sp := range s.spaces {
+		if sp.OrgGUID == guid {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteOrg deletes the org and everything in it
+func (s *Server) deleteOrg(guid string) {
+	for _, sp := range s.spaces {
+		if sp.OrgGUID == guid {
+			s.deleteSpace(sp.GUID)
+		}
+	}
+	var quotas []*SpaceQuota
+	for _, q := range s.spaceQuotas {
+		if q.OrgGUID != guid {
+			quotas = append(quotas, q)
+		}
+	}
+	s.spaceQuotas = quotas
+	var roles []*Role
+	for _, r := range s.roles {
+		if r.OrgGUID != guid {
+			roles = append(roles, r)
+		}
+	}
+	s.roles = roles
+	var orgs []*Org
+	for _, o := range s.orgs {
+		if o.GUID != guid {
+			orgs = append(orgs, o)
+		}
+	}
+	s.orgs = orgs
+}
+
+func without(guids []string, guid string) []string {
+	var result []string
+	for _, g := range guids {
+		if g != guid {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+func contains(guids []string, guid string) bool {
+	for _, g := range guids {
+		if g == guid {
+			return true
+		}
+	}
+	return false
+}
+
+func newGUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(errors.Wrap(err, "could not generate a guid"))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
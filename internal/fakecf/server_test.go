@@ -0,0 +1,294 @@
+package fakecf_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/api"
+	"github.com/pivotalservices/ignition/cloudfoundry"
+	"github.com/pivotalservices/ignition/config"
+	"github.com/pivotalservices/ignition/internal/fakecf"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestServer(t *testing.T) {
+	spec.Run(t, "Server", testServer, spec.Report(report.Terminal{}))
+}
+
+func testServer(t *testing.T, when spec.G, it spec.S) {
+	var (
+		f *fakecf.Server
+		s *httptest.Server
+		d *config.Deployment
+	)
+
+	deployment := func(clientSecret string) *config.Deployment {
+		os.Setenv("IGNITION_SYSTEM_DOMAIN", s.URL)
+		os.Setenv("IGNITION_UAA_ORIGIN", "ignition-sso")
+		os.Setenv("IGNITION_API_CLIENT_ID", "ignition")
+		os.Setenv("IGNITION_API_CLIENT_SECRET", clientSecret)
+		d, err := config.NewDeployment("ignition-config")
+		Expect(err).NotTo(HaveOccurred())
+		return d
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		f = fakecf.New("ignition", "ignition-secret")
+		s = httptest.NewServer(f)
+		d = deployment("ignition-secret")
+	})
+
+	it.After(func() {
+		s.Close()
+		for _, name := range []string{"IGNITION_SYSTEM_DOMAIN", "IGNITION_UAA_ORIGIN", "IGNITION_API_CLIENT_ID", "IGNITION_API_CLIENT_SECRET", "IGNITION_CC_API_VERSION"} {
+			os.Unsetenv(name)
+		}
+	})
+
+	when("using the UAA", func() {
+		it("finds users by account name", func() {
+			id := f.AddUser("developer@example.net", "ignition-sso", "developer@example.net")
+			userID, err := d.UAA.UserIDForAccountName("developer@example.net")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(userID).To(Equal(id))
+
+			_, err = d.UAA.UserIDForAccountName("someone-else@example.net")
+			Expect(err).To(HaveOccurred())
+		})
+
+		it("creates users, rejecting duplicates", func() {
+			id, err := d.UAA.CreateUser("developer@example.net", "ignition-sso", "developer-subject", "developer@example.net")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.Users()).To(ConsistOf(fakecf.User{
+				ID:         id,
+				UserName:   "developer@example.net",
+				Origin:     "ignition-sso",
+				ExternalID: "developer-subject",
+				Email:      "developer@example.net",
+			}))
+
+			_, err = d.UAA.CreateUser("developer@example.net", "ignition-sso", "developer-subject", "developer@example.net")
+			Expect(err).To(HaveOccurred())
+		})
+
+		it("does not issue tokens to clients with the wrong secret", func() {
+			d = deployment("wrong-secret")
+			_, err := d.UAA.UserIDForAccountName("developer@example.net")
+			Expect(err).To(HaveOccurred())
+			_, err = cloudfoundry.QuotaIDForName(fakecf.DefaultQuotaName, d.CC)
+			Expect(err).To(HaveOccurred())
+		})
+
+		it("does not issue tokens with scopes the client does not have", func() {
+			f.AddClient("reader", "reader-secret", "scim.read")
+			config := d.Config()
+			config.ClientID, config.ClientSecret = "reader", "reader-secret"
+			_, err := config.Token(context.Background())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	for _, version := range []string{config.CCAPIVersion2, config.CCAPIVersion3} {
+		version := version
+		when("using the "+version+" Cloud Controller API", func() {
+			var userID, quotaID, isoSegmentID string
+
+			it.Before(func() {
+				os.Setenv("IGNITION_CC_API_VERSION", version)
+				d = deployment("ignition-secret")
+				userID = f.AddUser("developer@example.net", "ignition-sso", "developer@example.net")
+				f.AddOrgQuota("ignition")
+				var err error
+				quotaID, err = cloudfoundry.QuotaIDForName("ignition", d.CC)
+				Expect(err).NotTo(HaveOccurred())
+				isoSegmentID, err = cloudfoundry.ISOSegmentIDForName(fakecf.SharedIsolationSegmentName, d.CC)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("looks up quotas and isolation segments by name", func() {
+				Expect(quotaID).NotTo(BeEmpty())
+				Expect(isoSegmentID).NotTo(BeEmpty())
+				_, err := cloudfoundry.QuotaIDForName("unknown", d.CC)
+				Expect(err).To(HaveOccurred())
+			})
+
+			it("provisions an org that is found for the user", func() {
+				sg := f.AddSecurityGroup("public_networks")
+				f.AddService("p-mysql", "small")
+				template := cloudfoundry.DefaultOrgTemplate("playground")
+				template.Spaces[0].RunningSecurityGroups = []string{"public_networks"}
+				template.Spaces[0].Services = []cloudfoundry.ServiceInstanceTemplate{{Name: "db", Service: "p-mysql", Plan: "small"}}
+
+				org, err := api.CreateOrgForUser("ignition-developer", d.AppsURL, userID, quotaID, isoSegmentID, template, d.CC)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(org.Name).To(Equal("ignition-developer"))
+				Expect(org.QuotaDefinitionGUID).To(Equal(quotaID))
+
+				orgs := f.Orgs()
+				Expect(orgs).To(HaveLen(1))
+				Expect(orgs[0].DefaultIsolationSegmentGUID).To(Equal(isoSegmentID))
+				spaces := f.Spaces()
+				Expect(spaces).To(HaveLen(1))
+				Expect(spaces[0].Name).To(Equal("playground"))
+				var roles []string
+				for _, r := range f.Roles() {
+					Expect(r.UserGUID).To(Equal(userID))
+					roles = append(roles, r.Type)
+				}
+				Expect(roles).To(ConsistOf(
+					fakecf.RoleOrganizationUser, fakecf.RoleOrganizationManager, fakecf.RoleOrganizationAuditor,
+					fakecf.RoleSpaceManager, fakecf.RoleSpaceDeveloper, fakecf.RoleSpaceAuditor,
+				))
+				groups := f.SecurityGroups()
+				Expect(groups[0].GUID).To(Equal(sg))
+				Expect(groups[0].RunningSpaceGUIDs).To(ConsistOf(spaces[0].GUID))
+				instances := f.ServiceInstances()
+				Expect(instances).To(HaveLen(1))
+				Expect(instances[0].Name).To(Equal("db"))
+
+				found, err := api.FindOrgForUser("ignition-developer", d.AppsURL, userID, quotaID, d.CC)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found.GUID).To(Equal(org.GUID))
+			})
+
+			it("does not find orgs for other users", func() {
+				_, err := api.CreateOrgForUser("ignition-developer", d.AppsURL, userID, quotaID, isoSegmentID, cloudfoundry.DefaultOrgTemplate("playground"), d.CC)
+				Expect(err).NotTo(HaveOccurred())
+				otherID := f.AddUser("other@example.net", "ignition-sso", "other@example.net")
+				orgs, err := cloudfoundry.OrgsForUserID(otherID, d.AppsURL, d.CC)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(orgs).To(BeEmpty())
+			})
+
+			it("rejects orgs with names that are taken", func() {
+				_, err := cloudfoundry.CreateOrgWithQuota("ignition-developer", d.AppsURL, quotaID, d.CC)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = cloudfoundry.CreateOrgWithQuota("ignition-developer", d.AppsURL, quotaID, d.CC)
+				Expect(cloudfoundry.IsOrgNameTakenError(err)).To(BeTrue())
+			})
+
+			it("tears down orgs and everything in them", func() {
+				org, err := api.CreateOrgForUser("ignition-developer", d.AppsURL, userID, quotaID, isoSegmentID, cloudfoundry.DefaultOrgTemplate("playground"), d.CC)
+				Expect(err).NotTo(HaveOccurred())
+				space := f.Spaces()[0].GUID
+				f.AddApp("app", space)
+				f.AddRoute("app", space)
+
+				Expect(cloudfoundry.TearDownOrg(org.GUID, d.CC)).To(Succeed())
+				Expect(f.Orgs()).To(BeEmpty())
+				Expect(f.Spaces()).To(BeEmpty())
+				Expect(f.Roles()).To(BeEmpty())
+			})
+		})
+	}
+
+	when("using the v2 Cloud Controller API", func() {
+		it("rejects unsupported filters", func() {
+			_, err := d.CC.(*cloudfoundry.V2Client).ListOrgsByQuery(url.Values{"q": {"unsupported:value"}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	when("using the v3 API", func() {
+		var client *http.Client
+
+		do := func(method, path string, body interface{}, result interface{}) int {
+			var b bytes.Buffer
+			if body != nil {
+				Expect(json.NewEncoder(&b).Encode(body)).To(Succeed())
+			}
+			req, err := http.NewRequest(method, s.URL+path, &b)
+			Expect(err).NotTo(HaveOccurred())
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			if result != nil {
+				Expect(json.NewDecoder(resp.Body).Decode(result)).To(Succeed())
+			}
+			return resp.StatusCode
+		}
+
+		it.Before(func() {
+			client = d.Config().Client(context.Background())
+		})
+
+		it("requires a token", func() {
+			resp, err := http.Get(s.URL + "/v3/organizations")
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		})
+
+		it("grants space roles only to org members", func() {
+			userID := f.AddUser("developer@example.net", "ignition-sso", "developer@example.net")
+			var org, space struct{ GUID string }
+			Expect(do(http.MethodPost, "/v3/organizations", map[string]interface{}{"name": "ignition-developer"}, &org)).To(Equal(http.StatusCreated))
+			Expect(do(http.MethodPost, "/v3/spaces", map[string]interface{}{
+				"name":          "playground",
+				"relationships": map[string]interface{}{"organization": map[string]interface{}{"data": map[string]string{"guid": org.GUID}}},
+			}, &space)).To(Equal(http.StatusCreated))
+
+			spaceRole := map[string]interface{}{
+				"type": fakecf.RoleSpaceDeveloper,
+				"relationships": map[string]interface{}{
+					"user":  map[string]interface{}{"data": map[string]string{"guid": userID}},
+					"space": map[string]interface{}{"data": map[string]string{"guid": space.GUID}},
+				},
+			}
+			Expect(do(http.MethodPost, "/v3/roles", spaceRole, nil)).To(Equal(http.StatusUnprocessableEntity))
+			Expect(do(http.MethodPost, "/v3/roles", map[string]interface{}{
+				"type": fakecf.RoleOrganizationUser,
+				"relationships": map[string]interface{}{
+					"user":         map[string]interface{}{"data": map[string]string{"username": "developer@example.net", "origin": "ignition-sso"}},
+					"organization": map[string]interface{}{"data": map[string]string{"guid": org.GUID}},
+				},
+			}, nil)).To(Equal(http.StatusCreated))
+			Expect(do(http.MethodPost, "/v3/roles", spaceRole, nil)).To(Equal(http.StatusCreated))
+
+			var roles struct {
+				Resources []struct{ Type string }
+			}
+			Expect(do(http.MethodGet, "/v3/roles?user_guids="+userID+"&organization_guids="+org.GUID, nil, &roles)).To(Equal(http.StatusOK))
+			Expect(roles.Resources).To(HaveLen(1))
+			Expect(roles.Resources[0].Type).To(Equal(fakecf.RoleOrganizationUser))
+		})
+
+		it("sets the default isolation segment only once it is entitled", func() {
+			var org struct{ GUID string }
+			Expect(do(http.MethodPost, "/v3/organizations", map[string]interface{}{"name": "ignition-developer"}, &org)).To(Equal(http.StatusCreated))
+			var segments struct {
+				Resources []struct{ GUID string }
+			}
+			Expect(do(http.MethodGet, "/v3/isolation_segments?names="+fakecf.SharedIsolationSegmentName, nil, &segments)).To(Equal(http.StatusOK))
+			Expect(segments.Resources).To(HaveLen(1))
+			segment := map[string]interface{}{"data": map[string]string{"guid": segments.Resources[0].GUID}}
+
+			path := "/v3/organizations/" + org.GUID + "/relationships/default_isolation_segment"
+			Expect(do(http.MethodPatch, path, segment, nil)).To(Equal(http.StatusUnprocessableEntity))
+			Expect(do(http.MethodPost, "/v3/isolation_segments/"+segments.Resources[0].GUID+"/relationships/organizations", map[string]interface{}{
+				"data": []map[string]string{{"guid": org.GUID}},
+			}, nil)).To(Equal(http.StatusOK))
+			Expect(do(http.MethodPatch, path, segment, nil)).To(Equal(http.StatusOK))
+		})
+
+		it("rejects unknown query parameters", func() {
+			Expect(do(http.MethodGet, "/v3/organizations?user_guids=abc", nil, nil)).To(Equal(http.StatusBadRequest))
+		})
+
+		it("deletes orgs asynchronously", func() {
+			var org struct{ GUID string }
+			Expect(do(http.MethodPost, "/v3/organizations", map[string]interface{}{"name": "ignition-developer"}, &org)).To(Equal(http.StatusCreated))
+			Expect(do(http.MethodDelete, "/v3/organizations/"+org.GUID, nil, nil)).To(Equal(http.StatusAccepted))
+			Expect(f.Orgs()).To(BeEmpty())
+		})
+	})
+}
@@ -0,0 +1,230 @@
+package fakecf
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// scimCondition is one attribute comparison of a SCIM filter, e.g.
+// userName eq "developer"
+var scimCondition = regexp.MustCompile(`(?i)^\s*(\w+)\s+eq\s+"([^"]*)"\s*$`)
+
+// scimAnd separates the conditions of a SCIM filter
+var scimAnd = regexp.MustCompile(`(?i)\s+and\s+`)
+
+// scimUser is a user as it is represented by the UAA's SCIM API
+type scimUser struct {
+	ID         string      `json:"id,omitempty"`
+	UserName   string      `json:"userName"`
+	Origin     string      `json:"origin,omitempty"`
+	ExternalID string      `json:"externalId,omitempty"`
+	Emails     []scimEmail `json:"emails,omitempty"`
+	Active     bool        `json:"active"`
+	Schemas    []string    `json:"schemas,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary"`
+}
+
+func (s *Server) handleUAA(r *mux.Router) {
+	r.HandleFunc("/oauth/token", s.token).Methods(http.MethodPost)
+	r.HandleFunc("/Users", s.scim("scim.read", s.listUsers)).Methods(http.MethodGet)
+	r.HandleFunc("/Users", s.scim("scim.write", s.createUser)).Methods(http.MethodPost)
+	r.HandleFunc("/Users/{id}", s.scim("scim.read", s.getUser)).Methods(http.MethodGet)
+	r.HandleFunc("/Users/{id}", s.scim("scim.write", s.deleteUser)).Methods(http.MethodDelete)
+}
+
+// token issues access tokens to clients with the client credentials grant
+func (s *Server) token(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	id, secret, ok := req.BasicAuth()
+	if !ok {
+		id, secret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+	if req.PostForm.Get("grant_type") != "client_credentials" {
+		uaaError(w, http.StatusBadRequest, "unsupported_grant_type", "only the client_credentials grant is supported")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var client *Client
+	for _, c := range s.clients {
+		if c.ID == id && c.Secret == secret {
+			client = c
+		}
+	}
+	if client == nil {
+		uaaError(w, http.StatusUnauthorized, "unauthorized", "Bad credentials")
+		return
+	}
+	scopes := client.Authorities
+	if requested := strings.Fields(req.PostForm.Get("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !hasAuthority(client, scope) {
+				uaaError(w, http.StatusBadRequest, "invalid_scope", "Invalid scope: "+scope+". Did you know that you can get default scopes by simply sending no value?")
+				return
+			}
+		}
+		scopes = requested
+	}
+	token := newGUID()
+	s.tokens[token] = client
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "bearer",
+		"expires_in":   43199,
+		"scope":        strings.Join(scopes, " "),
+		"jti":          token,
+	})
+}
+
+// scim ensures the request's token has the authority before handling it;
+// s.mu is held while next handles the request
+func (s *Server) scim(authority string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		c := s.authenticate(req)
+		if c == nil {
+			uaaError(w, http.StatusUnauthorized, "invalid_token", "the access token is missing or invalid")
+			return
+		}
+		if !hasAuthority(c, authority) {
+			uaaError(w, http.StatusForbidden, "insufficient_scope", "Insufficient scope for this resource")
+			return
+		}
+		next(w, req)
+	}
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, req *http.Request) {
+	var conditions [][]string
+	if filter := req.URL.Query().Get("filter"); filter != "" {
+		for _, c := range scimAnd.Split(filter, -1) {
+			m := scimCondition.FindStringSubmatch(c)
+			if m == nil {
+				uaaError(w, http.StatusBadRequest, "invalid_filter", "Invalid filter expression: ["+filter+"]")
+				return
+			}
+			switch strings.ToLower(m[1]) {
+			case "username", "origin", "id", "externalid", "email":
+			default:
+				uaaError(w, http.StatusBadRequest, "invalid_filter", "Invalid filter attribute: ["+m[1]+"]")
+				return
+			}
+			conditions = append(conditions, []string{strings.ToLower(m[1]), m[2]})
+		}
+	}
+
+	resources := []scimUser{}
+	for _, u := range s.users {
+		match := true
+		for _, c := range conditions {
+			var value string
+			switch c[0] {
+			case "username":
+				value = u.UserName
+			case "origin":
+				value = u.Origin
+			case "id":
+				value = u.ID
+			case "externalid":
+				value = u.ExternalID
+			case "email":
+				value = u.Email
+			}
+			// SCIM attribute values are compared case insensitively
+			if !strings.EqualFold(value, c[1]) {
+				match = false
+			}
+		}
+		if match {
+			resources = append(resources, toSCIM(u))
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resources":    resources,
+		"startIndex":   1,
+		"itemsPerPage": len(resources),
+		"totalResults": len(resources),
+		"schemas":      []string{"urn:scim:schemas:core:1.0"},
+	})
+}
+
+func (s *Server) createUser(w http.ResponseWriter, req *http.Request) {
+	var u scimUser
+	err := json.NewDecoder(req.Body).Decode(&u)
+	if err != nil || strings.TrimSpace(u.UserName) == "" {
+		uaaError(w, http.StatusBadRequest, "invalid_scim_resource", "A username must be provided.")
+		return
+	}
+	if u.Origin == "" {
+		u.Origin = "uaa"
+	}
+	for _, existing := range s.users {
+		if strings.EqualFold(existing.UserName, u.UserName) && existing.Origin == u.Origin {
+			uaaError(w, http.StatusConflict, "scim_resource_already_exists", "Username already in use: "+u.UserName)
+			return
+		}
+	}
+	user := &User{ID: newGUID(), UserName: u.UserName, Origin: u.Origin, ExternalID: u.ExternalID}
+	if len(u.Emails) > 0 {
+		user.Email = u.Emails[0].Value
+	}
+	s.users = append(s.users, user)
+	writeJSON(w, http.StatusCreated, toSCIM(user))
+}
+
+func (s *Server) getUser(w http.ResponseWriter, req *http.Request) {
+	u := s.user(mux.Vars(req)["id"])
+	if u == nil {
+		uaaError(w, http.StatusNotFound, "scim_resource_not_found", "User "+mux.Vars(req)["id"]+" does not exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, toSCIM(u))
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, req *http.Request) {
+	u := s.user(mux.Vars(req)["id"])
+	if u == nil {
+		uaaError(w, http.StatusNotFound, "scim_resource_not_found", "User "+mux.Vars(req)["id"]+" does not exist")
+		return
+	}
+	var users []*User
+	for _, existing := range s.users {
+		if existing != u {
+			users = append(users, existing)
+		}
+	}
+	s.users = users
+	writeJSON(w, http.StatusOK, toSCIM(u))
+}
+
+func toSCIM(u *User) scimUser {
+	result := scimUser{
+		ID:         u.ID,
+		UserName:   u.UserName,
+		Origin:     u.Origin,
+		ExternalID: u.ExternalID,
+		Active:     true,
+		Schemas:    []string{"urn:scim:schemas:core:1.0"},
+	}
+	if u.Email != "" {
+		result.Emails = []scimEmail{{Value: u.Email, Primary: true}}
+	}
+	return result
+}
+
+func uaaError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
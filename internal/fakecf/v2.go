@@ -0,0 +1,952 @@
+package fakecf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// v2Filter is a q filter of a v2 list request, e.g. name:ignition-developer or
+// name IN a,b
+type v2Filter struct {
+	field  string
+	values []string
+}
+
+// v2OrgRoles are the org roles that can be listed and associated with the v2
+// API, by the name of their path segment
+var v2OrgRoles = map[string]string{
+	"users":    RoleOrganizationUser,
+	"managers": RoleOrganizationManager,
+	"auditors": RoleOrganizationAuditor,
+}
+
+func (s *Server) handleV2(r *mux.Router) {
+	r.HandleFunc("/v2/info", s.info).Methods(http.MethodGet)
+
+	r.HandleFunc("/v2/organizations", s.cc(s.listOrgsV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/organizations", s.cc(s.createOrgV2)).Methods(http.MethodPost)
+	r.HandleFunc("/v2/organizations/{guid}", s.cc(s.getOrgV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/organizations/{guid}", s.cc(s.updateOrgV2)).Methods(http.MethodPut)
+	r.HandleFunc("/v2/organizations/{guid}", s.cc(s.deleteOrgV2)).Methods(http.MethodDelete)
+	r.HandleFunc("/v2/organizations/{guid}/{role:users|managers|auditors}", s.cc(s.listOrgRoleV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/organizations/{guid}/{role:users|managers|auditors}/{user}", s.cc(s.associateOrgRoleV2)).Methods(http.MethodPut)
+	r.HandleFunc("/v2/organizations/{guid}/{role:users|managers|auditors}/{user}", s.cc(s.removeOrgRoleV2)).Methods(http.MethodDelete)
+
+	r.HandleFunc("/v2/quota_definitions", s.cc(s.listQuotasV2)).Methods(http.MethodGet)
+
+	r.HandleFunc("/v2/spaces", s.cc(s.listSpacesV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/spaces", s.cc(s.createSpaceV2)).Methods(http.MethodPost)
+	r.HandleFunc("/v2/spaces/{guid}", s.cc(s.deleteSpaceV2)).Methods(http.MethodDelete)
+
+	r.HandleFunc("/v2/space_quota_definitions", s.cc(s.listSpaceQuotasV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/space_quota_definitions", s.cc(s.createSpaceQuotaV2)).Methods(http.MethodPost)
+
+	r.HandleFunc("/v2/security_groups", s.cc(s.listSecurityGroupsV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/security_groups/{guid}/{lifecycle:spaces|staging_spaces}/{space}", s.cc(s.bindSecurityGroupV2)).Methods(http.MethodPut)
+
+	r.HandleFunc("/v2/services", s.cc(s.listServicesV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/service_plans", s.cc(s.listServicePlansV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/service_instances", s.cc(s.listServiceInstancesV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/service_instances", s.cc(s.createServiceInstanceV2)).Methods(http.MethodPost)
+	r.HandleFunc("/v2/service_instances/{guid}", s.cc(s.deleteServiceInstanceV2)).Methods(http.MethodDelete)
+
+	r.HandleFunc("/v2/apps", s.cc(s.listAppsV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/apps/{guid}", s.cc(s.deleteAppV2)).Methods(http.MethodDelete)
+	r.HandleFunc("/v2/routes", s.cc(s.listRoutesV2)).Methods(http.MethodGet)
+	r.HandleFunc("/v2/routes/{guid}", s.cc(s.deleteRouteV2)).Methods(http.MethodDelete)
+}
+
+// info describes the Cloud Controller, and where its UAA is
+func (s *Server) info(w http.ResponseWriter, req *http.Request) {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	base := fmt.Sprintf("%s://%s", scheme, req.Host)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":                   "fakecf",
+		"api_version":            "2.128.0",
+		"authorization_endpoint": base,
+		"token_endpoint":         base,
+	})
+}
+
+// cc ensures the request's token was issued to a Cloud Controller admin
+// before handling it; s.mu is held while next handles the request
+func (s *Server) cc(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		v3 := strings.HasPrefix(req.URL.Path, "/v3/")
+		c := s.authenticate(req)
+		if c == nil {
+			if v3 {
+				writeV3Error(w, http.StatusUnauthorized, 10002, "CF-NotAuthenticated", "Authentication error")
+				return
+			}
+			writeV2Error(w, http.StatusUnauthorized, 1000, "CF-InvalidAuthToken", "Invalid Auth Token")
+			return
+		}
+		if !hasAuthority(c, "cloud_controller.admin") {
+			if v3 {
+				writeV3Error(w, http.StatusForbidden, 10003, "CF-NotAuthorized", "You are not authorized to perform the requested action")
+				return
+			}
+			writeV2Error(w, http.StatusForbidden, 10003, "CF-NotAuthorized", "You are not authorized to perform the requested action")
+			return
+		}
+		next(w, req)
+	}
+}
+
+// parseV2Filters parses the request's q filters, returning an error when a
+// filter is malformed or is not one of the allowed fields
+func parseV2Filters(req *http.Request, allowed ...string) ([]v2Filter, error) {
+	var filters []v2Filter
+	for _, q := range req.URL.Query()["q"] {
+		var f v2Filter
+		if i := strings.Index(q, " IN "); i > 0 && (strings.Index(q, ":") < 0 || strings.Index(q, ":") > i) {
+			f = v2Filter{field: q[:i], values: strings.Split(q[i+len(" IN "):], ",")}
+		} else if i := strings.Index(q, ":"); i > 0 {
+			f = v2Filter{field: q[:i], values: []string{q[i+1:]}}
+		} else {
+			return nil, fmt.Errorf("The query parameter is invalid: %s", q)
+		}
+		if !contains(allowed, f.field) {
+			return nil, fmt.Errorf("The query parameter is invalid: %s is not a valid filter", f.field)
+		}
+		filters = append(filters, f)
+	}
+	for k := range req.URL.Query() {
+		switch k {
+		case "q", "page", "results-per-page", "order-direction", "order-by", "inline-relations-depth":
+		default:
+			return nil, fmt.Errorf("The query parameter is invalid: %s", k)
+		}
+	}
+	return filters, nil
+}
+
+// matchV2 returns true when the resource, whose fields have the given values,
+// matches every filter
+func matchV2(filters []v2Filter, fields map[string][]string) bool {
+	for _, f := range filters {
+		match := false
+		for _, v := range fields[f.field] {
+			if contains(f.values, v) {
+				match = true
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+func v2Resource(path, guid string, created, updated time.Time, entity map[string]interface{}) map[string]interface{} {
+	meta := map[string]interface{}{
+		"guid":       guid,
+		"url":        path + "/" + guid,
+		"created_at": nil,
+		"updated_at": nil,
+	}
+	if !created.IsZero() {
+		meta["created_at"] = timestamp(created)
+	}
+	if !updated.IsZero() {
+		meta["updated_at"] = timestamp(updated)
+	}
+	return map[string]interface{}{"metadata": meta, "entity": entity}
+}
+
+func writeV2List(w http.ResponseWriter, resources []map[string]interface{}) {
+	if resources == nil {
+		resources = []map[string]interface{}{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_results": len(resources),
+		"total_pages":   1,
+		"prev_url":      nil,
+		"next_url":      nil,
+		"resources":     resources,
+	})
+}
+
+func writeV2Error(w http.ResponseWriter, status int, code int, errorCode, description string) {
+	writeJSON(w, status, map[string]interface{}{
+		"code":        code,
+		"description": description,
+		"error_code":  errorCode,
+	})
+}
+
+func writeInvalidQuery(w http.ResponseWriter, err error) {
+	writeV2Error(w, http.StatusBadRequest, 1001, "CF-BadQueryParameter", err.Error())
+}
+
+// decode reads the request's JSON body into v, writing an error and returning
+// false when it cannot be read
+func decodeV2(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	err := json.NewDecoder(req.Body).Decode(v)
+	if err != nil {
+		writeV2Error(w, http.StatusBadRequest, 1001, "CF-MessageParseError", "Request invalid due to parse error: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func (s *Server) orgV2(o *Org) map[string]interface{} {
+	var isoSegment interface{}
+	if o.DefaultIsolationSegmentGUID != "" {
+		isoSegment = o.DefaultIsolationSegmentGUID
+	}
+	return v2Resource("/v2/organizations", o.GUID, o.CreatedAt, o.UpdatedAt, map[string]interface{}{
+		"name":                           o.Name,
+		"billing_enabled":                false,
+		"status":                         o.Status,
+		"quota_definition_guid":          o.QuotaGUID,
+		"default_isolation_segment_guid": isoSegment,
+		"spaces_url":                     "/v2/organizations/" + o.GUID + "/spaces",
+		"users_url":                      "/v2/organizations/" + o.GUID + "/users",
+		"managers_url":                   "/v2/organizations/" + o.GUID + "/managers",
+		"auditors_url":                   "/v2/organizations/" + o.GUID + "/auditors",
+	})
+}
+
+// orgFields are the values of the org's fields that it can be filtered by
+func (s *Server) orgFields(o *Org) map[string][]string {
+	fields := map[string][]string{
+		"name":                  {o.Name},
+		"status":                {o.Status},
+		"quota_definition_guid": {o.QuotaGUID},
+	}
+	for _, r := range s.roles {
+		if r.OrgGUID != o.GUID || r.SpaceGUID != "" {
+			continue
+		}
+		switch r.Type {
+		case RoleOrganizationUser:
+			fields["user_guid"] = append(fields["user_guid"], r.UserGUID)
+		case RoleOrganizationManager:
+			fields["manager_guid"] = append(fields["manager_guid"], r.UserGUID)
+		case RoleOrganizationAuditor:
+			fields["auditor_guid"] = append(fields["auditor_guid"], r.UserGUID)
+		}
+	}
+	return fields
+}
+
+func (s *Server) listOrgsV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "name", "status", "quota_definition_guid", "user_guid", "manager_guid", "auditor_guid")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, o := range s.orgs {
+		if matchV2(filters, s.orgFields(o)) {
+			resources = append(resources, s.orgV2(o))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+// orgRequest is the body of requests to create and update orgs
+type orgRequest struct {
+	Name                        string  `json:"name"`
+	Status                      string  `json:"status"`
+	QuotaDefinitionGUID         string  `json:"quota_definition_guid"`
+	DefaultIsolationSegmentGUID *string `json:"default_isolation_segment_guid"`
+}
+
+func (s *Server) createOrgV2(w http.ResponseWriter, req *http.Request) {
+	var body orgRequest
+	if !decodeV2(w, req, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		writeV2Error(w, http.StatusBadRequest, 30001, "CF-OrganizationInvalid", "The organization info is invalid: name presence")
+		return
+	}
+	if s.orgNamed(body.Name) != nil {
+		writeV2Error(w, http.StatusBadRequest, 30002, "CF-OrganizationNameTaken", "The organization name is taken: "+body.Name)
+		return
+	}
+	o := &Org{GUID: newGUID(), Name: body.Name, Status: "active", CreatedAt: s.now()}
+	if body.Status != "" {
+		o.Status = body.Status
+	}
+	if !s.setOrgQuota(w, o, body.QuotaDefinitionGUID) {
+		return
+	}
+	if body.DefaultIsolationSegmentGUID != nil && *body.DefaultIsolationSegmentGUID != "" {
+		writeV2Error(w, http.StatusBadRequest, 30010, "CF-InvalidRelation", "Could not find Isolation Segment to set as the default: "+*body.DefaultIsolationSegmentGUID)
+		return
+	}
+	s.orgs = append(s.orgs, o)
+	writeJSON(w, http.StatusCreated, s.orgV2(o))
+}
+
+// setOrgQuota assigns the quota, or the default quota when the guid is
+// empty, to the org
+func (s *Server) setOrgQuota(w http.ResponseWriter, o *Org, guid string) bool {
+	if guid == "" {
+		for _, q := range s.quotas {
+			if q.Name == DefaultQuotaName {
+				guid = q.GUID
+			}
+		}
+	}
+	if s.quota(guid) == nil {
+		writeV2Error(w, http.StatusBadRequest, 1002, "CF-InvalidRelation", "Could not find VCAP::CloudController::QuotaDefinition with guid: "+guid)
+		return false
+	}
+	o.QuotaGUID = guid
+	return true
+}
+
+func (s *Server) getOrgV2(w http.ResponseWriter, req *http.Request) {
+	o := s.org(mux.Vars(req)["guid"])
+	if o == nil {
+		writeV2Error(w, http.StatusNotFound, 30003, "CF-OrganizationNotFound", "The organization could not be found: "+mux.Vars(req)["guid"])
+		return
+	}
+	writeJSON(w, http.StatusOK, s.orgV2(o))
+}
+
+func (s *Server) updateOrgV2(w http.ResponseWriter, req *http.Request) {
+	o := s.org(mux.Vars(req)["guid"])
+	if o == nil {
+		writeV2Error(w, http.StatusNotFound, 30003, "CF-OrganizationNotFound", "The organization could not be found: "+mux.Vars(req)["guid"])
+		return
+	}
+	var body orgRequest
+	if !decodeV2(w, req, &body) {
+		return
+	}
+	if body.Name != "" && body.Name != o.Name {
+		if s.orgNamed(body.Name) != nil {
+			writeV2Error(w, http.StatusBadRequest, 30002, "CF-OrganizationNameTaken", "The organization name is taken: "+body.Name)
+			return
+		}
+	}
+	if body.QuotaDefinitionGUID != "" && s.quota(body.QuotaDefinitionGUID) == nil {
+		writeV2Error(w, http.StatusBadRequest, 1002, "CF-InvalidRelation", "Could not find VCAP::CloudController::QuotaDefinition with guid: "+body.QuotaDefinitionGUID)
+		return
+	}
+	if body.DefaultIsolationSegmentGUID != nil && *body.DefaultIsolationSegmentGUID != "" && !contains(o.IsolationSegmentGUIDs, *body.DefaultIsolationSegmentGUID) {
+		writeV2Error(w, http.StatusBadRequest, 30010, "CF-InvalidRelation", "Could not find Isolation Segment to set as the default: "+*body.DefaultIsolationSegmentGUID)
+		return
+	}
+	if body.Name != "" {
+		o.Name = body.Name
+	}
+	if body.Status != "" {
+		o.Status = body.Status
+	}
+	if body.QuotaDefinitionGUID != "" {
+		o.QuotaGUID = body.QuotaDefinitionGUID
+	}
+	if body.DefaultIsolationSegmentGUID != nil {
+		o.DefaultIsolationSegmentGUID = *body.DefaultIsolationSegmentGUID
+	}
+	o.UpdatedAt = s.now()
+	writeJSON(w, http.StatusCreated, s.orgV2(o))
+}
+
+func (s *Server) deleteOrgV2(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	if s.org(guid) == nil {
+		writeV2Error(w, http.StatusNotFound, 30003, "CF-OrganizationNotFound", "The organization could not be found: "+guid)
+		return
+	}
+	if req.URL.Query().Get("recursive") != "true" && !s.orgIsEmpty(guid) {
+		writeV2Error(w, http.StatusBadRequest, 10006, "CF-AssociationNotEmpty", "Please delete the spaces associations for your organizations.")
+		return
+	}
+	s.deleteOrg(guid)
+	writeDeleted(w, req)
+}
+
+// writeDeleted responds to a delete request; async deletions are accepted,
+// though the resource has already been deleted
+func writeDeleted(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Get("async") == "true" {
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"metadata": map[string]interface{}{"guid": newGUID()},
+			"entity":   map[string]interface{}{"status": "finished"},
+		})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) userV2(guid string) map[string]interface{} {
+	u := s.user(guid)
+	username := ""
+	if u != nil {
+		username = u.UserName
+	}
+	return v2Resource("/v2/users", guid, time.Time{}, time.Time{}, map[string]interface{}{
+		"admin":    false,
+		"active":   true,
+		"username": username,
+	})
+}
+
+func (s *Server) listOrgRoleV2(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	if s.org(guid) == nil {
+		writeV2Error(w, http.StatusNotFound, 30003, "CF-OrganizationNotFound", "The organization could not be found: "+guid)
+		return
+	}
+	roleType := v2OrgRoles[mux.Vars(req)["role"]]
+	var resources []map[string]interface{}
+	for _, r := range s.roles {
+		if r.Type == roleType && r.OrgGUID == guid && r.SpaceGUID == "" {
+			resources = append(resources, s.userV2(r.UserGUID))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+func (s *Server) associateOrgRoleV2(w http.ResponseWriter, req *http.Request) {
+	o := s.org(mux.Vars(req)["guid"])
+	if o == nil {
+		writeV2Error(w, http.StatusNotFound, 30003, "CF-OrganizationNotFound", "The organization could not be found: "+mux.Vars(req)["guid"])
+		return
+	}
+	userGUID := mux.Vars(req)["user"]
+	if s.user(userGUID) == nil {
+		writeV2Error(w, http.StatusBadRequest, 1002, "CF-InvalidRelation", "Could not find VCAP::CloudController::User with guid: "+userGUID)
+		return
+	}
+	s.grant(v2OrgRoles[mux.Vars(req)["role"]], userGUID, o.GUID, "")
+	writeJSON(w, http.StatusCreated, s.orgV2(o))
+}
+
+func (s *Server) removeOrgRoleV2(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	roleType := v2OrgRoles[mux.Vars(req)["role"]]
+	var roles []*Role
+	for _, r := range s.roles {
+		if !(r.Type == roleType && r.OrgGUID == guid && r.SpaceGUID == "" && r.UserGUID == mux.Vars(req)["user"]) {
+			roles = append(roles, r)
+		}
+	}
+	s.roles = roles
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listQuotasV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "name")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, q := range s.quotas {
+		if matchV2(filters, map[string][]string{"name": {q.Name}}) {
+			resources = append(resources, v2Resource("/v2/quota_definitions", q.GUID, time.Time{}, time.Time{}, map[string]interface{}{
+				"name":                       q.Name,
+				"non_basic_services_allowed": true,
+				"total_services":             -1,
+				"total_routes":               -1,
+				"memory_limit":               10240,
+				"instance_memory_limit":      -1,
+				"app_instance_limit":         -1,
+			}))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+func (s *Server) spaceV2(sp *Space) map[string]interface{} {
+	var quota interface{}
+	if sp.SpaceQuotaGUID != "" {
+		quota = sp.SpaceQuotaGUID
+	}
+	return v2Resource("/v2/spaces", sp.GUID, sp.CreatedAt, sp.UpdatedAt, map[string]interface{}{
+		"name":                        sp.Name,
+		"organization_guid":           sp.OrgGUID,
+		"organization_url":            "/v2/organizations/" + sp.OrgGUID,
+		"space_quota_definition_guid": quota,
+		"isolation_segment_guid":      nil,
+		"allow_ssh":                   sp.AllowSSH,
+	})
+}
+
+func (s *Server) spaceFields(sp *Space) map[string][]string {
+	fields := map[string][]string{
+		"name":              {sp.Name},
+		"organization_guid": {sp.OrgGUID},
+	}
+	for _, r := range s.roles {
+		if r.SpaceGUID != sp.GUID {
+			continue
+		}
+		switch r.Type {
+		case RoleSpaceDeveloper:
+			fields["developer_guid"] = append(fields["developer_guid"], r.UserGUID)
+		case RoleSpaceManager:
+			fields["manager_guid"] = append(fields["manager_guid"], r.UserGUID)
+		case RoleSpaceAuditor:
+			fields["auditor_guid"] = append(fields["auditor_guid"], r.UserGUID)
+		}
+	}
+	return fields
+}
+
+func (s *Server) listSpacesV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "name", "organization_guid", "developer_guid", "manager_guid", "auditor_guid")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, sp := range s.spaces {
+		if matchV2(filters, s.spaceFields(sp)) {
+			resources = append(resources, s.spaceV2(sp))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+// spaceRequest is the body of a request to create a space
+type spaceRequest struct {
+	Name                     string   `json:"name"`
+	OrganizationGUID         string   `json:"organization_guid"`
+	DeveloperGUIDs           []string `json:"developer_guids"`
+	ManagerGUIDs             []string `json:"manager_guids"`
+	AuditorGUIDs             []string `json:"auditor_guids"`
+	SpaceQuotaDefinitionGUID string   `json:"space_quota_definition_guid"`
+	AllowSSH                 bool     `json:"allow_ssh"`
+}
+
+func (s *Server) createSpaceV2(w http.ResponseWriter, req *http.Request) {
+	var body spaceRequest
+	if !decodeV2(w, req, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		writeV2Error(w, http.StatusBadRequest, 40001, "CF-SpaceInvalid", "The app space is invalid: name presence")
+		return
+	}
+	if s.org(body.OrganizationGUID) == nil {
+		writeV2Error(w, http.StatusBadRequest, 1002, "CF-InvalidRelation", "Could not find VCAP::CloudController::Organization with guid: "+body.OrganizationGUID)
+		return
+	}
+	for _, sp := range s.spaces {
+		if sp.OrgGUID == body.OrganizationGUID && sp.Name == body.Name {
+			writeV2Error(w, http.StatusBadRequest, 40002, "CF-SpaceNameTaken", "The app space name is taken: "+body.Name)
+			return
+		}
+	}
+	if body.SpaceQuotaDefinitionGUID != "" && !s.orgHasSpaceQuota(body.OrganizationGUID, body.SpaceQuotaDefinitionGUID) {
+		writeV2Error(w, http.StatusBadRequest, 1002, "CF-InvalidRelation", "Could not find VCAP::CloudController::SpaceQuotaDefinition with guid: "+body.SpaceQuotaDefinitionGUID)
+		return
+	}
+	roles := map[string][]string{
+		RoleSpaceDeveloper: body.DeveloperGUIDs,
+		RoleSpaceManager:   body.ManagerGUIDs,
+		RoleSpaceAuditor:   body.AuditorGUIDs,
+	}
+	for _, users := range roles {
+		for _, u := range users {
+			if !s.inOrg(u, body.OrganizationGUID) {
+				writeV2Error(w, http.StatusBadRequest, 1002, "CF-InvalidRelation", "Users cannot be assigned roles in a space if they are not a member of that space's organization: "+u)
+				return
+			}
+		}
+	}
+	now := s.now()
+	sp := &Space{
+		GUID:           newGUID(),
+		Name:           body.Name,
+		OrgGUID:        body.OrganizationGUID,
+		SpaceQuotaGUID: body.SpaceQuotaDefinitionGUID,
+		AllowSSH:       body.AllowSSH,
+		CreatedAt:      now,
+	}
+	s.spaces = append(s.spaces, sp)
+	for _, roleType := range []string{RoleSpaceManager, RoleSpaceDeveloper, RoleSpaceAuditor} {
+		for _, u := range roles[roleType] {
+			s.grant(roleType, u, sp.OrgGUID, sp.GUID)
+		}
+	}
+	writeJSON(w, http.StatusCreated, s.spaceV2(sp))
+}
+
+func (s *Server) orgHasSpaceQuota(orgGUID, guid string) bool {
+	for _, q := range s.spaceQuotas {
+		if q.GUID == guid && q.OrgGUID == orgGUID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) deleteSpaceV2(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	if s.space(guid) == nil {
+		writeV2Error(w, http.StatusNotFound, 40004, "CF-SpaceNotFound", "The app space could not be found: "+guid)
+		return
+	}
+	if req.URL.Query().Get("recursive") != "true" && !s.spaceIsEmpty(guid) {
+		writeV2Error(w, http.StatusBadRequest, 10006, "CF-AssociationNotEmpty", "Please delete the apps, routes, and service instances in the space.")
+		return
+	}
+	s.deleteSpace(guid)
+	writeDeleted(w, req)
+}
+
+func spaceQuotaV2(q *SpaceQuota) map[string]interface{} {
+	return v2Resource("/v2/space_quota_definitions", q.GUID, time.Time{}, time.Time{}, map[string]interface{}{
+		"name":                       q.Name,
+		"organization_guid":          q.OrgGUID,
+		"non_basic_services_allowed": q.NonBasicServices,
+		"total_services":             q.TotalServices,
+		"total_routes":               q.TotalRoutes,
+		"memory_limit":               q.MemoryLimit,
+		"instance_memory_limit":      q.InstanceMemoryLimit,
+		"app_instance_limit":         q.AppInstanceLimit,
+	})
+}
+
+func (s *Server) listSpaceQuotasV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "name", "organization_guid")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, q := range s.spaceQuotas {
+		if matchV2(filters, map[string][]string{"name": {q.Name}, "organization_guid": {q.OrgGUID}}) {
+			resources = append(resources, spaceQuotaV2(q))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+// spaceQuotaRequest is the body of a request to create a space quota
+type spaceQuotaRequest struct {
+	Name                    string `json:"name"`
+	OrganizationGUID        string `json:"organization_guid"`
+	NonBasicServicesAllowed bool   `json:"non_basic_services_allowed"`
+	TotalServices           int    `json:"total_services"`
+	TotalRoutes             int    `json:"total_routes"`
+	MemoryLimit             int    `json:"memory_limit"`
+	InstanceMemoryLimit     int    `json:"instance_memory_limit"`
+	AppInstanceLimit        int    `json:"app_instance_limit"`
+}
+
+func (s *Server) createSpaceQuotaV2(w http.ResponseWriter, req *http.Request) {
+	var body spaceQuotaRequest
+	if !decodeV2(w, req, &body) {
+		return
+	}
+	if s.org(body.OrganizationGUID) == nil {
+		writeV2Error(w, http.StatusBadRequest, 1002, "CF-InvalidRelation", "Could not find VCAP::CloudController::Organization with guid: "+body.OrganizationGUID)
+		return
+	}
+	for _, q := range s.spaceQuotas {
+		if q.OrgGUID == body.OrganizationGUID && q.Name == body.Name {
+			writeV2Error(w, http.StatusBadRequest, 310007, "CF-SpaceQuotaDefinitionInvalid", "The space quota definition is invalid: organization_id and name unique")
+			return
+		}
+	}
+	q := &SpaceQuota{
+		GUID:                newGUID(),
+		Name:                body.Name,
+		OrgGUID:             body.OrganizationGUID,
+		MemoryLimit:         body.MemoryLimit,
+		InstanceMemoryLimit: body.InstanceMemoryLimit,
+		TotalRoutes:         body.TotalRoutes,
+		TotalServices:       body.TotalServices,
+		AppInstanceLimit:    body.AppInstanceLimit,
+		NonBasicServices:    body.NonBasicServicesAllowed,
+	}
+	s.spaceQuotas = append(s.spaceQuotas, q)
+	writeJSON(w, http.StatusCreated, spaceQuotaV2(q))
+}
+
+func securityGroupV2(g *SecurityGroup) map[string]interface{} {
+	return v2Resource("/v2/security_groups", g.GUID, time.Time{}, time.Time{}, map[string]interface{}{
+		"name":            g.Name,
+		"rules":           []interface{}{},
+		"running_default": false,
+		"staging_default": false,
+	})
+}
+
+func (s *Server) listSecurityGroupsV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "name")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, g := range s.securityGroups {
+		if matchV2(filters, map[string][]string{"name": {g.Name}}) {
+			resources = append(resources, securityGroupV2(g))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+func (s *Server) bindSecurityGroupV2(w http.ResponseWriter, req *http.Request) {
+	g := s.securityGroup(mux.Vars(req)["guid"])
+	if g == nil {
+		writeV2Error(w, http.StatusNotFound, 300002, "CF-SecurityGroupNotFound", "The security group could not be found: "+mux.Vars(req)["guid"])
+		return
+	}
+	spaceGUID := mux.Vars(req)["space"]
+	if s.space(spaceGUID) == nil {
+		writeV2Error(w, http.StatusNotFound, 40004, "CF-SpaceNotFound", "The app space could not be found: "+spaceGUID)
+		return
+	}
+	if mux.Vars(req)["lifecycle"] == "staging_spaces" {
+		if !contains(g.StagingSpaceGUIDs, spaceGUID) {
+			g.StagingSpaceGUIDs = append(g.StagingSpaceGUIDs, spaceGUID)
+		}
+	} else if !contains(g.RunningSpaceGUIDs, spaceGUID) {
+		g.RunningSpaceGUIDs = append(g.RunningSpaceGUIDs, spaceGUID)
+	}
+	writeJSON(w, http.StatusCreated, securityGroupV2(g))
+}
+
+func (s *Server) listServicesV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "label")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, svc := range s.services {
+		if matchV2(filters, map[string][]string{"label": {svc.Label}}) {
+			resources = append(resources, v2Resource("/v2/services", svc.GUID, time.Time{}, time.Time{}, map[string]interface{}{
+				"label":    svc.Label,
+				"active":   true,
+				"bindable": true,
+			}))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+func (s *Server) listServicePlansV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "service_guid")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, p := range s.plans {
+		if matchV2(filters, map[string][]string{"service_guid": {p.ServiceGUID}}) {
+			resources = append(resources, v2Resource("/v2/service_plans", p.GUID, time.Time{}, time.Time{}, map[string]interface{}{
+				"name":         p.Name,
+				"service_guid": p.ServiceGUID,
+				"free":         true,
+				"public":       true,
+				"active":       true,
+				"bindable":     true,
+			}))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+func (s *Server) serviceInstanceV2(i *ServiceInstance) map[string]interface{} {
+	serviceGUID := ""
+	if p := s.plan(i.PlanGUID); p != nil {
+		serviceGUID = p.ServiceGUID
+	}
+	return v2Resource("/v2/service_instances", i.GUID, time.Time{}, time.Time{}, map[string]interface{}{
+		"name":              i.Name,
+		"space_guid":        i.SpaceGUID,
+		"service_plan_guid": i.PlanGUID,
+		"service_guid":      serviceGUID,
+		"type":              "managed_service_instance",
+		"tags":              i.Tags,
+		"last_operation": map[string]interface{}{
+			"type":  "create",
+			"state": "succeeded",
+		},
+	})
+}
+
+func (s *Server) listServiceInstancesV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "name", "space_guid", "organization_guid", "service_plan_guid")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, i := range s.serviceInstances {
+		fields := map[string][]string{
+			"name":              {i.Name},
+			"space_guid":        {i.SpaceGUID},
+			"organization_guid": {s.spaceOrg(i.SpaceGUID)},
+			"service_plan_guid": {i.PlanGUID},
+		}
+		if matchV2(filters, fields) {
+			resources = append(resources, s.serviceInstanceV2(i))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+// serviceInstanceRequest is the body of a request to create a service
+// instance
+type serviceInstanceRequest struct {
+	Name            string                 `json:"name"`
+	SpaceGUID       string                 `json:"space_guid"`
+	ServicePlanGUID string                 `json:"service_plan_guid"`
+	Parameters      map[string]interface{} `json:"parameters"`
+	Tags            []string               `json:"tags"`
+}
+
+func (s *Server) createServiceInstanceV2(w http.ResponseWriter, req *http.Request) {
+	var body serviceInstanceRequest
+	if !decodeV2(w, req, &body) {
+		return
+	}
+	if s.space(body.SpaceGUID) == nil {
+		writeV2Error(w, http.StatusBadRequest, 1002, "CF-InvalidRelation", "Could not find VCAP::CloudController::Space with guid: "+body.SpaceGUID)
+		return
+	}
+	if s.plan(body.ServicePlanGUID) == nil {
+		writeV2Error(w, http.StatusBadRequest, 60003, "CF-InvalidRelation", "Could not find VCAP::CloudController::ServicePlan with guid: "+body.ServicePlanGUID)
+		return
+	}
+	for _, i := range s.serviceInstances {
+		if i.SpaceGUID == body.SpaceGUID && i.Name == body.Name {
+			writeV2Error(w, http.StatusBadRequest, 60002, "CF-ServiceInstanceNameTaken", "The service instance name is taken: "+body.Name)
+			return
+		}
+	}
+	i := &ServiceInstance{
+		GUID:       newGUID(),
+		Name:       body.Name,
+		SpaceGUID:  body.SpaceGUID,
+		PlanGUID:   body.ServicePlanGUID,
+		Parameters: body.Parameters,
+		Tags:       body.Tags,
+	}
+	s.serviceInstances = append(s.serviceInstances, i)
+	writeJSON(w, http.StatusCreated, s.serviceInstanceV2(i))
+}
+
+func (s *Server) deleteServiceInstanceV2(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	var instances []*ServiceInstance
+	found := false
+	for _, i := range s.serviceInstances {
+		if i.GUID == guid {
+			found = true
+			continue
+		}
+		instances = append(instances, i)
+	}
+	if !found {
+		writeV2Error(w, http.StatusNotFound, 60004, "CF-ServiceInstanceNotFound", "The service instance could not be found: "+guid)
+		return
+	}
+	s.serviceInstances = instances
+	writeDeleted(w, req)
+}
+
+func (s *Server) listAppsV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "name", "space_guid", "organization_guid")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, a := range s.apps {
+		fields := map[string][]string{
+			"name":              {a.Name},
+			"space_guid":        {a.SpaceGUID},
+			"organization_guid": {s.spaceOrg(a.SpaceGUID)},
+		}
+		if matchV2(filters, fields) {
+			resources = append(resources, v2Resource("/v2/apps", a.GUID, a.CreatedAt, a.UpdatedAt, map[string]interface{}{
+				"name":               a.Name,
+				"space_guid":         a.SpaceGUID,
+				"state":              a.State,
+				"instances":          1,
+				"memory":             1024,
+				"package_updated_at": timestamp(a.UpdatedAt),
+			}))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+func (s *Server) deleteAppV2(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	var apps []*App
+	found := false
+	for _, a := range s.apps {
+		if a.GUID == guid {
+			found = true
+			continue
+		}
+		apps = append(apps, a)
+	}
+	if !found {
+		writeV2Error(w, http.StatusNotFound, 100004, "CF-AppNotFound", "The app could not be found: "+guid)
+		return
+	}
+	s.apps = apps
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listRoutesV2(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV2Filters(req, "host", "space_guid", "organization_guid")
+	if err != nil {
+		writeInvalidQuery(w, err)
+		return
+	}
+	var resources []map[string]interface{}
+	for _, r := range s.routes {
+		fields := map[string][]string{
+			"host":              {r.Host},
+			"space_guid":        {r.SpaceGUID},
+			"organization_guid": {s.spaceOrg(r.SpaceGUID)},
+		}
+		if matchV2(filters, fields) {
+			resources = append(resources, v2Resource("/v2/routes", r.GUID, time.Time{}, time.Time{}, map[string]interface{}{
+				"host":       r.Host,
+				"path":       "",
+				"space_guid": r.SpaceGUID,
+			}))
+		}
+	}
+	writeV2List(w, resources)
+}
+
+func (s *Server) deleteRouteV2(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	var routes []*Route
+	found := false
+	for _, r := range s.routes {
+		if r.GUID == guid {
+			found = true
+			continue
+		}
+		routes = append(routes, r)
+	}
+	if !found {
+		writeV2Error(w, http.StatusNotFound, 210002, "CF-RouteNotFound", "The route could not be found: "+guid)
+		return
+	}
+	s.routes = routes
+	writeDeleted(w, req)
+}
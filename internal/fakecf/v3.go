@@ -0,0 +1,1220 @@
+package fakecf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// v3Relationship is a to-one relationship of a v3 resource
+type v3Relationship struct {
+	Data *v3Ref `json:"data"`
+}
+
+// v3Relationships is a to-many relationship of a v3 resource
+type v3Relationships struct {
+	Data []v3Ref `json:"data"`
+}
+
+// v3Ref refers to a resource by GUID; users can also be referred to by their
+// username and origin
+type v3Ref struct {
+	GUID     string `json:"guid,omitempty"`
+	Username string `json:"username,omitempty"`
+	Origin   string `json:"origin,omitempty"`
+}
+
+// v3Filters are the comma separated filters of a v3 list request
+type v3Filters map[string][]string
+
+func (s *Server) handleV3(r *mux.Router) {
+	r.HandleFunc("/v3/organizations", s.cc(s.listOrgsV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/organizations", s.cc(s.createOrgV3)).Methods(http.MethodPost)
+	r.HandleFunc("/v3/organizations/{guid}", s.cc(s.getOrgV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/organizations/{guid}", s.cc(s.updateOrgV3)).Methods(http.MethodPatch)
+	r.HandleFunc("/v3/organizations/{guid}", s.cc(s.deleteOrgV3)).Methods(http.MethodDelete)
+	r.HandleFunc("/v3/organizations/{guid}/relationships/default_isolation_segment", s.cc(s.getDefaultIsolationSegmentV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/organizations/{guid}/relationships/default_isolation_segment", s.cc(s.setDefaultIsolationSegmentV3)).Methods(http.MethodPatch)
+
+	r.HandleFunc("/v3/spaces", s.cc(s.listSpacesV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/spaces", s.cc(s.createSpaceV3)).Methods(http.MethodPost)
+	r.HandleFunc("/v3/spaces/{guid}", s.cc(s.getSpaceV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/spaces/{guid}", s.cc(s.deleteSpaceV3)).Methods(http.MethodDelete)
+	r.HandleFunc("/v3/spaces/{guid}/features/ssh", s.cc(s.getSSHFeatureV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/spaces/{guid}/features/ssh", s.cc(s.updateSSHFeatureV3)).Methods(http.MethodPatch)
+	r.HandleFunc("/v3/space_quotas", s.cc(s.listSpaceQuotasV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/space_quotas", s.cc(s.createSpaceQuotaV3)).Methods(http.MethodPost)
+	r.HandleFunc("/v3/space_quotas/{guid}/relationships/spaces", s.cc(s.applySpaceQuotaV3)).Methods(http.MethodPost)
+
+	r.HandleFunc("/v3/roles", s.cc(s.listRolesV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/roles", s.cc(s.createRoleV3)).Methods(http.MethodPost)
+	r.HandleFunc("/v3/roles/{guid}", s.cc(s.deleteRoleV3)).Methods(http.MethodDelete)
+
+	r.HandleFunc("/v3/isolation_segments", s.cc(s.listIsolationSegmentsV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/isolation_segments/{guid}/relationships/organizations", s.cc(s.entitleIsolationSegmentV3)).Methods(http.MethodPost)
+
+	r.HandleFunc("/v3/organization_quotas", s.cc(s.listOrgQuotasV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/organization_quotas/{guid}/relationships/organizations", s.cc(s.applyOrgQuotaV3)).Methods(http.MethodPost)
+
+	r.HandleFunc("/v3/security_groups", s.cc(s.listSecurityGroupsV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/security_groups/{guid}/relationships/{lifecycle:running_spaces|staging_spaces}", s.cc(s.bindSecurityGroupV3)).Methods(http.MethodPost)
+
+	r.HandleFunc("/v3/service_offerings", s.cc(s.listServiceOfferingsV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/service_plans", s.cc(s.listServicePlansV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/service_instances", s.cc(s.listServiceInstancesV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/service_instances", s.cc(s.createServiceInstanceV3)).Methods(http.MethodPost)
+	r.HandleFunc("/v3/service_instances/{guid}", s.cc(s.deleteServiceInstanceV3)).Methods(http.MethodDelete)
+
+	r.HandleFunc("/v3/apps", s.cc(s.listAppsV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/apps/{guid}", s.cc(s.deleteAppV3)).Methods(http.MethodDelete)
+	r.HandleFunc("/v3/packages", s.cc(s.listPackagesV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/routes", s.cc(s.listRoutesV3)).Methods(http.MethodGet)
+	r.HandleFunc("/v3/routes/{guid}", s.cc(s.deleteRouteV3)).Methods(http.MethodDelete)
+
+	r.HandleFunc("/v3/jobs/{guid}", s.cc(s.getJobV3)).Methods(http.MethodGet)
+}
+
+// parseV3Filters parses the request's query parameters, returning an error
+// when one of them is not an allowed filter
+func parseV3Filters(req *http.Request, allowed ...string) (v3Filters, error) {
+	filters := make(v3Filters)
+	var unknown []string
+	for k, values := range req.URL.Query() {
+		switch k {
+		case "page", "per_page", "order_by", "include":
+			continue
+		}
+		if !contains(allowed, k) {
+			unknown = append(unknown, "'"+k+"'")
+			continue
+		}
+		for _, v := range values {
+			filters[k] = append(filters[k], strings.Split(v, ",")...)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("The query parameter is invalid: Unknown query parameter(s): %s", strings.Join(unknown, ", "))
+	}
+	return filters, nil
+}
+
+// match returns true when the value is one of the filter's values, or the
+// request is not filtered by the field
+func (f v3Filters) match(field string, values ...string) bool {
+	allowed, ok := f[field]
+	if !ok {
+		return true
+	}
+	for _, v := range values {
+		if contains(allowed, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeV3List(w http.ResponseWriter, req *http.Request, resources []map[string]interface{}) {
+	writeV3ListIncluding(w, req, resources, nil)
+}
+
+// writeV3ListIncluding writes the list with the resources that were
+// requested with the include parameter
+func writeV3ListIncluding(w http.ResponseWriter, req *http.Request, resources []map[string]interface{}, included map[string]interface{}) {
+	if resources == nil {
+		resources = []map[string]interface{}{}
+	}
+	href := map[string]string{"href": "/v3" + strings.TrimPrefix(req.URL.Path, "/v3")}
+	list := map[string]interface{}{
+		"pagination": map[string]interface{}{
+			"total_results": len(resources),
+			"total_pages":   1,
+			"first":         href,
+			"last":          href,
+			"next":          nil,
+			"previous":      nil,
+		},
+		"resources": resources,
+	}
+	if included != nil {
+		list["included"] = included
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func writeV3Error(w http.ResponseWriter, status int, code int, title, detail string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"code":   code,
+			"title":  title,
+			"detail": detail,
+		}},
+	})
+}
+
+func writeV3NotFound(w http.ResponseWriter, resource string) {
+	writeV3Error(w, http.StatusNotFound, 10010, "CF-ResourceNotFound", resource+" not found")
+}
+
+func writeV3Unprocessable(w http.ResponseWriter, detail string) {
+	writeV3Error(w, http.StatusUnprocessableEntity, 10008, "CF-UnprocessableEntity", detail)
+}
+
+func decodeV3(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	err := json.NewDecoder(req.Body).Decode(v)
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 1001, "CF-MessageParseError", "Request invalid due to parse error: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// writeJob accepts a request that the Cloud Controller completes
+// asynchronously; the fake has already completed it
+func writeJob(w http.ResponseWriter, operation string) {
+	w.Header().Set("Location", "/v3/jobs/"+operation+"~"+newGUID())
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) getJobV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	operation := guid
+	if i := strings.Index(guid, "~"); i > 0 {
+		operation = guid[:i]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"guid":      guid,
+		"operation": operation,
+		"state":     "COMPLETE",
+		"errors":    []interface{}{},
+		"warnings":  []interface{}{},
+	})
+}
+
+func toOne(guid string) map[string]interface{} {
+	if guid == "" {
+		return map[string]interface{}{"data": nil}
+	}
+	return map[string]interface{}{"data": map[string]string{"guid": guid}}
+}
+
+func toMany(guids []string) map[string]interface{} {
+	data := []map[string]string{}
+	for _, g := range guids {
+		data = append(data, map[string]string{"guid": g})
+	}
+	return map[string]interface{}{"data": data}
+}
+
+func orgV3(o *Org) map[string]interface{} {
+	updated := o.UpdatedAt
+	if updated.IsZero() {
+		updated = o.CreatedAt
+	}
+	return map[string]interface{}{
+		"guid":       o.GUID,
+		"name":       o.Name,
+		"suspended":  o.Status == "suspended",
+		"created_at": timestamp(o.CreatedAt),
+		"updated_at": timestamp(updated),
+		"relationships": map[string]interface{}{
+			"quota": toOne(o.QuotaGUID),
+		},
+		"metadata": map[string]interface{}{
+			"labels":      map[string]string{},
+			"annotations": map[string]string{},
+		},
+	}
+}
+
+func (s *Server) listOrgsV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, o := range s.orgs {
+		if filters.match("names", o.Name) && filters.match("guids", o.GUID) {
+			resources = append(resources, orgV3(o))
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+// orgRequestV3 is the body of v3 requests to create and update orgs
+type orgRequestV3 struct {
+	Name      string `json:"name"`
+	Suspended *bool  `json:"suspended"`
+}
+
+func (s *Server) createOrgV3(w http.ResponseWriter, req *http.Request) {
+	var body orgRequestV3
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		writeV3Unprocessable(w, "Name can't be blank")
+		return
+	}
+	if s.orgNamed(body.Name) != nil {
+		writeV3Unprocessable(w, "Organization '"+body.Name+"' already exists.")
+		return
+	}
+	o := &Org{GUID: newGUID(), Name: body.Name, Status: "active", CreatedAt: s.now()}
+	if body.Suspended != nil && *body.Suspended {
+		o.Status = "suspended"
+	}
+	for _, q := range s.quotas {
+		if q.Name == DefaultQuotaName {
+			o.QuotaGUID = q.GUID
+		}
+	}
+	s.orgs = append(s.orgs, o)
+	writeJSON(w, http.StatusCreated, orgV3(o))
+}
+
+func (s *Server) getOrgV3(w http.ResponseWriter, req *http.Request) {
+	o := s.org(mux.Vars(req)["guid"])
+	if o == nil {
+		writeV3NotFound(w, "Organization")
+		return
+	}
+	writeJSON(w, http.StatusOK, orgV3(o))
+}
+
+func (s *Server) updateOrgV3(w http.ResponseWriter, req *http.Request) {
+	o := s.org(mux.Vars(req)["guid"])
+	if o == nil {
+		writeV3NotFound(w, "Organization")
+		return
+	}
+	var body orgRequestV3
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	if body.Name != "" && body.Name != o.Name && s.orgNamed(body.Name) != nil {
+		writeV3Unprocessable(w, "Organization '"+body.Name+"' already exists.")
+		return
+	}
+	if body.Name != "" {
+		o.Name = body.Name
+	}
+	if body.Suspended != nil {
+		o.Status = "active"
+		if *body.Suspended {
+			o.Status = "suspended"
+		}
+	}
+	o.UpdatedAt = s.now()
+	writeJSON(w, http.StatusOK, orgV3(o))
+}
+
+func (s *Server) deleteOrgV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	if s.org(guid) == nil {
+		writeV3NotFound(w, "Organization")
+		return
+	}
+	s.deleteOrg(guid)
+	writeJob(w, "organization.delete")
+}
+
+func (s *Server) getDefaultIsolationSegmentV3(w http.ResponseWriter, req *http.Request) {
+	o := s.org(mux.Vars(req)["guid"])
+	if o == nil {
+		writeV3NotFound(w, "Organization")
+		return
+	}
+	writeJSON(w, http.StatusOK, toOne(o.DefaultIsolationSegmentGUID))
+}
+
+func (s *Server) setDefaultIsolationSegmentV3(w http.ResponseWriter, req *http.Request) {
+	o := s.org(mux.Vars(req)["guid"])
+	if o == nil {
+		writeV3NotFound(w, "Organization")
+		return
+	}
+	var body v3Relationship
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	guid := ""
+	if body.Data != nil {
+		guid = body.Data.GUID
+	}
+	if guid != "" && !contains(o.IsolationSegmentGUIDs, guid) {
+		writeV3Unprocessable(w, "Unable to assign isolation segment with guid '"+guid+"'. Ensure it has been entitled to the organization.")
+		return
+	}
+	o.DefaultIsolationSegmentGUID = guid
+	o.UpdatedAt = s.now()
+	writeJSON(w, http.StatusOK, toOne(guid))
+}
+
+func spaceV3(sp *Space) map[string]interface{} {
+	updated := sp.UpdatedAt
+	if updated.IsZero() {
+		updated = sp.CreatedAt
+	}
+	return map[string]interface{}{
+		"guid":       sp.GUID,
+		"name":       sp.Name,
+		"created_at": timestamp(sp.CreatedAt),
+		"updated_at": timestamp(updated),
+		"relationships": map[string]interface{}{
+			"organization": toOne(sp.OrgGUID),
+			"quota":        toOne(sp.SpaceQuotaGUID),
+		},
+		"metadata": map[string]interface{}{
+			"labels":      map[string]string{},
+			"annotations": map[string]string{},
+		},
+	}
+}
+
+func (s *Server) listSpacesV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids", "organization_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, sp := range s.spaces {
+		if filters.match("names", sp.Name) && filters.match("guids", sp.GUID) && filters.match("organization_guids", sp.OrgGUID) {
+			resources = append(resources, spaceV3(sp))
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+// spaceRequestV3 is the body of a v3 request to create a space
+type spaceRequestV3 struct {
+	Name          string `json:"name"`
+	Relationships struct {
+		Organization v3Relationship `json:"organization"`
+	} `json:"relationships"`
+}
+
+func (s *Server) createSpaceV3(w http.ResponseWriter, req *http.Request) {
+	var body spaceRequestV3
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		writeV3Unprocessable(w, "Name can't be blank")
+		return
+	}
+	orgGUID := ""
+	if body.Relationships.Organization.Data != nil {
+		orgGUID = body.Relationships.Organization.Data.GUID
+	}
+	if s.org(orgGUID) == nil {
+		writeV3Unprocessable(w, "Invalid organization. Ensure the organization exists and you have access to it.")
+		return
+	}
+	for _, sp := range s.spaces {
+		if sp.OrgGUID == orgGUID && sp.Name == body.Name {
+			writeV3Unprocessable(w, "Name must be unique per organization")
+			return
+		}
+	}
+	sp := &Space{GUID: newGUID(), Name: body.Name, OrgGUID: orgGUID, AllowSSH: true, CreatedAt: s.now()}
+	s.spaces = append(s.spaces, sp)
+	writeJSON(w, http.StatusCreated, spaceV3(sp))
+}
+
+func (s *Server) getSpaceV3(w http.ResponseWriter, req *http.Request) {
+	sp := s.space(mux.Vars(req)["guid"])
+	if sp == nil {
+		writeV3NotFound(w, "Space")
+		return
+	}
+	writeJSON(w, http.StatusOK, spaceV3(sp))
+}
+
+func (s *Server) deleteSpaceV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	if s.space(guid) == nil {
+		writeV3NotFound(w, "Space")
+		return
+	}
+	s.deleteSpace(guid)
+	writeJob(w, "space.delete")
+}
+
+func sshFeatureV3(sp *Space) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        "ssh",
+		"description": "Enable SSHing into apps in the space.",
+		"enabled":     sp.AllowSSH,
+	}
+}
+
+func (s *Server) getSSHFeatureV3(w http.ResponseWriter, req *http.Request) {
+	sp := s.space(mux.Vars(req)["guid"])
+	if sp == nil {
+		writeV3NotFound(w, "Space")
+		return
+	}
+	writeJSON(w, http.StatusOK, sshFeatureV3(sp))
+}
+
+func (s *Server) updateSSHFeatureV3(w http.ResponseWriter, req *http.Request) {
+	sp := s.space(mux.Vars(req)["guid"])
+	if sp == nil {
+		writeV3NotFound(w, "Space")
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	if body.Enabled == nil {
+		writeV3Unprocessable(w, "Enabled must be a boolean")
+		return
+	}
+	sp.AllowSSH = *body.Enabled
+	sp.UpdatedAt = s.now()
+	writeJSON(w, http.StatusOK, sshFeatureV3(sp))
+}
+
+func (s *Server) applySpaceQuotaV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	var quota *SpaceQuota
+	for _, q := range s.spaceQuotas {
+		if q.GUID == guid {
+			quota = q
+		}
+	}
+	if quota == nil {
+		writeV3NotFound(w, "Space quota")
+		return
+	}
+	var body v3Relationships
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	for _, ref := range body.Data {
+		sp := s.space(ref.GUID)
+		if sp == nil || sp.OrgGUID != quota.OrgGUID {
+			writeV3Unprocessable(w, "Spaces with guids [\""+ref.GUID+"\"] do not exist within the organization for this space quota, or you do not have access to them.")
+			return
+		}
+	}
+	var spaces []string
+	for _, sp := range s.spaces {
+		for _, ref := range body.Data {
+			if sp.GUID == ref.GUID {
+				sp.SpaceQuotaGUID = guid
+				sp.UpdatedAt = s.now()
+			}
+		}
+		if sp.SpaceQuotaGUID == guid {
+			spaces = append(spaces, sp.GUID)
+		}
+	}
+	writeJSON(w, http.StatusOK, toMany(spaces))
+}
+
+// roleOrg returns the org that the role is related to; space roles are not
+// related to the org of their space
+func roleOrg(r *Role) string {
+	if r.SpaceGUID != "" {
+		return ""
+	}
+	return r.OrgGUID
+}
+
+func roleV3(r *Role) map[string]interface{} {
+	return map[string]interface{}{
+		"guid": r.GUID,
+		"type": r.Type,
+		"relationships": map[string]interface{}{
+			"user":         toOne(r.UserGUID),
+			"organization": toOne(roleOrg(r)),
+			"space":        toOne(r.SpaceGUID),
+		},
+	}
+}
+
+func (s *Server) listRolesV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "guids", "types", "user_guids", "organization_guids", "space_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	var users []map[string]interface{}
+	for _, r := range s.roles {
+		if filters.match("guids", r.GUID) && filters.match("types", r.Type) && filters.match("user_guids", r.UserGUID) &&
+			filters.match("organization_guids", roleOrg(r)) && filters.match("space_guids", r.SpaceGUID) {
+			resources = append(resources, roleV3(r))
+			if u := s.user(r.UserGUID); u != nil {
+				users = append(users, map[string]interface{}{
+					"guid":     u.ID,
+					"username": u.UserName,
+					"origin":   u.Origin,
+				})
+			}
+		}
+	}
+	if req.URL.Query().Get("include") != "user" {
+		writeV3List(w, req, resources)
+		return
+	}
+	if users == nil {
+		users = []map[string]interface{}{}
+	}
+	writeV3ListIncluding(w, req, resources, map[string]interface{}{"users": users})
+}
+
+// roleRequestV3 is the body of a v3 request to create a role
+type roleRequestV3 struct {
+	Type          string `json:"type"`
+	Relationships struct {
+		User         v3Relationship `json:"user"`
+		Organization v3Relationship `json:"organization"`
+		Space        v3Relationship `json:"space"`
+	} `json:"relationships"`
+}
+
+func (s *Server) createRoleV3(w http.ResponseWriter, req *http.Request) {
+	var body roleRequestV3
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	user := s.roleUser(body.Relationships.User.Data)
+	if user == nil {
+		writeV3Unprocessable(w, "Invalid user. Ensure that the user exists and you have access to it.")
+		return
+	}
+
+	var orgGUID, spaceGUID string
+	switch body.Type {
+	case RoleOrganizationUser, RoleOrganizationManager, RoleOrganizationAuditor:
+		if body.Relationships.Organization.Data == nil || s.org(body.Relationships.Organization.Data.GUID) == nil {
+			writeV3Unprocessable(w, "Invalid organization. Ensure that the organization exists and you have access to it.")
+			return
+		}
+		orgGUID = body.Relationships.Organization.Data.GUID
+	case RoleSpaceDeveloper, RoleSpaceManager, RoleSpaceAuditor:
+		if body.Relationships.Space.Data == nil || s.space(body.Relationships.Space.Data.GUID) == nil {
+			writeV3Unprocessable(w, "Invalid space. Ensure that the space exists and you have access to it.")
+			return
+		}
+		spaceGUID = body.Relationships.Space.Data.GUID
+		orgGUID = s.spaceOrg(spaceGUID)
+		if !s.inOrg(user.ID, orgGUID) {
+			writeV3Unprocessable(w, "Users cannot be assigned roles in a space if they do not have a role in that space's organization.")
+			return
+		}
+	default:
+		writeV3Unprocessable(w, "Type must be one of the allowed types")
+		return
+	}
+	if s.hasRole(body.Type, user.ID, orgGUID, spaceGUID) {
+		writeV3Unprocessable(w, fmt.Sprintf("User '%s' already has '%s' role", user.UserName, body.Type))
+		return
+	}
+	writeJSON(w, http.StatusCreated, roleV3(s.grant(body.Type, user.ID, orgGUID, spaceGUID)))
+}
+
+// roleUser returns the UAA user that a role refers to, by GUID or by
+// username and origin
+func (s *Server) roleUser(ref *v3Ref) *User {
+	if ref == nil {
+		return nil
+	}
+	if ref.GUID != "" {
+		return s.user(ref.GUID)
+	}
+	origin := ref.Origin
+	if origin == "" {
+		origin = "uaa"
+	}
+	for _, u := range s.users {
+		if strings.EqualFold(u.UserName, ref.Username) && u.Origin == origin {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Server) deleteRoleV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	var roles []*Role
+	found := false
+	for _, r := range s.roles {
+		if r.GUID == guid {
+			found = true
+			continue
+		}
+		roles = append(roles, r)
+	}
+	if !found {
+		writeV3NotFound(w, "Role")
+		return
+	}
+	s.roles = roles
+	writeJob(w, "role.delete")
+}
+
+func (s *Server) isolationSegmentOrgs(guid string) []string {
+	var orgs []string
+	for _, o := range s.orgs {
+		if contains(o.IsolationSegmentGUIDs, guid) {
+			orgs = append(orgs, o.GUID)
+		}
+	}
+	return orgs
+}
+
+func (s *Server) listIsolationSegmentsV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids", "organization_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, i := range s.isolationSegments {
+		created := timestamp(s.now())
+		if filters.match("names", i.Name) && filters.match("guids", i.GUID) && filters.match("organization_guids", s.isolationSegmentOrgs(i.GUID)...) {
+			resources = append(resources, map[string]interface{}{
+				"guid":       i.GUID,
+				"name":       i.Name,
+				"created_at": created,
+				"updated_at": created,
+			})
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+func (s *Server) entitleIsolationSegmentV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	if s.isolationSegment(guid) == nil {
+		writeV3NotFound(w, "Isolation segment")
+		return
+	}
+	var body v3Relationships
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	for _, ref := range body.Data {
+		if s.org(ref.GUID) == nil {
+			writeV3Unprocessable(w, "Organization guids "+ref.GUID+" do not exist")
+			return
+		}
+	}
+	for _, ref := range body.Data {
+		o := s.org(ref.GUID)
+		if !contains(o.IsolationSegmentGUIDs, guid) {
+			o.IsolationSegmentGUIDs = append(o.IsolationSegmentGUIDs, guid)
+		}
+	}
+	writeJSON(w, http.StatusOK, toMany(s.isolationSegmentOrgs(guid)))
+}
+
+func (s *Server) quotaOrgs(guid string) []string {
+	var orgs []string
+	for _, o := range s.orgs {
+		if o.QuotaGUID == guid {
+			orgs = append(orgs, o.GUID)
+		}
+	}
+	return orgs
+}
+
+func (s *Server) listOrgQuotasV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids", "organization_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, q := range s.quotas {
+		orgs := s.quotaOrgs(q.GUID)
+		if filters.match("names", q.Name) && filters.match("guids", q.GUID) && filters.match("organization_guids", orgs...) {
+			resources = append(resources, map[string]interface{}{
+				"guid": q.GUID,
+				"name": q.Name,
+				"relationships": map[string]interface{}{
+					"organizations": toMany(orgs),
+				},
+			})
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+func (s *Server) applyOrgQuotaV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	if s.quota(guid) == nil {
+		writeV3NotFound(w, "Organization quota")
+		return
+	}
+	var body v3Relationships
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	for _, ref := range body.Data {
+		if s.org(ref.GUID) == nil {
+			writeV3Unprocessable(w, "Organizations with guids [\""+ref.GUID+"\"] do not exist, or you do not have access to them.")
+			return
+		}
+	}
+	for _, ref := range body.Data {
+		o := s.org(ref.GUID)
+		o.QuotaGUID = guid
+		o.UpdatedAt = s.now()
+	}
+	writeJSON(w, http.StatusOK, toMany(s.quotaOrgs(guid)))
+}
+
+// limitV3 is the v3 value of a limit, in which null is unlimited
+func limitV3(l int) interface{} {
+	if l < 0 {
+		return nil
+	}
+	return l
+}
+
+func (s *Server) spaceQuotaV3(q *SpaceQuota) map[string]interface{} {
+	var spaces []string
+	for _, sp := range s.spaces {
+		if sp.SpaceQuotaGUID == q.GUID {
+			spaces = append(spaces, sp.GUID)
+		}
+	}
+	return map[string]interface{}{
+		"guid": q.GUID,
+		"name": q.Name,
+		"apps": map[string]interface{}{
+			"total_memory_in_mb":       limitV3(q.MemoryLimit),
+			"per_process_memory_in_mb": limitV3(q.InstanceMemoryLimit),
+			"total_instances":          limitV3(q.AppInstanceLimit),
+		},
+		"services": map[string]interface{}{
+			"paid_services_allowed":   q.NonBasicServices,
+			"total_service_instances": limitV3(q.TotalServices),
+		},
+		"routes": map[string]interface{}{
+			"total_routes": limitV3(q.TotalRoutes),
+		},
+		"relationships": map[string]interface{}{
+			"organization": toOne(q.OrgGUID),
+			"spaces":       toMany(spaces),
+		},
+	}
+}
+
+func (s *Server) listSpaceQuotasV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids", "organization_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, q := range s.spaceQuotas {
+		if filters.match("names", q.Name) && filters.match("guids", q.GUID) && filters.match("organization_guids", q.OrgGUID) {
+			resources = append(resources, s.spaceQuotaV3(q))
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+// spaceQuotaRequestV3 is the body of a v3 request to create a space quota;
+// limits that are null or not given are unlimited
+type spaceQuotaRequestV3 struct {
+	Name string `json:"name"`
+	Apps struct {
+		TotalMemoryInMB      *int `json:"total_memory_in_mb"`
+		PerProcessMemoryInMB *int `json:"per_process_memory_in_mb"`
+		TotalInstances       *int `json:"total_instances"`
+	} `json:"apps"`
+	Services struct {
+		PaidServicesAllowed   *bool `json:"paid_services_allowed"`
+		TotalServiceInstances *int  `json:"total_service_instances"`
+	} `json:"services"`
+	Routes struct {
+		TotalRoutes *int `json:"total_routes"`
+	} `json:"routes"`
+	Relationships struct {
+		Organization v3Relationship `json:"organization"`
+	} `json:"relationships"`
+}
+
+func unlimitedV3(l *int) int {
+	if l == nil {
+		return -1
+	}
+	return *l
+}
+
+func (s *Server) createSpaceQuotaV3(w http.ResponseWriter, req *http.Request) {
+	var body spaceQuotaRequestV3
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		writeV3Unprocessable(w, "Name can't be blank")
+		return
+	}
+	orgGUID := ""
+	if body.Relationships.Organization.Data != nil {
+		orgGUID = body.Relationships.Organization.Data.GUID
+	}
+	if s.org(orgGUID) == nil {
+		writeV3Unprocessable(w, "Organization with guid '"+orgGUID+"' does not exist, or you do not have access to it.")
+		return
+	}
+	for _, q := range s.spaceQuotas {
+		if q.OrgGUID == orgGUID && q.Name == body.Name {
+			writeV3Unprocessable(w, "Space Quota '"+body.Name+"' already exists.")
+			return
+		}
+	}
+	q := &SpaceQuota{
+		GUID:                newGUID(),
+		Name:                body.Name,
+		OrgGUID:             orgGUID,
+		MemoryLimit:         unlimitedV3(body.Apps.TotalMemoryInMB),
+		InstanceMemoryLimit: unlimitedV3(body.Apps.PerProcessMemoryInMB),
+		AppInstanceLimit:    unlimitedV3(body.Apps.TotalInstances),
+		TotalServices:       unlimitedV3(body.Services.TotalServiceInstances),
+		TotalRoutes:         unlimitedV3(body.Routes.TotalRoutes),
+		NonBasicServices:    body.Services.PaidServicesAllowed == nil || *body.Services.PaidServicesAllowed,
+	}
+	s.spaceQuotas = append(s.spaceQuotas, q)
+	writeJSON(w, http.StatusCreated, s.spaceQuotaV3(q))
+}
+
+func securityGroupV3(g *SecurityGroup) map[string]interface{} {
+	return map[string]interface{}{
+		"guid":  g.GUID,
+		"name":  g.Name,
+		"rules": []interface{}{},
+		"globally_enabled": map[string]bool{
+			"running": false,
+			"staging": false,
+		},
+		"relationships": map[string]interface{}{
+			"running_spaces": toMany(g.RunningSpaceGUIDs),
+			"staging_spaces": toMany(g.StagingSpaceGUIDs),
+		},
+	}
+}
+
+func (s *Server) listSecurityGroupsV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids", "running_space_guids", "staging_space_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, g := range s.securityGroups {
+		if filters.match("names", g.Name) && filters.match("guids", g.GUID) &&
+			filters.match("running_space_guids", g.RunningSpaceGUIDs...) && filters.match("staging_space_guids", g.StagingSpaceGUIDs...) {
+			resources = append(resources, securityGroupV3(g))
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+func (s *Server) bindSecurityGroupV3(w http.ResponseWriter, req *http.Request) {
+	g := s.securityGroup(mux.Vars(req)["guid"])
+	if g == nil {
+		writeV3NotFound(w, "Security group")
+		return
+	}
+	var body v3Relationships
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	for _, ref := range body.Data {
+		if s.space(ref.GUID) == nil {
+			writeV3Unprocessable(w, "Spaces with guids [\""+ref.GUID+"\"] do not exist, or you do not have access to them.")
+			return
+		}
+	}
+	spaces := &g.RunningSpaceGUIDs
+	if mux.Vars(req)["lifecycle"] == "staging_spaces" {
+		spaces = &g.StagingSpaceGUIDs
+	}
+	for _, ref := range body.Data {
+		if !contains(*spaces, ref.GUID) {
+			*spaces = append(*spaces, ref.GUID)
+		}
+	}
+	writeJSON(w, http.StatusOK, toMany(*spaces))
+}
+
+func (s *Server) listServiceOfferingsV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, svc := range s.services {
+		if filters.match("names", svc.Label) && filters.match("guids", svc.GUID) {
+			resources = append(resources, map[string]interface{}{
+				"guid":      svc.GUID,
+				"name":      svc.Label,
+				"available": true,
+			})
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+func (s *Server) listServicePlansV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids", "service_offering_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, p := range s.plans {
+		if filters.match("names", p.Name) && filters.match("guids", p.GUID) && filters.match("service_offering_guids", p.ServiceGUID) {
+			resources = append(resources, map[string]interface{}{
+				"guid":      p.GUID,
+				"name":      p.Name,
+				"free":      true,
+				"available": true,
+				"relationships": map[string]interface{}{
+					"service_offering": toOne(p.ServiceGUID),
+				},
+			})
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+func serviceInstanceV3(i *ServiceInstance) map[string]interface{} {
+	return map[string]interface{}{
+		"guid": i.GUID,
+		"name": i.Name,
+		"type": "managed",
+		"tags": i.Tags,
+		"last_operation": map[string]interface{}{
+			"type":  "create",
+			"state": "succeeded",
+		},
+		"relationships": map[string]interface{}{
+			"space":        toOne(i.SpaceGUID),
+			"service_plan": toOne(i.PlanGUID),
+		},
+	}
+}
+
+func (s *Server) listServiceInstancesV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids", "space_guids", "organization_guids", "service_plan_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, i := range s.serviceInstances {
+		if filters.match("names", i.Name) && filters.match("guids", i.GUID) && filters.match("space_guids", i.SpaceGUID) &&
+			filters.match("organization_guids", s.spaceOrg(i.SpaceGUID)) && filters.match("service_plan_guids", i.PlanGUID) {
+			resources = append(resources, serviceInstanceV3(i))
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+// serviceInstanceRequestV3 is the body of a v3 request to create a service
+// instance
+type serviceInstanceRequestV3 struct {
+	Type          string                 `json:"type"`
+	Name          string                 `json:"name"`
+	Parameters    map[string]interface{} `json:"parameters"`
+	Tags          []string               `json:"tags"`
+	Relationships struct {
+		Space       v3Relationship `json:"space"`
+		ServicePlan v3Relationship `json:"service_plan"`
+	} `json:"relationships"`
+}
+
+func (s *Server) createServiceInstanceV3(w http.ResponseWriter, req *http.Request) {
+	var body serviceInstanceRequestV3
+	if !decodeV3(w, req, &body) {
+		return
+	}
+	if body.Type != "managed" {
+		writeV3Unprocessable(w, "Type must be one of 'managed', 'user-provided'")
+		return
+	}
+	var spaceGUID, planGUID string
+	if body.Relationships.Space.Data != nil {
+		spaceGUID = body.Relationships.Space.Data.GUID
+	}
+	if body.Relationships.ServicePlan.Data != nil {
+		planGUID = body.Relationships.ServicePlan.Data.GUID
+	}
+	if s.space(spaceGUID) == nil {
+		writeV3Unprocessable(w, "Invalid space. Ensure that the space exists and you have access to it.")
+		return
+	}
+	if s.plan(planGUID) == nil {
+		writeV3Unprocessable(w, "Invalid service plan. Ensure that the service plan exists, is available, and you have access to it.")
+		return
+	}
+	for _, i := range s.serviceInstances {
+		if i.SpaceGUID == spaceGUID && i.Name == body.Name {
+			writeV3Unprocessable(w, "The service instance name is taken: "+body.Name+".")
+			return
+		}
+	}
+	s.serviceInstances = append(s.serviceInstances, &ServiceInstance{
+		GUID:       newGUID(),
+		Name:       body.Name,
+		SpaceGUID:  spaceGUID,
+		PlanGUID:   planGUID,
+		Parameters: body.Parameters,
+		Tags:       body.Tags,
+	})
+	writeJob(w, "service_instance.create")
+}
+
+func (s *Server) deleteServiceInstanceV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	var instances []*ServiceInstance
+	found := false
+	for _, i := range s.serviceInstances {
+		if i.GUID == guid {
+			found = true
+			continue
+		}
+		instances = append(instances, i)
+	}
+	if !found {
+		writeV3NotFound(w, "Service instance")
+		return
+	}
+	s.serviceInstances = instances
+	writeJob(w, "service_instance.delete")
+}
+
+func appV3(a *App) map[string]interface{} {
+	return map[string]interface{}{
+		"guid":       a.GUID,
+		"name":       a.Name,
+		"state":      a.State,
+		"created_at": timestamp(a.CreatedAt),
+		"updated_at": timestamp(a.UpdatedAt),
+		"relationships": map[string]interface{}{
+			"space": toOne(a.SpaceGUID),
+		},
+	}
+}
+
+func (s *Server) listAppsV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "names", "guids", "space_guids", "organization_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, a := range s.apps {
+		if filters.match("names", a.Name) && filters.match("guids", a.GUID) && filters.match("space_guids", a.SpaceGUID) &&
+			filters.match("organization_guids", s.spaceOrg(a.SpaceGUID)) {
+			resources = append(resources, appV3(a))
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+func (s *Server) deleteAppV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	var apps []*App
+	found := false
+	for _, a := range s.apps {
+		if a.GUID == guid {
+			found = true
+			continue
+		}
+		apps = append(apps, a)
+	}
+	if !found {
+		writeV3NotFound(w, "App")
+		return
+	}
+	s.apps = apps
+	writeJob(w, "app.delete")
+}
+
+// listPackagesV3 lists a package for each app, which was last updated when
+// the app was
+func (s *Server) listPackagesV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "guids", "app_guids", "space_guids", "organization_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, a := range s.apps {
+		if filters.match("guids", a.GUID) && filters.match("app_guids", a.GUID) && filters.match("space_guids", a.SpaceGUID) &&
+			filters.match("organization_guids", s.spaceOrg(a.SpaceGUID)) {
+			resources = append(resources, map[string]interface{}{
+				"guid":       a.GUID,
+				"type":       "bits",
+				"state":      "READY",
+				"created_at": timestamp(a.CreatedAt),
+				"updated_at": timestamp(a.UpdatedAt),
+				"relationships": map[string]interface{}{
+					"app": toOne(a.GUID),
+				},
+			})
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+func (s *Server) listRoutesV3(w http.ResponseWriter, req *http.Request) {
+	filters, err := parseV3Filters(req, "hosts", "guids", "space_guids", "organization_guids")
+	if err != nil {
+		writeV3Error(w, http.StatusBadRequest, 10005, "CF-BadQueryParameter", err.Error())
+		return
+	}
+	var resources []map[string]interface{}
+	for _, r := range s.routes {
+		if filters.match("hosts", r.Host) && filters.match("guids", r.GUID) && filters.match("space_guids", r.SpaceGUID) &&
+			filters.match("organization_guids", s.spaceOrg(r.SpaceGUID)) {
+			resources = append(resources, map[string]interface{}{
+				"guid": r.GUID,
+				"host": r.Host,
+				"path": "",
+				"relationships": map[string]interface{}{
+					"space": toOne(r.SpaceGUID),
+				},
+			})
+		}
+	}
+	writeV3List(w, req, resources)
+}
+
+func (s *Server) deleteRouteV3(w http.ResponseWriter, req *http.Request) {
+	guid := mux.Vars(req)["guid"]
+	var routes []*Route
+	found := false
+	for _, r := range s.routes {
+		if r.GUID == guid {
+			found = true
+			continue
+		}
+		routes = append(routes, r)
+	}
+	if !found {
+		writeV3NotFound(w, "Route")
+		return
+	}
+	s.routes = routes
+	writeJob(w, "route.delete")
+}
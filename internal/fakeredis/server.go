@@ -0,0 +1,469 @@
+// Package fakeredis is an in-memory fake of the Redis server, speaking the
+// Redis protocol over TCP, so that ignition's Redis stores are exercised with
+// the real client by tests. It implements only the commands that those stores
+// use.
+package fakeredis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake Redis server listening on a local port
+type Server struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	values  map[string]string
+	sets    map[string]map[string]bool
+	expires map[string]time.Time
+	offset  time.Duration
+	conns   map[net.Conn]bool
+}
+
+// compareScript is the only form of script that EVAL runs: one that runs a
+// command on the key when the key's value is the first argument, such as the
+// scripts that release or extend a lock only while its owner holds it
+var compareScript = regexp.MustCompile(`^if redis\.call\("GET", KEYS\[1\]\) == ARGV\[1\] then return redis\.call\("(\w+)", KEYS\[1\]((?:, ARGV\[\d+\])*)\) else return 0 end$`)
+
+// scriptArg is a reference to an argument of a script
+var scriptArg = regexp.MustCompile(`ARGV\[(\d+)\]`)
+
+// status is a simple string reply, such as OK
+type status string
+
+// errorReply is an error reply
+type errorReply string
+
+// Run starts a Server on a random local port
+func Run() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		listener: l,
+		values:   map[string]string{},
+		sets:     map[string]map[string]bool{},
+		expires:  map[string]time.Time{},
+		conns:    map[net.Conn]bool{},
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Addr is the host and port that the Server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the Server and closes its connections
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// FastForward moves the Server's clock forward, expiring the keys whose time
+// to live has passed
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset += d
+}
+
+// Exists returns whether the key has a value
+func (s *Server) Exists(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exists(key)
+}
+
+// Get returns the string value of the key, if it has one
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key)
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// TTL returns the time that the key has left to live, or 0 when it does not
+// expire
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.exists(key) {
+		return 0
+	}
+	at, ok := s.expires[key]
+	if !ok {
+		return 0
+	}
+	return at.Sub(s.now())
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	var queue [][]string
+	multi := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		var reply interface{}
+		switch {
+		case name == "MULTI":
+			multi, queue = true, nil
+			reply = status("OK")
+		case name == "EXEC" && multi:
+			replies := []interface{}{}
+			s.mu.Lock()
+			for _, q := range queue {
+				replies = append(replies, s.do(q))
+			}
+			s.mu.Unlock()
+			multi, queue = false, nil
+			reply = replies
+		case name == "DISCARD" && multi:
+			multi, queue = false, nil
+			reply = status("OK")
+		case multi:
+			queue = append(queue, args)
+			reply = status("QUEUED")
+		default:
+			s.mu.Lock()
+			reply = s.do(args)
+			s.mu.Unlock()
+		}
+		writeReply(w, reply)
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// do runs the command, and returns its reply; s.mu must be held
+func (s *Server) do(args []string) interface{} {
+	name, args := strings.ToUpper(args[0]), args[1:]
+	for _, key := range args {
+		s.expire(key)
+	}
+	switch name {
+	case "PING":
+		return status("PONG")
+	case "GET":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		if _, ok := s.sets[args[0]]; ok {
+			return wrongType()
+		}
+		v, ok := s.values[args[0]]
+		if !ok {
+			return nil
+		}
+		return []byte(v)
+	case "SET":
+		return s.set(args)
+	case "DEL":
+		n := 0
+		for _, key := range args {
+			if s.exists(key) {
+				s.delete(key)
+				n++
+			}
+		}
+		return n
+	case "EXISTS":
+		n := 0
+		for _, key := range args {
+			if s.exists(key) {
+				n++
+			}
+		}
+		return n
+	case "EXPIRE", "PEXPIRE":
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return notInteger()
+		}
+		if !s.exists(args[0]) {
+			return 0
+		}
+		unit := time.Second
+		if name == "PEXPIRE" {
+			unit = time.Millisecond
+		}
+		s.expires[args[0]] = s.now().Add(time.Duration(n) * unit)
+		return 1
+	case "EVAL":
+		return s.eval(args)
+	case "TTL":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		if !s.exists(args[0]) {
+			return -2
+		}
+		at, ok := s.expires[args[0]]
+		if !ok {
+			return -1
+		}
+		return int(at.Sub(s.now()).Seconds())
+	case "SADD", "SREM":
+		if len(args) < 2 {
+			return wrongArgs(name)
+		}
+		if _, ok := s.values[args[0]]; ok {
+			return wrongType()
+		}
+		set := s.sets[args[0]]
+		if set == nil {
+			set = map[string]bool{}
+		}
+		n := 0
+		for _, m := range args[1:] {
+			switch {
+			case name == "SADD" && !set[m]:
+				set[m] = true
+				n++
+			case name == "SREM" && set[m]:
+				delete(set, m)
+				n++
+			}
+		}
+		if len(set) == 0 {
+			s.delete(args[0])
+		} else {
+			s.sets[args[0]] = set
+		}
+		return n
+	case "SMEMBERS":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		if _, ok := s.values[args[0]]; ok {
+			return wrongType()
+		}
+		members := []string{}
+		for m := range s.sets[args[0]] {
+			members = append(members, m)
+		}
+		sort.Strings(members)
+		reply := []interface{}{}
+		for _, m := range members {
+			reply = append(reply, []byte(m))
+		}
+		return reply
+	}
+	return errorReply(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+}
+
+// eval runs EVAL script numkeys key [arg ...] for a compareScript with one key
+func (s *Server) eval(args []string) interface{} {
+	if len(args) < 2 {
+		return wrongArgs("EVAL")
+	}
+	m := compareScript.FindStringSubmatch(args[0])
+	if m == nil {
+		return errorReply("ERR the fake only runs scripts that compare a key's value")
+	}
+	if args[1] != "1" || len(args) < 4 {
+		return errorReply("ERR the script takes one key and at least one argument")
+	}
+	key, argv := args[2], args[3:]
+	if _, ok := s.sets[key]; ok {
+		return wrongType()
+	}
+	if v, ok := s.values[key]; !ok || v != argv[0] {
+		return 0
+	}
+	command := []string{m[1], key}
+	for _, ref := range scriptArg.FindAllStringSubmatch(m[2], -1) {
+		i, _ := strconv.Atoi(ref[1])
+		if i < 1 || i > len(argv) {
+			return errorReply("ERR the script refers to a missing argument")
+		}
+		command = append(command, argv[i-1])
+	}
+	return s.do(command)
+}
+
+// set runs SET key value [EX seconds|PX milliseconds] [NX|XX]
+func (s *Server) set(args []string) interface{} {
+	if len(args) < 2 {
+		return wrongArgs("SET")
+	}
+	key, value := args[0], args[1]
+	var ttl time.Duration
+	nx, xx := false, false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 == len(args) {
+				return errorReply("ERR syntax error")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				return errorReply("ERR invalid expire time in set")
+			}
+			unit := time.Second
+			if strings.ToUpper(args[i]) == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+	if (nx && s.exists(key)) || (xx && !s.exists(key)) {
+		return nil
+	}
+	s.delete(key)
+	s.values[key] = value
+	if ttl > 0 {
+		s.expires[key] = s.now().Add(ttl)
+	}
+	return status("OK")
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// expire deletes the key when its time to live has passed
+func (s *Server) expire(key string) {
+	if at, ok := s.expires[key]; ok && !s.now().Before(at) {
+		s.delete(key)
+	}
+}
+
+func (s *Server) exists(key string) bool {
+	s.expire(key)
+	_, value := s.values[key]
+	_, set := s.sets[key]
+	return value || set
+}
+
+func (s *Server) delete(key string) {
+	delete(s.values, key)
+	delete(s.sets, key)
+	delete(s.expires, key)
+}
+
+func wrongArgs(name string) errorReply {
+	return errorReply(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
+
+func wrongType() errorReply {
+	return errorReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+func notInteger() errorReply {
+	return errorReply("ERR value is not an integer or out of range")
+}
+
+// readCommand reads a command, which clients send as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected an array, got [%s]", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid array length [%s]", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected a bulk string, got [%s]", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk string length [%s]", line)
+		}
+		b := make([]byte, size+2)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case status:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case errorReply:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, r := range v {
+			writeReply(w, r)
+		}
+	}
+}
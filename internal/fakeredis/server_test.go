@@ -0,0 +1,104 @@
+package fakeredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/internal/fakeredis"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestServer(t *testing.T) {
+	spec.Run(t, "Server", testServer, spec.Report(report.Terminal{}))
+}
+
+func testServer(t *testing.T, when spec.G, it spec.S) {
+	var (
+		s *fakeredis.Server
+		c redis.Conn
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		var err error
+		s, err = fakeredis.Run()
+		Expect(err).NotTo(HaveOccurred())
+		c, err = redis.Dial("tcp", s.Addr())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		c.Close()
+		s.Close()
+	})
+
+	it("stores values until they expire", func() {
+		Expect(redis.String(c.Do("PING"))).To(Equal("PONG"))
+		Expect(redis.String(c.Do("SET", "key", "value", "EX", 60))).To(Equal("OK"))
+		Expect(redis.String(c.Do("GET", "key"))).To(Equal("value"))
+		Expect(redis.Int(c.Do("TTL", "key"))).To(BeNumerically("~", 60, 1))
+		Expect(s.TTL("key")).To(BeNumerically("~", time.Minute, time.Second))
+
+		s.FastForward(time.Minute)
+		_, err := redis.String(c.Do("GET", "key"))
+		Expect(err).To(Equal(redis.ErrNil))
+		Expect(s.Exists("key")).To(BeFalse())
+	})
+
+	it("only sets a value that does not exist with NX", func() {
+		Expect(redis.String(c.Do("SET", "key", "first", "NX"))).To(Equal("OK"))
+		_, err := redis.String(c.Do("SET", "key", "second", "NX"))
+		Expect(err).To(Equal(redis.ErrNil))
+		v, ok := s.Get("key")
+		Expect(ok).To(BeTrue())
+		Expect(v).To(Equal("first"))
+		Expect(redis.Int(c.Do("DEL", "key", "missing"))).To(Equal(1))
+		Expect(redis.Int(c.Do("EXISTS", "key"))).To(Equal(0))
+	})
+
+	it("stores sets", func() {
+		Expect(redis.Int(c.Do("SADD", "set", "b", "a", "b"))).To(Equal(2))
+		Expect(redis.Strings(c.Do("SMEMBERS", "set"))).To(Equal([]string{"a", "b"}))
+		Expect(redis.Int(c.Do("SREM", "set", "a", "c"))).To(Equal(1))
+		Expect(redis.Int(c.Do("EXPIRE", "set", 60))).To(Equal(1))
+		_, err := c.Do("GET", "set")
+		Expect(err).To(MatchError(HavePrefix("WRONGTYPE")))
+		Expect(redis.Int(c.Do("SREM", "set", "b"))).To(Equal(1))
+		Expect(s.Exists("set")).To(BeFalse())
+	})
+
+	it("runs transactions", func() {
+		c.Send("MULTI")
+		c.Send("SET", "key", "value")
+		c.Send("SADD", "set", "member")
+		replies, err := redis.Values(c.Do("EXEC"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replies).To(Equal([]interface{}{"OK", int64(1)}))
+		Expect(s.Exists("key")).To(BeTrue())
+		Expect(s.Exists("set")).To(BeTrue())
+	})
+
+	it("runs scripts that compare a key's value", func() {
+		del := `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+		pexpire := `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+		Expect(redis.String(c.Do("SET", "key", "value", "PX", 1000))).To(Equal("OK"))
+
+		Expect(redis.Int(c.Do("EVAL", pexpire, 1, "key", "value", 60000))).To(Equal(1))
+		Expect(s.TTL("key")).To(BeNumerically("~", time.Minute, time.Second))
+		Expect(redis.Int(c.Do("EVAL", del, 1, "key", "other"))).To(Equal(0))
+		Expect(s.Exists("key")).To(BeTrue())
+		Expect(redis.Int(c.Do("EVAL", del, 1, "key", "value"))).To(Equal(1))
+		Expect(s.Exists("key")).To(BeFalse())
+
+		_, err := c.Do("EVAL", "return 1", 0)
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("errors on commands that it does not implement", func() {
+		_, err := c.Do("FLUSHALL")
+		Expect(err).To(MatchError("ERR unknown command 'flushall'"))
+	})
+}
@@ -0,0 +1,33 @@
+package logging
+
+import "context"
+
+type contextKey string
+
+const loggerKey contextKey = "logger"
+
+// NewContext returns a context that carries the logger for a request
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the request's logger, or the default logger if the
+// context does not have one
+func FromContext(ctx context.Context) *Logger {
+	l, ok := ctx.Value(loggerKey).(*Logger)
+	if !ok || l == nil {
+		return Default()
+	}
+	return l
+}
+
+// AddField adds the field to every subsequent entry written by the request's
+// logger, including those written by handlers that wrap the one that learns
+// the field's value. It does nothing if the context does not have a logger.
+func AddField(ctx context.Context, key string, value interface{}) {
+	l, ok := ctx.Value(loggerKey).(*Logger)
+	if !ok || l == nil {
+		return
+	}
+	l.set(key, value)
+}
@@ -0,0 +1,211 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+// The levels of log entries, in increasing order of severity
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+func (l Level) String() string {
+	name, ok := levelNames[l]
+	if !ok {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return name
+}
+
+// ParseLevel returns the level with the given name (e.g. "info")
+func ParseLevel(name string) (Level, error) {
+	for l, n := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return l, nil
+		}
+	}
+	return InfoLevel, fmt.Errorf("unknown log level [%s]", name)
+}
+
+// Logger writes log entries as JSON, one per line. Each entry has the time,
+// level, and message, the logger's fields, and any fields given when it is
+// logged.
+type Logger struct {
+	out   io.Writer
+	outMu *sync.Mutex
+	level Level
+
+	mu     sync.Mutex
+	fields map[string]interface{}
+}
+
+// New returns a Logger that writes entries at or above the level to out
+func New(out io.Writer, level Level) *Logger {
+	return &Logger{
+		out:    out,
+		outMu:  &sync.Mutex{},
+		level:  level,
+		fields: make(map[string]interface{}),
+	}
+}
+
+var (
+	stdMu sync.RWMutex
+	std   = New(os.Stdout, InfoLevel)
+)
+
+// Default returns the logger used outside of a request
+func Default() *Logger {
+	stdMu.RLock()
+	defer stdMu.RUnlock()
+	return std
+}
+
+// SetDefault replaces the logger used outside of a request
+func SetDefault(l *Logger) {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	std = l
+}
+
+// With returns a logger that adds the field to every entry
+func (l *Logger) With(key string, value interface{}) *Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w := &Logger{
+		out:    l.out,
+		outMu:  l.outMu,
+		level:  l.level,
+		fields: make(map[string]interface{}, len(l.fields)+1),
+	}
+	for k, v := range l.fields {
+		w.fields[k] = v
+	}
+	w.fields[key] = value
+	return w
+}
+
+// set adds the field to every subsequent entry written by the logger
+func (l *Logger) set(key string, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fields[key] = value
+}
+
+// Debug logs the message and the given key/value pairs at DebugLevel
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(DebugLevel, msg, keyvals)
+}
+
+// Info logs the message and the given key/value pairs at InfoLevel
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(InfoLevel, msg, keyvals)
+}
+
+// Warn logs the message and the given key/value pairs at WarnLevel
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(WarnLevel, msg, keyvals)
+}
+
+// Error logs the message and the given key/value pairs at ErrorLevel
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(ErrorLevel, msg, keyvals)
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if level < l.level {
+		return
+	}
+	entry := make(map[string]interface{})
+	l.mu.Lock()
+	for k, v := range l.fields {
+		entry[k] = jsonValue(v)
+	}
+	l.mu.Unlock()
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		if i+1 == len(keyvals) {
+			entry[key] = nil
+			break
+		}
+		entry[key] = jsonValue(keyvals[i+1])
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		b, _ = json.Marshal(map[string]interface{}{
+			"time":  entry["time"],
+			"level": entry["level"],
+			"msg":   msg,
+			"error": fmt.Sprintf("could not marshal log entry: %s", err.Error()),
+		})
+	}
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+	l.out.Write(append(b, '\n'))
+}
+
+// jsonValue converts values that would not otherwise be useful as JSON (e.g.
+// errors, which marshal to an empty object)
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	default:
+		return v
+	}
+}
+
+// Writer returns an io.Writer that logs each line written to it, so that the
+// standard library's log package can write through the logger. A line that
+// starts with a level in brackets (e.g. "[WARN]") is logged at that level,
+// and any other line is logged at InfoLevel.
+func (l *Logger) Writer() io.Writer {
+	return writer{l: l}
+}
+
+type writer struct {
+	l *Logger
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		msg := strings.TrimSpace(string(line))
+		level := InfoLevel
+		if strings.HasPrefix(msg, "[") {
+			if end := strings.Index(msg, "]"); end > 0 {
+				if parsed, err := ParseLevel(msg[1:end]); err == nil {
+					level = parsed
+					msg = strings.TrimSpace(msg[end+1:])
+				}
+			}
+		}
+		w.l.log(level, msg, nil)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,131 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/logging"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestLogger(t *testing.T) {
+	spec.Run(t, "Logger", testLogger, spec.Report(report.Terminal{}))
+}
+
+func testLogger(t *testing.T, when spec.G, it spec.S) {
+	var (
+		buf *bytes.Buffer
+		l   *logging.Logger
+	)
+
+	entries := func() []map[string]interface{} {
+		var result []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			entry := make(map[string]interface{})
+			Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed())
+			result = append(result, entry)
+		}
+		return result
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		buf = &bytes.Buffer{}
+		l = logging.New(buf, logging.InfoLevel)
+	})
+
+	it("writes each entry as a line of JSON", func() {
+		l.Info("test message", "org", "ignition-testuser", "count", 2)
+		l.Error("test failure", "error", errors.New("test error"))
+		e := entries()
+		Expect(e).To(HaveLen(2))
+		Expect(e[0]).To(HaveKeyWithValue("level", "info"))
+		Expect(e[0]).To(HaveKeyWithValue("msg", "test message"))
+		Expect(e[0]).To(HaveKeyWithValue("org", "ignition-testuser"))
+		Expect(e[0]).To(HaveKeyWithValue("count", BeNumerically("==", 2)))
+		Expect(e[0]).To(HaveKey("time"))
+		Expect(e[1]).To(HaveKeyWithValue("level", "error"))
+		Expect(e[1]).To(HaveKeyWithValue("error", "test error"))
+	})
+
+	it("does not write entries below its level", func() {
+		l.Debug("test debug")
+		l.Warn("test warning")
+		e := entries()
+		Expect(e).To(HaveLen(1))
+		Expect(e[0]).To(HaveKeyWithValue("level", "warn"))
+	})
+
+	it("adds its fields to every entry", func() {
+		w := l.With("request_id", "test-request-id")
+		w.Info("first")
+		w.Info("second")
+		l.Info("third")
+		e := entries()
+		Expect(e[0]).To(HaveKeyWithValue("request_id", "test-request-id"))
+		Expect(e[1]).To(HaveKeyWithValue("request_id", "test-request-id"))
+		Expect(e[2]).NotTo(HaveKey("request_id"))
+	})
+
+	it("writes lines from the standard logger at the level in their prefix", func() {
+		std := log.New(l.Writer(), "", 0)
+		std.Println("[WARN] test warning")
+		std.Println("test message")
+		e := entries()
+		Expect(e).To(HaveLen(2))
+		Expect(e[0]).To(HaveKeyWithValue("level", "warn"))
+		Expect(e[0]).To(HaveKeyWithValue("msg", "test warning"))
+		Expect(e[1]).To(HaveKeyWithValue("level", "info"))
+		Expect(e[1]).To(HaveKeyWithValue("msg", "test message"))
+	})
+
+	when("the logger is in the context", func() {
+		it("is returned from the context", func() {
+			ctx := logging.NewContext(context.Background(), l)
+			Expect(logging.FromContext(ctx)).To(BeIdenticalTo(l))
+		})
+
+		it("adds fields to the logger for the rest of the request", func() {
+			r := l.With("request_id", "test-request-id")
+			ctx := logging.NewContext(context.Background(), r)
+			logging.AddField(ctx, "account_name", "testuser")
+			r.Info("test message")
+			e := entries()
+			Expect(e[0]).To(HaveKeyWithValue("account_name", "testuser"))
+			Expect(e[0]).To(HaveKeyWithValue("request_id", "test-request-id"))
+		})
+	})
+
+	when("the logger is not in the context", func() {
+		it("returns the default logger", func() {
+			Expect(logging.FromContext(context.Background())).To(BeIdenticalTo(logging.Default()))
+		})
+
+		it("does not add fields to the default logger", func() {
+			logging.AddField(context.Background(), "account_name", "testuser")
+			d := logging.Default()
+			logging.SetDefault(l)
+			defer logging.SetDefault(d)
+			logging.FromContext(context.Background()).Info("test message")
+			Expect(entries()[0]).NotTo(HaveKey("account_name"))
+		})
+	})
+
+	it("parses levels by name", func() {
+		level, err := logging.ParseLevel("WARN")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(level).To(Equal(logging.WarnLevel))
+		_, err = logging.ParseLevel("verbose")
+		Expect(err).To(HaveOccurred())
+	})
+}
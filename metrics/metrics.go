@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ignition"
+
+// The reasons that a login can fail
+const (
+	LoginFailureOAuth2  = "oauth2"
+	LoginFailureToken   = "token"
+	LoginFailureProfile = "profile"
+	LoginFailureSession = "session"
+)
+
+// The upstream services whose requests are instrumented
+const (
+	UpstreamCloudController = "cloud_controller"
+	UpstreamUAA             = "uaa"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	logins = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "The number of successful logins.",
+	})
+	loginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "The number of failed logins, by reason.",
+	}, []string{"reason"})
+	orgsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orgs_created_total",
+		Help:      "The number of orgs that have been provisioned.",
+	})
+	provisioningStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provisioning_step_duration_seconds",
+		Help:      "The time taken by each provisioning step, by step and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"step", "state"})
+	upstreamRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "The time taken by requests to the Cloud Controller and UAA, by upstream, method, and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"upstream", "method", "code"})
+	upstreamRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_errors_total",
+		Help:      "The number of requests to the Cloud Controller and UAA that failed or returned a server error, by upstream.",
+	}, []string{"upstream"})
+	orgs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "orgs",
+		Help:      "The number of orgs that use the ignition quota.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		logins,
+		loginFailures,
+		orgsCreated,
+		provisioningStepDuration,
+		upstreamRequestDuration,
+		upstreamRequestErrors,
+		orgs,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// LoginSucceeded counts a successful login
+func LoginSucceeded() {
+	logins.Inc()
+}
+
+// LoginFailed counts a failed login for the given reason
+func LoginFailed(reason string) {
+	loginFailures.WithLabelValues(reason).Inc()
+}
+
+// OrgCreated counts an org that has been provisioned
+func OrgCreated() {
+	orgsCreated.Inc()
+}
+
+// ObserveProvisioningStep records the time taken by a provisioning step and
+// the state it finished in. Steps that are repeated for each space (e.g.
+// "space:playground") are recorded under their kind (e.g. "space").
+func ObserveProvisioningStep(step string, state string, d time.Duration) {
+	kind := strings.SplitN(step, ":", 2)[0]
+	provisioningStepDuration.WithLabelValues(kind, state).Observe(d.Seconds())
+}
+
+// SetIgnitionOrgCount records the number of ignition orgs
+func SetIgnitionOrgCount(count int) {
+	orgs.Set(float64(count))
+}
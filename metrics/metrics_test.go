@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestMetrics(t *testing.T) {
+	spec.Run(t, "Metrics", testMetrics, spec.Report(report.Terminal{}))
+}
+
+func testMetrics(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("counts logins and login failures by reason", func() {
+		before := testutil.ToFloat64(logins)
+		LoginSucceeded()
+		Expect(testutil.ToFloat64(logins)).To(Equal(before + 1))
+
+		before = testutil.ToFloat64(loginFailures.WithLabelValues(LoginFailureProfile))
+		LoginFailed(LoginFailureProfile)
+		Expect(testutil.ToFloat64(loginFailures.WithLabelValues(LoginFailureProfile))).To(Equal(before + 1))
+	})
+
+	it("records the ignition org count", func() {
+		SetIgnitionOrgCount(42)
+		Expect(testutil.ToFloat64(orgs)).To(Equal(float64(42)))
+	})
+
+	it("records provisioning steps by their kind", func() {
+		ObserveProvisioningStep("space:playground", "succeeded", time.Second)
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(`ignition_provisioning_step_duration_seconds_count{state="succeeded",step="space"}`))
+		Expect(w.Body.String()).NotTo(ContainSubstring("space:playground"))
+	})
+
+	it("serves the metrics in the prometheus text format", func() {
+		OrgCreated()
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Content-Type")).To(ContainSubstring("text/plain"))
+		Expect(w.Body.String()).To(ContainSubstring("# TYPE ignition_orgs_created_total counter"))
+		Expect(w.Body.String()).To(ContainSubstring("go_goroutines"))
+	})
+}
+
+func TestTransport(t *testing.T) {
+	spec.Run(t, "Transport", testTransport, spec.Report(report.Terminal{}))
+}
+
+type failingRoundTripper struct{}
+
+func (failingRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("test error")
+}
+
+func testTransport(t *testing.T, when spec.G, it spec.S) {
+	var (
+		status int
+		server *httptest.Server
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	it("records the latency of each request", func() {
+		c := &http.Client{Transport: &Transport{Upstream: "test-ok"}}
+		res, err := c.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		Expect(w.Body.String()).To(ContainSubstring(`ignition_upstream_request_duration_seconds_count{code="200",method="GET",upstream="test-ok"} 1`))
+		Expect(testutil.ToFloat64(upstreamRequestErrors.WithLabelValues("test-ok"))).To(BeZero())
+	})
+
+	it("counts server errors", func() {
+		status = http.StatusBadGateway
+		c := &http.Client{Transport: &Transport{Upstream: "test-server-error"}}
+		res, err := c.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+		Expect(testutil.ToFloat64(upstreamRequestErrors.WithLabelValues("test-server-error"))).To(Equal(float64(1)))
+	})
+
+	it("counts requests that fail", func() {
+		c := &http.Client{Transport: &Transport{Upstream: "test-failure", Base: failingRoundTripper{}}}
+		_, err := c.Get(server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(testutil.ToFloat64(upstreamRequestErrors.WithLabelValues("test-failure"))).To(Equal(float64(1)))
+	})
+}
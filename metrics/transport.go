@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Transport records the latency and errors of the requests made to an
+// upstream service
+type Transport struct {
+	Upstream string
+	Base     http.RoundTripper
+}
+
+// RoundTrip sends the request using the Base transport, or
+// http.DefaultTransport if Base is nil, and records its outcome
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	start := time.Now()
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		upstreamRequestErrors.WithLabelValues(t.Upstream).Inc()
+		return res, err
+	}
+	upstreamRequestDuration.WithLabelValues(t.Upstream, req.Method, strconv.Itoa(res.StatusCode)).Observe(time.Since(start).Seconds())
+	if res.StatusCode >= http.StatusInternalServerError {
+		upstreamRequestErrors.WithLabelValues(t.Upstream).Inc()
+	}
+	return res, nil
+}
@@ -1,6 +1,7 @@
 package uaa
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -21,7 +22,15 @@ type Client struct {
 	userManager  *uaa.UserManager
 }
 
-// UserIDForAccountName queries the UAA API for users filtered by account name
+// UserNotFoundError indicates that the UAA has no user with the account name
+type UserNotFoundError string
+
+func (u UserNotFoundError) Error() string {
+	return fmt.Sprintf("cannot find user with account name: [%s]", string(u))
+}
+
+// UserIDForAccountName queries the UAA API for users filtered by account name,
+// returning a UserNotFoundError when there is no such user
 func (a *Client) UserIDForAccountName(accountName string) (string, error) {
 	if strings.TrimSpace(accountName) == "" {
 		return "", errors.New("cannot search for a user with an empty account name")
@@ -33,10 +42,15 @@ func (a *Client) UserIDForAccountName(accountName string) (string, error) {
 	}
 	user, err := a.userManager.GetByUsername(accountName, "", "")
 	if err != nil {
+		// the UAA client reports a search without results with this error,
+		// rather than with a type that can be checked
+		if err.Error() == fmt.Sprintf("User %s not found.", accountName) {
+			return "", UserNotFoundError(accountName)
+		}
 		return "", errors.Wrap(err, "uaa: cannot get user")
 	}
 	if strings.TrimSpace(user.ID) == "" {
-		return "", errors.Errorf("cannot find user with account name: [%s]", accountName)
+		return "", UserNotFoundError(accountName)
 	}
 	return user.ID, nil
 }
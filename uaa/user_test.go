@@ -102,6 +102,7 @@ func testUserIDForAccountName(t *testing.T, when spec.G, it spec.S) {
 			it("returns the error", func() {
 				userID, err := a.UserIDForAccountName("tester@pivotal.io")
 				Expect(err).To(HaveOccurred())
+				Expect(err).NotTo(BeAssignableToTypeOf(uaa.UserNotFoundError("")))
 				Expect(userID).To(BeZero())
 				Expect(called).To(BeTrue())
 			})
@@ -117,12 +118,28 @@ func testUserIDForAccountName(t *testing.T, when spec.G, it spec.S) {
 
 			it("returns an error", func() {
 				userID, err := a.UserIDForAccountName("tester@pivotal.io")
-				Expect(err).To(HaveOccurred())
+				Expect(err).To(Equal(uaa.UserNotFoundError("tester@pivotal.io")))
 				Expect(userID).To(BeZero())
 				Expect(err.Error()).To(Equal("cannot find user with account name: [tester@pivotal.io]"))
 				Expect(called).To(BeTrue())
 			})
 		})
+
+		when("no user is returned", func() {
+			it.Before(func() {
+				s = internal.ServeFromTestdata(t, "no-users.json", func() {
+					called = true
+				})
+				a.URL = s.URL
+			})
+
+			it("returns a UserNotFoundError", func() {
+				userID, err := a.UserIDForAccountName("tester@pivotal.io")
+				Expect(err).To(Equal(uaa.UserNotFoundError("tester@pivotal.io")))
+				Expect(userID).To(BeZero())
+				Expect(called).To(BeTrue())
+			})
+		})
 	})
 }
 
@@ -0,0 +1,472 @@
+// Package fakeprovider is a fake OpenID Connect provider, for running ignition
+// locally without a single sign on service, and for end to end tests of the
+// login flow. It is not secure: any configured user can log in without a
+// password.
+package fakeprovider
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotalservices/ignition/user/openid"
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// The paths of the provider's endpoints
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	AuthorizePath = "/oauth/authorize"
+	TokenPath     = "/oauth/token"
+	KeysPath      = "/token_keys"
+	UserInfoPath  = "/userinfo"
+	LogoutPath    = "/logout.do"
+)
+
+const (
+	keyID   = "fake-provider-key"
+	codeTTL = time.Minute
+)
+
+// User is a user that can log in to the provider
+type User struct {
+	Subject    string   `json:"sub"`
+	UserName   string   `json:"user_name"`
+	Email      string   `json:"email"`
+	GivenName  string   `json:"given_name"`
+	FamilyName string   `json:"family_name"`
+	Groups     []string `json:"groups,omitempty"`
+
+	// Claims are added to the user's ID tokens, replacing the claims above
+	// when they have the same name (e.g. user_attributes)
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
+
+// DefaultUser is the user that can log in when no users are configured
+var DefaultUser = User{
+	Subject:    "fake-developer",
+	UserName:   "developer",
+	Email:      "developer@example.net",
+	GivenName:  "Dev",
+	FamilyName: "Eloper",
+}
+
+// Provider is a fake OpenID Connect provider that serves the discovery,
+// authorization, token, key set, user info, and end session endpoints
+type Provider struct {
+	// Issuer is the URL that the provider is served at
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Users        []User
+
+	// TokenTTL is how long access tokens and ID tokens are valid for
+	TokenTTL time.Duration
+
+	key     *rsa.PrivateKey
+	signer  jose.Signer
+	mux     *http.ServeMux
+	mu      sync.Mutex
+	codes   map[string]*grant
+	refresh map[string]*User
+	access  map[string]*User
+}
+
+// grant is an authorization code that has not yet been exchanged for a token
+type grant struct {
+	user                *User
+	redirectURI         string
+	nonce               string
+	codeChallenge       string
+	codeChallengeMethod string
+	expires             time.Time
+}
+
+// New returns a provider for the client that the users can log in to; the
+// DefaultUser can log in when there are no users. Set the Issuer to the URL
+// the provider is served at before using it.
+func New(clientID, clientSecret string, users ...User) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate signing key")
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create signer")
+	}
+	if len(users) == 0 {
+		users = []User{DefaultUser}
+	}
+	for i := range users {
+		if users[i].Subject == "" {
+			users[i].Subject = users[i].UserName
+		}
+		if users[i].Subject == "" {
+			users[i].Subject = users[i].Email
+		}
+	}
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Users:        users,
+		TokenTTL:     time.Hour,
+		key:          key,
+		signer:       signer,
+		codes:        make(map[string]*grant),
+		refresh:      make(map[string]*User),
+		access:       make(map[string]*User),
+	}
+	p.mux = http.NewServeMux()
+	p.mux.HandleFunc(DiscoveryPath, p.discovery)
+	p.mux.HandleFunc(AuthorizePath, p.authorize)
+	p.mux.HandleFunc(TokenPath, p.token)
+	p.mux.HandleFunc(KeysPath, p.keys)
+	p.mux.HandleFunc(UserInfoPath, p.userInfo)
+	p.mux.HandleFunc(LogoutPath, p.logout)
+	return p, nil
+}
+
+// ServeHTTP serves the provider's endpoints
+func (p *Provider) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p.mux.ServeHTTP(w, req)
+}
+
+// IDToken returns a signed ID token for the user, with the nonce if it is set
+func (p *Provider) IDToken(u *User, nonce string) (string, error) {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":         p.Issuer,
+		"sub":         u.Subject,
+		"aud":         p.ClientID,
+		"iat":         now.Unix(),
+		"exp":         now.Add(p.TokenTTL).Unix(),
+		"user_name":   u.UserName,
+		"email":       u.Email,
+		"given_name":  u.GivenName,
+		"family_name": u.FamilyName,
+	}
+	if len(u.Groups) > 0 {
+		claims["groups"] = u.Groups
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	for k, v := range u.Claims {
+		claims[k] = v
+	}
+	return p.sign(claims)
+}
+
+// LogoutToken returns a signed back-channel logout token for the subject
+func (p *Provider) LogoutToken(sub string) (string, error) {
+	jti, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return p.sign(map[string]interface{}{
+		"iss":    p.Issuer,
+		"sub":    sub,
+		"aud":    p.ClientID,
+		"iat":    now.Unix(),
+		"exp":    now.Add(2 * time.Minute).Unix(),
+		"jti":    jti,
+		"events": map[string]interface{}{openid.BackChannelLogoutEvent: map[string]interface{}{}},
+	})
+}
+
+func (p *Provider) sign(claims map[string]interface{}) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	jws, err := p.signer.Sign(payload)
+	if err != nil {
+		return "", errors.Wrap(err, "could not sign token")
+	}
+	return jws.CompactSerialize()
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + AuthorizePath,
+		"token_endpoint":                        p.Issuer + TokenPath,
+		"jwks_uri":                              p.Issuer + KeysPath,
+		"userinfo_endpoint":                     p.Issuer + UserInfoPath,
+		"end_session_endpoint":                  p.Issuer + LogoutPath,
+		"scopes_supported":                      []string{"openid", "profile", "email", "roles", "user_attributes"},
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256", "plain"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+	})
+}
+
+var chooser = template.Must(template.New("chooser").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Fake OpenID Connect Provider</title></head>
+<body>
+<h1>Log in as</h1>
+<ul>
+{{range .}}<li><a href="{{.URL}}">{{.User.UserName}} ({{.User.Email}})</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// authorize logs in the user named by the login_hint, or the only user, and
+// redirects back to the client with an authorization code. When there is more
+// than one user, and no login_hint, the user chooses who to log in as.
+func (p *Provider) authorize(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	if q.Get("client_id") != p.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" {
+		redirectError(w, req, redirectURI, q.Get("state"), "unsupported_response_type")
+		return
+	}
+	method := q.Get("code_challenge_method")
+	if q.Get("code_challenge") != "" && method != "S256" && method != "plain" && method != "" {
+		redirectError(w, req, redirectURI, q.Get("state"), "invalid_request")
+		return
+	}
+
+	u := p.user(q.Get("login_hint"))
+	if u == nil && q.Get("login_hint") == "" && len(p.Users) > 1 {
+		type choice struct {
+			User User
+			URL  string
+		}
+		var choices []choice
+		for _, user := range p.Users {
+			cq := req.URL.Query()
+			cq.Set("login_hint", user.UserName)
+			choices = append(choices, choice{User: user, URL: AuthorizePath + "?" + cq.Encode()})
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		chooser.Execute(w, choices)
+		return
+	}
+	if u == nil && len(p.Users) == 1 && q.Get("login_hint") == "" {
+		u = &p.Users[0]
+	}
+	if u == nil {
+		redirectError(w, req, redirectURI, q.Get("state"), "access_denied")
+		return
+	}
+
+	code, err := randomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.codes[code] = &grant{
+		user:                u,
+		redirectURI:         q.Get("redirect_uri"),
+		nonce:               q.Get("nonce"),
+		codeChallenge:       q.Get("code_challenge"),
+		codeChallengeMethod: method,
+		expires:             time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	rq := redirectURI.Query()
+	rq.Set("code", code)
+	if state := q.Get("state"); state != "" {
+		rq.Set("state", state)
+	}
+	redirectURI.RawQuery = rq.Encode()
+	http.Redirect(w, req, redirectURI.String(), http.StatusFound)
+}
+
+// token exchanges authorization codes and refresh tokens for tokens
+func (p *Provider) token(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	req.ParseForm()
+	clientID, clientSecret, ok := req.BasicAuth()
+	if !ok {
+		clientID, clientSecret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || (p.ClientSecret != "" && clientSecret != p.ClientSecret) {
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "the client could not be authenticated")
+		return
+	}
+
+	switch req.PostForm.Get("grant_type") {
+	case "authorization_code":
+		p.mu.Lock()
+		g, ok := p.codes[req.PostForm.Get("code")]
+		delete(p.codes, req.PostForm.Get("code"))
+		p.mu.Unlock()
+		if !ok || time.Now().After(g.expires) {
+			tokenError(w, http.StatusBadRequest, "invalid_grant", "the code is invalid or has expired")
+			return
+		}
+		if g.redirectURI != req.PostForm.Get("redirect_uri") {
+			tokenError(w, http.StatusBadRequest, "invalid_grant", "the redirect_uri does not match the authorization request")
+			return
+		}
+		if !g.verify(req.PostForm.Get("code_verifier")) {
+			tokenError(w, http.StatusBadRequest, "invalid_grant", "the code_verifier does not match the code_challenge")
+			return
+		}
+		p.issue(w, g.user, g.nonce)
+	case "refresh_token":
+		p.mu.Lock()
+		u, ok := p.refresh[req.PostForm.Get("refresh_token")]
+		delete(p.refresh, req.PostForm.Get("refresh_token"))
+		p.mu.Unlock()
+		if !ok {
+			tokenError(w, http.StatusBadRequest, "invalid_grant", "the refresh token is invalid")
+			return
+		}
+		p.issue(w, u, "")
+	default:
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+// issue writes a token response for the user
+func (p *Provider) issue(w http.ResponseWriter, u *User, nonce string) {
+	accessToken, err := randomString()
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	refreshToken, err := randomString()
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	idToken, err := p.IDToken(u, nonce)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	p.mu.Lock()
+	p.access[accessToken] = u
+	p.refresh[refreshToken] = u
+	p.mu.Unlock()
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "bearer",
+		"expires_in":    int(p.TokenTTL / time.Second),
+		"refresh_token": refreshToken,
+		"id_token":      idToken,
+		"scope":         "openid profile user_attributes",
+	})
+}
+
+func (p *Provider) keys(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			jose.JSONWebKey{Key: &p.key.PublicKey, KeyID: keyID, Algorithm: string(jose.RS256), Use: "sig"},
+		},
+	})
+}
+
+func (p *Provider) userInfo(w http.ResponseWriter, req *http.Request) {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	p.mu.Lock()
+	u, ok := p.access[token]
+	p.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// logout sends the user back to the post_logout_redirect_uri; the provider
+// has no sessions of its own to end
+func (p *Provider) logout(w http.ResponseWriter, req *http.Request) {
+	if redirect := req.URL.Query().Get("post_logout_redirect_uri"); redirect != "" {
+		http.Redirect(w, req, redirect, http.StatusFound)
+		return
+	}
+	w.Write([]byte("You have been logged out"))
+}
+
+// user finds the user with the account name or email
+func (p *Provider) user(name string) *User {
+	if name == "" {
+		return nil
+	}
+	for i := range p.Users {
+		if strings.EqualFold(p.Users[i].UserName, name) || strings.EqualFold(p.Users[i].Email, name) {
+			return &p.Users[i]
+		}
+	}
+	return nil
+}
+
+// verify checks the PKCE code verifier against the code challenge, if the
+// authorization request had one
+func (g *grant) verify(verifier string) bool {
+	if g.codeChallenge == "" {
+		return true
+	}
+	challenge := verifier
+	if g.codeChallengeMethod == "S256" {
+		sum := sha256.Sum256([]byte(verifier))
+		challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(g.codeChallenge)) == 1
+}
+
+func redirectError(w http.ResponseWriter, req *http.Request, redirectURI *url.URL, state string, code string) {
+	q := redirectURI.Query()
+	q.Set("error", code)
+	if state != "" {
+		q.Set("state", state)
+	}
+	redirectURI.RawQuery = q.Encode()
+	http.Redirect(w, req, redirectURI.String(), http.StatusFound)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string, description string) {
+	writeJSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "could not generate random string")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,178 @@
+package fakeprovider_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/ignition/user/openid"
+	"github.com/pivotalservices/ignition/user/openid/fakeprovider"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+	"golang.org/x/oauth2"
+)
+
+func TestProvider(t *testing.T) {
+	spec.Run(t, "Provider", testProvider, spec.Report(report.Terminal{}))
+}
+
+func testProvider(t *testing.T, when spec.G, it spec.S) {
+	var (
+		p      *fakeprovider.Provider
+		s      *httptest.Server
+		config *oauth2.Config
+		client *http.Client
+	)
+
+	// authorize starts a login as the user with the PKCE code verifier and the
+	// nonce, returning the redirect to the client
+	authorize := func(loginHint, verifier, nonce string) *url.URL {
+		sum := sha256.Sum256([]byte(verifier))
+		authURL := config.AuthCodeURL("test-state",
+			oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:])),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+			oauth2.SetAuthURLParam("nonce", nonce),
+			oauth2.SetAuthURLParam("login_hint", loginHint))
+		resp, err := client.Get(authURL)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusFound))
+		location, err := url.Parse(resp.Header.Get("Location"))
+		Expect(err).NotTo(HaveOccurred())
+		return location
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		var err error
+		p, err = fakeprovider.New("test-client", "test-secret",
+			fakeprovider.User{UserName: "tester", Email: "tester@example.net", Groups: []string{"developers"}},
+			fakeprovider.User{Subject: "admin-subject", UserName: "admin", Email: "admin@example.net", Claims: map[string]interface{}{
+				"user_attributes": map[string][]string{"memberOf": []string{"platform"}},
+			}},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		s = httptest.NewServer(p)
+		p.Issuer = s.URL
+		config = &oauth2.Config{
+			ClientID:     "test-client",
+			ClientSecret: "test-secret",
+			RedirectURL:  "https://ignition.example.net/oauth2",
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  s.URL + fakeprovider.AuthorizePath,
+				TokenURL: s.URL + fakeprovider.TokenPath,
+			},
+			Scopes: []string{"openid"},
+		}
+		client = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	})
+
+	it.After(func() {
+		s.Close()
+	})
+
+	it("serves a discovery document", func() {
+		resp, err := http.Get(s.URL + fakeprovider.DiscoveryPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		var discovery map[string]interface{}
+		Expect(json.NewDecoder(resp.Body).Decode(&discovery)).To(Succeed())
+		Expect(discovery).To(HaveKeyWithValue("issuer", s.URL))
+		Expect(discovery).To(HaveKeyWithValue("token_endpoint", s.URL+"/oauth/token"))
+		Expect(discovery).To(HaveKeyWithValue("jwks_uri", s.URL+"/token_keys"))
+		Expect(discovery).To(HaveKeyWithValue("end_session_endpoint", s.URL+"/logout.do"))
+	})
+
+	it("issues ID tokens that can be verified with its key set", func() {
+		location := authorize("tester", "test-code-verifier", "test-nonce")
+		Expect(location.Host).To(Equal("ignition.example.net"))
+		Expect(location.Query().Get("state")).To(Equal("test-state"))
+
+		token, err := config.Exchange(context.Background(), location.Query().Get("code"), oauth2.SetAuthURLParam("code_verifier", "test-code-verifier"))
+		Expect(err).NotTo(HaveOccurred())
+		rawIDToken, ok := token.Extra("id_token").(string)
+		Expect(ok).To(BeTrue())
+
+		verifier := openid.NewVerifier(s.URL, "test-client", s.URL+fakeprovider.KeysPath, false)
+		claims, err := verifier.Verify(context.Background(), rawIDToken)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims.Sub).To(Equal("tester"))
+		Expect(claims.UserName).To(Equal("tester"))
+		Expect(claims.Email).To(Equal("tester@example.net"))
+		Expect(claims.Groups).To(ConsistOf("developers"))
+		Expect(claims.Nonce).To(Equal("test-nonce"))
+	})
+
+	it("adds the user's claims to their ID token", func() {
+		location := authorize("admin@example.net", "test-code-verifier", "test-nonce")
+		token, err := config.Exchange(context.Background(), location.Query().Get("code"), oauth2.SetAuthURLParam("code_verifier", "test-code-verifier"))
+		Expect(err).NotTo(HaveOccurred())
+		verifier := openid.NewVerifier(s.URL, "test-client", s.URL+fakeprovider.KeysPath, false)
+		claims, err := verifier.Verify(context.Background(), token.Extra("id_token").(string))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims.Sub).To(Equal("admin-subject"))
+		Expect(claims.GroupNames("memberOf")).To(ConsistOf("platform"))
+	})
+
+	it("rejects a code verifier that does not match the code challenge", func() {
+		location := authorize("tester", "test-code-verifier", "test-nonce")
+		_, err := config.Exchange(context.Background(), location.Query().Get("code"), oauth2.SetAuthURLParam("code_verifier", "another-code-verifier"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("invalid_grant"))
+	})
+
+	it("only exchanges a code once", func() {
+		location := authorize("tester", "test-code-verifier", "test-nonce")
+		_, err := config.Exchange(context.Background(), location.Query().Get("code"), oauth2.SetAuthURLParam("code_verifier", "test-code-verifier"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = config.Exchange(context.Background(), location.Query().Get("code"), oauth2.SetAuthURLParam("code_verifier", "test-code-verifier"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("rejects an unknown client", func() {
+		location := authorize("tester", "test-code-verifier", "test-nonce")
+		config.ClientSecret = "wrong-secret"
+		_, err := config.Exchange(context.Background(), location.Query().Get("code"), oauth2.SetAuthURLParam("code_verifier", "test-code-verifier"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("refreshes tokens", func() {
+		location := authorize("tester", "test-code-verifier", "test-nonce")
+		token, err := config.Exchange(context.Background(), location.Query().Get("code"), oauth2.SetAuthURLParam("code_verifier", "test-code-verifier"))
+		Expect(err).NotTo(HaveOccurred())
+		token.Expiry = token.Expiry.Add(-2 * p.TokenTTL)
+		refreshed, err := config.TokenSource(context.Background(), token).Token()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refreshed.AccessToken).NotTo(Equal(token.AccessToken))
+		Expect(refreshed.Extra("id_token")).NotTo(BeNil())
+	})
+
+	it("lets the user choose who to log in as when there is no login hint", func() {
+		resp, err := client.Get(config.AuthCodeURL("test-state"))
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(ContainSubstring("text/html"))
+	})
+
+	it("denies access to an unknown user", func() {
+		location := authorize("nobody", "test-code-verifier", "test-nonce")
+		Expect(location.Query().Get("error")).To(Equal("access_denied"))
+	})
+
+	it("issues back-channel logout tokens", func() {
+		rawToken, err := p.LogoutToken("tester")
+		Expect(err).NotTo(HaveOccurred())
+		verifier := openid.NewVerifier(s.URL, "test-client", s.URL+fakeprovider.KeysPath, false)
+		claims, err := verifier.Verify(context.Background(), rawToken)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims.IsLogoutToken()).To(BeTrue())
+		Expect(claims.Sub).To(Equal("tester"))
+	})
+}
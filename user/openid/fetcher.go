@@ -2,7 +2,9 @@ package openid
 
 import (
 	"context"
+	"crypto/subtle"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -16,6 +18,14 @@ import (
 // Fetcher retrieves the profile for a user when the auth variant is "google"
 type Fetcher struct {
 	Verifier Verifier
+
+	// GroupAttributes are the user_attributes claims whose values are treated
+	// as the user's groups, in addition to the groups and roles claims
+	GroupAttributes []string
+
+	// FoundationAttribute is the user_attributes claim whose value assigns the
+	// user to a foundation
+	FoundationAttribute string
 }
 
 // Verifier takes an OpenID ID token and verifies it, returning claims
@@ -36,9 +46,64 @@ type Claims struct {
 	GivenName  string `json:"given_name"`
 	FamilyName string `json:"family_name"`
 	Email      string `json:"email"`
+
+	Groups         []string            `json:"groups"`
+	Roles          []string            `json:"roles"`
+	UserAttributes map[string][]string `json:"user_attributes"`
+
+	// Sid, Nonce, and Events are used to tell logout tokens from ID tokens
+	Sid    string                     `json:"sid"`
+	Nonce  string                     `json:"nonce"`
+	Events map[string]json.RawMessage `json:"events"`
 }
 
-// Profile retrieves the user's profile with the given context, config, and token
+// BackChannelLogoutEvent is the event that identifies a back-channel logout
+// token
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// IsLogoutToken reports whether the claims are those of a back-channel logout
+// token, which must have the logout event and must not have a nonce
+func (c *Claims) IsLogoutToken() bool {
+	_, ok := c.Events[BackChannelLogoutEvent]
+	return ok && c.Nonce == ""
+}
+
+// GroupNames returns the names in the groups and roles claims, and in the
+// given user_attributes, without duplicates
+func (c *Claims) GroupNames(attributes ...string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(values []string) {
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if v == "" || seen[v] {
+				continue
+			}
+			seen[v] = true
+			names = append(names, v)
+		}
+	}
+	add(c.Groups)
+	add(c.Roles)
+	for _, attribute := range attributes {
+		add(c.UserAttributes[attribute])
+	}
+	return names
+}
+
+// Attribute returns the first value of the user_attribute, or an empty string
+// if the user does not have it
+func (c *Claims) Attribute(attribute string) string {
+	for _, v := range c.UserAttributes[attribute] {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Profile retrieves the user's profile with the given context, config, and
+// token. If the context has a nonce, the ID token must have the same nonce.
 func (g *Fetcher) Profile(ctx context.Context, c *oauth2.Config, t *oauth2.Token) (*user.Profile, error) {
 	if g.Verifier == nil {
 		return nil, errors.New("unable to verify token")
@@ -55,6 +120,9 @@ func (g *Fetcher) Profile(ctx context.Context, c *oauth2.Config, t *oauth2.Token
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch claims")
 	}
+	if nonce, ok := NonceFromContext(ctx); ok && subtle.ConstantTimeCompare([]byte(nonce), []byte(claims.Nonce)) != 1 {
+		return nil, errors.New("id_token nonce does not match the authorization request")
+	}
 
 	username := claims.UserName
 	if strings.TrimSpace(username) == "" {
@@ -65,6 +133,9 @@ func (g *Fetcher) Profile(ctx context.Context, c *oauth2.Config, t *oauth2.Token
 		Email:       claims.Email,
 		AccountName: username,
 		Name:        strings.TrimSpace(fmt.Sprintf("%s %s", claims.GivenName, claims.FamilyName)),
+		Groups:      claims.GroupNames(g.GroupAttributes...),
+		Subject:     claims.Sub,
+		Foundation:  claims.Attribute(g.FoundationAttribute),
 	}, nil
 }
 
@@ -6,6 +6,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"io"
@@ -185,6 +186,7 @@ func testFetcher(t *testing.T, when spec.G, it spec.S) {
 				it("returns the profile", func() {
 					v := &openidfakes.FakeVerifier{}
 					v.VerifyReturns(&openid.Claims{
+						Sub:        "test-subject",
 						Email:      "test@example.net",
 						UserName:   "tester",
 						GivenName:  "Test",
@@ -197,6 +199,41 @@ func testFetcher(t *testing.T, when spec.G, it spec.S) {
 					Expect(p.Name).To(Equal("Test User"))
 					Expect(p.AccountName).To(Equal("tester"))
 					Expect(p.Email).To(Equal("test@example.net"))
+					Expect(p.Subject).To(Equal("test-subject"))
+				})
+
+				it("returns the user's groups", func() {
+					v := &openidfakes.FakeVerifier{}
+					v.VerifyReturns(&openid.Claims{
+						Email:  "test@example.net",
+						Groups: []string{"engineering", "admins"},
+						Roles:  []string{"admins", "developers"},
+						UserAttributes: map[string][]string{
+							"memberOf": []string{"cn=platform"},
+							"region":   []string{"emea"},
+						},
+					}, nil)
+					f.Verifier = v
+					f.GroupAttributes = []string{"memberOf"}
+					p, err := f.Profile(context.Background(), nil, t)
+					Expect(err).To(BeNil())
+					Expect(p.Groups).To(Equal([]string{"engineering", "admins", "developers", "cn=platform"}))
+					Expect(p.Foundation).To(BeEmpty())
+				})
+
+				it("returns the foundation the user is assigned to", func() {
+					v := &openidfakes.FakeVerifier{}
+					v.VerifyReturns(&openid.Claims{
+						Email: "test@example.net",
+						UserAttributes: map[string][]string{
+							"region": []string{" ", "emea"},
+						},
+					}, nil)
+					f.Verifier = v
+					f.FoundationAttribute = "region"
+					p, err := f.Profile(context.Background(), nil, t)
+					Expect(err).To(BeNil())
+					Expect(p.Foundation).To(Equal("emea"))
 				})
 
 				it("uses the email address as the account name if it is not set", func() {
@@ -218,4 +255,33 @@ func testFetcher(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	it("checks the ID token's nonce against the nonce in the context", func() {
+		v := &openidfakes.FakeVerifier{}
+		v.VerifyReturns(&openid.Claims{Email: "test@example.net", UserName: "tester", Nonce: "test-nonce"}, nil)
+		f.Verifier = v
+		t := (&oauth2.Token{AccessToken: "test-token"}).WithExtra(map[string]interface{}{"id_token": "test-id_token"})
+
+		p, err := f.Profile(openid.WithNonce(context.Background(), "test-nonce"), nil, t)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.AccountName).To(Equal("tester"))
+
+		p, err = f.Profile(openid.WithNonce(context.Background(), "other-nonce"), nil, t)
+		Expect(err).To(MatchError(ContainSubstring("nonce")))
+		Expect(p).To(BeNil())
+
+		_, err = f.Profile(context.Background(), nil, t)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it("recognizes back-channel logout tokens", func() {
+		var c openid.Claims
+		err := json.Unmarshal([]byte(`{"sub": "test-subject", "events": {"http://schemas.openid.net/event/backchannel-logout": {}}}`), &c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.IsLogoutToken()).To(BeTrue())
+
+		c.Nonce = "test-nonce"
+		Expect(c.IsLogoutToken()).To(BeFalse())
+		Expect((&openid.Claims{Sub: "test-subject"}).IsLogoutToken()).To(BeFalse())
+	})
 }
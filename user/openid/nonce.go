@@ -0,0 +1,22 @@
+package openid
+
+import "context"
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	nonceKey key = iota
+)
+
+// WithNonce returns a copy of ctx that stores the nonce that was sent with
+// the authorization request, so that it can be checked against the ID token
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey, nonce)
+}
+
+// NonceFromContext returns the nonce from the ctx, if there is one
+func NonceFromContext(ctx context.Context) (string, bool) {
+	nonce, ok := ctx.Value(nonceKey).(string)
+	return nonce, ok
+}
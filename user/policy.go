@@ -0,0 +1,129 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy decides which users are authorized to use ignition. A user in
+// DeniedUsers is never authorized, and a user in AllowedUsers always is. Any
+// other user must have an email in one of the Domains (or, when
+// MatchSubdomains is set, in a subdomain of one of them), must not be in any of
+// the DeniedGroups, and, when there are AllowedGroups, must be in at least one
+// of them. Users are matched by account name or email.
+//
+// Authorized users with one of the AdminEmails, or in one of the AdminGroups,
+// are administrators.
+type Policy struct {
+	Domains         []string
+	MatchSubdomains bool
+	AllowedUsers    []string
+	DeniedUsers     []string
+	AllowedGroups   []string
+	DeniedGroups    []string
+	AdminEmails     []string
+	AdminGroups     []string
+}
+
+// NotAuthorizedError describes why a user is not authorized to use ignition
+type NotAuthorizedError string
+
+func (e NotAuthorizedError) Error() string {
+	return string(e)
+}
+
+// Authorize returns a NotAuthorizedError if the user is not authorized by the
+// policy
+func (p *Policy) Authorize(profile *Profile) error {
+	if p.isUser(profile, p.DeniedUsers) {
+		return NotAuthorizedError("your account has been denied access")
+	}
+	if p.isUser(profile, p.AllowedUsers) {
+		return nil
+	}
+	if len(p.Domains) > 0 && !p.inDomain(profile.Email) {
+		return NotAuthorizedError(fmt.Sprintf("email domain %s is not authorized", EmailDomain(profile.Email)))
+	}
+	if group, ok := memberOf(profile.Groups, p.DeniedGroups); ok {
+		return NotAuthorizedError(fmt.Sprintf("members of group %s are denied access", group))
+	}
+	if len(p.AllowedGroups) == 0 {
+		return nil
+	}
+	if _, ok := memberOf(profile.Groups, p.AllowedGroups); !ok {
+		return NotAuthorizedError("you are not a member of a group that is allowed access")
+	}
+	return nil
+}
+
+// IsAdmin returns true when the user is an administrator
+func (p *Policy) IsAdmin(profile *Profile) bool {
+	for _, email := range p.AdminEmails {
+		if strings.TrimSpace(profile.Email) != "" && strings.EqualFold(strings.TrimSpace(email), strings.TrimSpace(profile.Email)) {
+			return true
+		}
+	}
+	_, ok := memberOf(profile.Groups, p.AdminGroups)
+	return ok
+}
+
+// inDomain returns true when the email is in one of the policy's domains
+func (p *Policy) inDomain(email string) bool {
+	domain := EmailDomain(email)
+	if domain == "" {
+		return false
+	}
+	for _, d := range p.Domains {
+		d = NormalizeDomain(d)
+		if d == "" {
+			continue
+		}
+		if domain == d || (p.MatchSubdomains && strings.HasSuffix(domain, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUser returns true when the user's account name or email is one of the
+// names
+func (p *Policy) isUser(profile *Profile, names []string) bool {
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		if strings.EqualFold(n, profile.AccountName) || strings.EqualFold(n, profile.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+// EmailDomain returns the lower case domain of the email address, or an empty
+// string if it is not an email address
+func EmailDomain(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[i+1:]))
+}
+
+// NormalizeDomain returns the domain in lower case, without a leading @ (as in
+// @example.net)
+func NormalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "@")
+}
+
+// memberOf returns the first of the groups that is one of the names
+func memberOf(groups []string, names []string) (string, bool) {
+	for _, g := range groups {
+		for _, n := range names {
+			if strings.EqualFold(g, n) {
+				return g, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,123 @@
+package user
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestPolicy(t *testing.T) {
+	spec.Run(t, "Policy", testPolicy, spec.Report(report.Terminal{}))
+}
+
+func testPolicy(t *testing.T, when spec.G, it spec.S) {
+	var profile *Profile
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		profile = &Profile{
+			Email:       "Tester@example.net",
+			AccountName: "corp\\tester",
+			Groups:      []string{"engineering", "contractors"},
+		}
+	})
+
+	it("authorizes every user when it is empty", func() {
+		Expect((&Policy{}).Authorize(profile)).To(Succeed())
+	})
+
+	when("there are domains", func() {
+		it("requires an email in one of the domains", func() {
+			Expect((&Policy{Domains: []string{"example.com", "@Example.net"}}).Authorize(profile)).To(Succeed())
+			err := (&Policy{Domains: []string{"example.com"}}).Authorize(profile)
+			Expect(err).To(Equal(NotAuthorizedError("email domain example.net is not authorized")))
+		})
+
+		it("does not match lookalike domains", func() {
+			profile.Email = "tester@evil-example.net"
+			Expect((&Policy{Domains: []string{"example.net"}}).Authorize(profile)).NotTo(Succeed())
+		})
+
+		it("only matches subdomains when asked to", func() {
+			profile.Email = "tester@eng.example.net"
+			Expect((&Policy{Domains: []string{"example.net"}}).Authorize(profile)).NotTo(Succeed())
+			Expect((&Policy{Domains: []string{"example.net"}, MatchSubdomains: true}).Authorize(profile)).To(Succeed())
+			profile.Email = "tester@engexample.net"
+			Expect((&Policy{Domains: []string{"example.net"}, MatchSubdomains: true}).Authorize(profile)).NotTo(Succeed())
+		})
+
+		it("does not authorize a user without an email", func() {
+			profile.Email = ""
+			Expect((&Policy{Domains: []string{"example.net"}}).Authorize(profile)).NotTo(Succeed())
+		})
+	})
+
+	when("there are users", func() {
+		it("denies a denied user by account name or email", func() {
+			err := (&Policy{Domains: []string{"example.net"}, DeniedUsers: []string{"CORP\\tester"}}).Authorize(profile)
+			Expect(err).To(Equal(NotAuthorizedError("your account has been denied access")))
+			Expect((&Policy{DeniedUsers: []string{"tester@example.net"}}).Authorize(profile)).NotTo(Succeed())
+		})
+
+		it("authorizes an allowed user outside of the domains and groups", func() {
+			profile.Email = "tester@partner.example.org"
+			policy := &Policy{
+				Domains:       []string{"example.net"},
+				AllowedUsers:  []string{"tester@partner.example.org"},
+				AllowedGroups: []string{"sales"},
+				DeniedGroups:  []string{"contractors"},
+			}
+			Expect(policy.Authorize(profile)).To(Succeed())
+		})
+
+		it("denies a user that is both allowed and denied", func() {
+			policy := &Policy{AllowedUsers: []string{"corp\\tester"}, DeniedUsers: []string{"corp\\tester"}}
+			Expect(policy.Authorize(profile)).NotTo(Succeed())
+		})
+	})
+
+	when("there are groups", func() {
+		it("denies members of a denied group", func() {
+			err := (&Policy{DeniedGroups: []string{"Contractors"}}).Authorize(profile)
+			Expect(err).To(Equal(NotAuthorizedError("members of group contractors are denied access")))
+		})
+
+		it("requires membership of an allowed group when there are allowed groups", func() {
+			Expect((&Policy{AllowedGroups: []string{"sales", "engineering"}}).Authorize(profile)).To(Succeed())
+			err := (&Policy{AllowedGroups: []string{"sales"}}).Authorize(profile)
+			Expect(err).To(Equal(NotAuthorizedError("you are not a member of a group that is allowed access")))
+		})
+
+		it("denies members of a denied group even when they are in an allowed group", func() {
+			policy := &Policy{
+				AllowedGroups: []string{"engineering"},
+				DeniedGroups:  []string{"contractors"},
+			}
+			Expect(policy.Authorize(profile)).NotTo(Succeed())
+		})
+	})
+
+	when("there are admins", func() {
+		it("makes users with an admin email admins", func() {
+			Expect((&Policy{AdminEmails: []string{"tester@example.net"}}).IsAdmin(profile)).To(BeTrue())
+			Expect((&Policy{AdminEmails: []string{"other@example.net"}}).IsAdmin(profile)).To(BeFalse())
+		})
+
+		it("makes members of an admin group admins", func() {
+			Expect((&Policy{AdminGroups: []string{"Engineering"}}).IsAdmin(profile)).To(BeTrue())
+			Expect((&Policy{AdminGroups: []string{"platform"}}).IsAdmin(profile)).To(BeFalse())
+		})
+
+		it("has no admins by default", func() {
+			Expect((&Policy{}).IsAdmin(profile)).To(BeFalse())
+			Expect((&Policy{AdminEmails: []string{""}}).IsAdmin(&Profile{})).To(BeFalse())
+		})
+	})
+
+	it("gets the domain of an email", func() {
+		Expect(EmailDomain("Tester@Example.NET")).To(Equal("example.net"))
+		Expect(EmailDomain("tester")).To(Equal(""))
+	})
+}
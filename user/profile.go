@@ -17,6 +17,14 @@ type Profile struct {
 	Email       string
 	AccountName string
 	Name        string
+	Groups      []string
+
+	// Subject identifies the user at the identity provider (the sub claim)
+	Subject string
+
+	// Foundation is the name of the foundation that the identity provider
+	// assigns the user to, if any
+	Foundation string
 }
 
 // unexported key type prevents collisions
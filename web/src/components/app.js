@@ -27,10 +27,15 @@ class App extends React.Component {
           window.location.replace('/login')
         } else if (response.status === 403) {
           this.setState({ forbidden: true })
+          return response.json().then(body => {
+            this.setState({ reason: body.reason })
+          })
         }
       })
       .then(info => {
-        this.setState({ info: info })
+        if (!this.state.forbidden) {
+          this.setState({ info: info })
+        }
       })
     window
       .fetch('/api/v1/profile', {
@@ -52,7 +57,7 @@ class App extends React.Component {
         <Redirect
           to={{
             pathname: '/forbidden',
-            state: { profile: this.state.profile }
+            state: { profile: this.state.profile, reason: this.state.reason }
           }}
         />
       )
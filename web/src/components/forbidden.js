@@ -98,9 +98,10 @@ class Forbidden extends React.Component {
 
   render () {
     const { classes } = this.props
-    let email
+    let email, reason
     if (this.props.location.state !== undefined) {
       email = this.props.location.state.profile.Email
+      reason = this.props.location.state.reason
     } else {
       this.setState({ redirect: true })
     }
@@ -121,6 +122,11 @@ class Forbidden extends React.Component {
                 You&apos;ve attempted to sign in with {email} which does not
                 grant you access.
               </div>
+              {reason && (
+                <div className={classes.text}>
+                  The reason given was: {reason}.
+                </div>
+              )}
               <div className={classes.text}>
                 Please sign in with your company email account.
               </div>
@@ -1,15 +1,28 @@
+const pollInterval = 1000
+const maxPolls = 120
+
+const sleep = ms => new Promise(resolve => setTimeout(resolve, ms))
+
 async function getOrgUrl () {
-  const response = await window.fetch('/api/v1/organization', {
-    credentials: 'same-origin'
-  })
-  if (!response.ok) {
-    return
-  }
-  const json = await response.json()
-  if (!json) {
-    return
+  for (let i = 0; i < maxPolls; i++) {
+    const response = await window.fetch('/api/v1/organization', {
+      credentials: 'same-origin'
+    })
+    if (response.status === 202) {
+      // the org is still being provisioned
+      await sleep(pollInterval)
+      continue
+    }
+    if (!response.ok) {
+      return
+    }
+    // the user's orgs, in each foundation that they have one in
+    const json = await response.json()
+    if (!json || json.length === 0) {
+      return
+    }
+    return json[0].url
   }
-  return json.url
 }
 
 export { getOrgUrl }
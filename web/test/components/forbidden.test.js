@@ -20,3 +20,19 @@ test('forbidden renders email address when present', () => {
   forbidden.setProps({ location: location })
   expect(forbidden.html().includes('sneal@example.com')).toBe(true)
 })
+
+test('forbidden renders the reason when present', () => {
+  const location = {
+    state: {
+      profile: {
+        Email: 'sneal@example.org'
+      },
+      reason: 'email domain example.org is not authorized'
+    }
+  }
+  const forbidden = shallow(<Forbidden />)
+  forbidden.setProps({ location: location })
+  expect(
+    forbidden.html().includes('email domain example.org is not authorized')
+  ).toBe(true)
+})